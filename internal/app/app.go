@@ -2,59 +2,325 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"text/template"
 	"time"
 
+	"go_scrap/internal/attribution"
+	"go_scrap/internal/cache"
 	"go_scrap/internal/fetch"
+	"go_scrap/internal/footprint"
+	"go_scrap/internal/markdown"
+	"go_scrap/internal/output"
+	"go_scrap/internal/policy"
+	"go_scrap/internal/presets"
+	"go_scrap/internal/seal"
+	"go_scrap/internal/tokenize"
+	"go_scrap/internal/warnings"
+
+	"github.com/PuerkitoBio/goquery"
 )
 
 type Options struct {
-	URL                string
+	URL string
+	// URLs are scraped each into its own pages/ directory, with merged
+	// outputs as after a crawl but without following links (--url-file).
+	URLs []string
+	// Mode is the fetch mode; empty means the preset's mode, else auto.
 	Mode               fetch.Mode
+	PreferView         string
 	OutputDir          string
+	PublishDir         string
 	Timeout            time.Duration
 	UserAgent          string
 	WaitFor            string
 	Headless           bool
+	Browser            fetch.Browser
+	ScrollToBottom     bool
+	ClickSelector      string
+	MaxScrolls         int
+	ScrollQuiet        time.Duration
 	RateLimitPerSecond float64
 	Yes                bool
 	Strict             bool
 	DryRun             bool
 	Stdout             bool
 	UseCache           bool
-	DownloadAssets     bool
-	NavSelector        string
-	ContentSelector    string
-	ExcludeSelector    string
-	NavWalk            bool
-	MaxSections        int
-	MaxMenuItems       int
-	MaxMarkdownBytes   int
-	MaxChars           int
-	MaxTokens          int
-	ProxyURL           string
-	AuthHeaders        map[string]string
-	AuthCookies        map[string]string
-	PipelineHooks      []string
-	PostCommands       []string
-	Crawl              bool
-	Resume             bool
-	SitemapURL         string
-	MaxPages           int
-	CrawlDepth         int
-	CrawlFilter        string
+	CacheDir           string
+	CacheMaxMB         int
+	// CacheTTL is how long a --cache entry is reused before the page is
+	// fetched again (0 = forever).
+	CacheTTL       time.Duration
+	EncryptCache   bool
+	Sign           string
+	SignKey        string
+	DownloadAssets bool
+	AssetNames     string
+	// AssetRetries is how many times a failed asset or media download is
+	// resumed, waiting AssetRetryBackoff, doubled each time, in between.
+	AssetRetries      int
+	AssetRetryBackoff time.Duration
+	// DownloadMedia lists the media types or extensions of linked files
+	// to download to media/ (see output.MediaTypes); "all" selects every one.
+	DownloadMedia     []string
+	NavSelector       string
+	ContentSelector   string
+	ExcludeSelector   string
+	ItemSelector      string
+	ItemTitleSelector string
+	ItemBodySelector  string
+	ItemDateSelector  string
+	NavWalk           bool
+	MaxSections       int
+	DropEmptySections bool
+	IncludeHeadings   string
+	ExcludeHeadings   string
+	Since             string
+	Until             string
+	MaxMenuItems      int
+	MaxMarkdownBytes  int
+	MaxChars          int
+	MaxTokens         int
+	// SplitByHeadingLevel writes content.md as one file per heading of this
+	// level or shallower, with content.md indexing them (0 = one file).
+	SplitByHeadingLevel int
+	// SplitIndexTemplate is a text/template file rendering the index of
+	// split Markdown; see output.LoadSplitIndexTemplate.
+	SplitIndexTemplate string
+	// ChunkTokens enables chunks.jsonl, windows of section Markdown of at
+	// most this many tokens overlapping by ChunkOverlap.
+	ChunkTokens  int
+	ChunkOverlap int
+	// Tokenizer counts tokens for MaxTokens, ChunkTokens and token
+	// estimates: tokenize.Cl100k, tokenize.O200k or tokenize.Approx (default).
+	Tokenizer         string
+	RenderConcurrency int
+	ConvertCacheSize  int
+	OmitContentText   bool
+	JSONFields        []string
+	JSONFormat        string
+	GzipJSON          bool
+	Newline           string
+	BOM               bool
+	FrontMatter       bool
+	Citation          string
+	CitationTemplate  string
+	ProxyURL          string
+	AuthHeaders       map[string]string
+	AuthCookies       map[string]string
+	Login             *fetch.Login
+	ClientCert        string
+	ClientKey         string
+	FetchMiddleware   []string
+	CacheProxy        string
+	PipelineHooks     []string
+	PostCommands      []string
+	PreFetchCommands  []string
+	HookTimeout       time.Duration // 0 = no limit, negative = DefaultHookTimeoutSeconds
+	HookEnv           []string
+	ScrubPatterns     []string
+	Crawl             bool
+	Resume            bool
+	Repair            bool
+	SitemapURL        string
+	MaxPages          int
+	MaxPagesMode      string
+	CrawlDepth        int
+	CrawlFilter       string
+	CrawlShardSize    int
+	DumpFrontier      bool
+	QueueDir          string
+	WorkerID          string
+	QueueLease        time.Duration
+	AnchorScope       string
+	MinPageChars      int
+	MaxPageChars      int
+	SoftPages         string
+	PageNames         string
+	PageTimeout       time.Duration
+	ProcessWorkers    int
+	StageDir          string
+	ConfigPath        string
+	ConfigDir         string
+	Seed              int64
+	Retry             fetch.RetryPolicy
+	URLRewrites       []fetch.RewriteRule
+	Preset            string
+	Sanitize          string
+	NormalizeUnicode  bool
+	Emoji             string
+	Slug              string
+	SlugPattern       string
+	// Watch re-runs the scrape at this interval until interrupted (see
+	// Watch).
+	Watch time.Duration
+	// RunID identifies the run in run.json and the crawl index; Run generates
+	// one when empty.
+	RunID string `json:"-"`
+	// NewConverter builds a Markdown converter for each pipeline worker
+	// (default: markdown.NewConverter).
+	NewConverter func() *markdown.Converter `json:"-"`
+	// HostLimiter, when set, spaces requests to each host on top of
+	// RateLimitPerSecond, e.g. one limiter run-all shares across configs.
+	HostLimiter *fetch.HostLimiter `json:"-"`
+	// Middleware wraps every fetch, outside the middleware named in
+	// FetchMiddleware (see fetch.RegisterMiddleware).
+	Middleware []fetch.Middleware `json:"-"`
+	// Policy limits the run; Run loads the system policy when it is nil
+	// (see policy.Find).
+	Policy *policy.Policy `json:"-"`
+
+	// sealer encrypts the HTML cache and crawl state with EncryptCache.
+	sealer *seal.Sealer
+	// tokenizer is the loaded Tokenizer.
+	tokenizer tokenize.Tokenizer
+	// storageState is the browser state saved by Login.
+	storageState string
+	// splitIndex is the parsed SplitIndexTemplate.
+	splitIndex *template.Template
+	// progress receives per-page progress output instead of stdout; crawl
+	// workers buffer it so pages are reported in URL order.
+	progress io.Writer
+	// fetchedAt is when the page was fetched, for citation footers and front
+	// matter.
+	fetchedAt time.Time
+	// assets is the run's shared asset store in a crawl with
+	// --download-assets, and assetRefDir the directory a page's files link
+	// to it from when that is not OutputDir.
+	assets      *output.AssetStore
+	assetRefDir string
+	// retrier applies Retry to page, anchor and crawl fetches, with jitter
+	// drawn from Seed.
+	retrier *fetch.Retrier
+	// rewriter applies URLRewrites to crawled links; seeds are rewritten
+	// when options are normalized.
+	rewriter *fetch.Rewriter
+	// watching marks the runs of Watch cycles, which leave a single page
+	// whose sections did not change as it is.
+	watching bool
+	// autoPresets are the presets --preset auto detects from, loaded once
+	// per run.
+	autoPresets []presets.Preset
+}
+
+// stdout is where per-page progress is printed.
+func (o Options) stdout() io.Writer {
+	if o.progress != nil {
+		return o.progress
+	}
+	return os.Stdout
 }
 
 func Run(ctx context.Context, opts Options) error {
-	normalized, err := normalizeOptions(opts)
+	if opts.Watch > 0 {
+		return Watch(ctx, opts)
+	}
+	if opts.Repair {
+		if !opts.Crawl {
+			return errors.New("--repair needs --crawl or --sitemap")
+		}
+		return RetryFailed(ctx, opts)
+	}
+	return run(ctx, opts, func(ctx context.Context, opts Options) error {
+		if opts.Crawl {
+			return runCrawl(ctx, opts)
+		}
+		if len(opts.URLs) > 0 {
+			return runURLList(ctx, opts)
+		}
+		return runSingle(ctx, opts)
+	})
+}
+
+// run validates opts and runs process with them, then writes the run
+// manifest, metrics and checksums of the output directory and, with
+// PublishDir, publishes it.
+func run(ctx context.Context, opts Options, process func(context.Context, Options) error) error {
+	startedAt := time.Now()
+	normalized, err := prepareRun(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if normalized.RunID == "" {
+		normalized.RunID = newRunID(startedAt)
+	}
+	pub, err := startPublish(&normalized)
 	if err != nil {
 		return err
 	}
 
-	if normalized.Crawl {
-		return runCrawl(ctx, normalized)
+	rec := footprint.New()
+	ctx = footprint.WithRecorder(ctx, rec)
+	if limiter := fetch.NewHostLimiter(normalized.RateLimitPerSecond).Within(normalized.HostLimiter); limiter != nil {
+		ctx = fetch.WithHostLimiter(ctx, limiter)
 	}
-	return runSingle(ctx, normalized)
+	warns := warnings.New(os.Stderr)
+	ctx = warnings.WithCollector(ctx, warns)
+	normalized, logout, err := logIn(ctx, normalized)
+	if err == nil {
+		var rt http.RoundTripper
+		if rt, err = fetch.Transport(buildFetchOptions(normalized, normalized.Mode)); err == nil {
+			ctx = fetch.WithTransport(ctx, rt)
+			err = process(ctx, normalized)
+		}
+	}
+	logout()
+	if merr := writeRunManifest(normalized, startedAt, err, warns.List()); merr != nil && !normalized.Stdout {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write run.json: %v\n", merr)
+	}
+	summary := rec.Summary()
+	if !normalized.Stdout {
+		summary.Print(os.Stdout)
+	}
+	if merr := writeMetrics(normalized, summary); merr != nil && !normalized.Stdout {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write metrics.json: %v\n", merr)
+	}
+	if cerr := writeChecksums(ctx, normalized, err == nil); cerr != nil {
+		if normalized.Sign != "" && err == nil {
+			return pub.finish(cerr)
+		}
+		if !normalized.Stdout {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write checksums: %v\n", cerr)
+		}
+	}
+	return pub.finish(err)
+}
+
+// prepareRun validates opts, applies the organization policy and sets up
+// cache encryption, the tokenizer and the split index template.
+func prepareRun(ctx context.Context, opts Options) (Options, error) {
+	normalized, err := normalizeOptions(opts)
+	if err != nil {
+		return opts, err
+	}
+	if normalized, err = applyPolicy(normalized); err != nil {
+		return opts, err
+	}
+	if normalized.EncryptCache {
+		if normalized.sealer, err = seal.FromEnv(ctx); err != nil {
+			return opts, fmt.Errorf("encrypt-cache: %w", err)
+		}
+	}
+	cacheDir := normalized.CacheDir
+	if cacheDir == "" {
+		cacheDir = cache.DefaultDir()
+	}
+	rt, err := fetch.Transport(buildFetchOptions(normalized, normalized.Mode))
+	if err != nil {
+		return opts, err
+	}
+	if normalized.tokenizer, err = tokenize.New(fetch.WithTransport(ctx, rt), normalized.Tokenizer, tokenize.Dir(cacheDir)); err != nil {
+		return opts, err
+	}
+	if normalized.splitIndex, err = output.LoadSplitIndexTemplate(normalized.SplitIndexTemplate); err != nil {
+		return opts, err
+	}
+	return normalized, nil
 }
 
 func runSingle(ctx context.Context, opts Options) error {
@@ -62,23 +328,49 @@ func runSingle(ctx context.Context, opts Options) error {
 	if err != nil {
 		return err
 	}
-	baseDoc, fetchResult, err := prepareBaseDocument(ctx, pipeline, opts)
+	if err := pipeline.runBeforeFetchHooks(ctx, &opts); err != nil {
+		return err
+	}
+	opts.assets = openAssets(opts)
+	baseDoc, fetchResult, err := prepareBaseDocument(ctx, pipeline, &opts)
 	if err != nil {
 		return err
 	}
+	err = pipeline.processSingle(ctx, opts, baseDoc, fetchResult)
+	writeAssetIndex(ctx, opts)
+	return err
+}
 
-	analysis, err := pipeline.analyze(ctx, opts, baseDoc, true)
+// processSingle analyzes a fetched page and writes its outputs.
+func (p *pipeline) processSingle(ctx context.Context, opts Options, baseDoc *goquery.Document, fetchResult fetch.Result) error {
+	analysis, err := p.analyze(ctx, opts, baseDoc, true)
 	if err != nil {
 		return err
 	}
-	pipeline.summarize(opts, fetchResult.SourceInfo, analysis)
+	p.summarize(opts, fetchResult.SourceInfo, analysis)
+	p.printDryRunEstimate(ctx, opts, baseDoc, analysis)
+	printRunDiff(opts, func() (runDiff, bool, error) { return singleRunDiff(opts, analysis.Doc) })
+	if opts.watching {
+		if d, ok, err := singleRunDiff(opts, analysis.Doc); err == nil && ok && !d.changed() {
+			if !opts.Stdout {
+				fmt.Printf("No changes; left %s as it is\n", opts.OutputDir)
+			}
+			return nil
+		}
+	}
 
-	if !pipeline.shouldWrite(opts) {
+	if !p.shouldWrite(opts) {
 		return nil
 	}
 
 	analysis.Trim(opts.MaxSections)
-	return pipeline.writeOutputs(ctx, opts, baseDoc, analysis)
+	if err := p.writeOutputs(ctx, opts, baseDoc, analysis); err != nil {
+		return err
+	}
+	if page, ok := detectAttribution(opts.URL, fetchResult.HTML, opts.fetchedAt); ok {
+		writeAttribution(ctx, opts, []attribution.Page{page})
+	}
+	return nil
 }
 
 func runCrawl(ctx context.Context, opts Options) error {
@@ -86,13 +378,27 @@ func runCrawl(ctx context.Context, opts Options) error {
 	if err != nil {
 		return err
 	}
-	c, baseURL, err := initCrawler(ctx, opts)
+	if err := pipeline.runBeforeFetchHooks(ctx, &opts); err != nil {
+		return err
+	}
+	queue, err := openCrawlQueue(opts)
+	if err != nil {
+		return err
+	}
+	rootDir := opts.OutputDir
+	if queue != nil {
+		opts.OutputDir = filepath.Join(rootDir, "workers", queue.Worker())
+	}
+	c, baseURL, sitemapPages, err := initCrawler(ctx, opts, queue)
 	if err != nil {
 		return err
 	}
 
 	if !opts.Stdout {
 		fmt.Printf("Starting crawl from %s (max %d pages, depth %d)\n", baseURL, opts.MaxPages, opts.CrawlDepth)
+		if queue != nil {
+			fmt.Printf("Sharing crawl queue %s as worker %s\n", opts.QueueDir, queue.Worker())
+		}
 	}
 
 	results, stats, err := c.Crawl(ctx)
@@ -102,11 +408,44 @@ func runCrawl(ctx context.Context, opts Options) error {
 
 	if !opts.Stdout {
 		fmt.Printf("Crawl complete: %d pages crawled, %d failed\n", stats.PagesCrawled, stats.PagesFailed)
+		if n := len(stats.ThrottleEvents); n > 0 {
+			fmt.Printf("Throttled %d time(s) (429/503); slowed down and retried per Retry-After\n", n)
+		}
 	}
 
+	frontier := c.Frontier()
+	if len(frontier) > 0 && !opts.Stdout {
+		fmt.Printf("Frontier: %d URL(s) found but not crawled (max pages reached)\n", len(frontier))
+	}
+	reportMaxPages(ctx, baseURL, stats.MaxPages)
+	if opts.DryRun && !opts.Stdout {
+		est, err := pipeline.estimateCrawl(ctx, opts, results, sitemapPages)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: dry-run estimate failed: %v\n", err)
+		} else {
+			printEstimate(opts, est)
+		}
+	}
+	printRunDiff(opts, func() (runDiff, bool, error) { return crawlRunDiff(opts, results) })
+
 	if !pipeline.shouldWrite(opts) {
 		return nil
 	}
+	if opts.DumpFrontier {
+		writeFrontier(ctx, opts, frontier)
+	}
 
-	return processCrawlResults(ctx, pipeline, opts, results, stats)
+	// A crawl that ran out of time still writes what it collected; explicit
+	// cancellation stops output work as well.
+	writeCtx := ctx
+	if errors.Is(err, context.DeadlineExceeded) {
+		writeCtx = context.WithoutCancel(ctx)
+	}
+	if err := processCrawlResults(writeCtx, pipeline, opts, results, stats); err != nil {
+		return err
+	}
+	if queue != nil && !opts.Stdout {
+		return finishSharedCrawl(ctx, queue, opts, rootDir, baseURL)
+	}
+	return nil
 }
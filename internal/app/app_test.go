@@ -2,16 +2,27 @@ package app_test
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"go_scrap/internal/app"
 	"go_scrap/internal/fetch"
+	"go_scrap/internal/footprint"
+	"go_scrap/internal/output"
+	"go_scrap/internal/policy"
+	"go_scrap/internal/warnings"
 )
 
 func TestRun_StaticHTML_NoSelectors(t *testing.T) {
@@ -180,3 +191,1254 @@ func TestRun_EmptyURL(t *testing.T) {
 		t.Errorf("expected 'url is required' error, got: %v", err)
 	}
 }
+
+func TestRun_WritesRunManifest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body><main class="content"><h1 id="h">Title</h1><p>Body</p></main></body></html>`))
+	}))
+	defer srv.Close()
+
+	tmpDir := t.TempDir()
+	cfgPath := filepath.Join(tmpDir, "cfg.json")
+	if err := os.WriteFile(cfgPath, []byte(`{"url":"x"}`), 0600); err != nil {
+		t.Fatalf("write cfg: %v", err)
+	}
+	outDir := filepath.Join(tmpDir, "out")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := app.Options{
+		URL:             srv.URL,
+		Mode:            fetch.ModeStatic,
+		Timeout:         5 * time.Second,
+		Yes:             true,
+		Headless:        true,
+		UserAgent:       "test",
+		ContentSelector: ".content",
+		OutputDir:       outDir,
+		ConfigPath:      cfgPath,
+		Seed:            42,
+		AuthHeaders:     map[string]string{"Authorization": "Bearer secret"},
+	}
+	if err := app.Run(ctx, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outDir, "run.json"))
+	if err != nil {
+		t.Fatalf("missing run.json: %v", err)
+	}
+	if strings.Contains(string(data), "secret") {
+		t.Fatalf("run.json leaked credentials: %s", data)
+	}
+	var manifest app.RunManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("unmarshal run.json: %v", err)
+	}
+	if manifest.Seed != 42 || manifest.ConfigHash == "" || manifest.Options.URL != srv.URL {
+		t.Fatalf("unexpected manifest: %+v", manifest)
+	}
+	if manifest.Tool.Version == "" || manifest.CompletedAt.Before(manifest.StartedAt) {
+		t.Fatalf("unexpected tool/timing info: %+v", manifest)
+	}
+}
+
+func TestRun_WritesMetrics(t *testing.T) {
+	page := `<html><body><main class="content"><h1 id="h">Title</h1><p>Body</p></main></body></html>`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(page))
+	}))
+	defer srv.Close()
+
+	outDir := filepath.Join(t.TempDir(), "out")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := app.Options{
+		URL:             srv.URL,
+		Mode:            fetch.ModeStatic,
+		Timeout:         5 * time.Second,
+		Yes:             true,
+		Headless:        true,
+		UserAgent:       "test",
+		ContentSelector: ".content",
+		OutputDir:       outDir,
+	}
+	if err := app.Run(ctx, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outDir, "metrics.json"))
+	if err != nil {
+		t.Fatalf("missing metrics.json: %v", err)
+	}
+	var summary footprint.Summary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		t.Fatalf("unmarshal metrics.json: %v", err)
+	}
+	host := strings.TrimPrefix(srv.URL, "http://")
+	if summary.Requests != 1 || summary.Bytes != int64(len(page)) || summary.Domains[host].Requests != 1 {
+		t.Fatalf("unexpected metrics: %+v", summary)
+	}
+}
+
+func TestRun_SharedQueueMergesWorkerOutputs(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body><main class="content"><h1 id="h">Home</h1><a href="/a">A</a> <a href="/b">B</a></main></body></html>`))
+	})
+	for _, p := range []string{"/a", "/b"} {
+		mux.HandleFunc(p, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(`<html><body><main class="content"><h1 id="h">Page ` + r.URL.Path + `</h1><p>Body</p></main></body></html>`))
+		})
+	}
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	tmpDir := t.TempDir()
+	outDir := filepath.Join(tmpDir, "out")
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	// The first worker stops after one page, leaving the rest queued for the
+	// second, which drains the queue and merges both outputs.
+	for i, worker := range []string{"one", "two"} {
+		opts := app.Options{
+			URL:                srv.URL,
+			Mode:               fetch.ModeStatic,
+			Timeout:            5 * time.Second,
+			Yes:                true,
+			Headless:           true,
+			UserAgent:          "test",
+			ContentSelector:    ".content",
+			OutputDir:          outDir,
+			Crawl:              true,
+			MaxPages:           1 + i*10,
+			CrawlDepth:         2,
+			RateLimitPerSecond: 50,
+			QueueDir:           filepath.Join(tmpDir, "queue"),
+			WorkerID:           worker,
+		}
+		if err := app.Run(ctx, opts); err != nil {
+			t.Fatalf("worker %s: %v", worker, err)
+		}
+		_, err := os.Stat(filepath.Join(outDir, "crawl-index.json"))
+		if worker == "one" && err == nil {
+			t.Fatal("first worker should not merge while URLs are still queued")
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(outDir, "crawl-index.json"))
+	if err != nil {
+		t.Fatalf("missing merged crawl-index.json: %v", err)
+	}
+	var index struct {
+		RunID        string `json:"run_id"`
+		PagesCrawled int    `json:"pages_crawled"`
+		Pages        []struct {
+			URL string `json:"url"`
+		} `json:"pages"`
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		t.Fatalf("unmarshal crawl index: %v", err)
+	}
+	if index.RunID == "" || index.PagesCrawled != 3 || len(index.Pages) != 3 {
+		t.Fatalf("expected 3 pages across workers, got %+v", index)
+	}
+	for _, name := range []string{"index.jsonl", "index.html", filepath.Join("workers", "one", "crawl-index.json")} {
+		if _, err := os.Stat(filepath.Join(outDir, name)); err != nil {
+			t.Fatalf("missing %s: %v", name, err)
+		}
+	}
+}
+
+func TestRun_ManifestRecordsWarnings(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body><h1 id="h">Title</h1><p>Body <img src="/missing.png"></p></body></html>`))
+	})
+	mux.HandleFunc("/missing.png", http.NotFound)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	outDir := filepath.Join(t.TempDir(), "out")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := app.Options{
+		URL:             srv.URL,
+		Mode:            fetch.ModeStatic,
+		Timeout:         5 * time.Second,
+		Yes:             true,
+		Headless:        true,
+		UserAgent:       "test",
+		ContentSelector: ".does-not-exist",
+		DownloadAssets:  true,
+		OutputDir:       outDir,
+	}
+	if err := app.Run(ctx, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outDir, "run.json"))
+	if err != nil {
+		t.Fatalf("missing run.json: %v", err)
+	}
+	var manifest app.RunManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("unmarshal run.json: %v", err)
+	}
+	if manifest.RunID == "" {
+		t.Fatal("expected a run ID")
+	}
+	codes := map[string]map[string]string{}
+	for _, w := range manifest.Warnings {
+		codes[w.Code] = w.Context
+	}
+	if ctx, ok := codes[warnings.CodeSelectorFallback]; !ok || ctx["selector"] != ".does-not-exist" {
+		t.Fatalf("expected a selector_fallback warning, got %+v", manifest.Warnings)
+	}
+	if ctx, ok := codes[warnings.CodeAssetDownload]; !ok || ctx["asset"] != srv.URL+"/missing.png" {
+		t.Fatalf("expected an asset_download_failed warning, got %+v", manifest.Warnings)
+	}
+}
+
+func TestRun_PolicyBlocksDeniedRunsAndContentTypes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/report" {
+			w.Header().Set("Content-Type", "application/pdf")
+			_, _ = w.Write([]byte("%PDF-1.4"))
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body><h1 id="h">Title</h1><p>Body</p></body></html>`))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	outDir := t.TempDir()
+	base := app.Options{
+		Mode:      fetch.ModeStatic,
+		OutputDir: outDir,
+		Timeout:   5 * time.Second,
+		Yes:       true,
+		DryRun:    true,
+		UserAgent: "test",
+	}
+
+	opts := base
+	opts.URL = srv.URL + "/"
+	opts.Policy = &policy.Policy{Source: "test-policy", DeniedOutputDirs: []string{outDir}}
+	err := app.Run(ctx, opts)
+	var perr *policy.Error
+	if !errors.As(err, &perr) || !strings.Contains(err.Error(), "test-policy") {
+		t.Fatalf("expected a policy violation for the output dir, got %v", err)
+	}
+
+	opts = base
+	opts.URL = srv.URL + "/report"
+	opts.Policy = &policy.Policy{Source: "test-policy", DeniedContentTypes: []string{"application/pdf"}}
+	if err := app.Run(ctx, opts); !errors.As(err, &perr) {
+		t.Fatalf("expected the PDF response to be blocked, got %v", err)
+	}
+
+	opts.URL = srv.URL + "/"
+	if err := app.Run(ctx, opts); err != nil {
+		t.Fatalf("expected an allowed page to run, got %v", err)
+	}
+}
+
+func TestRun_ItemSelectorWritesOneSectionPerItem(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body><h1>Changelog</h1>
+			<article class="entry"><h2>1.2.0</h2><time datetime="2024-03-02">March 2</time><p>Added export.</p></article>
+			<article class="entry"><h2>1.1.0</h2><time datetime="2024-01-15">January 15</time><p>Fixed login.</p></article>
+		</body></html>`))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	outDir := t.TempDir()
+	opts := app.Options{
+		URL:              srv.URL,
+		Mode:             fetch.ModeStatic,
+		OutputDir:        outDir,
+		Timeout:          5 * time.Second,
+		Yes:              true,
+		UserAgent:        "test",
+		ItemSelector:     "article.entry",
+		ItemDateSelector: "time",
+	}
+	if err := app.Run(ctx, opts); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(outDir, "index.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 item records, got %d:\n%s", len(lines), data)
+	}
+	var rec struct {
+		Heading string `json:"heading"`
+		Date    string `json:"date"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Heading != "1.2.0" || rec.Date != "2024-03-02" {
+		t.Fatalf("unexpected first record %+v", rec)
+	}
+}
+
+func TestRun_PreferViewFallsBackToTheNextViewOrThePage(t *testing.T) {
+	body := strings.Repeat("Install the tool and run it. ", 20)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/docs", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><head><link rel="amphtml" href="/docs/amp"></head><body>
+			<nav><a href="/">Home</a> <a href="/docs?print=1">Print</a></nav>
+			<h1>Page chrome</h1><p>%s</p></body></html>`, body)
+	})
+	mux.HandleFunc("/docs/amp", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><head><title>Page not found</title></head><body><h1>Oops</h1></body></html>`))
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/docs" && r.URL.Query().Get("print") == "1" {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintf(w, `<html><body><h1>Printable</h1><p>%s</p></body></html>`, body)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		prefer, heading string
+	}{
+		{app.PreferViewAny, "Printable"},
+		{app.PreferViewAMP, "Page chrome"},
+	} {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		outDir := t.TempDir()
+		err := app.Run(ctx, app.Options{
+			URL:        srv.URL + "/docs",
+			Mode:       fetch.ModeStatic,
+			PreferView: tc.prefer,
+			OutputDir:  outDir,
+			Timeout:    5 * time.Second,
+			Yes:        true,
+			UserAgent:  "test",
+		})
+		cancel()
+		if err != nil {
+			t.Fatalf("%s: run: %v", tc.prefer, err)
+		}
+		data, err := os.ReadFile(filepath.Join(outDir, "index.jsonl"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), `"heading":"`+tc.heading+`"`) {
+			t.Fatalf("%s: expected heading %q in index.jsonl:\n%s", tc.prefer, tc.heading, data)
+		}
+		runJSON, err := os.ReadFile(filepath.Join(outDir, "run.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(runJSON), "view_fallback") {
+			t.Fatalf("%s: expected the skipped AMP view as a warning in run.json:\n%s", tc.prefer, runJSON)
+		}
+	}
+}
+
+func TestRun_AuthorsInCorpusAndFrontMatter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><head><meta name="author" content="Jane Doe">
+			<meta property="article:published_time" content="2024-05-01"></head>
+			<body><h1>Post</h1><p>Body.</p><h2>Details</h2><p>More.</p>
+			<a rel="author" href="/authors/jane">Jane Doe</a></body></html>`))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	outDir := t.TempDir()
+	opts := app.Options{
+		URL:         srv.URL,
+		Mode:        fetch.ModeStatic,
+		OutputDir:   outDir,
+		Timeout:     5 * time.Second,
+		Yes:         true,
+		UserAgent:   "test",
+		FrontMatter: true,
+	}
+	if err := app.Run(ctx, opts); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	corpus, err := os.ReadFile(filepath.Join(outDir, "corpus.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(corpus)), "\n") {
+		var rec struct {
+			Authors []string `json:"authors"`
+		}
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatal(err)
+		}
+		if len(rec.Authors) != 1 || rec.Authors[0] != "Jane Doe" {
+			t.Fatalf("expected every corpus record to carry the author, got %s", line)
+		}
+	}
+	md, err := os.ReadFile(filepath.Join(outDir, "content.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantFM := regexp.MustCompile(`^---\ntitle: "Post"\nsource_url: "` + regexp.QuoteMeta(srv.URL) + `"\nfetched_at: "[0-9T:-]+Z"\npublished: "2024-05-01"\nauthors:\n  - name: "Jane Doe"\n    url: "` + regexp.QuoteMeta(srv.URL) + `/authors/jane"\ncontent_hash: "([0-9a-f]{64})"\n---\n\n`)
+	m := wantFM.FindStringSubmatch(string(md))
+	if m == nil {
+		t.Fatalf("expected front matter matching\n%s\ngot\n%s", wantFM, md)
+	}
+	body := strings.TrimSpace(string(md)[len(m[0]):])
+	if sum := sha256.Sum256([]byte(body)); m[1] != hex.EncodeToString(sum[:]) {
+		t.Fatalf("content_hash %s does not hash the Markdown after the front matter", m[1])
+	}
+}
+
+func TestRun_FrontMatterOnSectionFiles(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body>
+			<nav class="menu"><a href="#intro">Introduction</a><a href="#guide">Guide</a></nav>
+			<main><h1 id="intro">Introduction</h1><p>Intro content</p>
+			<h2 id="guide">Guide</h2><p>Guide content</p></main></body></html>`))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	outDir := t.TempDir()
+	opts := app.Options{
+		URL:         srv.URL,
+		Mode:        fetch.ModeStatic,
+		OutputDir:   outDir,
+		Timeout:     5 * time.Second,
+		Yes:         true,
+		UserAgent:   "test",
+		NavSelector: ".menu",
+		FrontMatter: true,
+	}
+	if err := app.Run(ctx, opts); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	index, err := os.ReadFile(filepath.Join(outDir, "index.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sectionIDs := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(index)), "\n") {
+		var rec struct {
+			ID          string `json:"id"`
+			HeadingPath string `json:"heading_path"`
+		}
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatal(err)
+		}
+		sectionIDs[rec.HeadingPath] = rec.ID
+	}
+
+	files, err := filepath.Glob(filepath.Join(outDir, "sections", "*.md"))
+	if err != nil || len(files) != 2 {
+		t.Fatalf("expected 2 section files, got %v (%v)", files, err)
+	}
+	var guide string
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "Guide content") {
+			guide = string(data)
+		}
+	}
+	want := "---\ntitle: \"Guide\"\nsource_url: \"" + srv.URL + "#guide\"\nheading_path: \"Introduction > Guide\"\nsection_id: \"" + sectionIDs["Introduction > Guide"] + "\"\nfetched_at: "
+	if sectionIDs["Introduction > Guide"] == "" || !strings.HasPrefix(guide, want) {
+		t.Fatalf("expected section front matter starting\n%s\ngot\n%s", want, guide)
+	}
+	if !strings.Contains(guide, "content_hash: \"") {
+		t.Fatalf("expected a content_hash in\n%s", guide)
+	}
+}
+
+func TestRun_SplitByHeadingLevel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body><h1 id="api">API</h1><p>Intro.</p>
+			<h2 id="users">Users</h2><p>User endpoints.</p><h3 id="list">List users</h3><p>GET /users</p>
+			<h2 id="orders">Orders</h2><p>Order endpoints.</p></body></html>`))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	outDir := t.TempDir()
+	opts := app.Options{
+		URL:                 srv.URL,
+		Mode:                fetch.ModeStatic,
+		OutputDir:           outDir,
+		Timeout:             5 * time.Second,
+		Yes:                 true,
+		UserAgent:           "test",
+		SplitByHeadingLevel: 2,
+		FrontMatter:         true,
+	}
+	if err := app.Run(ctx, opts); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	index, err := os.ReadFile(filepath.Join(outDir, "content.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(index), "- [Users](content/002-users.md)") || !strings.Contains(string(index), "- [Orders](content/003-orders.md)") {
+		t.Fatalf("expected content.md to index the files, got\n%s", index)
+	}
+	users, err := os.ReadFile(filepath.Join(outDir, "content", "002-users.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(users)
+	if !strings.HasPrefix(got, "---\ntitle: \"Users\"\nsource_url: \""+srv.URL+"#users\"\n") {
+		t.Fatalf("expected the file to start with the Users front matter, got\n%s", got)
+	}
+	if !strings.Contains(got, "\n# Users\n") || !strings.Contains(got, "\n## List users\n") || strings.Contains(got, "Orders") {
+		t.Fatalf("expected the Users subtree with headings raised one level, got\n%s", got)
+	}
+}
+
+func TestRun_MediaLinksInventory(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body><h1 id="downloads">Downloads</h1>
+			<p><a href="/files/guide.pdf">Guide</a>, <a href="/files/src.zip">source</a> and <a href="/about">about</a>.</p>
+			<video><source src="/media/intro.mp4" type="video/mp4"></video></body></html>`))
+	})
+	mux.HandleFunc("/files/guide.pdf", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("%PDF-1.4"))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	outDir := t.TempDir()
+	opts := app.Options{
+		URL:           srv.URL,
+		Mode:          fetch.ModeStatic,
+		OutputDir:     outDir,
+		Timeout:       5 * time.Second,
+		Yes:           true,
+		UserAgent:     "test",
+		DownloadMedia: []string{"pdf"},
+	}
+	if err := app.Run(ctx, opts); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(outDir, "media-links.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var inv output.MediaInventory
+	if err := json.Unmarshal(data, &inv); err != nil {
+		t.Fatal(err)
+	}
+	if inv.Total != 3 || inv.Counts["pdf"] != 1 || inv.Counts["archive"] != 1 || inv.Counts["video"] != 1 {
+		t.Fatalf("expected a pdf, an archive and a video, got %+v", inv)
+	}
+	for _, link := range inv.Links {
+		switch link.Type {
+		case "pdf":
+			got, err := os.ReadFile(filepath.Join(outDir, filepath.FromSlash(link.LocalPath)))
+			if err != nil || string(got) != "%PDF-1.4" {
+				t.Fatalf("expected the pdf downloaded to %q, got %q (%v)", link.LocalPath, got, err)
+			}
+		default:
+			if link.LocalPath != "" {
+				t.Fatalf("expected only pdfs downloaded, got %+v", link)
+			}
+		}
+	}
+}
+
+func TestRun_CitationFooterPerSection(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body><h1 id="post">Post</h1><p>Body.</p><h2 id="details">Details</h2><p>More.</p></body></html>`))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	outDir := t.TempDir()
+	opts := app.Options{
+		URL:              srv.URL,
+		Mode:             fetch.ModeStatic,
+		OutputDir:        outDir,
+		Timeout:          5 * time.Second,
+		Yes:              true,
+		UserAgent:        "test",
+		Citation:         app.CitationSection,
+		CitationTemplate: "Source: {section_url} ({heading}, fetched {date})",
+	}
+	if err := app.Run(ctx, opts); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	today := time.Now().UTC().Format("2006-01-02")
+	corpus, err := os.ReadFile(filepath.Join(outDir, "corpus.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Source: " + srv.URL + "#details (Details, fetched " + today + ")"
+	if !strings.Contains(string(corpus), want) {
+		t.Fatalf("expected the section's corpus record to end with %q, got\n%s", want, corpus)
+	}
+	md, err := os.ReadFile(filepath.Join(outDir, "content.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(string(md), "Source: "); got != 2 {
+		t.Fatalf("expected one footer per section in content.md, got %d:\n%s", got, md)
+	}
+
+	opts.CitationTemplate = "Source: {link}"
+	if err := app.Run(ctx, opts); err == nil || !strings.Contains(err.Error(), "{link}") {
+		t.Fatalf("expected an unknown placeholder to be rejected, got %v", err)
+	}
+}
+
+func TestRun_RevalidatesExpiredCacheEntries(t *testing.T) {
+	full, notModified := 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full++
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body><h1>Guide</h1><p>Cached body.</p></body></html>`))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	opts := app.Options{
+		URL:       srv.URL,
+		Mode:      fetch.ModeStatic,
+		OutputDir: t.TempDir(),
+		Timeout:   5 * time.Second,
+		Yes:       true,
+		UserAgent: "test",
+		UseCache:  true,
+		CacheDir:  t.TempDir(),
+		// Every entry is expired by the next run.
+		CacheTTL: time.Nanosecond,
+	}
+	for i := 0; i < 2; i++ {
+		if err := app.Run(ctx, opts); err != nil {
+			t.Fatalf("run %d: %v", i+1, err)
+		}
+	}
+	if full != 1 || notModified != 1 {
+		t.Fatalf("expected one full fetch then one 304, got %d full and %d not modified", full, notModified)
+	}
+	md, err := os.ReadFile(filepath.Join(opts.OutputDir, "content.md"))
+	if err != nil || !strings.Contains(string(md), "Cached body.") {
+		t.Fatalf("expected the cached page to be converted after a 304, got %q (%v)", md, err)
+	}
+}
+
+func TestFetchThenTransform_WorksOffline(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body><h1>Guide</h1><p>Read me.</p><p id="extra">Extra notes.</p></body></html>`))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stageDir := t.TempDir()
+	opts := app.Options{
+		URL:       srv.URL,
+		Mode:      fetch.ModeStatic,
+		Timeout:   5 * time.Second,
+		Yes:       true,
+		UserAgent: "test",
+		StageDir:  stageDir,
+	}
+	if err := app.Fetch(ctx, opts); err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	srv.Close()
+
+	// Iterate on selectors against the staged HTML.
+	for _, exclude := range []string{"", "#extra"} {
+		outDir := t.TempDir()
+		transform := app.Options{StageDir: stageDir, OutputDir: outDir, Yes: true, ExcludeSelector: exclude}
+		if err := app.Transform(ctx, transform); err != nil {
+			t.Fatalf("transform excluding %q: %v", exclude, err)
+		}
+		md, err := os.ReadFile(filepath.Join(outDir, "content.md"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hasExtra := strings.Contains(string(md), "Extra notes"); hasExtra != (exclude == "") {
+			t.Fatalf("exclude selector %q not applied to staged HTML:\n%s", exclude, md)
+		}
+	}
+	if requests != 1 {
+		t.Fatalf("expected a single fetch, got %d requests", requests)
+	}
+}
+
+func TestFetchThenTransform_Crawl(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body><h1>Home</h1><p>Start here.</p><a href="/guide">Guide</a></body></html>`))
+	})
+	mux.HandleFunc("/guide", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body><h1>Guide</h1><p>Read me.</p></body></html>`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stageDir := t.TempDir()
+	err := app.Fetch(ctx, app.Options{
+		URL:                srv.URL,
+		Crawl:              true,
+		MaxPages:           5,
+		CrawlDepth:         2,
+		RateLimitPerSecond: 50,
+		Timeout:            5 * time.Second,
+		UserAgent:          "test",
+		StageDir:           stageDir,
+	})
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	srv.Close()
+
+	outDir := t.TempDir()
+	if err := app.Transform(ctx, app.Options{StageDir: stageDir, OutputDir: outDir, Yes: true}); err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(outDir, "crawl-index.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var index struct {
+		Pages []struct {
+			URL    string `json:"url"`
+			Status string `json:"status"`
+		} `json:"pages"`
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Pages) != 2 || index.Pages[0].Status != "success" || index.Pages[1].Status != "success" {
+		t.Fatalf("expected both staged pages to be written, got %s", data)
+	}
+}
+
+func TestRetryFailed_MergesRecoveredPages(t *testing.T) {
+	var guideRequests atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body><h1>Home</h1><p>Start here.</p><a href="/guide">Guide</a></body></html>`))
+	})
+	mux.HandleFunc("/guide", func(w http.ResponseWriter, _ *http.Request) {
+		if guideRequests.Add(1) == 1 {
+			http.Error(w, "try again", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body><h1>Guide</h1><p>Read me.</p></body></html>`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	outDir := t.TempDir()
+	opts := app.Options{
+		URL:                srv.URL,
+		Crawl:              true,
+		MaxPages:           5,
+		CrawlDepth:         2,
+		RateLimitPerSecond: 50,
+		Timeout:            5 * time.Second,
+		UserAgent:          "test",
+		OutputDir:          outDir,
+		Yes:                true,
+		// Leave the failure for RetryFailed rather than the crawl's retries.
+		Retry: fetch.RetryPolicy{MaxAttempts: 1},
+	}
+	if err := app.Run(ctx, opts); err != nil {
+		t.Fatalf("crawl: %v", err)
+	}
+	readStatuses := func() map[string]string {
+		index, err := output.ReadCrawlIndex(outDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		statuses := map[string]string{}
+		for _, page := range index.Pages {
+			statuses[page.URL] = page.Status
+		}
+		return statuses
+	}
+	if got := readStatuses()[srv.URL+"/guide"]; got != "error" {
+		t.Fatalf("expected the guide to fail on the first crawl, got %q", got)
+	}
+
+	err := app.RetryFailed(ctx, app.Options{OutputDir: outDir, Mode: fetch.ModeStatic, Timeout: 5 * time.Second, UserAgent: "test", Yes: true})
+	if err != nil {
+		t.Fatalf("retry-failed: %v", err)
+	}
+	statuses := readStatuses()
+	if len(statuses) != 2 || statuses[srv.URL+"/guide"] != "success" || statuses[srv.URL+"/"] != "success" {
+		t.Fatalf("expected the retried page merged into the index, got %v", statuses)
+	}
+	corpus, err := os.ReadFile(filepath.Join(outDir, "corpus.jsonl"))
+	if err != nil || !strings.Contains(string(corpus), "Read me.") || !strings.Contains(string(corpus), "Start here.") {
+		t.Fatalf("expected the merged corpus to hold both pages, got %s (%v)", corpus, err)
+	}
+	if n := guideRequests.Load(); n != 2 {
+		t.Fatalf("expected the guide to be fetched twice, got %d", n)
+	}
+}
+
+func TestRun_RepairRefetchesIncompletePages(t *testing.T) {
+	var homeRequests, guideRequests atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+		homeRequests.Add(1)
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body><h1>Home</h1><p>Start here.</p><a href="/guide">Guide</a></body></html>`))
+	})
+	mux.HandleFunc("/guide", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if guideRequests.Add(1) == 1 {
+			_, _ = w.Write([]byte(`<html><body><h1>Guide</h1><p>Read <a href="#setup">setup</a>.</p><h2>Setup</h2></body></html>`))
+			return
+		}
+		_, _ = w.Write([]byte(`<html><body><h1>Guide</h1><p>Read <a href="#setup">setup</a>.</p><h2 id="setup">Setup</h2><p>Done.</p></body></html>`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	outDir := t.TempDir()
+	opts := app.Options{
+		URL:                srv.URL,
+		Mode:               fetch.ModeStatic,
+		Crawl:              true,
+		MaxPages:           5,
+		CrawlDepth:         2,
+		RateLimitPerSecond: 50,
+		Timeout:            5 * time.Second,
+		UserAgent:          "test",
+		OutputDir:          outDir,
+		Yes:                true,
+	}
+	if err := app.Run(ctx, opts); err != nil {
+		t.Fatalf("crawl: %v", err)
+	}
+	index, err := output.ReadCrawlIndex(outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, page := range index.Pages {
+		if page.URL == srv.URL+"/guide" && (page.BrokenAnchors != 1 || page.EmptySections != 1) {
+			t.Fatalf("expected the guide's issues in the crawl index, got %+v", page)
+		}
+	}
+
+	opts.Repair = true
+	if err := app.Run(ctx, opts); err != nil {
+		t.Fatalf("repair: %v", err)
+	}
+	if homeRequests.Load() != 1 || guideRequests.Load() != 2 {
+		t.Fatalf("expected only the guide to be fetched again, got home %d guide %d", homeRequests.Load(), guideRequests.Load())
+	}
+	data, err := os.ReadFile(filepath.Join(outDir, "repair.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summary struct {
+		Pages   int `json:"pages"`
+		Fixed   int `json:"fixed"`
+		Results []struct {
+			URL     string `json:"url"`
+			Outcome string `json:"outcome"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(data, &summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.Pages != 1 || summary.Fixed != 1 || summary.Results[0].URL != srv.URL+"/guide" {
+		t.Fatalf("unexpected repair summary %s", data)
+	}
+	corpus, err := os.ReadFile(filepath.Join(outDir, "corpus.jsonl"))
+	if err != nil || !strings.Contains(string(corpus), "Done.") || !strings.Contains(string(corpus), "Start here.") {
+		t.Fatalf("expected the repaired page merged into the corpus, got %s (%v)", corpus, err)
+	}
+}
+
+func TestRun_CrawlNamesPagesFromTitles(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><head><title>Welcome Home</title></head><body><h1>Home</h1><p>Start.</p>
+			<a href="/a/setup">A</a><a href="/b/setup">B</a></body></html>`))
+	})
+	for _, path := range []string{"/a/setup", "/b/setup"} {
+		mux.HandleFunc(path, func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(`<html><body><h1>Setup Guide</h1><p>Steps.</p></body></html>`))
+		})
+	}
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	outDir := t.TempDir()
+	opts := app.Options{
+		URL:                srv.URL,
+		Mode:               fetch.ModeStatic,
+		Crawl:              true,
+		MaxPages:           5,
+		CrawlDepth:         2,
+		RateLimitPerSecond: 50,
+		Timeout:            5 * time.Second,
+		UserAgent:          "test",
+		OutputDir:          outDir,
+		Yes:                true,
+		PageNames:          app.PageNamesTitle,
+		FrontMatter:        true,
+	}
+	if err := app.Run(ctx, opts); err != nil {
+		t.Fatalf("crawl: %v", err)
+	}
+
+	index, err := output.ReadCrawlIndex(outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string][2]string{}
+	for _, page := range index.Pages {
+		got[page.URL] = [2]string{page.Title, page.OutputDir}
+	}
+	want := map[string][2]string{
+		srv.URL + "/":        {"Welcome Home", "pages/welcome_home"},
+		srv.URL + "/a/setup": {"Setup Guide", "pages/setup_guide"},
+		srv.URL + "/b/setup": {"Setup Guide", "pages/setup_guide-2"},
+	}
+	for pageURL, w := range want {
+		if got[pageURL] != w {
+			t.Fatalf("expected %s to be %v, got %v", pageURL, w, got)
+		}
+	}
+	md, err := os.ReadFile(filepath.Join(outDir, "pages", "setup_guide-2", "content.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(md), "---\ntitle: \"Setup Guide\"\nsource_url: \""+srv.URL+"/b/setup\"\n") {
+		t.Fatalf("expected front matter with the title and URL, got\n%s", md)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "pages", ".staging")); !os.IsNotExist(err) {
+		t.Fatalf("expected the staging directory to be removed, got %v", err)
+	}
+	corpus, err := os.ReadFile(filepath.Join(outDir, "corpus.jsonl"))
+	if err != nil || strings.Count(string(corpus), "Steps.") != 2 {
+		t.Fatalf("expected the merged corpus to hold both setup pages, got %s (%v)", corpus, err)
+	}
+}
+
+func TestRun_CrawlSharesDownloadedAssetsAcrossPages(t *testing.T) {
+	var logoFetches atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body><h1>Home</h1><p>Start <img src="/logo.png"></p>
+			<a href="/docs/a">A</a><a href="/docs/b">B</a></body></html>`))
+	})
+	for _, path := range []string{"/docs/a", "/docs/b"} {
+		mux.HandleFunc(path, func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(`<html><body><h1>Doc</h1><p>Body <img src="/logo.png"><img src="/copy/logo.png"></p></body></html>`))
+		})
+	}
+	mux.HandleFunc("/logo.png", func(w http.ResponseWriter, _ *http.Request) {
+		logoFetches.Add(1)
+		_, _ = w.Write([]byte("png bytes"))
+	})
+	mux.HandleFunc("/copy/logo.png", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("png bytes"))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	outDir := t.TempDir()
+	opts := app.Options{
+		URL:                srv.URL,
+		Mode:               fetch.ModeStatic,
+		Crawl:              true,
+		MaxPages:           5,
+		CrawlDepth:         2,
+		RateLimitPerSecond: 50,
+		ProcessWorkers:     3,
+		Timeout:            5 * time.Second,
+		UserAgent:          "test",
+		OutputDir:          outDir,
+		Yes:                true,
+		DownloadAssets:     true,
+	}
+	if err := app.Run(ctx, opts); err != nil {
+		t.Fatalf("crawl: %v", err)
+	}
+
+	if n := logoFetches.Load(); n != 1 {
+		t.Fatalf("expected the shared logo to be fetched once, got %d", n)
+	}
+	entries, err := os.ReadDir(filepath.Join(outDir, "assets"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []string
+	for _, e := range entries {
+		if e.Name() != output.AssetsManifestName {
+			files = append(files, e.Name())
+		}
+	}
+	if len(files) != 1 {
+		t.Fatalf("expected identical images to be stored once, got %v", files)
+	}
+	md, err := os.ReadFile(filepath.Join(outDir, "pages", "docs", "a", "content.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(md), "](../../../assets/"+files[0]+")") {
+		t.Fatalf("expected a link to the shared asset, got\n%s", md)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "pages", "docs", "a", "assets")); !os.IsNotExist(err) {
+		t.Fatalf("expected no per-page assets directory, got %v", err)
+	}
+}
+
+func TestRun_URLListScrapesEachPageWithoutFollowingLinks(t *testing.T) {
+	mux := http.NewServeMux()
+	for _, path := range []string{"/a", "/b", "/c"} {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(`<html><body><h1>Page ` + r.URL.Path + `</h1><p>Body of ` + r.URL.Path + `.</p><a href="/c">C</a></body></html>`))
+		})
+	}
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	outDir := t.TempDir()
+	opts := app.Options{
+		URLs:      []string{srv.URL + "/a", srv.URL + "/b", srv.URL + "/missing"},
+		Mode:      fetch.ModeStatic,
+		Timeout:   5 * time.Second,
+		UserAgent: "test",
+		OutputDir: outDir,
+		Yes:       true,
+	}
+	if err := app.Run(ctx, opts); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	index, err := output.ReadCrawlIndex(outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := map[string]string{}
+	for _, page := range index.Pages {
+		status[page.URL] = page.Status
+	}
+	if len(status) != 3 || status[srv.URL+"/a"] != "success" || status[srv.URL+"/b"] != "success" || status[srv.URL+"/missing"] != "error" {
+		t.Fatalf("expected the listed pages only, got %v", status)
+	}
+	for _, page := range []string{"a", "b"} {
+		if _, err := os.Stat(filepath.Join(outDir, "pages", page, "content.md")); err != nil {
+			t.Fatalf("expected pages/%s/content.md: %v", page, err)
+		}
+	}
+	corpus, err := os.ReadFile(filepath.Join(outDir, "corpus.jsonl"))
+	if err != nil || !strings.Contains(string(corpus), "Body of /a.") || !strings.Contains(string(corpus), "Body of /b.") || strings.Contains(string(corpus), "Body of /c.") {
+		t.Fatalf("expected the merged corpus to hold the listed pages, got %s (%v)", corpus, err)
+	}
+}
+
+func TestRun_PublishDirSwapsInSuccessfulRunsOnly(t *testing.T) {
+	title := "First"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = fmt.Fprintf(w, `<html><body><main class="content"><h1 id="h">%s</h1><p>Body</p></main></body></html>`, title)
+	}))
+	defer srv.Close()
+
+	publishDir := filepath.Join(t.TempDir(), "site")
+	opts := app.Options{
+		URL:             srv.URL,
+		Mode:            fetch.ModeStatic,
+		Timeout:         5 * time.Second,
+		Yes:             true,
+		UserAgent:       "test",
+		ContentSelector: ".content",
+		PublishDir:      publishDir,
+	}
+	readContent := func() string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(publishDir, "content.md"))
+		if err != nil {
+			t.Fatalf("read published content: %v", err)
+		}
+		return string(data)
+	}
+
+	for _, want := range []string{"First", "Second", "Third"} {
+		title = want
+		if err := app.Run(context.Background(), opts); err != nil {
+			t.Fatalf("run %s: %v", want, err)
+		}
+		if got := readContent(); !strings.Contains(got, want) {
+			t.Fatalf("expected the %s run to be published, got %q", want, got)
+		}
+	}
+	if info, err := os.Lstat(publishDir); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("expected %s to be a symlink to the release, got %v, %v", publishDir, info, err)
+	}
+	releases, err := os.ReadDir(filepath.Join(filepath.Dir(publishDir), ".site.releases"))
+	if err != nil || len(releases) != 2 {
+		t.Fatalf("expected the current and previous release to be kept, got %v, %v", releases, err)
+	}
+
+	srv.Close()
+	if err := app.Run(context.Background(), opts); err == nil {
+		t.Fatal("expected the run against a stopped server to fail")
+	}
+	if got := readContent(); !strings.Contains(got, "Third") {
+		t.Fatalf("a failed run must leave the published output alone, got %q", got)
+	}
+	if after, _ := os.ReadDir(filepath.Join(filepath.Dir(publishDir), ".site.releases")); len(after) != 2 {
+		t.Fatalf("expected the failed release to be removed, got %v", after)
+	}
+}
+
+func TestWatch_LogsChangedHeadingsPerCycle(t *testing.T) {
+	outDir := t.TempDir()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	pages := []string{
+		`<html><body><h1>Guide</h1><h2>Install</h2><p>Run make.</p><h2>Usage</h2><p>Call it.</p></body></html>`,
+		`<html><body><h1>Guide</h1><h2>Install</h2><p>Run make install.</p><h2>Config</h2><p>Edit it.</p></body></html>`,
+	}
+	var hits atomic.Int32
+	var rewrittenAt atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		n := hits.Add(1)
+		if n == 3 {
+			if info, err := os.Stat(filepath.Join(outDir, "content.md")); err == nil {
+				rewrittenAt.Store(info.ModTime())
+			}
+		}
+		if n == 4 {
+			cancel()
+		}
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(pages[min(int(n), 2)-1]))
+	}))
+	defer srv.Close()
+
+	opts := app.Options{
+		URL:       srv.URL,
+		Mode:      fetch.ModeStatic,
+		Timeout:   5 * time.Second,
+		UserAgent: "test",
+		OutputDir: outDir,
+		Watch:     20 * time.Millisecond,
+	}
+	if err := app.Run(ctx, opts); err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outDir, app.WatchLogName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cycles []app.WatchCycle
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var c app.WatchCycle
+		if err := json.Unmarshal([]byte(line), &c); err != nil {
+			t.Fatalf("bad log line %q: %v", line, err)
+		}
+		cycles = append(cycles, c)
+	}
+	if len(cycles) != 3 {
+		t.Fatalf("expected 3 logged cycles, got %d: %s", len(cycles), data)
+	}
+	if len(cycles[0].Added) != 3 || cycles[0].Error != "" {
+		t.Fatalf("expected the first cycle to add every heading, got %+v", cycles[0])
+	}
+	second := cycles[1]
+	if len(second.Added) != 1 || !strings.Contains(second.Added[0], "Config") ||
+		len(second.Removed) != 1 || !strings.Contains(second.Removed[0], "Usage") ||
+		len(second.Changed) != 1 || !strings.Contains(second.Changed[0], "Install") {
+		t.Fatalf("expected Config added, Usage removed and Install changed, got %+v", second)
+	}
+	third := cycles[2]
+	if len(third.Added)+len(third.Removed)+len(third.Changed) != 0 || third.Unchanged != 3 {
+		t.Fatalf("expected an unchanged third cycle, got %+v", third)
+	}
+	info, err := os.Stat(filepath.Join(outDir, "content.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before, ok := rewrittenAt.Load().(time.Time); !ok || !info.ModTime().Equal(before) {
+		t.Fatalf("expected the unchanged cycle to leave content.md alone")
+	}
+}
@@ -0,0 +1,31 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go_scrap/internal/attribution"
+	"go_scrap/internal/parse"
+)
+
+// detectAttribution scans the raw page, before exclusions strip footers.
+func detectAttribution(pageURL, rawHTML string, accessedAt time.Time) (attribution.Page, bool) {
+	doc, err := parse.NewDocument(rawHTML)
+	if err != nil {
+		return attribution.Page{}, false
+	}
+	return attribution.Detect(doc, pageURL, accessedAt), true
+}
+
+func writeAttribution(ctx context.Context, opts Options, pages []attribution.Page) {
+	if opts.Stdout || len(pages) == 0 {
+		return
+	}
+	path, err := attribution.Write(opts.OutputDir, pages)
+	if err != nil {
+		warnOutputWrite(ctx, "ATTRIBUTION.md", err)
+		return
+	}
+	fmt.Printf("Wrote attribution: %s\n", path)
+}
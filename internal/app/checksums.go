@@ -0,0 +1,66 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"go_scrap/internal/output"
+)
+
+// Tools --sign runs on checksums.sha256 after a run.
+const (
+	SignMinisign = "minisign"
+	SignCosign   = "cosign"
+)
+
+// writeChecksums records the SHA-256 of every output file and, when sign is
+// set and opts.Sign names a tool, signs the checksums file with it.
+func writeChecksums(ctx context.Context, opts Options, sign bool) error {
+	if opts.DryRun {
+		return nil
+	}
+	if info, err := os.Stat(opts.OutputDir); err != nil || !info.IsDir() {
+		return nil
+	}
+	path, err := output.WriteChecksums(opts.OutputDir)
+	if err != nil {
+		return err
+	}
+	if !sign || opts.Sign == "" {
+		return nil
+	}
+	// The signer may prompt for a key password or an OIDC login, so it
+	// must not inherit the run's deadline.
+	cmd := signCommand(context.WithoutCancel(ctx), opts, path)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("sign %s with %s: %w", output.ChecksumsFile, opts.Sign, err)
+	}
+	return nil
+}
+
+// signCommand builds the signer invocation. minisign writes
+// checksums.sha256.minisig; cosign writes checksums.sha256.sig, plus
+// checksums.sha256.pem when signing keyless.
+func signCommand(ctx context.Context, opts Options, path string) *exec.Cmd {
+	switch opts.Sign {
+	case SignCosign:
+		args := []string{"sign-blob", "--yes", "--output-signature", path + ".sig"}
+		if opts.SignKey != "" {
+			args = append(args, "--key", opts.SignKey)
+		} else {
+			args = append(args, "--output-certificate", path+".pem")
+		}
+		return exec.CommandContext(ctx, "cosign", append(args, path)...)
+	default:
+		args := []string{"-S", "-m", path}
+		if opts.SignKey != "" {
+			args = append(args, "-s", opts.SignKey)
+		}
+		return exec.CommandContext(ctx, "minisign", args...)
+	}
+}
@@ -2,40 +2,70 @@ package app
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
+	"go_scrap/internal/attribution"
 	"go_scrap/internal/crawler"
+	"go_scrap/internal/fetch"
+	"go_scrap/internal/fsutil"
 	"go_scrap/internal/output"
+	"go_scrap/internal/report"
+	"go_scrap/internal/warnings"
 )
 
-func initCrawler(ctx context.Context, opts Options) (*crawler.Crawler, string, error) {
+// crawlStateDir (inside the output directory) holds the visited set and
+// fetched pages of a crawl in progress; --resume continues from it after a
+// restart.
+const crawlStateDir = ".crawl-state"
+
+// initCrawler creates the crawler and seeds it from the sitemap. It also
+// returns how many sitemap URLs are in crawl scope (-1 without a sitemap).
+func initCrawler(ctx context.Context, opts Options, queue *crawler.FileQueue) (*crawler.Crawler, string, int, error) {
 	urlFilter, err := buildURLFilter(opts.CrawlFilter)
 	if err != nil {
-		return nil, "", err
+		return nil, "", 0, err
 	}
 
 	baseURL, err := determineBaseURL(opts)
 	if err != nil {
-		return nil, "", err
+		return nil, "", 0, err
 	}
 
+	tlsConfig, err := fetch.ClientTLSConfig(opts.ClientCert, opts.ClientKey)
+	if err != nil {
+		return nil, "", 0, err
+	}
 	crawlerOpts := buildCrawlerOptions(opts, baseURL, urlFilter)
+	crawlerOpts.TLSConfig = tlsConfig
+	crawlerOpts.WrapTransport = wrapTransport(opts)
+	crawlerOpts.Limiter = fetch.HostLimiterFrom(ctx)
+	if queue != nil {
+		crawlerOpts.Queue = queue
+	} else if !opts.DryRun && !opts.Stdout {
+		crawlerOpts.StateDir = filepath.Join(opts.OutputDir, crawlStateDir)
+		crawlerOpts.ResumeState = opts.Resume
+		crawlerOpts.Sealer = opts.sealer
+	}
 
 	c, err := crawler.New(crawlerOpts)
 	if err != nil {
-		return nil, "", fmt.Errorf("create crawler: %w", err)
+		return nil, "", 0, fmt.Errorf("create crawler: %w", err)
 	}
 
-	if err := addSitemapURLs(ctx, c, opts); err != nil {
-		return nil, "", err
+	sitemapPages, err := addSitemapURLs(ctx, c, opts, tlsConfig)
+	if err != nil {
+		return nil, "", 0, err
 	}
 
-	return c, baseURL, nil
+	return c, baseURL, sitemapPages, nil
 }
 
 func buildURLFilter(filter string) (*regexp.Regexp, error) {
@@ -53,6 +83,13 @@ func determineBaseURL(opts Options) (string, error) {
 	if opts.URL != "" {
 		return opts.URL, nil
 	}
+	if len(opts.URLs) > 0 {
+		u, err := url.Parse(opts.URLs[0])
+		if err != nil {
+			return "", fmt.Errorf("invalid URL: %w", err)
+		}
+		return u.Scheme + "://" + u.Host, nil
+	}
 	if opts.SitemapURL != "" {
 		u, err := url.Parse(opts.SitemapURL)
 		if err != nil {
@@ -65,99 +102,360 @@ func determineBaseURL(opts Options) (string, error) {
 
 func buildCrawlerOptions(opts Options, baseURL string, urlFilter *regexp.Regexp) crawler.Options {
 	crawlerOpts := crawler.Options{
-		BaseURL:     baseURL,
-		RateLimit:   opts.RateLimitPerSecond,
-		Parallelism: 2,
-		UserAgent:   opts.UserAgent,
-		MaxDepth:    opts.CrawlDepth,
-		MaxPages:    opts.MaxPages,
-		URLFilter:   urlFilter,
-		Timeout:     opts.Timeout,
-		ProxyURL:    opts.ProxyURL,
-		Headers:     opts.AuthHeaders,
-		Cookies:     opts.AuthCookies,
+		BaseURL:      baseURL,
+		RateLimit:    opts.RateLimitPerSecond,
+		Parallelism:  2,
+		UserAgent:    opts.UserAgent,
+		MaxDepth:     opts.CrawlDepth,
+		MaxPages:     opts.MaxPages,
+		MaxPagesMode: crawler.MaxPagesMode(opts.MaxPagesMode),
+		URLFilter:    urlFilter,
+		Timeout:      opts.Timeout,
+		ProxyURL:     opts.ProxyURL,
+		Headers:      opts.AuthHeaders,
+		Cookies:      opts.AuthCookies,
+		Retrier:      retrier(opts),
+		Rewriter:     opts.rewriter,
 	}
 	if crawlerOpts.RateLimit <= 0 {
-		crawlerOpts.RateLimit = 1.0
+		crawlerOpts.RateLimit = crawlerDefaultRateLimit
 	}
 	return crawlerOpts
 }
 
-func addSitemapURLs(ctx context.Context, c *crawler.Crawler, opts Options) error {
+// addSitemapURLs queues the sitemap's URLs and returns how many of them are
+// in crawl scope, or -1 when there is no sitemap.
+func addSitemapURLs(ctx context.Context, c *crawler.Crawler, opts Options, tlsConfig *tls.Config) (int, error) {
 	if opts.SitemapURL == "" {
-		return nil
+		return -1, nil
 	}
 	sitemapURLs, err := crawler.ParseSitemap(ctx, opts.SitemapURL, crawler.SitemapOptions{
-		UserAgent: opts.UserAgent,
-		Timeout:   opts.Timeout,
+		UserAgent:     opts.UserAgent,
+		Timeout:       opts.Timeout,
+		TLSConfig:     tlsConfig,
+		WrapTransport: wrapTransport(opts),
 	})
 	if err != nil {
-		return fmt.Errorf("parse sitemap: %w", err)
+		return 0, fmt.Errorf("parse sitemap: %w", err)
 	}
 	if !opts.Stdout {
 		fmt.Printf("Found %d URLs in sitemap\n", len(sitemapURLs))
 	}
 	if err := c.AddURLs(sitemapURLs); err != nil {
-		return fmt.Errorf("add sitemap URLs: %w", err)
+		return 0, fmt.Errorf("add sitemap URLs: %w", err)
 	}
-	return nil
+	inScope := 0
+	for _, u := range sitemapURLs {
+		if c.InScope(u) {
+			inScope++
+		}
+	}
+	return inScope, nil
 }
 
-func processCrawlResults(ctx context.Context, pipeline *pipeline, opts Options, results map[string]*crawler.Result, stats crawler.Stats) error {
+func processCrawlResults(ctx context.Context, p *pipeline, opts Options, results map[string]*crawler.Result, stats crawler.Stats) error {
 	pagesDir := filepath.Join(opts.OutputDir, "pages")
+	opts.assets = openAssets(opts)
 	pageSections := []output.PageSectionCount{}
+	pageDirs := map[string]string{}
+	attributions := []attribution.Page{}
+	anchorPages := []report.PageAnchors{}
 	resumeEntries, err := loadResumeEntries(opts)
 	if err != nil {
 		return err
 	}
 
-	for pageURL, result := range results {
-		if resumeEntry, ok := resumeEntries[pageURL]; ok && shouldResumeSkip(opts, result, resumeEntry) {
-			pageDir, dirErr := urlToOutputDir(pageURL, pagesDir)
-			if dirErr == nil {
-				if _, err := os.Stat(pageDir); err == nil {
-					if resumeEntry.Status == "success" {
-						pageSections = append(pageSections, output.PageSectionCount{
-							URL:      pageURL,
-							Sections: resumeEntry.SectionCount,
-						})
-					}
-					if !opts.Stdout {
-						fmt.Printf("Skipped (unchanged): %s\n", pageDir)
-					}
-					continue
-				}
-			}
+	urls := make([]string, 0, len(results))
+	for pageURL := range results {
+		urls = append(urls, pageURL)
+	}
+	sort.Strings(urls)
+	earlier := make([]crawler.PageEntry, 0, len(resumeEntries))
+	for _, entry := range resumeEntries {
+		earlier = append(earlier, entry)
+	}
+	namer := newPageNamer(opts, pagesDir, earlier)
+	if namer != nil {
+		defer namer.cleanup()
+	}
+	process := func(ctx context.Context, worker *pipeline, pageURL string) *crawlPageOutcome {
+		return worker.crawlPageOutcome(ctx, opts, pageURL, results[pageURL], pagesDir, resumeEntries)
+	}
+	// Outcomes are merged in URL order however many workers ran, so
+	// progress, warnings, page names and the crawl index are the same on
+	// every run.
+	err = processCrawlPages(ctx, p, opts, urls, process, func(outcome *crawlPageOutcome) error {
+		if outcome.err != nil {
+			return outcome.err
 		}
-
-		summary := pipeline.processCrawlPage(ctx, opts, pageURL, result, pagesDir)
-		if summary.Processed {
-			pageSections = append(pageSections, output.PageSectionCount{
-				URL:      pageURL,
-				Sections: summary.Sections,
-			})
-			if !opts.Stdout {
-				fmt.Printf("Wrote: %s (%d sections)\n", summary.OutputDir, summary.Sections)
-			}
-			continue
+		if err := outcome.place(namer, pagesDir, opts.assets); err != nil {
+			return err
+		}
+		_, _ = outcome.progress.WriteTo(opts.stdout())
+		for _, w := range outcome.warnings.List() {
+			warnings.Report(ctx, w)
+		}
+		if outcome.attribution != nil {
+			attributions = append(attributions, *outcome.attribution)
+		}
+		if outcome.anchors != nil {
+			anchorPages = append(anchorPages, *outcome.anchors)
+		}
+		if outcome.pageDir != "" {
+			pageDirs[outcome.url] = outcome.pageDir
 		}
-		if summary.Skipped {
-			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %s\n", pageURL, summary.SkipReason)
-			continue
+		if outcome.section != nil {
+			pageSections = append(pageSections, *outcome.section)
 		}
-		if summary.ProcessError != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to process %s: %v\n", pageURL, summary.ProcessError)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	writeAssetIndex(ctx, opts)
+	if hits, misses := p.convertCache.Stats(); hits > 0 && !opts.Stdout {
+		fmt.Printf("Markdown cache: %d of %d section conversions reused\n", hits, hits+misses)
+	}
+	writeAttribution(ctx, opts, attributions)
+	if !opts.Stdout {
+		if err := writeMergedIndexes(opts.OutputDir, pageDirs); err != nil {
+			return fmt.Errorf("write merged index: %w", err)
 		}
 	}
 
 	baseURL, _ := determineBaseURL(opts)
-	if err := output.WriteCrawlIndexFromPages(opts.OutputDir, results, stats, baseURL, pageSections, opts.Stdout); err != nil {
+	index := output.BuildCrawlIndex(results, stats, baseURL, pageSections)
+	index.RunID = opts.RunID
+	index.Warnings = warnings.From(ctx).List()
+	if err := output.WriteShardedCrawlIndex(opts.OutputDir, index, opts.CrawlShardSize, opts.Stdout); err != nil {
 		return fmt.Errorf("write crawl index: %w", err)
 	}
+	if !opts.Stdout {
+		writeCrawlBrowseHTML(ctx, opts.OutputDir, baseURL, pageDirs)
+	}
+	if opts.AnchorScope == AnchorScopeCrawl {
+		return checkCrossPageAnchors(ctx, opts, anchorPages)
+	}
 
 	return nil
 }
 
+// openAssets opens the assets/ store shared by the run's pages with
+// --download-assets.
+func openAssets(opts Options) *output.AssetStore {
+	if !opts.DownloadAssets || opts.DryRun {
+		return nil
+	}
+	store := output.OpenAssetStore(filepath.Join(opts.OutputDir, "assets"))
+	store.OriginalNames = opts.AssetNames == AssetNamesOriginal
+	store.Retry = assetRetry(opts)
+	return store
+}
+
+// assetRetry is the retry policy for asset and media downloads.
+func assetRetry(opts Options) output.AssetRetry {
+	return output.AssetRetry{Retries: opts.AssetRetries, Backoff: opts.AssetRetryBackoff}
+}
+
+// writeAssetIndex writes the index of the run's asset store.
+func writeAssetIndex(ctx context.Context, opts Options) {
+	if opts.assets == nil {
+		return
+	}
+	path, err := opts.assets.WriteManifest()
+	if err != nil {
+		warnOutputWrite(ctx, output.AssetsManifestName, err)
+		return
+	}
+	if path != "" && !opts.Stdout {
+		fmt.Fprintf(opts.stdout(), "Wrote assets index: %s\n", path)
+	}
+}
+
+// openCrawlQueue opens the shared crawl queue, or returns nil when the crawl
+// is not shared.
+func openCrawlQueue(opts Options) (*crawler.FileQueue, error) {
+	if opts.QueueDir == "" {
+		return nil, nil
+	}
+	queue, err := crawler.NewFileQueue(opts.QueueDir, opts.WorkerID, opts.QueueLease)
+	if err != nil {
+		return nil, fmt.Errorf("open crawl queue: %w", err)
+	}
+	return queue, nil
+}
+
+// finishSharedCrawl merges the outputs of every worker under rootDir/workers
+// into rootDir once the shared queue is drained. Workers that finish while
+// others still hold URLs leave the merge to the last one.
+func finishSharedCrawl(ctx context.Context, queue crawler.Queue, opts Options, rootDir, baseURL string) error {
+	drained, err := queue.Drained()
+	if err != nil {
+		return fmt.Errorf("check crawl queue: %w", err)
+	}
+	if !drained {
+		fmt.Println("Crawl queue not drained yet; the last worker to finish merges the index")
+		return nil
+	}
+	return mergeWorkerOutputs(ctx, opts, rootDir, baseURL)
+}
+
+// mergeWorkerOutputs writes the root crawl-index.json, merged index, corpus
+// and index.html over the page directories of every worker.
+func mergeWorkerOutputs(ctx context.Context, opts Options, rootDir, baseURL string) error {
+	workerDirs, err := filepath.Glob(filepath.Join(rootDir, "workers", "*"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(workerDirs)
+	indexes := make([]crawler.CrawlIndex, 0, len(workerDirs))
+	pageDirs := map[string]string{}
+	for _, dir := range workerDirs {
+		index, err := output.ReadCrawlIndex(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("read worker crawl index: %w", err)
+		}
+		indexes = append(indexes, index)
+		for _, page := range index.Pages {
+			if page.Status != "success" {
+				continue
+			}
+			if _, ok := pageDirs[page.URL]; ok {
+				continue
+			}
+			pageDir, err := pageOutputDir(page, dir)
+			if err != nil {
+				continue
+			}
+			if _, err := os.Stat(pageDir); err == nil {
+				pageDirs[page.URL] = pageDir
+			}
+		}
+	}
+
+	if err := writeMergedIndexes(rootDir, pageDirs); err != nil {
+		return fmt.Errorf("write merged index: %w", err)
+	}
+	merged := output.MergeCrawlIndexes(baseURL, indexes)
+	merged.RunID = opts.RunID
+	if err := output.WriteShardedCrawlIndex(rootDir, merged, opts.CrawlShardSize, opts.Stdout); err != nil {
+		return fmt.Errorf("write crawl index: %w", err)
+	}
+	writeCrawlBrowseHTML(ctx, rootDir, baseURL, pageDirs)
+	return nil
+}
+
+// writeMergedIndexes combines every page's index.jsonl, corpus.jsonl,
+// chunks.jsonl, media-links.json and external-links.json into single files at the crawl root, ordered by page URL so
+// reruns produce identical output.
+func writeMergedIndexes(outDir string, pageDirs map[string]string) error {
+	urls := make([]string, 0, len(pageDirs))
+	for pageURL := range pageDirs {
+		urls = append(urls, pageURL)
+	}
+	sort.Strings(urls)
+	indexPaths := make([]string, 0, len(urls))
+	corpusPaths := make([]string, 0, len(urls))
+	var chunkPaths, mediaPaths, externalPaths []string
+	for _, pageURL := range urls {
+		indexPaths = append(indexPaths, filepath.Join(pageDirs[pageURL], "index.jsonl"))
+		corpusPaths = append(corpusPaths, filepath.Join(pageDirs[pageURL], "corpus.jsonl"))
+		// chunks.jsonl is only written with --chunk-tokens.
+		chunks := filepath.Join(pageDirs[pageURL], "chunks.jsonl")
+		if _, err := os.Stat(chunks); err == nil {
+			chunkPaths = append(chunkPaths, chunks)
+		}
+		// media-links.json only exists for pages linking to non-HTML files.
+		media := filepath.Join(pageDirs[pageURL], "media-links.json")
+		if _, err := os.Stat(media); err == nil {
+			mediaPaths = append(mediaPaths, media)
+		}
+		// external-links.json only exists for pages linking off-site.
+		external := filepath.Join(pageDirs[pageURL], "external-links.json")
+		if _, err := os.Stat(external); err == nil {
+			externalPaths = append(externalPaths, external)
+		}
+	}
+	path, err := output.MergeIndexes(outDir, indexPaths)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Wrote merged index: %s\n", path)
+	path, err = output.MergeCorpora(outDir, corpusPaths)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Wrote corpus: %s\n", path)
+	if len(chunkPaths) > 0 {
+		path, err = output.MergeChunks(outDir, chunkPaths)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Wrote chunks: %s\n", path)
+	}
+	if len(mediaPaths) > 0 {
+		path, err = output.MergeMediaLinks(outDir, mediaPaths)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Wrote media links: %s\n", path)
+	}
+	if len(externalPaths) > 0 {
+		path, err = output.MergeExternalLinks(outDir, externalPaths)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Wrote external links: %s\n", path)
+	}
+	return nil
+}
+
+// writeCrawlBrowseHTML writes the root index.html over the merged index,
+// linking each page to its content.md.
+func writeCrawlBrowseHTML(ctx context.Context, outDir, baseURL string, pageDirs map[string]string) {
+	pages := make([]output.BrowsePage, 0, len(pageDirs))
+	for pageURL, dir := range pageDirs {
+		page := output.BrowsePage{URL: pageURL}
+		if rel, err := filepath.Rel(outDir, filepath.Join(dir, "content.md")); err == nil {
+			page.Markdown = filepath.ToSlash(rel)
+		}
+		pages = append(pages, page)
+	}
+	sort.Slice(pages, func(i, j int) bool { return pages[i].URL < pages[j].URL })
+	path, err := output.WriteBrowseHTML(outDir, baseURL, pages, nil)
+	if err != nil {
+		warnOutputWrite(ctx, "index.html", err)
+		return
+	}
+	fmt.Printf("Wrote browser index: %s\n", path)
+}
+
+// writeFrontier writes the URLs a capped crawl left unvisited to
+// frontier.txt, one per line, for a follow-up run.
+func writeFrontier(ctx context.Context, opts Options, frontier []string) {
+	path := filepath.Join(opts.OutputDir, "frontier.txt")
+	if err := fsutil.MkdirAll(opts.OutputDir, 0755); err != nil {
+		warnOutputWrite(ctx, "frontier.txt", err)
+		return
+	}
+	var b strings.Builder
+	for _, link := range frontier {
+		b.WriteString(link)
+		b.WriteByte('\n')
+	}
+	if err := fsutil.WriteFile(path, []byte(b.String()), 0600); err != nil {
+		warnOutputWrite(ctx, "frontier.txt", err)
+		return
+	}
+	if !opts.Stdout {
+		fmt.Printf("Wrote frontier: %s (%d URLs)\n", path, len(frontier))
+	}
+}
+
 func loadResumeEntries(opts Options) (map[string]crawler.PageEntry, error) {
 	if !opts.Resume {
 		return nil, nil
@@ -206,16 +504,61 @@ func urlToOutputDir(pageURL, baseDir string) (string, error) {
 	return filepath.Join(baseDir, filepath.Join(parts...)), nil
 }
 
+// windowsReserved are device names Windows refuses as file or directory
+// names, with or without an extension.
+var windowsReserved = map[string]bool{
+	"con": true, "prn": true, "aux": true, "nul": true,
+	"com1": true, "com2": true, "com3": true, "com4": true, "com5": true, "com6": true, "com7": true, "com8": true, "com9": true,
+	"lpt1": true, "lpt2": true, "lpt3": true, "lpt4": true, "lpt5": true, "lpt6": true, "lpt7": true, "lpt8": true, "lpt9": true,
+}
+
+// sanitizePathComponent makes one URL path segment a valid file name on
+// every OS: characters Windows forbids (":" would otherwise read as a drive
+// letter) become "_", trailing dots and spaces are dropped because Windows
+// strips them silently, and reserved device names get a "_" suffix.
 func sanitizePathComponent(s string) string {
-	s = strings.ReplaceAll(s, ":", "_")
-	s = strings.ReplaceAll(s, "?", "_")
-	s = strings.ReplaceAll(s, "*", "_")
-	s = strings.ReplaceAll(s, "\"", "_")
-	s = strings.ReplaceAll(s, "<", "_")
-	s = strings.ReplaceAll(s, ">", "_")
-	s = strings.ReplaceAll(s, "|", "_")
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 || strings.ContainsRune(`:?*"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, s)
+	s = strings.TrimRight(s, ". ")
 	if s == "" {
 		s = "_"
 	}
+	stem, _, _ := strings.Cut(s, ".")
+	if windowsReserved[strings.ToLower(stem)] {
+		s = stem + "_" + s[len(stem):]
+	}
 	return s
 }
+
+// reportMaxPages warns that a crawl reached --max-pages, saying how it went
+// on and what it missed.
+func reportMaxPages(ctx context.Context, baseURL string, report *crawler.MaxPagesReport) {
+	if report == nil {
+		return
+	}
+	var msg string
+	switch report.Mode {
+	case crawler.MaxPagesFinishDepth:
+		msg = fmt.Sprintf("reached --max-pages %d at depth %d; finished that depth with %d more page(s) and left %d deeper URL(s) uncrawled", report.Limit, report.Depth, report.OverLimit, report.Missed)
+	case crawler.MaxPagesWarn:
+		msg = fmt.Sprintf("reached --max-pages %d at depth %d; kept crawling and queued %d page(s) past it", report.Limit, report.Depth, report.OverLimit)
+	default:
+		msg = fmt.Sprintf("reached --max-pages %d at depth %d; stopped queueing links and left %d URL(s) uncrawled (see --dump-frontier)", report.Limit, report.Depth, report.Missed)
+	}
+	warnings.Report(ctx, warnings.Warning{
+		Code:    warnings.CodeMaxPages,
+		Message: msg,
+		URL:     baseURL,
+		Context: map[string]string{
+			"mode":       string(report.Mode),
+			"limit":      strconv.Itoa(report.Limit),
+			"depth":      strconv.Itoa(report.Depth),
+			"over_limit": strconv.Itoa(report.OverLimit),
+			"missed":     strconv.Itoa(report.Missed),
+		},
+	})
+}
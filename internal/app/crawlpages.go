@@ -0,0 +1,296 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"go_scrap/internal/attribution"
+	"go_scrap/internal/crawler"
+	"go_scrap/internal/dates"
+	"go_scrap/internal/output"
+	"go_scrap/internal/report"
+	"go_scrap/internal/warnings"
+)
+
+// crawlPageOutcome is what processing one crawled page contributes to the
+// crawl's shared outputs. Pages are processed concurrently, but outcomes are
+// merged one at a time in URL order.
+type crawlPageOutcome struct {
+	url     string
+	pageDir string
+	// title is the page's title; staged is set when pageDir is a staging
+	// directory still to be named from it.
+	title       string
+	staged      bool
+	sections    int
+	section     *output.PageSectionCount
+	attribution *attribution.Page
+	anchors     *report.PageAnchors
+	// progress holds the page's stdout lines and warnings what it reported;
+	// both are replayed when the outcome is merged.
+	progress lockedBuffer
+	warnings *warnings.Collector
+	err      error
+}
+
+// lockedBuffer is a bytes.Buffer safe for concurrent use: a page abandoned by
+// its timeout may keep writing to it after the outcome is merged.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) WriteTo(w io.Writer) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.WriteTo(w)
+}
+
+// processWorkers returns how many crawled pages are processed at once:
+// n, or GOMAXPROCS when n is 0.
+func processWorkers(n int) int {
+	if n <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return n
+}
+
+// worker returns a pipeline for another page worker. Hooks may keep per-page
+// state, so each worker builds its own; converters and the conversion cache
+// are safe to share.
+func (p *pipeline) worker(opts Options) (*pipeline, error) {
+	hooks, err := buildHooks(opts)
+	if err != nil {
+		return nil, err
+	}
+	w := *p
+	w.hooks = hooks
+	return &w, nil
+}
+
+// processCrawlPages runs process for every URL on up to --process-workers
+// goroutines and calls merge with each outcome in the order of urls. An
+// error from merge stops the remaining pages and is returned.
+func processCrawlPages(ctx context.Context, p *pipeline, opts Options, urls []string, process func(context.Context, *pipeline, string) *crawlPageOutcome, merge func(*crawlPageOutcome) error) error {
+	workers := min(processWorkers(opts.ProcessWorkers), len(urls))
+	if workers <= 1 {
+		for _, pageURL := range urls {
+			if err := merge(process(ctx, p, pageURL)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	pipelines := []*pipeline{p}
+	for len(pipelines) < workers {
+		w, err := p.worker(opts)
+		if err != nil {
+			return err
+		}
+		pipelines = append(pipelines, w)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make([]chan *crawlPageOutcome, len(urls))
+	for i := range done {
+		done[i] = make(chan *crawlPageOutcome, 1)
+	}
+	jobs := make(chan int)
+	go func() {
+		defer close(jobs)
+		for i := range urls {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	var wg sync.WaitGroup
+	for _, wp := range pipelines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				done[i] <- process(ctx, wp, urls[i])
+			}
+		}()
+	}
+	// On an early return, pages not yet started see the canceled context
+	// and finish at once.
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	for i := range urls {
+		select {
+		case outcome := <-done[i]:
+			if err := merge(outcome); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// crawlPageOutcome processes one crawled page (or skips it on --resume) and
+// records its contribution to the crawl index, attribution and anchors.
+func (p *pipeline) crawlPageOutcome(ctx context.Context, opts Options, pageURL string, result *crawler.Result, pagesDir string, resumeEntries map[string]crawler.PageEntry) *crawlPageOutcome {
+	outcome := &crawlPageOutcome{url: pageURL, warnings: warnings.New(nil)}
+	if err := ctx.Err(); err != nil {
+		outcome.err = err
+		return outcome
+	}
+	ctx = warnings.WithCollector(ctx, outcome.warnings)
+	opts.progress = &outcome.progress
+
+	if result != nil && result.Error == nil && result.HTML != "" {
+		if page, ok := detectAttribution(pageURL, result.HTML, result.FetchedAt); ok {
+			outcome.attribution = &page
+		}
+		if opts.AnchorScope == AnchorScopeCrawl {
+			if pa, ok := pageAnchors(pageURL, result.HTML, opts.ExcludeSelector); ok {
+				outcome.anchors = &pa
+			}
+		}
+	}
+	if resumeEntry, ok := resumeEntries[pageURL]; ok && shouldResumeSkip(opts, result, resumeEntry) {
+		pageDir, dirErr := pageOutputDir(resumeEntry, filepath.Dir(pagesDir))
+		if dirErr == nil {
+			if _, err := os.Stat(pageDir); err == nil {
+				if resumeEntry.Status == "success" {
+					outcome.pageDir = pageDir
+					outcome.section = &output.PageSectionCount{
+						URL:                  pageURL,
+						Sections:             resumeEntry.SectionCount,
+						Classification:       resumeEntry.Classification,
+						ClassificationReason: resumeEntry.ClassificationReason,
+						Published:            resumeEntry.Published,
+						Modified:             resumeEntry.Modified,
+						Authors:              resumeEntry.Authors,
+						Title:                resumeEntry.Title,
+						BrokenAnchors:        resumeEntry.BrokenAnchors,
+						EmptySections:        resumeEntry.EmptySections,
+					}
+				}
+				if !opts.Stdout {
+					fmt.Fprintf(&outcome.progress, "Skipped (unchanged): %s\n", pageDir)
+				}
+				return outcome
+			}
+		}
+	}
+
+	summary := p.processCrawlPageIsolated(ctx, opts, pageURL, result, pagesDir)
+	switch {
+	case summary.Processed:
+		outcome.pageDir = summary.OutputDir
+		outcome.title = summary.Title
+		outcome.staged = summary.Staged
+		outcome.sections = summary.Sections
+		outcome.section = &output.PageSectionCount{
+			URL:                  pageURL,
+			Sections:             summary.Sections,
+			Classification:       summary.Class.Class,
+			ClassificationReason: summary.Class.Reason,
+			Published:            dates.Format(summary.Dates.Published),
+			Modified:             dates.Format(summary.Dates.Modified),
+			Authors:              summary.Authors,
+			Title:                summary.Title,
+			BrokenAnchors:        summary.BrokenAnchors,
+			EmptySections:        summary.EmptySections,
+		}
+		if !opts.Stdout {
+			// A staged page is reported once it is named.
+			if !summary.Staged {
+				fmt.Fprintf(&outcome.progress, "Wrote: %s (%d sections)\n", summary.OutputDir, summary.Sections)
+			}
+			if summary.Class.Class != "" {
+				warnings.Report(ctx, warnings.Warning{
+					Code:    warnings.CodePageClassified,
+					Message: fmt.Sprintf("%s looks like a %s page: %s", pageURL, summary.Class.Class, summary.Class.Reason),
+					URL:     pageURL,
+					Context: map[string]string{"class": summary.Class.Class, "reason": summary.Class.Reason},
+				})
+			}
+		}
+	case summary.Skipped:
+		warnings.Report(ctx, warnings.Warning{
+			Code:    warnings.CodePageSkipped,
+			Message: fmt.Sprintf("skipping %s: %s", pageURL, summary.SkipReason),
+			URL:     pageURL,
+			Context: map[string]string{"reason": summary.SkipReason, "category": string(summary.SkipCategory)},
+		})
+		outcome.section = &output.PageSectionCount{
+			URL:                  pageURL,
+			SkipReason:           summary.SkipReason,
+			SkipCategory:         summary.SkipCategory,
+			Classification:       summary.Class.Class,
+			ClassificationReason: summary.Class.Reason,
+			Published:            dates.Format(summary.Dates.Published),
+			Modified:             dates.Format(summary.Dates.Modified),
+			Authors:              summary.Authors,
+			Title:                summary.Title,
+		}
+	case summary.ProcessError != nil:
+		warnings.Report(ctx, warnings.Warning{
+			Code:    warnings.CodePageFailed,
+			Message: fmt.Sprintf("failed to process %s: %v", pageURL, summary.ProcessError),
+			URL:     pageURL,
+			Context: map[string]string{"error": summary.ProcessError.Error()},
+		})
+		outcome.section = &output.PageSectionCount{
+			URL:   pageURL,
+			Error: summary.ProcessError.Error(),
+		}
+	}
+	return outcome
+}
+
+// place moves a staged page to the directory namer names it after, and
+// records the page's directory for the crawl index. It runs as outcomes are
+// merged, in URL order.
+func (o *crawlPageOutcome) place(namer *pageNamer, pagesDir string, assets *output.AssetStore) error {
+	if o.pageDir == "" {
+		return nil
+	}
+	if o.staged && namer != nil {
+		dir, err := namer.place(o.pageDir, o.url, o.title)
+		if err != nil {
+			return err
+		}
+		if assets != nil && filepath.Dir(dir) != pagesDir {
+			// An untitled page kept its URL-path directory, at another
+			// depth than the staged links to shared assets assumed.
+			staged := assets.LinkPrefix(filepath.Join(pagesDir, stagingDirName))
+			if err := relinkAssets(dir, staged, assets.LinkPrefix(dir)); err != nil {
+				return err
+			}
+		}
+		o.pageDir = dir
+		o.staged = false
+		if !namer.opts.Stdout {
+			fmt.Fprintf(&o.progress, "Wrote: %s (%d sections)\n", dir, o.sections)
+		}
+	}
+	if o.section != nil {
+		o.section.OutputDir = relativePageDir(pagesDir, o.pageDir)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go_scrap/internal/fsutil"
+	"go_scrap/internal/parse"
+	"go_scrap/internal/report"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// pageAnchors collects the IDs a crawled page defines and the fragment links
+// it contains, after exclusions, for the crawl-wide anchor check.
+func pageAnchors(pageURL, rawHTML, excludeSelector string) (report.PageAnchors, bool) {
+	doc, err := parse.NewDocument(rawHTML)
+	if err != nil {
+		return report.PageAnchors{}, false
+	}
+	applyExclusions(doc, excludeSelector)
+
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return report.PageAnchors{}, false
+	}
+	// Fragment-only links resolve against <base href>, which is how many doc
+	// sites end up pointing "#id" at another page.
+	if href, ok := doc.Find("base[href]").First().Attr("href"); ok {
+		if ref, err := url.Parse(strings.TrimSpace(href)); err == nil {
+			base = base.ResolveReference(ref)
+		}
+	}
+
+	pa := report.PageAnchors{URL: pageURL}
+	doc.Find("[id], a[name]").Each(func(_ int, s *goquery.Selection) {
+		if id := s.AttrOr("id", ""); id != "" {
+			pa.IDs = append(pa.IDs, id)
+		}
+		if name := s.AttrOr("name", ""); name != "" && goquery.NodeName(s) == "a" {
+			pa.IDs = append(pa.IDs, name)
+		}
+	})
+	doc.Find("a[href]").Each(func(_ int, s *goquery.Selection) {
+		href := strings.TrimSpace(s.AttrOr("href", ""))
+		if !strings.Contains(href, "#") {
+			return
+		}
+		ref, err := url.Parse(href)
+		if err != nil || ref.Fragment == "" {
+			return
+		}
+		target := base.ResolveReference(ref)
+		if target.Scheme != "http" && target.Scheme != "https" {
+			return
+		}
+		fragment := target.Fragment
+		target.Fragment = ""
+		target.RawFragment = ""
+		pa.Links = append(pa.Links, report.AnchorLink{Href: href, Target: target.String(), Fragment: fragment})
+	})
+	return pa, true
+}
+
+// checkCrossPageAnchors resolves fragment links against every crawled page,
+// writes anchor-check.json and, with --strict, fails on broken anchors.
+func checkCrossPageAnchors(ctx context.Context, opts Options, pages []report.PageAnchors) error {
+	rep := report.AnalyzeCrossPage(pages)
+	if !opts.Stdout {
+		rep.Print(os.Stdout)
+		if err := writeAnchorCheck(opts.OutputDir, rep); err != nil {
+			warnOutputWrite(ctx, "anchor-check.json", err)
+		}
+	}
+	if opts.Strict && len(rep.Broken) > 0 {
+		return errors.New("completeness checks failed: broken cross-page anchors (see anchor-check.json)")
+	}
+	return nil
+}
+
+func writeAnchorCheck(outDir string, rep report.CrossPageReport) error {
+	if err := fsutil.MkdirAll(outDir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFile(filepath.Join(outDir, "anchor-check.json"), append(data, '\n'), 0600)
+}
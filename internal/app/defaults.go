@@ -4,4 +4,80 @@ const (
 	DefaultTimeoutSeconds = 45
 	DefaultUserAgent      = "go_scrap/1.0"
 	DefaultOutputRoot     = "artifacts"
+	// DefaultHookTimeoutSeconds bounds each exec hook post command.
+	DefaultHookTimeoutSeconds = 300
+	// DefaultQueueLeaseSeconds is how long a worker holds a URL claimed from
+	// a shared crawl queue before other workers may take it over.
+	DefaultQueueLeaseSeconds = 300
+	// DefaultPageTimeoutSeconds bounds processing (not fetching) of each
+	// crawled page.
+	DefaultPageTimeoutSeconds = 120
+	// DefaultConvertCacheSize is how many section HTML-to-Markdown
+	// conversions are kept for reuse across pages.
+	DefaultConvertCacheSize = 2048
+	// DefaultCacheMaxMB caps the --cache HTML cache; least recently used
+	// pages are evicted beyond it.
+	DefaultCacheMaxMB = 512
+	// DefaultAssetRetries is how many times the CLI retries a failed asset
+	// download, waiting DefaultAssetRetryBackoffMS before the first retry.
+	DefaultAssetRetries        = 2
+	DefaultAssetRetryBackoffMS = 1000
+	// DefaultCitationTemplate is the --citation footer.
+	DefaultCitationTemplate = "Source: {url} (fetched {date})"
+)
+
+// Citation footers: CitationSection ends every section's Markdown with one,
+// CitationPage only the page's.
+const (
+	CitationSection = "section"
+	CitationPage    = "page"
+)
+
+const (
+	JSONFormatJSON   = "json"
+	JSONFormatNDJSON = "ndjson"
+)
+
+// Anchor scopes for completeness checks: AnchorScopePage checks fragment
+// links against the page's own IDs; AnchorScopeCrawl defers the check until
+// every crawled page is known.
+const (
+	AnchorScopePage  = "page"
+	AnchorScopeCrawl = "crawl"
+)
+
+// Page views scraped in place of a page (--prefer-view): PreferViewAMP uses
+// the page's <link rel="amphtml">, PreferViewPrint its print stylesheet
+// alternate or printer-friendly link, and PreferViewAny tries AMP first.
+const (
+	PreferViewOff   = "off"
+	PreferViewAMP   = "amp"
+	PreferViewPrint = "print"
+	PreferViewAny   = "any"
+)
+
+// Handling of pages classified as soft-404, login-wall or js-required during
+// a crawl: SoftPagesKeep writes them and records the class, SoftPagesDrop
+// skips them, and SoftPagesRetry re-fetches them with a browser and skips
+// them only if they still look the same.
+const (
+	SoftPagesKeep  = "keep"
+	SoftPagesDrop  = "drop"
+	SoftPagesRetry = "retry-dynamic"
+)
+
+// Downloaded asset file names: AssetNamesHash names each after a prefix of
+// its content's SHA-256, AssetNamesOriginal keeps the URL's sanitized file
+// name and adds the hash prefix only when another file has that name.
+const (
+	AssetNamesHash     = "hash"
+	AssetNamesOriginal = "original"
+)
+
+// Crawled page directories: PageNamesURL mirrors each page's URL path under
+// pages/, PageNamesTitle names it from the page title or first h1, slugified
+// and deduplicated.
+const (
+	PageNamesURL   = "url"
+	PageNamesTitle = "title"
 )
@@ -0,0 +1,215 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go_scrap/internal/crawler"
+	"go_scrap/internal/footprint"
+	"go_scrap/internal/menu"
+	"go_scrap/internal/output"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// runEstimate is --dry-run's forecast of what a full run would write.
+type runEstimate struct {
+	Pages int
+	// SitemapPages counts sitemap URLs in crawl scope; -1 without a sitemap.
+	SitemapPages  int
+	Files         int
+	MarkdownBytes int64
+	Chunks        int
+	Assets        output.AssetEstimate
+}
+
+// Per-page files besides content.md and section files: content.json,
+// index.jsonl, corpus.jsonl, anchors.json and index.html.
+const estimatePageFiles = 5
+
+// Run-level files: run.json and metrics.json, plus the crawl index and
+// browser index in crawl mode.
+const (
+	estimateSingleRunFiles = 2
+	estimateCrawlRunFiles  = 4
+)
+
+func (e *runEstimate) add(o runEstimate) {
+	e.Pages += o.Pages
+	e.Files += o.Files
+	e.MarkdownBytes += o.MarkdownBytes
+	e.Chunks += o.Chunks
+	e.Assets.Add(o.Assets)
+}
+
+// estimatePage renders a page in memory and counts what writeOutputs would
+// write for it. Hooks are not run.
+func (p *pipeline) estimatePage(ctx context.Context, opts Options, baseDoc *goquery.Document, result analysisResult) (runEstimate, error) {
+	result.Trim(opts.MaxSections)
+	sectionMarkdowns, err := p.renderSections(ctx, result.Doc.Sections)
+	if err != nil {
+		return runEstimate{}, err
+	}
+	parts := make([]string, 0, len(sectionMarkdowns))
+	for _, sm := range sectionMarkdowns {
+		parts = append(parts, sm.Markdown)
+	}
+	limits := chunkLimits(opts)
+	est := runEstimate{
+		Pages:         1,
+		MarkdownBytes: encodedMarkdownSize(parts, textEncoding(opts)),
+		Chunks:        len(output.MeasureChunks(result.Doc.Sections, sectionMarkdownsFor(result.Doc.Sections, sectionMarkdowns), limits)),
+		Files:         estimatePageFiles + output.MarkdownFileCount(parts, limits),
+	}
+	if opts.ChunkTokens > 0 {
+		est.Files++ // chunks.jsonl
+	}
+	if len(output.CollectMediaLinks(baseDoc, opts.URL)) > 0 {
+		est.Files++ // media-links.json
+	}
+	if len(output.CollectExternalLinks(opts.URL, result.Doc.Sections)) > 0 {
+		est.Files++ // external-links.json
+	}
+	if strings.TrimSpace(opts.NavSelector) != "" {
+		if nodes, err := menu.Extract(baseDoc, opts.NavSelector); err == nil {
+			// menu.json, SUMMARY.md and _sidebar.md
+			est.Files += 3 + countSectionFiles(nodes, sectionMarkdowns, opts.MaxMenuItems)
+		}
+	}
+	if opts.DownloadAssets {
+		assets, err := output.EstimateAssets(ctx, baseDoc, opts.URL, opts.UserAgent)
+		if err != nil {
+			return runEstimate{}, err
+		}
+		est.Assets = assets
+		est.Files += assets.Count
+	}
+	return est, nil
+}
+
+// estimateCrawl estimates every page fetched by a dry-run crawl the way
+// processCrawlResults would process it.
+func (p *pipeline) estimateCrawl(ctx context.Context, opts Options, results map[string]*crawler.Result, sitemapPages int) (runEstimate, error) {
+	total := runEstimate{SitemapPages: sitemapPages, Files: estimateCrawlRunFiles}
+	for pageURL, result := range results {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		if result == nil || result.Error != nil || result.HTML == "" {
+			continue
+		}
+		if class := classifyHTML(result.HTML); class.Class != "" && opts.SoftPages != SoftPagesKeep {
+			continue
+		}
+		pageOpts := opts
+		pageOpts.URL = pageURL
+		pageOpts, _ = detectPreset(pageOpts, result.HTML)
+		baseDoc, err := p.prepareDocument(ctx, pageOpts, result.HTML)
+		if err != nil {
+			continue
+		}
+		analysis, err := p.analyze(ctx, pageOpts, baseDoc, false)
+		if err != nil || dateSkipReason(analysis) != "" {
+			continue
+		}
+		if reason, _ := pageLengthSkipReason(opts, analysis.Doc); reason != "" {
+			continue
+		}
+		est, err := p.estimatePage(ctx, pageOpts, baseDoc, analysis)
+		if err != nil {
+			return total, fmt.Errorf("estimate %s: %w", pageURL, err)
+		}
+		total.add(est)
+	}
+	return total, nil
+}
+
+// countSectionFiles counts the menu nodes writeMenuOutputs would write a
+// section file for, up to maxItems (0 = all).
+func countSectionFiles(nodes []menu.Node, sections []sectionMarkdown, maxItems int) int {
+	hasMarkdown := map[string]bool{}
+	for _, sm := range sections {
+		if strings.TrimSpace(sm.Markdown) == "" {
+			continue
+		}
+		hasMarkdown[sm.HeadingID] = true
+		for _, id := range sm.ContentIDs {
+			hasMarkdown[id] = true
+		}
+	}
+	count := 0
+	var walk func([]menu.Node)
+	walk = func(nodes []menu.Node) {
+		for _, node := range nodes {
+			if maxItems > 0 && count >= maxItems {
+				return
+			}
+			if node.Anchor != "" && hasMarkdown[node.Anchor] {
+				count++
+			}
+			walk(node.Children)
+		}
+	}
+	walk(nodes)
+	return count
+}
+
+// encodedMarkdownSize is the size of content.md for parts, including CRLF
+// line endings and the BOM when configured.
+func encodedMarkdownSize(parts []string, enc output.TextEncoding) int64 {
+	var size int64
+	for _, part := range parts {
+		size += int64(len(part)) + 1
+		if enc.CRLF {
+			size += int64(strings.Count(part, "\n")-strings.Count(part, "\r\n")) + 1
+		}
+	}
+	if enc.BOM && size > 0 {
+		size += 3
+	}
+	return size
+}
+
+func printEstimate(opts Options, est runEstimate) {
+	if opts.Stdout {
+		return
+	}
+	fmt.Println("\nDry run estimate for a full run:")
+	if opts.Crawl {
+		fmt.Printf("  Pages: %d\n", est.Pages)
+		if est.SitemapPages >= 0 {
+			fmt.Printf("  Pages in scope from sitemap: %d", est.SitemapPages)
+			if est.SitemapPages > opts.MaxPages {
+				fmt.Printf(" (capped by --max-pages %d)", opts.MaxPages)
+			}
+			fmt.Println()
+		}
+	}
+	fmt.Printf("  Files: ~%d\n", est.Files)
+	fmt.Printf("  Markdown: %s\n", footprint.FormatBytes(est.MarkdownBytes))
+	fmt.Printf("  Chunks: %d\n", est.Chunks)
+	if opts.DownloadAssets {
+		fmt.Printf("  Assets: %d (%s", est.Assets.Count, footprint.FormatBytes(est.Assets.Bytes))
+		if est.Assets.Unknown > 0 {
+			fmt.Printf(", %d of unknown size", est.Assets.Unknown)
+		}
+		fmt.Println(")")
+	}
+}
+
+// printDryRunEstimate estimates a single-page run. Failures only warn: the
+// dry run itself succeeded.
+func (p *pipeline) printDryRunEstimate(ctx context.Context, opts Options, baseDoc *goquery.Document, result analysisResult) {
+	if !opts.DryRun || opts.Stdout {
+		return
+	}
+	est, err := p.estimatePage(ctx, opts, baseDoc, result)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: dry-run estimate failed: %v\n", err)
+		return
+	}
+	est.Files += estimateSingleRunFiles
+	printEstimate(opts, est)
+}
@@ -2,22 +2,28 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"path/filepath"
 	"time"
 
+	"go_scrap/internal/cache"
 	"go_scrap/internal/fetch"
+	"go_scrap/internal/footprint"
 
 	"github.com/PuerkitoBio/goquery"
 )
 
-func prepareBaseDocument(ctx context.Context, pipeline *pipeline, opts Options) (*goquery.Document, fetch.Result, error) {
-	result, err := fetchResult(ctx, opts)
+func prepareBaseDocument(ctx context.Context, pipeline *pipeline, opts *Options) (*goquery.Document, fetch.Result, error) {
+	result, err := fetchResult(ctx, *opts)
 	if err != nil {
 		return nil, fetch.Result{}, err
 	}
+	opts.fetchedAt = time.Now()
 
-	baseDoc, err := pipeline.prepareDocument(ctx, opts, result.HTML)
+	baseDoc, err := pipeline.prepareFetchedDocument(ctx, opts, result.HTML)
 	if err != nil {
 		return nil, fetch.Result{}, err
 	}
@@ -25,46 +31,122 @@ func prepareBaseDocument(ctx context.Context, pipeline *pipeline, opts Options)
 	return baseDoc, result, nil
 }
 
+// prepareFetchedDocument applies a detected preset to opts and parses the
+// fetched html.
+func (p *pipeline) prepareFetchedDocument(ctx context.Context, opts *Options, html string) (*goquery.Document, error) {
+	var detected string
+	if *opts, detected = detectPreset(*opts, html); detected != "" && !opts.Stdout {
+		fmt.Printf("Detected preset: %s\n", detected)
+	}
+	return p.prepareDocument(ctx, *opts, html)
+}
+
+// fetchResult fetches opts.URL, or the view of it that --prefer-view asks
+// for.
 func fetchResult(ctx context.Context, opts Options) (fetch.Result, error) {
+	result, err := fetchPage(ctx, opts)
+	if err != nil || opts.NavWalk {
+		return result, err
+	}
+	return preferView(ctx, opts, result), nil
+}
+
+// fetchPage fetches opts.URL through the cache, with retries.
+func fetchPage(ctx context.Context, opts Options) (fetch.Result, error) {
 	mode := opts.Mode
 	if opts.NavWalk {
 		mode = fetch.ModeDynamic
 	}
+	fetchOpts := buildFetchOptions(opts, mode)
 
+	var cachePath string
 	if opts.UseCache {
-		cachePath := fetch.GetCachePath(opts.URL)
-		if content, err := os.ReadFile(cachePath); err == nil {
-			return fetch.Result{HTML: string(content), SourceInfo: "cache"}, nil
+		cachePath = cache.Path(opts.CacheDir, opts.URL)
+		content, err := cache.Load(cachePath, opts.CacheTTL, opts.sealer)
+		if err == nil {
+			footprint.From(ctx).CacheHit(opts.URL)
+			return fetch.Result{HTML: content, SourceInfo: "cache"}, nil
 		}
-	}
-
-	var result fetch.Result
-	var err error
-	backoffs := []time.Duration{0, time.Second, 2 * time.Second}
-	for attempt := 0; attempt < 3; attempt++ {
-		if attempt > 0 {
-			time.Sleep(backoffs[attempt])
-			if !opts.Stdout {
-				fmt.Fprintf(os.Stderr, "Fetch attempt %d failed. Retrying...\n", attempt)
+		// An expired entry is revalidated rather than downloaded again when
+		// the server gave validators and the entry can be read afterwards.
+		if errors.Is(err, cache.ErrExpired) {
+			if entry, err := cache.Stat(cachePath); err == nil && (!entry.Encrypted || opts.sealer != nil) {
+				fetchOpts.IfNoneMatch = entry.ETag
+				fetchOpts.IfModifiedSince = entry.LastModified
 			}
 		}
-		result, err = fetch.Fetch(ctx, buildFetchOptions(opts, mode))
-		if err == nil || ctx.Err() != nil {
-			break
-		}
 	}
+
+	result, err := fetchWithRetries(ctx, opts, fetchOpts)
 	if err != nil {
 		return fetch.Result{}, err
 	}
+	if result.NotModified {
+		content, err := cache.Load(cachePath, 0, opts.sealer)
+		if err == nil {
+			_ = cache.Revalidated(cachePath, result)
+			footprint.From(ctx).CacheHit(opts.URL)
+			return fetch.Result{HTML: content, SourceInfo: "cache (not modified)", Status: result.Status, ETag: result.ETag, LastModified: result.LastModified}, nil
+		}
+		// The entry vanished since it was checked; fetch it in full.
+		fetchOpts.IfNoneMatch, fetchOpts.IfModifiedSince = "", ""
+		if result, err = fetchWithRetries(ctx, opts, fetchOpts); err != nil {
+			return fetch.Result{}, err
+		}
+	}
 
 	if opts.UseCache {
-		cachePath := fetch.GetCachePath(opts.URL)
-		_ = fetch.SaveToCache(cachePath, result.HTML)
+		if err := cache.Save(cachePath, opts.URL, result, opts.sealer); err == nil {
+			_, _, _ = cache.Prune(filepath.Dir(cachePath), int64(opts.CacheMaxMB)<<20)
+		}
 	}
 
 	return result, nil
 }
 
+// fetchWithRetries fetches fetchOpts.URL, retrying twice with backoff.
+func fetchWithRetries(ctx context.Context, opts Options, fetchOpts fetch.Options) (fetch.Result, error) {
+	var result fetch.Result
+	err := retrier(opts).Do(ctx, func(attempt int) error {
+		if attempt > 1 && !opts.Stdout {
+			fmt.Fprintf(os.Stderr, "Fetch attempt %d failed. Retrying...\n", attempt-1)
+		}
+		var err error
+		result, err = fetch.Fetch(ctx, fetchOpts)
+		return err
+	})
+	return result, err
+}
+
+// retrier is the run's Retrier, or one for opts.Retry when opts were not
+// normalized.
+func retrier(opts Options) *fetch.Retrier {
+	if opts.retrier != nil {
+		return opts.retrier
+	}
+	return fetch.NewRetrier(opts.Retry, opts.Seed)
+}
+
+// refetchDynamic re-fetches a crawled page with a browser; tests replace it.
+var refetchDynamic = func(ctx context.Context, opts Options) (string, error) {
+	result, err := fetch.Fetch(ctx, buildFetchOptions(opts, fetch.ModeDynamic))
+	if err != nil {
+		return "", err
+	}
+	return result.HTML, nil
+}
+
+// wrapTransport applies the run's fetch middleware to crawler and sitemap
+// requests; it is nil when there is none.
+func wrapTransport(opts Options) func(http.RoundTripper) http.RoundTripper {
+	if len(opts.Middleware) == 0 {
+		return nil
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return fetch.WrapTransport(next, opts.Middleware)
+	}
+}
+
 func buildFetchOptions(opts Options, mode fetch.Mode) fetch.Options {
 	return fetch.Options{
 		URL:                opts.URL,
@@ -73,9 +155,18 @@ func buildFetchOptions(opts Options, mode fetch.Mode) fetch.Options {
 		UserAgent:          opts.UserAgent,
 		WaitForSelector:    opts.WaitFor,
 		Headless:           opts.Headless,
+		Browser:            opts.Browser,
+		ScrollToBottom:     opts.ScrollToBottom,
+		ClickSelector:      opts.ClickSelector,
+		MaxScrolls:         opts.MaxScrolls,
+		ScrollQuiet:        opts.ScrollQuiet,
 		RateLimitPerSecond: opts.RateLimitPerSecond,
 		ProxyURL:           opts.ProxyURL,
 		Headers:            opts.AuthHeaders,
 		Cookies:            opts.AuthCookies,
+		ClientCertFile:     opts.ClientCert,
+		ClientKeyFile:      opts.ClientKey,
+		Middleware:         opts.Middleware,
+		StorageState:       opts.storageState,
 	}
 }
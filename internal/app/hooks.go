@@ -1,13 +1,18 @@
 package app
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
+	"time"
 
 	"go_scrap/internal/parse"
 	"go_scrap/internal/report"
@@ -29,7 +34,16 @@ type WriteResult struct {
 	MarkdownPath string
 	JSONPath     string
 	IndexPath    string
-	MenuPath     string
+	CorpusPath   string
+	ChunksPath   string
+	// MediaLinksPath is media-links.json, written when the page links to
+	// non-HTML files.
+	MediaLinksPath string
+	// ExternalLinksPath is external-links.json, written when sections link
+	// off-site.
+	ExternalLinksPath string
+	AnchorsPath       string
+	MenuPath          string
 }
 
 type Hook interface {
@@ -39,6 +53,12 @@ type Hook interface {
 	AfterWrite(ctx context.Context, opts Options, doc *parse.Document, rep *report.Report, rendered Rendered, written WriteResult) error
 }
 
+// FetchRewriter is implemented by hooks that rewrite the target URL or
+// other options before fetching.
+type FetchRewriter interface {
+	BeforeFetch(ctx context.Context, opts *Options) error
+}
+
 type HookBase struct{}
 
 func (HookBase) BeforeRender(context.Context, Options, *parse.Document, *report.Report) error {
@@ -51,17 +71,37 @@ func (HookBase) AfterWrite(context.Context, Options, *parse.Document, *report.Re
 	return nil
 }
 
-type hookFactory func(opts Options) (Hook, error)
+// HookFactory builds a hook for a run.
+type HookFactory func(opts Options) (Hook, error)
+
+var (
+	hookRegistryMu sync.RWMutex
+	hookRegistry   = map[string]HookFactory{
+		"strict-report": func(Options) (Hook, error) { return strictReportHook{}, nil },
+		"exec":          func(Options) (Hook, error) { return execHook{}, nil },
+		"scrub":         newScrubHook,
+	}
+)
+
+// RegisterHook makes a hook available to --hook / pipeline_hooks by name,
+// replacing any existing hook with the same name.
+func RegisterHook(name string, factory HookFactory) {
+	hookRegistryMu.Lock()
+	defer hookRegistryMu.Unlock()
+	hookRegistry[name] = factory
+}
 
 func buildHooks(opts Options) ([]Hook, error) {
 	if len(opts.PipelineHooks) == 0 {
 		return nil, nil
 	}
 
-	registry := map[string]hookFactory{
-		"strict-report": func(Options) (Hook, error) { return strictReportHook{}, nil },
-		"exec":          func(Options) (Hook, error) { return execHook{}, nil },
+	hookRegistryMu.RLock()
+	registry := make(map[string]HookFactory, len(hookRegistry))
+	for name, factory := range hookRegistry {
+		registry[name] = factory
 	}
+	hookRegistryMu.RUnlock()
 
 	names := dedupePreserveOrder(opts.PipelineHooks)
 	out := make([]Hook, 0, len(names))
@@ -79,12 +119,28 @@ func buildHooks(opts Options) ([]Hook, error) {
 	return out, nil
 }
 
+func (p *pipeline) runBeforeFetchHooks(ctx context.Context, opts *Options) error {
+	for _, h := range p.hooks {
+		rw, ok := h.(FetchRewriter)
+		if !ok {
+			continue
+		}
+		if err := rw.BeforeFetch(ctx, opts); err != nil {
+			return fmt.Errorf("hook %q failed (before fetch): %w", h.Name(), err)
+		}
+	}
+	if strings.TrimSpace(opts.URL) == "" && strings.TrimSpace(opts.SitemapURL) == "" && len(opts.URLs) == 0 {
+		return errors.New("before fetch hooks left no url to fetch")
+	}
+	return nil
+}
+
 func (p *pipeline) runBeforeRenderHooks(ctx context.Context, opts Options, doc *parse.Document, rep *report.Report) error {
 	for _, h := range p.hooks {
 		if err := h.BeforeRender(ctx, opts, doc, rep); err != nil {
 			return fmt.Errorf("hook %q failed (before render): %w", h.Name(), err)
 		}
-		*rep = report.Analyze(doc)
+		*rep = reanalyze(doc, *rep)
 	}
 	return nil
 }
@@ -170,11 +226,11 @@ type strictReportHook struct {
 
 func (strictReportHook) Name() string { return "strict-report" }
 
-func (strictReportHook) BeforeRender(_ context.Context, _ Options, _ *parse.Document, rep *report.Report) error {
+func (strictReportHook) BeforeRender(_ context.Context, opts Options, _ *parse.Document, rep *report.Report) error {
 	if rep == nil {
 		return errors.New("missing report")
 	}
-	if reportHasIssues(*rep) {
+	if reportHasIssues(strictReport(opts, *rep)) {
 		return errors.New("completeness checks failed")
 	}
 	return nil
@@ -186,47 +242,134 @@ type execHook struct {
 
 func (execHook) Name() string { return "exec" }
 
-func (execHook) AfterWrite(ctx context.Context, opts Options, _ *parse.Document, _ *report.Report, _ Rendered, written WriteResult) error {
-	commands := make([]string, 0, len(opts.PostCommands))
-	for _, c := range opts.PostCommands {
-		c = strings.TrimSpace(c)
-		if c == "" || strings.HasPrefix(c, "#") {
-			continue
+// defaultHookEnv lists the variables post commands inherit from the parent
+// environment; anything else must be allowed explicitly via Options.HookEnv.
+var defaultHookEnv = []string{
+	"PATH", "HOME", "USER", "LANG", "TMPDIR",
+	"TEMP", "TMP", "SYSTEMROOT", "COMSPEC", "PATHEXT", "USERPROFILE",
+}
+
+// BeforeFetch runs pre-fetch commands with GO_SCRAP_URL set; the first
+// non-empty line a command prints becomes the new target URL.
+func (execHook) BeforeFetch(ctx context.Context, opts *Options) error {
+	for i, cmdStr := range hookCommands(opts.PreFetchCommands) {
+		var out bytes.Buffer
+		env := hookEnv(*opts, WriteResult{})
+		if err := runHookCommand(ctx, cmdStr, "", env, opts.HookTimeout, &out, os.Stderr); err != nil {
+			return fmt.Errorf("pre-fetch command #%d %q failed: %w", i+1, cmdStr, err)
+		}
+		if rewritten := firstNonEmptyLine(out.String()); rewritten != "" {
+			opts.URL = rewritten
 		}
-		commands = append(commands, c)
 	}
+	return nil
+}
+
+func (execHook) AfterWrite(ctx context.Context, opts Options, _ *parse.Document, _ *report.Report, _ Rendered, written WriteResult) error {
+	commands := hookCommands(opts.PostCommands)
 	if len(commands) == 0 {
 		return nil
 	}
 
-	for _, cmdStr := range commands {
-		cmd, err := commandForShell(ctx, cmdStr)
-		if err != nil {
-			return err
+	dir, err := hookWorkDir(written.OutputDir)
+	if err != nil {
+		return err
+	}
+	env := hookEnv(opts, written)
+
+	var stdout, stderr io.Writer
+	if opts.Stdout {
+		stdout, stderr = os.Stderr, os.Stderr
+	}
+	for i, cmdStr := range commands {
+		if err := runHookCommand(ctx, cmdStr, dir, env, opts.HookTimeout, stdout, stderr); err != nil {
+			return fmt.Errorf("post command #%d %q failed: %w", i+1, cmdStr, err)
 		}
-		cmd.Env = append(os.Environ(),
-			"GO_SCRAP_URL="+opts.URL,
-			"GO_SCRAP_OUTPUT_DIR="+written.OutputDir,
-			"GO_SCRAP_MARKDOWN_PATH="+written.MarkdownPath,
-			"GO_SCRAP_JSON_PATH="+written.JSONPath,
-			"GO_SCRAP_INDEX_PATH="+written.IndexPath,
-			"GO_SCRAP_MENU_PATH="+written.MenuPath,
-		)
-		if written.OutputDir != "" {
-			cmd.Dir = written.OutputDir
+	}
+	return nil
+}
+
+func hookCommands(raw []string) []string {
+	commands := make([]string, 0, len(raw))
+	for _, c := range raw {
+		c = strings.TrimSpace(c)
+		if c == "" || strings.HasPrefix(c, "#") {
+			continue
 		}
-		if opts.Stdout {
-			cmd.Stdout = os.Stderr
-			cmd.Stderr = os.Stderr
+		commands = append(commands, c)
+	}
+	return commands
+}
+
+func firstNonEmptyLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
 		}
+	}
+	return ""
+}
 
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("post command failed %q: %w", cmdStr, err)
+func runHookCommand(ctx context.Context, command, dir string, env []string, timeout time.Duration, stdout, stderr io.Writer) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	cmd, err := commandForShell(ctx, command)
+	if err != nil {
+		return err
+	}
+	cmd.Env = env
+	cmd.Dir = dir
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("timed out after %s (see --hook-timeout)", timeout)
 		}
+		return err
 	}
 	return nil
 }
 
+// hookWorkDir confines post commands to the run's output directory.
+func hookWorkDir(outputDir string) (string, error) {
+	if strings.TrimSpace(outputDir) == "" {
+		return "", errors.New("exec hook requires an output directory")
+	}
+	dir, err := filepath.Abs(outputDir)
+	if err != nil {
+		return "", fmt.Errorf("resolve hook working dir: %w", err)
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return "", fmt.Errorf("hook working dir: %w", err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("hook working dir is not a directory: %s", dir)
+	}
+	return dir, nil
+}
+
+func hookEnv(opts Options, written WriteResult) []string {
+	names := dedupePreserveOrder(append(append([]string(nil), defaultHookEnv...), opts.HookEnv...))
+	env := make([]string, 0, len(names)+6)
+	for _, name := range names {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return append(env,
+		"GO_SCRAP_URL="+opts.URL,
+		"GO_SCRAP_OUTPUT_DIR="+written.OutputDir,
+		"GO_SCRAP_MARKDOWN_PATH="+written.MarkdownPath,
+		"GO_SCRAP_JSON_PATH="+written.JSONPath,
+		"GO_SCRAP_INDEX_PATH="+written.IndexPath,
+		"GO_SCRAP_MENU_PATH="+written.MenuPath,
+	)
+}
+
 func commandForShell(ctx context.Context, command string) (*exec.Cmd, error) {
 	command = strings.TrimSpace(command)
 	if command == "" {
@@ -0,0 +1,196 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"go_scrap/internal/crawler"
+	"go_scrap/internal/output"
+	"go_scrap/internal/parse"
+	"go_scrap/internal/warnings"
+)
+
+// Page is one page run through the pipeline in memory by Scrape, Extract and
+// CrawlPages.
+type Page struct {
+	URL string
+	// Doc is nil when the page was skipped or failed.
+	Doc *parse.Document
+	// SectionMarkdown holds the Markdown of each of Doc.Sections.
+	SectionMarkdown []string
+	// Markdown is the whole page as content.md would hold it.
+	Markdown string
+	// SkipReason says why a crawled page was skipped (soft page, date or
+	// length filters); Err is why it failed.
+	SkipReason string
+	Err        error
+	Warnings   []warnings.Warning
+}
+
+// Scrape fetches opts.URL and runs it through the same pipeline as Run, but
+// returns the page instead of writing outputs. Nothing is printed.
+func Scrape(ctx context.Context, opts Options) (*Page, error) {
+	opts, p, err := prepareLibrary(ctx, opts, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.runBeforeFetchHooks(ctx, &opts); err != nil {
+		return nil, err
+	}
+	warns := warnings.New(nil)
+	ctx = warnings.WithCollector(ctx, warns)
+	baseDoc, _, err := prepareBaseDocument(ctx, p, &opts)
+	if err != nil {
+		return nil, err
+	}
+	analysis, err := p.analyze(ctx, opts, baseDoc, true)
+	if err != nil {
+		return nil, err
+	}
+	analysis.Trim(opts.MaxSections)
+	page, err := p.renderPage(ctx, opts, analysis)
+	if err != nil {
+		return nil, err
+	}
+	page.Warnings = warns.List()
+	return page, nil
+}
+
+// Extract runs html, the page at opts.URL, through the pipeline without
+// fetching it. Nav-walk is not available since it needs a browser session.
+func Extract(ctx context.Context, opts Options, html string) (*Page, error) {
+	opts, p, err := prepareLibrary(ctx, opts, false)
+	if err != nil {
+		return nil, err
+	}
+	warns := warnings.New(nil)
+	ctx = warnings.WithCollector(ctx, warns)
+	opts, _ = detectPreset(opts, html)
+	baseDoc, err := p.prepareDocument(ctx, opts, html)
+	if err != nil {
+		return nil, err
+	}
+	analysis, err := p.analyze(ctx, opts, baseDoc, false)
+	if err != nil {
+		return nil, err
+	}
+	analysis.Trim(opts.MaxSections)
+	page, err := p.renderPage(ctx, opts, analysis)
+	if err != nil {
+		return nil, err
+	}
+	page.Warnings = warns.List()
+	return page, nil
+}
+
+// CrawlPages crawls like Run in crawl mode and calls fn with every crawled
+// page, in URL order, once the crawl ends. Skipped and failed pages are
+// passed too, with SkipReason or Err set. Nothing is written or printed, and
+// a crawl cannot be resumed. An error from fn stops the walk and is returned.
+func CrawlPages(ctx context.Context, opts Options, fn func(*Page) error) error {
+	opts, p, err := prepareLibrary(ctx, opts, true)
+	if err != nil {
+		return err
+	}
+	if err := p.runBeforeFetchHooks(ctx, &opts); err != nil {
+		return err
+	}
+	c, _, _, err := initCrawler(ctx, opts, nil)
+	if err != nil {
+		return err
+	}
+	results, _, err := c.Crawl(ctx)
+	if err != nil && !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("crawl failed: %w", err)
+	}
+
+	urls := make([]string, 0, len(results))
+	for u := range results {
+		urls = append(urls, u)
+	}
+	sort.Strings(urls)
+	for _, pageURL := range urls {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(p.crawledPage(ctx, opts, pageURL, results[pageURL])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// crawledPage extracts and renders one crawled page for CrawlPages.
+func (p *pipeline) crawledPage(ctx context.Context, opts Options, pageURL string, result *crawler.Result) *Page {
+	warns := warnings.New(nil)
+	ctx = warnings.WithCollector(ctx, warns)
+	page := &Page{URL: pageURL}
+	switch {
+	case result != nil && result.Error != nil:
+		page.Err = result.Error
+		return page
+	case result == nil || result.HTML == "":
+		page.SkipReason = "empty result"
+		return page
+	}
+
+	pageOpts := opts
+	pageOpts.URL = pageURL
+	pageOpts.fetchedAt = result.FetchedAt
+	summary := crawlPageSummary{URL: pageURL}
+	extracted, ok := p.extractCrawlPage(ctx, opts, pageOpts, result.HTML, &summary)
+	if ok {
+		rendered, err := p.renderPage(ctx, extracted.Opts, extracted.Analysis)
+		if err != nil {
+			summary.ProcessError = err
+		} else {
+			page = rendered
+		}
+	}
+	page.SkipReason = summary.SkipReason
+	page.Err = summary.ProcessError
+	page.Warnings = warns.List()
+	return page
+}
+
+// renderPage converts an analyzed page to Markdown, running the render hooks.
+func (p *pipeline) renderPage(ctx context.Context, opts Options, result analysisResult) (*Page, error) {
+	md, sectionMarkdowns, _, err := p.render(ctx, opts, &result)
+	if err != nil {
+		return nil, err
+	}
+	if md == "" {
+		md = joinMarkdown(sectionMarkdowns)
+	}
+	if footer := pageCitation(opts, result.Doc); footer != "" {
+		md = output.AppendCitation(md, footer)
+	}
+	return &Page{
+		URL:             opts.URL,
+		Doc:             result.Doc,
+		SectionMarkdown: sectionMarkdownsFor(result.Doc.Sections, sectionMarkdowns),
+		Markdown:        md,
+	}, nil
+}
+
+// prepareLibrary validates opts for Scrape, Extract and CrawlPages. Stdout
+// silences progress output; asset downloads are off since nothing is written.
+func prepareLibrary(ctx context.Context, opts Options, crawl bool) (Options, *pipeline, error) {
+	opts.Crawl = crawl
+	opts.Stdout = true
+	opts.DryRun = false
+	opts.Resume = false
+	opts.QueueDir = ""
+	opts.DownloadAssets = false
+	opts, err := prepareRun(ctx, opts)
+	if err != nil {
+		return opts, nil, err
+	}
+	p, err := newPipeline(opts)
+	if err != nil {
+		return opts, nil, err
+	}
+	return opts, p, nil
+}
@@ -0,0 +1,76 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go_scrap/internal/fetch"
+)
+
+const (
+	// LoginUsernameEnv and LoginPasswordEnv supply the login credentials
+	// that a config's login block leaves out.
+	LoginUsernameEnv = "GO_SCRAP_LOGIN_USERNAME"
+	LoginPasswordEnv = "GO_SCRAP_LOGIN_PASSWORD"
+)
+
+// withLoginEnv fills the credentials missing from login from the
+// environment.
+func withLoginEnv(login fetch.Login) fetch.Login {
+	if login.Username == "" {
+		login.Username = os.Getenv(LoginUsernameEnv)
+	}
+	if login.Password == "" {
+		login.Password = os.Getenv(LoginPasswordEnv)
+	}
+	return login
+}
+
+// logIn performs opts.Login, when set, before anything else is fetched.
+// Browser fetches and nav walks then start from the session's storage
+// state, and its cookies for the scraped site are added to AuthCookies for
+// static fetches and crawls; an --auth-cookie of the same name wins. The
+// returned cleanup removes the saved state.
+func logIn(ctx context.Context, opts Options) (Options, func(), error) {
+	if opts.Login == nil {
+		return opts, func() {}, nil
+	}
+	f, err := os.CreateTemp("", "go_scrap-login-*.json")
+	if err != nil {
+		return opts, func() {}, fmt.Errorf("login: %w", err)
+	}
+	_ = f.Close()
+	cleanup := func() { _ = os.Remove(f.Name()) }
+
+	if !opts.Stdout {
+		fmt.Printf("Logging in at %s\n", opts.Login.URL)
+	}
+	session, err := fetch.RunLogin(ctx, buildFetchOptions(opts, fetch.ModeDynamic), *opts.Login, f.Name())
+	if err != nil {
+		cleanup()
+		return opts, func() {}, err
+	}
+	opts.storageState = session.StorageState
+	cookies := session.CookiesFor(loginSiteURL(opts))
+	for name, value := range opts.AuthCookies {
+		cookies[name] = value
+	}
+	if len(cookies) > 0 {
+		opts.AuthCookies = cookies
+	}
+	return opts, cleanup, nil
+}
+
+// loginSiteURL is the URL whose cookies a login passes on.
+func loginSiteURL(opts Options) string {
+	switch {
+	case opts.URL != "":
+		return opts.URL
+	case opts.SitemapURL != "":
+		return opts.SitemapURL
+	case len(opts.URLs) > 0:
+		return opts.URLs[0]
+	}
+	return ""
+}
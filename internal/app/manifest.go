@@ -0,0 +1,160 @@
+package app
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"go_scrap/internal/fsutil"
+	"go_scrap/internal/version"
+	"go_scrap/internal/warnings"
+)
+
+const redacted = "REDACTED"
+
+// RunManifest is written to run.json so an output directory records exactly
+// how it was produced.
+type RunManifest struct {
+	RunID       string         `json:"run_id"`
+	Tool        version.Info   `json:"tool"`
+	StartedAt   time.Time      `json:"started_at"`
+	CompletedAt time.Time      `json:"completed_at"`
+	ConfigPath  string         `json:"config_path,omitempty"`
+	ConfigHash  string         `json:"config_hash,omitempty"`
+	Seed        int64          `json:"seed"`
+	Environment RunEnvironment `json:"environment"`
+	Options     Options        `json:"options"`
+	Error       string         `json:"error,omitempty"`
+	// Warnings lists everything the run worked around, by code.
+	Warnings []warnings.Warning `json:"warnings,omitempty"`
+}
+
+type RunEnvironment struct {
+	GOOS       string `json:"goos"`
+	GOARCH     string `json:"goarch"`
+	NumCPU     int    `json:"num_cpu"`
+	WorkingDir string `json:"working_dir,omitempty"`
+}
+
+func buildRunManifest(opts Options, startedAt time.Time, runErr error, warns []warnings.Warning) RunManifest {
+	wd, _ := os.Getwd()
+	m := RunManifest{
+		RunID:       opts.RunID,
+		Tool:        version.Get(),
+		StartedAt:   startedAt,
+		CompletedAt: time.Now(),
+		ConfigPath:  opts.ConfigPath,
+		ConfigHash:  hashFile(opts.ConfigPath),
+		Seed:        opts.Seed,
+		Environment: RunEnvironment{
+			GOOS:       runtime.GOOS,
+			GOARCH:     runtime.GOARCH,
+			NumCPU:     runtime.NumCPU(),
+			WorkingDir: wd,
+		},
+		Options:  redactOptions(opts),
+		Warnings: warns,
+	}
+	if runErr != nil {
+		m.Error = runErr.Error()
+	}
+	return m
+}
+
+// writeRunManifest writes run.json when the run produced an output directory.
+func writeRunManifest(opts Options, startedAt time.Time, runErr error, warns []warnings.Warning) error {
+	if opts.DryRun {
+		return nil
+	}
+	if info, err := os.Stat(opts.OutputDir); err != nil || !info.IsDir() {
+		return nil
+	}
+	data, err := json.MarshalIndent(buildRunManifest(opts, startedAt, runErr, warns), "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFile(filepath.Join(opts.OutputDir, "run.json"), data, 0600)
+}
+
+func hashFile(path string) string {
+	if path == "" {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// redactOptions masks credentials so run.json can be shared.
+func redactOptions(opts Options) Options {
+	opts.AuthHeaders = redactValues(opts.AuthHeaders)
+	opts.AuthCookies = redactValues(opts.AuthCookies)
+	if opts.Login != nil {
+		login := *opts.Login
+		if login.Username != "" {
+			login.Username = redacted
+		}
+		login.Password = redacted
+		opts.Login = &login
+	}
+	opts.ProxyURL = redactURL(opts.ProxyURL)
+	opts.URL = redactURL(opts.URL)
+	opts.SitemapURL = redactURL(opts.SitemapURL)
+	if len(opts.URLs) > 0 {
+		urls := make([]string, len(opts.URLs))
+		for i, u := range opts.URLs {
+			urls[i] = redactURL(u)
+		}
+		opts.URLs = urls
+	}
+	if opts.CacheProxy != "" {
+		if u, err := url.Parse(opts.CacheProxy); err == nil {
+			if q := u.Query(); q.Has("token") {
+				q.Set("token", redacted)
+				u.RawQuery = q.Encode()
+			}
+			opts.CacheProxy = u.Redacted()
+		}
+	}
+	return opts
+}
+
+// redactURL masks the password of user:pass@ credentials in rawURL.
+func redactURL(rawURL string) string {
+	if rawURL == "" {
+		return rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Redacted()
+}
+
+func redactValues(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return m
+	}
+	out := make(map[string]string, len(m))
+	for k := range m {
+		out[k] = redacted
+	}
+	return out
+}
+
+// newRunID returns a sortable, unique run ID such as
+// "20260102T150405Z-3f9a1c2b".
+func newRunID(startedAt time.Time) string {
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	return startedAt.UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(b[:])
+}
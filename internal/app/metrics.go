@@ -0,0 +1,25 @@
+package app
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"go_scrap/internal/footprint"
+	"go_scrap/internal/fsutil"
+)
+
+// writeMetrics saves the run's network footprint next to run.json.
+func writeMetrics(opts Options, summary footprint.Summary) error {
+	if opts.DryRun {
+		return nil
+	}
+	if info, err := os.Stat(opts.OutputDir); err != nil || !info.IsDir() {
+		return nil
+	}
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFile(filepath.Join(opts.OutputDir, "metrics.json"), data, 0600)
+}
@@ -2,13 +2,16 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 
+	"go_scrap/internal/byline"
+	"go_scrap/internal/dates"
 	"go_scrap/internal/fetch"
 	"go_scrap/internal/menu"
-	"go_scrap/internal/output"
 	"go_scrap/internal/parse"
+	"go_scrap/internal/textnorm"
 
 	"github.com/PuerkitoBio/goquery"
 )
@@ -23,7 +26,7 @@ func buildDocument(ctx context.Context, opts Options, baseDoc *goquery.Document)
 	if opts.NavWalk && strings.TrimSpace(opts.NavSelector) != "" {
 		return runNavWalk(ctx, opts, baseDoc)
 	}
-	return parseDocuments(baseDoc, opts.ContentSelector)
+	return parseDocuments(ctx, baseDoc, opts)
 }
 
 func runNavWalk(ctx context.Context, opts Options, baseDoc *goquery.Document) (*parse.Document, error) {
@@ -34,7 +37,12 @@ func runNavWalk(ctx context.Context, opts Options, baseDoc *goquery.Document) (*
 	items := flattenMenu(nodes)
 	anchors := collectAnchors(items)
 
-	htmlByAnchor, err := fetch.AnchorHTML(ctx, buildFetchOptions(opts, fetch.ModeDynamic), anchors)
+	var htmlByAnchor map[string]string
+	err = retrier(opts).Do(ctx, func(int) error {
+		var err error
+		htmlByAnchor, err = fetch.AnchorHTML(ctx, buildFetchOptions(opts, fetch.ModeDynamic), anchors)
+		return err
+	})
 	if err != nil {
 		if ctx.Err() != nil {
 			return nil, fmt.Errorf("navwalk timed out processing %d anchors (try increasing --timeout or reducing menu depth): %w", len(anchors), err)
@@ -42,7 +50,10 @@ func runNavWalk(ctx context.Context, opts Options, baseDoc *goquery.Document) (*
 		return nil, err
 	}
 
-	sections, headings := buildNavSections(items, anchors, htmlByAnchor, opts)
+	sections, headings := buildNavSections(ctx, items, anchors, htmlByAnchor, opts)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	return &parse.Document{
 		HTML:               documentOuterHTML(baseDoc),
@@ -51,6 +62,9 @@ func runNavWalk(ctx context.Context, opts Options, baseDoc *goquery.Document) (*
 		AnchorTargets:      anchors,
 		AllElementIDs:      headings,
 		AnchorTargetsByRaw: anchors,
+		Dates:              dates.FromDocument(baseDoc),
+		Byline:             byline.FromDocument(baseDoc),
+		Title:              parse.PageTitle(baseDoc),
 	}, nil
 }
 
@@ -70,10 +84,13 @@ func collectAnchors(items []menuItem) []string {
 	return anchors
 }
 
-func buildNavSections(items []menuItem, anchors []string, htmlByAnchor map[string]string, opts Options) ([]parse.Section, []string) {
+func buildNavSections(ctx context.Context, items []menuItem, anchors []string, htmlByAnchor map[string]string, opts Options) ([]parse.Section, []string) {
 	sections := []parse.Section{}
 	headings := []string{}
 	for _, item := range items {
+		if ctx.Err() != nil {
+			break
+		}
 		if item.Anchor == "" {
 			continue
 		}
@@ -81,7 +98,7 @@ func buildNavSections(items []menuItem, anchors []string, htmlByAnchor map[strin
 		if !ok {
 			continue
 		}
-		section, ok := buildSectionFromAnchor(item, htmlForAnchor, anchors, opts)
+		section, ok := buildSectionFromAnchor(ctx, item, htmlForAnchor, anchors, opts)
 		if !ok {
 			continue
 		}
@@ -91,12 +108,13 @@ func buildNavSections(items []menuItem, anchors []string, htmlByAnchor map[strin
 	return sections, headings
 }
 
-func buildSectionFromAnchor(item menuItem, htmlForAnchor string, anchors []string, opts Options) (parse.Section, bool) {
+func buildSectionFromAnchor(ctx context.Context, item menuItem, htmlForAnchor string, anchors []string, opts Options) (parse.Section, bool) {
 	anchorDoc, err := parse.NewDocument(htmlForAnchor)
 	if err != nil {
 		return parse.Section{}, false
 	}
-	contentDoc := prepareContentDoc(anchorDoc, opts, item.Anchor)
+	textnorm.Document(anchorDoc, textNormOptions(opts))
+	contentDoc := prepareContentDoc(ctx, anchorDoc, opts, item.Anchor)
 
 	contentHTML := documentOuterHTML(contentDoc)
 	contentText := strings.TrimSpace(contentDoc.Text())
@@ -106,7 +124,7 @@ func buildSectionFromAnchor(item menuItem, htmlForAnchor string, anchors []strin
 		level = 6
 	}
 	section := parse.Section{
-		HeadingText:   strings.TrimSpace(item.Title),
+		HeadingText:   strings.TrimSpace(textnorm.Heading(item.Title, textNormOptions(opts))),
 		HeadingLevel:  level,
 		HeadingID:     item.Anchor,
 		ContentHTML:   contentHTML,
@@ -117,11 +135,9 @@ func buildSectionFromAnchor(item menuItem, htmlForAnchor string, anchors []strin
 	return section, true
 }
 
-func prepareContentDoc(anchorDoc *goquery.Document, opts Options, anchor string) *goquery.Document {
+func prepareContentDoc(ctx context.Context, anchorDoc *goquery.Document, opts Options, anchor string) *goquery.Document {
 	applyExclusions(anchorDoc, opts.ExcludeSelector)
-	if opts.DownloadAssets && !opts.DryRun {
-		_ = output.Download(anchorDoc, opts.URL, opts.OutputDir, opts.UserAgent)
-	}
+	downloadAssets(ctx, opts, anchorDoc)
 	baseDoc := anchorDoc
 	if strings.TrimSpace(opts.ContentSelector) != "" {
 		extracted, err := parse.ExtractBySelector(anchorDoc, opts.ContentSelector)
@@ -221,26 +237,53 @@ func flattenMenu(nodes []menu.Node) []menuItem {
 	return items
 }
 
-func parseDocuments(doc *goquery.Document, contentSelector string) (*parse.Document, error) {
-	fullDoc, err := parse.Parse(doc)
+// parseDocuments parses the content selected by opts.ContentSelector, falling
+// back to the whole page (with a selector_fallback warning) when the selector
+// matches nothing or yields no sections.
+func parseDocuments(ctx context.Context, doc *goquery.Document, opts Options) (*parse.Document, error) {
+	slugs := slugStrategy(opts)
+	fullDoc, err := parse.ParseWithSlugger(doc, slugs)
 	if err != nil {
 		return nil, err
 	}
 
+	contentSelector := strings.TrimSpace(opts.ContentSelector)
 	contentDoc := doc
-	if strings.TrimSpace(contentSelector) != "" {
+	if contentSelector != "" {
 		extracted, err := parse.ExtractBySelector(doc, contentSelector)
 		if err == nil && extracted != nil {
 			contentDoc = extracted
+		} else {
+			warnSelectorFallback(ctx, opts.URL, contentSelector, "matched nothing")
+		}
+	}
+
+	if strings.TrimSpace(opts.ItemSelector) != "" {
+		items, err := parse.ParseItems(contentDoc, itemSelectors(opts), slugs)
+		if err == nil {
+			items.AnchorTargets = fullDoc.AnchorTargets
+			items.AllElementIDs = fullDoc.AllElementIDs
+			items.AnchorTargetsByRaw = fullDoc.AnchorTargetsByRaw
+			items.Dates = fullDoc.Dates
+			items.Byline = fullDoc.Byline
+			items.Title = fullDoc.Title
+			return items, nil
 		}
+		if !errors.Is(err, parse.ErrNoItems) {
+			return nil, err
+		}
+		warnItemFallback(ctx, opts.URL, opts.ItemSelector)
 	}
 
-	contentParsed, err := parse.Parse(contentDoc)
+	contentParsed, err := parse.ParseWithSlugger(contentDoc, slugs)
 	if err != nil {
 		return nil, err
 	}
 
 	if len(contentParsed.Sections) == 0 {
+		if contentDoc != doc && len(fullDoc.Sections) > 0 {
+			warnSelectorFallback(ctx, opts.URL, contentSelector, "yielded no sections")
+		}
 		return fullDoc, nil
 	}
 
@@ -248,9 +291,21 @@ func parseDocuments(doc *goquery.Document, contentSelector string) (*parse.Docum
 	contentParsed.AnchorTargets = fullDoc.AnchorTargets
 	contentParsed.AllElementIDs = fullDoc.AllElementIDs
 	contentParsed.AnchorTargetsByRaw = fullDoc.AnchorTargetsByRaw
+	contentParsed.Dates = fullDoc.Dates
+	contentParsed.Byline = fullDoc.Byline
+	contentParsed.Title = fullDoc.Title
 	return contentParsed, nil
 }
 
+func itemSelectors(opts Options) parse.ItemSelectors {
+	return parse.ItemSelectors{
+		Item:  opts.ItemSelector,
+		Title: opts.ItemTitleSelector,
+		Body:  opts.ItemBodySelector,
+		Date:  opts.ItemDateSelector,
+	}
+}
+
 func documentOuterHTML(doc *goquery.Document) string {
 	if doc == nil || doc.Selection == nil {
 		return ""
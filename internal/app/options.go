@@ -2,33 +2,294 @@ package app
 
 import (
 	"errors"
+	"fmt"
 	"net/url"
+	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
 
+	"go_scrap/internal/cacheproxy"
+	"go_scrap/internal/crawler"
+	"go_scrap/internal/dates"
 	"go_scrap/internal/fetch"
+	"go_scrap/internal/output"
+	"go_scrap/internal/sanitize"
+	"go_scrap/internal/slug"
+	"go_scrap/internal/textnorm"
+	"go_scrap/internal/tokenize"
 )
 
 func normalizeOptions(opts Options) (Options, error) {
-	if strings.TrimSpace(opts.URL) == "" && !opts.Crawl {
+	rewriter, err := fetch.NewRewriter(opts.URLRewrites)
+	if err != nil {
+		return opts, err
+	}
+	opts.rewriter = rewriter
+	opts.URL = rewriter.Rewrite(opts.URL)
+	opts.SitemapURL = rewriter.Rewrite(opts.SitemapURL)
+	if len(opts.URLs) > 0 {
+		urls := make([]string, len(opts.URLs))
+		for i, u := range opts.URLs {
+			urls[i] = rewriter.Rewrite(u)
+		}
+		opts.URLs = urls
+	}
+	if len(opts.URLs) > 0 {
+		if opts.Crawl {
+			return opts, errors.New("url-file cannot be combined with crawl mode")
+		}
+		for _, u := range opts.URLs {
+			if parsed, err := url.Parse(u); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				return opts, fmt.Errorf("url-file: %q is not an http(s) URL", u)
+			}
+		}
+	}
+	if strings.TrimSpace(opts.URL) == "" && !opts.Crawl && len(opts.URLs) == 0 {
 		return opts, errors.New("url is required")
 	}
 	if opts.Crawl && strings.TrimSpace(opts.URL) == "" && strings.TrimSpace(opts.SitemapURL) == "" {
 		return opts, errors.New("url or sitemap is required for crawl mode")
 	}
+	if err := output.ValidateSectionFields(opts.JSONFields); err != nil {
+		return opts, err
+	}
+	if err := output.ValidateMediaTypes(opts.DownloadMedia); err != nil {
+		return opts, err
+	}
+	switch opts.JSONFormat {
+	case "":
+		opts.JSONFormat = JSONFormatJSON
+	case JSONFormatJSON, JSONFormatNDJSON:
+	default:
+		return opts, fmt.Errorf("unknown json format %q (expected json or ndjson)", opts.JSONFormat)
+	}
+	if opts.MinPageChars < 0 || opts.MaxPageChars < 0 {
+		return opts, errors.New("min-page-chars and max-page-chars must not be negative")
+	}
+	if opts.MaxPageChars > 0 && opts.MinPageChars > opts.MaxPageChars {
+		return opts, fmt.Errorf("min-page-chars (%d) is greater than max-page-chars (%d)", opts.MinPageChars, opts.MaxPageChars)
+	}
+	if opts.ClientKey != "" && opts.ClientCert == "" {
+		return opts, errors.New("client-key requires client-cert")
+	}
+	if _, err := fetch.ClientTLSConfig(opts.ClientCert, opts.ClientKey); err != nil {
+		return opts, err
+	}
+	if opts.QueueDir != "" {
+		if !opts.Crawl {
+			return opts, errors.New("queue-dir requires crawl mode")
+		}
+		if opts.Resume {
+			return opts, errors.New("queue-dir cannot be combined with resume")
+		}
+		if opts.WorkerID == "" {
+			opts.WorkerID = defaultWorkerID()
+		}
+		if opts.QueueLease <= 0 {
+			opts.QueueLease = time.Duration(DefaultQueueLeaseSeconds) * time.Second
+		}
+	}
+	mode, err := crawler.ParseMaxPagesMode(opts.MaxPagesMode)
+	if err != nil {
+		return opts, err
+	}
+	opts.MaxPagesMode = string(mode)
+	switch opts.PreferView {
+	case "":
+		opts.PreferView = PreferViewOff
+	case PreferViewOff, PreferViewAMP, PreferViewPrint, PreferViewAny:
+	default:
+		return opts, fmt.Errorf("unknown prefer-view %q (expected off, amp, print or any)", opts.PreferView)
+	}
+	switch opts.SoftPages {
+	case "":
+		opts.SoftPages = SoftPagesKeep
+	case SoftPagesKeep, SoftPagesDrop, SoftPagesRetry:
+	default:
+		return opts, fmt.Errorf("unknown soft-pages mode %q (expected keep, drop or retry-dynamic)", opts.SoftPages)
+	}
+	if opts.AssetRetries < 0 || opts.AssetRetryBackoff < 0 {
+		return opts, errors.New("asset-retries and asset-retry-backoff-ms must not be negative")
+	}
+	switch opts.AssetNames {
+	case "":
+		opts.AssetNames = AssetNamesHash
+	case AssetNamesHash, AssetNamesOriginal:
+	default:
+		return opts, fmt.Errorf("unknown asset-names mode %q (expected hash or original)", opts.AssetNames)
+	}
+	switch opts.PageNames {
+	case "":
+		opts.PageNames = PageNamesURL
+	case PageNamesURL, PageNamesTitle:
+	default:
+		return opts, fmt.Errorf("unknown page-names mode %q (expected url or title)", opts.PageNames)
+	}
+	if opts.RenderConcurrency < 0 {
+		return opts, errors.New("render-concurrency must not be negative")
+	}
+	if opts.ConvertCacheSize < 0 {
+		return opts, errors.New("convert-cache must not be negative")
+	}
+	if opts.CacheMaxMB < 0 {
+		return opts, errors.New("cache-max-mb must not be negative")
+	}
+	if opts.CacheTTL < 0 {
+		return opts, errors.New("cache-ttl must not be negative")
+	}
+	switch opts.Sign {
+	case "", SignMinisign, SignCosign:
+	default:
+		return opts, fmt.Errorf("unknown signing tool %q (expected minisign or cosign)", opts.Sign)
+	}
+	if opts.SignKey != "" && opts.Sign == "" {
+		return opts, errors.New("sign-key requires --sign")
+	}
+	if strings.TrimSpace(opts.ItemSelector) == "" && (opts.ItemTitleSelector != "" || opts.ItemBodySelector != "" || opts.ItemDateSelector != "") {
+		return opts, errors.New("item-title, item-body and item-date require --item-selector")
+	}
+	if opts.ItemSelector != "" && opts.NavWalk {
+		return opts, errors.New("item-selector cannot be combined with nav-walk")
+	}
+	if opts.MaxScrolls < 0 || opts.ScrollQuiet < 0 {
+		return opts, errors.New("max-scrolls and scroll-quiet-ms must not be negative")
+	}
+	if opts.PageTimeout < 0 {
+		return opts, errors.New("page-timeout must not be negative")
+	}
+	if opts.ProcessWorkers < 0 {
+		return opts, errors.New("process-workers must not be negative")
+	}
+	if opts.ChunkTokens < 0 || opts.ChunkOverlap < 0 {
+		return opts, errors.New("chunk-tokens and chunk-overlap must not be negative")
+	}
+	if opts.ChunkOverlap > 0 && opts.ChunkOverlap >= opts.ChunkTokens {
+		return opts, errors.New("chunk-overlap must be smaller than chunk-tokens")
+	}
+	if opts.Tokenizer == "" {
+		opts.Tokenizer = tokenize.Approx
+	}
+	if err := tokenize.Validate(opts.Tokenizer); err != nil {
+		return opts, err
+	}
+	if opts.SplitByHeadingLevel < 0 || opts.SplitByHeadingLevel > 6 {
+		return opts, errors.New("split-by-heading-level must be between 1 and 6 (0 = off)")
+	}
+	switch opts.Citation {
+	case "", CitationSection, CitationPage:
+	default:
+		return opts, fmt.Errorf("unknown citation mode %q (expected section or page)", opts.Citation)
+	}
+	if opts.CitationTemplate == "" {
+		opts.CitationTemplate = DefaultCitationTemplate
+	}
+	if err := output.ValidateCitationTemplate(opts.CitationTemplate); err != nil {
+		return opts, err
+	}
+	switch opts.AnchorScope {
+	case "":
+		opts.AnchorScope = AnchorScopeCrawl
+	case AnchorScopePage, AnchorScopeCrawl:
+	default:
+		return opts, fmt.Errorf("unknown anchor scope %q (expected crawl or page)", opts.AnchorScope)
+	}
+	switch opts.Newline {
+	case "":
+		opts.Newline = output.NewlineLF
+	case output.NewlineLF, output.NewlineCRLF:
+	default:
+		return opts, fmt.Errorf("unknown newline style %q (expected lf or crlf)", opts.Newline)
+	}
+	if _, err := sanitize.Lookup(opts.Sanitize); err != nil {
+		return opts, err
+	}
+	if _, _, err := headingFilters(opts); err != nil {
+		return opts, err
+	}
+	if _, err := dates.ParseWindow(opts.Since, opts.Until); err != nil {
+		return opts, err
+	}
+	if err := textnorm.ValidateEmoji(opts.Emoji); err != nil {
+		return opts, err
+	}
+	if opts.Emoji == "" {
+		opts.Emoji = textnorm.EmojiKeep
+	}
+	if opts.SlugPattern != "" && (opts.Slug == "" || opts.Slug == slug.Default) {
+		opts.Slug = slug.Custom
+	}
+	if _, err := slug.Lookup(opts.Slug, opts.SlugPattern); err != nil {
+		return opts, err
+	}
+	opts, err = resolvePreset(opts)
+	if err != nil {
+		return opts, err
+	}
+	named, err := fetch.LookupMiddleware(opts.FetchMiddleware)
+	if err != nil {
+		return opts, err
+	}
+	opts.Middleware = append(append([]fetch.Middleware(nil), opts.Middleware...), named...)
+	if opts.CacheProxy != "" {
+		endpoint, err := cacheproxy.Endpoint(opts.CacheProxy)
+		if err != nil {
+			return opts, err
+		}
+		opts.Middleware = append(opts.Middleware, cacheproxy.Middleware(endpoint))
+	}
 	if opts.Mode == "" {
 		opts.Mode = fetch.ModeAuto
 	}
+	if err := fetch.ValidateBrowser(opts.Browser); err != nil {
+		return opts, err
+	}
+	if opts.Login != nil {
+		login := withLoginEnv(*opts.Login)
+		if err := login.Validate(); err != nil {
+			return opts, err
+		}
+		opts.Login = &login
+	}
 	if opts.Timeout == 0 {
 		opts.Timeout = time.Duration(DefaultTimeoutSeconds) * time.Second
 	}
+	if opts.HookTimeout < 0 {
+		opts.HookTimeout = time.Duration(DefaultHookTimeoutSeconds) * time.Second
+	}
+	if opts.Seed == 0 {
+		opts.Seed = time.Now().UnixNano()
+	}
+	if err := opts.Retry.Validate(); err != nil {
+		return opts, err
+	}
+	opts.Retry = opts.Retry.WithDefaults()
+	opts.retrier = fetch.NewRetrier(opts.Retry, opts.Seed)
 	if opts.UserAgent == "" {
 		opts.UserAgent = DefaultUserAgent
 	}
+	if opts.Watch < 0 {
+		return opts, errors.New("watch interval must not be negative")
+	}
+	if opts.Watch > 0 && (opts.DryRun || opts.Stdout || opts.Repair || opts.QueueDir != "") {
+		return opts, errors.New("watch cannot be combined with dry-run, stdout, repair or queue-dir")
+	}
+	if opts.PublishDir != "" {
+		if opts.OutputDir != "" && filepath.Clean(opts.OutputDir) != filepath.Clean(opts.PublishDir) {
+			return opts, errors.New("publish-dir cannot be combined with output-dir")
+		}
+		if opts.Stdout {
+			return opts, errors.New("publish-dir cannot be combined with stdout")
+		}
+		// Read the published output until run switches to the release.
+		opts.OutputDir = opts.PublishDir
+	}
 	if opts.OutputDir == "" {
 		urlForHost := opts.URL
+		if urlForHost == "" && len(opts.URLs) > 0 {
+			urlForHost = opts.URLs[0]
+		}
 		if urlForHost == "" {
 			urlForHost = opts.SitemapURL
 		}
@@ -44,6 +305,16 @@ func normalizeOptions(opts Options) (Options, error) {
 	return opts, nil
 }
 
+// defaultWorkerID names a shared-queue worker after its host and process so
+// several instances on one machine do not collide.
+func defaultWorkerID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "worker"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
 func hostFromURL(urlStr string) string {
 	if !strings.Contains(urlStr, "://") {
 		urlStr = "https://" + urlStr
@@ -0,0 +1,153 @@
+package app
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"go_scrap/internal/crawler"
+	"go_scrap/internal/fsutil"
+)
+
+// stagingDirName holds pages under pages/ until --page-names title moves
+// them to their named directory.
+const stagingDirName = ".staging"
+
+// maxPageNameLen caps a title-derived directory name, in bytes.
+const maxPageNameLen = 80
+
+// pageNamer names crawl page directories from page titles. Pages are written
+// to a staging directory while they are processed, in any order, and moved to
+// their name as outcomes are merged in URL order, so repeated titles get the
+// same -2, -3 suffixes on every run.
+type pageNamer struct {
+	opts     Options
+	pagesDir string
+	// taken maps each claimed name to the URL it belongs to.
+	taken map[string]string
+}
+
+// newPageNamer returns the namer for a crawl writing to pagesDir, or nil
+// unless --page-names is title. The directories of pages written by an
+// earlier run stay reserved for their URLs.
+func newPageNamer(opts Options, pagesDir string, earlier []crawler.PageEntry) *pageNamer {
+	if opts.PageNames != PageNamesTitle {
+		return nil
+	}
+	n := &pageNamer{opts: opts, pagesDir: pagesDir, taken: map[string]string{}}
+	for _, page := range earlier {
+		if page.Status == "success" && page.OutputDir != "" {
+			n.taken[filepath.Base(filepath.FromSlash(page.OutputDir))] = page.URL
+		}
+	}
+	return n
+}
+
+// stagingPageDir is where pageURL is written before it is named.
+func stagingPageDir(pagesDir, pageURL string) string {
+	sum := sha256.Sum256([]byte(pageURL))
+	return filepath.Join(pagesDir, stagingDirName, hex.EncodeToString(sum[:8]))
+}
+
+// place moves the page staged in dir to a directory named from title and
+// returns it. Pages without a usable title keep their URL-path directory.
+// A directory of that name left by an earlier run is replaced.
+func (n *pageNamer) place(dir, pageURL, title string) (string, error) {
+	name := n.name(pageURL, title)
+	var target string
+	if name == "" {
+		var err error
+		if target, err = urlToOutputDir(pageURL, n.pagesDir); err != nil {
+			return "", err
+		}
+	} else {
+		target = filepath.Join(n.pagesDir, name)
+	}
+	if err := os.RemoveAll(target); err != nil {
+		return "", err
+	}
+	if err := fsutil.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", err
+	}
+	if err := os.Rename(dir, target); err != nil {
+		return "", fmt.Errorf("name page directory: %w", err)
+	}
+	return target, nil
+}
+
+// name returns the directory name for title not claimed by another URL and
+// claims it for pageURL, or "" when title has nothing to slugify.
+func (n *pageNamer) name(pageURL, title string) string {
+	base := sanitizePathComponent(slugStrategy(n.opts).Slug(title))
+	if len(base) > maxPageNameLen {
+		base = strings.TrimRight(truncateUTF8(base, maxPageNameLen), "-_. ")
+	}
+	if base == "" || base == "_" || base == stagingDirName {
+		return ""
+	}
+	name := base
+	for i := 2; n.taken[name] != "" && n.taken[name] != pageURL; i++ {
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+	n.taken[name] = pageURL
+	return name
+}
+
+// cleanup removes what is left of the staging directory: pages that were
+// skipped or failed.
+func (n *pageNamer) cleanup() {
+	_ = os.RemoveAll(filepath.Join(n.pagesDir, stagingDirName))
+}
+
+// relinkedExts are the page files that may link to downloaded assets.
+var relinkedExts = map[string]bool{".md": true, ".json": true, ".jsonl": true, ".ndjson": true, ".html": true}
+
+// relinkAssets rewrites links to shared assets in the files under dir from
+// the prefix from to to. Gzipped JSON is left as written.
+func relinkAssets(dir, from, to string) error {
+	if from == to {
+		return nil
+	}
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !relinkedExts[filepath.Ext(path)] {
+			return err
+		}
+		data, err := fsutil.ReadFile(path)
+		if err != nil || !bytes.Contains(data, []byte(from)) {
+			return err
+		}
+		return fsutil.WriteFile(path, bytes.ReplaceAll(data, []byte(from), []byte(to)), 0600)
+	})
+}
+
+// pageOutputDir returns the directory of a page recorded in the crawl index
+// under rootDir: its recorded output_dir, or else its URL path under pages/.
+func pageOutputDir(page crawler.PageEntry, rootDir string) (string, error) {
+	if page.OutputDir != "" {
+		return filepath.Join(rootDir, filepath.FromSlash(page.OutputDir)), nil
+	}
+	return urlToOutputDir(page.URL, filepath.Join(rootDir, "pages"))
+}
+
+// relativePageDir is pageDir relative to the crawl output directory above
+// pagesDir, as recorded in the crawl index.
+func relativePageDir(pagesDir, pageDir string) string {
+	rel, err := filepath.Rel(filepath.Dir(pagesDir), pageDir)
+	if err != nil {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+func truncateUTF8(s string, n int) string {
+	for n > 0 && n < len(s) && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
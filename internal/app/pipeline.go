@@ -2,20 +2,37 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"go_scrap/internal/crawler"
+	"go_scrap/internal/dates"
 	"go_scrap/internal/markdown"
 	"go_scrap/internal/output"
+	"go_scrap/internal/pageclass"
 	"go_scrap/internal/parse"
 	"go_scrap/internal/report"
+	"go_scrap/internal/sanitize"
+	"go_scrap/internal/slug"
+	"go_scrap/internal/textnorm"
 
 	"github.com/PuerkitoBio/goquery"
 )
 
 type pipeline struct {
-	conv  *markdown.Converter
-	hooks []Hook
+	converters *markdown.Pool
+	hooks      []Hook
+	// renderWorkers is how many sections are converted at once.
+	renderWorkers int
+	// convertCache is shared by every converter of the run (nil when off).
+	convertCache *markdown.Cache
 }
 
 type analysisResult struct {
@@ -42,7 +59,21 @@ func newPipeline(opts Options) (*pipeline, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &pipeline{conv: markdown.NewConverter(), hooks: hooks}, nil
+	cache := markdown.NewCache(opts.ConvertCacheSize)
+	newConverter := opts.NewConverter
+	if newConverter == nil {
+		newConverter = markdown.NewConverter
+	}
+	return &pipeline{
+		converters: markdown.NewPool(func() *markdown.Converter {
+			conv := newConverter()
+			conv.UseCache(cache)
+			return conv
+		}),
+		hooks:         hooks,
+		renderWorkers: renderWorkers(opts.RenderConcurrency),
+		convertCache:  cache,
+	}, nil
 }
 
 func (p *pipeline) analyze(ctx context.Context, opts Options, baseDoc *goquery.Document, allowNavWalk bool) (analysisResult, error) {
@@ -53,56 +84,301 @@ func (p *pipeline) analyze(ctx context.Context, opts Options, baseDoc *goquery.D
 	if allowNavWalk {
 		doc, err = buildDocument(ctx, opts, baseDoc)
 	} else {
-		doc, err = parseDocuments(baseDoc, opts.ContentSelector)
+		doc, err = parseDocuments(ctx, baseDoc, opts)
+	}
+	if err != nil {
+		return analysisResult{}, err
+	}
+	policy, err := sanitize.Lookup(opts.Sanitize)
+	if err != nil {
+		return analysisResult{}, err
+	}
+	sanitizeSections(doc, policy)
+	doc.Byline = doc.Byline.Resolve(opts.URL)
+	attributeSections(doc)
+	include, exclude, err := headingFilters(opts)
+	if err != nil {
+		return analysisResult{}, err
 	}
+	filtered := filterSections(doc, include, exclude)
+	window, err := dates.ParseWindow(opts.Since, opts.Until)
 	if err != nil {
 		return analysisResult{}, err
 	}
-	return analysisResult{Doc: doc, Rep: report.Analyze(doc)}, nil
+	dateFiltered := filterSectionsByDate(doc, window)
+	dropped := 0
+	if opts.DropEmptySections {
+		dropped = dropEmptySections(doc)
+	}
+	rep := report.Analyze(doc)
+	rep.FilteredSections = filtered
+	rep.DateFilteredSections = dateFiltered
+	rep.DroppedEmptySections = dropped
+	return analysisResult{Doc: doc, Rep: rep}, nil
+}
+
+// reanalyze recomputes the report after hooks changed sections, keeping the
+// counts of sections removed before the first analysis.
+func reanalyze(doc *parse.Document, prev report.Report) report.Report {
+	rep := report.Analyze(doc)
+	rep.FilteredSections = prev.FilteredSections
+	rep.DateFilteredSections = prev.DateFilteredSections
+	rep.DroppedEmptySections = prev.DroppedEmptySections
+	return rep
+}
+
+func headingFilters(opts Options) (include, exclude *regexp.Regexp, err error) {
+	if strings.TrimSpace(opts.IncludeHeadings) != "" {
+		if include, err = regexp.Compile(opts.IncludeHeadings); err != nil {
+			return nil, nil, fmt.Errorf("invalid include-headings regex: %w", err)
+		}
+	}
+	if strings.TrimSpace(opts.ExcludeHeadings) != "" {
+		if exclude, err = regexp.Compile(opts.ExcludeHeadings); err != nil {
+			return nil, nil, fmt.Errorf("invalid exclude-headings regex: %w", err)
+		}
+	}
+	return include, exclude, nil
+}
+
+// filterSections applies the heading filters to whole subtrees: an excluded
+// heading takes its deeper subsections with it, and subsections of an
+// included heading are kept whatever their own heading. Exclusion wins. It
+// returns the number of sections removed.
+func filterSections(doc *parse.Document, include, exclude *regexp.Regexp) int {
+	if include == nil && exclude == nil {
+		return 0
+	}
+	kept := doc.Sections[:0]
+	removed := 0
+	excludedLevel, includedLevel := 0, 0
+	for _, s := range doc.Sections {
+		level := s.HeadingLevel
+		if excludedLevel > 0 && level > excludedLevel {
+			removed++
+			continue
+		}
+		excludedLevel = 0
+		if includedLevel > 0 && level <= includedLevel {
+			includedLevel = 0
+		}
+		if exclude != nil && exclude.MatchString(s.HeadingText) {
+			excludedLevel = level
+			removed++
+			continue
+		}
+		if include != nil && includedLevel == 0 {
+			if !include.MatchString(s.HeadingText) {
+				removed++
+				continue
+			}
+			includedLevel = level
+		}
+		kept = append(kept, s)
+	}
+	doc.Sections = kept
+	return removed
+}
+
+// filterSectionsByDate removes sections dated outside window. A section
+// without its own date takes the page's date; undated content is kept. It
+// returns the number of sections removed.
+func filterSectionsByDate(doc *parse.Document, window dates.Window) int {
+	if window.IsZero() {
+		return 0
+	}
+	pageDate := doc.Dates.Latest()
+	kept := doc.Sections[:0]
+	removed := 0
+	for _, s := range doc.Sections {
+		date := pageDate
+		if t, ok := dates.Parse(s.Date); ok {
+			date = t
+		}
+		if !window.Contains(date) {
+			removed++
+			continue
+		}
+		kept = append(kept, s)
+	}
+	doc.Sections = kept
+	return removed
+}
+
+// attributeSections gives sections without a byline of their own the page's
+// authors, so each index and corpus record can be cited on its own.
+func attributeSections(doc *parse.Document) {
+	authors := doc.Byline.AuthorNames()
+	if len(authors) == 0 {
+		return
+	}
+	for i := range doc.Sections {
+		if len(doc.Sections[i].Authors) == 0 {
+			doc.Sections[i].Authors = authors
+		}
+	}
+}
+
+// dateSkipReason flags crawled pages left without sections by --since and
+// --until.
+func dateSkipReason(result analysisResult) string {
+	if result.Rep.DateFilteredSections == 0 || len(result.Doc.Sections) > 0 {
+		return ""
+	}
+	if latest := result.Doc.Dates.Latest(); !latest.IsZero() {
+		return fmt.Sprintf("dated %s, outside --since/--until", dates.Format(latest))
+	}
+	return fmt.Sprintf("all %d sections dated outside --since/--until", result.Rep.DateFilteredSections)
+}
+
+// dropEmptySections removes sections without text or media, keeping empty
+// headings that introduce deeper subsections so heading paths stay intact.
+// It returns the number of sections removed.
+func dropEmptySections(doc *parse.Document) int {
+	kept := doc.Sections[:0]
+	dropped := 0
+	for i, s := range doc.Sections {
+		parent := i+1 < len(doc.Sections) && doc.Sections[i+1].HeadingLevel > s.HeadingLevel
+		if !parent && sectionIsEmpty(s) {
+			dropped++
+			continue
+		}
+		kept = append(kept, s)
+	}
+	doc.Sections = kept
+	return dropped
+}
+
+var sectionMediaTags = []string{"<img", "<picture", "<svg", "<video", "<audio", "<iframe", "<table", "<pre"}
+
+func sectionIsEmpty(s parse.Section) bool {
+	if strings.TrimSpace(s.ContentText) != "" {
+		return false
+	}
+	content := strings.ToLower(s.ContentHTML)
+	for _, tag := range sectionMediaTags {
+		if strings.Contains(content, tag) {
+			return false
+		}
+	}
+	return true
 }
 
-func (p *pipeline) prepareDocument(_ context.Context, opts Options, html string) (*goquery.Document, error) {
+// sanitizeSections strips active content from section HTML before it reaches
+// content.json, index.jsonl, or the Markdown converter.
+func sanitizeSections(doc *parse.Document, policy *sanitize.Policy) {
+	if policy == nil {
+		return
+	}
+	for i := range doc.Sections {
+		doc.Sections[i].HeadingHTML = policy.HTML(doc.Sections[i].HeadingHTML)
+		doc.Sections[i].ContentHTML = policy.HTML(doc.Sections[i].ContentHTML)
+	}
+}
+
+// slugStrategy returns the heading slug strategy for opts; options are
+// validated up front, so an invalid strategy falls back to the default.
+func slugStrategy(opts Options) *slug.Strategy {
+	s, _ := slug.Lookup(opts.Slug, opts.SlugPattern)
+	return s
+}
+
+// textNormOptions maps run options onto the Unicode normalizer. It runs
+// before parsing so heading IDs are slugged from the normalized text.
+func textNormOptions(opts Options) textnorm.Options {
+	return textnorm.Options{NFC: opts.NormalizeUnicode, Emoji: opts.Emoji}
+}
+
+func (p *pipeline) prepareDocument(ctx context.Context, opts Options, html string) (*goquery.Document, error) {
 	doc, err := parse.NewDocument(html)
 	if err != nil {
 		return nil, err
 	}
 	applyExclusions(doc, opts.ExcludeSelector)
-	if opts.DownloadAssets && !opts.DryRun {
-		if err := output.Download(doc, opts.URL, opts.OutputDir, opts.UserAgent); err != nil && !opts.Stdout {
-			fmt.Printf("Warning: asset processing failed: %v\n", err)
-		}
-	}
+	textnorm.Document(doc, textNormOptions(opts))
+	downloadAssets(ctx, opts, doc)
 	return doc, nil
 }
 
-func (p *pipeline) renderSections(sections []parse.Section) (string, []sectionMarkdown, error) {
-	return buildMarkdown(p.conv, sections)
+// downloadAssets downloads the images of doc with --download-assets and
+// points them at the run's asset store.
+func downloadAssets(ctx context.Context, opts Options, doc *goquery.Document) {
+	if !opts.DownloadAssets || opts.DryRun {
+		return
+	}
+	var err error
+	if opts.assets != nil {
+		err = opts.assets.DownloadContext(ctx, doc, opts.URL, assetRefDir(opts), opts.UserAgent)
+	} else {
+		err = output.DownloadContext(ctx, doc, opts.URL, opts.OutputDir, opts.UserAgent)
+	}
+	if err != nil {
+		warnAssetDownload(ctx, opts.URL, err)
+	}
+}
+
+func (p *pipeline) renderSections(ctx context.Context, sections []parse.Section) ([]sectionMarkdown, error) {
+	return buildMarkdownConcurrent(ctx, p.converters, sections, p.renderWorkers)
+}
+
+// renderWorkers resolves --render-concurrency: 0 means GOMAXPROCS, and
+// larger values are capped at GOMAXPROCS since conversion is CPU-bound.
+func renderWorkers(concurrency int) int {
+	limit := runtime.GOMAXPROCS(0)
+	if concurrency <= 0 || concurrency > limit {
+		return limit
+	}
+	return concurrency
 }
 
 func (p *pipeline) writeOutputs(ctx context.Context, opts Options, baseDoc *goquery.Document, result analysisResult) error {
-	if err := p.runBeforeRenderHooks(ctx, opts, result.Doc, &result.Rep); err != nil {
+	if p.streamable(opts) {
+		_, err := p.streamOutputs(ctx, opts, baseDoc, result)
 		return err
 	}
-
-	md, sectionMarkdowns, err := p.renderSections(result.Doc.Sections)
+	md, sectionMarkdowns, rendered, err := p.render(ctx, opts, &result)
 	if err != nil {
 		return err
 	}
 
-	rendered := Rendered{
-		Markdown: md,
-		Sections: toRenderedSections(sectionMarkdowns),
+	writeRes, err := writeOutputsWithMarkdown(ctx, opts, baseDoc, result, md, sectionMarkdowns)
+	if err != nil {
+		return err
 	}
-	if err := p.runAfterRenderHooks(ctx, opts, result.Doc, &result.Rep, &rendered); err != nil {
+	if err := ctx.Err(); err != nil {
 		return err
 	}
-	md, sectionMarkdowns = fromRendered(rendered)
+	return p.runAfterWriteHooks(ctx, opts, result.Doc, &result.Rep, rendered, writeRes)
+}
 
-	writeRes, err := writeOutputsWithMarkdown(opts, baseDoc, result, md, sectionMarkdowns)
+// render converts result's sections to Markdown and runs the render hooks.
+// md is the joined page Markdown when a hook produced it, and "" otherwise.
+func (p *pipeline) render(ctx context.Context, opts Options, result *analysisResult) (string, []sectionMarkdown, Rendered, error) {
+	if err := p.runBeforeRenderHooks(ctx, opts, result.Doc, &result.Rep); err != nil {
+		return "", nil, Rendered{}, err
+	}
+
+	sectionMarkdowns, err := p.renderSections(ctx, result.Doc.Sections)
 	if err != nil {
-		return err
+		return "", nil, Rendered{}, err
 	}
-	return p.runAfterWriteHooks(ctx, opts, result.Doc, &result.Rep, rendered, writeRes)
+
+	if opts.Citation == CitationSection {
+		citeSections(opts, result.Doc.Sections, sectionMarkdowns)
+	}
+
+	// Only hooks see the page as one string; otherwise sections are written
+	// from the slice so large pages don't need a second copy in memory.
+	var md string
+	rendered := Rendered{Sections: toRenderedSections(sectionMarkdowns)}
+	if len(p.hooks) > 0 {
+		rendered.Markdown = joinMarkdown(sectionMarkdowns)
+		if err := p.runAfterRenderHooks(ctx, opts, result.Doc, &result.Rep, &rendered); err != nil {
+			return "", nil, Rendered{}, err
+		}
+		md, sectionMarkdowns = fromRendered(rendered)
+	}
+	return md, sectionMarkdowns, rendered, nil
 }
 
 type crawlPageSummary struct {
@@ -111,8 +387,24 @@ type crawlPageSummary struct {
 	OutputDir    string
 	Skipped      bool
 	SkipReason   string
+	SkipCategory crawler.SkipCategory
 	Processed    bool
 	ProcessError error
+	// Class is the soft-404/login-wall/js-required classification, if any.
+	Class pageclass.Result
+	// Dates are the page's publication and update dates, once parsed.
+	Dates dates.Page
+	// Authors are the page's author names, once parsed.
+	Authors []string
+	// Title is the page's <title> or first h1, once parsed.
+	Title string
+	// BrokenAnchors and EmptySections count the issues of the page's
+	// completeness report, once analyzed.
+	BrokenAnchors int
+	EmptySections int
+	// Staged is set when the page was written to the staging directory to be
+	// named from its title.
+	Staged bool
 }
 
 func (p *pipeline) processCrawlPage(ctx context.Context, opts Options, pageURL string, result *crawler.Result, pagesDir string) crawlPageSummary {
@@ -120,6 +412,10 @@ func (p *pipeline) processCrawlPage(ctx context.Context, opts Options, pageURL s
 	if result == nil || result.Error != nil || result.HTML == "" {
 		summary.Skipped = true
 		summary.SkipReason = "empty or errored result"
+		summary.SkipCategory = crawler.SkipEmpty
+		if result != nil && result.Error != nil {
+			summary.SkipCategory = crawler.ErrorCategory(result.Error)
+		}
 		return summary
 	}
 
@@ -127,36 +423,194 @@ func (p *pipeline) processCrawlPage(ctx context.Context, opts Options, pageURL s
 	if err != nil {
 		summary.Skipped = true
 		summary.SkipReason = err.Error()
+		summary.SkipCategory = crawler.SkipError
 		return summary
 	}
+	pageOpts := opts
+	if opts.PageNames == PageNamesTitle {
+		pageDir = stagingPageDir(pagesDir, pageURL)
+		_ = os.RemoveAll(pageDir)
+		summary.Staged = true
+		// Staged pages link shared assets as if already placed under
+		// pages/, where a titled page ends up.
+		pageOpts.assetRefDir = filepath.Join(pagesDir, stagingDirName)
+	}
 	summary.OutputDir = pageDir
 
-	pageOpts := opts
 	pageOpts.URL = pageURL
 	pageOpts.OutputDir = pageDir
+	pageOpts.fetchedAt = result.FetchedAt
+	page, ok := p.extractCrawlPage(ctx, opts, pageOpts, result.HTML, &summary)
+	if !ok {
+		return summary
+	}
+	if err := ctx.Err(); err != nil {
+		summary.ProcessError = err
+		return summary
+	}
+
+	if err := p.writeOutputs(ctx, page.Opts, page.BaseDoc, page.Analysis); err != nil {
+		summary.ProcessError = err
+		return summary
+	}
+
+	summary.Processed = true
+	return summary
+}
 
-	baseDoc, err := p.prepareDocument(ctx, pageOpts, result.HTML)
+// extractedPage is a crawled page analyzed and ready to render.
+type extractedPage struct {
+	// Opts are the page's options after preset detection.
+	Opts     Options
+	BaseDoc  *goquery.Document
+	Analysis analysisResult
+}
+
+// extractCrawlPage classifies, parses and filters one crawled page. When the
+// page is skipped or fails it records why in summary and returns false.
+func (p *pipeline) extractCrawlPage(ctx context.Context, opts, pageOpts Options, html string, summary *crawlPageSummary) (extractedPage, bool) {
+	html, class := classifyCrawlPage(ctx, opts, pageOpts, html)
+	summary.Class = class
+	if class.Class != "" && opts.SoftPages != SoftPagesKeep {
+		summary.Skipped = true
+		summary.SkipReason = class.Class + ": " + class.Reason
+		summary.SkipCategory = crawler.SkipFiltered
+		return extractedPage{}, false
+	}
+	pageOpts, _ = detectPreset(pageOpts, html)
+
+	baseDoc, err := p.prepareDocument(ctx, pageOpts, html)
 	if err != nil {
 		summary.Skipped = true
 		summary.SkipReason = err.Error()
-		return summary
+		summary.SkipCategory = crawler.SkipError
+		return extractedPage{}, false
 	}
 
 	analysis, err := p.analyze(ctx, pageOpts, baseDoc, false)
 	if err != nil {
 		summary.ProcessError = err
-		return summary
+		return extractedPage{}, false
+	}
+	summary.Dates = analysis.Doc.Dates
+	summary.Authors = analysis.Doc.Byline.AuthorNames()
+	summary.Title = analysis.Doc.Title
+	if reason := dateSkipReason(analysis); reason != "" {
+		summary.Skipped = true
+		summary.SkipReason = reason
+		summary.SkipCategory = crawler.SkipFiltered
+		return extractedPage{}, false
+	}
+	if reason, category := pageLengthSkipReason(opts, analysis.Doc); reason != "" {
+		summary.Skipped = true
+		summary.SkipReason = reason
+		summary.SkipCategory = category
+		return extractedPage{}, false
 	}
 	analysis.Trim(opts.MaxSections)
 	summary.Sections = analysis.SectionsCount()
+	summary.BrokenAnchors = len(analysis.Rep.BrokenAnchors)
+	summary.EmptySections = len(analysis.Rep.EmptySections)
+	return extractedPage{Opts: pageOpts, BaseDoc: baseDoc, Analysis: analysis}, true
+}
 
-	if err := p.writeOutputs(ctx, pageOpts, baseDoc, analysis); err != nil {
-		summary.ProcessError = err
+// processCrawlPageIsolated runs processCrawlPage under opts.PageTimeout and
+// turns a panic into a ProcessError, so one pathological page cannot hang or
+// crash the whole crawl. A timed-out page stops before its next stage or
+// write; it is waited for up to pageAbandonGrace, then abandoned.
+func (p *pipeline) processCrawlPageIsolated(ctx context.Context, opts Options, pageURL string, result *crawler.Result, pagesDir string) crawlPageSummary {
+	return isolatePage(ctx, opts.PageTimeout, pageURL, func(ctx context.Context) crawlPageSummary {
+		return p.processCrawlPage(ctx, opts, pageURL, result, pagesDir)
+	})
+}
+
+// pageAbandonGrace is how long a timed-out page is given to reach its next
+// context check. A page stuck in code that never checks (a deeply nested
+// DOM in goquery, say) is abandoned after it; its writes still check the
+// canceled context, so it cannot write once it resumes.
+var pageAbandonGrace = 2 * time.Second
+
+func isolatePage(ctx context.Context, timeout time.Duration, pageURL string, process func(context.Context) crawlPageSummary) crawlPageSummary {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	done := make(chan crawlPageSummary, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- crawlPageSummary{URL: pageURL, ProcessError: fmt.Errorf("panic while processing page: %v", r)}
+			}
+		}()
+		done <- process(ctx)
+	}()
+	select {
+	case summary := <-done:
 		return summary
+	case <-ctx.Done():
+		// Give the page a moment to stop at its next check, so it has
+		// usually finished before the failure is reported.
+		grace := time.NewTimer(pageAbandonGrace)
+		defer grace.Stop()
+		select {
+		case <-done:
+		case <-grace.C:
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return crawlPageSummary{URL: pageURL, ProcessError: fmt.Errorf("processing timed out after %s", timeout)}
+		}
+		return crawlPageSummary{URL: pageURL, ProcessError: ctx.Err()}
 	}
+}
 
-	summary.Processed = true
-	return summary
+// classifyCrawlPage classifies a crawled page and, with --soft-pages
+// retry-dynamic, re-fetches a flagged page with a browser. It returns the
+// HTML to process and the final classification.
+func classifyCrawlPage(ctx context.Context, opts, pageOpts Options, html string) (string, pageclass.Result) {
+	class := classifyHTML(html)
+	if class.Class == "" || opts.SoftPages != SoftPagesRetry {
+		return html, class
+	}
+	rendered, err := refetchDynamic(ctx, pageOpts)
+	if err != nil {
+		class.Reason += fmt.Sprintf(" (dynamic retry failed: %v)", err)
+		return html, class
+	}
+	retried := classifyHTML(rendered)
+	if retried.Class != "" {
+		retried.Reason += " (after dynamic retry)"
+	}
+	return rendered, retried
+}
+
+func classifyHTML(html string) pageclass.Result {
+	doc, err := parse.NewDocument(html)
+	if err != nil {
+		return pageclass.Result{}
+	}
+	return pageclass.Classify(doc)
+}
+
+// pageLengthSkipReason flags pages whose extracted text falls outside
+// --min-page-chars/--max-page-chars, such as login walls and redirect stubs:
+// too short counts as empty, too long as filtered.
+func pageLengthSkipReason(opts Options, doc *parse.Document) (string, crawler.SkipCategory) {
+	if opts.MinPageChars <= 0 && opts.MaxPageChars <= 0 {
+		return "", ""
+	}
+	chars := 0
+	for _, s := range doc.Sections {
+		chars += utf8.RuneCountInString(strings.TrimSpace(s.HeadingText))
+		chars += utf8.RuneCountInString(strings.TrimSpace(s.ContentText))
+	}
+	if opts.MinPageChars > 0 && chars < opts.MinPageChars {
+		return fmt.Sprintf("content too short (%d chars < --min-page-chars %d)", chars, opts.MinPageChars), crawler.SkipEmpty
+	}
+	if opts.MaxPageChars > 0 && chars > opts.MaxPageChars {
+		return fmt.Sprintf("content too long (%d chars > --max-page-chars %d)", chars, opts.MaxPageChars), crawler.SkipFiltered
+	}
+	return "", ""
 }
 
 func (p *pipeline) summarize(opts Options, sourceInfo string, result analysisResult) {
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go_scrap/internal/fetch"
+	"go_scrap/internal/policy"
+)
+
+// The limits crawler.New applies when a crawl sets none.
+const (
+	crawlerDefaultRateLimit = 1.0
+	crawlerDefaultMaxPages  = 100
+)
+
+// applyPolicy checks a run against opts.Policy (loading the system policy
+// when none is given) before anything is fetched or written, then installs
+// the policy as the outermost fetch middleware so redirects and responses
+// are checked too.
+func applyPolicy(opts Options) (Options, error) {
+	if opts.Policy == nil {
+		p, err := policy.Find()
+		if err != nil {
+			return opts, err
+		}
+		if p == nil {
+			return opts, nil
+		}
+		opts.Policy = p
+	}
+	run := policy.Run{
+		URLs:      append([]string{opts.URL, opts.SitemapURL}, opts.URLs...),
+		Crawl:     opts.Crawl,
+		MaxPages:  opts.MaxPages,
+		RateLimit: opts.RateLimitPerSecond,
+		OutputDir: opts.OutputDir,
+	}
+	if opts.Crawl && run.RateLimit <= 0 {
+		run.RateLimit = crawlerDefaultRateLimit
+	}
+	if opts.Crawl && run.MaxPages <= 0 {
+		run.MaxPages = crawlerDefaultMaxPages
+	}
+	if err := opts.Policy.Check(run); err != nil {
+		return opts, err
+	}
+	opts.Middleware = append([]fetch.Middleware{policyMiddleware(opts.Policy)}, opts.Middleware...)
+	return opts, nil
+}
+
+// policyMiddleware rejects requests to denied domains, including redirect
+// targets, and responses with a denied content type. Browser fetches are
+// only checked before navigating.
+func policyMiddleware(p *policy.Policy) fetch.Middleware {
+	deny := func(format string, args ...any) error {
+		return &policy.Error{Source: p.Source, Violations: []string{fmt.Sprintf(format, args...)}}
+	}
+	return fetch.Middleware{
+		Name: "policy",
+		RoundTrip: func(next http.RoundTripper) http.RoundTripper {
+			return fetch.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				if !p.AllowsHost(req.URL.Hostname()) {
+					return nil, deny("domain of %s is denied", req.URL.Redacted())
+				}
+				resp, err := next.RoundTrip(req)
+				if err != nil {
+					return resp, err
+				}
+				if ct := resp.Header.Get("Content-Type"); !p.AllowsContentType(ct) {
+					_ = resp.Body.Close()
+					return nil, deny("content type %s of %s is denied", ct, req.URL.Redacted())
+				}
+				return resp, nil
+			})
+		},
+		BeforeNavigate: func(_ context.Context, pageURL string, _ map[string]string) error {
+			if !p.AllowsURL(pageURL) {
+				return deny("domain of %s is denied", pageURL)
+			}
+			return nil
+		},
+	}
+}
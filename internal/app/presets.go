@@ -0,0 +1,107 @@
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"go_scrap/internal/fetch"
+	"go_scrap/internal/parse"
+	"go_scrap/internal/presets"
+)
+
+// resolvePreset applies a named preset; "auto" is resolved after fetching,
+// against the presets loaded here.
+func resolvePreset(opts Options) (Options, error) {
+	name := strings.ToLower(strings.TrimSpace(opts.Preset))
+	opts.Preset = name
+	if name == "" {
+		return opts, nil
+	}
+	all, err := loadPresets(opts)
+	if name == presets.Auto {
+		if err != nil {
+			all = presets.Builtin()
+		}
+		opts.autoPresets = all
+		return opts, nil
+	}
+	if err != nil {
+		return opts, err
+	}
+	p, ok := presets.Find(all, name)
+	if !ok {
+		return opts, fmt.Errorf("unknown preset %q (available: %s, %s)", name, presets.Auto, strings.Join(presets.Names(all), ", "))
+	}
+	return applyPreset(opts, p), nil
+}
+
+// loadPresets reads user presets from <ConfigDir>/presets when --config-dir
+// is set.
+func loadPresets(opts Options) ([]presets.Preset, error) {
+	if opts.ConfigDir != "" {
+		return presets.LoadDir(filepath.Join(opts.ConfigDir, "presets"))
+	}
+	return presets.LoadAll()
+}
+
+// applyPreset fills in settings the user left unset; explicit flags and
+// config values always win.
+func applyPreset(opts Options, p presets.Preset) Options {
+	if p.Mode != "" && opts.Mode == "" {
+		opts.Mode = fetch.Mode(p.Mode)
+	}
+	if opts.WaitFor == "" {
+		opts.WaitFor = p.WaitFor
+	}
+	if opts.NavSelector == "" {
+		opts.NavSelector = p.NavSelector
+	}
+	if opts.ContentSelector == "" {
+		opts.ContentSelector = p.ContentSelector
+	}
+	if opts.RateLimitPerSecond == 0 {
+		opts.RateLimitPerSecond = p.RateLimitPerSecond
+	}
+	if headers := p.ExpandedAuthHeaders(); len(headers) > 0 {
+		merged := make(map[string]string, len(headers)+len(opts.AuthHeaders))
+		for k, v := range headers {
+			merged[k] = v
+		}
+		for k, v := range opts.AuthHeaders {
+			merged[k] = v
+		}
+		opts.AuthHeaders = merged
+	}
+	if opts.ExcludeSelector == "" {
+		opts.ExcludeSelector = p.ExcludeSelector
+	} else if p.ExcludeSelector != "" {
+		opts.ExcludeSelector += ", " + p.ExcludeSelector
+	}
+	return opts
+}
+
+// detectPreset applies the preset matching html when opts.Preset is "auto".
+// It returns the detected preset name, or "" when nothing matched. The page
+// is already fetched by then, so only the preset's selectors and exclusions
+// apply; its mode, wait-for, rate limit and auth headers need the preset
+// named explicitly.
+func detectPreset(opts Options, html string) (Options, string) {
+	if opts.Preset != presets.Auto {
+		return opts, ""
+	}
+	doc, err := parse.NewDocument(html)
+	if err != nil {
+		return opts, ""
+	}
+	all := opts.autoPresets
+	if all == nil {
+		all = presets.Builtin()
+	}
+	p, ok := presets.Detect(doc, all)
+	if !ok {
+		return opts, ""
+	}
+	selectors := presets.Preset{NavSelector: p.NavSelector, ContentSelector: p.ContentSelector, ExcludeSelector: p.ExcludeSelector}
+	return applyPreset(opts, selectors), p.Name
+}
@@ -0,0 +1,189 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"go_scrap/internal/fsutil"
+)
+
+// publication is a run writing into a release directory that replaces
+// opts.PublishDir only once the run succeeded, so readers of PublishDir see
+// either the previous output or the new one, never a partial update.
+type publication struct {
+	dir     string
+	release string
+	stdout  bool
+}
+
+// releasesDir holds the releases of publishDir, beside it.
+func releasesDir(publishDir string) string {
+	return filepath.Join(filepath.Dir(publishDir), "."+filepath.Base(publishDir)+".releases")
+}
+
+// startPublish points opts.OutputDir at a new release of opts.PublishDir,
+// seeded with a copy of the published output so resumes, run diffs and
+// incremental outputs see the previous run. It returns nil without
+// PublishDir, and for dry runs, which write nothing.
+func startPublish(opts *Options) (*publication, error) {
+	if opts.PublishDir == "" || opts.DryRun {
+		return nil, nil
+	}
+	dir := filepath.Clean(opts.PublishDir)
+	p := &publication{dir: dir, release: filepath.Join(releasesDir(dir), opts.RunID), stdout: opts.Stdout}
+	if err := fsutil.MkdirAll(releasesDir(dir), 0755); err != nil {
+		return nil, fmt.Errorf("publish-dir: %w", err)
+	}
+	if _, err := os.Stat(dir); err == nil {
+		if err := copyTree(dir, p.release); err != nil {
+			_ = os.RemoveAll(p.release)
+			return nil, fmt.Errorf("publish-dir: copy %s: %w", dir, err)
+		}
+	} else if err := fsutil.MkdirAll(p.release, 0755); err != nil {
+		return nil, fmt.Errorf("publish-dir: %w", err)
+	}
+	opts.OutputDir = p.release
+	return p, nil
+}
+
+// finish publishes the release when the run succeeded and removes it
+// otherwise, returning the run's error or the publishing one.
+func (p *publication) finish(runErr error) error {
+	if p == nil {
+		return runErr
+	}
+	if runErr != nil {
+		_ = os.RemoveAll(p.release)
+		return runErr
+	}
+	previous, err := p.swap()
+	if err != nil {
+		return fmt.Errorf("publish %s: %w", p.dir, err)
+	}
+	p.prune(previous)
+	if !p.stdout {
+		fmt.Printf("Published %s\n", p.dir)
+	}
+	return nil
+}
+
+// swap makes dir a symlink to the release, replacing the previous link in a
+// single rename, and returns the release it pointed at. A plain directory at
+// dir, from before --publish-dir or from a system without symlinks, is moved
+// into the releases first; where symlinks cannot be created (Windows without
+// the privilege), the release itself is renamed into place.
+func (p *publication) swap() (string, error) {
+	previous := ""
+	info, err := os.Lstat(p.dir)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return "", err
+	case info.Mode()&fs.ModeSymlink != 0:
+		if target, err := os.Readlink(p.dir); err == nil {
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(p.dir), target)
+			}
+			previous = filepath.Clean(target)
+		}
+	default:
+		previous = filepath.Join(releasesDir(p.dir), "retired-"+filepath.Base(p.release))
+		if err := fsutil.Rename(p.dir, previous); err != nil {
+			return "", err
+		}
+	}
+
+	target, err := filepath.Rel(filepath.Dir(p.dir), p.release)
+	if err != nil {
+		target = p.release
+	}
+	tmp := p.dir + ".publishing"
+	_ = os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err == nil {
+		err := fsutil.Rename(tmp, p.dir)
+		if err != nil && previous != "" {
+			// Windows does not rename over a directory link.
+			if rerr := os.Remove(p.dir); rerr == nil {
+				err = fsutil.Rename(tmp, p.dir)
+			}
+		}
+		if err != nil {
+			_ = os.Remove(tmp)
+			return "", err
+		}
+		return previous, nil
+	}
+	if err := fsutil.Rename(p.release, p.dir); err != nil {
+		return "", err
+	}
+	return previous, nil
+}
+
+// prune removes the releases other than the published one and the one
+// before it, which readers that resolved the old link may still be reading.
+func (p *publication) prune(previous string) {
+	dir := releasesDir(p.dir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		if path == p.release || path == previous {
+			continue
+		}
+		_ = os.RemoveAll(path)
+	}
+}
+
+// copyTree copies the regular files and directories under src to dst.
+func copyTree(src, dst string) error {
+	root, err := filepath.EvalSymlinks(src)
+	if err != nil {
+		return err
+	}
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case d.IsDir():
+			return fsutil.MkdirAll(target, 0755)
+		case d.Type().IsRegular():
+			return copyFile(path, target)
+		}
+		return nil
+	})
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := fsutil.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
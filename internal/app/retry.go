@@ -0,0 +1,380 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go_scrap/internal/crawler"
+	"go_scrap/internal/fetch"
+	"go_scrap/internal/fsutil"
+	"go_scrap/internal/output"
+	"go_scrap/internal/warnings"
+)
+
+// RetryFailed re-fetches the pages of a previous crawl in opts.OutputDir
+// whose crawl-index entry has status "error", processes them as the crawl
+// would have, and merges them back into the crawl index, merged index,
+// corpus and index.html. Pages are fetched with opts.Mode, so "dynamic"
+// renders every failed page in a browser. The crawl's base URL is used when
+// opts.URL is empty.
+//
+// With opts.Repair it also re-fetches the pages whose completeness report
+// found broken anchors or empty sections, worst first and at most
+// opts.MaxPages of them, and writes repair.json comparing each page before
+// and after.
+func RetryFailed(ctx context.Context, opts Options) error {
+	if opts.OutputDir == "" {
+		opts.OutputDir = opts.PublishDir
+	}
+	if opts.OutputDir == "" && opts.URL == "" {
+		return errors.New("output directory of the crawl is required (--output-dir)")
+	}
+	if opts.OutputDir == "" {
+		opts.OutputDir = filepath.Join(DefaultOutputRoot, hostFromURL(opts.URL))
+	}
+	index, err := output.ReadCrawlIndex(opts.OutputDir)
+	if errors.Is(err, os.ErrNotExist) {
+		if opts.Repair {
+			return fmt.Errorf("%s has no crawl-index.json; --repair needs a previous crawl", opts.OutputDir)
+		}
+		return fmt.Errorf("%s has no crawl-index.json; retry-failed needs a previous crawl", opts.OutputDir)
+	}
+	if err != nil {
+		return fmt.Errorf("read crawl index: %w", err)
+	}
+	if opts.URL == "" {
+		opts.URL = index.BaseURL
+	}
+	opts.Crawl = true
+	opts.Resume = false
+	return run(ctx, opts, func(ctx context.Context, opts Options) error {
+		return retryFailedPages(ctx, opts, index)
+	})
+}
+
+// failedPages returns the URLs of the index's pages with status "error".
+func failedPages(index crawler.CrawlIndex) []string {
+	var urls []string
+	for _, page := range index.Pages {
+		if page.Status == "error" {
+			urls = append(urls, page.URL)
+		}
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// repairCandidates returns the URLs of the index's pages that failed or have
+// completeness issues: failed pages first, then by number of issues, at most
+// limit of them (0 = all).
+func repairCandidates(index crawler.CrawlIndex, limit int) []string {
+	var pages []crawler.PageEntry
+	for _, page := range index.Pages {
+		if page.Status == "error" || pageIssues(page) > 0 {
+			pages = append(pages, page)
+		}
+	}
+	sort.SliceStable(pages, func(i, j int) bool {
+		ei, ej := pages[i].Status == "error", pages[j].Status == "error"
+		if ei != ej {
+			return ei
+		}
+		if ni, nj := pageIssues(pages[i]), pageIssues(pages[j]); ni != nj {
+			return ni > nj
+		}
+		return pages[i].URL < pages[j].URL
+	})
+	if limit > 0 && len(pages) > limit {
+		pages = pages[:limit]
+	}
+	urls := make([]string, len(pages))
+	for i, page := range pages {
+		urls[i] = page.URL
+	}
+	return urls
+}
+
+func pageIssues(page crawler.PageEntry) int {
+	return page.BrokenAnchors + page.EmptySections
+}
+
+func retryFailedPages(ctx context.Context, opts Options, index crawler.CrawlIndex) error {
+	urls := failedPages(index)
+	if opts.Repair {
+		urls = repairCandidates(index, opts.MaxPages)
+	}
+	if len(urls) == 0 {
+		if !opts.Stdout {
+			if opts.Repair {
+				fmt.Printf("No failed or incomplete pages in %s\n", opts.OutputDir)
+			} else {
+				fmt.Printf("No failed pages in %s\n", opts.OutputDir)
+			}
+		}
+		return nil
+	}
+	p, err := newPipeline(opts)
+	if err != nil {
+		return err
+	}
+	if err := p.runBeforeFetchHooks(ctx, &opts); err != nil {
+		return err
+	}
+	if !opts.Stdout {
+		if opts.Repair {
+			fmt.Printf("Repairing %d pages from %s (mode %s)\n", len(urls), opts.OutputDir, opts.Mode)
+		} else {
+			fmt.Printf("Retrying %d failed pages from %s (mode %s)\n", len(urls), opts.OutputDir, opts.Mode)
+		}
+	}
+
+	stats := crawler.Stats{StartedAt: time.Now()}
+	results := make(map[string]*crawler.Result, len(urls))
+	for _, pageURL := range urls {
+		r := refetchFailedPage(ctx, opts, pageURL)
+		if r.Error != nil {
+			stats.PagesFailed++
+			stats.Errors = append(stats.Errors, fmt.Sprintf("%s: %v", pageURL, r.Error))
+		} else {
+			stats.PagesCrawled++
+		}
+		results[pageURL] = r
+	}
+	stats.CompletedAt = time.Now()
+	if !p.shouldWrite(opts) {
+		return nil
+	}
+
+	pagesDir := filepath.Join(opts.OutputDir, "pages")
+	opts.assets = openAssets(opts)
+	var sections []output.PageSectionCount
+	namer := newPageNamer(opts, pagesDir, index.Pages)
+	if namer != nil {
+		defer namer.cleanup()
+	}
+	process := func(ctx context.Context, worker *pipeline, pageURL string) *crawlPageOutcome {
+		return worker.crawlPageOutcome(ctx, opts, pageURL, results[pageURL], pagesDir, nil)
+	}
+	err = processCrawlPages(ctx, p, opts, urls, process, func(outcome *crawlPageOutcome) error {
+		if outcome.err != nil {
+			return outcome.err
+		}
+		if err := outcome.place(namer, pagesDir, opts.assets); err != nil {
+			return err
+		}
+		_, _ = outcome.progress.WriteTo(opts.stdout())
+		for _, w := range outcome.warnings.List() {
+			warnings.Report(ctx, w)
+		}
+		if outcome.section != nil {
+			sections = append(sections, *outcome.section)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	writeAssetIndex(ctx, opts)
+
+	retried := output.BuildCrawlIndex(results, stats, index.BaseURL, sections)
+	retried.Warnings = warnings.From(ctx).List()
+	// The retry's errors replace those recorded for the pages it retried.
+	index.Errors = errorsExcept(index.Errors, urls)
+	before := index.Pages
+	index.Pages = replaceRetried(index.Pages, retried.Pages)
+	retriedPages := retried.Pages
+	retried.Pages = nil
+	merged := output.MergeCrawlIndexes(index.BaseURL, []crawler.CrawlIndex{index, retried})
+	merged.RunID = opts.RunID
+	if err := output.WriteShardedCrawlIndex(opts.OutputDir, merged, opts.CrawlShardSize, opts.Stdout); err != nil {
+		return fmt.Errorf("write crawl index: %w", err)
+	}
+	if !opts.Stdout {
+		pageDirs := successfulPageDirs(merged, opts.OutputDir)
+		if err := writeMergedIndexes(opts.OutputDir, pageDirs); err != nil {
+			return fmt.Errorf("write merged index: %w", err)
+		}
+		writeCrawlBrowseHTML(ctx, opts.OutputDir, index.BaseURL, pageDirs)
+		if opts.Repair {
+			summary := buildRepairSummary(opts.RunID, before, retriedPages)
+			if err := writeRepairSummary(opts.OutputDir, summary); err != nil {
+				warnOutputWrite(ctx, "repair.json", err)
+			}
+			fmt.Printf("Repaired %d pages: %d fixed, %d improved, %d unchanged, %d worse, %d failed (see repair.json)\n",
+				summary.Pages, summary.Fixed, summary.Improved, summary.Unchanged, summary.Worse, summary.Failed)
+			return nil
+		}
+		stillFailing := len(failedPages(merged))
+		fmt.Printf("Retried %d pages: %d recovered, %d still failing\n", len(urls), len(urls)-stillFailing, stillFailing)
+	}
+	return nil
+}
+
+// replaceRetried replaces the entries of pages with their retried ones. A
+// page that was written before keeps its entry and output when the retry
+// failed or skipped it.
+func replaceRetried(pages, retried []crawler.PageEntry) []crawler.PageEntry {
+	byURL := make(map[string]crawler.PageEntry, len(retried))
+	for _, page := range retried {
+		byURL[page.URL] = page
+	}
+	out := make([]crawler.PageEntry, len(pages))
+	for i, page := range pages {
+		out[i] = page
+		if r, ok := byURL[page.URL]; ok && (page.Status != "success" || r.Status == "success") {
+			out[i] = r
+		}
+	}
+	return out
+}
+
+// repairState is a page's crawl-index status and completeness issues.
+type repairState struct {
+	Status        string `json:"status"`
+	Error         string `json:"error,omitempty"`
+	BrokenAnchors int    `json:"broken_anchors"`
+	EmptySections int    `json:"empty_sections"`
+}
+
+// repairPage compares a repaired page before and after. Outcome is "fixed"
+// (no issues left), "improved", "unchanged", "worse" or "failed" (the retry
+// failed or was skipped; a written page keeps its previous output).
+type repairPage struct {
+	URL     string      `json:"url"`
+	Before  repairState `json:"before"`
+	After   repairState `json:"after"`
+	Outcome string      `json:"outcome"`
+}
+
+// repairSummary is the content of repair.json.
+type repairSummary struct {
+	RunID     string       `json:"run_id,omitempty"`
+	Pages     int          `json:"pages"`
+	Fixed     int          `json:"fixed"`
+	Improved  int          `json:"improved"`
+	Unchanged int          `json:"unchanged"`
+	Worse     int          `json:"worse"`
+	Failed    int          `json:"failed"`
+	Results   []repairPage `json:"results"`
+}
+
+func buildRepairSummary(runID string, before, retried []crawler.PageEntry) repairSummary {
+	previous := make(map[string]crawler.PageEntry, len(before))
+	for _, page := range before {
+		previous[page.URL] = page
+	}
+	summary := repairSummary{RunID: runID, Results: []repairPage{}}
+	for _, after := range retried {
+		prev := previous[after.URL]
+		rp := repairPage{URL: after.URL, Before: newRepairState(prev), After: newRepairState(after)}
+		switch {
+		case after.Status != "success":
+			rp.Outcome = "failed"
+			summary.Failed++
+		case pageIssues(after) == 0:
+			rp.Outcome = "fixed"
+			summary.Fixed++
+		case prev.Status != "success" || pageIssues(after) < pageIssues(prev):
+			rp.Outcome = "improved"
+			summary.Improved++
+		case pageIssues(after) == pageIssues(prev):
+			rp.Outcome = "unchanged"
+			summary.Unchanged++
+		default:
+			rp.Outcome = "worse"
+			summary.Worse++
+		}
+		summary.Results = append(summary.Results, rp)
+	}
+	summary.Pages = len(summary.Results)
+	return summary
+}
+
+func newRepairState(page crawler.PageEntry) repairState {
+	msg := page.Error
+	if msg == "" {
+		msg = page.SkipReason
+	}
+	return repairState{Status: page.Status, Error: msg, BrokenAnchors: page.BrokenAnchors, EmptySections: page.EmptySections}
+}
+
+func writeRepairSummary(outDir string, summary repairSummary) error {
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFile(filepath.Join(outDir, "repair.json"), append(data, '\n'), 0600)
+}
+
+// refetchFailedPage fetches pageURL once, as the crawler would have, and
+// returns it as a crawl result.
+func refetchFailedPage(ctx context.Context, opts Options, pageURL string) *crawler.Result {
+	pageOpts := opts
+	pageOpts.URL = pageURL
+	start := time.Now()
+	result, err := fetch.Fetch(ctx, buildFetchOptions(pageOpts, opts.Mode))
+	r := &crawler.Result{URL: pageURL, FetchedAt: time.Now()}
+	if err != nil {
+		r.Error = err
+		return r
+	}
+	r.HTML = result.HTML
+	r.ContentHash = crawler.HashHTML(result.HTML)
+	r.Provenance = crawler.Provenance{HTTPStatus: result.Status, DurationMS: time.Since(start).Milliseconds()}
+	headers := map[string]string{}
+	if result.ETag != "" {
+		headers["ETag"] = result.ETag
+	}
+	if result.LastModified != "" {
+		headers["Last-Modified"] = result.LastModified
+	}
+	if len(headers) > 0 {
+		r.Provenance.Headers = headers
+	}
+	return r
+}
+
+// successfulPageDirs maps each successful page of index to its directory
+// under the crawl's rootDir, when that exists.
+func successfulPageDirs(index crawler.CrawlIndex, rootDir string) map[string]string {
+	pageDirs := map[string]string{}
+	for _, page := range index.Pages {
+		if page.Status != "success" {
+			continue
+		}
+		pageDir, err := pageOutputDir(page, rootDir)
+		if err != nil {
+			continue
+		}
+		if _, err := os.Stat(pageDir); err == nil {
+			pageDirs[page.URL] = pageDir
+		}
+	}
+	return pageDirs
+}
+
+// errorsExcept drops the crawl errors ("<url>: <error>") of urls.
+func errorsExcept(errs []string, urls []string) []string {
+	var kept []string
+	for _, e := range errs {
+		retried := false
+		for _, u := range urls {
+			if strings.HasPrefix(e, u+": ") {
+				retried = true
+				break
+			}
+		}
+		if !retried {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
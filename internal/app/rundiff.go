@@ -0,0 +1,151 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"go_scrap/internal/crawler"
+	"go_scrap/internal/output"
+	"go_scrap/internal/parse"
+)
+
+// maxDiffLines caps how many added, removed or modified entries are listed
+// per kind before the confirm prompt.
+const maxDiffLines = 20
+
+// runDiff is how a run would change an existing output directory: pages in
+// crawl mode, sections for a single page.
+type runDiff struct {
+	Kind      string
+	Added     []string
+	Removed   []string
+	Modified  []string
+	Unchanged int
+}
+
+// diffFingerprints compares label -> hash maps of the previous and the
+// pending run.
+func diffFingerprints(kind string, prev, next map[string]string) runDiff {
+	d := runDiff{Kind: kind}
+	for label, hash := range next {
+		old, ok := prev[label]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, label)
+		case old != hash:
+			d.Modified = append(d.Modified, label)
+		default:
+			d.Unchanged++
+		}
+	}
+	for label := range prev {
+		if _, ok := next[label]; !ok {
+			d.Removed = append(d.Removed, label)
+		}
+	}
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	sort.Strings(d.Modified)
+	return d
+}
+
+func (d runDiff) changed() bool {
+	return len(d.Added) > 0 || len(d.Removed) > 0 || len(d.Modified) > 0
+}
+
+func (d runDiff) print(w io.Writer, outputDir string) {
+	if !d.changed() {
+		fmt.Fprintf(w, "\nNo changes to existing output in %s (%d %s unchanged).\n", outputDir, d.Unchanged, d.Kind)
+		return
+	}
+	fmt.Fprintf(w, "\nExisting output in %s would change:\n", outputDir)
+	fmt.Fprintf(w, "  %s: %d new, %d removed, %d modified, %d unchanged\n", d.Kind, len(d.Added), len(d.Removed), len(d.Modified), d.Unchanged)
+	printDiffLines(w, "+", d.Added)
+	printDiffLines(w, "-", d.Removed)
+	printDiffLines(w, "~", d.Modified)
+}
+
+func printDiffLines(w io.Writer, marker string, labels []string) {
+	for i, label := range labels {
+		if i == maxDiffLines {
+			fmt.Fprintf(w, "  %s ... and %d more\n", marker, len(labels)-i)
+			return
+		}
+		fmt.Fprintf(w, "  %s %s\n", marker, label)
+	}
+}
+
+// singleRunDiff compares a page's sections with the index.jsonl of a previous
+// run. ok is false when there is no previous run to compare with.
+func singleRunDiff(opts Options, doc *parse.Document) (runDiff, bool, error) {
+	prev, err := output.ReadSectionFingerprints(opts.OutputDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return runDiff{}, false, nil
+		}
+		return runDiff{}, false, err
+	}
+	sections := doc.Sections
+	if opts.MaxSections > 0 && opts.MaxSections < len(sections) {
+		sections = sections[:opts.MaxSections]
+	}
+	return diffFingerprints("sections", sectionFingerprintMap(prev), sectionFingerprintMap(output.SectionFingerprints(opts.URL, sections))), true, nil
+}
+
+// sectionFingerprintMap keys sections by heading path, falling back to the
+// index ID when a path repeats.
+func sectionFingerprintMap(fps []output.SectionFingerprint) map[string]string {
+	out := make(map[string]string, len(fps))
+	for _, fp := range fps {
+		label := fp.HeadingPath
+		if _, dup := out[label]; dup || label == "" {
+			label = fmt.Sprintf("%s [%s]", fp.HeadingPath, fp.ID)
+		}
+		out[label] = fp.Hash
+	}
+	return out
+}
+
+// crawlRunDiff compares fetched pages with the crawl index of a previous run
+// by content hash. ok is false when there is no previous crawl index.
+func crawlRunDiff(opts Options, results map[string]*crawler.Result) (runDiff, bool, error) {
+	index, err := output.ReadCrawlIndex(opts.OutputDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return runDiff{}, false, nil
+		}
+		return runDiff{}, false, err
+	}
+	prev := map[string]string{}
+	for _, page := range index.Pages {
+		if page.Status == "success" {
+			prev[page.URL] = page.ContentHash
+		}
+	}
+	next := map[string]string{}
+	for pageURL, result := range results {
+		if result != nil && result.Error == nil && result.HTML != "" {
+			next[pageURL] = result.ContentHash
+		}
+	}
+	return diffFingerprints("pages", prev, next), true, nil
+}
+
+// printRunDiff shows what a pending run would change before asking to
+// continue. Runs that will not prompt skip the comparison.
+func printRunDiff(opts Options, diff func() (runDiff, bool, error)) {
+	if opts.DryRun || opts.Yes || opts.Stdout {
+		return
+	}
+	d, ok, err := diff()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not compare with the previous run: %v\n", err)
+		return
+	}
+	if ok {
+		d.print(os.Stdout, opts.OutputDir)
+	}
+}
@@ -0,0 +1,133 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"go_scrap/internal/parse"
+	"go_scrap/internal/report"
+)
+
+type scrubRule struct {
+	Label string
+	Re    *regexp.Regexp
+}
+
+// builtinScrubRules are deliberately conservative: each pattern targets a
+// recognizable shape so ordinary prose, versions and dates survive.
+var builtinScrubRules = []scrubRule{
+	{Label: "email", Re: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)},
+	{Label: "phone", Re: regexp.MustCompile(`\+\d{1,3}[\s.-]?\(?\d{1,4}\)?(?:[\s.-]?\d{2,4}){2,4}|\(\d{3}\)\s?\d{3}[\s.-]\d{4}|\b\d{3}[.-]\d{3}[.-]\d{4}\b`)},
+	{Label: "token", Re: regexp.MustCompile(`\b(?:sk|pk|rk)_(?:live|test)_[A-Za-z0-9]{16,}\b` +
+		`|\bgh[pousr]_[A-Za-z0-9]{36,}\b` +
+		`|\bAKIA[0-9A-Z]{16}\b` +
+		`|\bxox[abprs]-[A-Za-z0-9-]{10,}\b` +
+		`|\bAIza[0-9A-Za-z_-]{35}\b` +
+		`|\beyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\b` +
+		`|(?i:bearer)\s+[A-Za-z0-9._~+/-]{20,}=*`)},
+}
+
+// scrubHook redacts PII and secrets from section HTML/text (and therefore
+// content.json and index.jsonl) before rendering, then from the rendered
+// Markdown, and prints how many values it replaced.
+type scrubHook struct {
+	HookBase
+	rules  []scrubRule
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func newScrubHook(opts Options) (Hook, error) {
+	rules := append([]scrubRule(nil), builtinScrubRules...)
+	for _, pattern := range opts.ScrubPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scrub pattern %q: %w", pattern, err)
+		}
+		rules = append(rules, scrubRule{Label: "custom", Re: re})
+	}
+	return &scrubHook{rules: rules, counts: map[string]int{}}, nil
+}
+
+func (*scrubHook) Name() string { return "scrub" }
+
+func (h *scrubHook) BeforeRender(_ context.Context, _ Options, doc *parse.Document, _ *report.Report) error {
+	if doc == nil {
+		return nil
+	}
+	for i := range doc.Sections {
+		sec := &doc.Sections[i]
+		sec.HeadingText = h.scrub(sec.HeadingText, true)
+		sec.HeadingHTML = h.scrub(sec.HeadingHTML, false)
+		sec.ContentHTML = h.scrub(sec.ContentHTML, false)
+		sec.ContentText = h.scrub(sec.ContentText, true)
+	}
+	return nil
+}
+
+func (h *scrubHook) AfterRender(_ context.Context, _ Options, _ *parse.Document, _ *report.Report, rendered *Rendered) error {
+	rendered.Markdown = h.scrub(rendered.Markdown, true)
+	for i := range rendered.Sections {
+		rendered.Sections[i].Markdown = h.scrub(rendered.Sections[i].Markdown, false)
+	}
+	return nil
+}
+
+func (h *scrubHook) AfterWrite(_ context.Context, opts Options, _ *parse.Document, _ *report.Report, _ Rendered, _ WriteResult) error {
+	h.mu.Lock()
+	counts := h.counts
+	h.counts = map[string]int{}
+	h.mu.Unlock()
+
+	w := opts.stdout()
+	if opts.Stdout {
+		w = os.Stderr
+	}
+	printScrubSummary(w, opts.URL, counts)
+	return nil
+}
+
+// scrub replaces every match with [REDACTED:<label>]. Only counted passes
+// add to the summary, so the same value seen in HTML and text is counted once.
+func (h *scrubHook) scrub(s string, count bool) string {
+	if s == "" {
+		return s
+	}
+	for _, rule := range h.rules {
+		replacement := "[REDACTED:" + rule.Label + "]"
+		n := 0
+		s = rule.Re.ReplaceAllStringFunc(s, func(string) string {
+			n++
+			return replacement
+		})
+		if count && n > 0 {
+			h.mu.Lock()
+			h.counts[rule.Label] += n
+			h.mu.Unlock()
+		}
+	}
+	return s
+}
+
+func printScrubSummary(w io.Writer, pageURL string, counts map[string]int) {
+	if len(counts) == 0 {
+		fmt.Fprintf(w, "Scrubbed %s: nothing redacted\n", pageURL)
+		return
+	}
+	labels := make([]string, 0, len(counts))
+	for label := range counts {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	parts := make([]string, 0, len(labels))
+	for _, label := range labels {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[label], label))
+	}
+	fmt.Fprintf(w, "Scrubbed %s: %s\n", pageURL, strings.Join(parts, ", "))
+}
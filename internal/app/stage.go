@@ -0,0 +1,173 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go_scrap/internal/fetch"
+	"go_scrap/internal/stage"
+	"go_scrap/internal/warnings"
+)
+
+// Fetch is the network half of a run: it fetches opts.URL, or crawls it,
+// and stages the raw HTML and metadata in opts.StageDir. Nothing is parsed
+// or written to the output directory; Transform does that.
+func Fetch(ctx context.Context, opts Options) error {
+	if err := checkStageOptions(opts); err != nil {
+		return err
+	}
+	normalized, err := prepareRun(ctx, opts)
+	if err != nil {
+		return err
+	}
+	ctx = warnings.WithCollector(ctx, warnings.New(os.Stderr))
+	if normalized.Crawl {
+		return fetchCrawl(ctx, normalized)
+	}
+	return fetchSingle(ctx, normalized)
+}
+
+// Transform is the offline half of a run: it parses, converts and writes the
+// pages staged in opts.StageDir by Fetch, exactly as Run would have after
+// fetching them. The staged URL and crawl mode replace those in opts.
+func Transform(ctx context.Context, opts Options) error {
+	if err := checkStageOptions(opts); err != nil {
+		return err
+	}
+	m, err := stage.Read(opts.StageDir)
+	if err != nil {
+		return err
+	}
+	opts.URL = m.URL
+	opts.SitemapURL = m.SitemapURL
+	opts.Crawl = m.Crawl
+	opts.UseCache = false
+	return run(ctx, opts, func(ctx context.Context, opts Options) error {
+		if opts.Crawl {
+			return transformCrawl(ctx, opts, m)
+		}
+		return transformSingle(ctx, opts, m)
+	})
+}
+
+func checkStageOptions(opts Options) error {
+	if strings.TrimSpace(opts.StageDir) == "" {
+		return errors.New("stage directory is required (--stage)")
+	}
+	if opts.NavWalk {
+		return errors.New("nav-walk clicks through a live page and cannot be staged")
+	}
+	if opts.QueueDir != "" {
+		return errors.New("queue-dir cannot be combined with --stage")
+	}
+	return nil
+}
+
+func fetchSingle(ctx context.Context, opts Options) error {
+	p, err := newPipeline(opts)
+	if err != nil {
+		return err
+	}
+	if err := p.runBeforeFetchHooks(ctx, &opts); err != nil {
+		return err
+	}
+	result, err := fetchResult(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if err := stage.WriteSingle(opts.StageDir, opts.URL, result, time.Now(), opts.sealer); err != nil {
+		return fmt.Errorf("stage %s: %w", opts.URL, err)
+	}
+	if !opts.Stdout {
+		fmt.Printf("Staged 1 page in %s\n", opts.StageDir)
+	}
+	return nil
+}
+
+func fetchCrawl(ctx context.Context, opts Options) error {
+	p, err := newPipeline(opts)
+	if err != nil {
+		return err
+	}
+	if err := p.runBeforeFetchHooks(ctx, &opts); err != nil {
+		return err
+	}
+	// The crawl state lives with the staged pages, so --resume continues an
+	// interrupted fetch.
+	crawlOpts := opts
+	crawlOpts.OutputDir = opts.StageDir
+	c, baseURL, _, err := initCrawler(ctx, crawlOpts, nil)
+	if err != nil {
+		return err
+	}
+	if !opts.Stdout {
+		fmt.Printf("Starting crawl from %s (max %d pages, depth %d)\n", baseURL, opts.MaxPages, opts.CrawlDepth)
+	}
+	results, stats, err := c.Crawl(ctx)
+	if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("crawl failed: %w", err)
+	}
+	if err := stage.WriteCrawl(opts.StageDir, baseURL, opts.SitemapURL, results, stats, opts.sealer); err != nil {
+		return fmt.Errorf("stage crawl: %w", err)
+	}
+	if !opts.Stdout {
+		fmt.Printf("Staged %d pages in %s (%d failed)\n", len(results), opts.StageDir, stats.PagesFailed)
+	}
+	return nil
+}
+
+func transformSingle(ctx context.Context, opts Options, m stage.Manifest) error {
+	if len(m.Pages) != 1 {
+		return fmt.Errorf("%s: expected 1 staged page, found %d", opts.StageDir, len(m.Pages))
+	}
+	page := m.Pages[0]
+	html, err := stage.HTML(opts.StageDir, page, opts.sealer)
+	if err != nil {
+		return err
+	}
+	if html == "" {
+		return fmt.Errorf("%s: staged page %s has no HTML", opts.StageDir, page.URL)
+	}
+	p, err := newPipeline(opts)
+	if err != nil {
+		return err
+	}
+	opts.fetchedAt = page.FetchedAt
+	baseDoc, err := p.prepareFetchedDocument(ctx, &opts, html)
+	if err != nil {
+		return err
+	}
+	sourceInfo := strings.TrimSpace("staged " + page.SourceInfo)
+	return p.processSingle(ctx, opts, baseDoc, fetch.Result{HTML: html, FinalMode: page.FinalMode, SourceInfo: sourceInfo})
+}
+
+func transformCrawl(ctx context.Context, opts Options, m stage.Manifest) error {
+	results, err := stage.Results(opts.StageDir, m, opts.sealer)
+	if err != nil {
+		return err
+	}
+	p, err := newPipeline(opts)
+	if err != nil {
+		return err
+	}
+	if !opts.Stdout {
+		fmt.Printf("Transforming %d staged pages from %s\n", len(results), opts.StageDir)
+	}
+	if opts.DryRun && !opts.Stdout {
+		est, err := p.estimateCrawl(ctx, opts, results, -1)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: dry-run estimate failed: %v\n", err)
+		} else {
+			printEstimate(opts, est)
+		}
+	}
+	printRunDiff(opts, func() (runDiff, bool, error) { return crawlRunDiff(opts, results) })
+	if !p.shouldWrite(opts) {
+		return nil
+	}
+	return processCrawlResults(ctx, p, opts, results, m.Stats)
+}
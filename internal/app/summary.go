@@ -22,6 +22,15 @@ func printSummary(sourceInfo string, doc *parse.Document, rep report.Report) {
 
 	fmt.Printf("Fetch mode: %s\n", sourceInfo)
 	fmt.Printf("Sections found: %d\n", len(doc.Sections))
+	if rep.FilteredSections > 0 {
+		fmt.Printf("Filtered sections (heading patterns): %d\n", rep.FilteredSections)
+	}
+	if rep.DateFilteredSections > 0 {
+		fmt.Printf("Filtered sections (outside --since/--until): %d\n", rep.DateFilteredSections)
+	}
+	if rep.DroppedEmptySections > 0 {
+		fmt.Printf("Dropped empty sections: %d\n", rep.DroppedEmptySections)
+	}
 
 	fmt.Println("Heading IDs:")
 	printList(headingIDs)
@@ -67,6 +76,15 @@ func unique(list []string) []string {
 	return out
 }
 
+// strictReport is the per-page report --strict enforces. With the crawl
+// anchor scope, broken anchors are checked once all pages are crawled.
+func strictReport(opts Options, rep report.Report) report.Report {
+	if opts.Crawl && opts.AnchorScope == AnchorScopeCrawl {
+		rep.BrokenAnchors = nil
+	}
+	return rep
+}
+
 func reportHasIssues(rep report.Report) bool {
 	return len(rep.MissingHeadingIDs) > 0 ||
 		len(rep.DuplicateIDs) > 0 ||
@@ -0,0 +1,78 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go_scrap/internal/crawler"
+)
+
+// runURLList scrapes each of opts.URLs into its own directory under pages/,
+// as a crawl would but without following links, and writes the merged index,
+// corpus, crawl index and index.html over them.
+func runURLList(ctx context.Context, opts Options) error {
+	p, err := newPipeline(opts)
+	if err != nil {
+		return err
+	}
+	if err := p.runBeforeFetchHooks(ctx, &opts); err != nil {
+		return err
+	}
+	if !opts.Stdout {
+		fmt.Printf("Scraping %d URLs\n", len(opts.URLs))
+	}
+
+	stats := crawler.Stats{StartedAt: time.Now()}
+	results := make(map[string]*crawler.Result, len(opts.URLs))
+	for _, pageURL := range opts.URLs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if _, ok := results[pageURL]; ok {
+			continue
+		}
+		r := fetchListedPage(ctx, opts, pageURL)
+		if r.Error != nil {
+			stats.PagesFailed++
+			stats.Errors = append(stats.Errors, fmt.Sprintf("%s: %v", pageURL, r.Error))
+			stats.Skipped = crawler.AddSkipCounts(stats.Skipped, map[crawler.SkipCategory]int{crawler.ErrorCategory(r.Error): 1})
+		} else {
+			stats.PagesCrawled++
+		}
+		results[pageURL] = r
+	}
+	stats.CompletedAt = time.Now()
+	if !opts.Stdout {
+		fmt.Printf("Fetched %d URLs, %d failed\n", stats.PagesCrawled, stats.PagesFailed)
+	}
+
+	if opts.DryRun && !opts.Stdout {
+		if est, err := p.estimateCrawl(ctx, opts, results, -1); err == nil {
+			printEstimate(opts, est)
+		}
+	}
+	printRunDiff(opts, func() (runDiff, bool, error) { return crawlRunDiff(opts, results) })
+	if !p.shouldWrite(opts) {
+		return nil
+	}
+	return processCrawlResults(ctx, p, opts, results, stats)
+}
+
+// fetchListedPage fetches pageURL as a single page would be, through the
+// cache when enabled, and returns it as a crawl result.
+func fetchListedPage(ctx context.Context, opts Options, pageURL string) *crawler.Result {
+	pageOpts := opts
+	pageOpts.URL = pageURL
+	start := time.Now()
+	result, err := fetchResult(ctx, pageOpts)
+	r := &crawler.Result{URL: pageURL, FetchedAt: time.Now()}
+	if err != nil {
+		r.Error = err
+		return r
+	}
+	r.HTML = result.HTML
+	r.ContentHash = crawler.HashHTML(result.HTML)
+	r.Provenance = crawler.Provenance{HTTPStatus: result.Status, DurationMS: time.Since(start).Milliseconds()}
+	return r
+}
@@ -0,0 +1,144 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"go_scrap/internal/fetch"
+	"go_scrap/internal/pageclass"
+	"go_scrap/internal/parse"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// printURLRe matches printer-friendly links such as ?print=1, ?view=print
+// or a trailing /print.
+var printURLRe = regexp.MustCompile(`(?i)[?&](?:print(?:able)?=(?:1|true|yes)|(?:view|output|format|mode|layout)=print(?:able)?)(?:&|$)|/print(?:able)?/?$`)
+
+// pageView is an alternate view of a page: its kind ("amp" or "print") and
+// absolute URL.
+type pageView struct {
+	kind string
+	url  string
+}
+
+// preferView returns the view of the page in result that opts.PreferView
+// asks for, when the page links to one that fetches and still holds the
+// page's content: no soft 404, login wall or JavaScript shell, and at least
+// a quarter of the page's text. Otherwise result is returned as it is.
+// The rest of the run resolves links against opts.URL, so the view's
+// relative links are made absolute against the view's own URL first.
+func preferView(ctx context.Context, opts Options, result fetch.Result) fetch.Result {
+	if opts.PreferView == "" || opts.PreferView == PreferViewOff {
+		return result
+	}
+	doc, err := parse.NewDocument(result.HTML)
+	if err != nil {
+		return result
+	}
+	for _, view := range pageViews(opts.URL, doc, opts.PreferView) {
+		viewOpts := opts
+		viewOpts.URL = view.url
+		viewResult, err := fetchPage(ctx, viewOpts)
+		if err == nil {
+			err = checkView(doc, viewResult.HTML)
+		}
+		if err != nil {
+			warnViewFallback(ctx, opts.URL, view.kind, view.url, err)
+			continue
+		}
+		if !opts.Stdout {
+			fmt.Printf("Using %s view: %s\n", view.kind, view.url)
+		}
+		viewResult.HTML = fetch.AbsoluteLinks(view.url, viewResult.HTML)
+		viewResult.SourceInfo = fmt.Sprintf("%s (%s view %s)", viewResult.SourceInfo, view.kind, view.url)
+		return viewResult
+	}
+	return result
+}
+
+// pageViews lists the views of the page at pageURL that prefer asks for, in
+// the order to try them.
+func pageViews(pageURL string, doc *goquery.Document, prefer string) []pageView {
+	var views []pageView
+	if prefer == PreferViewAMP || prefer == PreferViewAny {
+		if href, ok := doc.Find(`link[rel~="amphtml"]`).First().Attr("href"); ok {
+			if u := resolveView(pageURL, href); u != "" {
+				views = append(views, pageView{kind: PreferViewAMP, url: u})
+			}
+		}
+	}
+	if prefer == PreferViewPrint || prefer == PreferViewAny {
+		if u := printViewURL(pageURL, doc); u != "" {
+			views = append(views, pageView{kind: PreferViewPrint, url: u})
+		}
+	}
+	return views
+}
+
+// printViewURL is the page's print stylesheet alternate, or else its first
+// printer-friendly link on the same host.
+func printViewURL(pageURL string, doc *goquery.Document) string {
+	if href, ok := doc.Find(`link[rel~="alternate"][media="print" i]`).First().Attr("href"); ok {
+		if u := resolveView(pageURL, href); u != "" {
+			return u
+		}
+	}
+	page, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+	found := ""
+	doc.Find("a[href]").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		href, _ := s.Attr("href")
+		u := resolveView(pageURL, href)
+		if u == "" {
+			return true
+		}
+		if parsed, err := url.Parse(u); err == nil && strings.EqualFold(parsed.Host, page.Host) && printURLRe.MatchString(u) {
+			found = u
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// resolveView resolves href against pageURL, without its fragment. It is
+// empty unless href names another http(s) page.
+func resolveView(pageURL, href string) string {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+	u, err := base.Parse(strings.TrimSpace(href))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	u.Fragment = ""
+	base.Fragment = ""
+	if u.String() == base.String() {
+		return ""
+	}
+	return u.String()
+}
+
+// checkView reports why the fetched view html can't stand in for the page
+// in doc, or nil when it can.
+func checkView(doc *goquery.Document, html string) error {
+	viewDoc, err := parse.NewDocument(html)
+	if err != nil {
+		return err
+	}
+	if class := pageclass.Classify(viewDoc); class.Class != "" {
+		return fmt.Errorf("looks like a %s page (%s)", class.Class, class.Reason)
+	}
+	chars, pageChars := pageclass.VisibleChars(viewDoc), pageclass.VisibleChars(doc)
+	if chars*4 < pageChars {
+		return fmt.Errorf("%d characters of text against the page's %d", chars, pageChars)
+	}
+	return nil
+}
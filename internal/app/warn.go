@@ -0,0 +1,60 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"go_scrap/internal/warnings"
+)
+
+// warnOutputWrite reports an optional output file that could not be written.
+func warnOutputWrite(ctx context.Context, file string, err error) {
+	warnings.Report(ctx, warnings.Warning{
+		Code:    warnings.CodeOutputWrite,
+		Message: fmt.Sprintf("failed to write %s: %v", file, err),
+		Context: map[string]string{"file": file, "error": err.Error()},
+	})
+}
+
+// warnAssetDownload reports a page whose assets could not all be downloaded.
+func warnAssetDownload(ctx context.Context, pageURL string, err error) {
+	warnings.Report(ctx, warnings.Warning{
+		Code:    warnings.CodeAssetDownload,
+		Message: fmt.Sprintf("asset processing failed: %v", err),
+		URL:     pageURL,
+		Context: map[string]string{"error": err.Error()},
+	})
+}
+
+// warnSelectorFallback reports a content selector that matched nothing
+// usable, so the whole page was parsed instead.
+func warnSelectorFallback(ctx context.Context, pageURL, selector, reason string) {
+	warnings.Report(ctx, warnings.Warning{
+		Code:    warnings.CodeSelectorFallback,
+		Message: fmt.Sprintf("content selector %q %s on %s; using the whole page", selector, reason, pageURL),
+		URL:     pageURL,
+		Context: map[string]string{"selector": selector, "reason": reason},
+	})
+}
+
+// warnItemFallback reports an item selector that matched nothing on a page,
+// which was split into sections by headings instead.
+func warnItemFallback(ctx context.Context, pageURL, selector string) {
+	warnings.Report(ctx, warnings.Warning{
+		Code:    warnings.CodeSelectorFallback,
+		Message: fmt.Sprintf("item selector %q matched nothing on %s; using heading sections", selector, pageURL),
+		URL:     pageURL,
+		Context: map[string]string{"selector": selector, "reason": "matched nothing"},
+	})
+}
+
+// warnViewFallback reports a --prefer-view view that was skipped for the
+// next view or the page itself.
+func warnViewFallback(ctx context.Context, pageURL, kind, viewURL string, err error) {
+	warnings.Report(ctx, warnings.Warning{
+		Code:    warnings.CodeViewFallback,
+		Message: fmt.Sprintf("ignoring %s view %s: %v", kind, viewURL, err),
+		URL:     pageURL,
+		Context: map[string]string{"view": kind, "view_url": viewURL, "error": err.Error()},
+	})
+}
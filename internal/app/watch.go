@@ -0,0 +1,146 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go_scrap/internal/fsutil"
+	"go_scrap/internal/output"
+)
+
+// WatchLogName is the change log appended to by every --watch cycle.
+const WatchLogName = "watch.jsonl"
+
+// WatchCycle is one line of watch.jsonl: the headings a cycle added,
+// removed or changed, labelled by heading path (prefixed with the page URL
+// when the run covers several pages).
+type WatchCycle struct {
+	Cycle       int       `json:"cycle"`
+	RunID       string    `json:"run_id"`
+	StartedAt   time.Time `json:"started_at"`
+	CompletedAt time.Time `json:"completed_at"`
+	Added       []string  `json:"added,omitempty"`
+	Removed     []string  `json:"removed,omitempty"`
+	Changed     []string  `json:"changed,omitempty"`
+	Unchanged   int       `json:"unchanged"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// Watch runs opts every opts.Watch until ctx is done, each cycle within
+// opts.Timeout. Each cycle compares the sections of the output directory
+// before and after the run and appends the differences to watch.jsonl.
+// Crawled pages whose content hash did not change, and a single page whose
+// sections did not, are left as they are. A failed cycle is logged and the
+// next one runs as scheduled.
+func Watch(ctx context.Context, opts Options) error {
+	normalized, err := normalizeOptions(opts)
+	if err != nil {
+		return err
+	}
+	dir, multiPage := normalized.OutputDir, normalized.Crawl || len(normalized.URLs) > 0
+	interval := opts.Watch
+	opts.Watch = 0
+	opts.Yes = true
+	opts.Resume = multiPage
+	opts.watching = true
+
+	for cycle := 1; ; cycle++ {
+		started := time.Now()
+		cycleOpts := opts
+		cycleOpts.RunID = newRunID(started)
+		fmt.Printf("Watch cycle %d (%s)\n", cycle, started.Format(time.RFC3339))
+
+		prev, err := watchFingerprints(dir, multiPage)
+		if err == nil {
+			err = runCycle(ctx, cycleOpts)
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		entry := WatchCycle{Cycle: cycle, RunID: cycleOpts.RunID, StartedAt: started}
+		if err != nil {
+			entry.Error = err.Error()
+			fmt.Fprintf(os.Stderr, "Warning: watch cycle %d failed: %v\n", cycle, err)
+		} else if next, ferr := watchFingerprints(dir, multiPage); ferr != nil {
+			entry.Error = ferr.Error()
+		} else {
+			d := diffFingerprints("headings", prev, next)
+			entry.Added, entry.Removed, entry.Changed, entry.Unchanged = d.Added, d.Removed, d.Modified, d.Unchanged
+			fmt.Printf("Watch cycle %d: %d added, %d removed, %d changed, %d unchanged headings\n", cycle, len(d.Added), len(d.Removed), len(d.Modified), d.Unchanged)
+			printDiffLines(os.Stdout, "+", d.Added)
+			printDiffLines(os.Stdout, "-", d.Removed)
+			printDiffLines(os.Stdout, "~", d.Modified)
+		}
+		entry.CompletedAt = time.Now()
+		if werr := appendWatchLog(dir, entry); werr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write %s: %v\n", WatchLogName, werr)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Until(started.Add(interval))):
+		}
+	}
+}
+
+// runCycle runs one watch cycle within opts.Timeout.
+func runCycle(ctx context.Context, opts Options) error {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	return Run(ctx, opts)
+}
+
+// watchFingerprints fingerprints the sections in dir/index.jsonl, keyed as
+// WatchCycle labels them; it is empty before the first run.
+func watchFingerprints(dir string, multiPage bool) (map[string]string, error) {
+	fps, err := output.ReadSectionFingerprints(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+	if !multiPage {
+		return sectionFingerprintMap(fps), nil
+	}
+	byPage := map[string][]output.SectionFingerprint{}
+	for _, fp := range fps {
+		byPage[fp.URL] = append(byPage[fp.URL], fp)
+	}
+	out := map[string]string{}
+	for pageURL, pageFPs := range byPage {
+		for label, hash := range sectionFingerprintMap(pageFPs) {
+			out[pageURL+" > "+label] = hash
+		}
+	}
+	return out, nil
+}
+
+// appendWatchLog appends entry to outDir/watch.jsonl.
+func appendWatchLog(outDir string, entry WatchCycle) error {
+	if err := fsutil.MkdirAll(outDir, 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f, err := fsutil.OpenFile(filepath.Join(outDir, WatchLogName), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -1,15 +1,20 @@
 package app
 
 import (
+	"bufio"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"go_scrap/internal/markdown"
 	"go_scrap/internal/menu"
 	"go_scrap/internal/output"
 	"go_scrap/internal/parse"
+	"go_scrap/internal/report"
 
 	"github.com/PuerkitoBio/goquery"
 )
@@ -20,28 +25,74 @@ type sectionMarkdown struct {
 	Markdown   string
 }
 
-func writeOutputsWithMarkdown(opts Options, baseDoc *goquery.Document, result analysisResult, md string, sectionMarkdowns []sectionMarkdown) (WriteResult, error) {
+// writeOutputsWithMarkdown writes every output for one page from its
+// rendered sections. md is the joined page Markdown when a hook produced it;
+// when empty, content.md is streamed from the sections.
+func writeOutputsWithMarkdown(ctx context.Context, opts Options, baseDoc *goquery.Document, result analysisResult, md string, sectionMarkdowns []sectionMarkdown) (WriteResult, error) {
 	written := WriteResult{OutputDir: opts.OutputDir}
-	if opts.Strict && reportHasIssues(result.Rep) {
+	if opts.Strict && reportHasIssues(strictReport(opts, result.Rep)) {
 		return WriteResult{}, errors.New("completeness checks failed (use --strict=false to allow)")
 	}
 
-	jsonPath, err := output.WriteJSON(result.Doc, result.Rep, output.WriteOptions{OutputDir: opts.OutputDir})
+	// A canceled context (a page past --page-timeout) stops before each
+	// write, so an abandoned page leaves nothing half-written behind.
+	if err := ctx.Err(); err != nil {
+		return WriteResult{}, err
+	}
+	limits := chunkLimits(opts)
+	markdowns := sectionMarkdownsFor(result.Doc.Sections, sectionMarkdowns)
+	perSection := newSectionOutputs(opts, result.Doc.Sections)
+	for i, sm := range markdowns {
+		perSection.add(i, sm)
+	}
+	perSection.close(&written)
+	reportChunks(opts, &result, perSection.meter)
+
+	if err := ctx.Err(); err != nil {
+		return WriteResult{}, err
+	}
+	jsonPath, err := writeJSON(opts, result)
 	if err != nil {
 		return WriteResult{}, err
 	}
 	written.JSONPath = jsonPath
 
 	var mdPath string
-	limits := chunkLimits(opts)
 	contentParts := make([]string, 0, len(sectionMarkdowns))
 	for _, sm := range sectionMarkdowns {
 		contentParts = append(contentParts, sm.Markdown)
 	}
-	if limits.Enabled() {
-		mdPath, err = output.WriteMarkdownParts(opts.OutputDir, "content.md", contentParts, limits)
-	} else {
-		mdPath, err = output.WriteMarkdown(opts.OutputDir, "content.md", md)
+	if footer := pageCitation(opts, result.Doc); footer != "" {
+		contentParts = append(contentParts, footer)
+		if md != "" {
+			md = output.AppendCitation(md, footer)
+		}
+	}
+	if opts.FrontMatter {
+		var hash string
+		if md != "" {
+			hash = output.HashMarkdown([]string{md}, "")
+		} else {
+			hash = output.HashMarkdown(contentParts, "\n")
+		}
+		fm := output.FrontMatter(opts.URL, result.Doc, opts.fetchedAt, hash)
+		contentParts = append([]string{fm}, contentParts...)
+		if md != "" {
+			md = fm + "\n" + md
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return WriteResult{}, err
+	}
+	switch {
+	case opts.SplitByHeadingLevel > 0 && len(result.Doc.Sections) > 0:
+		mdPath, err = writeHeadingSplit(opts, result.Doc, markdowns, limits)
+	case limits.Enabled():
+		mdPath, err = output.WriteMarkdownPartsEncoded(opts.OutputDir, "content.md", contentParts, limits, textEncoding(opts), slugStrategy(opts))
+	case md != "":
+		mdPath, err = output.WriteMarkdownEncoded(opts.OutputDir, "content.md", md, textEncoding(opts))
+	default:
+		mdPath, err = output.WriteMarkdownStream(opts.OutputDir, "content.md", contentParts, "\n", textEncoding(opts))
 	}
 	if err != nil {
 		return WriteResult{}, err
@@ -49,40 +100,459 @@ func writeOutputsWithMarkdown(opts Options, baseDoc *goquery.Document, result an
 	written.MarkdownPath = mdPath
 
 	if opts.Stdout {
-		fmt.Println(md)
+		if err := printMarkdown(opts.stdout(), md, contentParts); err != nil {
+			return WriteResult{}, err
+		}
 	} else {
-		fmt.Printf("\nWrote markdown: %s\n", mdPath)
-		fmt.Printf("Wrote json: %s\n", jsonPath)
+		fmt.Fprintf(opts.stdout(), "\nWrote markdown: %s\n", mdPath)
+		fmt.Fprintf(opts.stdout(), "Wrote json: %s\n", jsonPath)
+	}
+	return writePageExtras(ctx, opts, baseDoc, result, written, sectionMarkdowns)
+}
+
+// streamable reports whether a page can be written section by section as it
+// is rendered. Hooks see the whole page, and front matter, menus and
+// --split-by-heading-level need every section first, so they keep the
+// rendered sections in memory.
+func (p *pipeline) streamable(opts Options) bool {
+	return len(p.hooks) == 0 && !opts.FrontMatter && opts.SplitByHeadingLevel <= 0 && strings.TrimSpace(opts.NavSelector) == ""
+}
+
+// streamOutputs renders a page's sections and writes each one to content.md,
+// --stdout, corpus.jsonl and chunks.jsonl as soon as it is rendered, so only
+// a few sections' Markdown is in memory at a time. The page's other outputs
+// follow, as in writeOutputsWithMarkdown. A failed or canceled render removes
+// the partly written files.
+func (p *pipeline) streamOutputs(ctx context.Context, opts Options, baseDoc *goquery.Document, result analysisResult) (WriteResult, error) {
+	written := WriteResult{OutputDir: opts.OutputDir}
+	if opts.Strict && reportHasIssues(strictReport(opts, result.Rep)) {
+		return WriteResult{}, errors.New("completeness checks failed (use --strict=false to allow)")
+	}
+	if err := ctx.Err(); err != nil {
+		return WriteResult{}, err
+	}
+
+	limits := chunkLimits(opts)
+	sep := "\n"
+	if limits.Enabled() {
+		sep = ""
+	}
+	content, err := output.NewMarkdownWriter(opts.OutputDir, "content.md", sep, limits, textEncoding(opts), slugStrategy(opts))
+	if err != nil {
+		return WriteResult{}, err
+	}
+	var stdout *bufio.Writer
+	if opts.Stdout {
+		stdout = bufio.NewWriter(opts.stdout())
+	}
+	sections := result.Doc.Sections
+	perSection := newSectionOutputs(opts, sections)
+	add := func(md string) error {
+		if err := content.Add(md); err != nil {
+			return err
+		}
+		if stdout != nil {
+			if _, err := stdout.WriteString(md + "\n"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	err = renderEach(ctx, p.converters, sections, p.renderWorkers, func(i int, sm sectionMarkdown) error {
+		if opts.Citation == CitationSection {
+			sm.Markdown = output.AppendCitation(sm.Markdown, sectionCitation(opts, sections[i]))
+		}
+		perSection.add(i, sm.Markdown)
+		return add(sm.Markdown)
+	})
+	if err == nil {
+		if footer := pageCitation(opts, result.Doc); footer != "" {
+			err = add(footer)
+		}
+	}
+	if err != nil {
+		content.Abort()
+		perSection.abort()
+		return WriteResult{}, err
+	}
+	mdPath, err := content.Close()
+	if err != nil {
+		perSection.abort()
+		return WriteResult{}, err
+	}
+	written.MarkdownPath = mdPath
+	perSection.close(&written)
+	if stdout != nil {
+		_, err := stdout.WriteString("\n")
+		if ferr := stdout.Flush(); err == nil {
+			err = ferr
+		}
+		if err != nil {
+			return WriteResult{}, err
+		}
+	}
+	reportChunks(opts, &result, perSection.meter)
+
+	if err := ctx.Err(); err != nil {
+		return WriteResult{}, err
+	}
+	jsonPath, err := writeJSON(opts, result)
+	if err != nil {
+		return WriteResult{}, err
+	}
+	written.JSONPath = jsonPath
+	if !opts.Stdout {
+		fmt.Fprintf(opts.stdout(), "\nWrote markdown: %s\n", mdPath)
+		fmt.Fprintf(opts.stdout(), "Wrote json: %s\n", jsonPath)
+	}
+	return writePageExtras(ctx, opts, baseDoc, result, written, nil)
+}
+
+// sectionOutputs are the outputs built a section at a time: the chunk sizes
+// of the report, and corpus.jsonl and chunks.jsonl unless --stdout. A file
+// that fails to write is dropped, like the page's other optional outputs.
+type sectionOutputs struct {
+	meter  *output.ChunkMeter
+	corpus *output.CorpusWriter
+	chunks *output.ChunksWriter
+}
+
+func newSectionOutputs(opts Options, sections []parse.Section) *sectionOutputs {
+	limits := chunkLimits(opts)
+	s := &sectionOutputs{meter: output.NewChunkMeter(sections, limits)}
+	if opts.Stdout {
+		return s
+	}
+	if corpus, err := output.NewCorpusWriter(opts.OutputDir, opts.URL, sections, limits); err == nil {
+		s.corpus = corpus
+	}
+	if opts.ChunkTokens > 0 {
+		chunkOpts := output.ChunkOptions{Tokens: opts.ChunkTokens, Overlap: opts.ChunkOverlap, Tokenizer: opts.tokenizer}
+		if chunks, err := output.NewChunksWriter(opts.OutputDir, opts.URL, sections, chunkOpts); err == nil {
+			s.chunks = chunks
+		}
+	}
+	return s
+}
+
+// add takes the Markdown of sections[i].
+func (s *sectionOutputs) add(i int, md string) {
+	s.meter.Add(i, md)
+	if s.corpus != nil && s.corpus.Add(i, md) != nil {
+		s.corpus.Abort()
+		s.corpus = nil
+	}
+	if s.chunks != nil && s.chunks.Add(i, md) != nil {
+		s.chunks.Abort()
+		s.chunks = nil
+	}
+}
+
+// close finishes the files and records them in written.
+func (s *sectionOutputs) close(written *WriteResult) {
+	if s.corpus != nil {
+		if path, err := s.corpus.Close(); err == nil {
+			written.CorpusPath = path
+		}
+	}
+	if s.chunks != nil {
+		if path, err := s.chunks.Close(); err == nil {
+			written.ChunksPath = path
+		}
+	}
+}
+
+func (s *sectionOutputs) abort() {
+	if s.corpus != nil {
+		s.corpus.Abort()
+	}
+	if s.chunks != nil {
+		s.chunks.Abort()
 	}
+}
 
-	if err := writeMenuOutputs(opts, baseDoc, result.Doc, sectionMarkdowns); err != nil {
+// reportChunks adds the measured chunk sizes to the page report and prints
+// them.
+func reportChunks(opts Options, result *analysisResult, meter *output.ChunkMeter) {
+	limits := chunkLimits(opts)
+	chunkReport := report.AnalyzeChunks(meter.Sizes(), report.ChunkLimits{MaxBytes: limits.MaxBytes, MaxChars: limits.MaxChars, MaxTokens: limits.MaxTokens})
+	result.Rep.Chunks = &chunkReport
+	if !opts.Stdout {
+		chunkReport.Print(opts.stdout())
+	}
+}
+
+func writeJSON(opts Options, result analysisResult) (string, error) {
+	return output.WriteJSON(result.Doc, result.Rep, output.WriteOptions{
+		OutputDir:       opts.OutputDir,
+		JSONFields:      opts.JSONFields,
+		OmitContentText: opts.OmitContentText,
+		NDJSON:          opts.JSONFormat == JSONFormatNDJSON,
+		Gzip:            opts.GzipJSON,
+		Encoding:        textEncoding(opts),
+	})
+}
+
+// writePageExtras writes the page's outputs that follow content.md: the menu
+// files and, unless --stdout, index.jsonl and the link, anchor and browse
+// files. corpus.jsonl and chunks.jsonl are already in written.
+func writePageExtras(ctx context.Context, opts Options, baseDoc *goquery.Document, result analysisResult, written WriteResult, sectionMarkdowns []sectionMarkdown) (WriteResult, error) {
+	if err := ctx.Err(); err != nil {
+		return WriteResult{}, err
+	}
+	sectionFiles, err := writeMenuOutputs(ctx, opts, baseDoc, result.Doc, sectionMarkdowns)
+	if err != nil {
 		return WriteResult{}, err
 	}
 	if strings.TrimSpace(opts.NavSelector) != "" {
 		written.MenuPath = filepath.Join(opts.OutputDir, "menu.json")
 	}
 
+	if err := ctx.Err(); err != nil {
+		return WriteResult{}, err
+	}
 	if !opts.Stdout {
-		if indexPath, err := output.WriteIndex(opts.OutputDir, opts.URL, result.Doc.Sections); err == nil {
-			fmt.Printf("Wrote index: %s\n", indexPath)
+		if indexPath, err := output.WriteIndex(opts.OutputDir, opts.URL, result.Doc.Sections, opts.tokenizer); err == nil {
+			fmt.Fprintf(opts.stdout(), "Wrote index: %s\n", indexPath)
 			written.IndexPath = indexPath
 		}
+		if written.CorpusPath != "" {
+			fmt.Fprintf(opts.stdout(), "Wrote corpus: %s\n", written.CorpusPath)
+		}
+		if written.ChunksPath != "" {
+			fmt.Fprintf(opts.stdout(), "Wrote chunks: %s\n", written.ChunksPath)
+		}
+		if mediaPath := writeMediaLinks(ctx, opts, baseDoc); mediaPath != "" {
+			fmt.Fprintf(opts.stdout(), "Wrote media links: %s\n", mediaPath)
+			written.MediaLinksPath = mediaPath
+		}
+		if externalPath := writeExternalLinks(ctx, opts, result.Doc.Sections); externalPath != "" {
+			fmt.Fprintf(opts.stdout(), "Wrote external links: %s\n", externalPath)
+			written.ExternalLinksPath = externalPath
+		}
+		anchors := output.BuildAnchorMap(opts.URL, "content.md", result.Doc, sectionFiles)
+		if anchorsPath, err := output.WriteAnchors(opts.OutputDir, anchors, textEncoding(opts)); err == nil {
+			fmt.Fprintf(opts.stdout(), "Wrote anchors: %s\n", anchorsPath)
+			written.AnchorsPath = anchorsPath
+		}
+		pages := []output.BrowsePage{{URL: opts.URL, Markdown: "content.md"}}
+		if browsePath, err := output.WriteBrowseHTML(opts.OutputDir, opts.URL, pages, result.Rep); err == nil {
+			fmt.Fprintf(opts.stdout(), "Wrote browser index: %s\n", browsePath)
+		} else {
+			warnOutputWrite(ctx, "index.html", err)
+		}
 	}
-
 	return written, nil
 }
 
+// writeMediaLinks writes media-links.json when the page links to non-HTML
+// files, first downloading those selected by --download-media, and returns
+// its path.
+func writeMediaLinks(ctx context.Context, opts Options, baseDoc *goquery.Document) string {
+	links := output.CollectMediaLinks(baseDoc, opts.URL)
+	if len(links) == 0 {
+		return ""
+	}
+	if len(opts.DownloadMedia) > 0 {
+		if err := output.DownloadMedia(ctx, links, opts.DownloadMedia, opts.OutputDir, opts.UserAgent, assetRetry(opts)); err != nil {
+			warnAssetDownload(ctx, opts.URL, err)
+		}
+	}
+	path, err := output.WriteMediaLinks(opts.OutputDir, links)
+	if err != nil {
+		warnOutputWrite(ctx, "media-links.json", err)
+		return ""
+	}
+	return path
+}
+
+// writeExternalLinks writes external-links.json when sections link off-site
+// and returns its path.
+func writeExternalLinks(ctx context.Context, opts Options, sections []parse.Section) string {
+	links := output.CollectExternalLinks(opts.URL, sections)
+	if len(links) == 0 {
+		return ""
+	}
+	path, err := output.WriteExternalLinks(opts.OutputDir, links)
+	if err != nil {
+		warnOutputWrite(ctx, "external-links.json", err)
+		return ""
+	}
+	return path
+}
+
+// printMarkdown writes the page Markdown to stdout for --stdout, streaming the
+// sections when no hook produced a joined document.
+func printMarkdown(out io.Writer, md string, parts []string) error {
+	w := bufio.NewWriter(out)
+	var err error
+	if md != "" {
+		_, err = w.WriteString(md)
+	} else {
+		err = output.StreamMarkdown(w, parts, "\n")
+	}
+	if err == nil {
+		_, err = w.WriteString("\n")
+	}
+	if ferr := w.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// citeSections ends each section's Markdown with its citation footer, for
+// --citation section. parts are in the order of sections.
+func citeSections(opts Options, sections []parse.Section, parts []sectionMarkdown) {
+	for i := range parts {
+		if i >= len(sections) {
+			break
+		}
+		parts[i].Markdown = output.AppendCitation(parts[i].Markdown, sectionCitation(opts, sections[i]))
+	}
+}
+
+// sectionCitation returns the footer that ends sec's Markdown with
+// --citation section.
+func sectionCitation(opts Options, sec parse.Section) string {
+	return output.CitationFooter(opts.CitationTemplate, output.Citation{
+		URL:       opts.URL,
+		SectionID: sec.HeadingID,
+		Heading:   sec.HeadingText,
+		FetchedAt: opts.fetchedAt,
+	})
+}
+
+// pageCitation returns the footer that ends content.md with --citation page,
+// or "".
+func pageCitation(opts Options, doc *parse.Document) string {
+	if opts.Citation != CitationPage {
+		return ""
+	}
+	c := output.Citation{URL: opts.URL, FetchedAt: opts.fetchedAt}
+	if doc != nil && len(doc.Sections) > 0 {
+		c.Heading = doc.Sections[0].HeadingText
+	}
+	return output.CitationFooter(opts.CitationTemplate, c)
+}
+
+// sectionMarkdownsFor lines rendered Markdown up with sections. Hooks may have
+// reordered or dropped rendered sections, so mismatches fall back to the
+// heading ID.
+func sectionMarkdownsFor(sections []parse.Section, rendered []sectionMarkdown) []string {
+	byID := make(map[string]string, len(rendered))
+	for _, sm := range rendered {
+		byID[sm.HeadingID] = sm.Markdown
+	}
+	out := make([]string, len(sections))
+	for i, sec := range sections {
+		if i < len(rendered) && rendered[i].HeadingID == sec.HeadingID {
+			out[i] = rendered[i].Markdown
+			continue
+		}
+		out[i] = byID[sec.HeadingID]
+	}
+	return out
+}
+
+// writeHeadingSplit writes content.md as an index of one file per heading of
+// --split-by-heading-level or shallower, each with its front matter and the
+// last ending with the page citation.
+func writeHeadingSplit(opts Options, doc *parse.Document, markdowns []string, limits output.ChunkLimits) (string, error) {
+	files := output.SplitByHeadingLevel(doc.Sections, markdowns, opts.SplitByHeadingLevel)
+	if footer := pageCitation(opts, doc); footer != "" && len(files) > 0 {
+		last := &files[len(files)-1]
+		last.Markdown = output.AppendCitation(last.Markdown, footer)
+	}
+	for i := range files {
+		if opts.DownloadAssets {
+			files[i].Markdown = nestedAssetLinks(files[i].Markdown, assetLinkPrefix(opts))
+		}
+	}
+	if opts.FrontMatter {
+		// Each file's front matter describes its first section, with the
+		// hash of the whole file.
+		fileMarkdowns := make([]string, len(doc.Sections))
+		for _, f := range files {
+			fileMarkdowns[f.Lead] = f.Markdown
+		}
+		fms := output.SectionFrontMatters(opts.URL, doc.Sections, fileMarkdowns, opts.fetchedAt)
+		for i := range files {
+			files[i].Markdown = fms[files[i].Lead] + "\n" + files[i].Markdown
+		}
+	}
+	var heading string
+	if title := strings.TrimSpace(doc.Sections[0].HeadingText); title != "" {
+		heading = "# " + title
+	}
+	return output.WriteHeadingFiles(opts.OutputDir, "content.md", heading, files, limits, textEncoding(opts), slugStrategy(opts))
+}
+
+// nestedAssetLinks points --download-assets links in Markdown written one
+// directory below the output directory back at the assets, which the page
+// links to with prefix.
+func nestedAssetLinks(md, prefix string) string {
+	md = strings.ReplaceAll(md, "("+prefix, "(../"+prefix)
+	return strings.ReplaceAll(md, "\""+prefix, "\"../"+prefix)
+}
+
+// assetLinkPrefix is how the page's files link to its downloaded assets:
+// assets/, or the run's shared store in a crawl.
+func assetLinkPrefix(opts Options) string {
+	if opts.assets == nil {
+		return "assets/"
+	}
+	return opts.assets.LinkPrefix(assetRefDir(opts))
+}
+
+// assetRefDir is the directory the page's files link to shared assets from.
+func assetRefDir(opts Options) string {
+	if opts.assetRefDir != "" {
+		return opts.assetRefDir
+	}
+	return opts.OutputDir
+}
+
+// sectionFrontMatters returns the front matter for each rendered section's
+// file, or "" for one no parsed section matches.
+func sectionFrontMatters(opts Options, sections []parse.Section, rendered []sectionMarkdown) []string {
+	fms := output.SectionFrontMatters(opts.URL, sections, sectionMarkdownsFor(sections, rendered), opts.fetchedAt)
+	byID := make(map[string]string, len(sections))
+	for i, sec := range sections {
+		if _, ok := byID[sec.HeadingID]; !ok {
+			byID[sec.HeadingID] = fms[i]
+		}
+	}
+	out := make([]string, len(rendered))
+	for i, sm := range rendered {
+		if i < len(sections) && sections[i].HeadingID == sm.HeadingID {
+			out[i] = fms[i]
+			continue
+		}
+		out[i] = byID[sm.HeadingID]
+	}
+	return out
+}
+
 func trimSections(doc *parse.Document, maxSections int) {
 	if maxSections > 0 && maxSections < len(doc.Sections) {
 		doc.Sections = doc.Sections[:maxSections]
 	}
 }
 
+func textEncoding(opts Options) output.TextEncoding {
+	return output.TextEncoding{
+		CRLF: opts.Newline == output.NewlineCRLF,
+		BOM:  opts.BOM,
+	}
+}
+
 func chunkLimits(opts Options) output.ChunkLimits {
 	return output.ChunkLimits{
-		MaxBytes:  opts.MaxMarkdownBytes,
-		MaxChars:  opts.MaxChars,
-		MaxTokens: opts.MaxTokens,
+		MaxBytes:      opts.MaxMarkdownBytes,
+		MaxChars:      opts.MaxChars,
+		MaxTokens:     opts.MaxTokens,
+		Tokenizer:     opts.tokenizer,
+		IndexTemplate: opts.splitIndex,
 	}
 }
 
@@ -93,46 +563,194 @@ func applyExclusions(doc *goquery.Document, selector string) {
 	_ = parse.RemoveSelectors(doc, selector)
 }
 
-func buildMarkdown(conv *markdown.Converter, sections []parse.Section) (string, []sectionMarkdown, error) {
-	var mdBuilder strings.Builder
+func buildMarkdown(ctx context.Context, conv *markdown.Converter, sections []parse.Section) ([]sectionMarkdown, error) {
 	parts := make([]sectionMarkdown, 0, len(sections))
 	for _, section := range sections {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		md, err := conv.SectionToMarkdown(section.HeadingText, section.HeadingLevel, section.ContentHTML)
 		if err != nil {
-			return "", nil, err
+			return nil, err
 		}
-		mdBuilder.WriteString(md)
-		mdBuilder.WriteString("\n")
-		if !strings.HasSuffix(md, "\n") {
-			md += "\n"
+		parts = append(parts, newSectionMarkdown(section, md))
+	}
+	return parts, nil
+}
+
+// buildMarkdownConcurrent converts sections on up to workers goroutines and
+// collects them in document order.
+func buildMarkdownConcurrent(ctx context.Context, pool *markdown.Pool, sections []parse.Section, workers int) ([]sectionMarkdown, error) {
+	parts := make([]sectionMarkdown, 0, len(sections))
+	err := renderEach(ctx, pool, sections, workers, func(_ int, sm sectionMarkdown) error {
+		parts = append(parts, sm)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parts, nil
+}
+
+// renderEach converts sections on up to workers goroutines, each with its
+// own converter from pool, and hands them to emit in document order as soon
+// as every section before them has been handed over. Workers run at most
+// 2*workers sections ahead of emit, so a page is never held whole. The first
+// conversion or emit error cancels the rest.
+func renderEach(ctx context.Context, pool *markdown.Pool, sections []parse.Section, workers int, emit func(i int, sm sectionMarkdown) error) error {
+	if workers > len(sections) {
+		workers = len(sections)
+	}
+	if workers <= 1 {
+		conv := pool.Get()
+		defer pool.Put(conv)
+		for i, section := range sections {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			md, err := conv.SectionToMarkdown(section.HeadingText, section.HeadingLevel, section.ContentHTML)
+			if err != nil {
+				return err
+			}
+			if err := emit(i, newSectionMarkdown(section, md)); err != nil {
+				return err
+			}
 		}
-		parts = append(parts, sectionMarkdown{
-			HeadingID:  section.HeadingID,
-			ContentIDs: section.ContentIDs,
-			Markdown:   md,
-		})
+		return nil
+	}
+
+	type converted struct {
+		md  string
+		err error
+	}
+	type job struct {
+		i   int
+		out chan<- converted
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// pending queues each section's result in document order; its capacity
+	// is how far conversion runs ahead of emit.
+	pending := make(chan chan converted, 2*workers)
+	jobs := make(chan job)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conv := pool.Get()
+			defer pool.Put(conv)
+			for j := range jobs {
+				sec := sections[j.i]
+				md, err := conv.SectionToMarkdown(sec.HeadingText, sec.HeadingLevel, sec.ContentHTML)
+				j.out <- converted{md: md, err: err}
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(jobs)
+		defer close(pending)
+		for i := range sections {
+			out := make(chan converted, 1)
+			select {
+			case pending <- out:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- job{i: i, out: out}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var err error
+	i := 0
+	for out := range pending {
+		select {
+		case c := <-out:
+			err = c.err
+			if err == nil {
+				err = emit(i, newSectionMarkdown(sections[i], c.md))
+			}
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		if err != nil {
+			break
+		}
+		i++
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	cancel()
+	wg.Wait()
+	return err
+}
+
+// newSectionMarkdown pairs converted section Markdown with its section.
+func newSectionMarkdown(section parse.Section, md string) sectionMarkdown {
+	if !strings.HasSuffix(md, "\n") {
+		md += "\n"
+	}
+	return sectionMarkdown{
+		HeadingID:  section.HeadingID,
+		ContentIDs: section.ContentIDs,
+		Markdown:   md,
 	}
-	return mdBuilder.String(), parts, nil
 }
 
-func writeMenuOutputs(opts Options, baseDoc *goquery.Document, _ *parse.Document, sections []sectionMarkdown) error {
+// joinMarkdown is the page Markdown: every section followed by a blank line.
+// It is only built when a hook needs the whole document; writes stream the
+// sections instead.
+func joinMarkdown(sections []sectionMarkdown) string {
+	size := 0
+	for _, sm := range sections {
+		size += len(sm.Markdown) + 1
+	}
+	var b strings.Builder
+	b.Grow(size)
+	for _, sm := range sections {
+		b.WriteString(sm.Markdown)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// writeMenuOutputs writes the per-section files and then menu.json,
+// SUMMARY.md and _sidebar.md, so each menu node carries its URL and generated
+// file. It returns the section file
+// written for each menu anchor.
+func writeMenuOutputs(ctx context.Context, opts Options, baseDoc *goquery.Document, doc *parse.Document, sections []sectionMarkdown) (map[string]string, error) {
 	if strings.TrimSpace(opts.NavSelector) == "" {
-		return nil
+		return nil, nil
 	}
 	nodes, err := menu.Extract(baseDoc, opts.NavSelector)
 	if err != nil {
-		return fmt.Errorf("menu extract failed (%s): %w", opts.NavSelector, err)
+		return nil, fmt.Errorf("menu extract failed (%s): %w", opts.NavSelector, err)
 	}
-	if err := output.WriteMenu(opts.OutputDir, nodes); err != nil {
-		return fmt.Errorf("menu write failed: %w", err)
+	menu.ResolveURLs(nodes, opts.URL)
+
+	sections = append([]sectionMarkdown(nil), sections...)
+	if opts.DownloadAssets {
+		for i := range sections {
+			sections[i].Markdown = nestedAssetLinks(sections[i].Markdown, assetLinkPrefix(opts))
+		}
+	}
+	var frontMatters []string
+	if opts.FrontMatter && doc != nil {
+		frontMatters = sectionFrontMatters(opts, doc.Sections, sections)
 	}
 
 	mdByID := map[string]string{}
-	for _, section := range sections {
+	for i, section := range sections {
 		md := section.Markdown
-		if opts.DownloadAssets {
-			md = strings.ReplaceAll(md, "(assets/", "(../assets/")
-			md = strings.ReplaceAll(md, "\"assets/", "\"../assets/")
+		if i < len(frontMatters) && frontMatters[i] != "" {
+			md = frontMatters[i] + "\n" + md
 		}
 
 		if section.HeadingID != "" {
@@ -146,8 +764,15 @@ func writeMenuOutputs(opts Options, baseDoc *goquery.Document, _ *parse.Document
 	}
 
 	limits := chunkLimits(opts)
-	if err := output.WriteSectionFiles(opts.OutputDir, nodes, mdByID, opts.MaxMenuItems, limits); err != nil {
-		return fmt.Errorf("section write failed: %w", err)
+	files, err := output.WriteSectionFilesContext(ctx, opts.OutputDir, nodes, mdByID, opts.MaxMenuItems, limits, textEncoding(opts), slugStrategy(opts))
+	if err != nil {
+		return nil, fmt.Errorf("section write failed: %w", err)
+	}
+	if err := output.WriteMenuEncoded(opts.OutputDir, nodes, textEncoding(opts)); err != nil {
+		return nil, fmt.Errorf("menu write failed: %w", err)
+	}
+	if _, err := output.WriteNavMarkdown(opts.OutputDir, nodes, textEncoding(opts)); err != nil {
+		return nil, fmt.Errorf("nav markdown write failed: %w", err)
 	}
-	return nil
+	return files, nil
 }
@@ -0,0 +1,245 @@
+// Package attribution detects license, terms and copyright notices on
+// scraped pages and writes them to ATTRIBUTION.md.
+package attribution
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+
+	"go_scrap/internal/fsutil"
+)
+
+const (
+	maxLinks     = 5
+	maxNotices   = 3
+	maxNoticeLen = 200
+)
+
+// Page is the attribution information found on one page.
+type Page struct {
+	URL          string
+	AccessedAt   time.Time
+	License      string
+	LicenseLinks []string
+	TermsLinks   []string
+	Copyright    []string
+}
+
+var (
+	licenseLinkRe = regexp.MustCompile(`(?i)\blicen[cs]e\b|creativecommons\.org/(licenses|publicdomain)`)
+	termsLinkRe   = regexp.MustCompile(`(?i)\b(terms|tos|legal|copyright)\b`)
+	copyrightRe   = regexp.MustCompile(`(?i)©|\(c\)\s*\d{4}|\bcopyright\b`)
+	ccPathRe      = regexp.MustCompile(`creativecommons\.org/(licenses|publicdomain)/([a-z-]+)/(\d\.\d)`)
+	licenseNameRe = regexp.MustCompile(`(?i)\b(MIT License|Apache License,? (?:Version )?2\.0|BSD [23]-Clause(?: License)?|GNU (?:Free Documentation|General Public) License(?: v?\d(?:\.\d)?)?|CC[ -]BY(?:[ -](?:SA|NC|ND))*[ -]\d\.\d|CC0(?: 1\.0)?)`)
+)
+
+// footerSelector is where sites usually put legal notices; license names are
+// only trusted there so docs that merely mention a license are not misread.
+const footerSelector = `footer, [role="contentinfo"], .footer, #footer`
+
+// Detect inspects the full page (before exclusions) for license and terms
+// links, a license name, and copyright notices.
+func Detect(doc *goquery.Document, pageURL string, accessedAt time.Time) Page {
+	page := Page{URL: pageURL, AccessedAt: accessedAt.UTC()}
+	if doc == nil {
+		return page
+	}
+	base, _ := url.Parse(pageURL)
+
+	var relLicense []string
+	doc.Find(`link[rel~="license"], a[rel~="license"]`).Each(func(_ int, s *goquery.Selection) {
+		if href := resolve(base, s.AttrOr("href", "")); href != "" {
+			relLicense = append(relLicense, href)
+		}
+	})
+	page.LicenseLinks = relLicense
+
+	doc.Find("a[href]").Each(func(_ int, s *goquery.Selection) {
+		raw := strings.TrimSpace(s.AttrOr("href", ""))
+		if strings.HasPrefix(raw, "#") {
+			return
+		}
+		href := resolve(base, raw)
+		if href == "" {
+			return
+		}
+		text := strings.TrimSpace(s.Text())
+		switch {
+		case licenseLinkRe.MatchString(text) || licenseLinkRe.MatchString(href):
+			page.LicenseLinks = append(page.LicenseLinks, href)
+		case termsLinkRe.MatchString(text) || termsLinkRe.MatchString(pathOf(href)):
+			page.TermsLinks = append(page.TermsLinks, href)
+		}
+	})
+	page.LicenseLinks = limit(dedupe(page.LicenseLinks), maxLinks)
+	page.TermsLinks = limit(dedupe(page.TermsLinks), maxLinks)
+
+	page.License = licenseName(doc, page.LicenseLinks)
+	page.Copyright = copyrightNotices(doc)
+	return page
+}
+
+func licenseName(doc *goquery.Document, links []string) string {
+	for _, link := range links {
+		if m := ccPathRe.FindStringSubmatch(strings.ToLower(link)); m != nil {
+			if m[1] == "publicdomain" {
+				return "CC0 " + m[3]
+			}
+			return "CC " + strings.ToUpper(m[2]) + " " + m[3]
+		}
+	}
+	var name string
+	doc.Find(footerSelector).EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		name = licenseNameRe.FindString(normalizeSpace(s.Text()))
+		return name == ""
+	})
+	return name
+}
+
+// copyrightNotices returns the text of the smallest block around each
+// copyright mark, preferring footers over the rest of the page.
+func copyrightNotices(doc *goquery.Document) []string {
+	scopes := doc.Find(footerSelector)
+	if scopes.Length() == 0 {
+		scopes = doc.Find("body")
+	}
+	var notices []string
+	scopes.Each(func(_ int, scope *goquery.Selection) {
+		for _, n := range scope.Nodes {
+			walkText(n, func(t *html.Node) {
+				if !copyrightRe.MatchString(t.Data) {
+					return
+				}
+				block := t.Parent
+				for block != nil && isInline(block.Data) && block.Parent != nil {
+					block = block.Parent
+				}
+				if block == nil {
+					return
+				}
+				text := normalizeSpace(goquery.NewDocumentFromNode(block).Text())
+				if text == "" {
+					return
+				}
+				if runes := []rune(text); len(runes) > maxNoticeLen {
+					text = strings.TrimSpace(string(runes[:maxNoticeLen])) + "…"
+				}
+				notices = append(notices, text)
+			})
+		}
+	})
+	return limit(dedupe(notices), maxNotices)
+}
+
+func walkText(n *html.Node, fn func(*html.Node)) {
+	if n.Type == html.ElementNode && (n.Data == "script" || n.Data == "style") {
+		return
+	}
+	if n.Type == html.TextNode {
+		fn(n)
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walkText(c, fn)
+	}
+}
+
+func isInline(tag string) bool {
+	switch tag {
+	case "a", "span", "small", "strong", "em", "b", "i", "time", "abbr":
+		return true
+	}
+	return false
+}
+
+// Write renders ATTRIBUTION.md in outDir with one section per page, sorted by
+// URL.
+func Write(outDir string, pages []Page) (string, error) {
+	if err := fsutil.MkdirAll(outDir, 0755); err != nil {
+		return "", err
+	}
+	sorted := append([]Page(nil), pages...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].URL < sorted[j].URL })
+
+	var b strings.Builder
+	b.WriteString("# Attribution\n\n")
+	b.WriteString("Content in this directory was scraped from the pages below. Check each source's license and terms before redistributing it.\n")
+	for _, p := range sorted {
+		fmt.Fprintf(&b, "\n## %s\n\n", p.URL)
+		fmt.Fprintf(&b, "- Accessed: %s\n", p.AccessedAt.Format(time.RFC3339))
+		if p.License != "" {
+			fmt.Fprintf(&b, "- License: %s\n", p.License)
+		} else {
+			b.WriteString("- License: not detected\n")
+		}
+		for _, link := range p.LicenseLinks {
+			fmt.Fprintf(&b, "- License link: %s\n", link)
+		}
+		for _, link := range p.TermsLinks {
+			fmt.Fprintf(&b, "- Terms: %s\n", link)
+		}
+		for _, notice := range p.Copyright {
+			fmt.Fprintf(&b, "- Copyright: %s\n", notice)
+		}
+	}
+
+	path := filepath.Join(outDir, "ATTRIBUTION.md")
+	if err := fsutil.WriteFile(path, []byte(b.String()), 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func resolve(base *url.URL, href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(strings.ToLower(href), "javascript:") || strings.HasPrefix(href, "mailto:") {
+		return ""
+	}
+	u, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	return u.String()
+}
+
+func pathOf(href string) string {
+	u, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+	return u.Path
+}
+
+func normalizeSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func dedupe(items []string) []string {
+	seen := make(map[string]struct{}, len(items))
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		if _, ok := seen[item]; ok {
+			continue
+		}
+		seen[item] = struct{}{}
+		out = append(out, item)
+	}
+	return out
+}
+
+func limit(items []string, n int) []string {
+	if len(items) > n {
+		return items[:n]
+	}
+	return items
+}
@@ -0,0 +1,74 @@
+package attribution
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestDetect_LicenseTermsAndCopyright(t *testing.T) {
+	page := `<html><head><link rel="license" href="https://creativecommons.org/licenses/by-sa/4.0/"></head><body>
+<main><p>Docs mention the MIT License here.</p><a href="#top">Top</a></main>
+<footer><p>© 2024 <a href="/">Acme Inc.</a> All rights reserved.</p>
+<a href="/legal/terms">Terms of Service</a> <a href="/LICENSE">License</a></footer>
+</body></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	accessed := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	got := Detect(doc, "https://docs.example.com/guide/", accessed)
+
+	if got.License != "CC BY-SA 4.0" {
+		t.Fatalf("unexpected license: %q", got.License)
+	}
+	if len(got.LicenseLinks) != 2 || got.LicenseLinks[1] != "https://docs.example.com/LICENSE" {
+		t.Fatalf("unexpected license links: %v", got.LicenseLinks)
+	}
+	if len(got.TermsLinks) != 1 || got.TermsLinks[0] != "https://docs.example.com/legal/terms" {
+		t.Fatalf("unexpected terms links: %v", got.TermsLinks)
+	}
+	if len(got.Copyright) != 1 || got.Copyright[0] != "© 2024 Acme Inc. All rights reserved." {
+		t.Fatalf("unexpected copyright: %v", got.Copyright)
+	}
+}
+
+func TestDetect_LicenseNameOnlyFromFooter(t *testing.T) {
+	doc, _ := goquery.NewDocumentFromReader(strings.NewReader(`<body><p>Compare the MIT License and GPL.</p></body>`))
+	if got := Detect(doc, "https://example.com", time.Now()); got.License != "" {
+		t.Fatalf("license should not be read from body prose, got %q", got.License)
+	}
+	doc, _ = goquery.NewDocumentFromReader(strings.NewReader(`<body><div class="footer">Released under the Apache License 2.0</div></body>`))
+	if got := Detect(doc, "https://example.com", time.Now()); got.License != "Apache License 2.0" {
+		t.Fatalf("unexpected footer license: %q", got.License)
+	}
+}
+
+func TestWrite_SortsPages(t *testing.T) {
+	dir := t.TempDir()
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	path, err := Write(dir, []Page{
+		{URL: "https://b.example", AccessedAt: at},
+		{URL: "https://a.example", AccessedAt: at, License: "MIT License", Copyright: []string{"© Acme"}},
+	})
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if path != filepath.Join(dir, "ATTRIBUTION.md") {
+		t.Fatalf("unexpected path %s", path)
+	}
+	data, _ := os.ReadFile(path)
+	out := string(data)
+	if strings.Index(out, "## https://a.example") > strings.Index(out, "## https://b.example") {
+		t.Fatalf("pages not sorted:\n%s", out)
+	}
+	for _, want := range []string{"- Accessed: 2026-01-02T03:04:05Z", "- License: MIT License", "- Copyright: © Acme", "- License: not detected"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+// Package byline finds who wrote and contributed to a page, so corpora built
+// from blogs and knowledge bases keep attribution for citations.
+package byline
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Person is an author or contributor; URL is their profile page, if linked.
+type Person struct {
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+// Page holds a page's authors and contributors (editors, reviewers).
+type Page struct {
+	Authors      []Person
+	Contributors []Person
+}
+
+// AuthorNames returns the authors' names.
+func (p Page) AuthorNames() []string {
+	return Names(p.Authors)
+}
+
+// Names returns the names of people.
+func Names(people []Person) []string {
+	if len(people) == 0 {
+		return nil
+	}
+	names := make([]string, len(people))
+	for i, person := range people {
+		names[i] = person.Name
+	}
+	return names
+}
+
+// Resolve makes profile URLs absolute against pageURL.
+func (p Page) Resolve(pageURL string) Page {
+	base, err := url.Parse(pageURL)
+	if err != nil || pageURL == "" {
+		return p
+	}
+	resolve := func(people []Person) []Person {
+		out := make([]Person, len(people))
+		for i, person := range people {
+			out[i] = person
+			if ref, err := url.Parse(person.URL); err == nil && person.URL != "" {
+				out[i].URL = base.ResolveReference(ref).String()
+			}
+		}
+		return out
+	}
+	return Page{Authors: resolve(p.Authors), Contributors: resolve(p.Contributors)}
+}
+
+var (
+	authorMeta = []string{
+		`meta[name="author"]`,
+		`meta[name="DC.creator"]`,
+		`meta[name="dcterms.creator"]`,
+		`meta[property="article:author"]`,
+	}
+	contributorMeta = []string{
+		`meta[name="DC.contributor"]`,
+		`meta[name="dcterms.contributor"]`,
+	}
+	// bylineSelector matches visible bylines, used when a page has no
+	// structured author data, and inside items.
+	bylineSelector = `[itemprop="author"], [rel~="author"], .byline, .author, .post-author, .entry-author`
+
+	byPrefixRe = regexp.MustCompile(`(?i)^(written\s+|posted\s+)?by\s+`)
+	// bylineTailRe cuts what follows the names in "By Jane on May 1, 2024"
+	// or "Jane · 5 min read".
+	bylineTailRe = regexp.MustCompile(`(?i)\s+(?:on|at|updated|published)\s.*$|\s*[|·•—–]\s*.*$`)
+	nameSepRe    = regexp.MustCompile(`\s*(?:,|&|\band\b)\s*`)
+)
+
+// maxNameLen and maxNameWords keep a byline selector that matched a bio or
+// paragraph from becoming an author.
+const (
+	maxNameLen   = 80
+	maxNameWords = 5
+)
+
+// FromDocument reads authors from, in order of preference, JSON-LD
+// author/contributor/editor, meta tags (author, DC.creator, article:author)
+// and rel="author" links; visible bylines are used only when none of those
+// name an author.
+func FromDocument(doc *goquery.Document) Page {
+	var p Page
+	if doc == nil {
+		return p
+	}
+	doc.Find(`script[type="application/ld+json"]`).Each(func(_ int, s *goquery.Selection) {
+		authors, contributors := jsonLDPeople(s.Text())
+		p.Authors = merge(p.Authors, authors...)
+		p.Contributors = merge(p.Contributors, contributors...)
+	})
+	for _, sel := range authorMeta {
+		doc.Find(sel).Each(func(_ int, s *goquery.Selection) {
+			p.Authors = mergeValue(p.Authors, s.AttrOr("content", ""))
+		})
+	}
+	for _, sel := range contributorMeta {
+		doc.Find(sel).Each(func(_ int, s *goquery.Selection) {
+			p.Contributors = mergeValue(p.Contributors, s.AttrOr("content", ""))
+		})
+	}
+	doc.Find(`link[rel~="author"], a[rel~="author"]`).Each(func(_ int, s *goquery.Selection) {
+		href := strings.TrimSpace(s.AttrOr("href", ""))
+		if name := cleanName(s.Text()); name != "" {
+			p.Authors = merge(p.Authors, Person{Name: name, URL: href})
+		} else {
+			p.Authors = attachURL(p.Authors, href)
+		}
+	})
+	if len(named(p.Authors)) == 0 {
+		p.Authors = merge(p.Authors, FromSelection(doc.Selection)...)
+	}
+	p.Authors = named(p.Authors)
+	p.Contributors = named(p.Contributors)
+	return p
+}
+
+// FromSelection reads the visible bylines inside sel, such as the author of
+// one forum post.
+func FromSelection(sel *goquery.Selection) []Person {
+	var people []Person
+	sel.Find(bylineSelector).Each(func(_ int, s *goquery.Selection) {
+		href := s.AttrOr("href", "")
+		if href == "" {
+			href = s.Find("a[href]").First().AttrOr("href", "")
+		}
+		text := strings.Join(strings.Fields(s.Text()), " ")
+		if text == "" || len(text) > maxNameLen {
+			return
+		}
+		names := splitNames(text)
+		for i, name := range names {
+			person := Person{Name: name}
+			if len(names) == 1 && i == 0 {
+				person.URL = strings.TrimSpace(href)
+			}
+			people = merge(people, person)
+		}
+	})
+	return named(people)
+}
+
+// mergeValue adds a meta tag value, which is either a name or a profile URL
+// (article:author). A URL is attached to the first author without one.
+func mergeValue(people []Person, value string) []Person {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return people
+	}
+	if isURL(value) {
+		return attachURL(people, value)
+	}
+	for _, name := range splitNames(value) {
+		people = merge(people, Person{Name: name})
+	}
+	return people
+}
+
+// attachURL gives link to the first person without a URL, or records it on
+// its own until a name turns up.
+func attachURL(people []Person, link string) []Person {
+	if link == "" {
+		return people
+	}
+	for i := range people {
+		if people[i].URL == link {
+			return people
+		}
+	}
+	for i := range people {
+		if people[i].URL == "" {
+			people[i].URL = link
+			return people
+		}
+	}
+	return append(people, Person{URL: link})
+}
+
+// merge adds people not already present (by name, case-insensitively, or by
+// URL), filling in a missing name or URL of an existing entry.
+func merge(people []Person, add ...Person) []Person {
+	for _, person := range add {
+		person.Name = cleanName(person.Name)
+		if person.Name == "" && person.URL == "" {
+			continue
+		}
+		found := false
+		for i := range people {
+			sameName := person.Name != "" && strings.EqualFold(people[i].Name, person.Name)
+			sameURL := person.URL != "" && people[i].URL == person.URL
+			if !sameName && !sameURL {
+				continue
+			}
+			if people[i].Name == "" {
+				people[i].Name = person.Name
+			}
+			if people[i].URL == "" {
+				people[i].URL = person.URL
+			}
+			found = true
+			break
+		}
+		if !found {
+			people = append(people, person)
+		}
+	}
+	return people
+}
+
+// named drops entries that only have a URL.
+func named(people []Person) []Person {
+	out := people[:0:0]
+	for _, person := range people {
+		if person.Name != "" {
+			out = append(out, person)
+		}
+	}
+	return out
+}
+
+func cleanName(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	s = byPrefixRe.ReplaceAllString(s, "")
+	if len(s) > maxNameLen || len(strings.Fields(s)) > maxNameWords || isURL(s) || !strings.ContainsFunc(s, unicode.IsLetter) {
+		return ""
+	}
+	return strings.TrimSpace(s)
+}
+
+func splitNames(s string) []string {
+	s = byPrefixRe.ReplaceAllString(strings.TrimSpace(s), "")
+	s = bylineTailRe.ReplaceAllString(s, "")
+	var names []string
+	for _, part := range nameSepRe.Split(s, -1) {
+		if len(strings.Fields(part)) > maxNameWords {
+			// Prose, not a byline.
+			return nil
+		}
+		if name := cleanName(part); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// jsonLDPeople returns the authors and contributors (including editors)
+// named in a JSON-LD block, resolving {"@id": ...} references within it.
+func jsonLDPeople(text string) (authors, contributors []Person) {
+	var v any
+	if err := json.Unmarshal([]byte(text), &v); err != nil {
+		return nil, nil
+	}
+	byID := map[string]map[string]any{}
+	var index func(any)
+	index = func(v any) {
+		switch node := v.(type) {
+		case map[string]any:
+			if id, ok := node["@id"].(string); ok && node["name"] != nil {
+				byID[id] = node
+			}
+			for _, child := range node {
+				index(child)
+			}
+		case []any:
+			for _, child := range node {
+				index(child)
+			}
+		}
+	}
+	index(v)
+
+	var walk func(any)
+	walk = func(v any) {
+		switch node := v.(type) {
+		case map[string]any:
+			authors = merge(authors, jsonLDPersons(node["author"], byID)...)
+			authors = merge(authors, jsonLDPersons(node["creator"], byID)...)
+			contributors = merge(contributors, jsonLDPersons(node["contributor"], byID)...)
+			contributors = merge(contributors, jsonLDPersons(node["editor"], byID)...)
+			for key, child := range node {
+				switch key {
+				case "author", "creator", "contributor", "editor":
+					continue
+				}
+				walk(child)
+			}
+		case []any:
+			for _, child := range node {
+				walk(child)
+			}
+		}
+	}
+	walk(v)
+	return authors, contributors
+}
+
+// jsonLDPersons reads a JSON-LD person value: a name, a Person or
+// Organization object, an @id reference, or a list of those.
+func jsonLDPersons(v any, byID map[string]map[string]any) []Person {
+	switch node := v.(type) {
+	case string:
+		if isURL(node) {
+			return []Person{{URL: node}}
+		}
+		return []Person{{Name: node}}
+	case map[string]any:
+		if _, hasName := node["name"]; !hasName {
+			if id, ok := node["@id"].(string); ok && byID[id] != nil {
+				node = byID[id]
+			}
+		}
+		name, _ := node["name"].(string)
+		link, _ := node["url"].(string)
+		if link == "" {
+			if id, ok := node["@id"].(string); ok && isURL(id) && !strings.Contains(id, "#") {
+				link = id
+			}
+		}
+		return []Person{{Name: name, URL: link}}
+	case []any:
+		var people []Person
+		for _, child := range node {
+			people = append(people, jsonLDPersons(child, byID)...)
+		}
+		return people
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package byline
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func doc(t *testing.T, html string) *goquery.Document {
+	t.Helper()
+	d, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestFromDocument_StructuredSources(t *testing.T) {
+	d := doc(t, `<html><head>
+<script type="application/ld+json">{"@graph":[
+  {"@type":"Article","author":{"@id":"#jane"},"editor":{"@type":"Person","name":"Sam Lee"}},
+  {"@type":"Person","@id":"#jane","name":"Jane Doe","url":"https://example.com/jane"}
+]}</script>
+<meta name="author" content="jane doe, Bob Smith">
+<meta name="DC.contributor" content="Ana Ruiz">
+<link rel="author" href="/people/bob">
+</head><body><p class="byline">By Someone Else</p></body></html>`)
+
+	p := FromDocument(d)
+	want := []Person{
+		{Name: "Jane Doe", URL: "https://example.com/jane"},
+		{Name: "Bob Smith", URL: "/people/bob"},
+	}
+	if !reflect.DeepEqual(p.Authors, want) {
+		t.Fatalf("authors: got %+v, want %+v", p.Authors, want)
+	}
+	if names := Names(p.Contributors); !reflect.DeepEqual(names, []string{"Sam Lee", "Ana Ruiz"}) {
+		t.Fatalf("contributors: got %v", names)
+	}
+	if got := p.Resolve("https://example.com/blog/post").Authors[1].URL; got != "https://example.com/people/bob" {
+		t.Fatalf("expected the profile URL to be resolved, got %s", got)
+	}
+}
+
+func TestFromDocument_VisibleBylineFallback(t *testing.T) {
+	d := doc(t, `<html><body><article>
+<div class="byline">Posted by <a href="/u/ana">Ana Ruiz</a> and Li Wei on May 1, 2024</div>
+<div class="author"><img src="a.png"><p>Ana has written about distributed systems for a decade and likes long bios.</p></div>
+</article></body></html>`)
+
+	if names := FromDocument(d).AuthorNames(); !reflect.DeepEqual(names, []string{"Ana Ruiz", "Li Wei"}) {
+		t.Fatalf("got %v", names)
+	}
+}
+
+func TestFromSelection_ItemAuthor(t *testing.T) {
+	d := doc(t, `<div class="post"><span itemprop="author"><a href="/u/kim">kim_42</a></span><p>Reply.</p></div>`)
+
+	got := FromSelection(d.Find(".post"))
+	if !reflect.DeepEqual(got, []Person{{Name: "kim_42", URL: "/u/kim"}}) {
+		t.Fatalf("got %+v", got)
+	}
+}
@@ -0,0 +1,308 @@
+// Package cache is the --cache HTML cache: each fetched page is stored as
+// <hash>.html with its metadata (URL, fetch time, HTTP status, ETag,
+// Last-Modified, fetch mode) in <hash>.json, expires after --cache-ttl, and
+// is evicted least recently used first beyond --cache-max-mb. Expired
+// entries with an ETag or Last-Modified are revalidated with a conditional
+// fetch rather than downloaded again.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go_scrap/internal/fetch"
+	"go_scrap/internal/fsutil"
+	"go_scrap/internal/seal"
+)
+
+// DirEnv overrides the default cache directory, e.g. to point a container
+// at a mounted volume.
+const DirEnv = "GO_SCRAP_CACHE_DIR"
+
+// DefaultDir is where --cache keeps fetched HTML: $GO_SCRAP_CACHE_DIR, or
+// go_scrap/html under the OS cache dir ($XDG_CACHE_HOME or ~/.cache on
+// Linux, ~/Library/Caches on macOS, %LocalAppData% on Windows). Without a
+// home directory it falls back to artifacts/cache.
+func DefaultDir() string {
+	if dir := strings.TrimSpace(os.Getenv(DirEnv)); dir != "" {
+		return dir
+	}
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join("artifacts", "cache")
+	}
+	return filepath.Join(base, "go_scrap", "html")
+}
+
+// ProxyDir is where the proxy subcommand keeps its cache by default: proxy
+// under $GO_SCRAP_CACHE_DIR, or go_scrap/proxy under the OS cache dir. It is
+// kept apart from DefaultDir, where runs also cache pages fetched with their
+// headers, cookies or login, so the proxy never serves those to its clients.
+func ProxyDir() string {
+	if dir := strings.TrimSpace(os.Getenv(DirEnv)); dir != "" {
+		return filepath.Join(dir, "proxy")
+	}
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join("artifacts", "cache-proxy")
+	}
+	return filepath.Join(base, "go_scrap", "proxy")
+}
+
+// Path is the cache file for urlStr in dir (DefaultDir when empty).
+func Path(dir, urlStr string) string {
+	if dir == "" {
+		dir = DefaultDir()
+	}
+	h := sha256.Sum256([]byte(urlStr))
+	name := hex.EncodeToString(h[:]) + ".html"
+	return filepath.Join(dir, name)
+}
+
+// Entry describes one cached page. It is stored next to the page's HTML as
+// <hash>.json; entries written before metadata existed have only Path, Size
+// and LastUsed.
+type Entry struct {
+	URL       string     `json:"url"`
+	Mode      fetch.Mode `json:"mode,omitempty"`
+	FetchedAt time.Time  `json:"fetched_at"`
+	// Status, ETag and LastModified come from the HTTP response of a static
+	// fetch; they are empty for browser fetches.
+	Status       int    `json:"status,omitempty"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	// Path is the HTML file, Size the bytes of HTML plus metadata, and
+	// LastUsed the HTML file's modification time, which cache hits bump.
+	Path      string    `json:"-"`
+	Size      int64     `json:"-"`
+	LastUsed  time.Time `json:"-"`
+	Encrypted bool      `json:"-"`
+}
+
+// Expired reports whether e was fetched more than ttl before now; a ttl of
+// 0 never expires.
+func (e Entry) Expired(ttl time.Duration, now time.Time) bool {
+	return ttl > 0 && now.Sub(e.FetchedAt) > ttl
+}
+
+var (
+	// ErrSealed is returned when an encrypted entry is read or replaced
+	// without a key.
+	ErrSealed = errors.New("cache entry is encrypted (use --encrypt-cache with its key)")
+	// ErrExpired is returned by Load for an entry older than its TTL.
+	ErrExpired = errors.New("cache entry expired")
+)
+
+// Save stores the HTML of a fetch for pageURL at path, with its metadata
+// alongside. A non-nil s encrypts the HTML; without one, an encrypted entry
+// is left in place rather than replaced by plaintext.
+func Save(path, pageURL string, result fetch.Result, s *seal.Sealer) error {
+	if s == nil && entrySealed(path) {
+		return ErrSealed
+	}
+	if err := fsutil.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := s.Seal([]byte(result.HTML))
+	if err != nil {
+		return err
+	}
+	if err := fsutil.WriteFile(path, data, 0600); err != nil {
+		return err
+	}
+	return writeMeta(path, Entry{
+		URL:          pageURL,
+		Mode:         result.FinalMode,
+		FetchedAt:    time.Now().UTC(),
+		Status:       result.Status,
+		ETag:         result.ETag,
+		LastModified: result.LastModified,
+	})
+}
+
+// Revalidated records that the server confirmed the entry at path is
+// current (a 304 answer to a conditional fetch): its fetch time restarts its
+// TTL, and validators sent with the 304 replace the stored ones.
+func Revalidated(path string, result fetch.Result) error {
+	entry, err := Stat(path)
+	if err != nil {
+		return err
+	}
+	entry.FetchedAt = time.Now().UTC()
+	if result.ETag != "" {
+		entry.ETag = result.ETag
+	}
+	if result.LastModified != "" {
+		entry.LastModified = result.LastModified
+	}
+	return writeMeta(path, entry)
+}
+
+func writeMeta(path string, entry Entry) error {
+	meta, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFile(metaPath(path), meta, 0600)
+}
+
+// Load reads a page saved by Save and marks it as recently used for Prune.
+// An entry fetched more than ttl ago (0 = never) is ErrExpired. Encrypted
+// entries need s.
+func Load(path string, ttl time.Duration, s *seal.Sealer) (string, error) {
+	if ttl > 0 {
+		entry, err := Stat(path)
+		if err != nil {
+			return "", err
+		}
+		if entry.Expired(ttl, time.Now()) {
+			return "", ErrExpired
+		}
+	}
+	data, err := fsutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if seal.Sealed(data) && s == nil {
+		return "", ErrSealed
+	}
+	if data, err = s.Open(data); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return string(data), nil
+}
+
+// Stat returns the entry for the HTML file at path, falling back to its
+// modification time as the fetch time when it has no metadata.
+func Stat(path string) (Entry, error) {
+	info, err := os.Stat(fsutil.LongPath(path))
+	if err != nil {
+		return Entry{}, err
+	}
+	entry := Entry{}
+	if meta, err := os.ReadFile(fsutil.LongPath(metaPath(path))); err == nil {
+		_ = json.Unmarshal(meta, &entry)
+		entry.Size += int64(len(meta))
+	}
+	entry.Path = path
+	entry.Size += info.Size()
+	entry.LastUsed = info.ModTime()
+	entry.Encrypted = entrySealed(path)
+	if entry.FetchedAt.IsZero() {
+		entry.FetchedAt = info.ModTime()
+	}
+	return entry, nil
+}
+
+func entrySealed(path string) bool {
+	f, err := os.Open(fsutil.LongPath(path))
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	head := make([]byte, 64)
+	n, _ := f.Read(head)
+	return seal.Sealed(head[:n])
+}
+
+func metaPath(htmlPath string) string {
+	return strings.TrimSuffix(htmlPath, ".html") + ".json"
+}
+
+// List returns the entries in dir (DefaultDir when empty), most recently
+// used first. A missing directory is an empty cache.
+func List(dir string) ([]Entry, error) {
+	if dir == "" {
+		dir = DefaultDir()
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0, len(paths))
+	for _, path := range paths {
+		entry, err := Stat(path)
+		if err != nil {
+			continue // evicted by another process
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LastUsed.After(entries[j].LastUsed)
+	})
+	return entries, nil
+}
+
+// Remove deletes an entry's HTML and metadata.
+func Remove(e Entry) error {
+	if err := os.Remove(fsutil.LongPath(e.Path)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.Remove(fsutil.LongPath(metaPath(e.Path))); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Prune removes least recently used entries from dir until the cache is at
+// most maxBytes (0 = unlimited). It returns how many entries were removed
+// and how many bytes that freed.
+func Prune(dir string, maxBytes int64) (int, int64, error) {
+	if maxBytes <= 0 {
+		return 0, 0, nil
+	}
+	entries, err := List(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+	var total int64
+	for _, e := range entries {
+		total += e.Size
+	}
+	removed, freed := 0, int64(0)
+	for i := len(entries) - 1; i >= 0 && total > maxBytes; i-- {
+		e := entries[i]
+		if err := Remove(e); err != nil {
+			return removed, freed, err
+		}
+		total -= e.Size
+		freed += e.Size
+		removed++
+	}
+	return removed, freed, nil
+}
+
+// PruneExpired removes the entries in dir fetched more than ttl before now.
+func PruneExpired(dir string, ttl time.Duration, now time.Time) (int, int64, error) {
+	return RemoveIf(dir, func(e Entry) bool { return e.Expired(ttl, now) })
+}
+
+// RemoveIf removes the entries in dir for which match returns true, such as
+// every entry (clear) or one URL's.
+func RemoveIf(dir string, match func(Entry) bool) (int, int64, error) {
+	entries, err := List(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+	removed, freed := 0, int64(0)
+	for _, e := range entries {
+		if !match(e) {
+			continue
+		}
+		if err := Remove(e); err != nil {
+			return removed, freed, err
+		}
+		freed += e.Size
+		removed++
+	}
+	return removed, freed, nil
+}
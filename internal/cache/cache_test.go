@@ -0,0 +1,187 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"go_scrap/internal/fetch"
+	"go_scrap/internal/seal"
+)
+
+func TestPath(t *testing.T) {
+	dir := t.TempDir()
+	path := Path(dir, "https://example.com/docs")
+	if filepath.Dir(path) != dir {
+		t.Fatalf("unexpected cache dir: %s", filepath.Dir(path))
+	}
+	if !strings.HasSuffix(path, ".html") {
+		t.Fatalf("expected html cache file, got %s", path)
+	}
+}
+
+func TestPath_DefaultsToEnvThenUserCacheDir(t *testing.T) {
+	t.Setenv(DirEnv, "/tmp/go_scrap-cache")
+	if got := filepath.Dir(Path("", "https://example.com")); got != "/tmp/go_scrap-cache" {
+		t.Fatalf("expected %s to win, got %s", DirEnv, got)
+	}
+
+	t.Setenv(DirEnv, "")
+	t.Setenv("XDG_CACHE_HOME", "/tmp/xdg-cache")
+	t.Setenv("HOME", "/tmp/home")
+	want := filepath.Join("/tmp/xdg-cache", "go_scrap", "html")
+	if runtime.GOOS != "linux" {
+		t.Skip("XDG_CACHE_HOME only applies on Linux")
+	}
+	if got := DefaultDir(); got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
+
+func TestSave(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "nested", "cache.html")
+	content := "<html>cache</html>"
+
+	if err := Save(path, "https://example.com", fetch.Result{HTML: content, FinalMode: fetch.ModeStatic}, nil); err != nil {
+		t.Fatalf("save cache failed: %v", err)
+	}
+	got, err := Load(path, 0, nil)
+	if err != nil {
+		t.Fatalf("read cache failed: %v", err)
+	}
+	if got != content {
+		t.Fatalf("unexpected content: %s", got)
+	}
+	entries, err := List(filepath.Dir(path))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected one entry, got %v (%v)", entries, err)
+	}
+	if e := entries[0]; e.URL != "https://example.com" || e.Mode != fetch.ModeStatic || e.FetchedAt.IsZero() || e.Size <= int64(len(content)) {
+		t.Fatalf("unexpected metadata: %+v", e)
+	}
+}
+
+func TestPrune_EvictsLeastRecentlyUsed(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Now().Add(-time.Hour)
+	for i, u := range []string{"https://a", "https://b", "https://c"} {
+		path := Path(dir, u)
+		if err := Save(path, u, fetch.Result{HTML: strings.Repeat("x", 1000)}, nil); err != nil {
+			t.Fatal(err)
+		}
+		stamp := base.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(path, stamp, stamp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A hit makes the oldest entry the most recently used.
+	if _, err := Load(Path(dir, "https://a"), 0, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, _ := List(dir)
+	removed, freed, err := Prune(dir, entries[0].Size+entries[1].Size)
+	if err != nil || removed != 1 || freed != entries[2].Size {
+		t.Fatalf("expected one entry evicted, got %d (%d bytes, %v)", removed, freed, err)
+	}
+	if _, err := os.Stat(Path(dir, "https://b")); !os.IsNotExist(err) {
+		t.Fatalf("expected the least recently used page to be evicted, got %v", err)
+	}
+	if _, err := os.Stat(metaPath(Path(dir, "https://b"))); !os.IsNotExist(err) {
+		t.Fatalf("expected its metadata to be removed too, got %v", err)
+	}
+	if left, _ := List(dir); len(left) != 2 || left[0].URL != "https://a" {
+		t.Fatalf("unexpected entries after prune: %+v", left)
+	}
+}
+
+func TestCache_EncryptsWithSealer(t *testing.T) {
+	s, err := seal.New([]byte(strings.Repeat("k", 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := Path(t.TempDir(), "https://example.com/private")
+	if err := Save(path, "https://example.com/private", fetch.Result{HTML: "<p>account 42</p>"}, s); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	raw, _ := os.ReadFile(path)
+	if strings.Contains(string(raw), "account 42") {
+		t.Fatal("expected the cached HTML to be encrypted")
+	}
+	if got, err := Load(path, 0, s); err != nil || got != "<p>account 42</p>" {
+		t.Fatalf("load: %q %v", got, err)
+	}
+	if _, err := Load(path, 0, nil); !errors.Is(err, ErrSealed) {
+		t.Fatalf("expected ErrSealed without a key, got %v", err)
+	}
+	if err := Save(path, "https://example.com/private", fetch.Result{HTML: "plain"}, nil); !errors.Is(err, ErrSealed) {
+		t.Fatalf("expected an encrypted entry not to be replaced by plaintext, got %v", err)
+	}
+}
+
+func TestLoad_ExpiresAfterTTL(t *testing.T) {
+	dir := t.TempDir()
+	path := Path(dir, "https://example.com")
+	if err := Save(path, "https://example.com", fetch.Result{HTML: "<p>hi</p>", Status: 200, ETag: `"v1"`}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Load(path, time.Hour, nil); err != nil || got != "<p>hi</p>" {
+		t.Fatalf("expected a fresh entry to load, got %q %v", got, err)
+	}
+	entries, _ := List(dir)
+	if len(entries) != 1 || entries[0].Status != 200 || entries[0].ETag != `"v1"` {
+		t.Fatalf("expected status and ETag in the metadata, got %+v", entries)
+	}
+
+	// Backdate the fetch time in the metadata.
+	meta := Entry{URL: "https://example.com", FetchedAt: time.Now().Add(-2 * time.Hour)}
+	data, _ := json.Marshal(meta)
+	if err := os.WriteFile(metaPath(path), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path, time.Hour, nil); !errors.Is(err, ErrExpired) {
+		t.Fatalf("expected ErrExpired, got %v", err)
+	}
+	if _, err := Load(path, 0, nil); err != nil {
+		t.Fatalf("expected a TTL of 0 never to expire, got %v", err)
+	}
+
+	removed, _, err := PruneExpired(dir, time.Hour, time.Now())
+	if err != nil || removed != 1 {
+		t.Fatalf("expected the expired entry pruned, got %d %v", removed, err)
+	}
+	if left, _ := List(dir); len(left) != 0 {
+		t.Fatalf("expected an empty cache, got %+v", left)
+	}
+}
+
+func TestRevalidated_RestartsTTLAndKeepsValidators(t *testing.T) {
+	path := Path(t.TempDir(), "https://example.com")
+	result := fetch.Result{HTML: "<p>hi</p>", Status: 200, ETag: `"v1"`, LastModified: "Mon, 02 Jan 2006 15:04:05 GMT"}
+	if err := Save(path, "https://example.com", result, nil); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, err := Load(path, time.Millisecond, nil); !errors.Is(err, ErrExpired) {
+		t.Fatalf("expected the entry to expire, got %v", err)
+	}
+	if err := Revalidated(path, fetch.Result{Status: 304}); err != nil {
+		t.Fatal(err)
+	}
+	entry, err := Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.ETag != `"v1"` || entry.LastModified != result.LastModified || entry.Status != 200 || entry.URL != "https://example.com" {
+		t.Fatalf("expected the stored metadata to be kept, got %+v", entry)
+	}
+	if got, err := Load(path, time.Hour, nil); err != nil || got != "<p>hi</p>" {
+		t.Fatalf("expected the revalidated entry to load, got %q %v", got, err)
+	}
+}
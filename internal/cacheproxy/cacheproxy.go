@@ -0,0 +1,290 @@
+// Package cacheproxy shares the --cache HTML cache over HTTP: Server answers
+// page requests from a cache directory, fetching and recording pages it does
+// not have, and Middleware points a run's static fetches, crawls and sitemap
+// requests at such a server. Teammates iterating on selectors against one
+// site then download each page once between them.
+package cacheproxy
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go_scrap/internal/cache"
+	"go_scrap/internal/fetch"
+)
+
+// CacheHeader tells clients whether a page came from the cache ("hit"), was
+// revalidated with the origin ("revalidated") or fetched from it ("miss").
+const CacheHeader = "X-Go-Scrap-Cache"
+
+// TokenHeader carries Server.Token for clients that can't add it to the
+// request URL as ?token=.
+const TokenHeader = "X-Go-Scrap-Token"
+
+// forwardedHeaders are passed on to the origin when a page is fetched.
+// Credentials are not: pages are cached by URL alone and served to anyone,
+// so a page fetched with one user's Authorization or Cookie would leak it.
+var forwardedHeaders = []string{"Accept", "Accept-Language"}
+
+// Server serves pages from the cache in Dir. A page is requested as
+// GET /fetch?url=<page URL>, or as GET <page URL> by clients configured to
+// use the server as an HTTP proxy; HTTPS through CONNECT is refused because
+// the tunnel cannot be cached.
+type Server struct {
+	// Dir is the cache directory (cache.ProxyDir when empty).
+	Dir string
+	// TTL expires pages fetched longer ago (0 = never); expired pages are
+	// revalidated with the origin.
+	TTL time.Duration
+	// Replay serves only cached pages and answers 504 for the others.
+	Replay bool
+	// Timeout bounds each origin fetch.
+	Timeout time.Duration
+	// Token, when set, must be sent with every request as ?token= or in
+	// TokenHeader; other requests are answered 401.
+	Token string
+
+	mu    sync.Mutex
+	locks map[string]*pageLock
+}
+
+// pageLock serializes the requests for one page; refs counts the requests
+// holding or waiting for it, so it can be dropped when the last is done.
+type pageLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		http.Error(w, "CONNECT is not supported: use the cache proxy with --cache-proxy, or request /fetch?url=", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "only GET and HEAD are supported", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authorized(r) {
+		http.Error(w, "missing or wrong cache proxy token", http.StatusUnauthorized)
+		return
+	}
+	pageURL, err := requestedURL(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	unlock := s.lock(pageURL)
+	html, state, err := s.page(r, pageURL)
+	unlock()
+	if err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, errNotRecorded) {
+			status = http.StatusGatewayTimeout
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	entry, _ := cache.Stat(cache.Path(s.dir(), pageURL))
+	if entry.Encrypted {
+		entry = cache.Entry{}
+	}
+	h := w.Header()
+	h.Set(CacheHeader, state)
+	h.Set("Content-Type", http.DetectContentType([]byte(html)))
+	if entry.ETag != "" {
+		h.Set("ETag", entry.ETag)
+	}
+	if entry.LastModified != "" {
+		h.Set("Last-Modified", entry.LastModified)
+	}
+	if match := r.Header.Get("If-None-Match"); match != "" && match == entry.ETag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+	_, _ = w.Write([]byte(html))
+}
+
+var errNotRecorded = errors.New("page not recorded in the cache")
+
+// page returns the HTML of pageURL and how it was obtained.
+func (s *Server) page(r *http.Request, pageURL string) (string, string, error) {
+	path := cache.Path(s.dir(), pageURL)
+	// Entries sealed with --encrypt-cache can't be read without their key;
+	// they are left alone and the page is fetched without being recorded.
+	sealed := false
+	if entry, err := cache.Stat(path); err == nil && entry.Encrypted {
+		sealed = true
+	}
+	err := cache.ErrSealed
+	if !sealed {
+		var html string
+		if html, err = cache.Load(path, s.TTL, nil); err == nil {
+			return html, "hit", nil
+		}
+	}
+	if s.Replay {
+		return "", "", fmt.Errorf("%w: %s", errNotRecorded, pageURL)
+	}
+
+	fetchOpts := fetch.Options{
+		URL:       pageURL,
+		Mode:      fetch.ModeStatic,
+		Timeout:   s.Timeout,
+		UserAgent: r.UserAgent(),
+		Headers:   map[string]string{},
+	}
+	for _, name := range forwardedHeaders {
+		if v := r.Header.Get(name); v != "" {
+			fetchOpts.Headers[name] = v
+		}
+	}
+	if errors.Is(err, cache.ErrExpired) {
+		if entry, err := cache.Stat(path); err == nil && !entry.Encrypted {
+			fetchOpts.IfNoneMatch = entry.ETag
+			fetchOpts.IfModifiedSince = entry.LastModified
+		}
+	}
+	ctx := r.Context()
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+	result, err := fetch.Fetch(ctx, fetchOpts)
+	if err != nil {
+		return "", "", err
+	}
+	if result.NotModified {
+		if html, err := cache.Load(path, 0, nil); err == nil {
+			_ = cache.Revalidated(path, result)
+			return html, "revalidated", nil
+		}
+		fetchOpts.IfNoneMatch, fetchOpts.IfModifiedSince = "", ""
+		if result, err = fetch.Fetch(ctx, fetchOpts); err != nil {
+			return "", "", err
+		}
+	}
+	if sealed {
+		return result.HTML, "miss", nil
+	}
+	if err := cache.Save(path, pageURL, result, nil); err != nil {
+		return "", "", fmt.Errorf("record %s: %w", pageURL, err)
+	}
+	return result.HTML, "miss", nil
+}
+
+func (s *Server) dir() string {
+	if s.Dir == "" {
+		return cache.ProxyDir()
+	}
+	return s.Dir
+}
+
+// authorized reports whether r carries the server's token, if it has one.
+func (s *Server) authorized(r *http.Request) bool {
+	if s.Token == "" {
+		return true
+	}
+	token := r.Header.Get(TokenHeader)
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) == 1
+}
+
+// lock serializes requests for one page, so that teammates asking for it at
+// the same time wait for a single origin fetch. The lock is forgotten once
+// no request holds it.
+func (s *Server) lock(pageURL string) func() {
+	s.mu.Lock()
+	if s.locks == nil {
+		s.locks = map[string]*pageLock{}
+	}
+	l, ok := s.locks[pageURL]
+	if !ok {
+		l = &pageLock{}
+		s.locks[pageURL] = l
+	}
+	l.refs++
+	s.mu.Unlock()
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		s.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(s.locks, pageURL)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// requestedURL is the page a request asks for, in either form.
+func requestedURL(r *http.Request) (string, error) {
+	raw := r.URL.String()
+	if !r.URL.IsAbs() {
+		if r.URL.Path != "/fetch" {
+			return "", errors.New("request /fetch?url=<page URL>")
+		}
+		raw = r.URL.Query().Get("url")
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid page url %q", raw)
+	}
+	u.Fragment = ""
+	return u.String(), nil
+}
+
+// Endpoint validates a --cache-proxy URL.
+func Endpoint(raw string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("cache proxy must be an http(s) URL, got %q", raw)
+	}
+	return u, nil
+}
+
+// Middleware sends GET requests through the cache proxy at endpoint, with
+// the token in the endpoint's ?token= if it has one. Requests carrying
+// Authorization or Cookie go straight to the origin, since the proxy would
+// share their responses with everyone. The response keeps the original
+// request, so redirects and relative links resolve against the page rather
+// than the proxy. Browser fetches are not affected.
+func Middleware(endpoint *url.URL) fetch.Middleware {
+	return fetch.Middleware{
+		Name: "cache-proxy",
+		RoundTrip: func(next http.RoundTripper) http.RoundTripper {
+			return fetch.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				if req.Method != http.MethodGet || req.Header.Get("Authorization") != "" || req.Header.Get("Cookie") != "" {
+					return next.RoundTrip(req)
+				}
+				target := *endpoint
+				target.Path = strings.TrimSuffix(target.Path, "/") + "/fetch"
+				query := endpoint.Query()
+				query.Set("url", req.URL.String())
+				target.RawQuery = query.Encode()
+				proxied := req.Clone(req.Context())
+				proxied.URL = &target
+				proxied.Host = ""
+				resp, err := next.RoundTrip(proxied)
+				if err != nil {
+					return resp, err
+				}
+				resp.Request = req
+				return resp, nil
+			})
+		},
+	}
+}
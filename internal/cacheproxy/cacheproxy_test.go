@@ -0,0 +1,214 @@
+package cacheproxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go_scrap/internal/cache"
+	"go_scrap/internal/fetch"
+	"go_scrap/internal/seal"
+)
+
+func TestServer_RecordsThenReplays(t *testing.T) {
+	var hits atomic.Int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.Header.Get("Accept-Language") != "de" {
+			t.Errorf("Accept-Language not forwarded: %q", r.Header.Get("Accept-Language"))
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte("<html><body><h1>Docs</h1></body></html>"))
+	}))
+	defer origin.Close()
+
+	dir := t.TempDir()
+	srv := &Server{Dir: dir, Timeout: 5 * time.Second}
+	get := func(s *Server, pageURL string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/fetch?url="+url.QueryEscape(pageURL), nil)
+		req.Header.Set("Accept-Language", "de")
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := get(srv, origin.URL+"/docs#intro")
+	second := get(srv, origin.URL+"/docs")
+	if first.Code != http.StatusOK || first.Header().Get(CacheHeader) != "miss" || second.Header().Get(CacheHeader) != "hit" {
+		t.Fatalf("got %d %s, then %s", first.Code, first.Header().Get(CacheHeader), second.Header().Get(CacheHeader))
+	}
+	if second.Body.String() != first.Body.String() || second.Header().Get("ETag") != `"v1"` {
+		t.Fatalf("unexpected cached response %q etag %q", second.Body.String(), second.Header().Get("ETag"))
+	}
+	if hits.Load() != 1 {
+		t.Fatalf("origin hit %d times, want 1", hits.Load())
+	}
+
+	replay := &Server{Dir: dir, Replay: true}
+	if rec := get(replay, origin.URL+"/docs"); rec.Code != http.StatusOK {
+		t.Fatalf("replay of a recorded page: %d", rec.Code)
+	}
+	if rec := get(replay, origin.URL+"/other"); rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("replay of an unrecorded page: %d, want 504", rec.Code)
+	}
+	if hits.Load() != 1 {
+		t.Fatalf("replay contacted the origin")
+	}
+}
+
+func TestServer_RejectsConnectAndBadURLs(t *testing.T) {
+	srv := &Server{Dir: t.TempDir()}
+	cases := map[*http.Request]int{
+		httptest.NewRequest(http.MethodConnect, "/", nil):                          http.StatusMethodNotAllowed,
+		httptest.NewRequest(http.MethodPost, "/fetch?url=http://example.com", nil): http.StatusMethodNotAllowed,
+		httptest.NewRequest(http.MethodGet, "/fetch?url=ftp://example.com", nil):   http.StatusBadRequest,
+		httptest.NewRequest(http.MethodGet, "/other", nil):                         http.StatusBadRequest,
+	}
+	for req, want := range cases {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("%s %s: got %d, want %d", req.Method, req.URL, rec.Code, want)
+		}
+	}
+}
+
+func TestMiddleware_FetchesThroughProxy(t *testing.T) {
+	var hits atomic.Int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		_, _ = w.Write([]byte("<html><body><p>page</p></body></html>"))
+	}))
+	defer origin.Close()
+	proxy := httptest.NewServer(&Server{Dir: t.TempDir(), Timeout: 5 * time.Second})
+	defer proxy.Close()
+
+	endpoint, err := Endpoint(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		result, err := fetch.Fetch(context.Background(), fetch.Options{
+			URL:        origin.URL + "/page",
+			Mode:       fetch.ModeStatic,
+			Timeout:    5 * time.Second,
+			Middleware: []fetch.Middleware{Middleware(endpoint)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.HTML != "<html><body><p>page</p></body></html>" {
+			t.Fatalf("unexpected html %q", result.HTML)
+		}
+	}
+	if hits.Load() != 1 {
+		t.Fatalf("origin hit %d times, want 1", hits.Load())
+	}
+	if _, err := Endpoint("localhost:8787"); err == nil {
+		t.Fatal("expected an error for a URL without scheme")
+	}
+}
+
+func TestServer_KeepsCredentialsOutOfTheSharedCache(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != "" {
+			t.Errorf("credentials forwarded to the origin: %q %q", r.Header.Get("Authorization"), r.Header.Get("Cookie"))
+		}
+		_, _ = w.Write([]byte("<html><body><p>public</p></body></html>"))
+	}))
+	defer origin.Close()
+
+	srv := &Server{Dir: t.TempDir(), Timeout: 5 * time.Second, Token: "s3cret"}
+	get := func(target string, header http.Header) int {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	page := "/fetch?url=" + url.QueryEscape(origin.URL+"/docs")
+	if code := get(page, nil); code != http.StatusUnauthorized {
+		t.Fatalf("request without token: %d, want 401", code)
+	}
+	creds := http.Header{"Authorization": {"Bearer alice"}, "Cookie": {"session=alice"}}
+	if code := get(page+"&token=s3cret", creds); code != http.StatusOK {
+		t.Fatalf("request with token: %d", code)
+	}
+	if code := get(page, http.Header{TokenHeader: {"s3cret"}}); code != http.StatusOK {
+		t.Fatalf("request with token header: %d", code)
+	}
+	if len(srv.locks) != 0 {
+		t.Fatalf("page locks kept after the requests: %d", len(srv.locks))
+	}
+}
+
+func TestMiddleware_SendsCredentialedRequestsToTheOrigin(t *testing.T) {
+	var proxied atomic.Int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<html><body><p>" + r.Header.Get("Authorization") + "</p></body></html>"))
+	}))
+	defer origin.Close()
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied.Add(1)
+		if r.URL.Query().Get("token") != "s3cret" {
+			t.Errorf("token not passed on: %s", r.URL)
+		}
+		(&Server{Dir: t.TempDir(), Timeout: 5 * time.Second}).ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+
+	endpoint, err := Endpoint(proxy.URL + "?token=s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, headers := range []map[string]string{{"Authorization": "Bearer alice"}, nil} {
+		if _, err := fetch.Fetch(context.Background(), fetch.Options{
+			URL:        origin.URL + "/page",
+			Mode:       fetch.ModeStatic,
+			Timeout:    5 * time.Second,
+			Headers:    headers,
+			Middleware: []fetch.Middleware{Middleware(endpoint)},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if proxied.Load() != 1 {
+		t.Fatalf("proxy asked %d times, want only the request without credentials", proxied.Load())
+	}
+}
+
+func TestServer_SkipsSealedEntries(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<html><body><h1>Public</h1></body></html>"))
+	}))
+	defer origin.Close()
+
+	dir := t.TempDir()
+	s, err := seal.New([]byte(strings.Repeat("k", 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := cache.Path(dir, origin.URL+"/docs")
+	if err := cache.Save(path, origin.URL+"/docs", fetch.Result{HTML: "<p>account 42</p>"}, s); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/fetch?url="+url.QueryEscape(origin.URL+"/docs"), nil)
+	rec := httptest.NewRecorder()
+	(&Server{Dir: dir, Timeout: 5 * time.Second}).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get(CacheHeader) != "miss" || !strings.Contains(rec.Body.String(), "Public") {
+		t.Fatalf("got %d %s %q", rec.Code, rec.Header().Get(CacheHeader), rec.Body.String())
+	}
+	raw, _ := os.ReadFile(path)
+	if !seal.Sealed(raw) {
+		t.Fatal("expected the sealed entry to be left alone")
+	}
+}
@@ -2,6 +2,7 @@ package cli
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -107,3 +108,35 @@ func (s *stringSliceFlag) Set(v string) error {
 	s.WasSet = true
 	return nil
 }
+
+// intListFlag holds a comma-separated list of integers.
+type intListFlag struct {
+	Values []int
+	WasSet bool
+}
+
+func (f *intListFlag) String() string {
+	parts := make([]string, len(f.Values))
+	for i, v := range f.Values {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (f *intListFlag) Set(v string) error {
+	var values []int
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", part)
+		}
+		values = append(values, n)
+	}
+	f.Values = values
+	f.WasSet = true
+	return nil
+}
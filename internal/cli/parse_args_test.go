@@ -1,9 +1,13 @@
 package cli
 
 import (
+	"io"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"go_scrap/internal/app"
 )
@@ -19,6 +23,7 @@ func TestParseArgs_UsesConfigDefaults(t *testing.T) {
   "user_agent": "ua",
   "wait_for": "body",
   "headless": false,
+  "browser": "Firefox",
   "nav_selector": ".nav",
   "content_selector": ".content",
   "exclude_selector": ".ads",
@@ -27,6 +32,9 @@ func TestParseArgs_UsesConfigDefaults(t *testing.T) {
   "max_markdown_bytes": 4096,
   "max_chars": 12000,
   "max_tokens": 3000,
+  "chunk_tokens": 512,
+  "chunk_overlap": 64,
+  "tokenizer": "o200k",
   "pipeline_hooks": ["strict-report", "exec"],
   "post_commands": ["echo hello"]
 }`), 0600); err != nil {
@@ -58,8 +66,8 @@ func assertCoreDefaults(t *testing.T, opts app.Options, initCfg bool) {
 	if opts.Timeout.Seconds() != 9 {
 		t.Fatalf("timeout not applied: %v", opts.Timeout)
 	}
-	if opts.Headless {
-		t.Fatalf("headless should be false from config")
+	if opts.Headless || opts.Browser != "firefox" {
+		t.Fatalf("headless/browser not applied: headless %v, browser %q", opts.Headless, opts.Browser)
 	}
 	if !opts.NavWalk || opts.RateLimitPerSecond != 1.5 {
 		t.Fatalf("nav/rate not applied: %+v", opts)
@@ -74,6 +82,9 @@ func assertLimitDefaults(t *testing.T, opts app.Options) {
 	if opts.MaxChars != 12000 || opts.MaxTokens != 3000 {
 		t.Fatalf("max chars/tokens not applied: %+v", opts)
 	}
+	if opts.ChunkTokens != 512 || opts.ChunkOverlap != 64 || opts.Tokenizer != "o200k" {
+		t.Fatalf("chunk tokens/overlap/tokenizer not applied: %+v", opts)
+	}
 }
 
 func assertPipelineDefaults(t *testing.T, opts app.Options) {
@@ -99,6 +110,40 @@ func TestParseArgs_InitConfigShortCircuit(t *testing.T) {
 	}
 }
 
+func TestParseArgs_HookTimeoutZeroMeansNoLimit(t *testing.T) {
+	opts, _, err := ParseArgs([]string{"--url", "https://example.com", "--hook-timeout", "0"})
+	if err != nil {
+		t.Fatalf("ParseArgs error: %v", err)
+	}
+	if opts.HookTimeout != 0 {
+		t.Fatalf("expected --hook-timeout 0 to disable the limit, got %s", opts.HookTimeout)
+	}
+	opts, _, err = ParseArgs([]string{"--url", "https://example.com"})
+	if err != nil {
+		t.Fatalf("ParseArgs error: %v", err)
+	}
+	if opts.HookTimeout != app.DefaultHookTimeoutSeconds*time.Second {
+		t.Fatalf("expected the default hook timeout, got %s", opts.HookTimeout)
+	}
+}
+
+func TestParseArgs_LeavesUnsetModeToPreset(t *testing.T) {
+	opts, _, err := ParseArgs([]string{"--url", "https://example.com", "--preset", "sphinx"})
+	if err != nil {
+		t.Fatalf("ParseArgs error: %v", err)
+	}
+	if opts.Mode != "" {
+		t.Fatalf("expected no mode without --mode, got %q", opts.Mode)
+	}
+	opts, _, err = ParseArgs([]string{"--url", "https://example.com", "--preset", "sphinx", "--mode", "auto"})
+	if err != nil {
+		t.Fatalf("ParseArgs error: %v", err)
+	}
+	if opts.Mode != "auto" {
+		t.Fatalf("expected explicit --mode auto, got %q", opts.Mode)
+	}
+}
+
 func TestParseArgs_ErrorOnMissingURL(t *testing.T) {
 	_, _, err := ParseArgs([]string{"--mode", "static"})
 	if err == nil {
@@ -108,3 +153,79 @@ func TestParseArgs_ErrorOnMissingURL(t *testing.T) {
 		t.Fatalf("expected ExitError code 2, got %#v", err)
 	}
 }
+
+func TestParseArgs_ReadsURLFileAndStdin(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "urls.txt")
+	if err := os.WriteFile(path, []byte("# release notes\nhttps://example.com/a\n\n  https://example.com/b  \n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	opts, _, err := ParseArgs([]string{"--url-file", path, "--yes"})
+	if err != nil {
+		t.Fatalf("ParseArgs error: %v", err)
+	}
+	if !reflect.DeepEqual(opts.URLs, []string{"https://example.com/a", "https://example.com/b"}) {
+		t.Fatalf("unexpected URLs %q", opts.URLs)
+	}
+
+	defer func(r io.Reader) { stdin = r }(stdin)
+	stdin = strings.NewReader("https://example.com/c\n")
+	opts, _, err = ParseArgs([]string{"--url-file", "-", "--yes"})
+	if err != nil {
+		t.Fatalf("ParseArgs error: %v", err)
+	}
+	if !reflect.DeepEqual(opts.URLs, []string{"https://example.com/c"}) {
+		t.Fatalf("unexpected URLs from stdin %q", opts.URLs)
+	}
+}
+
+func TestParseArgs_FindsConfigInConfigDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "site.json"), []byte(`{"url": "https://example.com", "cache_dir": "/var/cache/go_scrap"}`), 0600); err != nil {
+		t.Fatalf("write cfg: %v", err)
+	}
+
+	opts, _, err := ParseArgs([]string{"--config-dir", dir, "--config", "site.json", "--yes"})
+	if err != nil {
+		t.Fatalf("ParseArgs error: %v", err)
+	}
+	if opts.URL != "https://example.com" || opts.ConfigPath != filepath.Join(dir, "site.json") || opts.ConfigDir != dir {
+		t.Fatalf("expected site.json to load from --config-dir, got %+v", opts)
+	}
+	if opts.CacheDir != "/var/cache/go_scrap" {
+		t.Fatalf("expected cache_dir from config, got %q", opts.CacheDir)
+	}
+
+	t.Setenv("GO_SCRAP_CONFIG_DIR", dir)
+	opts, _, err = ParseArgs([]string{"--config", "site.json", "--cache-dir", "cache", "--yes"})
+	if err != nil {
+		t.Fatalf("ParseArgs error: %v", err)
+	}
+	if opts.ConfigPath != filepath.Join(dir, "site.json") || opts.CacheDir != "cache" {
+		t.Fatalf("expected GO_SCRAP_CONFIG_DIR lookup and --cache-dir override, got %+v", opts)
+	}
+}
+
+func TestParseArgs_ReadsLoginBlock(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "cfg.json")
+	if err := os.WriteFile(cfgPath, []byte(`{
+  "url": "https://example.com/docs",
+  "login": {
+    "url": " https://example.com/login ",
+    "username": "ada",
+    "username_selector": "#user",
+    "password_selector": "#pass",
+    "submit_selector": "button[type=submit]",
+    "success_selector": ".account"
+  }
+}`), 0600); err != nil {
+		t.Fatalf("write cfg: %v", err)
+	}
+
+	opts, _, err := ParseArgs([]string{"--config", cfgPath, "--yes"})
+	if err != nil {
+		t.Fatalf("ParseArgs error: %v", err)
+	}
+	if opts.Login == nil || opts.Login.URL != "https://example.com/login" || opts.Login.Username != "ada" || opts.Login.SuccessSelector != ".account" {
+		t.Fatalf("unexpected login %+v", opts.Login)
+	}
+}
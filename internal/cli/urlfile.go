@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// stdin is read by --url-file -; tests replace it.
+var stdin io.Reader = os.Stdin
+
+// readURLFile reads the URLs of --url-file, one per line, from path or from
+// stdin when path is "-". Blank lines and lines starting with # are skipped.
+func readURLFile(path string) ([]string, error) {
+	var r io.Reader = stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("url-file: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	var urls []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("url-file: %w", err)
+	}
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("url-file: no URLs in %s", path)
+	}
+	return urls, nil
+}
@@ -2,50 +2,160 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 )
 
 type Config struct {
-	URL                string            `json:"url"`
-	Mode               string            `json:"mode"`
-	OutputDir          string            `json:"output_dir"`
-	TimeoutSeconds     int               `json:"timeout_seconds"`
-	UserAgent          string            `json:"user_agent"`
-	WaitForSelector    string            `json:"wait_for"`
-	Headless           *bool             `json:"headless"`
-	NavSelector        string            `json:"nav_selector"`
-	ContentSelector    string            `json:"content_selector"`
-	ExcludeSelector    string            `json:"exclude_selector"`
-	NavWalk            bool              `json:"nav_walk"`
-	RateLimitPerSecond float64           `json:"rate_limit_per_second"`
-	MaxMarkdownBytes   int               `json:"max_markdown_bytes"`
-	MaxChars           int               `json:"max_chars"`
-	MaxTokens          int               `json:"max_tokens"`
-	ProxyURL           string            `json:"proxy_url"`
-	AuthHeaders        map[string]string `json:"auth_headers"`
-	AuthCookies        map[string]string `json:"auth_cookies"`
+	URL                 string            `json:"url"`
+	URLFile             string            `json:"url_file,omitempty"`
+	Mode                string            `json:"mode"`
+	PreferView          string            `json:"prefer_view,omitempty"`
+	OutputDir           string            `json:"output_dir"`
+	PublishDir          string            `json:"publish_dir,omitempty"`
+	TimeoutSeconds      int               `json:"timeout_seconds"`
+	UserAgent           string            `json:"user_agent"`
+	WaitForSelector     string            `json:"wait_for"`
+	Headless            *bool             `json:"headless"`
+	Browser             string            `json:"browser,omitempty"`
+	ScrollToBottom      bool              `json:"scroll_to_bottom,omitempty"`
+	ClickSelector       string            `json:"click_selector,omitempty"`
+	MaxScrolls          int               `json:"max_scrolls,omitempty"`
+	ScrollQuietMS       int               `json:"scroll_quiet_ms,omitempty"`
+	NavSelector         string            `json:"nav_selector"`
+	ContentSelector     string            `json:"content_selector"`
+	ExcludeSelector     string            `json:"exclude_selector"`
+	ItemSelector        string            `json:"item_selector,omitempty"`
+	ItemTitleSelector   string            `json:"item_title_selector,omitempty"`
+	ItemBodySelector    string            `json:"item_body_selector,omitempty"`
+	ItemDateSelector    string            `json:"item_date_selector,omitempty"`
+	Preset              string            `json:"preset,omitempty"`
+	Sanitize            string            `json:"sanitize,omitempty"`
+	NormalizeUnicode    bool              `json:"normalize_unicode,omitempty"`
+	Emoji               string            `json:"emoji,omitempty"`
+	Slug                string            `json:"slug,omitempty"`
+	SlugPattern         string            `json:"slug_pattern,omitempty"`
+	NavWalk             bool              `json:"nav_walk"`
+	RateLimitPerSecond  float64           `json:"rate_limit_per_second"`
+	RetryAttempts       int               `json:"retry_attempts,omitempty"`
+	RetryBaseDelayMS    int               `json:"retry_base_delay_ms,omitempty"`
+	RetryMaxDelayMS     int               `json:"retry_max_delay_ms,omitempty"`
+	RetryOn             []int             `json:"retry_on,omitempty"`
+	RetryJitter         float64           `json:"retry_jitter,omitempty"`
+	MaxMarkdownBytes    int               `json:"max_markdown_bytes"`
+	MaxChars            int               `json:"max_chars"`
+	MaxTokens           int               `json:"max_tokens"`
+	SplitByHeadingLevel int               `json:"split_by_heading_level,omitempty"`
+	SplitIndexTemplate  string            `json:"split_index_template,omitempty"`
+	ChunkTokens         int               `json:"chunk_tokens,omitempty"`
+	ChunkOverlap        int               `json:"chunk_overlap,omitempty"`
+	Tokenizer           string            `json:"tokenizer,omitempty"`
+	RenderConcurrency   int               `json:"render_concurrency,omitempty"`
+	ConvertCacheSize    int               `json:"convert_cache_size,omitempty"`
+	OmitContentText     bool              `json:"omit_content_text,omitempty"`
+	DropEmptySections   bool              `json:"drop_empty_sections,omitempty"`
+	IncludeHeadings     string            `json:"include_headings,omitempty"`
+	ExcludeHeadings     string            `json:"exclude_headings,omitempty"`
+	Since               string            `json:"since,omitempty"`
+	Until               string            `json:"until,omitempty"`
+	JSONFields          []string          `json:"json_fields,omitempty"`
+	DownloadMedia       []string          `json:"download_media,omitempty"`
+	AssetRetries        int               `json:"asset_retries,omitempty"`
+	AssetRetryBackoffMS int               `json:"asset_retry_backoff_ms,omitempty"`
+	JSONFormat          string            `json:"json_format,omitempty"`
+	GzipJSON            bool              `json:"gzip_json,omitempty"`
+	Newline             string            `json:"newline,omitempty"`
+	BOM                 bool              `json:"bom,omitempty"`
+	FrontMatter         bool              `json:"frontmatter,omitempty"`
+	Citation            string            `json:"citation,omitempty"`
+	CitationTemplate    string            `json:"citation_template,omitempty"`
+	ProxyURL            string            `json:"proxy_url"`
+	AuthHeaders         map[string]string `json:"auth_headers"`
+	AuthCookies         map[string]string `json:"auth_cookies"`
+	Login               *Login            `json:"login,omitempty"`
+	ClientCert          string            `json:"client_cert,omitempty"`
+	ClientKey           string            `json:"client_key,omitempty"`
+	FetchMiddleware     []string          `json:"fetch_middleware,omitempty"`
+	URLRewrites         []string          `json:"url_rewrites,omitempty"`
+	CacheProxy          string            `json:"cache_proxy,omitempty"`
+	CacheDir            string            `json:"cache_dir,omitempty"`
+	CacheMaxMB          int               `json:"cache_max_mb,omitempty"`
+	CacheTTL            int               `json:"cache_ttl_seconds,omitempty"`
+	EncryptCache        bool              `json:"encrypt_cache,omitempty"`
+	Sign                string            `json:"sign,omitempty"`
+	SignKey             string            `json:"sign_key,omitempty"`
 	// Post-processing pipeline hooks
 	PipelineHooks []string `json:"pipeline_hooks"`
 	PostCommands  []string `json:"post_commands"`
+	PreFetchCmds  []string `json:"pre_fetch_commands,omitempty"`
+	HookTimeout   int      `json:"hook_timeout_seconds,omitempty"`
+	HookEnv       []string `json:"hook_env,omitempty"`
+	ScrubPatterns []string `json:"scrub_patterns,omitempty"`
+	Seed          int64    `json:"seed,omitempty"`
 	// Crawl mode settings
-	Crawl       bool   `json:"crawl"`
-	Resume      bool   `json:"resume"`
-	SitemapURL  string `json:"sitemap_url"`
-	MaxPages    int    `json:"max_pages"`
-	CrawlDepth  int    `json:"crawl_depth"`
-	CrawlFilter string `json:"crawl_filter"`
+	Crawl          bool   `json:"crawl"`
+	Resume         bool   `json:"resume"`
+	Repair         bool   `json:"repair,omitempty"`
+	SitemapURL     string `json:"sitemap_url"`
+	MaxPages       int    `json:"max_pages"`
+	MaxPagesMode   string `json:"max_pages_mode,omitempty"`
+	CrawlDepth     int    `json:"crawl_depth"`
+	CrawlFilter    string `json:"crawl_filter"`
+	CrawlShardSize int    `json:"crawl_index_shard_size,omitempty"`
+	DumpFrontier   bool   `json:"dump_frontier,omitempty"`
+	QueueDir       string `json:"queue_dir,omitempty"`
+	WorkerID       string `json:"worker_id,omitempty"`
+	QueueLease     int    `json:"queue_lease_seconds,omitempty"`
+	AnchorScope    string `json:"anchor_scope,omitempty"`
+	MinPageChars   int    `json:"min_page_chars,omitempty"`
+	MaxPageChars   int    `json:"max_page_chars,omitempty"`
+	SoftPages      string `json:"soft_pages,omitempty"`
+	PageNames      string `json:"page_names,omitempty"`
+	AssetNames     string `json:"asset_names,omitempty"`
+	PageTimeout    int    `json:"page_timeout_seconds,omitempty"`
+	ProcessWorkers int    `json:"process_workers,omitempty"`
+	Watch          int    `json:"watch_seconds,omitempty"`
 }
 
+// Login is a form login run in the browser before anything is fetched. A
+// missing username or password is read from $GO_SCRAP_LOGIN_USERNAME or
+// $GO_SCRAP_LOGIN_PASSWORD.
+type Login struct {
+	URL              string `json:"url"`
+	Username         string `json:"username,omitempty"`
+	Password         string `json:"password,omitempty"`
+	UsernameSelector string `json:"username_selector,omitempty"`
+	PasswordSelector string `json:"password_selector"`
+	SubmitSelector   string `json:"submit_selector"`
+	SuccessSelector  string `json:"success_selector"`
+}
+
+// Load reads a config file, upgrading deprecated keys and printing a warning
+// to stderr for each one.
 func Load(path string) (Config, error) {
+	cfg, warnings, err := LoadWithWarnings(path)
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s: %s\n", path, w)
+	}
+	return cfg, err
+}
+
+// LoadWithWarnings is like Load but returns migration warnings instead of
+// printing them.
+func LoadWithWarnings(path string) (Config, []string, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return Config{}, err
+		return Config{}, nil, err
+	}
+	migrated, warnings, err := Migrate(data)
+	if err != nil {
+		return Config{}, nil, err
 	}
 	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		return Config{}, err
+	if err := json.Unmarshal(migrated, &cfg); err != nil {
+		return Config{}, nil, err
 	}
-	return cfg, nil
+	return cfg, warnings, nil
 }
 
 func Marshal(cfg Config) ([]byte, error) {
@@ -91,3 +91,33 @@ func TestMarshalConfig(t *testing.T) {
 		t.Fatalf("marshal produced empty output")
 	}
 }
+
+func TestLoadWithWarnings_MigratesDeprecatedKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := []byte(`{"wait_for_selector": "main", "timeout_seconds": 12, "bogus": 1}`)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("write temp config: %v", err)
+	}
+
+	cfg, warnings, err := config.LoadWithWarnings(path)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if cfg.WaitForSelector != "main" || cfg.TimeoutSeconds != 12 {
+		t.Fatalf("renamed keys not applied: %+v", cfg)
+	}
+	if len(warnings) != 2 {
+		t.Fatalf("expected 2 warnings, got %v", warnings)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"wait_for_selector": "body", "wait_for": "main"}`), 0600); err != nil {
+		t.Fatalf("write temp config: %v", err)
+	}
+	cfg, _, err = config.LoadWithWarnings(path)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if cfg.WaitForSelector != "main" {
+		t.Fatalf("current key should win over deprecated one, got %q", cfg.WaitForSelector)
+	}
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// keyRenames maps old config keys to their current names. Add an entry here
+// whenever a key is renamed so existing config files keep loading.
+var keyRenames = map[string]string{
+	"wait_for_selector": "wait_for",
+}
+
+// Migrate upgrades renamed keys in a raw config document and returns the
+// upgraded document together with human-readable warnings.
+func Migrate(data []byte) ([]byte, []string, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, nil, err
+	}
+
+	var warnings []string
+	changed := false
+	for _, old := range sortedKeys(keyRenames) {
+		value, ok := raw[old]
+		if !ok {
+			continue
+		}
+		current := keyRenames[old]
+		delete(raw, old)
+		changed = true
+		if _, exists := raw[current]; exists {
+			warnings = append(warnings, fmt.Sprintf("config key %q is deprecated and ignored because %q is also set", old, current))
+			continue
+		}
+		raw[current] = value
+		warnings = append(warnings, fmt.Sprintf("config key %q is deprecated; use %q", old, current))
+	}
+
+	known := knownKeys()
+	for _, key := range sortedKeys(raw) {
+		if _, ok := known[key]; !ok {
+			warnings = append(warnings, fmt.Sprintf("unknown config key %q ignored", key))
+		}
+	}
+
+	if !changed {
+		return data, warnings, nil
+	}
+	out, err := json.Marshal(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, warnings, nil
+}
+
+func knownKeys() map[string]struct{} {
+	t := reflect.TypeOf(Config{})
+	keys := make(map[string]struct{}, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			keys[name] = struct{}{}
+		}
+	}
+	return keys
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
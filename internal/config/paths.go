@@ -1,6 +1,7 @@
 package config
 
 import (
+	"os"
 	"path/filepath"
 	"strings"
 )
@@ -9,20 +10,66 @@ const (
 	DefaultConfigDir  = "configs"
 	DefaultConfigFile = "config.json"
 	LegacyConfigDir   = ".codex/CONFIGS"
+	// DirEnv overrides UserDir, e.g. to point a container at a mounted volume.
+	DirEnv = "GO_SCRAP_CONFIG_DIR"
 )
 
 func DefaultConfigPath() string {
 	return filepath.Join(DefaultConfigDir, DefaultConfigFile)
 }
 
+// UserDir is the per-user config directory: $GO_SCRAP_CONFIG_DIR, or
+// go_scrap under the OS config dir ($XDG_CONFIG_HOME or ~/.config on Linux,
+// ~/Library/Application Support on macOS, %AppData% on Windows).
+func UserDir() (string, error) {
+	if dir := strings.TrimSpace(os.Getenv(DirEnv)); dir != "" {
+		return dir, nil
+	}
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "go_scrap"), nil
+}
+
+// SearchDirs lists where config files are looked up: the working directory
+// and its config folders, then UserDir.
 func SearchDirs() []string {
-	return uniqueDirs([]string{
+	dirs := []string{
 		".",
 		DefaultConfigDir,
 		LegacyConfigDir,
 		"CONFIGS",
 		".codex",
-	})
+	}
+	if dir, err := UserDir(); err == nil {
+		dirs = append(dirs, dir)
+	}
+	return uniqueDirs(dirs)
+}
+
+// ResolvePath finds a relative config path that does not exist as given in
+// dir (UserDir when dir is empty). Paths that exist, absolute paths and
+// paths not found in dir are returned unchanged.
+func ResolvePath(path, dir string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+	if dir == "" {
+		userDir, err := UserDir()
+		if err != nil {
+			return path
+		}
+		dir = userDir
+	}
+	candidate := filepath.Join(dir, path)
+	if _, err := os.Stat(candidate); err == nil {
+		return candidate
+	}
+	return path
 }
 
 func uniqueDirs(dirs []string) []string {
@@ -3,8 +3,11 @@ package crawler
 import (
 	"context"
 	"crypto/sha256"
+	"crypto/tls"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"regexp"
 	"sort"
@@ -12,6 +15,11 @@ import (
 	"sync"
 	"time"
 
+	"go_scrap/internal/fetch"
+	"go_scrap/internal/footprint"
+	"go_scrap/internal/seal"
+	"go_scrap/internal/warnings"
+
 	"github.com/gocolly/colly/v2"
 )
 
@@ -28,6 +36,35 @@ type Options struct {
 	ProxyURL        string
 	Headers         map[string]string
 	Cookies         map[string]string
+	// MaxPagesMode decides what happens once MaxPages URLs are queued
+	// (default: MaxPagesStop).
+	MaxPagesMode MaxPagesMode
+	// TLSConfig carries a client certificate for sites that require mutual TLS.
+	TLSConfig *tls.Config
+	// WrapTransport, when set, wraps the HTTP transport (fetch middleware).
+	WrapTransport func(http.RoundTripper) http.RoundTripper
+	// Queue, when set, shares the frontier with other workers; MaxPages
+	// then caps the URLs this worker claims.
+	Queue Queue
+	// StateDir, when set, persists the visited set, fetched pages and queued
+	// links while crawling so a restarted process can continue the crawl
+	// (see ResumeState). It is ignored with a Queue.
+	StateDir string
+	// ResumeState continues from the state in StateDir; otherwise any
+	// previous state is discarded.
+	ResumeState bool
+	// Sealer, when set, encrypts the fetched pages saved in StateDir.
+	Sealer *seal.Sealer
+	// Retrier decides which failed requests are retried and how long to
+	// wait first (default: fetch.DefaultRetryPolicy). 429 and 503 responses
+	// back the host off in the limiter instead.
+	Retrier *fetch.Retrier
+	// Rewriter rewrites discovered and added links before they are
+	// filtered, deduplicated and fetched.
+	Rewriter *fetch.Rewriter
+	// Limiter, when set, spaces requests to each host instead of RateLimit,
+	// sharing the budget with the run's other fetches.
+	Limiter *fetch.HostLimiter
 }
 
 type Result struct {
@@ -36,25 +73,71 @@ type Result struct {
 	Error       error
 	FetchedAt   time.Time
 	ContentHash string
+	Provenance  Provenance
+}
+
+// Provenance describes the HTTP response a page came from so crawl indexes
+// can be filtered (e.g. by content type) and debugged after the fact.
+type Provenance struct {
+	HTTPStatus  int    `json:"http_status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	// Headers holds the provenanceHeaders present on the response.
+	Headers    map[string]string `json:"headers,omitempty"`
+	DurationMS int64             `json:"duration_ms,omitempty"`
 }
 
+// provenanceHeaders are the response headers kept in the crawl index.
+var provenanceHeaders = []string{"Server", "Last-Modified", "ETag", "Cache-Control", "Content-Language", "X-Robots-Tag"}
+
 type Stats struct {
 	StartedAt    time.Time `json:"started_at"`
 	CompletedAt  time.Time `json:"completed_at"`
 	PagesCrawled int       `json:"pages_crawled"`
 	PagesFailed  int       `json:"pages_failed"`
 	Errors       []string  `json:"errors,omitempty"`
+	// ThrottleEvents lists 429/503 responses; throttled URLs are retried
+	// after the server's Retry-After with a slower per-host rate.
+	ThrottleEvents []ThrottleEvent `json:"throttle_events,omitempty"`
+	// Skipped counts the URLs the crawl found but did not fetch, and the
+	// pages whose fetch failed, by category.
+	Skipped map[SkipCategory]int `json:"skipped,omitempty"`
+	// MaxPages is set when the crawl reached MaxPages.
+	MaxPages *MaxPagesReport `json:"max_pages,omitempty"`
 }
 
 // PageEntry represents a single crawled page in the index.
 type PageEntry struct {
-	URL           string    `json:"url"`
-	Status        string    `json:"status"` // "success", "error"
-	SectionCount  int       `json:"section_count,omitempty"`
+	URL    string `json:"url"`
+	Status string `json:"status"` // "success", "error", "skipped"
+	// Title is the page's <title>, or its first h1.
+	Title string `json:"title,omitempty"`
+	// OutputDir is the page's directory relative to the crawl output
+	// directory, for written pages.
+	OutputDir    string `json:"output_dir,omitempty"`
+	SectionCount int    `json:"section_count,omitempty"`
+	// BrokenAnchors and EmptySections count the completeness issues of a
+	// written page's report; retry-failed --repair re-fetches those pages.
+	BrokenAnchors int       `json:"broken_anchors,omitempty"`
+	EmptySections int       `json:"empty_sections,omitempty"`
 	FetchedAt     time.Time `json:"fetched_at"`
 	Error         string    `json:"error,omitempty"`
 	ContentLength int       `json:"content_length,omitempty"`
 	ContentHash   string    `json:"content_hash,omitempty"`
+	// SkipReason says why a fetched page was not written (status "skipped"),
+	// and SkipCategory sums it up.
+	SkipReason   string       `json:"skip_reason,omitempty"`
+	SkipCategory SkipCategory `json:"skip_category,omitempty"`
+	Provenance
+	// Classification flags pages that look like a soft 404, login wall or
+	// JavaScript-required shell rather than content.
+	Classification       string `json:"classification,omitempty"`
+	ClassificationReason string `json:"classification_reason,omitempty"`
+	// Published and Modified are the page's dates as found in its meta
+	// tags, JSON-LD or <time> elements.
+	Published string `json:"published,omitempty"`
+	Modified  string `json:"modified,omitempty"`
+	// Authors are the names in the page's byline.
+	Authors []string `json:"authors,omitempty"`
 }
 
 // CrawlIndex is a comprehensive summary of a crawl operation.
@@ -67,6 +150,25 @@ type CrawlIndex struct {
 	TotalSections int         `json:"total_sections"`
 	Pages         []PageEntry `json:"pages"`
 	Errors        []string    `json:"errors,omitempty"`
+	// ThrottleEvents lists 429/503 responses seen during the crawl.
+	ThrottleEvents []ThrottleEvent `json:"throttle_events,omitempty"`
+	// Skipped counts URLs left out of the crawl, and pages not written, by
+	// category.
+	Skipped map[SkipCategory]int `json:"skipped,omitempty"`
+	// MaxPages says how the crawl went on after reaching --max-pages, and
+	// what it missed.
+	MaxPages *MaxPagesReport `json:"max_pages,omitempty"`
+	// Shards lists page shard files (relative to the index) when the index is sharded.
+	Shards []string `json:"shards,omitempty"`
+	// RunID is the run that wrote the index; Warnings are that run's
+	// structured warnings up to the point the index was written.
+	RunID    string             `json:"run_id,omitempty"`
+	Warnings []warnings.Warning `json:"warnings,omitempty"`
+}
+
+// CrawlIndexShard holds a slice of the pages of a sharded CrawlIndex.
+type CrawlIndexShard struct {
+	Pages []PageEntry `json:"pages"`
 }
 
 type Crawler struct {
@@ -76,6 +178,22 @@ type Crawler struct {
 	mu        sync.Mutex
 	stats     Stats
 	urlCount  int
+	// limiter spaces requests to each host, backing off those that push
+	// back: Options.Limiter, or one for Options.RateLimit.
+	limiter *fetch.HostLimiter
+	// ctx is the context of the running crawl.
+	ctx       context.Context
+	retries   map[string]int
+	footprint *footprint.Recorder
+	// started holds request start times by colly request ID.
+	started sync.Map
+	// frontier holds links left unvisited because MaxPages was reached.
+	frontier map[string]struct{}
+	baseHost string
+	// state persists the crawl when Options.StateDir is set.
+	state *crawlState
+	// skipped holds the URLs counted in stats.Skipped, by category.
+	skipped map[SkipCategory]map[string]struct{}
 }
 
 func New(opts Options) (*Crawler, error) {
@@ -93,7 +211,8 @@ func New(opts Options) (*Crawler, error) {
 		)
 	} else {
 		c = colly.NewCollector(
-			colly.AllowedDomains(baseURL.Host),
+			// colly matches the hostname without the port.
+			colly.AllowedDomains(baseURL.Hostname()),
 			colly.MaxDepth(opts.MaxDepth),
 			colly.Async(true),
 			colly.UserAgent(opts.UserAgent),
@@ -101,7 +220,7 @@ func New(opts Options) (*Crawler, error) {
 	}
 
 	configureRateLimiting(c, opts)
-	if err := configureProxy(c, opts); err != nil {
+	if err := configureTransport(c, opts); err != nil {
 		return nil, err
 	}
 
@@ -110,8 +229,29 @@ func New(opts Options) (*Crawler, error) {
 		opts:      opts,
 		results:   make(map[string]*Result),
 		stats:     Stats{StartedAt: time.Now()},
+		limiter:   opts.Limiter,
+		ctx:       context.Background(),
+		retries:   make(map[string]int),
+		frontier:  make(map[string]struct{}),
+		skipped:   make(map[SkipCategory]map[string]struct{}),
+		baseHost:  baseURL.Host,
+	}
+
+	if opts.StateDir != "" && opts.Queue == nil {
+		st, err := openCrawlState(opts.StateDir, opts.ResumeState, opts.Sealer)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.SetStorage(st.storage); err != nil {
+			st.close()
+			return nil, fmt.Errorf("set crawl storage: %w", err)
+		}
+		crawler.state = st
 	}
 
+	if crawler.limiter == nil {
+		crawler.limiter = fetch.NewHostLimiter(opts.RateLimit)
+	}
 	crawler.setupCallbacks(c)
 	return crawler, nil
 }
@@ -135,22 +275,28 @@ func validateAndNormalizeOptions(opts *Options) (*url.URL, error) {
 	if opts.MaxPages <= 0 {
 		opts.MaxPages = 100
 	}
+	if opts.MaxPagesMode == "" {
+		opts.MaxPagesMode = MaxPagesStop
+	}
 	if opts.UserAgent == "" {
 		opts.UserAgent = "go_scrap/1.0"
 	}
 	if opts.RateLimit <= 0 {
 		opts.RateLimit = 1.0
 	}
+	if opts.Retrier == nil {
+		opts.Retrier = fetch.NewRetrier(fetch.DefaultRetryPolicy, time.Now().UnixNano())
+	}
 
 	return baseURL, nil
 }
 
+// configureRateLimiting bounds parallelism; the crawler's limiter spaces
+// the requests themselves.
 func configureRateLimiting(c *colly.Collector, opts Options) {
-	delay := time.Duration(float64(time.Second) / opts.RateLimit)
 	_ = c.Limit(&colly.LimitRule{
 		DomainGlob:  "*",
 		Parallelism: opts.Parallelism,
-		Delay:       delay,
 	})
 
 	if opts.Timeout > 0 {
@@ -158,6 +304,30 @@ func configureRateLimiting(c *colly.Collector, opts Options) {
 	}
 }
 
+// configureTransport installs a transport carrying the client TLS config,
+// proxy and middleware. Without either of the first two or middleware,
+// colly's own transport and proxy handling are kept.
+func configureTransport(c *colly.Collector, opts Options) error {
+	if opts.TLSConfig == nil && opts.WrapTransport == nil {
+		return configureProxy(c, opts)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = opts.TLSConfig
+	if opts.ProxyURL != "" {
+		proxyURL, err := url.Parse(opts.ProxyURL)
+		if err != nil {
+			return fmt.Errorf("set proxy: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	var rt http.RoundTripper = transport
+	if opts.WrapTransport != nil {
+		rt = opts.WrapTransport(rt)
+	}
+	c.WithTransport(rt)
+	return nil
+}
+
 func configureProxy(c *colly.Collector, opts Options) error {
 	if opts.ProxyURL == "" {
 		return nil
@@ -173,7 +343,17 @@ func (cr *Crawler) setupCallbacks(c *colly.Collector) {
 	c.OnHTML("a[href]", cr.handleLink)
 	c.OnError(cr.handleError)
 	c.OnRequest(func(r *colly.Request) {
+		if err := cr.limiter.Wait(cr.ctx, r.URL.Hostname()); err != nil {
+			r.Abort()
+			return
+		}
 		applyRequestHeaders(r, cr.opts.Headers, cr.opts.Cookies)
+		r.Ctx.Put(requestedURLKey, r.URL.String())
+		cr.started.Store(r.ID, time.Now())
+	})
+	c.OnResponse(func(r *colly.Response) {
+		cr.footprint.Request(r.Request.URL.String(), int64(len(r.Body)), nil)
+		cr.limiter.Relax(r.Request.URL.Hostname())
 	})
 }
 
@@ -187,13 +367,16 @@ func (cr *Crawler) handleHTMLResponse(e *colly.HTMLElement) {
 		return
 	}
 
-	cr.results[e.Request.URL.String()] = &Result{
+	result := &Result{
 		URL:         e.Request.URL.String(),
 		HTML:        html,
 		FetchedAt:   time.Now(),
-		ContentHash: hashHTML(html),
+		ContentHash: HashHTML(html),
+		Provenance:  cr.provenance(e.Response),
 	}
+	cr.results[result.URL] = result
 	cr.stats.PagesCrawled++
+	cr.saveState(result, e.Request)
 }
 
 func (cr *Crawler) handleLink(e *colly.HTMLElement) {
@@ -202,26 +385,151 @@ func (cr *Crawler) handleLink(e *colly.HTMLElement) {
 		return
 	}
 
-	absURL := e.Request.AbsoluteURL(link)
+	absURL := cr.opts.Rewriter.Rewrite(e.Request.AbsoluteURL(link))
 	if absURL == "" {
 		return
 	}
 
 	if cr.opts.URLFilter != nil && !cr.opts.URLFilter.MatchString(absURL) {
+		cr.skip(absURL, SkipFiltered)
+		return
+	}
+	if cr.opts.Queue != nil {
+		cr.pushLink(e, absURL)
+		return
+	}
+	if cr.offDomain(absURL) {
+		cr.skip(absURL, SkipOffDomain)
 		return
 	}
 
-	if !cr.incrementURLCount() {
+	depth := linkDepth(e.Request)
+	if depth >= cr.opts.MaxDepth {
+		return
+	}
+	if !cr.incrementURLCount(depth + 1) {
+		cr.addFrontier(absURL)
 		return
 	}
 
-	_ = e.Request.Visit(absURL)
+	err := e.Request.Visit(absURL)
+	if err == nil {
+		cr.saveLink(absURL, depth+1)
+		return
+	}
+	cr.release()
+	if isAlreadyVisited(err) {
+		cr.skip(absURL, SkipDuplicate)
+	}
 }
 
 func (cr *Crawler) handleError(r *colly.Response, err error) {
+	cr.footprint.Request(r.Request.URL.String(), int64(len(r.Body)), err)
+	if isThrottleStatus(r.StatusCode) {
+		if cr.handleThrottle(r) {
+			return
+		}
+	} else if cr.opts.Retrier.Policy().RetryStatus(r.StatusCode) && cr.retryLater(r) {
+		return
+	}
 	cr.mu.Lock()
 	defer cr.mu.Unlock()
 	cr.recordError(r.Request.URL.String(), err)
+	result := cr.results[r.Request.URL.String()]
+	result.Provenance = cr.provenance(r)
+	cr.saveState(result, r.Request)
+}
+
+// provenance captures status, content type, headers of interest and timing
+// for a response.
+func (cr *Crawler) provenance(r *colly.Response) Provenance {
+	if r == nil {
+		return Provenance{}
+	}
+	p := Provenance{HTTPStatus: r.StatusCode}
+	if r.Request != nil {
+		if v, ok := cr.started.LoadAndDelete(r.Request.ID); ok {
+			p.DurationMS = time.Since(v.(time.Time)).Milliseconds()
+		}
+	}
+	if r.Headers == nil {
+		return p
+	}
+	p.ContentType = r.Headers.Get("Content-Type")
+	for _, name := range provenanceHeaders {
+		if v := r.Headers.Get(name); v != "" {
+			if p.Headers == nil {
+				p.Headers = map[string]string{}
+			}
+			p.Headers[name] = v
+		}
+	}
+	return p
+}
+
+// handleThrottle slows the host down and schedules a retry, holding the host
+// for the server's Retry-After, or the retry policy's backoff when it sent
+// none. It returns false once the URL has used up its retries so the error
+// is recorded as usual.
+func (cr *Crawler) handleThrottle(r *colly.Response) bool {
+	urlStr := r.Request.URL.String()
+	var header string
+	if r.Headers != nil {
+		header = r.Headers.Get("Retry-After")
+	}
+	retryAfter := fetch.ParseRetryAfter(header, time.Now())
+
+	cr.mu.Lock()
+	retry := cr.opts.Retrier.Policy().RetryStatus(r.StatusCode) && cr.retryBudget(urlStr)
+	n := cr.retries[urlStr]
+	cr.mu.Unlock()
+
+	hold := retryAfter
+	if retry && header == "" {
+		hold = cr.opts.Retrier.Delay(n)
+	}
+	delay := cr.limiter.Penalize(r.Request.URL.Hostname(), hold)
+
+	cr.mu.Lock()
+	cr.stats.ThrottleEvents = append(cr.stats.ThrottleEvents, ThrottleEvent{
+		URL:         urlStr,
+		StatusCode:  r.StatusCode,
+		RetryAfter:  retryAfter.Seconds(),
+		Delay:       delay.Seconds(),
+		Retried:     retry,
+		ThrottledAt: time.Now(),
+	})
+	cr.mu.Unlock()
+
+	if !retry {
+		return false
+	}
+	return r.Request.Retry() == nil
+}
+
+// retryLater retries a request that failed with a network error or a
+// retryable status after the policy's backoff. It returns false once the URL
+// has used up its retries.
+func (cr *Crawler) retryLater(r *colly.Response) bool {
+	cr.mu.Lock()
+	retry := cr.retryBudget(r.Request.URL.String())
+	n := cr.retries[r.Request.URL.String()]
+	cr.mu.Unlock()
+	if !retry {
+		return false
+	}
+	time.Sleep(cr.opts.Retrier.Delay(n))
+	return r.Request.Retry() == nil
+}
+
+// retryBudget counts a retry of urlStr and reports whether the policy still
+// allows it. It runs with cr.mu held.
+func (cr *Crawler) retryBudget(urlStr string) bool {
+	if cr.retries[urlStr] >= cr.opts.Retrier.Policy().MaxAttempts-1 {
+		return false
+	}
+	cr.retries[urlStr]++
+	return true
 }
 
 func (cr *Crawler) recordError(urlStr string, err error) {
@@ -230,18 +538,55 @@ func (cr *Crawler) recordError(urlStr string, err error) {
 		Error:     err,
 		FetchedAt: time.Now(),
 	}
+	cr.countSkip(urlStr, ErrorCategory(err))
 	cr.stats.PagesFailed++
 	cr.stats.Errors = append(cr.stats.Errors, fmt.Sprintf("%s: %v", urlStr, err))
 }
 
-func (cr *Crawler) incrementURLCount() bool {
+func (cr *Crawler) incrementURLCount(depth int) bool {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	return cr.admit(depth)
+}
+
+// addFrontier records a link the crawl could not queue. Links the crawl would
+// never follow (other hosts) are left out.
+func (cr *Crawler) addFrontier(link string) {
+	u, err := url.Parse(link)
+	if err != nil || (!cr.opts.AllowAllDomains && u.Host != cr.baseHost) {
+		return
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
 	cr.mu.Lock()
 	defer cr.mu.Unlock()
-	if cr.urlCount >= cr.opts.MaxPages {
+	cr.frontier[u.String()] = struct{}{}
+}
+
+// InScope reports whether the crawl would fetch link: it is on the base
+// host (unless AllowAllDomains) and matches URLFilter.
+func (cr *Crawler) InScope(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil || (!cr.opts.AllowAllDomains && u.Host != cr.baseHost) {
 		return false
 	}
-	cr.urlCount++
-	return true
+	return cr.opts.URLFilter == nil || cr.opts.URLFilter.MatchString(link)
+}
+
+// Frontier returns, sorted, the URLs that were found or added but not
+// crawled because MaxPages was reached. Feed them to a follow-up run.
+func (cr *Crawler) Frontier() []string {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	out := make([]string, 0, len(cr.frontier))
+	for link := range cr.frontier {
+		if _, visited := cr.results[link]; visited {
+			continue
+		}
+		out = append(out, link)
+	}
+	sort.Strings(out)
+	return out
 }
 
 func isValidLink(link string) bool {
@@ -287,9 +632,18 @@ func buildCookieHeader(cookies map[string]string) string {
 func (cr *Crawler) Crawl(ctx context.Context) (map[string]*Result, Stats, error) {
 	cr.mu.Lock()
 	cr.urlCount = 1 // Start URL counts as 1
+	cr.footprint = footprint.From(ctx)
+	cr.ctx = ctx
 	cr.mu.Unlock()
 
-	if err := cr.collector.Visit(cr.opts.BaseURL); err != nil {
+	if cr.opts.Queue != nil {
+		return cr.crawlQueue(ctx)
+	}
+	resumed := cr.state != nil && cr.state.resumed()
+	if resumed {
+		cr.restoreState()
+	}
+	if err := cr.collector.Visit(cr.opts.BaseURL); err != nil && !(resumed && isAlreadyVisited(err)) {
 		return nil, cr.stats, fmt.Errorf("failed to start crawl: %w", err)
 	}
 
@@ -308,47 +662,77 @@ func (cr *Crawler) Crawl(ctx context.Context) (map[string]*Result, Stats, error)
 	}
 
 	cr.stats.CompletedAt = time.Now()
+	if cr.stats.MaxPages != nil {
+		cr.stats.MaxPages.Missed = len(cr.Frontier())
+	}
+	if cr.state != nil {
+		if err := cr.state.finish(); err != nil {
+			cr.stats.Errors = append(cr.stats.Errors, fmt.Sprintf("crawl state: %v", err))
+		}
+	}
 	return cr.results, cr.stats, nil
 }
 
 func (cr *Crawler) AddURL(url string) error {
+	url = cr.opts.Rewriter.Rewrite(url)
+	if cr.opts.Queue != nil {
+		return cr.opts.Queue.Push(queueURL(url), 1)
+	}
 	cr.mu.Lock()
-	if cr.urlCount >= cr.opts.MaxPages {
+	if !cr.admit(1) {
+		cr.frontier[url] = struct{}{}
 		cr.mu.Unlock()
-		return fmt.Errorf("max pages limit reached")
+		return errMaxPages
 	}
-	cr.urlCount++
 	cr.mu.Unlock()
 
-	return cr.collector.Visit(url)
+	if err := cr.collector.Visit(url); err != nil {
+		cr.release()
+		return err
+	}
+	cr.saveLink(url, 1)
+	return nil
 }
 
+// AddURLs queues urls until MaxPages is reached; the rest go to the
+// frontier.
 func (cr *Crawler) AddURLs(urls []string) error {
 	for _, u := range urls {
-		if err := cr.AddURL(u); err != nil {
+		if err := cr.AddURL(u); err != nil && !errors.Is(err, errMaxPages) && !isAlreadyVisited(err) {
 			return err
 		}
 	}
 	return nil
 }
 
+var errMaxPages = errors.New("max pages limit reached")
+
+func isAlreadyVisited(err error) bool {
+	var visited *colly.AlreadyVisitedError
+	return errors.As(err, &visited)
+}
+
 // BuildIndex creates a CrawlIndex from the crawler results.
 // sectionCounts is a map from URL to section count (provided by caller after parsing).
 func BuildIndex(results map[string]*Result, stats Stats, baseURL string, sectionCounts map[string]int) CrawlIndex {
 	index := CrawlIndex{
-		StartedAt:    stats.StartedAt,
-		CompletedAt:  stats.CompletedAt,
-		BaseURL:      baseURL,
-		PagesCrawled: stats.PagesCrawled,
-		PagesFailed:  stats.PagesFailed,
-		Pages:        make([]PageEntry, 0, len(results)),
-		Errors:       stats.Errors,
+		StartedAt:      stats.StartedAt,
+		CompletedAt:    stats.CompletedAt,
+		BaseURL:        baseURL,
+		PagesCrawled:   stats.PagesCrawled,
+		PagesFailed:    stats.PagesFailed,
+		Pages:          make([]PageEntry, 0, len(results)),
+		Errors:         stats.Errors,
+		ThrottleEvents: stats.ThrottleEvents,
+		Skipped:        AddSkipCounts(nil, stats.Skipped),
+		MaxPages:       stats.MaxPages,
 	}
 
 	for url, result := range results {
 		entry := PageEntry{
-			URL:       url,
-			FetchedAt: result.FetchedAt,
+			URL:        url,
+			FetchedAt:  result.FetchedAt,
+			Provenance: result.Provenance,
 		}
 
 		if result.Error != nil {
@@ -373,7 +757,8 @@ func BuildIndex(results map[string]*Result, stats Stats, baseURL string, section
 	return index
 }
 
-func hashHTML(html string) string {
+// HashHTML is the content hash recorded for a page in the crawl index.
+func HashHTML(html string) string {
 	sum := sha256.Sum256([]byte(html))
 	return hex.EncodeToString(sum[:])
 }
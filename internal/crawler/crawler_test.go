@@ -2,13 +2,21 @@ package crawler_test
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"go_scrap/internal/crawler"
+	"go_scrap/internal/fetch"
 )
 
 func TestNew_ValidOptions(t *testing.T) {
@@ -176,6 +184,186 @@ func TestCrawl_RespectsMaxPages(t *testing.T) {
 	}
 }
 
+func TestCrawl_RecordsProvenance(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Server", "docs-server/1.2")
+		w.Header().Set("ETag", `"abc"`)
+		w.Header().Set("X-Internal", "not recorded")
+		_, _ = w.Write([]byte(`<html><body><h1>Home</h1><a href="/missing">gone</a></body></html>`))
+	}))
+	defer srv.Close()
+
+	c, err := crawler.New(crawler.Options{
+		BaseURL:         srv.URL,
+		RateLimit:       50.0,
+		MaxPages:        5,
+		Timeout:         5 * time.Second,
+		AllowAllDomains: true,
+	})
+	if err != nil {
+		t.Fatalf("create crawler: %v", err)
+	}
+	results, stats, err := c.Crawl(context.Background())
+	if err != nil {
+		t.Fatalf("crawl failed: %v", err)
+	}
+
+	index := crawler.BuildIndex(results, stats, srv.URL, nil)
+	var home, missing *crawler.PageEntry
+	for i := range index.Pages {
+		switch {
+		case strings.HasSuffix(index.Pages[i].URL, "/missing"):
+			missing = &index.Pages[i]
+		default:
+			home = &index.Pages[i]
+		}
+	}
+	if home == nil || missing == nil {
+		t.Fatalf("expected home and missing pages, got %#v", index.Pages)
+	}
+	if home.HTTPStatus != http.StatusOK || home.ContentType != "text/html; charset=utf-8" {
+		t.Fatalf("unexpected home provenance: %#v", home.Provenance)
+	}
+	if home.Headers["Server"] != "docs-server/1.2" || home.Headers["ETag"] != `"abc"` || len(home.Headers) != 2 {
+		t.Fatalf("unexpected home headers: %v", home.Headers)
+	}
+	if missing.Status != "error" || missing.HTTPStatus != http.StatusNotFound {
+		t.Fatalf("unexpected missing entry: %#v", missing)
+	}
+
+	data, err := json.Marshal(home)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"http_status":200`) || !strings.Contains(string(data), `"content_type":"text/html; charset=utf-8"`) {
+		t.Fatalf("expected provenance fields inline in the page entry: %s", data)
+	}
+}
+
+func TestCrawl_MaxPagesModes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			_, _ = w.Write([]byte(`<html><body><a href="/a">a</a><a href="/b">b</a><a href="/c">c</a><a href="/d">d</a></body></html>`))
+			return
+		}
+		_, _ = w.Write([]byte(`<html><body><a href="/x` + r.URL.Path + `">more</a></body></html>`))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		mode      crawler.MaxPagesMode
+		crawled   int
+		overLimit int
+		missed    int
+	}{
+		{crawler.MaxPagesStop, 3, 0, 4},
+		{crawler.MaxPagesFinishDepth, 5, 2, 4},
+		{crawler.MaxPagesWarn, 9, 6, 0},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			c, err := crawler.New(crawler.Options{
+				BaseURL:      srv.URL,
+				RateLimit:    50.0,
+				MaxPages:     3,
+				MaxPagesMode: tt.mode,
+				MaxDepth:     3,
+				Timeout:      5 * time.Second,
+			})
+			if err != nil {
+				t.Fatalf("create crawler: %v", err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			_, stats, err := c.Crawl(ctx)
+			if err != nil {
+				t.Fatalf("crawl failed: %v", err)
+			}
+			if stats.PagesCrawled != tt.crawled {
+				t.Fatalf("expected %d pages crawled, got %d", tt.crawled, stats.PagesCrawled)
+			}
+			want := &crawler.MaxPagesReport{Mode: tt.mode, Limit: 3, Depth: 2, OverLimit: tt.overLimit, Missed: tt.missed}
+			if !reflect.DeepEqual(stats.MaxPages, want) {
+				t.Fatalf("expected report %+v, got %+v", want, stats.MaxPages)
+			}
+			if got := len(c.Frontier()); got != tt.missed {
+				t.Fatalf("expected %d frontier URLs, got %d", tt.missed, got)
+			}
+		})
+	}
+}
+
+func TestCrawl_FrontierListsUncrawledLinks(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path != "/" {
+			_, _ = w.Write([]byte(`<html><body><p>leaf</p></body></html>`))
+			return
+		}
+		_, _ = w.Write([]byte(`<html><body>
+			<a href="/page1">1</a><a href="/page2">2</a><a href="/page3">3</a>
+			<a href="/page4">4</a><a href="/page4#top">4 again</a>
+		</body></html>`))
+	}))
+	defer srv.Close()
+
+	c, err := crawler.New(crawler.Options{
+		BaseURL:         srv.URL,
+		RateLimit:       50.0,
+		Parallelism:     1,
+		MaxPages:        3,
+		MaxDepth:        2,
+		Timeout:         5 * time.Second,
+		AllowAllDomains: true,
+	})
+	if err != nil {
+		t.Fatalf("create crawler: %v", err)
+	}
+	results, _, err := c.Crawl(context.Background())
+	if err != nil {
+		t.Fatalf("crawl failed: %v", err)
+	}
+
+	frontier := c.Frontier()
+	for _, link := range frontier {
+		if _, ok := results[link]; ok {
+			t.Fatalf("frontier contains crawled page %s", link)
+		}
+	}
+	if len(results)+len(frontier) != 5 || len(frontier) != 2 {
+		t.Fatalf("expected crawled + frontier to cover all 5 pages, got %d results and frontier %v", len(results), frontier)
+	}
+	if !strings.HasSuffix(frontier[len(frontier)-1], "/page4") {
+		t.Fatalf("expected sorted frontier ending with /page4, got %v", frontier)
+	}
+}
+
+func TestAddURLs_PastMaxPagesGoToFrontier(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body>ok</body></html>`))
+	}))
+	defer srv.Close()
+
+	c, err := crawler.New(crawler.Options{BaseURL: srv.URL, RateLimit: 10.0, MaxPages: 1, AllowAllDomains: true})
+	if err != nil {
+		t.Fatalf("create crawler: %v", err)
+	}
+	if err := c.AddURLs([]string{srv.URL + "/a", srv.URL + "/b"}); err != nil {
+		t.Fatalf("AddURLs past the cap should not fail: %v", err)
+	}
+	if got := c.Frontier(); len(got) != 1 || got[0] != srv.URL+"/b" {
+		t.Fatalf("unexpected frontier: %v", got)
+	}
+}
+
 func TestBuildIndex_Basic(t *testing.T) {
 	now := time.Now()
 	results := map[string]*crawler.Result{
@@ -280,3 +468,233 @@ func TestBuildIndex_SortedByURL(t *testing.T) {
 		t.Errorf("expected third page to be /z, got %s", index.Pages[2].URL)
 	}
 }
+
+func TestCrawl_RetriesAfterThrottle(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body><h1>OK</h1></body></html>`))
+	}))
+	defer srv.Close()
+
+	c, err := crawler.New(crawler.Options{
+		BaseURL:         srv.URL,
+		RateLimit:       20.0,
+		MaxPages:        1,
+		Timeout:         5 * time.Second,
+		AllowAllDomains: true,
+	})
+	if err != nil {
+		t.Fatalf("create crawler: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, stats, err := c.Crawl(ctx)
+	if err != nil {
+		t.Fatalf("crawl failed: %v", err)
+	}
+	if stats.PagesCrawled != 1 || stats.PagesFailed != 0 {
+		t.Fatalf("expected throttled page to succeed on retry: %+v", stats)
+	}
+	if len(stats.ThrottleEvents) != 1 || stats.ThrottleEvents[0].StatusCode != http.StatusTooManyRequests || !stats.ThrottleEvents[0].Retried {
+		t.Fatalf("unexpected throttle events: %+v", stats.ThrottleEvents)
+	}
+}
+
+func TestCrawler_RetriesServerErrorsPerPolicy(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(`<html><body><a href="/flaky">flaky</a><a href="/gone">gone</a></body></html>`))
+		case "/flaky":
+			if requests.Add(1) < 3 {
+				http.Error(w, "busy", http.StatusBadGateway)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(`<html><body><h1>Flaky</h1></body></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := crawler.New(crawler.Options{
+		BaseURL:         srv.URL,
+		RateLimit:       50.0,
+		MaxPages:        5,
+		Timeout:         5 * time.Second,
+		AllowAllDomains: true,
+		Retrier:         fetch.NewRetrier(fetch.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}, 1),
+	})
+	if err != nil {
+		t.Fatalf("create crawler: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	results, _, err := c.Crawl(ctx)
+	if err != nil {
+		t.Fatalf("crawl failed: %v", err)
+	}
+	if r := results[srv.URL+"/flaky"]; r == nil || r.Error != nil {
+		t.Fatalf("expected /flaky to succeed on its third attempt: %+v", r)
+	}
+	if r := results[srv.URL+"/gone"]; r == nil || r.Error == nil {
+		t.Fatalf("expected /gone to fail without retries: %+v", r)
+	}
+	if got := requests.Load(); got != 3 {
+		t.Fatalf("expected 3 requests for /flaky, got %d", got)
+	}
+}
+
+func TestCrawler_RewritesLinksBeforeFetching(t *testing.T) {
+	var oldRequests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/old/") {
+			oldRequests.Add(1)
+		}
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body><a href="/old/guide">guide</a><a href="/new/guide">again</a></body></html>`))
+	}))
+	defer srv.Close()
+
+	rewriter, err := fetch.NewRewriter([]fetch.RewriteRule{{Match: "/old/", Replace: "/new/"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := crawler.New(crawler.Options{
+		BaseURL:         srv.URL,
+		RateLimit:       50.0,
+		MaxPages:        5,
+		Timeout:         5 * time.Second,
+		AllowAllDomains: true,
+		Rewriter:        rewriter,
+	})
+	if err != nil {
+		t.Fatalf("create crawler: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	results, _, err := c.Crawl(ctx)
+	if err != nil {
+		t.Fatalf("crawl failed: %v", err)
+	}
+	if _, ok := results[srv.URL+"/new/guide"]; !ok {
+		t.Fatalf("expected the rewritten link to be crawled: %v", results)
+	}
+	if len(results) != 2 || oldRequests.Load() != 0 {
+		t.Fatalf("expected the old and new links to dedupe into one fetch, got %d results and %d old requests", len(results), oldRequests.Load())
+	}
+}
+
+func TestCrawl_CountsSkippedLinksByCategory(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			_, _ = w.Write([]byte(`<html><body>
+				<a href="/docs/a">a</a><a href="/docs/b">b</a><a href="/blog/post">post</a>
+				<a href="https://other.example/x">other</a><a href="https://other.example/x#top">other again</a>
+			</body></html>`))
+		case "/docs/a":
+			_, _ = w.Write([]byte(`<html><body><a href="/docs/b">b</a><a href="/">home</a></body></html>`))
+		case "/docs/b":
+			http.Error(w, "gone", http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := crawler.New(crawler.Options{
+		BaseURL:   srv.URL,
+		RateLimit: 50.0,
+		MaxPages:  10,
+		MaxDepth:  3,
+		Timeout:   5 * time.Second,
+		URLFilter: regexp.MustCompile(`/docs/|/$|other\.example`),
+	})
+	if err != nil {
+		t.Fatalf("create crawler: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, stats, err := c.Crawl(ctx)
+	if err != nil {
+		t.Fatalf("crawl failed: %v", err)
+	}
+	want := map[crawler.SkipCategory]int{
+		crawler.SkipFiltered:  1, // /blog/post
+		crawler.SkipOffDomain: 1, // other.example/x, with and without fragment
+		crawler.SkipDuplicate: 2, // /docs/b and / linked again from /docs/a
+		crawler.SkipError:     1, // /docs/b answered 404
+	}
+	if !reflect.DeepEqual(stats.Skipped, want) {
+		t.Fatalf("expected skip counts %v, got %v", want, stats.Skipped)
+	}
+	index := crawler.BuildIndex(map[string]*crawler.Result{}, stats, srv.URL, nil)
+	if !reflect.DeepEqual(index.Skipped, want) {
+		t.Fatalf("expected the index to carry skip counts %v, got %v", want, index.Skipped)
+	}
+}
+
+func TestCrawler_SharesTheRunLimiter(t *testing.T) {
+	var mu sync.Mutex
+	var times []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		times = append(times, time.Now())
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body><a href="/a">a</a><a href="/b">b</a></body></html>`))
+	}))
+	defer srv.Close()
+
+	limiter := fetch.NewHostLimiter(10) // 100ms apart
+	// Another fetcher sharing the limiter has just used the host's slot.
+	u, _ := url.Parse(srv.URL)
+	_ = limiter.Wait(context.Background(), u.Hostname())
+	start := time.Now()
+	c, err := crawler.New(crawler.Options{
+		BaseURL:         srv.URL,
+		RateLimit:       1000.0,
+		Parallelism:     3,
+		MaxPages:        3,
+		Timeout:         5 * time.Second,
+		AllowAllDomains: true,
+		Limiter:         limiter,
+	})
+	if err != nil {
+		t.Fatalf("create crawler: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, _, err := c.Crawl(ctx); err != nil {
+		t.Fatalf("crawl failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(times) != 3 {
+		t.Fatalf("expected 3 requests, got %d", len(times))
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	if d := times[0].Sub(start); d < 80*time.Millisecond {
+		t.Fatalf("expected the first request to wait for the shared slot, waited %s", d)
+	}
+	for i := 1; i < len(times); i++ {
+		if d := times[i].Sub(times[i-1]); d < 80*time.Millisecond {
+			t.Fatalf("requests %d and %d were %s apart, expected ~100ms", i-1, i, d)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package crawler
+
+import "fmt"
+
+// MaxPagesMode decides what a crawl does once MaxPages URLs are queued.
+type MaxPagesMode string
+
+const (
+	// MaxPagesStop queues nothing more; further links go to the frontier.
+	MaxPagesStop MaxPagesMode = "stop"
+	// MaxPagesFinishDepth still queues links at the depth being queued when
+	// the limit was reached, so that level is covered completely, and sends
+	// deeper links to the frontier.
+	MaxPagesFinishDepth MaxPagesMode = "finish-depth"
+	// MaxPagesWarn keeps crawling past the limit, within MaxDepth, and
+	// counts the URLs queued beyond it.
+	MaxPagesWarn MaxPagesMode = "warn"
+)
+
+// ParseMaxPagesMode accepts the modes by name; "" is MaxPagesStop.
+func ParseMaxPagesMode(s string) (MaxPagesMode, error) {
+	switch mode := MaxPagesMode(s); mode {
+	case "":
+		return MaxPagesStop, nil
+	case MaxPagesStop, MaxPagesFinishDepth, MaxPagesWarn:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown max-pages mode %q (expected stop, finish-depth or warn)", s)
+	}
+}
+
+// MaxPagesReport describes a crawl that reached MaxPages.
+type MaxPagesReport struct {
+	Mode  MaxPagesMode `json:"mode"`
+	Limit int          `json:"limit"`
+	// Depth is the link depth being queued when the limit was reached,
+	// counting the start URL as 1.
+	Depth int `json:"depth"`
+	// OverLimit counts the URLs queued past the limit.
+	OverLimit int `json:"over_limit,omitempty"`
+	// Missed counts the in-scope URLs found but not crawled; the frontier
+	// lists them.
+	Missed int `json:"missed"`
+}
+
+// admit reserves a MaxPages slot for a URL at depth and reports whether it
+// may be queued. It runs with cr.mu held.
+func (cr *Crawler) admit(depth int) bool {
+	if cr.urlCount < cr.opts.MaxPages {
+		cr.urlCount++
+		return true
+	}
+	if cr.stats.MaxPages == nil {
+		cr.stats.MaxPages = &MaxPagesReport{Mode: cr.opts.MaxPagesMode, Limit: cr.opts.MaxPages, Depth: depth}
+	}
+	report := cr.stats.MaxPages
+	switch {
+	case cr.opts.MaxPagesMode == MaxPagesWarn,
+		cr.opts.MaxPagesMode == MaxPagesFinishDepth && depth <= report.Depth:
+		cr.urlCount++
+		report.OverLimit++
+		return true
+	}
+	return false
+}
+
+// release gives back the slot of a URL admitted but not queued, such as a
+// link to a page already visited.
+func (cr *Crawler) release() {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if report := cr.stats.MaxPages; report != nil && report.OverLimit > 0 && cr.urlCount > cr.opts.MaxPages {
+		report.OverLimit--
+	}
+	cr.urlCount--
+}
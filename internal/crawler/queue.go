@@ -0,0 +1,348 @@
+package crawler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+)
+
+// Queue is a crawl frontier shared by several go_scrap instances. Each URL
+// is handed to one worker at a time under a lease; a worker that dies
+// without finishing loses the lease and the URL is handed out again.
+type Queue interface {
+	// Push adds a URL at the given link depth unless it was pushed before.
+	Push(url string, depth int) error
+	// Claim leases the next pending URL. ok is false when nothing is
+	// pending right now; other workers may still push more.
+	Claim() (item QueueItem, ok bool, err error)
+	// Done releases a claimed URL once it has been fetched.
+	Done(item QueueItem) error
+	// Drained reports whether nothing is pending or leased, i.e. the shared
+	// crawl is finished.
+	Drained() (bool, error)
+}
+
+// QueueItem is a leased URL.
+type QueueItem struct {
+	URL   string `json:"url"`
+	Depth int    `json:"depth"`
+	lease string
+}
+
+// DefaultQueueLease is how long a claimed URL stays with its worker.
+const DefaultQueueLease = 5 * time.Minute
+
+// FileQueue is a Queue in a directory on a volume every worker mounts. It
+// only relies on atomic create-exclusive and rename:
+//
+//	seen/<hash>     every URL ever pushed (deduplication)
+//	pending/<hash>  URLs waiting to be claimed
+//	leased/<hash>.<unix nanos>.<worker>  claimed URLs and when they were claimed
+type FileQueue struct {
+	dir    string
+	worker string
+	lease  time.Duration
+	now    func() time.Time
+}
+
+var workerIDUnsafe = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// NewFileQueue opens (creating if needed) a file queue in dir for worker.
+// A lease <= 0 means DefaultQueueLease.
+func NewFileQueue(dir, worker string, lease time.Duration) (*FileQueue, error) {
+	worker = workerIDUnsafe.ReplaceAllString(worker, "_")
+	if worker == "" {
+		return nil, errors.New("queue worker id is required")
+	}
+	if lease <= 0 {
+		lease = DefaultQueueLease
+	}
+	for _, sub := range []string{"seen", "pending", "leased", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return nil, fmt.Errorf("create queue dir: %w", err)
+		}
+	}
+	return &FileQueue{dir: dir, worker: worker, lease: lease, now: time.Now}, nil
+}
+
+// Worker returns the sanitized worker ID.
+func (q *FileQueue) Worker() string {
+	return q.worker
+}
+
+func queueKey(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Push makes url pending before marking it seen, so a worker that dies in
+// between leaves a URL that is fetched (at worst twice), never one that is
+// marked seen but was never queued.
+func (q *FileQueue) Push(url string, depth int) error {
+	data, err := json.Marshal(QueueItem{URL: url, Depth: depth})
+	if err != nil {
+		return err
+	}
+	key := queueKey(url)
+	seen := filepath.Join(q.dir, "seen", key)
+	if _, err := os.Stat(seen); err == nil {
+		return nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	// Write then rename so a claimer never reads a partial file.
+	tmp := filepath.Join(q.dir, "tmp", key+"."+q.worker)
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(q.dir, "pending", key)); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(seen, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return nil // pushed by another worker at the same time
+		}
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (q *FileQueue) Claim() (QueueItem, bool, error) {
+	item, ok, err := q.claimPending()
+	if ok || err != nil {
+		return item, ok, err
+	}
+	reclaimed, err := q.reclaimExpired()
+	if err != nil || reclaimed == 0 {
+		return QueueItem{}, false, err
+	}
+	return q.claimPending()
+}
+
+func (q *FileQueue) claimPending() (QueueItem, bool, error) {
+	names, err := readNames(filepath.Join(q.dir, "pending"), 64)
+	if err != nil {
+		return QueueItem{}, false, err
+	}
+	for _, key := range names {
+		lease := fmt.Sprintf("%s.%d.%s", key, q.now().UnixNano(), q.worker)
+		leasePath := filepath.Join(q.dir, "leased", lease)
+		if err := os.Rename(filepath.Join(q.dir, "pending", key), leasePath); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue // another worker got it first
+			}
+			return QueueItem{}, false, err
+		}
+		data, err := os.ReadFile(leasePath)
+		if err != nil {
+			return QueueItem{}, false, err
+		}
+		var item QueueItem
+		if err := json.Unmarshal(data, &item); err != nil {
+			return QueueItem{}, false, fmt.Errorf("parse queue item %s: %w", key, err)
+		}
+		item.lease = lease
+		return item, true, nil
+	}
+	return QueueItem{}, false, nil
+}
+
+// reclaimExpired moves leases older than the lease duration back to pending.
+func (q *FileQueue) reclaimExpired() (int, error) {
+	names, err := readNames(filepath.Join(q.dir, "leased"), -1)
+	if err != nil {
+		return 0, err
+	}
+	reclaimed := 0
+	for _, name := range names {
+		key, claimedAt, ok := parseLease(name)
+		if !ok || q.now().Sub(claimedAt) < q.lease {
+			continue
+		}
+		err := os.Rename(filepath.Join(q.dir, "leased", name), filepath.Join(q.dir, "pending", key))
+		if err == nil {
+			reclaimed++
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return reclaimed, err
+		}
+	}
+	return reclaimed, nil
+}
+
+func (q *FileQueue) Done(item QueueItem) error {
+	if item.lease == "" {
+		return nil
+	}
+	err := os.Remove(filepath.Join(q.dir, "leased", item.lease))
+	if errors.Is(err, os.ErrNotExist) {
+		// The lease expired and another worker took the URL over.
+		return nil
+	}
+	return err
+}
+
+func (q *FileQueue) Drained() (bool, error) {
+	for _, sub := range []string{"pending", "leased"} {
+		names, err := readNames(filepath.Join(q.dir, sub), 1)
+		if err != nil {
+			return false, err
+		}
+		if len(names) > 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func parseLease(name string) (string, time.Time, bool) {
+	parts := strings.SplitN(name, ".", 3)
+	if len(parts) != 3 {
+		return "", time.Time{}, false
+	}
+	nanos, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return parts[0], time.Unix(0, nanos), true
+}
+
+// readNames lists up to n entries of dir (all when n < 0).
+func readNames(dir string, n int) ([]string, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	names, err := f.Readdirnames(n)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return names, nil
+}
+
+// queueDepthKey carries a queued URL's link depth in the colly request
+// context.
+const queueDepthKey = "go_scrap_queue_depth"
+
+// queuePollInterval is how long a worker waits for other workers to push
+// more URLs when nothing is pending.
+var queuePollInterval = time.Second
+
+// crawlQueue claims URLs from the shared queue until it is drained or this
+// worker has claimed MaxPages of them. Links are pushed to the queue instead
+// of being visited directly.
+func (cr *Crawler) crawlQueue(ctx context.Context) (map[string]*Result, Stats, error) {
+	q := cr.opts.Queue
+	if err := q.Push(queueURL(cr.opts.BaseURL), 1); err != nil {
+		return nil, cr.stats, fmt.Errorf("push start URL: %w", err)
+	}
+
+	claimed := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return cr.results, cr.stats, err
+		}
+		batch := make([]QueueItem, 0, cr.opts.Parallelism)
+		for len(batch) < cr.opts.Parallelism && claimed < cr.opts.MaxPages {
+			item, ok, err := q.Claim()
+			if err != nil {
+				return cr.results, cr.stats, fmt.Errorf("claim from queue: %w", err)
+			}
+			if !ok {
+				break
+			}
+			batch = append(batch, item)
+			claimed++
+		}
+
+		if len(batch) == 0 {
+			if claimed >= cr.opts.MaxPages {
+				break
+			}
+			drained, err := q.Drained()
+			if err != nil {
+				return cr.results, cr.stats, fmt.Errorf("check queue: %w", err)
+			}
+			if drained {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return cr.results, cr.stats, ctx.Err()
+			case <-time.After(queuePollInterval):
+			}
+			continue
+		}
+
+		for _, item := range batch {
+			rctx := colly.NewContext()
+			rctx.Put(queueDepthKey, item.Depth)
+			// Already-visited and off-domain URLs are rejected by colly and
+			// simply released below.
+			_ = cr.collector.Request(http.MethodGet, item.URL, nil, rctx, nil)
+		}
+		cr.collector.Wait()
+		for _, item := range batch {
+			if err := q.Done(item); err != nil {
+				return cr.results, cr.stats, fmt.Errorf("release queue item: %w", err)
+			}
+		}
+	}
+
+	cr.stats.CompletedAt = time.Now()
+	return cr.results, cr.stats, nil
+}
+
+// pushLink queues a link found on a page claimed from the shared queue.
+func (cr *Crawler) pushLink(e *colly.HTMLElement, link string) {
+	depth, _ := e.Request.Ctx.GetAny(queueDepthKey).(int)
+	if depth >= cr.opts.MaxDepth {
+		return
+	}
+	if _, err := url.Parse(link); err != nil {
+		return
+	}
+	if cr.offDomain(link) {
+		cr.skip(link, SkipOffDomain)
+		return
+	}
+	if err := cr.opts.Queue.Push(queueURL(link), depth+1); err != nil {
+		cr.mu.Lock()
+		cr.stats.Errors = append(cr.stats.Errors, fmt.Sprintf("%s: queue push: %v", link, err))
+		cr.mu.Unlock()
+	}
+}
+
+// queueURL drops the fragment and spells an empty path as "/" so that
+// workers agree on one queue key per page.
+func queueURL(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	if u.Path == "" && u.Opaque == "" {
+		u.Path = "/"
+	}
+	return u.String()
+}
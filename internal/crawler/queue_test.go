@@ -0,0 +1,177 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileQueue_PushDedupesAndClaims(t *testing.T) {
+	q, err := NewFileQueue(t.TempDir(), "w1", time.Minute)
+	if err != nil {
+		t.Fatalf("new queue: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := q.Push("https://example.com/a", 2); err != nil {
+			t.Fatalf("push: %v", err)
+		}
+	}
+
+	item, ok, err := q.Claim()
+	if err != nil || !ok {
+		t.Fatalf("claim: ok=%v err=%v", ok, err)
+	}
+	if item.URL != "https://example.com/a" || item.Depth != 2 {
+		t.Fatalf("unexpected item %+v", item)
+	}
+	if _, ok, _ := q.Claim(); ok {
+		t.Fatal("duplicate push should not be claimable twice")
+	}
+	if drained, _ := q.Drained(); drained {
+		t.Fatal("queue with a leased item should not be drained")
+	}
+	if err := q.Done(item); err != nil {
+		t.Fatalf("done: %v", err)
+	}
+	if drained, _ := q.Drained(); !drained {
+		t.Fatal("expected drained queue")
+	}
+}
+
+func TestFileQueue_PushMarksSeenOnlyOncePending(t *testing.T) {
+	dir := t.TempDir()
+	q, err := NewFileQueue(dir, "w1", time.Minute)
+	if err != nil {
+		t.Fatalf("new queue: %v", err)
+	}
+	// A pending file that cannot be written plays a worker dying mid-push.
+	if err := os.RemoveAll(filepath.Join(dir, "tmp")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "tmp"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Push("https://example.com/a", 1); err == nil {
+		t.Fatal("expected the push to fail")
+	}
+	if names, _ := os.ReadDir(filepath.Join(dir, "seen")); len(names) != 0 {
+		t.Fatalf("URL marked seen without being queued: %v", names)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "tmp")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "tmp"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Push("https://example.com/a", 1); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	if item, ok, err := q.Claim(); err != nil || !ok || item.URL != "https://example.com/a" {
+		t.Fatalf("expected the retried push to be claimable: %+v ok=%v err=%v", item, ok, err)
+	}
+}
+
+func TestFileQueue_ReclaimsExpiredLease(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	dead, _ := NewFileQueue(dir, "dead", time.Minute)
+	dead.now = func() time.Time { return now }
+	live, _ := NewFileQueue(dir, "live", time.Minute)
+	live.now = func() time.Time { return now }
+
+	if err := dead.Push("https://example.com/a", 1); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	if _, ok, _ := dead.Claim(); !ok {
+		t.Fatal("expected claim")
+	}
+	if _, ok, _ := live.Claim(); ok {
+		t.Fatal("lease should still be held")
+	}
+
+	live.now = func() time.Time { return now.Add(2 * time.Minute) }
+	item, ok, err := live.Claim()
+	if err != nil || !ok {
+		t.Fatalf("expected expired lease to be reclaimed: ok=%v err=%v", ok, err)
+	}
+	if item.URL != "https://example.com/a" {
+		t.Fatalf("unexpected item %+v", item)
+	}
+}
+
+func TestCrawl_SharedQueueSplitsPages(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body><a href="/a">A</a><a href="/b">B</a><a href="/c#top">C</a></body></html>`))
+	})
+	for _, p := range []string{"/a", "/b", "/c"} {
+		mux.HandleFunc(p, func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(`<html><body><a href="/">Home</a></body></html>`))
+		})
+	}
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	var (
+		mu   sync.Mutex
+		seen []string
+		wg   sync.WaitGroup
+	)
+	for _, worker := range []string{"w1", "w2"} {
+		q, err := NewFileQueue(dir, worker, time.Minute)
+		if err != nil {
+			t.Fatalf("new queue: %v", err)
+		}
+		c, err := New(Options{
+			BaseURL:         srv.URL,
+			RateLimit:       50,
+			MaxPages:        10,
+			MaxDepth:        3,
+			Timeout:         5 * time.Second,
+			AllowAllDomains: true,
+			Queue:           q,
+		})
+		if err != nil {
+			t.Fatalf("create crawler: %v", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results, _, err := c.Crawl(ctx)
+			if err != nil {
+				t.Errorf("crawl: %v", err)
+				return
+			}
+			mu.Lock()
+			for u := range results {
+				seen = append(seen, u)
+			}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	sort.Strings(seen)
+	want := []string{srv.URL + "/", srv.URL + "/a", srv.URL + "/b", srv.URL + "/c"}
+	if len(seen) != len(want) {
+		t.Fatalf("expected each page crawled once across workers, got %v", seen)
+	}
+	for i := range want {
+		if seen[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, seen)
+		}
+	}
+}
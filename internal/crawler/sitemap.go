@@ -2,12 +2,15 @@ package crawler
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
 	"time"
+
+	"go_scrap/internal/fetch"
 )
 
 type urlset struct {
@@ -32,6 +35,10 @@ type sitemapLocation struct {
 type SitemapOptions struct {
 	UserAgent string
 	Timeout   time.Duration
+	// TLSConfig carries a client certificate for sites that require mutual TLS.
+	TLSConfig *tls.Config
+	// WrapTransport, when set, wraps the HTTP transport (fetch middleware).
+	WrapTransport func(http.RoundTripper) http.RoundTripper
 }
 
 func ParseSitemap(ctx context.Context, sitemapURL string, opts SitemapOptions) ([]string, error) {
@@ -66,8 +73,21 @@ func fetchSitemapContent(ctx context.Context, url string, opts SitemapOptions) (
 		return nil, fmt.Errorf("create request: %w", err)
 	}
 	req.Header.Set("User-Agent", opts.UserAgent)
+	if err := fetch.WaitURL(ctx, url, 0); err != nil {
+		return nil, err
+	}
 
-	resp, err := http.DefaultClient.Do(req)
+	client := http.DefaultClient
+	if opts.TLSConfig != nil || opts.WrapTransport != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = opts.TLSConfig
+		var rt http.RoundTripper = transport
+		if opts.WrapTransport != nil {
+			rt = opts.WrapTransport(rt)
+		}
+		client = &http.Client{Transport: rt}
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("fetch sitemap: %w", err)
 	}
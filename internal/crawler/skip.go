@@ -0,0 +1,85 @@
+package crawler
+
+import (
+	"errors"
+	"net/url"
+
+	"go_scrap/internal/policy"
+)
+
+// SkipCategory says why a URL found by a crawl was not fetched or a fetched
+// page was not written. Stats and the crawl index count URLs per category;
+// PageEntry.SkipReason keeps the details.
+type SkipCategory string
+
+const (
+	// SkipFiltered: excluded by --crawl-filter, --soft-pages, --since/--until
+	// or --max-page-chars.
+	SkipFiltered SkipCategory = "filtered"
+	// SkipOffDomain: a link to another host.
+	SkipOffDomain SkipCategory = "off-domain"
+	// SkipError: the fetch or the page's processing failed.
+	SkipError SkipCategory = "error"
+	// SkipEmpty: no content, or less than --min-page-chars.
+	SkipEmpty SkipCategory = "empty"
+	// SkipDuplicate: a link to a URL the crawl had already queued.
+	SkipDuplicate SkipCategory = "duplicate"
+	// SkipPolicy: refused by the organization policy.
+	SkipPolicy SkipCategory = "policy"
+)
+
+// ErrorCategory is the category of a page that failed with err.
+func ErrorCategory(err error) SkipCategory {
+	var violation *policy.Error
+	if errors.As(err, &violation) {
+		return SkipPolicy
+	}
+	return SkipError
+}
+
+// AddSkipCounts adds the counts of from to to, allocating it when needed.
+func AddSkipCounts(to map[SkipCategory]int, from map[SkipCategory]int) map[SkipCategory]int {
+	for category, n := range from {
+		if to == nil {
+			to = map[SkipCategory]int{}
+		}
+		to[category] += n
+	}
+	return to
+}
+
+// skip counts link under category.
+func (cr *Crawler) skip(link string, category SkipCategory) {
+	if u, err := url.Parse(link); err == nil {
+		u.Fragment = ""
+		u.RawFragment = ""
+		link = u.String()
+	}
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	cr.countSkip(link, category)
+}
+
+// countSkip counts link under category once, however often it is found. It
+// runs with cr.mu held.
+func (cr *Crawler) countSkip(link string, category SkipCategory) {
+	urls, ok := cr.skipped[category]
+	if !ok {
+		urls = map[string]struct{}{}
+		cr.skipped[category] = urls
+	}
+	if _, ok := urls[link]; ok {
+		return
+	}
+	urls[link] = struct{}{}
+	if cr.stats.Skipped == nil {
+		cr.stats.Skipped = map[SkipCategory]int{}
+	}
+	cr.stats.Skipped[category]++
+}
+
+// offDomain reports whether link is on a host the crawl does not follow.
+func (cr *Crawler) offDomain(link string) bool {
+	u, err := url.Parse(link)
+	return err == nil && !cr.opts.AllowAllDomains && u.Host != cr.baseHost
+}
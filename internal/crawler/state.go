@@ -0,0 +1,360 @@
+package crawler
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"go_scrap/internal/seal"
+
+	"github.com/gocolly/colly/v2"
+	"github.com/gocolly/colly/v2/storage"
+)
+
+// crawlState persists a crawl in Options.StateDir as it runs so a process
+// that is killed or restarted can continue without fetching visited pages
+// again:
+//
+//	visited     colly request IDs of finished pages, one hex ID per line
+//	pages.jsonl fetched pages (HTML, errors and provenance), one sealed
+//	            line per page when the crawl encrypts its state
+//	links.jsonl links queued for crawling and their depth
+//
+// The state is removed once a crawl completes, so resuming a finished crawl
+// starts over (and the output-level resume skips unchanged pages).
+type crawlState struct {
+	dir     string
+	storage *fileStorage
+	mu      sync.Mutex
+	pages   *os.File
+	links   *os.File
+	sealer  *seal.Sealer
+	// Loaded from a previous run.
+	results map[string]*Result
+	queued  []stateLink
+}
+
+type statePage struct {
+	URL         string     `json:"url"`
+	HTML        string     `json:"html,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	FetchedAt   time.Time  `json:"fetched_at"`
+	ContentHash string     `json:"content_hash,omitempty"`
+	Provenance  Provenance `json:"provenance"`
+}
+
+type stateLink struct {
+	URL   string `json:"url"`
+	Depth int    `json:"depth"`
+}
+
+// openCrawlState opens dir, discarding any previous state unless resume is
+// set. Pages are encrypted with sealer when it is not nil.
+func openCrawlState(dir string, resume bool, sealer *seal.Sealer) (*crawlState, error) {
+	if !resume {
+		if err := os.RemoveAll(dir); err != nil {
+			return nil, fmt.Errorf("reset crawl state: %w", err)
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create crawl state dir: %w", err)
+	}
+	st := &crawlState{
+		dir:     dir,
+		storage: &fileStorage{path: filepath.Join(dir, "visited")},
+		results: map[string]*Result{},
+		sealer:  sealer,
+	}
+	if err := readJSONLines(filepath.Join(dir, "pages.jsonl"), sealer, func(p statePage) {
+		result := &Result{
+			URL:         p.URL,
+			HTML:        p.HTML,
+			FetchedAt:   p.FetchedAt,
+			ContentHash: p.ContentHash,
+			Provenance:  p.Provenance,
+		}
+		if p.Error != "" {
+			result.Error = errors.New(p.Error)
+		}
+		st.results[p.URL] = result
+	}); err != nil {
+		return nil, err
+	}
+	if err := readJSONLines(filepath.Join(dir, "links.jsonl"), nil, func(l stateLink) {
+		st.queued = append(st.queued, l)
+	}); err != nil {
+		return nil, err
+	}
+	var err error
+	if st.pages, err = openAppend(filepath.Join(dir, "pages.jsonl")); err != nil {
+		return nil, err
+	}
+	if st.links, err = openAppend(filepath.Join(dir, "links.jsonl")); err != nil {
+		_ = st.pages.Close()
+		return nil, err
+	}
+	return st, nil
+}
+
+// resumed reports whether a previous run left pages or links behind.
+func (st *crawlState) resumed() bool {
+	return len(st.results) > 0 || len(st.queued) > 0
+}
+
+// page records a fetched (or failed) page and marks its URL visited, along
+// with requestedURL when a redirect led from it to r.URL.
+func (st *crawlState) page(r *Result, requestedURL string) error {
+	p := statePage{
+		URL:         r.URL,
+		HTML:        r.HTML,
+		FetchedAt:   r.FetchedAt,
+		ContentHash: r.ContentHash,
+		Provenance:  r.Provenance,
+	}
+	if r.Error != nil {
+		p.Error = r.Error.Error()
+	}
+	if err := st.appendJSON(st.pages, p, st.sealer); err != nil {
+		return err
+	}
+	if requestedURL != "" && requestedURL != r.URL {
+		if err := st.storage.commit(requestedURL); err != nil {
+			return err
+		}
+	}
+	return st.storage.commit(r.URL)
+}
+
+// link records a URL handed to the collector.
+func (st *crawlState) link(u string, depth int) error {
+	return st.appendJSON(st.links, stateLink{URL: u, Depth: depth}, nil)
+}
+
+func (st *crawlState) appendJSON(f *os.File, v any, sealer *seal.Sealer) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	line, err := sealer.SealString(data)
+	if err != nil {
+		return err
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	_, err = f.WriteString(line + "\n")
+	return err
+}
+
+func (st *crawlState) close() {
+	_ = st.pages.Close()
+	_ = st.links.Close()
+	st.storage.close()
+}
+
+// finish removes the state of a completed crawl.
+func (st *crawlState) finish() error {
+	st.close()
+	return os.RemoveAll(st.dir)
+}
+
+// fileStorage is a colly storage whose visited set survives restarts.
+// Requests are visited in memory as colly issues them, but only persisted
+// through commit once their page is recorded, so pages in flight when the
+// process stopped are fetched again. Cookies are kept in memory.
+type fileStorage struct {
+	storage.InMemoryStorage
+	path      string
+	mu        sync.RWMutex
+	persisted map[uint64]struct{}
+	f         *os.File
+}
+
+func (s *fileStorage) Init() error {
+	if err := s.InMemoryStorage.Init(); err != nil {
+		return err
+	}
+	s.persisted = map[uint64]struct{}{}
+	if data, err := os.Open(s.path); err == nil {
+		scanner := bufio.NewScanner(data)
+		for scanner.Scan() {
+			if id, err := strconv.ParseUint(scanner.Text(), 16, 64); err == nil {
+				s.persisted[id] = struct{}{}
+			}
+		}
+		_ = data.Close()
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("read crawl state: %w", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("read crawl state: %w", err)
+	}
+	f, err := openAppend(s.path)
+	if err != nil {
+		return err
+	}
+	s.f = f
+	return nil
+}
+
+func (s *fileStorage) IsVisited(requestID uint64) (bool, error) {
+	s.mu.RLock()
+	_, ok := s.persisted[requestID]
+	s.mu.RUnlock()
+	if ok {
+		return true, nil
+	}
+	return s.InMemoryStorage.IsVisited(requestID)
+}
+
+// commit persists pageURL as visited. The ID matches colly's request hash
+// for GET requests of an already normalized URL.
+func (s *fileStorage) commit(pageURL string) error {
+	id := visitID(pageURL)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.persisted[id]; ok {
+		return nil
+	}
+	s.persisted[id] = struct{}{}
+	_, err := fmt.Fprintf(s.f, "%016x\n", id)
+	return err
+}
+
+func (s *fileStorage) close() {
+	if s.f != nil {
+		_ = s.f.Close()
+	}
+}
+
+func visitID(pageURL string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(pageURL))
+	return h.Sum64()
+}
+
+func openAppend(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open crawl state: %w", err)
+	}
+	return f, nil
+}
+
+// readJSONLines decodes each line of path into fn, decrypting sealed lines
+// with sealer. A missing file and a torn last line (the process died
+// mid-write) are not errors; lines that cannot be decrypted are skipped, so
+// their pages are fetched again.
+func readJSONLines[T any](path string, sealer *seal.Sealer, fn func(T)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("read crawl state: %w", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line, err := sealer.OpenString(scanner.Bytes())
+		if err != nil {
+			continue
+		}
+		var v T
+		if err := json.Unmarshal(line, &v); err != nil {
+			continue
+		}
+		fn(v)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read crawl state %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// depthOffsetKey carries, in the colly request context, how much deeper than
+// colly's own count a request resumed from saved links is.
+const depthOffsetKey = "go_scrap_depth_offset"
+
+// requestedURLKey carries, in the colly request context, the URL a request
+// was made for. colly replaces r.URL with the target of a redirect, while
+// the visited ID of the request is that of the URL requested.
+const requestedURLKey = "go_scrap_requested_url"
+
+// requestedURL is the URL r was made for, before any redirect.
+func requestedURL(r *colly.Request) string {
+	return r.Ctx.Get(requestedURLKey)
+}
+
+// linkDepth is the depth of r from the start URL.
+func linkDepth(r *colly.Request) int {
+	offset, _ := r.Ctx.GetAny(depthOffsetKey).(int)
+	return r.Depth + offset
+}
+
+// saveState records a finished page fetched for req; the caller holds cr.mu.
+func (cr *Crawler) saveState(r *Result, req *colly.Request) {
+	if cr.state == nil {
+		return
+	}
+	if err := cr.state.page(r, requestedURL(req)); err != nil {
+		cr.stats.Errors = append(cr.stats.Errors, fmt.Sprintf("%s: crawl state: %v", r.URL, err))
+	}
+}
+
+// saveLink records a URL handed to the collector.
+func (cr *Crawler) saveLink(u string, depth int) {
+	if cr.state == nil {
+		return
+	}
+	if err := cr.state.link(u, depth); err != nil {
+		cr.mu.Lock()
+		cr.stats.Errors = append(cr.stats.Errors, fmt.Sprintf("%s: crawl state: %v", u, err))
+		cr.mu.Unlock()
+	}
+}
+
+// restoreState loads pages saved by a previous run and re-queues saved links
+// that were not fetched yet.
+func (cr *Crawler) restoreState() {
+	st := cr.state
+	cr.mu.Lock()
+	for _, r := range st.results {
+		cr.results[r.URL] = r
+		if r.Error != nil {
+			cr.stats.PagesFailed++
+			cr.stats.Errors = append(cr.stats.Errors, fmt.Sprintf("%s: %v", r.URL, r.Error))
+		} else {
+			cr.stats.PagesCrawled++
+		}
+	}
+	cr.urlCount = len(st.results)
+	cr.mu.Unlock()
+
+	if visited, _ := cr.collector.HasVisited(cr.opts.BaseURL); !visited {
+		cr.urlCount++
+	}
+	for _, l := range st.queued {
+		if _, done := cr.results[l.URL]; done {
+			continue
+		}
+		if visited, _ := cr.collector.HasVisited(l.URL); visited {
+			continue
+		}
+		if !cr.incrementURLCount(l.Depth) {
+			cr.addFrontier(l.URL)
+			continue
+		}
+		rctx := colly.NewContext()
+		rctx.Put(depthOffsetKey, l.Depth-1)
+		_ = cr.collector.Request(http.MethodGet, l.URL, nil, rctx, nil)
+	}
+}
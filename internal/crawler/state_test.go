@@ -0,0 +1,153 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go_scrap/internal/seal"
+)
+
+func TestCrawl_ResumesFromStateAfterRestart(t *testing.T) {
+	stateDir := filepath.Join(t.TempDir(), "state")
+	release := make(chan struct{})
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	defer cancel1()
+
+	var (
+		mu   sync.Mutex
+		hits = map[string]int{}
+	)
+	page := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			hits[r.URL.Path]++
+			n := hits[r.URL.Path]
+			mu.Unlock()
+			// The first request for /b plays a process dying mid-crawl: it
+			// stops the crawl once /a is saved and never answers.
+			if r.URL.Path == "/b" && n == 1 {
+				waitForState(t, stateDir, "/a")
+				cancel1()
+				<-release
+				return
+			}
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(body))
+		}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", page(`<html><body><a href="/old-a">A</a><a href="/b">B</a></body></html>`))
+	// The link to /a is saved as /old-a, so the resumed crawl has to know
+	// the redirected request was done.
+	mux.HandleFunc("/old-a", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+		http.Redirect(w, r, "/a", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/a", page(`<html><body>A</body></html>`))
+	mux.HandleFunc("/b", page(`<html><body>B</body></html>`))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	defer close(release)
+
+	newCrawler := func(resume bool) *Crawler {
+		c, err := New(Options{
+			BaseURL:         srv.URL,
+			RateLimit:       50,
+			MaxPages:        10,
+			MaxDepth:        2,
+			Timeout:         5 * time.Second,
+			AllowAllDomains: true,
+			StateDir:        stateDir,
+			ResumeState:     resume,
+		})
+		if err != nil {
+			t.Fatalf("create crawler: %v", err)
+		}
+		return c
+	}
+
+	if _, _, err := newCrawler(false).Crawl(ctx1); err == nil {
+		t.Fatal("expected the first crawl to be interrupted")
+	}
+
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel2()
+	results, stats, err := newCrawler(true).Crawl(ctx2)
+	if err != nil {
+		t.Fatalf("resumed crawl: %v", err)
+	}
+	if len(results) != 3 || stats.PagesCrawled != 3 {
+		t.Fatalf("expected 3 pages after resume, got %d (%+v)", len(results), stats)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if hits["/"] != 1 || hits["/old-a"] != 1 || hits["/a"] != 1 || hits["/b"] != 2 {
+		t.Fatalf("expected only /b to be fetched again, got %v", hits)
+	}
+	if _, err := os.Stat(stateDir); !os.IsNotExist(err) {
+		t.Fatalf("expected state to be removed after the crawl completed, got %v", err)
+	}
+}
+
+func waitForState(t *testing.T, stateDir, path string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		data, _ := os.ReadFile(filepath.Join(stateDir, "pages.jsonl"))
+		if strings.Contains(string(data), path+`"`) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("%s never saved to crawl state", path)
+}
+
+func TestCrawlState_EncryptsSavedPages(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state")
+	sealer, err := seal.New([]byte(strings.Repeat("k", 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := openCrawlState(dir, false, sealer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.storage.Init(); err != nil { // colly does this for a crawl
+		t.Fatal(err)
+	}
+	if err := st.page(&Result{URL: "https://example.com/a", HTML: "<p>private</p>"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	st.close()
+
+	data, _ := os.ReadFile(filepath.Join(dir, "pages.jsonl"))
+	if strings.Contains(string(data), "private") || strings.Contains(string(data), "example.com") {
+		t.Fatalf("expected pages.jsonl to be encrypted, got %s", data)
+	}
+	resumed, err := openCrawlState(dir, true, sealer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resumed.close()
+	if r := resumed.results["https://example.com/a"]; r == nil || r.HTML != "<p>private</p>" {
+		t.Fatalf("expected the page to be restored with the key, got %+v", r)
+	}
+
+	withoutKey, err := openCrawlState(dir, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer withoutKey.close()
+	if len(withoutKey.results) != 0 {
+		t.Fatalf("expected sealed pages to be skipped without the key, got %v", withoutKey.results)
+	}
+}
@@ -0,0 +1,20 @@
+package crawler
+
+import (
+	"net/http"
+	"time"
+)
+
+// ThrottleEvent records a 429/503 response and how the crawler reacted.
+type ThrottleEvent struct {
+	URL         string    `json:"url"`
+	StatusCode  int       `json:"status_code"`
+	RetryAfter  float64   `json:"retry_after_seconds"`
+	Delay       float64   `json:"delay_seconds"`
+	Retried     bool      `json:"retried"`
+	ThrottledAt time.Time `json:"throttled_at"`
+}
+
+func isThrottleStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable
+}
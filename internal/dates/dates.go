@@ -0,0 +1,235 @@
+// Package dates finds publication and update dates in HTML pages and checks
+// them against a --since/--until window.
+package dates
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// layouts are tried in order by Parse. Layouts without a zone are read as
+// UTC.
+var layouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"2006/01/02",
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"Jan. 2, 2006",
+	"2 January 2006",
+	"2 Jan 2006",
+	"January 2006",
+}
+
+// Parse reads the date formats found in meta tags, <time> elements, JSON-LD
+// and common human-written dates.
+func Parse(s string) (time.Time, bool) {
+	s = strings.Join(strings.Fields(s), " ")
+	if s == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Format writes t as a date when it has no time of day, and as RFC 3339
+// otherwise. The zero time is "".
+func Format(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0 {
+		return t.Format("2006-01-02")
+	}
+	return t.Format(time.RFC3339)
+}
+
+// Normalize reformats a parsable date with Format and returns anything else
+// unchanged.
+func Normalize(s string) string {
+	if t, ok := Parse(s); ok {
+		return Format(t)
+	}
+	return strings.TrimSpace(s)
+}
+
+// Page holds a page's publication and last-update dates; either may be zero.
+type Page struct {
+	Published time.Time
+	Modified  time.Time
+}
+
+// Latest is Modified, or Published for pages that were never updated.
+func (p Page) Latest() time.Time {
+	if !p.Modified.IsZero() {
+		return p.Modified
+	}
+	return p.Published
+}
+
+var (
+	publishedMeta = []string{
+		`meta[property="article:published_time"]`,
+		`meta[itemprop="datePublished"]`,
+		`meta[name="date"]`,
+		`meta[name="DC.date"]`,
+		`meta[name="dcterms.created"]`,
+		`meta[name="publish_date"]`,
+	}
+	modifiedMeta = []string{
+		`meta[property="article:modified_time"]`,
+		`meta[property="og:updated_time"]`,
+		`meta[itemprop="dateModified"]`,
+		`meta[name="last-modified"]`,
+		`meta[name="dcterms.modified"]`,
+	}
+)
+
+// FromDocument reads a page's dates from, in order of preference, JSON-LD
+// datePublished/dateModified, meta tags, and the first <time datetime>
+// element (as the publication date).
+func FromDocument(doc *goquery.Document) Page {
+	var p Page
+	if doc == nil {
+		return p
+	}
+	doc.Find(`script[type="application/ld+json"]`).EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		published, modified := jsonLDDates(s.Text())
+		if p.Published.IsZero() {
+			p.Published = published
+		}
+		if p.Modified.IsZero() {
+			p.Modified = modified
+		}
+		return p.Published.IsZero() || p.Modified.IsZero()
+	})
+	if p.Published.IsZero() {
+		p.Published = firstMeta(doc, publishedMeta)
+	}
+	if p.Modified.IsZero() {
+		p.Modified = firstMeta(doc, modifiedMeta)
+	}
+	if p.Published.IsZero() {
+		doc.Find("time[datetime]").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+			t, ok := Parse(s.AttrOr("datetime", ""))
+			if ok {
+				p.Published = t
+			}
+			return !ok
+		})
+	}
+	return p
+}
+
+func firstMeta(doc *goquery.Document, selectors []string) time.Time {
+	for _, sel := range selectors {
+		var found time.Time
+		doc.Find(sel).EachWithBreak(func(_ int, s *goquery.Selection) bool {
+			t, ok := Parse(s.AttrOr("content", ""))
+			if ok {
+				found = t
+			}
+			return !ok
+		})
+		if !found.IsZero() {
+			return found
+		}
+	}
+	return time.Time{}
+}
+
+// jsonLDDates returns the first datePublished and dateModified found in a
+// JSON-LD block, searching nested objects, arrays and @graph.
+func jsonLDDates(text string) (published, modified time.Time) {
+	var v any
+	if err := json.Unmarshal([]byte(text), &v); err != nil {
+		return published, modified
+	}
+	var walk func(any)
+	walk = func(v any) {
+		switch node := v.(type) {
+		case map[string]any:
+			if s, ok := node["datePublished"].(string); ok && published.IsZero() {
+				published, _ = Parse(s)
+			}
+			if s, ok := node["dateModified"].(string); ok && modified.IsZero() {
+				modified, _ = Parse(s)
+			}
+			for _, child := range node {
+				walk(child)
+			}
+		case []any:
+			for _, child := range node {
+				walk(child)
+			}
+		}
+	}
+	walk(v)
+	return published, modified
+}
+
+// Window is a --since/--until range; a zero bound is open.
+type Window struct {
+	Since time.Time
+	Until time.Time
+}
+
+// ParseWindow reads --since and --until values. A date-only until includes
+// that whole day.
+func ParseWindow(since, until string) (Window, error) {
+	var w Window
+	if strings.TrimSpace(since) != "" {
+		t, ok := Parse(since)
+		if !ok {
+			return w, fmt.Errorf("invalid since date %q (expected YYYY-MM-DD or RFC 3339)", since)
+		}
+		w.Since = t
+	}
+	if strings.TrimSpace(until) != "" {
+		t, ok := Parse(until)
+		if !ok {
+			return w, fmt.Errorf("invalid until date %q (expected YYYY-MM-DD or RFC 3339)", until)
+		}
+		if Format(t) == t.Format("2006-01-02") {
+			t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		w.Until = t
+	}
+	if !w.Since.IsZero() && !w.Until.IsZero() && w.Until.Before(w.Since) {
+		return w, fmt.Errorf("until (%s) is before since (%s)", until, since)
+	}
+	return w, nil
+}
+
+// IsZero reports whether the window has no bounds.
+func (w Window) IsZero() bool {
+	return w.Since.IsZero() && w.Until.IsZero()
+}
+
+// Contains reports whether t is inside the window. Undated content (zero t)
+// is always inside.
+func (w Window) Contains(t time.Time) bool {
+	if t.IsZero() {
+		return true
+	}
+	if !w.Since.IsZero() && t.Before(w.Since) {
+		return false
+	}
+	return w.Until.IsZero() || !t.After(w.Until)
+}
@@ -0,0 +1,84 @@
+package dates
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestParse_CommonFormats(t *testing.T) {
+	want := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	for _, s := range []string{"2024-03-05", "2024/03/05", "March 5, 2024", "Mar 5, 2024", "5 March 2024", "  5   Mar 2024 "} {
+		got, ok := Parse(s)
+		if !ok || !got.Equal(want) {
+			t.Errorf("Parse(%q) = %v, %v; want %v", s, got, ok, want)
+		}
+	}
+	if got, ok := Parse("2024-03-05T10:30:00+02:00"); !ok || Format(got) != "2024-03-05T10:30:00+02:00" {
+		t.Errorf("expected RFC 3339 with time to round-trip, got %v %v", got, ok)
+	}
+	if _, ok := Parse("last Tuesday"); ok {
+		t.Error("expected free text not to parse")
+	}
+}
+
+func TestFromDocument_PrefersJSONLDThenMetaThenTime(t *testing.T) {
+	doc := mustDoc(t, `<html><head>
+		<meta property="article:published_time" content="2020-01-01">
+		<meta property="article:modified_time" content="2021-02-03T04:05:06Z">
+		<script type="application/ld+json">{"@context":"https://schema.org","@graph":[{"@type":"Article","datePublished":"2022-06-07"}]}</script>
+	</head><body><time datetime="2019-09-09">Sep 9</time></body></html>`)
+	p := FromDocument(doc)
+	if Format(p.Published) != "2022-06-07" {
+		t.Errorf("expected JSON-LD datePublished, got %v", p.Published)
+	}
+	if Format(p.Modified) != "2021-02-03T04:05:06Z" {
+		t.Errorf("expected the modified meta tag, got %v", p.Modified)
+	}
+
+	p = FromDocument(mustDoc(t, `<body><p>x</p><time datetime="2019-09-09">Sep 9</time></body>`))
+	if Format(p.Published) != "2019-09-09" || !p.Modified.IsZero() {
+		t.Errorf("expected the <time> fallback, got %+v", p)
+	}
+	if Format(p.Latest()) != "2019-09-09" {
+		t.Errorf("expected Latest to fall back to Published, got %v", p.Latest())
+	}
+}
+
+func TestParseWindow(t *testing.T) {
+	w, err := ParseWindow("2024-01-01", "2024-03-31")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for date, want := range map[string]bool{
+		"2023-12-31":           false,
+		"2024-01-01":           true,
+		"2024-03-31T23:59:00Z": true,
+		"2024-04-01":           false,
+	} {
+		tm, _ := Parse(date)
+		if got := w.Contains(tm); got != want {
+			t.Errorf("Contains(%s) = %v, want %v", date, got, want)
+		}
+	}
+	if !w.Contains(time.Time{}) {
+		t.Error("expected undated content to be kept")
+	}
+	if _, err := ParseWindow("2024-05-01", "2024-04-01"); err == nil {
+		t.Error("expected until before since to be rejected")
+	}
+	if _, err := ParseWindow("soon", ""); err == nil || !strings.Contains(err.Error(), "since") {
+		t.Errorf("expected an invalid since error, got %v", err)
+	}
+}
+
+func mustDoc(t *testing.T, html string) *goquery.Document {
+	t.Helper()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
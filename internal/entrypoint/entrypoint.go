@@ -3,12 +3,24 @@ package entrypoint
 import (
 	"context"
 	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
 
 	"go_scrap/internal/app"
 	"go_scrap/internal/cli"
+	"go_scrap/internal/subcommands/cachecmd"
+	"go_scrap/internal/subcommands/configcmd"
+	"go_scrap/internal/subcommands/diffcmd"
 	"go_scrap/internal/subcommands/inspect"
+	"go_scrap/internal/subcommands/presetcmd"
+	"go_scrap/internal/subcommands/proxycmd"
+	"go_scrap/internal/subcommands/runall"
+	"go_scrap/internal/subcommands/selfupdate"
 	"go_scrap/internal/subcommands/testconfigs"
 	"go_scrap/internal/tui"
+	"go_scrap/internal/version"
 )
 
 func Execute(args []string) (int, error) {
@@ -18,11 +30,33 @@ func Execute(args []string) (int, error) {
 			return 0, inspect.Run(args[2:])
 		case "test-configs":
 			return 0, testconfigs.Run(args[2:])
+		case "run-all":
+			return 0, runall.Run(args[2:])
+		case "config":
+			return 0, configcmd.Run(args[2:])
+		case "cache":
+			return 0, cachecmd.Run(args[2:])
+		case "proxy":
+			return 0, proxycmd.Run(args[2:])
+		case "preset":
+			return 0, presetcmd.Run(args[2:])
+		case "diff":
+			return 0, diffcmd.Run(args[2:])
+		case "self-update":
+			return 0, selfupdate.Run(args[2:])
+		case "fetch", "transform":
+			return runStage(args[1], args[2:])
+		case "retry-failed":
+			return runRetryFailed(args[2:])
+		case "version", "--version", "-version":
+			fmt.Println(version.Get())
+			return 0, nil
 		}
 	}
 
-	if len(args) == 1 {
-		res, err := tui.Run()
+	if len(args) == 1 || (len(args) == 2 && args[1] == "--plain-tui") {
+		plain := len(args) == 2 || tui.PlainRequested(os.Getenv)
+		res, err := tui.Run(tui.Options{Plain: plain})
 		if err != nil {
 			return 1, err
 		}
@@ -31,7 +65,16 @@ func Execute(args []string) (int, error) {
 		}
 		ctx, cancel := context.WithTimeout(context.Background(), res.Options.Timeout)
 		defer cancel()
-		return 0, app.Run(ctx, res.Options)
+		startedAt := time.Now()
+		runErr := app.Run(ctx, res.Options)
+		path, err := tui.HistoryPath()
+		if err == nil {
+			err = tui.RecordRun(path, res, startedAt, runErr)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to record run history: %v\n", err)
+		}
+		return 0, runErr
 	}
 
 	opts, initConfig, err := cli.ParseArgs(args[1:])
@@ -46,8 +89,49 @@ func Execute(args []string) (int, error) {
 	if initConfig {
 		return 0, cli.RunConfigWizard()
 	}
+	if opts.Watch > 0 {
+		// --timeout bounds each cycle; the watch runs until interrupted.
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		return 0, app.Watch(ctx, opts)
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
 	defer cancel()
 	return 0, app.Run(ctx, opts)
 }
+
+// runStage runs the fetch or transform subcommand. Both take the scrape
+// flags plus --stage; transform works offline, so only fetch is bounded by
+// --timeout.
+func runStage(command string, args []string) (int, error) {
+	opts, _, err := cli.ParseArgs(args)
+	if err != nil {
+		var exitErr cli.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.Code, exitErr.Err
+		}
+		return 1, err
+	}
+	if command == "transform" {
+		return 0, app.Transform(context.Background(), opts)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+	return 0, app.Fetch(ctx, opts)
+}
+
+// runRetryFailed re-fetches the failed pages of the crawl in --output-dir.
+func runRetryFailed(args []string) (int, error) {
+	opts, err := cli.ParseRetryArgs(args)
+	if err != nil {
+		var exitErr cli.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.Code, exitErr.Err
+		}
+		return 1, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+	return 0, app.RetryFailed(ctx, opts)
+}
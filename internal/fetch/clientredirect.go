@@ -0,0 +1,174 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// MaxClientRedirects bounds the meta refresh and JavaScript redirects a
+// static fetch follows.
+const MaxClientRedirects = 5
+
+const (
+	// redirectShellChars is the visible text below which a page with a
+	// redirect counts as a redirect shell rather than content.
+	redirectShellChars = 200
+	// redirectScriptBytes bounds the inline script of a JavaScript redirect,
+	// so redirects buried in application bundles are left to the browser.
+	redirectScriptBytes = 1024
+)
+
+var (
+	refreshRe  = regexp.MustCompile(`(?i)^\s*(\d*\.?\d*)\s*[;,]?\s*(?:url\s*=\s*)?(.*)$`)
+	jsAssignRe = regexp.MustCompile(`(?:\b(?:window|document|top|self)\.)?\blocation(?:\.href)?\s*=\s*['"]([^'"]+)['"]`)
+	jsCallRe   = regexp.MustCompile(`\blocation\.(?:replace|assign)\(\s*['"]([^'"]+)['"]\s*\)`)
+)
+
+// fetchStaticFollowing fetches opts.URL statically and, while the page is a
+// redirect shell, fetches the page it redirects to. It returns the last
+// response and the hops it followed, and fails on a loop or after
+// MaxClientRedirects hops.
+func fetchStaticFollowing(ctx context.Context, opts Options) (staticResponse, []string, error) {
+	seen := map[string]bool{opts.URL: true}
+	var hops []string
+	for {
+		resp, err := staticFetch(ctx, opts)
+		if err != nil || resp.status == http.StatusNotModified {
+			return resp, hops, err
+		}
+		target, kind := clientRedirect(opts.URL, resp.html)
+		if target == "" {
+			if len(hops) > 0 {
+				// The page is used as if fetched from the original URL, so
+				// its relative links must not resolve against that.
+				resp.html = AbsoluteLinks(opts.URL, resp.html)
+				resp.finalURL = opts.URL
+			}
+			return resp, hops, nil
+		}
+		if seen[target] {
+			return staticResponse{}, hops, fmt.Errorf("client-side redirect loop: %s redirects back to %s", opts.URL, target)
+		}
+		if len(hops) == MaxClientRedirects {
+			return staticResponse{}, hops, fmt.Errorf("stopped after %d client-side redirects at %s", MaxClientRedirects, opts.URL)
+		}
+		seen[target] = true
+		hops = append(hops, kind+" to "+target)
+		opts.URL = target
+		// Validators belong to the page that was asked for, not its target.
+		opts.IfNoneMatch, opts.IfModifiedSince = "", ""
+	}
+}
+
+// clientRedirect returns the absolute URL a page redirects to with a meta
+// refresh or a small inline script, and which of the two it used. Pages
+// with real content are not redirects, whatever their markup says.
+func clientRedirect(pageURL, html string) (target, kind string) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return "", ""
+	}
+	body := doc.Find("body").First().Clone()
+	body.Find("script, style, template, noscript").Remove()
+	if utf8.RuneCountInString(strings.Join(strings.Fields(body.Text()), " ")) >= redirectShellChars {
+		return "", ""
+	}
+
+	if content, ok := doc.Find(`meta[http-equiv="refresh" i]`).First().Attr("content"); ok {
+		if m := refreshRe.FindStringSubmatch(content); m != nil {
+			if target := resolveRedirect(pageURL, strings.Trim(m[2], `'" `)); target != "" {
+				return target, "meta refresh"
+			}
+		}
+	}
+
+	var script strings.Builder
+	doc.Find("script:not([src])").Each(func(_ int, s *goquery.Selection) {
+		script.WriteString(s.Text())
+		script.WriteByte('\n')
+	})
+	if script.Len() > redirectScriptBytes {
+		return "", ""
+	}
+	for _, re := range []*regexp.Regexp{jsCallRe, jsAssignRe} {
+		if m := re.FindStringSubmatch(script.String()); m != nil {
+			if target := resolveRedirect(pageURL, m[1]); target != "" {
+				return target, "js redirect"
+			}
+		}
+	}
+	return "", ""
+}
+
+// resolveRedirect resolves ref against pageURL. It is empty unless ref
+// names another http(s) page, so a refresh that reloads the page, or one
+// to a fragment of it, is not a redirect.
+func resolveRedirect(pageURL, ref string) string {
+	if ref == "" {
+		return ""
+	}
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+	u, err := base.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	u.Fragment = ""
+	base.Fragment = ""
+	if u.String() == base.String() {
+		return ""
+	}
+	return u.String()
+}
+
+// AbsoluteLinks resolves the relative href and src attributes of html
+// against pageURL, or the page's <base href>. In-page fragment links are
+// left alone.
+func AbsoluteLinks(pageURL, html string) string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return html
+	}
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return html
+	}
+	if href, ok := doc.Find("base[href]").First().Attr("href"); ok {
+		if u, err := base.Parse(strings.TrimSpace(href)); err == nil {
+			base = u
+		}
+	}
+	for _, attr := range []string{"href", "src"} {
+		doc.Find("[" + attr + "]").Each(func(_ int, s *goquery.Selection) {
+			ref := strings.TrimSpace(s.AttrOr(attr, ""))
+			if ref == "" || strings.HasPrefix(ref, "#") {
+				return
+			}
+			if u, err := base.Parse(ref); err == nil && u.String() != ref {
+				s.SetAttr(attr, u.String())
+			}
+		})
+	}
+	out, err := doc.Html()
+	if err != nil {
+		return html
+	}
+	return out
+}
+
+// redirectSourceInfo appends the followed hops to a SourceInfo.
+func redirectSourceInfo(info string, hops []string) string {
+	if len(hops) == 0 {
+		return info
+	}
+	return info + " via " + strings.Join(hops, ", ")
+}
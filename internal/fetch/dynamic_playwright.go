@@ -2,26 +2,62 @@ package fetch
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"strings"
+	"net/http"
+	"net/url"
 	"time"
 
+	"go_scrap/internal/footprint"
+
 	"github.com/playwright-community/playwright-go"
 )
 
+// Browser is the browser engine of dynamic fetches and --nav-walk.
+type Browser string
+
+const (
+	BrowserChromium Browser = "chromium"
+	BrowserFirefox  Browser = "firefox"
+	BrowserWebKit   Browser = "webkit"
+)
+
+// ValidateBrowser checks a --browser name; empty means Chromium.
+func ValidateBrowser(b Browser) error {
+	switch b {
+	case "", BrowserChromium, BrowserFirefox, BrowserWebKit:
+		return nil
+	}
+	return fmt.Errorf("unknown browser %q (expected chromium, firefox or webkit)", b)
+}
+
+// browserType returns the Playwright browser type for b.
+func browserType(pw *playwright.Playwright, b Browser) playwright.BrowserType {
+	switch b {
+	case BrowserFirefox:
+		return pw.Firefox
+	case BrowserWebKit:
+		return pw.WebKit
+	default:
+		return pw.Chromium
+	}
+}
+
 type dynamicProvider interface {
 	Install() error
 	Run() (dynamicRunner, error)
 }
 
 type dynamicRunner interface {
-	ChromiumLaunch(headless bool, proxyURL string) (dynamicBrowser, error)
+	Launch(browser Browser, headless bool, proxyURL string) (dynamicBrowser, error)
 	Stop() error
 }
 
 type dynamicBrowser interface {
-	NewPage(userAgent string) (dynamicPage, error)
+	// NewPage opens a page, in a context restored from the storage state
+	// file when one is given.
+	NewPage(userAgent, storageState string) (dynamicPage, error)
 	Close() error
 }
 
@@ -30,39 +66,51 @@ type dynamicPage interface {
 	WaitFor(selector string, timeout time.Duration) error
 	Content() (string, error)
 	SetExtraHTTPHeaders(headers map[string]string) error
+	// ScrollHeight is the height of the rendered document in pixels.
+	ScrollHeight() (int, error)
+	ScrollToBottom() error
+	// ClickIfVisible clicks the first element matching selector and reports
+	// whether one was visible to click.
+	ClickIfVisible(selector string, timeout time.Duration) (bool, error)
+	Fill(selector, value string, timeout time.Duration) error
+	Click(selector string, timeout time.Duration) error
+	// SessionState returns the storage state of the page's context as JSON,
+	// and its cookies.
+	SessionState() ([]byte, []*http.Cookie, error)
 	Close() error
 }
 
-type playwrightProvider struct{}
+type playwrightProvider struct {
+	certs []playwright.ClientCertificate
+}
 
 func (playwrightProvider) Install() error {
 	return playwright.Install(&playwright.RunOptions{})
 }
 
-func (playwrightProvider) Run() (dynamicRunner, error) {
+func (p playwrightProvider) Run() (dynamicRunner, error) {
 	pw, err := playwright.Run()
 	if err != nil {
 		return nil, err
 	}
-	return &playwrightRunner{pw: pw}, nil
+	return &playwrightRunner{pw: pw, certs: p.certs}, nil
 }
 
 type playwrightRunner struct {
-	pw *playwright.Playwright
+	pw    *playwright.Playwright
+	certs []playwright.ClientCertificate
 }
 
-func (r *playwrightRunner) ChromiumLaunch(headless bool, proxyURL string) (dynamicBrowser, error) {
+func (r *playwrightRunner) Launch(b Browser, headless bool, proxyURL string) (dynamicBrowser, error) {
 	launchOpts := playwright.BrowserTypeLaunchOptions{
 		Headless: playwright.Bool(headless),
 	}
-	if proxyURL != "" {
-		launchOpts.Proxy = &playwright.Proxy{Server: proxyURL}
-	}
-	browser, err := r.pw.Chromium.Launch(launchOpts)
+	launchOpts.Proxy = playwrightProxy(proxyURL)
+	browser, err := browserType(r.pw, b).Launch(launchOpts)
 	if err != nil {
 		return nil, err
 	}
-	return &playwrightBrowser{browser: browser}, nil
+	return &playwrightBrowser{browser: browser, certs: r.certs}, nil
 }
 
 func (r *playwrightRunner) Stop() error {
@@ -71,12 +119,18 @@ func (r *playwrightRunner) Stop() error {
 
 type playwrightBrowser struct {
 	browser playwright.Browser
+	certs   []playwright.ClientCertificate
 }
 
-func (b *playwrightBrowser) NewPage(userAgent string) (dynamicPage, error) {
-	page, err := b.browser.NewPage(playwright.BrowserNewPageOptions{
-		UserAgent: playwright.String(userAgent),
-	})
+func (b *playwrightBrowser) NewPage(userAgent, storageState string) (dynamicPage, error) {
+	pageOpts := playwright.BrowserNewPageOptions{
+		UserAgent:          playwright.String(userAgent),
+		ClientCertificates: b.certs,
+	}
+	if storageState != "" {
+		pageOpts.StorageStatePath = playwright.String(storageState)
+	}
+	page, err := b.browser.NewPage(pageOpts)
 	if err != nil {
 		return nil, err
 	}
@@ -114,16 +168,93 @@ func (p *playwrightPage) SetExtraHTTPHeaders(headers map[string]string) error {
 	return p.page.SetExtraHTTPHeaders(headers)
 }
 
+func (p *playwrightPage) ScrollHeight() (int, error) {
+	v, err := p.page.Evaluate(`() => document.documentElement.scrollHeight`)
+	if err != nil {
+		return 0, err
+	}
+	switch n := v.(type) {
+	case int:
+		return n, nil
+	case float64:
+		return int(n), nil
+	}
+	return 0, fmt.Errorf("unexpected scroll height %v", v)
+}
+
+func (p *playwrightPage) ScrollToBottom() error {
+	_, err := p.page.Evaluate(`() => window.scrollTo(0, document.documentElement.scrollHeight)`)
+	return err
+}
+
+func (p *playwrightPage) ClickIfVisible(selector string, timeout time.Duration) (bool, error) {
+	loc := p.page.Locator(selector).First()
+	visible, err := loc.IsVisible()
+	if err != nil || !visible {
+		return false, err
+	}
+	return true, loc.Click(playwright.LocatorClickOptions{
+		Timeout: playwright.Float(float64(timeout.Milliseconds())),
+	})
+}
+
+func (p *playwrightPage) Fill(selector, value string, timeout time.Duration) error {
+	return p.page.Locator(selector).First().Fill(value, playwright.LocatorFillOptions{
+		Timeout: playwright.Float(float64(timeout.Milliseconds())),
+	})
+}
+
+func (p *playwrightPage) Click(selector string, timeout time.Duration) error {
+	return p.page.Locator(selector).First().Click(playwright.LocatorClickOptions{
+		Timeout: playwright.Float(float64(timeout.Milliseconds())),
+	})
+}
+
+func (p *playwrightPage) SessionState() ([]byte, []*http.Cookie, error) {
+	state, err := p.page.Context().StorageState()
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, nil, err
+	}
+	cookies := make([]*http.Cookie, 0, len(state.Cookies))
+	for _, c := range state.Cookies {
+		cookies = append(cookies, &http.Cookie{Name: c.Name, Value: c.Value, Domain: c.Domain, Path: c.Path, Secure: c.Secure, HttpOnly: c.HttpOnly})
+	}
+	return data, cookies, nil
+}
+
 func (p *playwrightPage) Close() error {
 	return p.page.Close()
 }
 
+// playwrightProxy maps a proxy URL onto Playwright, which takes credentials
+// separately rather than from the URL's userinfo as net/http does.
+func playwrightProxy(proxyURL string) *playwright.Proxy {
+	if proxyURL == "" {
+		return nil
+	}
+	u, err := url.Parse(proxyURL)
+	if err != nil || u.User == nil {
+		return &playwright.Proxy{Server: proxyURL}
+	}
+	proxy := &playwright.Proxy{Username: playwright.String(u.User.Username())}
+	if password, ok := u.User.Password(); ok {
+		proxy.Password = playwright.String(password)
+	}
+	u.User = nil
+	proxy.Server = u.String()
+	return proxy
+}
+
 func fetchDynamic(ctx context.Context, opts Options) (string, error) {
-	return fetchDynamicWith(ctx, opts, playwrightProvider{})
+	return fetchDynamicWith(ctx, opts, playwrightProvider{certs: clientCertificates(opts)})
 }
 
 func fetchDynamicWith(ctx context.Context, opts Options, provider dynamicProvider) (string, error) {
-	if err := waitForRateLimit(ctx, opts.RateLimitPerSecond); err != nil {
+	if err := WaitURL(ctx, opts.URL, opts.RateLimitPerSecond); err != nil {
 		return "", err
 	}
 
@@ -138,7 +269,7 @@ func fetchDynamicWith(ctx context.Context, opts Options, provider dynamicProvide
 		_ = runner.Stop()
 	}()
 
-	browser, err := runner.ChromiumLaunch(opts.Headless, opts.ProxyURL)
+	browser, err := runner.Launch(opts.Browser, opts.Headless, opts.ProxyURL)
 	if err != nil {
 		return "", err
 	}
@@ -146,7 +277,7 @@ func fetchDynamicWith(ctx context.Context, opts Options, provider dynamicProvide
 		_ = browser.Close()
 	}()
 
-	page, err := browser.NewPage(opts.UserAgent)
+	page, err := browser.NewPage(opts.UserAgent, opts.StorageState)
 	if err != nil {
 		return "", err
 	}
@@ -154,11 +285,13 @@ func fetchDynamicWith(ctx context.Context, opts Options, provider dynamicProvide
 		_ = page.Close()
 	}()
 
-	if err := applyDynamicHeaders(page, opts); err != nil {
+	if err := applyDynamicHeaders(ctx, page, opts); err != nil {
 		return "", err
 	}
 
+	rec := footprint.From(ctx)
 	if err := page.Goto(opts.URL, opts.Timeout); err != nil {
+		rec.Request(opts.URL, 0, err)
 		if errors.Is(err, context.DeadlineExceeded) {
 			return "", fmt.Errorf("dynamic fetch timed out after %s (try --timeout or --wait-for)", opts.Timeout)
 		}
@@ -169,26 +302,22 @@ func fetchDynamicWith(ctx context.Context, opts Options, provider dynamicProvide
 			return "", fmt.Errorf("wait-for selector timed out: %s", opts.WaitForSelector)
 		}
 	}
+	if err := loadMore(ctx, page, opts); err != nil {
+		return "", err
+	}
 
 	html, err := page.Content()
+	rec.Request(opts.URL, int64(len(html)), err)
 	if err != nil {
 		return "", err
 	}
-	return html, nil
+	return afterContent(ctx, opts.Middleware, opts.URL, html)
 }
 
-func applyDynamicHeaders(page dynamicPage, opts Options) error {
-	headers := map[string]string{}
-	for key, value := range opts.Headers {
-		headers[key] = value
-	}
-	cookieHeader := buildCookieHeader(opts.Cookies)
-	if cookieHeader != "" {
-		if existing, ok := headers["Cookie"]; ok && strings.TrimSpace(existing) != "" {
-			headers["Cookie"] = existing + "; " + cookieHeader
-		} else {
-			headers["Cookie"] = cookieHeader
-		}
+func applyDynamicHeaders(ctx context.Context, page dynamicPage, opts Options) error {
+	headers := requestHeaders(opts)
+	if err := beforeNavigate(ctx, opts.Middleware, opts.URL, headers); err != nil {
+		return err
 	}
 	if len(headers) == 0 {
 		return nil
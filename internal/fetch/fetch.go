@@ -10,6 +10,8 @@ import (
 	"sort"
 	"strings"
 	"time"
+
+	"go_scrap/internal/footprint"
 )
 
 type Mode string
@@ -21,22 +23,83 @@ const (
 )
 
 type Options struct {
-	URL                string
-	Mode               Mode
-	Timeout            time.Duration
-	UserAgent          string
-	WaitForSelector    string
-	Headless           bool
+	URL             string
+	Mode            Mode
+	Timeout         time.Duration
+	UserAgent       string
+	WaitForSelector string
+	Headless        bool
+	// Browser is the engine of browser fetches (default Chromium).
+	Browser            Browser
 	RateLimitPerSecond float64
 	ProxyURL           string
 	Headers            map[string]string
 	Cookies            map[string]string
+	// ClientCertFile and ClientKeyFile are PEM files presented for mutual
+	// TLS; the key defaults to the certificate file.
+	ClientCertFile string
+	ClientKeyFile  string
+	// Middleware wraps static requests and hooks into browser fetches; see
+	// RegisterMiddleware.
+	Middleware []Middleware
+	// IfNoneMatch and IfModifiedSince make static fetches conditional: a 304
+	// response is returned as Result.NotModified, without HTML. Browser
+	// fetches ignore them.
+	IfNoneMatch     string
+	IfModifiedSince string
+	// ScrollToBottom and ClickSelector load lazy content in browser fetches
+	// before the page is captured: the page is scrolled to the bottom and
+	// the first visible "load more" element clicked, for up to MaxScrolls
+	// rounds (default DefaultMaxScrolls), each followed by a wait until the
+	// page height holds still for ScrollQuiet (default DefaultScrollQuiet).
+	ScrollToBottom bool
+	ClickSelector  string
+	MaxScrolls     int
+	ScrollQuiet    time.Duration
+	// StorageState is a Playwright storage state file, such as a login
+	// Session's, that browser pages start from.
+	StorageState string
 }
 
 type Result struct {
 	HTML       string
 	FinalMode  Mode
 	SourceInfo string
+	// Status, ETag and LastModified are from the HTTP response when the
+	// page was fetched statically; a browser fetch leaves them empty.
+	Status       int
+	ETag         string
+	LastModified string
+	// NotModified reports a 304 answer to a conditional fetch; HTML is empty
+	// and the caller's copy is current.
+	NotModified bool
+	// FinalURL is the page a static fetch ended on after meta refresh or
+	// JavaScript redirects, or "" when it followed none. HTML's relative
+	// links are already resolved against it.
+	FinalURL string
+}
+
+// staticResponse is the body of a static fetch and the response metadata
+// kept in Result.
+type staticResponse struct {
+	html         string
+	status       int
+	etag         string
+	lastModified string
+	finalURL     string
+}
+
+func (r staticResponse) result(sourceInfo string) Result {
+	return Result{
+		HTML:         r.html,
+		FinalMode:    ModeStatic,
+		SourceInfo:   sourceInfo,
+		Status:       r.status,
+		ETag:         r.etag,
+		LastModified: r.lastModified,
+		NotModified:  r.status == http.StatusNotModified,
+		FinalURL:     r.finalURL,
+	}
 }
 
 var staticFetch = fetchStatic
@@ -52,14 +115,19 @@ func Fetch(ctx context.Context, opts Options) (Result, error) {
 	if opts.UserAgent == "" {
 		opts.UserAgent = "go_scrap/1.0"
 	}
+	if opts.ProxyURL != "" {
+		if _, err := url.Parse(opts.ProxyURL); err != nil {
+			return Result{}, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+	}
 
 	switch opts.Mode {
 	case ModeStatic:
-		html, err := staticFetch(ctx, opts)
+		resp, hops, err := fetchStaticFollowing(ctx, opts)
 		if err != nil {
 			return Result{}, err
 		}
-		return Result{HTML: html, FinalMode: ModeStatic, SourceInfo: "static"}, nil
+		return resp.result(redirectSourceInfo("static", hops)), nil
 	case ModeDynamic:
 		html, err := dynamicFetch(ctx, opts)
 		if err != nil {
@@ -67,9 +135,9 @@ func Fetch(ctx context.Context, opts Options) (Result, error) {
 		}
 		return Result{HTML: html, FinalMode: ModeDynamic, SourceInfo: "dynamic"}, nil
 	case ModeAuto:
-		html, err := staticFetch(ctx, opts)
-		if err == nil && !looksDynamic(html) {
-			return Result{HTML: html, FinalMode: ModeStatic, SourceInfo: "auto:static"}, nil
+		resp, hops, err := fetchStaticFollowing(ctx, opts)
+		if err == nil && (resp.status == http.StatusNotModified || !looksDynamic(resp.html)) {
+			return resp.result(redirectSourceInfo("auto:static", hops)), nil
 		}
 		html, derr := dynamicFetch(ctx, opts)
 		if derr != nil {
@@ -84,45 +152,128 @@ func Fetch(ctx context.Context, opts Options) (Result, error) {
 	}
 }
 
-func fetchStatic(ctx context.Context, opts Options) (string, error) {
-	if err := waitForRateLimit(ctx, opts.RateLimitPerSecond); err != nil {
-		return "", err
+func fetchStatic(ctx context.Context, opts Options) (staticResponse, error) {
+	if err := WaitURL(ctx, opts.URL, opts.RateLimitPerSecond); err != nil {
+		return staticResponse{}, err
 	}
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, opts.URL, nil)
 	if err != nil {
-		return "", err
+		return staticResponse{}, err
 	}
 
 	req.Header.Set("User-Agent", opts.UserAgent)
 	applyHeaders(req.Header, opts.Headers, opts.Cookies)
+	conditional := opts.IfNoneMatch != "" || opts.IfModifiedSince != ""
+	if opts.IfNoneMatch != "" {
+		req.Header.Set("If-None-Match", opts.IfNoneMatch)
+	}
+	if opts.IfModifiedSince != "" {
+		req.Header.Set("If-Modified-Since", opts.IfModifiedSince)
+	}
 
-	client := &http.Client{Timeout: opts.Timeout}
-	if opts.ProxyURL != "" {
-		proxyURL, err := url.Parse(opts.ProxyURL)
-		if err != nil {
-			return "", fmt.Errorf("invalid proxy URL: %w", err)
-		}
-		client.Transport = &http.Transport{
-			Proxy: http.ProxyURL(proxyURL),
-		}
+	transport, err := Transport(opts)
+	if err != nil {
+		return staticResponse{}, err
 	}
+	client := &http.Client{Timeout: opts.Timeout, Transport: transport}
+	rec := footprint.From(ctx)
 	resp, err := client.Do(req)
 	if err != nil {
+		rec.Request(opts.URL, 0, err)
 		if errors.Is(err, context.DeadlineExceeded) {
-			return "", fmt.Errorf("static fetch timed out after %s", opts.Timeout)
+			return staticResponse{}, fmt.Errorf("static fetch timed out after %s", opts.Timeout)
 		}
-		return "", err
+		return staticResponse{}, err
 	}
 	defer resp.Body.Close()
+	if conditional && resp.StatusCode == http.StatusNotModified {
+		rec.Request(opts.URL, 0, nil)
+		return staticResponse{status: resp.StatusCode, etag: resp.Header.Get("ETag"), lastModified: resp.Header.Get("Last-Modified")}, nil
+	}
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return "", fmt.Errorf("http status %d", resp.StatusCode)
+		err := &StatusError{Code: resp.StatusCode, RetryAfter: ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now())}
+		rec.Request(opts.URL, 0, err)
+		return staticResponse{}, err
 	}
 	body, err := io.ReadAll(resp.Body)
+	rec.Request(opts.URL, int64(len(body)), err)
+	if err != nil {
+		return staticResponse{}, err
+	}
+	return staticResponse{html: string(body), status: resp.StatusCode, etag: resp.Header.Get("ETag"), lastModified: resp.Header.Get("Last-Modified")}, nil
+}
+
+// Transport is the round tripper of static fetches for opts: its proxy and
+// client certificate, wrapped in its middleware. It is nil, meaning
+// http.DefaultTransport, when opts configure none of them.
+func Transport(opts Options) (http.RoundTripper, error) {
+	transport, err := staticTransport(opts)
+	if err != nil {
+		return nil, err
+	}
+	var rt http.RoundTripper
+	if transport != nil {
+		rt = transport
+	}
+	if len(opts.Middleware) > 0 {
+		rt = WrapTransport(rt, opts.Middleware)
+	}
+	return rt, nil
+}
+
+type transportKey struct{}
+
+// WithTransport attaches rt to ctx for downloads made outside Fetch, such as
+// assets and media, so they use the run's proxy, client certificate and
+// middleware (among them the organization policy).
+func WithTransport(ctx context.Context, rt http.RoundTripper) context.Context {
+	return context.WithValue(ctx, transportKey{}, rt)
+}
+
+// TransportFrom returns the transport attached to ctx, or nil for
+// http.DefaultTransport.
+func TransportFrom(ctx context.Context) http.RoundTripper {
+	if ctx == nil {
+		return nil
+	}
+	rt, _ := ctx.Value(transportKey{}).(http.RoundTripper)
+	return rt
+}
+
+// staticTransport returns a transport for the proxy and client certificate,
+// or nil when neither is configured.
+func staticTransport(opts Options) (*http.Transport, error) {
+	if opts.ProxyURL == "" && opts.ClientCertFile == "" {
+		return nil, nil
+	}
+	transport := &http.Transport{}
+	if opts.ProxyURL != "" {
+		proxyURL, err := url.Parse(opts.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	tlsConfig, err := ClientTLSConfig(opts.ClientCertFile, opts.ClientKeyFile)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+// requestHeaders is the auth header set every fetch path sends, with cookies
+// merged into Cookie. Keys are canonicalized as net/http does, so "cookie"
+// and "Cookie" merge the same way in the browser as in static fetches.
+func requestHeaders(opts Options) map[string]string {
+	h := http.Header{}
+	applyHeaders(h, opts.Headers, opts.Cookies)
+	out := make(map[string]string, len(h))
+	for key := range h {
+		out[key] = h.Get(key)
 	}
-	return string(body), nil
+	return out
 }
 
 func applyHeaders(headers http.Header, extra map[string]string, cookies map[string]string) {
@@ -156,22 +307,10 @@ func buildCookieHeader(cookies map[string]string) string {
 	return strings.Join(parts, "; ")
 }
 
-func waitForRateLimit(ctx context.Context, ratePerSecond float64) error {
-	if ratePerSecond <= 0 {
-		return nil
-	}
-	interval := time.Duration(float64(time.Second) / ratePerSecond)
-	if interval <= 0 {
-		return nil
-	}
-	timer := time.NewTimer(interval)
-	defer timer.Stop()
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case <-timer.C:
-		return nil
-	}
+// LooksDynamic reports whether statically fetched HTML looks like a
+// client-rendered shell that needs a browser.
+func LooksDynamic(html string) bool {
+	return looksDynamic(html)
 }
 
 func looksDynamic(html string) bool {
@@ -10,7 +10,10 @@ import (
 func withFetchers(staticFn func(context.Context, Options) (string, error), dynamicFn func(context.Context, Options) (string, error), fn func()) {
 	prevStatic := staticFetch
 	prevDynamic := dynamicFetch
-	staticFetch = staticFn
+	staticFetch = func(ctx context.Context, opts Options) (staticResponse, error) {
+		html, err := staticFn(ctx, opts)
+		return staticResponse{html: html}, err
+	}
 	dynamicFetch = dynamicFn
 	defer func() {
 		staticFetch = prevStatic
@@ -2,6 +2,7 @@ package fetch
 
 import (
 	"context"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -26,28 +27,48 @@ func TestLooksDynamic(t *testing.T) {
 	}
 }
 
-func TestWaitForRateLimit(t *testing.T) {
+func TestWaitURL(t *testing.T) {
 	t.Run("Disabled", func(t *testing.T) {
 		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 		defer cancel()
-		if err := waitForRateLimit(ctx, 0); err != nil {
-			t.Fatalf("unexpected error: %v", err)
+		for i := 0; i < 3; i++ {
+			if err := WaitURL(ctx, "https://disabled.example/", 0); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+	})
+
+	t.Run("SharedAcrossCalls", func(t *testing.T) {
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			if err := WaitURL(context.Background(), "https://shared.example/page"+strconv.Itoa(i), 20); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+			t.Fatalf("expected calls to one host to be spaced 50ms apart, took %s", elapsed)
 		}
 	})
 
 	t.Run("CanceledContext", func(t *testing.T) {
 		ctx, cancel := context.WithCancel(context.Background())
+		_ = WaitURL(ctx, "https://canceled.example/", 10)
 		cancel()
-		if err := waitForRateLimit(ctx, 10); err == nil {
+		if err := WaitURL(ctx, "https://canceled.example/", 10); err == nil {
 			t.Fatal("expected error")
 		}
 	})
 
-	t.Run("HighRate", func(t *testing.T) {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-		defer cancel()
-		if err := waitForRateLimit(ctx, 1e12); err != nil {
-			t.Fatalf("unexpected error: %v", err)
+	t.Run("ContextLimiter", func(t *testing.T) {
+		ctx := WithHostLimiter(context.Background(), NewHostLimiter(20))
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			if err := WaitURL(ctx, "https://context.example/", 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+			t.Fatalf("expected the context's limiter to space calls, took %s", elapsed)
 		}
 	})
 }
@@ -2,6 +2,10 @@ package fetch
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -11,3 +15,105 @@ func TestFetchStatic_InvalidURL(t *testing.T) {
 		t.Fatal("expected error for invalid url")
 	}
 }
+
+func TestFetch_StaticKeepsStatusAndETag(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, "<html><body><p>hi</p></body></html>")
+	}))
+	defer srv.Close()
+
+	res, err := Fetch(context.Background(), Options{URL: srv.URL, Mode: ModeStatic})
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if res.Status != http.StatusOK || res.ETag != `"v1"` {
+		t.Fatalf("expected status 200 and ETag \"v1\", got %d %q", res.Status, res.ETag)
+	}
+}
+
+func TestFetch_ConditionalReturnsNotModified(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprint(w, "<html><body><p>hi</p></body></html>")
+	}))
+	defer srv.Close()
+
+	res, err := Fetch(context.Background(), Options{URL: srv.URL, Mode: ModeStatic, IfNoneMatch: `"v1"`})
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if !res.NotModified || res.HTML != "" || res.LastModified != "Mon, 02 Jan 2006 15:04:05 GMT" {
+		t.Fatalf("expected a 304 result, got %+v", res)
+	}
+
+	res, err = Fetch(context.Background(), Options{URL: srv.URL, Mode: ModeStatic, IfNoneMatch: `"v2"`})
+	if err != nil || res.NotModified || res.HTML == "" {
+		t.Fatalf("expected a changed ETag to refetch, got %+v %v", res, err)
+	}
+}
+
+func TestFetch_StaticFollowsClientRedirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head><meta http-equiv="Refresh" content="0; URL='/mid'"></head><body>Moved.</body></html>`)
+	})
+	mux.HandleFunc("/mid", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><body><script>window.location.replace("/docs/new#top")</script></body></html>`)
+	})
+	mux.HandleFunc("/docs/new", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head><meta http-equiv="refresh" content="300"></head><body><h1>New</h1><a href="setup">Setup</a></body></html>`)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><body><script>location.href = "/loop2"</script></body></html>`)
+	})
+	mux.HandleFunc("/loop2", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head><meta http-equiv="refresh" content="1;url=/loop"></head></html>`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	res, err := Fetch(context.Background(), Options{URL: srv.URL + "/old", Mode: ModeStatic})
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	want := "static via meta refresh to " + srv.URL + "/mid, js redirect to " + srv.URL + "/docs/new"
+	if res.SourceInfo != want || !strings.Contains(res.HTML, "<h1>New</h1>") {
+		t.Fatalf("expected the redirects to be followed, got %q: %s", res.SourceInfo, res.HTML)
+	}
+	if res.FinalURL != srv.URL+"/docs/new" || !strings.Contains(res.HTML, `href="`+srv.URL+`/docs/setup"`) {
+		t.Fatalf("expected links resolved against the final URL %q, got %s", res.FinalURL, res.HTML)
+	}
+
+	if _, err := Fetch(context.Background(), Options{URL: srv.URL + "/loop", Mode: ModeStatic}); err == nil || !strings.Contains(err.Error(), "redirect loop") {
+		t.Fatalf("expected a redirect loop error, got %v", err)
+	}
+}
+
+func TestAbsoluteLinks(t *testing.T) {
+	html := AbsoluteLinks("https://example.com/amp/docs/install", `<html><body>
+		<a href="setup">Setup</a> <a href="#usage">Usage</a> <img src="/img/a.png">
+		<a href="https://other.example/x">Other</a></body></html>`)
+	for _, want := range []string{
+		`href="https://example.com/amp/docs/setup"`,
+		`href="#usage"`,
+		`src="https://example.com/img/a.png"`,
+		`href="https://other.example/x"`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Fatalf("expected %s in:\n%s", want, html)
+		}
+	}
+}
+
+func TestClientRedirect_IgnoresPagesWithContent(t *testing.T) {
+	html := `<html><head><meta http-equiv="refresh" content="0;url=/elsewhere"></head><body><p>` + strings.Repeat("Real content. ", 30) + `</p></body></html>`
+	if target, _ := clientRedirect("https://example.com/docs", html); target != "" {
+		t.Fatalf("expected no redirect for a page with content, got %q", target)
+	}
+}
@@ -115,3 +115,36 @@ func TestFetch_StaticUserAgent(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestFetch_StaticSendsAuthHeadersAndCookies(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer t" {
+			t.Errorf("expected Authorization header, got %q", got)
+		}
+		if got := r.Header.Get("Cookie"); got != "theme=dark; session=abc" {
+			t.Errorf("expected merged cookies, got %q", got)
+		}
+		_, _ = w.Write([]byte("<html>ok</html>"))
+	}))
+	defer srv.Close()
+
+	_, err := fetch.Fetch(context.Background(), fetch.Options{
+		URL:     srv.URL,
+		Mode:    fetch.ModeStatic,
+		Timeout: time.Second,
+		Headers: map[string]string{"authorization": "Bearer t", "cookie": "theme=dark"},
+		Cookies: map[string]string{"session": "abc"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestFetch_InvalidProxyFailsInEveryMode(t *testing.T) {
+	for _, mode := range []fetch.Mode{fetch.ModeStatic, fetch.ModeDynamic, fetch.ModeAuto} {
+		_, err := fetch.Fetch(context.Background(), fetch.Options{URL: "https://example.com", Mode: mode, ProxyURL: "http://[::1"})
+		if err == nil || !strings.Contains(err.Error(), "invalid proxy URL") {
+			t.Fatalf("%s: expected invalid proxy error, got %v", mode, err)
+		}
+	}
+}
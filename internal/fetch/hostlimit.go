@@ -0,0 +1,171 @@
+package fetch
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxHostDelay caps the spacing Penalize backs a host off to.
+const maxHostDelay = 30 * time.Second
+
+// HostLimiter spaces requests to each host 1/perSecond apart however many
+// fetchers share it, and further apart for a host that pushes back (see
+// Penalize). A run attaches one to its context (see WithHostLimiter) for
+// static and browser fetches, anchor walks, crawls, sitemaps and asset
+// downloads; run-all shares one across configs so parallel runs don't
+// hammer a common host.
+type HostLimiter struct {
+	interval time.Duration
+	// parent, when set, is waited for before each request as well.
+	parent *HostLimiter
+
+	mu   sync.Mutex
+	next map[string]time.Time
+	// slow holds the backed-off spacing of penalized hosts.
+	slow map[string]time.Duration
+}
+
+// NewHostLimiter returns a limiter allowing perSecond requests per host, or
+// nil (no limit) when perSecond is not positive.
+func NewHostLimiter(perSecond float64) *HostLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	return &HostLimiter{
+		interval: time.Duration(float64(time.Second) / perSecond),
+		next:     make(map[string]time.Time),
+		slow:     make(map[string]time.Duration),
+	}
+}
+
+// Within makes requests through l wait for parent too, e.g. a limiter
+// shared by several runs, and returns l, or parent when l is nil.
+func (l *HostLimiter) Within(parent *HostLimiter) *HostLimiter {
+	if l == nil {
+		return parent
+	}
+	l.parent = parent
+	return l
+}
+
+// Wait blocks until a request to host may be sent, or returns ctx's error
+// without taking a slot once it is done. A nil limiter never blocks.
+func (l *HostLimiter) Wait(ctx context.Context, host string) error {
+	if l == nil {
+		return nil
+	}
+	if err := l.parent.Wait(ctx, host); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	host = strings.ToLower(host)
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next[host]
+	if at.Before(now) {
+		at = now
+	}
+	l.next[host] = at.Add(l.spacing(host))
+	l.mu.Unlock()
+
+	delay := time.Until(at)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// spacing is the gap kept after a request to host. l.mu must be held.
+func (l *HostLimiter) spacing(host string) time.Duration {
+	if d, ok := l.slow[host]; ok {
+		return d
+	}
+	return l.interval
+}
+
+// Penalize doubles host's spacing, up to 30 seconds, and holds its requests
+// for hold, e.g. after a 429 or 503 response. It returns the new spacing.
+func (l *HostLimiter) Penalize(host string, hold time.Duration) time.Duration {
+	if l == nil {
+		return 0
+	}
+	host = strings.ToLower(host)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	d := min(l.spacing(host)*2, maxHostDelay)
+	l.slow[host] = d
+	if until := time.Now().Add(hold); until.After(l.next[host]) {
+		l.next[host] = until
+	}
+	return d
+}
+
+// Relax shrinks a penalized host's spacing after a successful response,
+// until it is back to the limiter's rate.
+func (l *HostLimiter) Relax(host string) {
+	if l == nil {
+		return
+	}
+	host = strings.ToLower(host)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	d, ok := l.slow[host]
+	if !ok {
+		return
+	}
+	if d = d * 9 / 10; d < l.interval {
+		delete(l.slow, host)
+		return
+	}
+	l.slow[host] = d
+}
+
+type limiterKey struct{}
+
+// WithHostLimiter attaches l to ctx for every fetch made with it.
+func WithHostLimiter(ctx context.Context, l *HostLimiter) context.Context {
+	return context.WithValue(ctx, limiterKey{}, l)
+}
+
+// HostLimiterFrom returns the limiter attached to ctx, or nil.
+func HostLimiterFrom(ctx context.Context) *HostLimiter {
+	if ctx == nil {
+		return nil
+	}
+	l, _ := ctx.Value(limiterKey{}).(*HostLimiter)
+	return l
+}
+
+// sharedLimiters holds a limiter per rate for fetches whose context carries
+// none, so that Options.RateLimitPerSecond holds across calls.
+var sharedLimiters sync.Map
+
+// WaitURL blocks until a request to the host of rawURL may be sent, per the
+// limiter attached to ctx or else a process-wide one for ratePerSecond.
+func WaitURL(ctx context.Context, rawURL string, ratePerSecond float64) error {
+	l := HostLimiterFrom(ctx)
+	if l == nil && ratePerSecond > 0 {
+		v, _ := sharedLimiters.LoadOrStore(ratePerSecond, NewHostLimiter(ratePerSecond))
+		l = v.(*HostLimiter)
+	}
+	if l == nil {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	return l.Wait(ctx, u.Hostname())
+}
@@ -0,0 +1,52 @@
+package fetch
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHostLimiter_SpacesRequestsPerHost(t *testing.T) {
+	l := NewHostLimiter(20) // 50ms apart
+	ctx := context.Background()
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.Wait(ctx, "a.example"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Fatalf("expected three requests to one host to take ~100ms, took %s", elapsed)
+	}
+
+	start = time.Now()
+	if err := l.Wait(ctx, "B.example"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 40*time.Millisecond {
+		t.Fatalf("expected another host not to wait, took %s", elapsed)
+	}
+
+	if NewHostLimiter(0) != nil {
+		t.Fatal("expected no limiter for a rate of 0")
+	}
+	if err := (*HostLimiter)(nil).Wait(ctx, "a.example"); err != nil {
+		t.Fatalf("expected a nil limiter not to block, got %v", err)
+	}
+}
+
+func TestHostLimiter_PenalizeAndRelax(t *testing.T) {
+	l := NewHostLimiter(1)
+	if d := l.Penalize("Example.com", 3*time.Second); d != 2*time.Second {
+		t.Fatalf("expected doubled spacing, got %v", d)
+	}
+	if until := time.Until(l.next["example.com"]); until < 2*time.Second {
+		t.Fatalf("expected requests held for the Retry-After, next slot in %v", until)
+	}
+	for i := 0; i < 7; i++ {
+		l.Relax("example.com")
+	}
+	if _, ok := l.slow["example.com"]; ok {
+		t.Fatal("expected the host to recover after successful responses")
+	}
+}
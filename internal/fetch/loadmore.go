@@ -0,0 +1,98 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultMaxScrolls bounds the rounds of scrolling and clicking when
+	// Options.MaxScrolls is unset.
+	DefaultMaxScrolls = 20
+	// DefaultScrollQuiet is how long the page height must hold still after
+	// a scroll or click when Options.ScrollQuiet is unset.
+	DefaultScrollQuiet = time.Second
+)
+
+// loadMore brings lazy-loaded content into a browser page before it is
+// captured: each round scrolls to the bottom and clicks opts.ClickSelector,
+// as enabled, then waits for the page to settle. It stops once a round leaves
+// the page height unchanged, no load-more element is visible any longer, or
+// after MaxScrolls rounds.
+func loadMore(ctx context.Context, page dynamicPage, opts Options) error {
+	if !opts.ScrollToBottom && opts.ClickSelector == "" {
+		return nil
+	}
+	rounds := opts.MaxScrolls
+	if rounds <= 0 {
+		rounds = DefaultMaxScrolls
+	}
+	quiet := opts.ScrollQuiet
+	if quiet <= 0 {
+		quiet = DefaultScrollQuiet
+	}
+
+	height, err := page.ScrollHeight()
+	if err != nil {
+		return fmt.Errorf("scroll: %w", err)
+	}
+	for i := 0; i < rounds; i++ {
+		acted := false
+		if opts.ScrollToBottom {
+			if err := page.ScrollToBottom(); err != nil {
+				return fmt.Errorf("scroll: %w", err)
+			}
+			acted = true
+		}
+		if opts.ClickSelector != "" {
+			clicked, err := page.ClickIfVisible(opts.ClickSelector, opts.Timeout)
+			if err != nil {
+				return fmt.Errorf("click %s: %w", opts.ClickSelector, err)
+			}
+			acted = acted || clicked
+		}
+		if !acted {
+			return nil
+		}
+		next, err := settle(ctx, page, quiet, opts.Timeout)
+		if err != nil {
+			return err
+		}
+		if next == height {
+			return nil
+		}
+		height = next
+	}
+	return nil
+}
+
+// settle waits until the page height has not changed for quiet, or until
+// limit has passed, and returns the height.
+func settle(ctx context.Context, page dynamicPage, quiet, limit time.Duration) (int, error) {
+	poll := quiet / 4
+	if poll < 10*time.Millisecond {
+		poll = quiet
+	}
+	height, err := page.ScrollHeight()
+	if err != nil {
+		return 0, fmt.Errorf("scroll: %w", err)
+	}
+	stable := time.Now()
+	deadline := stable.Add(limit)
+	for time.Since(stable) < quiet && (limit <= 0 || time.Now().Before(deadline)) {
+		select {
+		case <-ctx.Done():
+			return height, ctx.Err()
+		case <-time.After(poll):
+		}
+		h, err := page.ScrollHeight()
+		if err != nil {
+			return 0, fmt.Errorf("scroll: %w", err)
+		}
+		if h != height {
+			height, stable = h, time.Now()
+		}
+	}
+	return height, nil
+}
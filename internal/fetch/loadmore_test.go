@@ -0,0 +1,52 @@
+package fetch
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLoadMore_ScrollsUntilHeightStopsGrowing(t *testing.T) {
+	page := &fakePage{heights: []int{100, 200, 300, 300}}
+	opts := Options{ScrollToBottom: true, ScrollQuiet: time.Millisecond, Timeout: time.Second}
+	if err := loadMore(context.Background(), page, opts); err != nil {
+		t.Fatalf("loadMore: %v", err)
+	}
+	if page.scrolls != 3 {
+		t.Fatalf("expected 3 scrolls, got %d", page.scrolls)
+	}
+}
+
+func TestLoadMore_StopsAtMaxScrolls(t *testing.T) {
+	page := &fakePage{heights: []int{100, 200, 300, 400, 500}}
+	opts := Options{ScrollToBottom: true, MaxScrolls: 2, ScrollQuiet: time.Millisecond, Timeout: time.Second}
+	if err := loadMore(context.Background(), page, opts); err != nil {
+		t.Fatalf("loadMore: %v", err)
+	}
+	if page.scrolls != 2 {
+		t.Fatalf("expected 2 scrolls, got %d", page.scrolls)
+	}
+}
+
+func TestLoadMore_ClicksUntilButtonDisappears(t *testing.T) {
+	page := &fakePage{heights: []int{100, 200, 300, 400}, clickable: 2}
+	opts := Options{ClickSelector: "button.load-more", ScrollQuiet: time.Millisecond, Timeout: time.Second}
+	if err := loadMore(context.Background(), page, opts); err != nil {
+		t.Fatalf("loadMore: %v", err)
+	}
+	if page.clicks != 2 || page.clickSel != "button.load-more" || page.scrolls != 0 {
+		t.Fatalf("expected 2 clicks on the selector and no scrolls, got %d clicks on %q, %d scrolls", page.clicks, page.clickSel, page.scrolls)
+	}
+}
+
+func TestFetchDynamicWith_LoadsMoreBeforeCapture(t *testing.T) {
+	page := &fakePage{content: "<html></html>", heights: []int{100, 200, 200}}
+	provider := &fakeProvider{runner: &fakeRunner{browser: &fakeBrowser{page: page}}}
+	opts := Options{URL: "https://example.com", ScrollToBottom: true, ScrollQuiet: time.Millisecond, Timeout: time.Second}
+	if _, err := fetchDynamicWith(context.Background(), opts, provider); err != nil {
+		t.Fatalf("fetchDynamicWith: %v", err)
+	}
+	if page.scrolls != 2 {
+		t.Fatalf("expected the page to be scrolled twice, got %d", page.scrolls)
+	}
+}
@@ -0,0 +1,153 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go_scrap/internal/footprint"
+	"go_scrap/internal/fsutil"
+)
+
+// Login is a form login performed in the browser before a scrape.
+type Login struct {
+	// URL is the page with the login form.
+	URL      string `json:"url"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// UsernameSelector is optional, for forms that ask for the password
+	// alone.
+	UsernameSelector string `json:"username_selector,omitempty"`
+	PasswordSelector string `json:"password_selector"`
+	SubmitSelector   string `json:"submit_selector"`
+	// SuccessSelector appears once the login went through.
+	SuccessSelector string `json:"success_selector"`
+}
+
+// Validate reports a login that cannot be performed.
+func (l Login) Validate() error {
+	u, err := url.Parse(l.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("login: url must be an http(s) URL, got %q", l.URL)
+	}
+	var missing []string
+	for _, f := range []struct{ name, value string }{
+		{"password_selector", l.PasswordSelector},
+		{"submit_selector", l.SubmitSelector},
+		{"success_selector", l.SuccessSelector},
+		{"password", l.Password},
+	} {
+		if strings.TrimSpace(f.value) == "" {
+			missing = append(missing, f.name)
+		}
+	}
+	if l.UsernameSelector != "" && l.Username == "" {
+		missing = append(missing, "username")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("login: missing %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// Session is the browser state a login leaves behind.
+type Session struct {
+	// StorageState is a file holding the cookies and local storage of every
+	// origin, in Playwright's storage state format; see Options.StorageState.
+	StorageState string
+	Cookies      []*http.Cookie
+}
+
+// CookiesFor returns the session cookies sent to rawURL, by name.
+func (s Session) CookiesFor(rawURL string) map[string]string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	host := strings.ToLower(u.Hostname())
+	out := map[string]string{}
+	for _, c := range s.Cookies {
+		domain := strings.ToLower(strings.TrimPrefix(c.Domain, "."))
+		if domain == "" || host == domain || strings.HasSuffix(host, "."+domain) {
+			out[c.Name] = c.Value
+		}
+	}
+	return out
+}
+
+// RunLogin fills in and submits the login form in a browser configured like
+// a dynamic fetch with opts (browser, proxy, user agent, headers, timeout and
+// middleware), waits for the success selector and saves the resulting state
+// to statePath.
+func RunLogin(ctx context.Context, opts Options, login Login, statePath string) (Session, error) {
+	return runLoginWith(ctx, opts, login, statePath, playwrightProvider{certs: clientCertificates(opts)})
+}
+
+func runLoginWith(ctx context.Context, opts Options, login Login, statePath string, provider dynamicProvider) (Session, error) {
+	if err := login.Validate(); err != nil {
+		return Session{}, err
+	}
+	if statePath == "" {
+		return Session{}, errors.New("login: no path to save the session to")
+	}
+	opts.URL = login.URL
+	if err := provider.Install(); err != nil {
+		return Session{}, fmt.Errorf("install playwright: %w", err)
+	}
+	runner, err := provider.Run()
+	if err != nil {
+		return Session{}, err
+	}
+	defer func() {
+		_ = runner.Stop()
+	}()
+	browser, err := runner.Launch(opts.Browser, opts.Headless, opts.ProxyURL)
+	if err != nil {
+		return Session{}, err
+	}
+	defer func() {
+		_ = browser.Close()
+	}()
+	page, err := browser.NewPage(opts.UserAgent, "")
+	if err != nil {
+		return Session{}, err
+	}
+	defer func() {
+		_ = page.Close()
+	}()
+	if err := applyDynamicHeaders(ctx, page, opts); err != nil {
+		return Session{}, err
+	}
+
+	err = page.Goto(login.URL, opts.Timeout)
+	footprint.From(ctx).Request(login.URL, 0, err)
+	if err != nil {
+		return Session{}, fmt.Errorf("login: open %s: %w", login.URL, err)
+	}
+	if login.UsernameSelector != "" {
+		if err := page.Fill(login.UsernameSelector, login.Username, opts.Timeout); err != nil {
+			return Session{}, fmt.Errorf("login: fill %s: %w", login.UsernameSelector, err)
+		}
+	}
+	if err := page.Fill(login.PasswordSelector, login.Password, opts.Timeout); err != nil {
+		return Session{}, fmt.Errorf("login: fill %s: %w", login.PasswordSelector, err)
+	}
+	if err := page.Click(login.SubmitSelector, opts.Timeout); err != nil {
+		return Session{}, fmt.Errorf("login: click %s: %w", login.SubmitSelector, err)
+	}
+	if err := page.WaitFor(login.SuccessSelector, opts.Timeout); err != nil {
+		return Session{}, fmt.Errorf("login failed: %s did not appear after submitting the form", login.SuccessSelector)
+	}
+
+	state, cookies, err := page.SessionState()
+	if err != nil {
+		return Session{}, fmt.Errorf("login: read session: %w", err)
+	}
+	if err := fsutil.WriteFile(statePath, state, 0600); err != nil {
+		return Session{}, fmt.Errorf("login: save session: %w", err)
+	}
+	return Session{StorageState: statePath, Cookies: cookies}, nil
+}
@@ -0,0 +1,86 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testLogin() Login {
+	return Login{
+		URL:              "https://example.com/login",
+		Username:         "ada",
+		Password:         "secret",
+		UsernameSelector: "#user",
+		PasswordSelector: "#pass",
+		SubmitSelector:   "button[type=submit]",
+		SuccessSelector:  ".account",
+	}
+}
+
+func TestRunLoginWith_FillsFormAndSavesSession(t *testing.T) {
+	page := &fakePage{cookies: []*http.Cookie{
+		{Name: "session", Value: "abc", Domain: ".example.com"},
+		{Name: "sso", Value: "xyz", Domain: "auth.other.com"},
+	}}
+	provider := &fakeProvider{runner: &fakeRunner{browser: &fakeBrowser{page: page}}}
+	statePath := filepath.Join(t.TempDir(), "state.json")
+
+	session, err := runLoginWith(context.Background(), Options{Timeout: time.Second}, testLogin(), statePath, provider)
+	if err != nil {
+		t.Fatalf("runLoginWith: %v", err)
+	}
+	if page.gotoURL != "https://example.com/login" || page.filled["#user"] != "ada" || page.filled["#pass"] != "secret" {
+		t.Fatalf("form not filled: goto %q, filled %v", page.gotoURL, page.filled)
+	}
+	if len(page.clicked) != 1 || page.clicked[0] != "button[type=submit]" || page.waitSel != ".account" {
+		t.Fatalf("expected submit then wait for success, got clicks %v, wait %q", page.clicked, page.waitSel)
+	}
+	if _, err := os.Stat(statePath); err != nil || session.StorageState != statePath {
+		t.Fatalf("session state not saved: %v, %+v", err, session)
+	}
+	got := session.CookiesFor("https://docs.example.com/guide")
+	if len(got) != 1 || got["session"] != "abc" {
+		t.Fatalf("expected only the site's cookie, got %v", got)
+	}
+}
+
+func TestRunLoginWith_ReportsMissingSuccessSelector(t *testing.T) {
+	page := &fakePage{waitErr: errors.New("timeout")}
+	provider := &fakeProvider{runner: &fakeRunner{browser: &fakeBrowser{page: page}}}
+	_, err := runLoginWith(context.Background(), Options{}, testLogin(), filepath.Join(t.TempDir(), "state.json"), provider)
+	if err == nil || !strings.Contains(err.Error(), "login failed: .account did not appear") {
+		t.Fatalf("expected login failure, got %v", err)
+	}
+}
+
+func TestLoginValidate(t *testing.T) {
+	login := testLogin()
+	login.Password = ""
+	login.SubmitSelector = ""
+	if err := login.Validate(); err == nil || err.Error() != "login: missing submit_selector, password" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	login = testLogin()
+	login.URL = "/login"
+	if err := login.Validate(); err == nil {
+		t.Fatal("expected a relative login URL to be rejected")
+	}
+}
+
+func TestFetchDynamicWith_StartsFromStorageState(t *testing.T) {
+	browser := &fakeBrowser{page: &fakePage{content: "<html></html>"}}
+	provider := &fakeProvider{runner: &fakeRunner{browser: browser}}
+	opts := Options{URL: "https://example.com", StorageState: "/tmp/state.json"}
+	if _, err := fetchDynamicWith(context.Background(), opts, provider); err != nil {
+		t.Fatalf("fetchDynamicWith: %v", err)
+	}
+	if browser.state != "/tmp/state.json" {
+		t.Fatalf("expected the page to start from the storage state, got %q", browser.state)
+	}
+}
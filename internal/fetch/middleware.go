@@ -0,0 +1,151 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Middleware customizes fetching without changing this package: RoundTrip
+// wraps the HTTP transport of static fetches, crawls and sitemap requests,
+// while BeforeNavigate and AfterContent run around browser fetches. Any of
+// them may be nil.
+type Middleware struct {
+	Name string
+	// RoundTrip wraps the next transport, e.g. to sign, log, cache or mock
+	// requests.
+	RoundTrip func(next http.RoundTripper) http.RoundTripper
+	// BeforeNavigate runs before the browser loads pageURL and may add or
+	// change the extra request headers.
+	BeforeNavigate func(ctx context.Context, pageURL string, headers map[string]string) error
+	// AfterContent receives the rendered HTML and returns the HTML to use.
+	AfterContent func(ctx context.Context, pageURL, html string) (string, error)
+}
+
+// MiddlewareFactory builds a middleware for a run.
+type MiddlewareFactory func() (Middleware, error)
+
+var (
+	middlewareRegistryMu sync.RWMutex
+	middlewareRegistry   = map[string]MiddlewareFactory{
+		"log": func() (Middleware, error) { return logMiddleware(os.Stderr), nil },
+	}
+)
+
+// RegisterMiddleware makes a middleware available to --fetch-middleware /
+// fetch_middleware by name, replacing any existing one with the same name.
+func RegisterMiddleware(name string, factory MiddlewareFactory) {
+	middlewareRegistryMu.Lock()
+	defer middlewareRegistryMu.Unlock()
+	middlewareRegistry[name] = factory
+}
+
+// LookupMiddleware builds the named middleware in order. The first one is
+// outermost: it sees requests first and responses last.
+func LookupMiddleware(names []string) ([]Middleware, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	middlewareRegistryMu.RLock()
+	defer middlewareRegistryMu.RUnlock()
+
+	out := make([]Middleware, 0, len(names))
+	for _, name := range names {
+		factory, ok := middlewareRegistry[name]
+		if !ok {
+			available := make([]string, 0, len(middlewareRegistry))
+			for key := range middlewareRegistry {
+				available = append(available, key)
+			}
+			sort.Strings(available)
+			return nil, fmt.Errorf("unknown fetch middleware %q (available: %s)", name, strings.Join(available, ", "))
+		}
+		mw, err := factory()
+		if err != nil {
+			return nil, fmt.Errorf("init fetch middleware %q: %w", name, err)
+		}
+		if mw.Name == "" {
+			mw.Name = name
+		}
+		out = append(out, mw)
+	}
+	return out, nil
+}
+
+// WrapTransport applies the RoundTrip middleware to next; a nil next means
+// http.DefaultTransport.
+func WrapTransport(next http.RoundTripper, middleware []Middleware) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	for i := len(middleware) - 1; i >= 0; i-- {
+		if middleware[i].RoundTrip != nil {
+			next = middleware[i].RoundTrip(next)
+		}
+	}
+	return next
+}
+
+func beforeNavigate(ctx context.Context, middleware []Middleware, pageURL string, headers map[string]string) error {
+	for _, mw := range middleware {
+		if mw.BeforeNavigate == nil {
+			continue
+		}
+		if err := mw.BeforeNavigate(ctx, pageURL, headers); err != nil {
+			return fmt.Errorf("fetch middleware %q: %w", mw.Name, err)
+		}
+	}
+	return nil
+}
+
+func afterContent(ctx context.Context, middleware []Middleware, pageURL, html string) (string, error) {
+	// Run innermost first, mirroring how responses unwind through RoundTrip.
+	for i := len(middleware) - 1; i >= 0; i-- {
+		mw := middleware[i]
+		if mw.AfterContent == nil {
+			continue
+		}
+		var err error
+		if html, err = mw.AfterContent(ctx, pageURL, html); err != nil {
+			return "", fmt.Errorf("fetch middleware %q: %w", mw.Name, err)
+		}
+	}
+	return html, nil
+}
+
+// RoundTripperFunc adapts a function to http.RoundTripper.
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls f(req).
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// logMiddleware is the built-in "log" middleware: one line per request.
+func logMiddleware(w io.Writer) Middleware {
+	return Middleware{
+		Name: "log",
+		RoundTrip: func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				start := time.Now()
+				resp, err := next.RoundTrip(req)
+				if err != nil {
+					fmt.Fprintf(w, "fetch: %s %s: %v (%s)\n", req.Method, req.URL.Redacted(), err, time.Since(start).Round(time.Millisecond))
+					return resp, err
+				}
+				fmt.Fprintf(w, "fetch: %s %s -> %d (%s)\n", req.Method, req.URL.Redacted(), resp.StatusCode, time.Since(start).Round(time.Millisecond))
+				return resp, nil
+			})
+		},
+		AfterContent: func(_ context.Context, pageURL, html string) (string, error) {
+			fmt.Fprintf(w, "fetch: rendered %s (%d bytes)\n", pageURL, len(html))
+			return html, nil
+		},
+	}
+}
@@ -88,7 +88,7 @@ func AnchorHTML(ctx context.Context, opts Options, anchors []string) (map[string
 		return nil, err
 	}
 
-	if err := waitForRateLimit(ctx, opts.RateLimitPerSecond); err != nil {
+	if err := WaitURL(ctx, baseURL, opts.RateLimitPerSecond); err != nil {
 		return nil, err
 	}
 
@@ -98,11 +98,23 @@ func AnchorHTML(ctx context.Context, opts Options, anchors []string) (map[string
 	}
 	defer closeAll()
 
+	if err := applyNavHeaders(ctx, page, baseURL, opts); err != nil {
+		return nil, err
+	}
 	if err := gotoAndWait(page, baseURL, opts); err != nil {
 		return nil, err
 	}
 
-	return fetchAnchorContentWithPage(page, baseURL, opts, anchors)
+	results, err := fetchAnchorContentWithPage(page, baseURL, opts, anchors)
+	if err != nil {
+		return nil, err
+	}
+	for anchor, html := range results {
+		if results[anchor], err = afterContent(ctx, opts.Middleware, baseURL+"#"+anchor, html); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
 }
 
 func normalizeAnchorBase(rawURL string) (string, error) {
@@ -133,19 +145,19 @@ func openPage(opts Options) (navPage, func(), error) {
 		return nil, func() {}, err
 	}
 
-	page, err := browser.NewPage(playwright.BrowserNewPageOptions{
-		UserAgent: playwright.String(opts.UserAgent),
-	})
+	pageOpts := playwright.BrowserNewPageOptions{
+		UserAgent:          playwright.String(opts.UserAgent),
+		ClientCertificates: clientCertificates(opts),
+	}
+	if opts.StorageState != "" {
+		pageOpts.StorageStatePath = playwright.String(opts.StorageState)
+	}
+	page, err := browser.NewPage(pageOpts)
 	if err != nil {
 		return nil, func() {}, err
 	}
 
 	adapter := &playwrightPageAdapter{page: page}
-	if err := applyNavHeaders(adapter, opts); err != nil {
-		_ = page.Close()
-		return nil, func() {}, err
-	}
-
 	closeAll := func() {
 		_ = page.Close()
 	}
@@ -177,10 +189,8 @@ func ensureBrowser(opts Options) (playwright.Browser, error) {
 	launchOpts := playwright.BrowserTypeLaunchOptions{
 		Headless: playwright.Bool(opts.Headless),
 	}
-	if opts.ProxyURL != "" {
-		launchOpts.Proxy = &playwright.Proxy{Server: opts.ProxyURL}
-	}
-	browser, err := pw.Chromium.Launch(launchOpts)
+	launchOpts.Proxy = playwrightProxy(opts.ProxyURL)
+	browser, err := browserType(pw, opts.Browser).Launch(launchOpts)
 	if err != nil {
 		_ = pw.Stop()
 		return nil, err
@@ -229,18 +239,10 @@ func fetchAnchorContentWithPage(page navPage, baseURL string, opts Options, anch
 	return results, nil
 }
 
-func applyNavHeaders(page navPage, opts Options) error {
-	headers := map[string]string{}
-	for key, value := range opts.Headers {
-		headers[key] = value
-	}
-	cookieHeader := buildCookieHeader(opts.Cookies)
-	if cookieHeader != "" {
-		if existing, ok := headers["Cookie"]; ok && strings.TrimSpace(existing) != "" {
-			headers["Cookie"] = existing + "; " + cookieHeader
-		} else {
-			headers["Cookie"] = cookieHeader
-		}
+func applyNavHeaders(ctx context.Context, page navPage, pageURL string, opts Options) error {
+	headers := requestHeaders(opts)
+	if err := beforeNavigate(ctx, opts.Middleware, pageURL, headers); err != nil {
+		return err
 	}
 	if len(headers) == 0 {
 		return nil
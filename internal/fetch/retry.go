@@ -0,0 +1,192 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxRetryAfter caps the wait honored from a Retry-After header.
+const MaxRetryAfter = 2 * time.Minute
+
+// DefaultRetryPolicy tries a request 3 times, waiting about 1s and then 2s,
+// on network errors, throttling and transient server errors.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   time.Second,
+	MaxDelay:    30 * time.Second,
+	RetryOn: []int{
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	},
+	Jitter: 0.2,
+}
+
+// RetryPolicy decides whether a failed fetch is tried again and how long to
+// wait first. Zero fields other than Jitter take DefaultRetryPolicy's
+// values.
+type RetryPolicy struct {
+	// MaxAttempts counts the first try; 1 disables retries.
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry, doubled for each
+	// further one up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// RetryOn lists the HTTP statuses worth retrying. Network errors are
+	// always retried.
+	RetryOn []int
+	// Jitter spreads each wait randomly by up to this fraction either way,
+	// so that workers failing together do not retry together.
+	Jitter float64
+}
+
+// WithDefaults fills the zero fields of p, but Jitter, from
+// DefaultRetryPolicy.
+func (p RetryPolicy) WithDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = DefaultRetryPolicy.MaxAttempts
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = DefaultRetryPolicy.BaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = DefaultRetryPolicy.MaxDelay
+	}
+	if len(p.RetryOn) == 0 {
+		p.RetryOn = DefaultRetryPolicy.RetryOn
+	}
+	return p
+}
+
+// Validate rejects policies that cannot be applied.
+func (p RetryPolicy) Validate() error {
+	if p.MaxAttempts < 0 || p.BaseDelay < 0 || p.MaxDelay < 0 {
+		return errors.New("retry attempts and delays must not be negative")
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return fmt.Errorf("retry jitter must be between 0 and 1, got %g", p.Jitter)
+	}
+	for _, code := range p.RetryOn {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("invalid retry-on status %d", code)
+		}
+	}
+	return nil
+}
+
+// RetryStatus reports whether a response with the given status is retried;
+// 0 stands for a request that got no response.
+func (p RetryPolicy) RetryStatus(code int) bool {
+	return code == 0 || slices.Contains(p.RetryOn, code)
+}
+
+// Retryable reports whether a fetch that failed with err is retried.
+func (p RetryPolicy) Retryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var status *StatusError
+	if errors.As(err, &status) {
+		return p.RetryStatus(status.Code)
+	}
+	return true
+}
+
+// StatusError is a static fetch answered with a non-2xx status.
+type StatusError struct {
+	Code int
+	// RetryAfter is the wait the server asked for with Retry-After.
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("http status %d", e.Code)
+}
+
+// ParseRetryAfter accepts delta-seconds or an HTTP date, capped at
+// MaxRetryAfter.
+func ParseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	var d time.Duration
+	if secs, err := strconv.Atoi(value); err == nil {
+		d = time.Duration(secs) * time.Second
+	} else if at, err := http.ParseTime(value); err == nil {
+		d = at.Sub(now)
+	}
+	if d < 0 {
+		return 0
+	}
+	return min(d, MaxRetryAfter)
+}
+
+// Retrier applies a RetryPolicy. Its jitter comes from a seeded source, so
+// a run's waits are reproduced by its --seed. It is safe for concurrent use.
+type Retrier struct {
+	policy RetryPolicy
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// NewRetrier returns a Retrier for policy, with defaults filled in.
+func NewRetrier(policy RetryPolicy, seed int64) *Retrier {
+	return &Retrier{policy: policy.WithDefaults(), rnd: rand.New(rand.NewSource(seed))}
+}
+
+// Policy is the policy applied, with defaults filled in.
+func (r *Retrier) Policy() RetryPolicy {
+	return r.policy
+}
+
+// Delay is the wait before the given retry, counting from 1.
+func (r *Retrier) Delay(retry int) time.Duration {
+	d := r.policy.BaseDelay
+	for i := 1; i < retry && d < r.policy.MaxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, r.policy.MaxDelay)
+	r.mu.Lock()
+	spread := (r.rnd.Float64()*2 - 1) * r.policy.Jitter
+	r.mu.Unlock()
+	return time.Duration(float64(d) * (1 + spread))
+}
+
+// Do calls fn until it succeeds, fails with an error the policy does not
+// retry, or MaxAttempts is reached, waiting Delay between attempts, or the
+// server's Retry-After when longer. fn gets the attempt number, counting
+// from 1; Do returns its last error.
+func (r *Retrier) Do(ctx context.Context, fn func(attempt int) error) error {
+	var err error
+	for attempt := 1; attempt <= r.policy.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			wait := r.Delay(attempt - 1)
+			var status *StatusError
+			if errors.As(err, &status) {
+				wait = max(wait, status.RetryAfter)
+			}
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return err
+			}
+		}
+		err = fn(attempt)
+		if err == nil || ctx.Err() != nil || !r.policy.Retryable(err) {
+			return err
+		}
+	}
+	return err
+}
@@ -0,0 +1,156 @@
+package fetch_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go_scrap/internal/fetch"
+)
+
+func TestRetrier_DelayBacksOffExponentiallyUpToMax(t *testing.T) {
+	r := fetch.NewRetrier(fetch.RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: 350 * time.Millisecond}, 1)
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 350 * time.Millisecond, 350 * time.Millisecond}
+	for i, w := range want {
+		if got := r.Delay(i + 1); got != w {
+			t.Fatalf("retry %d: expected %s, got %s", i+1, w, got)
+		}
+	}
+}
+
+func TestRetrier_JitterIsBoundedAndSeeded(t *testing.T) {
+	policy := fetch.RetryPolicy{BaseDelay: time.Second, Jitter: 0.5}
+	a, b := fetch.NewRetrier(policy, 42), fetch.NewRetrier(policy, 42)
+	for i := 0; i < 20; i++ {
+		da, db := a.Delay(1), b.Delay(1)
+		if da != db {
+			t.Fatalf("same seed gave %s and %s", da, db)
+		}
+		if da < 500*time.Millisecond || da > 1500*time.Millisecond {
+			t.Fatalf("delay %s outside the jitter range", da)
+		}
+	}
+}
+
+func TestRetryPolicy_Retryable(t *testing.T) {
+	p := fetch.RetryPolicy{RetryOn: []int{503}}.WithDefaults()
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{&fetch.StatusError{Code: 503}, true},
+		{&fetch.StatusError{Code: 404}, false},
+		{errors.New("connection reset"), true},
+		{context.Canceled, false},
+		{nil, false},
+	}
+	for _, c := range cases {
+		if got := p.Retryable(c.err); got != c.want {
+			t.Fatalf("Retryable(%v): expected %v, got %v", c.err, c.want, got)
+		}
+	}
+}
+
+func TestRetrier_DoStopsOnSuccessOrPermanentError(t *testing.T) {
+	r := fetch.NewRetrier(fetch.RetryPolicy{MaxAttempts: 4, BaseDelay: time.Millisecond}, 1)
+	var calls int
+	err := r.Do(context.Background(), func(attempt int) error {
+		calls++
+		if attempt < 3 {
+			return &fetch.StatusError{Code: 502}
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Fatalf("expected success on attempt 3, got %v after %d calls", err, calls)
+	}
+
+	calls = 0
+	err = r.Do(context.Background(), func(int) error {
+		calls++
+		return &fetch.StatusError{Code: 404}
+	})
+	if calls != 1 || err == nil || err.Error() != "http status 404" {
+		t.Fatalf("expected one attempt failing with 404, got %v after %d calls", err, calls)
+	}
+
+	calls = 0
+	_ = r.Do(context.Background(), func(int) error {
+		calls++
+		return errors.New("connection refused")
+	})
+	if calls != 4 {
+		t.Fatalf("expected 4 attempts, got %d", calls)
+	}
+}
+
+func TestRetryPolicy_Validate(t *testing.T) {
+	for _, p := range []fetch.RetryPolicy{
+		{MaxAttempts: -1},
+		{BaseDelay: -time.Second},
+		{Jitter: 1.5},
+		{RetryOn: []int{42}},
+	} {
+		if err := p.Validate(); err == nil {
+			t.Fatalf("expected %+v to be rejected", p)
+		}
+	}
+	if err := fetch.DefaultRetryPolicy.Validate(); err != nil {
+		t.Fatalf("default policy: %v", err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if got := fetch.ParseRetryAfter("5", now); got != 5*time.Second {
+		t.Fatalf("seconds: got %v", got)
+	}
+	if got := fetch.ParseRetryAfter(now.Add(10*time.Second).Format(http.TimeFormat), now); got != 10*time.Second {
+		t.Fatalf("http date: got %v", got)
+	}
+	if got := fetch.ParseRetryAfter("86400", now); got != fetch.MaxRetryAfter {
+		t.Fatalf("expected cap, got %v", got)
+	}
+	if got := fetch.ParseRetryAfter("soon", now); got != 0 {
+		t.Fatalf("invalid value: got %v", got)
+	}
+}
+
+func TestRetrier_WaitsForRetryAfterOnThrottledStaticFetch(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte("<html><body>ok</body></html>"))
+	}))
+	defer srv.Close()
+
+	opts := fetch.Options{URL: srv.URL, Mode: fetch.ModeStatic, Timeout: 5 * time.Second}
+	r := fetch.NewRetrier(fetch.RetryPolicy{BaseDelay: time.Millisecond}, 1)
+	start := time.Now()
+	var firstErr error
+	err := r.Do(context.Background(), func(attempt int) error {
+		_, err := fetch.Fetch(context.Background(), opts)
+		if attempt == 1 {
+			firstErr = err
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("expected the retry to succeed: %v", err)
+	}
+	var status *fetch.StatusError
+	if !errors.As(firstErr, &status) || status.Code != http.StatusTooManyRequests || status.RetryAfter != time.Second {
+		t.Fatalf("expected a 429 asking for 1s, got %v", firstErr)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("retried after %s, before Retry-After", elapsed)
+	}
+}
@@ -0,0 +1,61 @@
+package fetch
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RewriteRule rewrites URLs matching the regular expression Match to
+// Replace, which may refer to its groups as $1 or ${name}.
+type RewriteRule struct {
+	Match   string
+	Replace string
+}
+
+// ParseRewriteRule parses a --rewrite-url value, PATTERN=>REPLACEMENT.
+func ParseRewriteRule(s string) (RewriteRule, error) {
+	match, replace, ok := strings.Cut(s, "=>")
+	if !ok || strings.TrimSpace(match) == "" {
+		return RewriteRule{}, fmt.Errorf("url rewrite %q: expected PATTERN=>REPLACEMENT", s)
+	}
+	return RewriteRule{Match: strings.TrimSpace(match), Replace: strings.TrimSpace(replace)}, nil
+}
+
+// Rewriter applies rewrite rules to URLs before they are deduplicated and
+// fetched. A nil Rewriter leaves URLs unchanged.
+type Rewriter struct {
+	rules []compiledRewrite
+}
+
+type compiledRewrite struct {
+	match   *regexp.Regexp
+	replace string
+}
+
+// NewRewriter compiles rules. It returns nil when there are none.
+func NewRewriter(rules []RewriteRule) (*Rewriter, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	r := &Rewriter{}
+	for _, rule := range rules {
+		re, err := regexp.Compile(rule.Match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid url rewrite pattern %q: %w", rule.Match, err)
+		}
+		r.rules = append(r.rules, compiledRewrite{match: re, replace: rule.Replace})
+	}
+	return r, nil
+}
+
+// Rewrite applies every rule in order, each to the result of the previous.
+func (r *Rewriter) Rewrite(u string) string {
+	if r == nil || u == "" {
+		return u
+	}
+	for _, rule := range r.rules {
+		u = rule.match.ReplaceAllString(u, rule.replace)
+	}
+	return u
+}
@@ -0,0 +1,52 @@
+package fetch_test
+
+import (
+	"testing"
+
+	"go_scrap/internal/fetch"
+)
+
+func TestRewriter_AppliesRulesInOrder(t *testing.T) {
+	var rules []fetch.RewriteRule
+	for _, v := range []string{
+		`^http://=>https://`,
+		`^https://docs\.example\.com/ => https://docs-mirror.example.com/`,
+		`^(https://[^?#]*)$=>${1}?theme=light`,
+	} {
+		rule, err := fetch.ParseRewriteRule(v)
+		if err != nil {
+			t.Fatalf("parse %q: %v", v, err)
+		}
+		rules = append(rules, rule)
+	}
+	r, err := fetch.NewRewriter(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]string{
+		"http://docs.example.com/guide":   "https://docs-mirror.example.com/guide?theme=light",
+		"https://other.example.com/a?b=1": "https://other.example.com/a?b=1",
+		"":                                "",
+	}
+	for in, want := range cases {
+		if got := r.Rewrite(in); got != want {
+			t.Fatalf("Rewrite(%q): expected %q, got %q", in, want, got)
+		}
+	}
+}
+
+func TestRewriter_NilAndInvalid(t *testing.T) {
+	r, err := fetch.NewRewriter(nil)
+	if err != nil || r != nil {
+		t.Fatalf("expected no rewriter without rules, got %v, %v", r, err)
+	}
+	if got := r.Rewrite("https://example.com/"); got != "https://example.com/" {
+		t.Fatalf("nil rewriter changed the URL: %q", got)
+	}
+	if _, err := fetch.NewRewriter([]fetch.RewriteRule{{Match: "("}}); err == nil {
+		t.Fatal("expected an invalid pattern to be rejected")
+	}
+	if _, err := fetch.ParseRewriteRule("no-arrow"); err == nil {
+		t.Fatal("expected a rule without => to be rejected")
+	}
+}
@@ -0,0 +1,47 @@
+package fetch
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/url"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// ClientTLSConfig loads a client certificate for sites that require mutual
+// TLS. keyFile defaults to certFile so a combined PEM file works on its own.
+// It returns nil when no certificate is configured.
+func ClientTLSConfig(certFile, keyFile string) (*tls.Config, error) {
+	if certFile == "" {
+		return nil, nil
+	}
+	if keyFile == "" {
+		keyFile = certFile
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load client certificate: %w", err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, nil
+}
+
+// clientCertificates maps the configured client certificate onto Playwright,
+// which only presents it to the origin being fetched.
+func clientCertificates(opts Options) []playwright.ClientCertificate {
+	if opts.ClientCertFile == "" {
+		return nil
+	}
+	u, err := url.Parse(opts.URL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil
+	}
+	keyFile := opts.ClientKeyFile
+	if keyFile == "" {
+		keyFile = opts.ClientCertFile
+	}
+	return []playwright.ClientCertificate{{
+		Origin:   u.Scheme + "://" + u.Host,
+		CertPath: playwright.String(opts.ClientCertFile),
+		KeyPath:  playwright.String(keyFile),
+	}}
+}
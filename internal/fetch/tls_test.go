@@ -0,0 +1,112 @@
+package fetch
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeClientCert writes a self-signed client certificate and key as
+// separate PEM files and returns their paths.
+func writeClientCert(t *testing.T) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "go_scrap test client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "client.pem")
+	keyPath := filepath.Join(dir, "client-key.pem")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certPath, keyPath
+}
+
+func TestStaticTransport_PresentsClientCertificate(t *testing.T) {
+	certPath, keyPath := writeClientCert(t)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			http.Error(w, "no client certificate", http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	transport, err := staticTransport(Options{ClientCertFile: certPath, ClientKeyFile: keyPath})
+	if err != nil {
+		t.Fatalf("staticTransport: %v", err)
+	}
+	// Trust the test server; production relies on the system roots.
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	transport.TLSClientConfig.RootCAs = roots
+
+	resp, err := (&http.Client{Transport: transport}).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 with client certificate, got %d", resp.StatusCode)
+	}
+}
+
+func TestClientTLSConfig(t *testing.T) {
+	if cfg, err := ClientTLSConfig("", ""); cfg != nil || err != nil {
+		t.Fatalf("expected nil config without a certificate, got %v, %v", cfg, err)
+	}
+	certPath, _ := writeClientCert(t)
+	if _, err := ClientTLSConfig(certPath, ""); err == nil {
+		t.Fatal("expected error when the certificate file has no key")
+	}
+	if _, err := ClientTLSConfig(filepath.Join(t.TempDir(), "missing.pem"), ""); err == nil {
+		t.Fatal("expected error for a missing certificate")
+	}
+}
+
+func TestClientCertificates_ScopedToOrigin(t *testing.T) {
+	certs := clientCertificates(Options{URL: "https://docs.internal:8443/guide/", ClientCertFile: "c.pem"})
+	if len(certs) != 1 || certs[0].Origin != "https://docs.internal:8443" {
+		t.Fatalf("unexpected certificates: %#v", certs)
+	}
+	if *certs[0].KeyPath != "c.pem" {
+		t.Fatalf("expected key to default to the certificate file, got %q", *certs[0].KeyPath)
+	}
+	if certs := clientCertificates(Options{URL: "http://docs.internal/", ClientCertFile: "c.pem"}); certs != nil {
+		t.Fatalf("expected no certificates for plain http, got %#v", certs)
+	}
+}
@@ -0,0 +1,141 @@
+package footprint
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"sync"
+)
+
+// DomainStats is the traffic sent to one host.
+type DomainStats struct {
+	Requests  int   `json:"requests"`
+	Bytes     int64 `json:"bytes"`
+	CacheHits int   `json:"cache_hits"`
+	Errors    int   `json:"errors"`
+}
+
+// Summary is the run-wide footprint written to metrics.json.
+type Summary struct {
+	Requests     int                    `json:"requests"`
+	Bytes        int64                  `json:"bytes"`
+	CacheHits    int                    `json:"cache_hits"`
+	CacheHitRate float64                `json:"cache_hit_rate"`
+	Errors       int                    `json:"errors"`
+	Domains      map[string]DomainStats `json:"domains"`
+}
+
+// Recorder counts network requests, transferred bytes and cache hits per
+// host. A nil *Recorder is valid and records nothing.
+type Recorder struct {
+	mu      sync.Mutex
+	domains map[string]*DomainStats
+}
+
+func New() *Recorder {
+	return &Recorder{domains: map[string]*DomainStats{}}
+}
+
+type ctxKey struct{}
+
+// WithRecorder attaches r to ctx so fetchers deep in the pipeline can report.
+func WithRecorder(ctx context.Context, r *Recorder) context.Context {
+	return context.WithValue(ctx, ctxKey{}, r)
+}
+
+// From returns the recorder attached to ctx, or nil.
+func From(ctx context.Context) *Recorder {
+	if ctx == nil {
+		return nil
+	}
+	r, _ := ctx.Value(ctxKey{}).(*Recorder)
+	return r
+}
+
+// Request records one network request to rawURL that transferred n bytes.
+func (r *Recorder) Request(rawURL string, n int64, err error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d := r.domain(rawURL)
+	d.Requests++
+	d.Bytes += n
+	if err != nil {
+		d.Errors++
+	}
+}
+
+// CacheHit records a response served from the local cache.
+func (r *Recorder) CacheHit(rawURL string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.domain(rawURL).CacheHits++
+}
+
+func (r *Recorder) domain(rawURL string) *DomainStats {
+	host := rawURL
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	d, ok := r.domains[host]
+	if !ok {
+		d = &DomainStats{}
+		r.domains[host] = d
+	}
+	return d
+}
+
+func (r *Recorder) Summary() Summary {
+	s := Summary{Domains: map[string]DomainStats{}}
+	if r == nil {
+		return s
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for host, d := range r.domains {
+		s.Domains[host] = *d
+		s.Requests += d.Requests
+		s.Bytes += d.Bytes
+		s.CacheHits += d.CacheHits
+		s.Errors += d.Errors
+	}
+	if total := s.Requests + s.CacheHits; total > 0 {
+		s.CacheHitRate = float64(s.CacheHits) / float64(total)
+	}
+	return s
+}
+
+// Print writes a human-readable footprint with a per-domain breakdown.
+func (s Summary) Print(w io.Writer) {
+	fmt.Fprintf(w, "Footprint: %d request(s), %s transferred, %d cache hit(s) (%.0f%%), %d error(s)\n",
+		s.Requests, FormatBytes(s.Bytes), s.CacheHits, s.CacheHitRate*100, s.Errors)
+	hosts := make([]string, 0, len(s.Domains))
+	for host := range s.Domains {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		d := s.Domains[host]
+		fmt.Fprintf(w, "  %s: %d request(s), %s, %d cache hit(s), %d error(s)\n", host, d.Requests, FormatBytes(d.Bytes), d.CacheHits, d.Errors)
+	}
+}
+
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
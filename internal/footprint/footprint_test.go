@@ -0,0 +1,51 @@
+package footprint
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRecorder_Summary(t *testing.T) {
+	r := New()
+	r.Request("https://a.example/x", 100, nil)
+	r.Request("https://a.example/y", 50, errors.New("boom"))
+	r.CacheHit("https://b.example/img.png")
+
+	s := r.Summary()
+	if s.Requests != 2 || s.Bytes != 150 || s.CacheHits != 1 || s.Errors != 1 {
+		t.Fatalf("unexpected totals: %+v", s)
+	}
+	if got := s.CacheHitRate; got < 0.33 || got > 0.34 {
+		t.Fatalf("unexpected hit rate: %v", got)
+	}
+	if s.Domains["a.example"].Requests != 2 || s.Domains["b.example"].CacheHits != 1 {
+		t.Fatalf("unexpected domains: %+v", s.Domains)
+	}
+
+	var buf bytes.Buffer
+	s.Print(&buf)
+	if !strings.Contains(buf.String(), "a.example: 2 request(s), 150 B") {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestFrom_NilRecorderIsNoop(t *testing.T) {
+	r := From(context.Background())
+	r.Request("https://a.example", 10, nil)
+	r.CacheHit("https://a.example")
+	if s := r.Summary(); s.Requests != 0 {
+		t.Fatalf("expected empty summary, got %+v", s)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	cases := map[int64]string{0: "0 B", 1023: "1023 B", 1536: "1.5 KiB", 3 << 20: "3.0 MiB"}
+	for n, want := range cases {
+		if got := FormatBytes(n); got != want {
+			t.Fatalf("FormatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Package fsutil wraps the file operations our output writers use so they
+// survive Windows quirks: paths longer than MAX_PATH get the \\?\ prefix, and
+// writes that hit a sharing violation (an indexer, antivirus scanner or
+// editor holding the file open) are retried briefly instead of failing the
+// crawl.
+package fsutil
+
+import (
+	"os"
+	"strings"
+	"time"
+)
+
+// maxPath is the length past which Windows needs the \\?\ prefix; directories
+// are limited to MAX_PATH minus room for an 8.3 file name.
+const maxPath = 248
+
+// Retries for a locked file: 10ms, 20ms, ... up to ~1.3s in total.
+var (
+	lockRetries = 7
+	lockBackoff = 10 * time.Millisecond
+)
+
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	return retryLocked(func() error {
+		return os.WriteFile(LongPath(path), data, perm)
+	}, isLocked)
+}
+
+func Create(path string) (*os.File, error) {
+	return OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+func OpenFile(path string, flag int, perm os.FileMode) (*os.File, error) {
+	var f *os.File
+	err := retryLocked(func() error {
+		var err error
+		f, err = os.OpenFile(LongPath(path), flag, perm)
+		return err
+	}, isLocked)
+	return f, err
+}
+
+func MkdirAll(path string, perm os.FileMode) error {
+	return os.MkdirAll(LongPath(path), perm)
+}
+
+func Rename(oldPath, newPath string) error {
+	return retryLocked(func() error {
+		return os.Rename(LongPath(oldPath), LongPath(newPath))
+	}, isLocked)
+}
+
+func ReadFile(path string) ([]byte, error) {
+	var data []byte
+	err := retryLocked(func() error {
+		var err error
+		data, err = os.ReadFile(LongPath(path))
+		return err
+	}, isLocked)
+	return data, err
+}
+
+// retryLocked runs op until it succeeds, fails for another reason than a
+// lock, or the retries run out.
+func retryLocked(op func() error, locked func(error) bool) error {
+	wait := lockBackoff
+	for attempt := 0; ; attempt++ {
+		err := op()
+		if err == nil || attempt == lockRetries || !locked(err) {
+			return err
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+// extendedPath turns an absolute Windows path into its \\?\ form: drive
+// paths become \\?\C:\..., UNC paths \\?\UNC\server\share\.... Already
+// prefixed and short paths are returned unchanged.
+func extendedPath(abs string) string {
+	if len(abs) < maxPath || strings.HasPrefix(abs, `\\?\`) || strings.HasPrefix(abs, `\\.\`) {
+		return abs
+	}
+	abs = strings.ReplaceAll(abs, "/", `\`)
+	if strings.HasPrefix(abs, `\\`) {
+		return `\\?\UNC\` + abs[2:]
+	}
+	if len(abs) >= 3 && abs[1] == ':' && abs[2] == '\\' {
+		return `\\?\` + abs
+	}
+	return abs
+}
@@ -0,0 +1,12 @@
+//go:build !windows
+
+package fsutil
+
+// LongPath returns path unchanged outside Windows.
+func LongPath(path string) string {
+	return path
+}
+
+func isLocked(error) bool {
+	return false
+}
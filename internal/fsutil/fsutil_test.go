@@ -0,0 +1,55 @@
+package fsutil
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExtendedPath(t *testing.T) {
+	long := strings.Repeat("d", maxPath)
+	cases := map[string]string{
+		`C:\short`:               `C:\short`,
+		`C:\` + long:             `\\?\C:\` + long,
+		`C:/` + long:             `\\?\C:\` + long,
+		`\\server\share\` + long: `\\?\UNC\server\share\` + long,
+		`\\?\C:\` + long:         `\\?\C:\` + long,
+		`relative\` + long:       `relative\` + long,
+	}
+	for in, want := range cases {
+		if got := extendedPath(in); got != want {
+			t.Errorf("extendedPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRetryLocked_RetriesOnlyLockErrors(t *testing.T) {
+	defer func(d time.Duration) { lockBackoff = d }(lockBackoff)
+	lockBackoff = time.Microsecond
+	errLocked := errors.New("locked")
+	locked := func(err error) bool { return errors.Is(err, errLocked) }
+
+	calls := 0
+	err := retryLocked(func() error {
+		calls++
+		if calls < 3 {
+			return errLocked
+		}
+		return nil
+	}, locked)
+	if err != nil || calls != 3 {
+		t.Fatalf("expected success on the third attempt, got %v after %d calls", err, calls)
+	}
+
+	calls = 0
+	errOther := errors.New("denied")
+	if err := retryLocked(func() error { calls++; return errOther }, locked); !errors.Is(err, errOther) || calls != 1 {
+		t.Fatalf("expected other errors to fail at once, got %v after %d calls", err, calls)
+	}
+
+	calls = 0
+	if err := retryLocked(func() error { calls++; return errLocked }, locked); !errors.Is(err, errLocked) || calls != lockRetries+1 {
+		t.Fatalf("expected %d attempts on a file that stays locked, got %d (%v)", lockRetries+1, calls, err)
+	}
+}
@@ -0,0 +1,27 @@
+package fsutil
+
+import (
+	"errors"
+	"path/filepath"
+	"syscall"
+)
+
+const (
+	errorSharingViolation syscall.Errno = 32
+	errorLockViolation    syscall.Errno = 33
+)
+
+// LongPath returns path in a form Windows accepts past MAX_PATH. The \\?\
+// prefix disables path normalization, so the path is made absolute and
+// cleaned first.
+func LongPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil || len(abs) < maxPath {
+		return path
+	}
+	return extendedPath(abs)
+}
+
+func isLocked(err error) bool {
+	return errors.Is(err, errorSharingViolation) || errors.Is(err, errorLockViolation)
+}
@@ -0,0 +1,76 @@
+package markdown
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+)
+
+// Cache is a size-bounded LRU of HTML-to-Markdown conversions keyed by the
+// SHA-256 of the HTML. Crawled pages often repeat whole blocks (footers,
+// legal text, navigation remnants), so converters of one run share a Cache
+// to convert each block once. It is safe for concurrent use.
+type Cache struct {
+	mu      sync.Mutex
+	max     int
+	order   *list.List
+	entries map[[sha256.Size]byte]*list.Element
+	hits    int
+	misses  int
+}
+
+type cacheEntry struct {
+	key [sha256.Size]byte
+	md  string
+}
+
+// NewCache returns a Cache holding up to maxEntries conversions, or nil
+// (no caching) when maxEntries <= 0.
+func NewCache(maxEntries int) *Cache {
+	if maxEntries <= 0 {
+		return nil
+	}
+	return &Cache{
+		max:     maxEntries,
+		order:   list.New(),
+		entries: map[[sha256.Size]byte]*list.Element{},
+	}
+}
+
+// Stats returns how many conversions were served from and missed the cache.
+func (c *Cache) Stats() (hits, misses int) {
+	if c == nil {
+		return 0, 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+func (c *Cache) get(key [sha256.Size]byte) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return "", false
+	}
+	c.hits++
+	c.order.MoveToFront(el)
+	return el.Value.(*cacheEntry).md, true
+}
+
+func (c *Cache) put(key [sha256.Size]byte, md string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, md: md})
+	for c.order.Len() > c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
@@ -0,0 +1,48 @@
+package markdown_test
+
+import (
+	"testing"
+
+	"go_scrap/internal/markdown"
+)
+
+func TestCache_ReusesConversionsAcrossConverters(t *testing.T) {
+	cache := markdown.NewCache(2)
+	a, b := markdown.NewConverter(), markdown.NewConverter()
+	a.UseCache(cache)
+	b.UseCache(cache)
+
+	footer := "<p>Copyright <strong>Example</strong></p>"
+	first, err := a.SectionToMarkdown("Footer", 2, footer)
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	second, err := b.SectionToMarkdown("Legal", 3, footer)
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	if first != "## Footer\n\nCopyright **Example**\n" || second != "### Legal\n\nCopyright **Example**\n" {
+		t.Fatalf("unexpected markdown %q / %q", first, second)
+	}
+	if hits, misses := cache.Stats(); hits != 1 || misses != 1 {
+		t.Fatalf("expected 1 hit and 1 miss, got %d/%d", hits, misses)
+	}
+}
+
+func TestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache := markdown.NewCache(2)
+	conv := markdown.NewConverter()
+	conv.UseCache(cache)
+	for _, html := range []string{"<p>a</p>", "<p>b</p>", "<p>a</p>", "<p>c</p>", "<p>a</p>", "<p>b</p>"} {
+		if _, err := conv.SectionToMarkdown("", 1, html); err != nil {
+			t.Fatalf("convert: %v", err)
+		}
+	}
+	// a, b miss; a hits; c misses and evicts b; a hits; b misses again.
+	if hits, misses := cache.Stats(); hits != 2 || misses != 4 {
+		t.Fatalf("expected 2 hits and 4 misses, got %d/%d", hits, misses)
+	}
+	if markdown.NewCache(0) != nil {
+		t.Fatal("expected a zero-size cache to be disabled")
+	}
+}
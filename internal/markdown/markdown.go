@@ -1,6 +1,7 @@
 package markdown
 
 import (
+	"crypto/sha256"
 	"regexp"
 	"strings"
 
@@ -10,7 +11,8 @@ import (
 )
 
 type Converter struct {
-	md *htmltomd.Converter
+	md    *htmltomd.Converter
+	cache *Cache
 }
 
 func NewConverter() *Converter {
@@ -25,6 +27,13 @@ func NewConverter() *Converter {
 	return &Converter{md: conv}
 }
 
+// UseCache makes the converter look up and store section bodies in cache
+// (nil disables caching). Converters sharing a cache must be configured
+// identically, since the key is the HTML alone.
+func (c *Converter) UseCache(cache *Cache) {
+	c.cache = cache
+}
+
 func (c *Converter) SectionToMarkdown(headingText string, headingLevel int, contentHTML string) (string, error) {
 	heading := "#"
 	if headingLevel > 1 {
@@ -32,7 +41,7 @@ func (c *Converter) SectionToMarkdown(headingText string, headingLevel int, cont
 	}
 	headingLine := strings.TrimSpace(heading + " " + headingText)
 
-	body, err := c.md.ConvertString(contentHTML)
+	body, err := c.convertBody(contentHTML)
 	if err != nil {
 		return "", err
 	}
@@ -42,6 +51,22 @@ func (c *Converter) SectionToMarkdown(headingText string, headingLevel int, cont
 	return headingLine + "\n\n" + strings.TrimSpace(body) + "\n", nil
 }
 
+func (c *Converter) convertBody(contentHTML string) (string, error) {
+	if c.cache == nil || contentHTML == "" {
+		return c.md.ConvertString(contentHTML)
+	}
+	key := sha256.Sum256([]byte(contentHTML))
+	if body, ok := c.cache.get(key); ok {
+		return body, nil
+	}
+	body, err := c.md.ConvertString(contentHTML)
+	if err != nil {
+		return "", err
+	}
+	c.cache.put(key, body)
+	return body, nil
+}
+
 func codeBlockRule() htmltomd.Rule {
 	return htmltomd.Rule{
 		Filter: []string{"pre"},
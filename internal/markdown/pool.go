@@ -0,0 +1,31 @@
+package markdown
+
+import "sync"
+
+// Pool hands out Converters so concurrent workers never share the underlying
+// html-to-markdown converter, which keeps per-conversion state.
+type Pool struct {
+	pool sync.Pool
+}
+
+// NewPool returns a Pool that builds converters with factory
+// (NewConverter when factory is nil).
+func NewPool(factory func() *Converter) *Pool {
+	if factory == nil {
+		factory = NewConverter
+	}
+	return &Pool{pool: sync.Pool{New: func() any { return factory() }}}
+}
+
+// Get returns a converter for exclusive use until it is handed back with Put.
+func (p *Pool) Get() *Converter {
+	return p.pool.Get().(*Converter)
+}
+
+// Put returns a converter to the pool.
+func (p *Pool) Put(c *Converter) {
+	if c == nil {
+		return
+	}
+	p.pool.Put(c)
+}
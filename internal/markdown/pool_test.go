@@ -0,0 +1,39 @@
+package markdown_test
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"go_scrap/internal/markdown"
+)
+
+func TestPool_ConcurrentConversions(t *testing.T) {
+	pool := markdown.NewPool(nil)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conv := pool.Get()
+			defer pool.Put(conv)
+			want := fmt.Sprintf("para %d", i)
+			md, err := conv.SectionToMarkdown("Title", 2, "<p>"+want+"</p>")
+			if err != nil {
+				errs <- err
+				return
+			}
+			if !strings.Contains(md, want) {
+				errs <- fmt.Errorf("expected %q in %q", want, md)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
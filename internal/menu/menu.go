@@ -9,9 +9,18 @@ import (
 )
 
 type Node struct {
-	Title    string `json:"title"`
-	Href     string `json:"href"`
-	Anchor   string `json:"anchor"`
+	Title  string `json:"title"`
+	Href   string `json:"href"`
+	Anchor string `json:"anchor"`
+	// URL is Href resolved against the page URL (see ResolveURLs).
+	URL string `json:"url,omitempty"`
+	// Order is the node's position in a depth-first walk of the menu as it
+	// appears on the page; Depth is 0 for top-level entries.
+	Order int `json:"order"`
+	Depth int `json:"depth"`
+	// File is the generated section file relative to the output directory,
+	// set when section files are written.
+	File     string `json:"file,omitempty"`
 	Children []Node `json:"children,omitempty"`
 }
 
@@ -24,11 +33,45 @@ func Extract(doc *goquery.Document, selector string) ([]Node, error) {
 		return nil, errors.New("nav selector not found")
 	}
 
-	list := nav.Find("ul, ol").First()
-	if list.Length() == 0 {
-		return extractFlat(nav), nil
+	var nodes []Node
+	if list := nav.Find("ul, ol").First(); list.Length() == 0 {
+		nodes = extractFlat(nav)
+	} else {
+		nodes = extractList(list)
+	}
+	order := 0
+	number(nodes, 0, &order)
+	return nodes, nil
+}
+
+func number(nodes []Node, depth int, order *int) {
+	for i := range nodes {
+		nodes[i].Order = *order
+		nodes[i].Depth = depth
+		*order++
+		number(nodes[i].Children, depth+1, order)
+	}
+}
+
+// ResolveURLs sets each node's URL to its href resolved against pageURL.
+// Nodes without an href, or with one that does not parse, keep an empty URL.
+func ResolveURLs(nodes []Node, pageURL string) {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return
+	}
+	resolveURLs(nodes, base)
+}
+
+func resolveURLs(nodes []Node, base *url.URL) {
+	for i := range nodes {
+		if href := strings.TrimSpace(nodes[i].Href); href != "" {
+			if ref, err := url.Parse(href); err == nil {
+				nodes[i].URL = base.ResolveReference(ref).String()
+			}
+		}
+		resolveURLs(nodes[i].Children, base)
 	}
-	return extractList(list), nil
 }
 
 func extractList(list *goquery.Selection) []Node {
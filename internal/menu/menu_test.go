@@ -71,3 +71,49 @@ func TestExtract_SelectorMissing(t *testing.T) {
 		t.Fatal("expected error for missing nav selector")
 	}
 }
+
+func TestExtract_OrderDepthAndURLs(t *testing.T) {
+	html := `
+	<nav class="nav">
+	  <ul>
+	    <li><a href="#a">A</a></li>
+	    <li>
+	      <a href="guide/b.html#b">B</a>
+	      <ul>
+	        <li><a href="/api#b1">B1</a></li>
+	      </ul>
+	    </li>
+	    <li><a href="https://other.example/c">C</a></li>
+	  </ul>
+	</nav>`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	nodes, err := menu.Extract(doc, ".nav")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	menu.ResolveURLs(nodes, "https://example.com/docs/index.html")
+
+	b1 := nodes[1].Children[0]
+	if nodes[0].Order != 0 || nodes[1].Order != 1 || b1.Order != 2 || nodes[2].Order != 3 {
+		t.Fatalf("unexpected order: %d %d %d %d", nodes[0].Order, nodes[1].Order, b1.Order, nodes[2].Order)
+	}
+	if nodes[1].Depth != 0 || b1.Depth != 1 {
+		t.Fatalf("unexpected depth: %d %d", nodes[1].Depth, b1.Depth)
+	}
+	want := []string{
+		"https://example.com/docs/index.html#a",
+		"https://example.com/docs/guide/b.html#b",
+		"https://example.com/api#b1",
+		"https://other.example/c",
+	}
+	got := []string{nodes[0].URL, nodes[1].URL, b1.URL, nodes[2].URL}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("url %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package output
+
+import (
+	"encoding/json"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go_scrap/internal/fsutil"
+	"go_scrap/internal/parse"
+)
+
+// AnchorTarget is where an element ID from the source page ended up.
+type AnchorTarget struct {
+	File        string `json:"file"`
+	SectionFile string `json:"section_file,omitempty"`
+	HeadingID   string `json:"heading_id"`
+	Heading     string `json:"heading"`
+}
+
+// AnchorMap translates deep links into the source page (#fragment) to the
+// output files and headings that contain them.
+type AnchorMap struct {
+	URL        string                  `json:"url"`
+	Anchors    map[string]AnchorTarget `json:"anchors"`
+	Unresolved []string                `json:"unresolved,omitempty"`
+}
+
+// BuildAnchorMap maps every element ID and in-page href target of doc to the
+// section containing it. Heading IDs win over content IDs, and a content ID
+// shared by several sections resolves to the first, matching section file
+// lookup. sectionFiles maps anchors to section files as returned by
+// WriteSectionFilesContext and may be nil.
+func BuildAnchorMap(pageURL, markdownFile string, doc *parse.Document, sectionFiles map[string]string) AnchorMap {
+	m := AnchorMap{URL: indexPageURL(pageURL), Anchors: map[string]AnchorTarget{}}
+	if doc == nil {
+		return m
+	}
+
+	owner := map[string]int{}
+	for i, sec := range doc.Sections {
+		if sec.HeadingID != "" {
+			owner[sec.HeadingID] = i
+		}
+	}
+	for i, sec := range doc.Sections {
+		for _, id := range sec.ContentIDs {
+			if _, ok := owner[id]; !ok {
+				owner[id] = i
+			}
+		}
+	}
+
+	// A section's file is the one written for any anchor it owns.
+	sectionFile := map[int]string{}
+	anchors := make([]string, 0, len(sectionFiles))
+	for anchor := range sectionFiles {
+		anchors = append(anchors, anchor)
+	}
+	sort.Strings(anchors)
+	for _, anchor := range anchors {
+		if i, ok := owner[anchor]; ok {
+			if _, seen := sectionFile[i]; !seen {
+				sectionFile[i] = sectionFiles[anchor]
+			}
+		}
+	}
+
+	unresolved := map[string]struct{}{}
+	add := func(key string) {
+		if key == "" {
+			return
+		}
+		if _, done := m.Anchors[key]; done {
+			return
+		}
+		i, ok := owner[key]
+		if !ok {
+			if decoded, err := url.PathUnescape(key); err == nil {
+				i, ok = owner[decoded]
+			}
+		}
+		if !ok {
+			unresolved[key] = struct{}{}
+			return
+		}
+		sec := doc.Sections[i]
+		m.Anchors[key] = AnchorTarget{
+			File:        markdownFile,
+			SectionFile: sectionFile[i],
+			HeadingID:   sec.HeadingID,
+			Heading:     sec.HeadingText,
+		}
+	}
+	for _, sec := range doc.Sections {
+		add(sec.HeadingID)
+	}
+	for _, id := range doc.AllElementIDs {
+		add(id)
+	}
+	for _, raw := range doc.AnchorTargetsByRaw {
+		add(strings.TrimPrefix(raw, "#"))
+	}
+
+	for key := range unresolved {
+		if _, ok := m.Anchors[key]; !ok {
+			m.Unresolved = append(m.Unresolved, key)
+		}
+	}
+	sort.Strings(m.Unresolved)
+	return m
+}
+
+// WriteAnchors writes outDir/anchors.json.
+func WriteAnchors(outDir string, m AnchorMap, enc TextEncoding) (string, error) {
+	if err := fsutil.MkdirAll(outDir, 0755); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(outDir, "anchors.json")
+	if err := enc.writeFile(path, string(data)+"\n"); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,65 @@
+package output
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go_scrap/internal/parse"
+)
+
+func TestBuildAnchorMap_ResolvesIDsAndHrefTargets(t *testing.T) {
+	doc := &parse.Document{
+		Sections: []parse.Section{
+			{HeadingText: "Intro", HeadingID: "intro", ContentIDs: []string{"note-1", "shared"}},
+			{HeadingText: "Café", HeadingID: "café", ContentIDs: []string{"shared", "fig"}},
+		},
+		AllElementIDs:      []string{"nav", "intro", "note-1", "shared", "café", "fig"},
+		AnchorTargetsByRaw: []string{"#fig", "#caf%C3%A9", "#gone"},
+	}
+	sectionFiles := map[string]string{"café": "sections/cafe.md"}
+
+	m := BuildAnchorMap("https://example.com/docs#top", "content.md", doc, sectionFiles)
+	if m.URL != "https://example.com/docs" {
+		t.Fatalf("url = %q", m.URL)
+	}
+	want := map[string]AnchorTarget{
+		"intro":     {File: "content.md", HeadingID: "intro", Heading: "Intro"},
+		"note-1":    {File: "content.md", HeadingID: "intro", Heading: "Intro"},
+		"shared":    {File: "content.md", HeadingID: "intro", Heading: "Intro"},
+		"café":      {File: "content.md", SectionFile: "sections/cafe.md", HeadingID: "café", Heading: "Café"},
+		"fig":       {File: "content.md", SectionFile: "sections/cafe.md", HeadingID: "café", Heading: "Café"},
+		"caf%C3%A9": {File: "content.md", SectionFile: "sections/cafe.md", HeadingID: "café", Heading: "Café"},
+	}
+	if !reflect.DeepEqual(m.Anchors, want) {
+		t.Fatalf("anchors = %#v", m.Anchors)
+	}
+	if !reflect.DeepEqual(m.Unresolved, []string{"gone", "nav"}) {
+		t.Fatalf("unresolved = %v", m.Unresolved)
+	}
+}
+
+func TestWriteAnchors(t *testing.T) {
+	dir := t.TempDir()
+	doc := &parse.Document{Sections: []parse.Section{{HeadingText: "A", HeadingID: "a"}}}
+	path, err := WriteAnchors(dir, BuildAnchorMap("https://example.com", "content.md", doc, nil), TextEncoding{})
+	if err != nil {
+		t.Fatalf("WriteAnchors: %v", err)
+	}
+	if path != filepath.Join(dir, "anchors.json") {
+		t.Fatalf("unexpected path: %s", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got AnchorMap
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got.Anchors["a"].HeadingID != "a" || got.Anchors["a"].File != "content.md" {
+		t.Fatalf("unexpected anchors.json: %s", data)
+	}
+}
@@ -0,0 +1,216 @@
+package output
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_scrap/internal/fetch"
+	"go_scrap/internal/footprint"
+	"go_scrap/internal/fsutil"
+)
+
+// partSuffix marks an asset still being downloaded. A .part file left by a
+// failed attempt, or an earlier run, is resumed with a Range request.
+const partSuffix = ".part"
+
+// validatorSuffix names the file kept next to a .part file with the ETag or
+// Last-Modified of the response it came from. It is sent as If-Range on
+// resume, so an asset that changed since is downloaded anew rather than
+// appended to the old bytes.
+const validatorSuffix = ".validator"
+
+// AssetRetry is the retry policy for one asset download. Each retry resumes
+// the partial file rather than starting over.
+type AssetRetry struct {
+	// Retries is how many times a failed download is tried again.
+	Retries int
+	// Backoff is the wait before the first retry, doubled for each further
+	// one.
+	Backoff time.Duration
+}
+
+// statusError is an unexpected HTTP status for an asset.
+type statusError struct {
+	code int
+}
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("unexpected status %d", e.code)
+}
+
+// retryable reports whether a download that failed with err may succeed
+// when tried again: network errors, throttling and server errors.
+func retryable(err error) bool {
+	var status statusError
+	if errors.As(err, &status) {
+		return status.code == http.StatusTooManyRequests || status.code >= 500
+	}
+	return true
+}
+
+func fetchAsset(ctx context.Context, job *downloadJob, userAgent string, retry AssetRetry) error {
+	if job == nil {
+		return fmt.Errorf("missing download job")
+	}
+	if _, err := os.Stat(job.LocalPath); err == nil {
+		footprint.From(ctx).CacheHit(job.AbsoluteURL)
+		return nil
+	}
+	part := job.LocalPath + partSuffix
+	if err := downloadPart(ctx, job.AbsoluteURL, userAgent, part, retry); err != nil {
+		return err
+	}
+	return fsutil.Rename(part, job.LocalPath)
+}
+
+// downloadPart downloads assetURL to partPath, retrying per retry. Every
+// attempt continues from what is already in partPath; it is kept when all
+// of them fail, so that the next run resumes it too.
+func downloadPart(ctx context.Context, assetURL, userAgent, partPath string, retry AssetRetry) error {
+	var err error
+	for attempt := 0; attempt <= max(retry.Retries, 0); attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(retry.Backoff << (attempt - 1)):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		err = downloadPartOnce(ctx, assetURL, userAgent, partPath)
+		if err == nil {
+			_ = os.Remove(fsutil.LongPath(partPath + validatorSuffix))
+			return nil
+		}
+		if ctx.Err() != nil || !retryable(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// downloadPartOnce requests the rest of assetURL after the bytes already in
+// partPath and appends it, if the asset still matches the validator saved
+// with them. A server that ignores the range, or has a changed asset, sends
+// the whole of it, which replaces the partial file. A partial file without
+// a validator can't be matched and is downloaded anew.
+func downloadPartOnce(ctx context.Context, assetURL, userAgent, partPath string) error {
+	rec := footprint.From(ctx)
+	validatorPath := partPath + validatorSuffix
+	var offset int64
+	var validator string
+	if info, err := os.Stat(fsutil.LongPath(partPath)); err == nil {
+		if data, err := fsutil.ReadFile(validatorPath); err == nil {
+			validator = strings.TrimSpace(string(data))
+		}
+		if validator != "" {
+			offset = info.Size()
+		}
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second, Transport: fetch.TransportFrom(ctx)}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, assetURL, nil)
+	if err != nil {
+		return err
+	}
+	if userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", validator)
+	}
+	if err := fetch.WaitURL(ctx, assetURL, 0); err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		rec.Request(assetURL, 0, err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	flag := os.O_WRONLY | os.O_CREATE
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		flag |= os.O_TRUNC
+		// Saved before the body, so an interrupted download can resume.
+		if v := partValidator(resp); v == "" || fsutil.WriteFile(validatorPath, []byte(v), 0600) != nil {
+			_ = os.Remove(fsutil.LongPath(validatorPath))
+		}
+	case resp.StatusCode == http.StatusPartialContent && offset > 0 && rangeStart(resp.Header.Get("Content-Range")) == offset:
+		flag |= os.O_APPEND
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0 && rangeTotal(resp.Header.Get("Content-Range")) == offset:
+		// The partial file already holds the whole asset.
+		rec.Request(assetURL, 0, nil)
+		return nil
+	default:
+		err := statusError{code: resp.StatusCode}
+		if resp.StatusCode == http.StatusPartialContent || resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+			// The partial file does not match what the server has; start
+			// over on the next attempt.
+			_ = os.Remove(fsutil.LongPath(partPath))
+			_ = os.Remove(fsutil.LongPath(validatorPath))
+		}
+		rec.Request(assetURL, 0, err)
+		return err
+	}
+
+	out, err := fsutil.OpenFile(partPath, flag, 0666)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(out, resp.Body)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	rec.Request(assetURL, n, err)
+	return err
+}
+
+// partValidator is the If-Range validator for resuming resp: its strong
+// ETag, or else its Last-Modified, or "".
+func partValidator(resp *http.Response) string {
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return resp.Header.Get("Last-Modified")
+}
+
+// rangeStart is the first byte of a Content-Range such as "bytes 100-199/200",
+// or -1.
+func rangeStart(contentRange string) int64 {
+	spec, ok := strings.CutPrefix(contentRange, "bytes ")
+	if !ok {
+		return -1
+	}
+	first, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return -1
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(first), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// rangeTotal is the complete length of a Content-Range such as
+// "bytes */200", or -1.
+func rangeTotal(contentRange string) int64 {
+	_, total, ok := strings.Cut(contentRange, "/")
+	if !ok {
+		return -1
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(total), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
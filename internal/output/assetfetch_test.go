@@ -0,0 +1,149 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go_scrap/internal/fetch"
+)
+
+func TestFetchAsset_ResumesInterruptedDownloadWithRange(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	var requests atomic.Int32
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if requests.Add(1) == 1 {
+			// Promise the whole file, send half, then drop the connection.
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			_, _ = w.Write(content[:len(content)/2])
+			return
+		}
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "manual.pdf", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	job, err := buildDownloadJob(srv.URL+"/manual.pdf", srv.URL, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fetchAsset(context.Background(), job, "test", AssetRetry{Retries: 1}); err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	got, err := os.ReadFile(job.LocalPath)
+	if err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("expected the resumed file to match, got %d bytes (%v)", len(got), err)
+	}
+	if len(ranges) != 1 || ranges[0] != "bytes="+strconv.Itoa(len(content)/2)+"-" {
+		t.Fatalf("expected the retry to request the rest, got %q", ranges)
+	}
+	if _, err := os.Stat(job.LocalPath + partSuffix); !os.IsNotExist(err) {
+		t.Fatalf("expected the .part file to be renamed, got %v", err)
+	}
+}
+
+func TestFetchAsset_RestartsWhenTheAssetChanged(t *testing.T) {
+	content := bytes.Repeat([]byte("abcdefghij"), 100)
+	var ifRange string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ifRange = r.Header.Get("If-Range")
+		w.Header().Set("ETag", `"v2"`)
+		http.ServeContent(w, r, "manual.pdf", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	job, err := buildDownloadJob(srv.URL+"/manual.pdf", srv.URL, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part := job.LocalPath + partSuffix
+	if err := os.WriteFile(part, []byte("old bytes of v1"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(part+validatorSuffix, []byte(`"v1"`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := fetchAsset(context.Background(), job, "test", AssetRetry{}); err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if ifRange != `"v1"` {
+		t.Fatalf("expected the resume to send If-Range, got %q", ifRange)
+	}
+	if got, err := os.ReadFile(job.LocalPath); err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("expected the changed asset in full, got %q (%v)", got, err)
+	}
+	if _, err := os.Stat(part + validatorSuffix); !os.IsNotExist(err) {
+		t.Fatalf("expected the validator to be removed, got %v", err)
+	}
+}
+
+func TestFetchAsset_KeepsPartAndSkipsRetriesForClientErrors(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path == "/gone.zip" {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, "busy", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	retry := AssetRetry{Retries: 2, Backoff: time.Millisecond}
+	gone, _ := buildDownloadJob(srv.URL+"/gone.zip", srv.URL, dir)
+	if err := fetchAsset(context.Background(), gone, "test", retry); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("expected a 404, got %v", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("expected no retries for a 404, got %d requests", n)
+	}
+
+	busy, _ := buildDownloadJob(srv.URL+"/busy.zip", srv.URL, dir)
+	if err := os.WriteFile(busy.LocalPath+partSuffix, []byte("partial"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := fetchAsset(context.Background(), busy, "test", retry); err == nil {
+		t.Fatal("expected the busy server to fail the download")
+	}
+	if n := requests.Load(); n != 4 {
+		t.Fatalf("expected 3 attempts for a 503, got %d requests", n-1)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, filepath.Base(busy.LocalPath)+partSuffix)); err != nil || string(data) != "partial" {
+		t.Fatalf("expected the .part file to be kept for the next run, got %q (%v)", data, err)
+	}
+}
+
+func TestFetchAsset_UsesTheRunTransport(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write([]byte("data"))
+	}))
+	defer srv.Close()
+
+	denied := errors.New("domain is denied")
+	ctx := fetch.WithTransport(context.Background(), fetch.RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return nil, denied
+	}))
+	job, _ := buildDownloadJob(srv.URL+"/manual.pdf", srv.URL, t.TempDir())
+	if err := fetchAsset(ctx, job, "test", AssetRetry{}); !errors.Is(err, denied) {
+		t.Fatalf("expected the run transport's error, got %v", err)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Fatalf("expected no request past the run transport, got %d", n)
+	}
+}
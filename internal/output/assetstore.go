@@ -0,0 +1,359 @@
+package output
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"go_scrap/internal/footprint"
+	"go_scrap/internal/fsutil"
+	"go_scrap/internal/warnings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// AssetsManifestName is the assets index: every downloaded asset's file,
+// original URL and the pages referencing it.
+const AssetsManifestName = "assets.json"
+
+// maxAssetStem caps the original-name part of an asset file name.
+const maxAssetStem = 64
+
+// AssetIndex is the content of assets.json.
+type AssetIndex struct {
+	Assets []IndexedAsset `json:"assets"`
+}
+
+// IndexedAsset is one downloaded asset URL. URLs whose content is identical
+// share a File.
+type IndexedAsset struct {
+	File   string   `json:"file"`
+	URL    string   `json:"url"`
+	SHA256 string   `json:"sha256"`
+	Pages  []string `json:"pages"`
+}
+
+// AssetStore is an assets directory shared by every page of a run. An image
+// is downloaded once however many pages reference it, and identical content
+// at two URLs is kept once. Files are named after a prefix of the SHA-256
+// of their content, or with OriginalNames after the URL's file name, with
+// that hash prefix added only when another file already has the name. It is
+// safe for concurrent use by page workers.
+type AssetStore struct {
+	dir string
+	// OriginalNames keeps sanitized original file names.
+	OriginalNames bool
+	// Retry is the policy for each download.
+	Retry AssetRetry
+
+	mu    sync.Mutex
+	byURL map[string]*storedAsset
+	// files maps file names to the SHA-256 of their content, and bySum back.
+	files map[string]string
+	bySum map[string]string
+}
+
+// storedAsset is an asset downloaded, or being downloaded, into the store;
+// done is closed once file or err is set.
+type storedAsset struct {
+	done  chan struct{}
+	file  string
+	sum   string
+	err   error
+	pages map[string]struct{}
+}
+
+// OpenAssetStore opens the store in dir, reusing the files its index
+// records from an earlier run.
+func OpenAssetStore(dir string) *AssetStore {
+	s := &AssetStore{dir: dir, byURL: map[string]*storedAsset{}, files: map[string]string{}, bySum: map[string]string{}}
+	data, err := fsutil.ReadFile(filepath.Join(dir, AssetsManifestName))
+	if err != nil {
+		return s
+	}
+	var index AssetIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return s
+	}
+	for _, a := range index.Assets {
+		if a.File == "" || a.File != filepath.Base(a.File) || a.SHA256 == "" {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, a.File)); err != nil {
+			continue
+		}
+		done := make(chan struct{})
+		close(done)
+		stored := &storedAsset{done: done, file: a.File, sum: a.SHA256, pages: map[string]struct{}{}}
+		for _, page := range a.Pages {
+			stored.pages[page] = struct{}{}
+		}
+		s.byURL[a.URL] = stored
+		s.files[a.File] = a.SHA256
+		s.bySum[a.SHA256] = a.File
+	}
+	return s
+}
+
+// Dir is the store's directory.
+func (s *AssetStore) Dir() string {
+	return s.dir
+}
+
+// LinkPrefix is how files in refDir link to the store, e.g. "../../assets/".
+func (s *AssetStore) LinkPrefix(refDir string) string {
+	rel, err := filepath.Rel(refDir, s.dir)
+	if err != nil {
+		rel = s.dir
+	}
+	return filepath.ToSlash(rel) + "/"
+}
+
+// DownloadContext is the package-level DownloadContext for a store: the
+// images of doc are downloaded into it and their src points at it, relative
+// to refDir, the directory the page's files are written to.
+func (s *AssetStore) DownloadContext(ctx context.Context, doc *goquery.Document, baseURL, refDir, userAgent string) error {
+	if doc == nil {
+		return errors.New("nil document")
+	}
+	prefix := s.LinkPrefix(refDir)
+	doc.Find("img").EachWithBreak(func(_ int, sel *goquery.Selection) bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		src, exists := sel.Attr("src")
+		if !exists || src == "" {
+			return true
+		}
+		job, err := buildDownloadJob(src, baseURL, "")
+		if err != nil || job == nil {
+			return true
+		}
+		file, err := s.get(ctx, job.AbsoluteURL, baseURL, userAgent)
+		if err == nil {
+			sel.SetAttr("src", prefix+file)
+		} else if ctx.Err() == nil {
+			warnings.Report(ctx, warnings.Warning{
+				Code:    warnings.CodeAssetDownload,
+				Message: fmt.Sprintf("failed to download %s: %v", job.AbsoluteURL, err),
+				URL:     baseURL,
+				Context: map[string]string{"asset": job.AbsoluteURL, "error": err.Error()},
+			})
+		}
+		return true
+	})
+	return ctx.Err()
+}
+
+// get returns the file holding assetURL for pageURL, downloading it unless
+// another page already has or is doing so. A failed download is not retried
+// by later pages, unless it failed because its page was cancelled.
+func (s *AssetStore) get(ctx context.Context, assetURL, pageURL, userAgent string) (string, error) {
+	for {
+		s.mu.Lock()
+		a, ok := s.byURL[assetURL]
+		if !ok {
+			a = &storedAsset{done: make(chan struct{}), pages: map[string]struct{}{}}
+			s.byURL[assetURL] = a
+			s.mu.Unlock()
+			a.file, a.sum, a.err = s.fetch(ctx, assetURL, userAgent)
+			s.mu.Lock()
+			if a.err != nil && ctx.Err() != nil {
+				delete(s.byURL, assetURL)
+			} else if a.err == nil {
+				a.pages[pageURL] = struct{}{}
+			}
+			s.mu.Unlock()
+			close(a.done)
+			return a.file, a.err
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-a.done:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+		if a.err != nil && (errors.Is(a.err, context.Canceled) || errors.Is(a.err, context.DeadlineExceeded)) {
+			continue
+		}
+		if a.err == nil {
+			s.mu.Lock()
+			a.pages[pageURL] = struct{}{}
+			s.mu.Unlock()
+			footprint.From(ctx).CacheHit(assetURL)
+		}
+		return a.file, a.err
+	}
+}
+
+// fetch downloads assetURL to a partial file named after the URL, so that
+// a later page or run resumes it, then names it and returns the name and
+// the SHA-256 of the content.
+func (s *AssetStore) fetch(ctx context.Context, assetURL, userAgent string) (string, string, error) {
+	if err := fsutil.MkdirAll(s.dir, 0755); err != nil {
+		return "", "", err
+	}
+	urlSum := sha256.Sum256([]byte(assetURL))
+	part := filepath.Join(s.dir, "."+hex.EncodeToString(urlSum[:8])+partSuffix)
+	if err := downloadPart(ctx, assetURL, userAgent, part, s.Retry); err != nil {
+		return "", "", err
+	}
+	sum, err := fileSHA256(part)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Naming and renaming are serialized, so that two pages storing
+	// different content cannot claim the same name.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if file, ok := s.bySum[sum]; ok {
+		_ = os.Remove(fsutil.LongPath(part))
+		return file, sum, nil
+	}
+	file := s.name(assetURL, sum)
+	path := filepath.Join(s.dir, file)
+	if _, err := os.Stat(fsutil.LongPath(path)); err == nil {
+		_ = os.Remove(fsutil.LongPath(part))
+	} else if err := fsutil.Rename(part, path); err != nil {
+		return "", "", err
+	}
+	s.files[file] = sum
+	s.bySum[sum] = file
+	return file, sum, nil
+}
+
+// name picks the file name for new content with the given sum. It runs
+// with s.mu held.
+func (s *AssetStore) name(assetURL, sum string) string {
+	stem, ext := assetFileName(assetURL)
+	if !s.OriginalNames {
+		return sum[:16] + ext
+	}
+	if file := stem + ext; s.nameFree(file, sum) {
+		return file
+	}
+	return stem + "-" + sum[:8] + ext
+}
+
+// nameFree reports whether file can hold content with the given sum: no
+// other content has it, in the store or on disk.
+func (s *AssetStore) nameFree(file, sum string) bool {
+	if owner, ok := s.files[file]; ok {
+		return owner == sum
+	}
+	onDisk, err := fileSHA256(filepath.Join(s.dir, file))
+	if err != nil {
+		return os.IsNotExist(err)
+	}
+	return onDisk == sum
+}
+
+// assetFileName splits the file name of assetURL into a sanitized stem and
+// extension, as buildDownloadJob picks it (.jpg when there is none).
+func assetFileName(assetURL string) (string, string) {
+	var base string
+	if u, err := url.Parse(assetURL); err == nil {
+		base = path.Base(u.Path)
+		if unescaped, err := url.PathUnescape(base); err == nil {
+			base = unescaped
+		}
+	}
+	ext := strings.ToLower(path.Ext(base))
+	stem := strings.TrimSuffix(base, path.Ext(base))
+	if ext == "" || !isSafeExt(ext) {
+		ext = ".jpg"
+	}
+
+	var b strings.Builder
+	for _, r := range stem {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('-')
+		}
+	}
+	stem = strings.Trim(b.String(), "-_")
+	for strings.Contains(stem, "--") {
+		stem = strings.ReplaceAll(stem, "--", "-")
+	}
+	if len(stem) > maxAssetStem {
+		stem = strings.TrimRight(stem[:maxAssetStem], "-_")
+	}
+	if stem == "" {
+		stem = "asset"
+	}
+	return stem, ext
+}
+
+func isSafeExt(ext string) bool {
+	if len(ext) > 6 {
+		return false
+	}
+	for _, r := range ext[1:] {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// Index lists every asset downloaded so far, by file then URL.
+func (s *AssetStore) Index() AssetIndex {
+	index := AssetIndex{Assets: []IndexedAsset{}}
+	s.mu.Lock()
+	for assetURL, a := range s.byURL {
+		select {
+		case <-a.done:
+		default:
+			continue
+		}
+		if a.err != nil {
+			continue
+		}
+		pages := make([]string, 0, len(a.pages))
+		for page := range a.pages {
+			pages = append(pages, page)
+		}
+		sort.Strings(pages)
+		index.Assets = append(index.Assets, IndexedAsset{File: a.file, URL: assetURL, SHA256: a.sum, Pages: pages})
+	}
+	s.mu.Unlock()
+	sort.Slice(index.Assets, func(i, j int) bool {
+		if index.Assets[i].File != index.Assets[j].File {
+			return index.Assets[i].File < index.Assets[j].File
+		}
+		return index.Assets[i].URL < index.Assets[j].URL
+	})
+	return index
+}
+
+// WriteManifest writes the store's assets.json and returns its path.
+// Nothing is written when the store is empty.
+func (s *AssetStore) WriteManifest() (string, error) {
+	index := s.Index()
+	if len(index.Assets) == 0 {
+		return "", nil
+	}
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(s.dir, AssetsManifestName)
+	if err := fsutil.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
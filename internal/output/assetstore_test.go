@@ -0,0 +1,109 @@
+package output
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestAssetStore_DownloadsEachURLOnceAcrossPages(t *testing.T) {
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		_, _ = w.Write([]byte("image " + strings.TrimPrefix(r.URL.Path, "/img/")[:1]))
+	}))
+	defer srv.Close()
+
+	root := t.TempDir()
+	store := OpenAssetStore(filepath.Join(root, "assets"))
+	html := `<p><img src="/img/a.png"><img src="/img/b.png"></p>`
+	docs := make([]*goquery.Document, 8)
+	var wg sync.WaitGroup
+	for i := range docs {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+		if err != nil {
+			t.Fatal(err)
+		}
+		docs[i] = doc
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			refDir := filepath.Join(root, "pages", "guide")
+			if err := store.DownloadContext(context.Background(), doc, srv.URL+"/guide", refDir, "test"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := fetches.Load(); n != 2 {
+		t.Fatalf("expected 2 downloads for 8 pages, got %d", n)
+	}
+	src := docs[3].Find("img").First().AttrOr("src", "")
+	if !strings.HasPrefix(src, "../../assets/") || !strings.HasSuffix(src, ".png") {
+		t.Fatalf("expected a link into the shared store, got %q", src)
+	}
+	if _, err := store.WriteManifest(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A later run reuses the files its manifest records.
+	reopened := OpenAssetStore(filepath.Join(root, "assets"))
+	doc, _ := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err := reopened.DownloadContext(context.Background(), doc, srv.URL+"/guide", root, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Fatalf("expected the reopened store to download nothing, got %d downloads", n)
+	}
+	if got := doc.Find("img").First().AttrOr("src", ""); got != strings.TrimPrefix(src, "../../") {
+		t.Fatalf("expected %q, got %q", strings.TrimPrefix(src, "../../"), got)
+	}
+}
+
+func TestAssetStore_OriginalNamesAddHashOnlyOnCollision(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := "diagram"
+		if strings.HasPrefix(r.URL.Path, "/v2/") {
+			body = "diagram v2"
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	root := t.TempDir()
+	store := OpenAssetStore(filepath.Join(root, "assets"))
+	store.OriginalNames = true
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<p>
+		<img src="/v1/Flow%20Diagram.PNG"><img src="/v2/Flow%20Diagram.PNG"><img src="/mirror/copy.png"></p>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DownloadContext(context.Background(), doc, srv.URL+"/guide", root, "test"); err != nil {
+		t.Fatal(err)
+	}
+	var srcs []string
+	doc.Find("img").Each(func(_ int, s *goquery.Selection) { srcs = append(srcs, s.AttrOr("src", "")) })
+	if srcs[0] != "assets/Flow-Diagram.png" {
+		t.Fatalf("expected the original name, got %q", srcs[0])
+	}
+	if !strings.HasPrefix(srcs[1], "assets/Flow-Diagram-") || srcs[1] == srcs[0] {
+		t.Fatalf("expected a hash suffix for the clashing name, got %q", srcs[1])
+	}
+	if srcs[2] != srcs[0] {
+		t.Fatalf("expected identical content to share a file, got %q", srcs[2])
+	}
+
+	index := store.Index()
+	// Sorted by file, then URL: the suffixed file sorts first.
+	if len(index.Assets) != 3 || index.Assets[2].File != "Flow-Diagram.png" || index.Assets[2].URL != srv.URL+"/v1/Flow%20Diagram.PNG" || index.Assets[2].Pages[0] != srv.URL+"/guide" {
+		t.Fatalf("unexpected index %+v", index)
+	}
+}
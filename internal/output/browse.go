@@ -0,0 +1,269 @@
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+
+	xhtml "golang.org/x/net/html"
+
+	"go_scrap/internal/fsutil"
+)
+
+// BrowsePage is a scraped page listed in index.html.
+type BrowsePage struct {
+	URL string `json:"url"`
+	// Markdown is the page's content.md relative to the output directory.
+	Markdown string `json:"markdown"`
+}
+
+// browseSection is the subset of an index.jsonl record embedded for search.
+type browseSection struct {
+	URL         string `json:"url"`
+	SourceURL   string `json:"source_url"`
+	Heading     string `json:"heading"`
+	Level       int    `json:"level"`
+	HeadingPath string `json:"heading_path"`
+	Text        string `json:"text"`
+}
+
+type browseData struct {
+	Title    string          `json:"title"`
+	Pages    []BrowsePage    `json:"pages"`
+	Sections []browseSection `json:"sections"`
+	Files    []string        `json:"files"`
+	Report   any             `json:"report,omitempty"`
+}
+
+// browseFiles are linked from index.html when present in the output dir.
+var browseFiles = []string{
+	"content.md", "content.json", "content.ndjson", "content.json.gz", "content.ndjson.gz",
+	"menu.json", NavSummary, "anchors.json", "index.jsonl", "corpus.jsonl",
+	"crawl-index.json", "ATTRIBUTION.md",
+}
+
+// maxBrowseText caps the searchable text kept per section so index.html stays
+// small enough to open directly in a browser.
+const maxBrowseText = 4000
+
+// WriteBrowseHTML writes outDir/index.html, a self-contained page that lists
+// pages and sections and searches them client-side. Section data is read from
+// outDir/index.jsonl and embedded, so the file works without a server. rep is
+// shown as JSON when non-nil.
+func WriteBrowseHTML(outDir, title string, pages []BrowsePage, rep any) (string, error) {
+	data := browseData{Title: title, Pages: make([]BrowsePage, 0, len(pages)), Report: rep}
+	for _, p := range pages {
+		// index.jsonl records carry the URL without its fragment.
+		p.URL = indexPageURL(p.URL)
+		data.Pages = append(data.Pages, p)
+	}
+	sections, err := readBrowseSections(filepath.Join(outDir, "index.jsonl"))
+	if err != nil {
+		return "", err
+	}
+	data.Sections = sections
+	data.Files = []string{}
+	for _, name := range browseFiles {
+		if _, err := os.Stat(filepath.Join(outDir, name)); err == nil {
+			data.Files = append(data.Files, name)
+		}
+	}
+
+	// json.Marshal escapes <, > and &, so the payload cannot close the
+	// surrounding script element.
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	page := strings.ReplaceAll(browseTemplate, "{{TITLE}}", html.EscapeString(title))
+	page = strings.Replace(page, "{{DATA}}", string(payload), 1)
+
+	path := filepath.Join(outDir, "index.html")
+	if err := fsutil.WriteFile(path, []byte(page), 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func readBrowseSections(path string) ([]browseSection, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []browseSection{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	sections := []browseSection{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		var rec IndexRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		sections = append(sections, browseSection{
+			URL:         rec.URL,
+			SourceURL:   rec.SourceURL,
+			Heading:     rec.Heading,
+			Level:       rec.HeadingLevel,
+			HeadingPath: rec.HeadingPath,
+			Text:        truncateRunes(htmlText(rec.Content), maxBrowseText),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return sections, nil
+}
+
+// htmlText flattens an HTML fragment to whitespace-collapsed text.
+func htmlText(fragment string) string {
+	var b strings.Builder
+	z := xhtml.NewTokenizer(strings.NewReader(fragment))
+	skip := 0
+	for {
+		switch z.Next() {
+		case xhtml.ErrorToken:
+			return strings.Join(strings.Fields(b.String()), " ")
+		case xhtml.StartTagToken:
+			if name, _ := z.TagName(); string(name) == "script" || string(name) == "style" {
+				skip++
+			}
+			b.WriteByte(' ')
+		case xhtml.EndTagToken:
+			if name, _ := z.TagName(); (string(name) == "script" || string(name) == "style") && skip > 0 {
+				skip--
+			}
+			b.WriteByte(' ')
+		case xhtml.TextToken:
+			if skip == 0 {
+				b.Write(z.Text())
+			}
+		}
+	}
+}
+
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
+
+const browseTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{TITLE}}</title>
+<style>
+body { font: 15px/1.5 system-ui, sans-serif; margin: 0; color: #222; }
+header { padding: 16px 24px; border-bottom: 1px solid #ddd; }
+header h1 { font-size: 20px; margin: 0 0 8px; }
+#q { width: 100%; max-width: 640px; padding: 8px; font-size: 15px; box-sizing: border-box; }
+main { display: flex; gap: 24px; padding: 16px 24px; }
+aside { flex: 0 0 280px; }
+section { flex: 1; min-width: 0; }
+h2 { font-size: 16px; margin: 16px 0 8px; }
+ul { padding-left: 18px; margin: 0; }
+.hit { margin: 0 0 12px; }
+.hit .path { color: #666; font-size: 13px; }
+.hit .text { color: #444; font-size: 13px; }
+pre { background: #f6f6f6; padding: 8px; overflow: auto; font-size: 12px; }
+.muted { color: #888; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{TITLE}}</h1>
+<input id="q" type="search" placeholder="Search sections..." autofocus>
+</header>
+<main>
+<aside>
+<h2>Pages</h2><ul id="pages"></ul>
+<h2>Files</h2><ul id="files"></ul>
+</aside>
+<section>
+<h2 id="count"></h2>
+<div id="results"></div>
+<div id="report-wrap"><h2>Report</h2><pre id="report"></pre></div>
+</section>
+</main>
+<script type="application/json" id="data">{{DATA}}</script>
+<script>
+(function () {
+  var data = JSON.parse(document.getElementById("data").textContent);
+  function el(tag, text, href) {
+    var e = document.createElement(href ? "a" : tag);
+    if (href) { e.href = href; }
+    if (text) { e.textContent = text; }
+    return e;
+  }
+  function item(list, text, href) {
+    var li = el("li");
+    li.appendChild(el("a", text, href));
+    list.appendChild(li);
+  }
+  var pages = document.getElementById("pages");
+  var markdownFor = {};
+  data.pages.forEach(function (p) {
+    markdownFor[p.url] = p.markdown;
+    item(pages, p.url, p.markdown || p.url);
+  });
+  var files = document.getElementById("files");
+  data.files.forEach(function (f) { item(files, f, f); });
+  if (data.report) {
+    document.getElementById("report").textContent = JSON.stringify(data.report, null, 2);
+  } else {
+    document.getElementById("report-wrap").style.display = "none";
+  }
+  data.sections.forEach(function (s) {
+    s.haystack = (s.heading_path + " " + s.heading + " " + s.text + " " + s.url).toLowerCase();
+  });
+  var results = document.getElementById("results");
+  var count = document.getElementById("count");
+  function render(query) {
+    var terms = query.toLowerCase().split(/\s+/).filter(Boolean);
+    var hits = data.sections.filter(function (s) {
+      return terms.every(function (t) { return s.haystack.indexOf(t) !== -1; });
+    });
+    results.textContent = "";
+    count.textContent = hits.length + " of " + data.sections.length + " sections";
+    hits.slice(0, 500).forEach(function (s) {
+      var div = el("div");
+      div.className = "hit";
+      var title = el("a", s.heading || "(untitled)", markdownFor[s.url] || s.source_url);
+      title.title = s.source_url;
+      div.appendChild(title);
+      var path = el("div", s.heading_path + " — " + s.url);
+      path.className = "path";
+      div.appendChild(path);
+      if (s.text) {
+        var text = el("div", s.text.length > 240 ? s.text.slice(0, 240) + "…" : s.text);
+        text.className = "text";
+        div.appendChild(text);
+      }
+      results.appendChild(div);
+    });
+    if (hits.length > 500) {
+      results.appendChild(el("p", "Showing the first 500 matches.")).className = "muted";
+    }
+  }
+  document.getElementById("q").addEventListener("input", function (e) { render(e.target.value); });
+  render("");
+})();
+</script>
+</body>
+</html>
+`
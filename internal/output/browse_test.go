@@ -0,0 +1,58 @@
+package output
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go_scrap/internal/parse"
+)
+
+func TestWriteBrowseHTML_EmbedsSectionsAndFiles(t *testing.T) {
+	dir := t.TempDir()
+	pageURL := "https://example.com/docs#top"
+	sections := []parse.Section{
+		{HeadingText: "Intro", HeadingLevel: 1, HeadingID: "intro", ContentHTML: "<p>Hello <b>world</b></p><script>evil()</script>"},
+		{HeadingText: "</script><b>x", HeadingLevel: 2, HeadingID: "x"},
+	}
+	if _, err := WriteIndex(dir, pageURL, sections, nil); err != nil {
+		t.Fatalf("WriteIndex: %v", err)
+	}
+
+	path, err := WriteBrowseHTML(dir, "Docs <test>", []BrowsePage{{URL: pageURL, Markdown: "content.md"}}, map[string]int{"sections": 2})
+	if err != nil {
+		t.Fatalf("WriteBrowseHTML: %v", err)
+	}
+	if path != filepath.Join(dir, "index.html") {
+		t.Fatalf("unexpected path: %s", path)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := string(raw)
+	if !strings.Contains(page, "<title>Docs &lt;test&gt;</title>") {
+		t.Fatal("title not escaped")
+	}
+	if strings.Count(page, "</script>") != 2 {
+		t.Fatalf("embedded data must not close the script element:\n%s", page)
+	}
+
+	start := strings.Index(page, `id="data">`) + len(`id="data">`)
+	end := strings.Index(page[start:], "</script>")
+	var data browseData
+	if err := json.Unmarshal([]byte(page[start:start+end]), &data); err != nil {
+		t.Fatalf("embedded data: %v", err)
+	}
+	if len(data.Sections) != 2 || data.Sections[0].Text != "Hello world" {
+		t.Fatalf("unexpected sections: %+v", data.Sections)
+	}
+	if data.Pages[0].URL != "https://example.com/docs" || data.Sections[0].URL != data.Pages[0].URL {
+		t.Fatalf("page URL should match index records: %+v", data.Pages)
+	}
+	if len(data.Files) != 1 || data.Files[0] != "index.jsonl" {
+		t.Fatalf("unexpected files: %v", data.Files)
+	}
+}
@@ -0,0 +1,81 @@
+package output
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go_scrap/internal/fsutil"
+)
+
+// ChecksumsFile lists the SHA-256 of every output file in the format of
+// `sha256sum`, so `sha256sum -c checksums.sha256` verifies a copy.
+const ChecksumsFile = "checksums.sha256"
+
+// WriteChecksums hashes every file under outDir and writes ChecksumsFile
+// there. Paths are relative with forward slashes, sorted. The checksums
+// file, its signatures and hidden directories (crawl state) are skipped.
+func WriteChecksums(outDir string) (string, error) {
+	sums := map[string]string{}
+	err := filepath.WalkDir(outDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(outDir, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if rel != "." && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || strings.HasPrefix(d.Name(), ChecksumsFile) {
+			return nil
+		}
+		sum, err := fileSHA256(path)
+		if err != nil {
+			return err
+		}
+		sums[filepath.ToSlash(rel)] = sum
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("hash outputs: %w", err)
+	}
+	paths := make([]string, 0, len(sums))
+	for rel := range sums {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+
+	path := filepath.Join(outDir, ChecksumsFile)
+	var b strings.Builder
+	for _, rel := range paths {
+		fmt.Fprintf(&b, "%s  %s\n", sums[rel], rel)
+	}
+	if err := fsutil.WriteFile(path, []byte(b.String()), 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(fsutil.LongPath(path))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
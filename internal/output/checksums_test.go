@@ -0,0 +1,54 @@
+package output
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteChecksums_HashesOutputsInSha256sumFormat(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"content.md":               "# Title\n",
+		"pages/docs/content.json":  "{}",
+		".crawl-state/pages.jsonl": "state",
+		ChecksumsFile + ".minisig": "sig",
+	}
+	for rel, body := range files {
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(body), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path, err := WriteChecksums(dir)
+	if err != nil {
+		t.Fatalf("WriteChecksums: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := func(s string) string {
+		h := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(h[:])
+	}
+	want := sum("# Title\n") + "  content.md\n" + sum("{}") + "  pages/docs/content.json\n"
+	if string(data) != want {
+		t.Fatalf("unexpected checksums:\n%s\nwant:\n%s", data, want)
+	}
+
+	// A second run does not hash the previous checksums file.
+	if _, err := WriteChecksums(dir); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := os.ReadFile(path); strings.Contains(string(again), ChecksumsFile) {
+		t.Fatalf("checksums file lists itself:\n%s", again)
+	}
+}
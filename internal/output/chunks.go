@@ -0,0 +1,268 @@
+package output
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"go_scrap/internal/parse"
+	"go_scrap/internal/tokenize"
+)
+
+// ChunkOptions sizes the chunks of chunks.jsonl, in tokens counted by
+// Tokenizer (nil = tokenize.Approx).
+type ChunkOptions struct {
+	Tokens    int
+	Overlap   int
+	Tokenizer tokenize.Tokenizer
+}
+
+// ChunkRecord is one token-limited window of section Markdown.
+type ChunkRecord struct {
+	ID            string `json:"id"`
+	SectionID     string `json:"section_id"`
+	URL           string `json:"url"`
+	SourceURL     string `json:"source_url"`
+	HeadingPath   string `json:"heading_path"`
+	ChunkIndex    int    `json:"chunk_index"`
+	ChunkCount    int    `json:"chunk_count"`
+	Content       string `json:"content"`
+	ContentHash   string `json:"content_hash"`
+	TokenEstimate int    `json:"token_estimate"`
+}
+
+// WriteChunks writes outDir/chunks.jsonl: each section's Markdown cut into
+// windows of at most opts.Tokens tokens, each repeating about
+// opts.Overlap tokens from the end of the one before. Windows end at a
+// paragraph, line or word boundary when one falls in their second half.
+// markdowns[i] is the rendered Markdown of sections[i], and SectionID
+// matches the id in index.jsonl.
+func WriteChunks(outDir, pageURL string, sections []parse.Section, markdowns []string, opts ChunkOptions) (string, error) {
+	c, err := NewChunksWriter(outDir, pageURL, sections, opts)
+	if err != nil {
+		return "", err
+	}
+	for i := range sections {
+		if i >= len(markdowns) {
+			break
+		}
+		if err := c.Add(i, markdowns[i]); err != nil {
+			c.Abort()
+			return "", err
+		}
+	}
+	return c.Close()
+}
+
+// ChunksWriter writes chunks.jsonl a section at a time, as each section's
+// Markdown is rendered.
+type ChunksWriter struct {
+	file     *jsonlFile
+	pageURL  string
+	sections []parse.Section
+	idents   []sectionIdentity
+	opts     ChunkOptions
+}
+
+// NewChunksWriter starts outDir/chunks.jsonl for the sections of pageURL.
+func NewChunksWriter(outDir, pageURL string, sections []parse.Section, opts ChunkOptions) (*ChunksWriter, error) {
+	file, err := createJSONL(outDir, "chunks.jsonl")
+	if err != nil {
+		return nil, err
+	}
+	pageURL = indexPageURL(pageURL)
+	return &ChunksWriter{file: file, pageURL: pageURL, sections: sections, idents: sectionIdentities(pageURL, sections), opts: opts}, nil
+}
+
+// Add writes the windows of sections[i], rendered as markdown.
+func (c *ChunksWriter) Add(i int, markdown string) error {
+	chunks := SplitTokenWindows(markdown, c.opts)
+	for n, chunk := range chunks {
+		sum := sha256.Sum256([]byte(chunk))
+		rec := ChunkRecord{
+			ID:            shortHash(c.idents[i].ID + "|window|" + strconv.Itoa(n)),
+			SectionID:     c.idents[i].ID,
+			URL:           c.pageURL,
+			SourceURL:     sectionSourceURL(c.pageURL, c.sections[i].HeadingID),
+			HeadingPath:   c.idents[i].HeadingPath,
+			ChunkIndex:    n,
+			ChunkCount:    len(chunks),
+			Content:       chunk,
+			ContentHash:   hex.EncodeToString(sum[:]),
+			TokenEstimate: tokenize.Or(c.opts.Tokenizer).Count(chunk),
+		}
+		line, err := json.Marshal(rec)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to marshal chunk record %q: %v\n", rec.HeadingPath, err)
+			continue
+		}
+		if err := c.file.writeLine(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close finishes chunks.jsonl and returns its path.
+func (c *ChunksWriter) Close() (string, error) {
+	return c.file.close()
+}
+
+// Abort stops chunks.jsonl and removes it.
+func (c *ChunksWriter) Abort() {
+	c.file.abort()
+}
+
+// MergeChunks concatenates per-page chunk files into outDir/chunks.jsonl in
+// the given order, skipping missing files.
+func MergeChunks(outDir string, chunkPaths []string) (string, error) {
+	return mergeJSONL(outDir, "chunks.jsonl", chunkPaths)
+}
+
+// SplitTokenWindows cuts md into windows of at most opts.Tokens tokens,
+// overlapping by about opts.Overlap. A non-positive opts.Tokens returns md as
+// one window.
+func SplitTokenWindows(md string, opts ChunkOptions) []string {
+	md = strings.TrimSpace(md)
+	if md == "" {
+		return nil
+	}
+	if opts.Tokens <= 0 {
+		return []string{md}
+	}
+	tok := tokenize.Or(opts.Tokenizer)
+
+	var windows []string
+	start := 0
+	for start < len(md) {
+		end := fitTokens(md, start, opts.Tokens, tok)
+		if end < len(md) {
+			end = windowEnd(md, start, end)
+		}
+		windows = append(windows, strings.TrimSpace(md[start:end]))
+		if end >= len(md) {
+			break
+		}
+		next := end
+		if opts.Overlap > 0 {
+			next = overlapStart(md, start, end, opts.Overlap, tok)
+		}
+		start = skipSpace(md, next)
+	}
+	return windows
+}
+
+// fitTokens returns the end of the longest md[start:end] of at most limit
+// tokens, or of its first character when even that is over. It starts from
+// 4 characters a token and searches from there.
+func fitTokens(md string, start, limit int, tok tokenize.Tokenizer) int {
+	lo := advanceRunes(md, start, 1)
+	span := limit * 4
+	hi := advanceRunes(md, start, span)
+	for tok.Count(md[start:hi]) <= limit {
+		if hi == len(md) {
+			return hi
+		}
+		lo = hi
+		span *= 2
+		hi = advanceRunes(md, start, span)
+	}
+	// md[start:lo] fits (or is one character), md[start:hi] does not.
+	for {
+		mid := runeStart(md, lo+(hi-lo)/2)
+		if mid <= lo {
+			return lo
+		}
+		if tok.Count(md[start:mid]) <= limit {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+}
+
+// windowEnd moves a window's end back to the last paragraph, line or word
+// break in the second half of md[start:end], or keeps end if there is none.
+func windowEnd(md string, start, end int) int {
+	half := start + (end-start)/2
+	for _, sep := range []string{"\n\n", "\n", " "} {
+		if i := strings.LastIndex(md[start:end], sep); i >= 0 && start+i > half {
+			return start + i
+		}
+	}
+	return end
+}
+
+// overlapStart returns where the window after md[start:end] begins: about
+// overlap tokens before end, moved forward to the start of a word, and always
+// after start so every window makes progress.
+func overlapStart(md string, start, end, overlap int, tok tokenize.Tokenizer) int {
+	next := tailTokens(md, start, end, overlap, tok)
+	if next <= start {
+		return end
+	}
+	if prev, _ := utf8.DecodeLastRuneInString(md[:next]); !unicode.IsSpace(prev) {
+		i := strings.IndexFunc(md[next:end], unicode.IsSpace)
+		if i < 0 {
+			return end
+		}
+		next += i
+	}
+	return next
+}
+
+// tailTokens returns the start of the longest md[next:end] of at most limit
+// tokens, with next no earlier than start.
+func tailTokens(md string, start, end, limit int, tok tokenize.Tokenizer) int {
+	if tok.Count(md[start:end]) <= limit {
+		return start
+	}
+	// md[lo:end] is over the limit, md[hi:end] is not.
+	lo, hi := start, end
+	for {
+		mid := runeStart(md, lo+(hi-lo)/2)
+		if mid <= lo {
+			return hi
+		}
+		if tok.Count(md[mid:end]) <= limit {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+}
+
+// runeStart moves i back to the start of the character it falls in.
+func runeStart(s string, i int) int {
+	for i > 0 && i < len(s) && !utf8.RuneStart(s[i]) {
+		i--
+	}
+	return i
+}
+
+// advanceRunes returns the byte offset n runes after from, or len(s).
+func advanceRunes(s string, from, n int) int {
+	i := from
+	for ; n > 0 && i < len(s); n-- {
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+	}
+	return i
+}
+
+func skipSpace(s string, i int) int {
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		i += size
+	}
+	return i
+}
@@ -0,0 +1,87 @@
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"go_scrap/internal/parse"
+)
+
+func TestSplitTokenWindows_LimitsAndOverlaps(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 60; i++ {
+		b.WriteString("word ")
+		if i%10 == 9 {
+			b.WriteString("\n\n")
+		}
+	}
+	md := "## Heading\n\n" + b.String()
+	windows := SplitTokenWindows(md, ChunkOptions{Tokens: 20, Overlap: 5})
+	if len(windows) < 3 {
+		t.Fatalf("expected several windows, got %q", windows)
+	}
+	for i, w := range windows {
+		if tokens := (ChunkLimits{}).sizeOf(w).tokens; tokens > 20 {
+			t.Fatalf("window %d has %d tokens: %q", i, tokens, w)
+		}
+		if strings.HasPrefix(w, "ord") || strings.HasSuffix(w, "wor") {
+			t.Fatalf("window %d cuts a word: %q", i, w)
+		}
+		if i > 0 && !strings.HasPrefix(w, "word") {
+			t.Fatalf("window %d should start with words repeated from window %d: %q", i, i-1, w)
+		}
+	}
+	if !strings.HasPrefix(windows[0], "## Heading") {
+		t.Fatalf("expected the first window to keep the heading, got %q", windows[0])
+	}
+
+	if got := SplitTokenWindows("short text", ChunkOptions{Tokens: 20, Overlap: 5}); len(got) != 1 || got[0] != "short text" {
+		t.Fatalf("expected one window, got %q", got)
+	}
+	if got := SplitTokenWindows(strings.Repeat("x", 100), ChunkOptions{Tokens: 10}); len(got) != 3 {
+		t.Fatalf("expected an unbroken word to be cut into 3 windows, got %q", got)
+	}
+}
+
+func TestWriteChunks_RecordsCarryMetadata(t *testing.T) {
+	dir := t.TempDir()
+	sections := []parse.Section{
+		{HeadingText: "Guide", HeadingLevel: 1, HeadingID: "guide"},
+		{HeadingText: "Install", HeadingLevel: 2, HeadingID: "install"},
+	}
+	markdowns := []string{"# Guide\n\nShort.", "## Install\n\n" + strings.Repeat("step ", 40)}
+	path, err := WriteChunks(dir, "https://example.com/docs#top", sections, markdowns, ChunkOptions{Tokens: 16, Overlap: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var recs []ChunkRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec ChunkRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatal(err)
+		}
+		recs = append(recs, rec)
+	}
+	if len(recs) < 3 || recs[0].ChunkIndex != 0 || recs[0].ChunkCount != 1 {
+		t.Fatalf("unexpected records %+v", recs)
+	}
+	last := recs[len(recs)-1]
+	if last.URL != "https://example.com/docs" || last.SourceURL != "https://example.com/docs#install" || last.HeadingPath != "Guide > Install" {
+		t.Fatalf("unexpected metadata %+v", last)
+	}
+	if last.ChunkIndex != last.ChunkCount-1 || last.SectionID != recs[1].SectionID {
+		t.Fatalf("expected the install windows to share a section and count up, got %+v", recs)
+	}
+	if strings.Contains(recs[1].Content, "<") || !strings.HasPrefix(recs[1].Content, "## Install") {
+		t.Fatalf("expected Markdown content, got %q", recs[1].Content)
+	}
+}
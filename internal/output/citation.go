@@ -0,0 +1,68 @@
+package output
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// citationFields are the placeholders a citation template may use.
+var citationFields = []string{"url", "section_url", "heading", "date"}
+
+var placeholderRe = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// Citation describes what a citation footer cites.
+type Citation struct {
+	// URL is the page URL; SectionID, when set, is appended as its fragment
+	// for {section_url}.
+	URL       string
+	SectionID string
+	Heading   string
+	FetchedAt time.Time
+}
+
+// ValidateCitationTemplate reports placeholders that CitationFooter would
+// leave unexpanded.
+func ValidateCitationTemplate(template string) error {
+	for _, m := range placeholderRe.FindAllStringSubmatch(template, -1) {
+		if !containsString(citationFields, m[1]) {
+			return fmt.Errorf("unknown citation placeholder {%s} (available: {%s})", m[1], strings.Join(citationFields, "}, {"))
+		}
+	}
+	return nil
+}
+
+// CitationFooter expands template for c: {url}, {section_url} (the URL with
+// the section's fragment), {heading} and {date} (the fetch date,
+// YYYY-MM-DD in UTC).
+func CitationFooter(template string, c Citation) string {
+	sectionURL := c.URL
+	if c.SectionID != "" {
+		sectionURL = strings.SplitN(c.URL, "#", 2)[0] + "#" + c.SectionID
+	}
+	fetchedAt := c.FetchedAt
+	if fetchedAt.IsZero() {
+		fetchedAt = time.Now()
+	}
+	return strings.NewReplacer(
+		"{url}", c.URL,
+		"{section_url}", sectionURL,
+		"{heading}", c.Heading,
+		"{date}", fetchedAt.UTC().Format("2006-01-02"),
+	).Replace(template)
+}
+
+// AppendCitation adds footer to md as its last paragraph.
+func AppendCitation(md, footer string) string {
+	return strings.TrimRight(md, "\n") + "\n\n" + footer + "\n"
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+package output
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"go_scrap/internal/parse"
+	"go_scrap/internal/report"
+)
+
+// CorpusRecord is one chunk of section Markdown, ready for embedding.
+type CorpusRecord struct {
+	ID            string   `json:"id"`
+	SectionID     string   `json:"section_id"`
+	URL           string   `json:"url"`
+	SourceURL     string   `json:"source_url"`
+	HeadingPath   string   `json:"heading_path"`
+	Date          string   `json:"date,omitempty"`
+	Authors       []string `json:"authors,omitempty"`
+	Chunk         int      `json:"chunk"`
+	Chunks        int      `json:"chunks"`
+	Markdown      string   `json:"markdown"`
+	ContentHash   string   `json:"content_hash"`
+	Chars         int      `json:"chars"`
+	TokenEstimate int      `json:"token_estimate"`
+}
+
+// WriteCorpus writes outDir/corpus.jsonl with one record per Markdown chunk.
+// markdowns[i] is the rendered Markdown of sections[i]; sections larger than
+// limits are split on subheadings and paragraphs like the Markdown outputs.
+// SectionID matches the id in index.jsonl.
+func WriteCorpus(outDir, pageURL string, sections []parse.Section, markdowns []string, limits ChunkLimits) (string, error) {
+	c, err := NewCorpusWriter(outDir, pageURL, sections, limits)
+	if err != nil {
+		return "", err
+	}
+	for i := range sections {
+		if i >= len(markdowns) {
+			break
+		}
+		if err := c.Add(i, markdowns[i]); err != nil {
+			c.Abort()
+			return "", err
+		}
+	}
+	return c.Close()
+}
+
+// CorpusWriter writes corpus.jsonl a section at a time, as each section's
+// Markdown is rendered.
+type CorpusWriter struct {
+	file     *jsonlFile
+	pageURL  string
+	sections []parse.Section
+	idents   []sectionIdentity
+	limits   ChunkLimits
+}
+
+// NewCorpusWriter starts outDir/corpus.jsonl for the sections of pageURL.
+func NewCorpusWriter(outDir, pageURL string, sections []parse.Section, limits ChunkLimits) (*CorpusWriter, error) {
+	file, err := createJSONL(outDir, "corpus.jsonl")
+	if err != nil {
+		return nil, err
+	}
+	pageURL = indexPageURL(pageURL)
+	return &CorpusWriter{file: file, pageURL: pageURL, sections: sections, idents: sectionIdentities(pageURL, sections), limits: limits}, nil
+}
+
+// Add writes the records of sections[i], rendered as markdown.
+func (c *CorpusWriter) Add(i int, markdown string) error {
+	sec := c.sections[i]
+	chunks := splitMarkdownByHeadings(markdown, c.limits)
+	for n, chunk := range chunks {
+		chunk = strings.TrimSpace(chunk)
+		sum := sha256.Sum256([]byte(chunk))
+		size := c.limits.sizeOf(chunk)
+		rec := CorpusRecord{
+			ID:            shortHash(c.idents[i].ID + "|" + strconv.Itoa(n)),
+			SectionID:     c.idents[i].ID,
+			URL:           c.pageURL,
+			SourceURL:     sectionSourceURL(c.pageURL, sec.HeadingID),
+			HeadingPath:   c.idents[i].HeadingPath,
+			Date:          sec.Date,
+			Authors:       sec.Authors,
+			Chunk:         n + 1,
+			Chunks:        len(chunks),
+			Markdown:      chunk,
+			ContentHash:   hex.EncodeToString(sum[:]),
+			Chars:         size.chars,
+			TokenEstimate: size.tokens,
+		}
+		line, err := json.Marshal(rec)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to marshal corpus record %q: %v\n", rec.HeadingPath, err)
+			continue
+		}
+		if err := c.file.writeLine(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close finishes corpus.jsonl and returns its path.
+func (c *CorpusWriter) Close() (string, error) {
+	return c.file.close()
+}
+
+// Abort stops corpus.jsonl and removes it.
+func (c *CorpusWriter) Abort() {
+	c.file.abort()
+}
+
+// MergeCorpora concatenates per-page corpus files into outDir/corpus.jsonl in
+// the given order, skipping missing files.
+func MergeCorpora(outDir string, corpusPaths []string) (string, error) {
+	return mergeJSONL(outDir, "corpus.jsonl", corpusPaths)
+}
+
+// MeasureChunks splits each section's Markdown the same way WriteCorpus does
+// and returns the size of every chunk for report.AnalyzeChunks.
+func MeasureChunks(sections []parse.Section, markdowns []string, limits ChunkLimits) []report.ChunkSize {
+	m := NewChunkMeter(sections, limits)
+	for i := range sections {
+		if i >= len(markdowns) {
+			break
+		}
+		m.Add(i, markdowns[i])
+	}
+	return m.Sizes()
+}
+
+// ChunkMeter is MeasureChunks a section at a time.
+type ChunkMeter struct {
+	idents []sectionIdentity
+	limits ChunkLimits
+	sizes  []report.ChunkSize
+}
+
+// NewChunkMeter measures the chunks of sections.
+func NewChunkMeter(sections []parse.Section, limits ChunkLimits) *ChunkMeter {
+	return &ChunkMeter{idents: sectionIdentities("", sections), limits: limits, sizes: []report.ChunkSize{}}
+}
+
+// Add measures the chunks of sections[i], rendered as markdown.
+func (m *ChunkMeter) Add(i int, markdown string) {
+	for n, chunk := range splitMarkdownByHeadings(markdown, m.limits) {
+		size := m.limits.sizeOf(strings.TrimSpace(chunk))
+		m.sizes = append(m.sizes, report.ChunkSize{
+			HeadingPath: m.idents[i].HeadingPath,
+			Chunk:       n + 1,
+			Bytes:       size.bytes,
+			Chars:       size.chars,
+			Tokens:      size.tokens,
+		})
+	}
+}
+
+// Sizes returns the chunks measured so far.
+func (m *ChunkMeter) Sizes() []report.ChunkSize {
+	return m.sizes
+}
@@ -0,0 +1,63 @@
+package output
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go_scrap/internal/parse"
+)
+
+func TestWriteCorpus_ChunksSectionsAndLinksToIndex(t *testing.T) {
+	dir := t.TempDir()
+	pageURL := "https://example.com/docs/page"
+	sections := []parse.Section{
+		{HeadingText: "Intro", HeadingLevel: 1, HeadingID: "intro"},
+		{HeadingText: "Setup", HeadingLevel: 2, HeadingID: "setup"},
+	}
+	long := "## Setup\n\n" + strings.Repeat("alpha beta gamma. ", 10) + "\n\n" + strings.Repeat("delta epsilon. ", 10)
+	markdowns := []string{"# Intro\n\nShort.\n", long}
+
+	path, err := WriteCorpus(dir, pageURL, sections, markdowns, ChunkLimits{MaxChars: 200})
+	if err != nil {
+		t.Fatalf("WriteCorpus: %v", err)
+	}
+	if path != filepath.Join(dir, "corpus.jsonl") {
+		t.Fatalf("unexpected path: %s", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read corpus: %v", err)
+	}
+	var recs []CorpusRecord
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var rec CorpusRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		recs = append(recs, rec)
+	}
+	if len(recs) != 3 {
+		t.Fatalf("expected 3 chunks, got %d: %+v", len(recs), recs)
+	}
+	if recs[1].HeadingPath != "Intro > Setup" || recs[1].Chunk != 1 || recs[1].Chunks != 2 || recs[2].Chunk != 2 {
+		t.Fatalf("unexpected chunk metadata: %+v", recs[1:])
+	}
+	if recs[1].SectionID != recs[2].SectionID || recs[1].ID == recs[2].ID {
+		t.Fatalf("chunks should share section id but not chunk id: %+v", recs[1:])
+	}
+	if len(recs[0].ContentHash) != 64 || recs[0].SourceURL != pageURL+"#intro" {
+		t.Fatalf("unexpected record: %+v", recs[0])
+	}
+
+	indexPath, err := WriteIndex(dir, pageURL, sections, nil)
+	if err != nil {
+		t.Fatalf("WriteIndex: %v", err)
+	}
+	index := readIndexRecords(t, indexPath)
+	if index[1].ID != recs[1].SectionID {
+		t.Fatalf("section id %q does not match index id %q", recs[1].SectionID, index[1].ID)
+	}
+}
@@ -5,57 +5,238 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 
 	"go_scrap/internal/crawler"
+	"go_scrap/internal/fsutil"
 )
 
+const crawlIndexShardDir = "crawl-index"
+
 type PageSectionCount struct {
 	URL      string
 	Sections int
+	// SkipReason marks a fetched page that was not written, SkipCategory
+	// groups it for the index's skip counts.
+	SkipReason   string
+	SkipCategory crawler.SkipCategory
+	// Error marks a fetched page whose processing failed (panic, timeout or
+	// write error); it is recorded with status "error".
+	Error string
+	// Classification and ClassificationReason come from page classification
+	// (soft-404, login-wall, js-required) and are recorded for written and
+	// skipped pages alike.
+	Classification       string
+	ClassificationReason string
+	// Published and Modified are recorded for written and skipped pages.
+	Published string
+	Modified  string
+	// Authors are the page's author names, for written and skipped pages.
+	Authors []string
+	// Title is recorded for written and skipped pages, OutputDir (relative
+	// to the crawl output directory) for written ones.
+	Title     string
+	OutputDir string
+	// BrokenAnchors and EmptySections count the issues of a written page's
+	// completeness report.
+	BrokenAnchors int
+	EmptySections int
 }
 
 func BuildCrawlIndex(results map[string]*crawler.Result, stats crawler.Stats, baseURL string, sections []PageSectionCount) crawler.CrawlIndex {
 	counts := map[string]int{}
+	skipped := map[string]PageSectionCount{}
+	failed := map[string]string{}
+	classified := map[string]PageSectionCount{}
+	dated := map[string]PageSectionCount{}
+	named := map[string]PageSectionCount{}
+	checked := map[string]PageSectionCount{}
 	for _, s := range sections {
 		if s.URL == "" {
 			continue
 		}
+		if s.Title != "" || s.OutputDir != "" {
+			named[s.URL] = s
+		}
+		if s.Classification != "" {
+			classified[s.URL] = s
+		}
+		if s.Published != "" || s.Modified != "" || len(s.Authors) > 0 {
+			dated[s.URL] = s
+		}
+		if s.BrokenAnchors > 0 || s.EmptySections > 0 {
+			checked[s.URL] = s
+		}
+		if s.Error != "" {
+			failed[s.URL] = s.Error
+			continue
+		}
+		if s.SkipReason != "" {
+			skipped[s.URL] = s
+			continue
+		}
 		counts[s.URL] = s.Sections
 	}
-	return crawler.BuildIndex(results, stats, baseURL, counts)
+	index := crawler.BuildIndex(results, stats, baseURL, counts)
+	for i := range index.Pages {
+		if s, ok := skipped[index.Pages[i].URL]; ok && index.Pages[i].Status == "success" {
+			category := s.SkipCategory
+			if category == "" {
+				category = crawler.SkipFiltered
+			}
+			index.Pages[i].Status = "skipped"
+			index.Pages[i].SkipReason = s.SkipReason
+			index.Pages[i].SkipCategory = category
+			index.Skipped = crawler.AddSkipCounts(index.Skipped, map[crawler.SkipCategory]int{category: 1})
+		}
+		if msg, ok := failed[index.Pages[i].URL]; ok && index.Pages[i].Status == "success" {
+			index.Pages[i].Status = "error"
+			index.Pages[i].Error = msg
+		}
+		if c, ok := classified[index.Pages[i].URL]; ok && index.Pages[i].Status != "error" {
+			index.Pages[i].Classification = c.Classification
+			index.Pages[i].ClassificationReason = c.ClassificationReason
+		}
+		if d, ok := dated[index.Pages[i].URL]; ok && index.Pages[i].Status != "error" {
+			index.Pages[i].Published = d.Published
+			index.Pages[i].Modified = d.Modified
+			index.Pages[i].Authors = d.Authors
+		}
+		if n, ok := named[index.Pages[i].URL]; ok && index.Pages[i].Status != "error" {
+			index.Pages[i].Title = n.Title
+			if index.Pages[i].Status == "success" {
+				index.Pages[i].OutputDir = n.OutputDir
+			}
+		}
+		if c, ok := checked[index.Pages[i].URL]; ok && index.Pages[i].Status == "success" {
+			index.Pages[i].BrokenAnchors = c.BrokenAnchors
+			index.Pages[i].EmptySections = c.EmptySections
+		}
+	}
+	return index
 }
 
-func WriteCrawlIndexFromPages(outputDir string, results map[string]*crawler.Result, stats crawler.Stats, baseURL string, sections []PageSectionCount, silent bool) error {
+// MergeCrawlIndexes combines the crawl indexes of workers that shared one
+// crawl queue. A URL crawled by more than one worker (after a lease expired)
+// keeps its successful entry; counts are recomputed from the merged pages.
+func MergeCrawlIndexes(baseURL string, indexes []crawler.CrawlIndex) crawler.CrawlIndex {
+	merged := crawler.CrawlIndex{BaseURL: baseURL, Pages: []crawler.PageEntry{}}
+	byURL := map[string]crawler.PageEntry{}
+	for _, index := range indexes {
+		if !index.StartedAt.IsZero() && (merged.StartedAt.IsZero() || index.StartedAt.Before(merged.StartedAt)) {
+			merged.StartedAt = index.StartedAt
+		}
+		if index.CompletedAt.After(merged.CompletedAt) {
+			merged.CompletedAt = index.CompletedAt
+		}
+		merged.Errors = append(merged.Errors, index.Errors...)
+		merged.ThrottleEvents = append(merged.ThrottleEvents, index.ThrottleEvents...)
+		merged.Warnings = append(merged.Warnings, index.Warnings...)
+		merged.Skipped = crawler.AddSkipCounts(merged.Skipped, index.Skipped)
+		for _, page := range index.Pages {
+			if prev, ok := byURL[page.URL]; ok && prev.Status != "error" {
+				continue
+			}
+			byURL[page.URL] = page
+		}
+	}
+	for _, page := range byURL {
+		switch page.Status {
+		case "error":
+			merged.PagesFailed++
+		default:
+			merged.PagesCrawled++
+			merged.TotalSections += page.SectionCount
+		}
+		merged.Pages = append(merged.Pages, page)
+	}
+	sort.Slice(merged.Pages, func(i, j int) bool { return merged.Pages[i].URL < merged.Pages[j].URL })
+	return merged
+}
+
+func WriteCrawlIndexFromPages(outputDir string, results map[string]*crawler.Result, stats crawler.Stats, baseURL string, sections []PageSectionCount, shardSize int, silent bool) error {
 	index := BuildCrawlIndex(results, stats, baseURL, sections)
-	return WriteCrawlIndex(outputDir, index, silent)
+	return WriteShardedCrawlIndex(outputDir, index, shardSize, silent)
 }
 
 func WriteCrawlIndex(outputDir string, index crawler.CrawlIndex, silent bool) error {
+	return WriteShardedCrawlIndex(outputDir, index, 0, silent)
+}
+
+// WriteShardedCrawlIndex writes crawl-index.json. When shardSize > 0 and the
+// index has more pages than that, pages are split into
+// crawl-index/index-NNNN.json files and crawl-index.json becomes a manifest.
+func WriteShardedCrawlIndex(outputDir string, index crawler.CrawlIndex, shardSize int, silent bool) error {
 	if outputDir == "" {
 		outputDir = "artifacts"
 	}
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
+	if err := fsutil.MkdirAll(outputDir, 0755); err != nil {
 		return err
 	}
 
+	shardDir := filepath.Join(outputDir, crawlIndexShardDir)
+	if err := os.RemoveAll(shardDir); err != nil {
+		return err
+	}
+
+	index.Shards = nil
+	if shardSize > 0 && len(index.Pages) > shardSize {
+		shards, err := writeCrawlIndexShards(shardDir, index.Pages, shardSize)
+		if err != nil {
+			return err
+		}
+		index.Shards = shards
+		index.Pages = []crawler.PageEntry{}
+	}
+
 	indexPath := filepath.Join(outputDir, "crawl-index.json")
 	data, err := json.MarshalIndent(index, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	if err := os.WriteFile(indexPath, data, 0600); err != nil {
+	if err := fsutil.WriteFile(indexPath, data, 0600); err != nil {
 		return err
 	}
 
 	if !silent {
-		fmt.Printf("Wrote crawl index: %s (%d pages, %d total sections)\n",
-			indexPath, index.PagesCrawled, index.TotalSections)
+		if len(index.Shards) > 0 {
+			fmt.Printf("Wrote crawl index: %s (%d pages in %d shards, %d total sections)\n",
+				indexPath, index.PagesCrawled, len(index.Shards), index.TotalSections)
+		} else {
+			fmt.Printf("Wrote crawl index: %s (%d pages, %d total sections)\n",
+				indexPath, index.PagesCrawled, index.TotalSections)
+		}
 	}
 
 	return nil
 }
 
+func writeCrawlIndexShards(shardDir string, pages []crawler.PageEntry, shardSize int) ([]string, error) {
+	if err := fsutil.MkdirAll(shardDir, 0755); err != nil {
+		return nil, err
+	}
+	shards := []string{}
+	for start := 0; start < len(pages); start += shardSize {
+		end := start + shardSize
+		if end > len(pages) {
+			end = len(pages)
+		}
+		name := fmt.Sprintf("index-%04d.json", len(shards)+1)
+		data, err := json.MarshalIndent(crawler.CrawlIndexShard{Pages: pages[start:end]}, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := fsutil.WriteFile(filepath.Join(shardDir, name), data, 0600); err != nil {
+			return nil, err
+		}
+		shards = append(shards, crawlIndexShardDir+"/"+name)
+	}
+	return shards, nil
+}
+
+// ReadCrawlIndex reads crawl-index.json, loading page shards when present so
+// callers always see the full page list.
 func ReadCrawlIndex(outputDir string) (crawler.CrawlIndex, error) {
 	if outputDir == "" {
 		outputDir = "artifacts"
@@ -69,5 +250,16 @@ func ReadCrawlIndex(outputDir string) (crawler.CrawlIndex, error) {
 	if err := json.Unmarshal(data, &index); err != nil {
 		return crawler.CrawlIndex{}, err
 	}
+	for _, shard := range index.Shards {
+		shardData, err := os.ReadFile(filepath.Join(outputDir, filepath.FromSlash(shard)))
+		if err != nil {
+			return crawler.CrawlIndex{}, fmt.Errorf("read crawl index shard %s: %w", shard, err)
+		}
+		var s crawler.CrawlIndexShard
+		if err := json.Unmarshal(shardData, &s); err != nil {
+			return crawler.CrawlIndex{}, fmt.Errorf("parse crawl index shard %s: %w", shard, err)
+		}
+		index.Pages = append(index.Pages, s.Pages...)
+	}
 	return index, nil
 }
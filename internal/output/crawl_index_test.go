@@ -47,6 +47,66 @@ func TestBuildCrawlIndex_UsesSectionCounts(t *testing.T) {
 	}
 }
 
+func TestBuildCrawlIndex_MarksSkippedPages(t *testing.T) {
+	results := map[string]*crawler.Result{
+		"https://example.com/a":     {URL: "https://example.com/a", HTML: "<p>a</p>", FetchedAt: time.Now()},
+		"https://example.com/login": {URL: "https://example.com/login", HTML: "<p>Sign in</p>", FetchedAt: time.Now()},
+	}
+	sections := []output.PageSectionCount{
+		{URL: "https://example.com/a", Sections: 2},
+		{URL: "https://example.com/login", SkipReason: "content too short", SkipCategory: crawler.SkipEmpty},
+	}
+	stats := crawler.Stats{PagesCrawled: 2, Skipped: map[crawler.SkipCategory]int{crawler.SkipOffDomain: 3}}
+
+	index := output.BuildCrawlIndex(results, stats, "https://example.com", sections)
+	if index.TotalSections != 2 {
+		t.Fatalf("expected total sections 2, got %d", index.TotalSections)
+	}
+	login := index.Pages[1]
+	if login.URL != "https://example.com/login" || login.Status != "skipped" || login.SkipReason != "content too short" || login.SkipCategory != crawler.SkipEmpty {
+		t.Fatalf("expected skipped login page, got %#v", login)
+	}
+	if index.Skipped[crawler.SkipEmpty] != 1 || index.Skipped[crawler.SkipOffDomain] != 3 || len(index.Skipped) != 2 {
+		t.Fatalf("expected crawl and page skips counted by category, got %v", index.Skipped)
+	}
+	if index.Pages[0].Status != "success" {
+		t.Fatalf("expected success for /a, got %#v", index.Pages[0])
+	}
+}
+
+func TestBuildCrawlIndex_MarksFailedProcessing(t *testing.T) {
+	results := map[string]*crawler.Result{
+		"https://example.com/deep": {URL: "https://example.com/deep", HTML: "<div></div>", FetchedAt: time.Now()},
+	}
+	sections := []output.PageSectionCount{{URL: "https://example.com/deep", Error: "processing timed out after 2m0s"}}
+
+	index := output.BuildCrawlIndex(results, crawler.Stats{PagesCrawled: 1}, "https://example.com", sections)
+	page := index.Pages[0]
+	if page.Status != "error" || page.Error != "processing timed out after 2m0s" {
+		t.Fatalf("expected failed page, got %#v", page)
+	}
+}
+
+func TestBuildCrawlIndex_RecordsClassification(t *testing.T) {
+	results := map[string]*crawler.Result{
+		"https://example.com/gone":  {URL: "https://example.com/gone", HTML: "<title>Not Found</title>", FetchedAt: time.Now()},
+		"https://example.com/login": {URL: "https://example.com/login", HTML: "<input type=password>", FetchedAt: time.Now()},
+	}
+	sections := []output.PageSectionCount{
+		{URL: "https://example.com/gone", Sections: 1, Classification: "soft-404", ClassificationReason: `title "Not Found"`},
+		{URL: "https://example.com/login", SkipReason: "login-wall: password form", Classification: "login-wall", ClassificationReason: "password form"},
+	}
+
+	index := output.BuildCrawlIndex(results, crawler.Stats{PagesCrawled: 2}, "https://example.com", sections)
+	gone, login := index.Pages[0], index.Pages[1]
+	if gone.Status != "success" || gone.Classification != "soft-404" || gone.ClassificationReason != `title "Not Found"` {
+		t.Fatalf("expected kept soft-404 page, got %#v", gone)
+	}
+	if login.Status != "skipped" || login.Classification != "login-wall" {
+		t.Fatalf("expected skipped login-wall page, got %#v", login)
+	}
+}
+
 func TestReadCrawlIndex(t *testing.T) {
 	dir := t.TempDir()
 	index := crawler.CrawlIndex{
@@ -79,3 +139,71 @@ func TestReadCrawlIndex(t *testing.T) {
 		t.Fatalf("expected content hash to round trip, got %#v", readIndex.Pages)
 	}
 }
+
+func TestWriteShardedCrawlIndex_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	pages := []crawler.PageEntry{
+		{URL: "https://example.com/a", Status: "success", ContentHash: "a"},
+		{URL: "https://example.com/b", Status: "success", ContentHash: "b"},
+		{URL: "https://example.com/c", Status: "error", Error: "boom"},
+	}
+	index := crawler.CrawlIndex{BaseURL: "https://example.com", PagesCrawled: 2, PagesFailed: 1, Pages: pages}
+
+	if err := output.WriteShardedCrawlIndex(dir, index, 2, true); err != nil {
+		t.Fatalf("WriteShardedCrawlIndex error: %v", err)
+	}
+	for _, name := range []string{"index-0001.json", "index-0002.json"} {
+		if _, err := os.Stat(filepath.Join(dir, "crawl-index", name)); err != nil {
+			t.Fatalf("missing shard %s: %v", name, err)
+		}
+	}
+
+	readIndex, err := output.ReadCrawlIndex(dir)
+	if err != nil {
+		t.Fatalf("ReadCrawlIndex error: %v", err)
+	}
+	if len(readIndex.Shards) != 2 {
+		t.Fatalf("expected 2 shards, got %v", readIndex.Shards)
+	}
+	if len(readIndex.Pages) != 3 || readIndex.Pages[2].Error != "boom" {
+		t.Fatalf("expected pages to be merged from shards, got %#v", readIndex.Pages)
+	}
+
+	if err := output.WriteCrawlIndex(dir, index, true); err != nil {
+		t.Fatalf("WriteCrawlIndex error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "crawl-index")); !os.IsNotExist(err) {
+		t.Fatalf("expected stale shards to be removed, got %v", err)
+	}
+}
+
+func TestMergeCrawlIndexes_PrefersSuccessAndRecounts(t *testing.T) {
+	early := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	late := early.Add(time.Hour)
+	w1 := crawler.CrawlIndex{
+		StartedAt:   early.Add(time.Minute),
+		CompletedAt: late,
+		Pages: []crawler.PageEntry{
+			{URL: "https://example.com/b", Status: "error", Error: "timeout"},
+			{URL: "https://example.com/a", Status: "success", SectionCount: 2},
+		},
+	}
+	w2 := crawler.CrawlIndex{
+		StartedAt:   early,
+		CompletedAt: early.Add(time.Minute),
+		Pages: []crawler.PageEntry{
+			{URL: "https://example.com/b", Status: "success", SectionCount: 3},
+		},
+	}
+
+	merged := output.MergeCrawlIndexes("https://example.com", []crawler.CrawlIndex{w1, w2})
+	if !merged.StartedAt.Equal(early) || !merged.CompletedAt.Equal(late) {
+		t.Fatalf("unexpected time span %v - %v", merged.StartedAt, merged.CompletedAt)
+	}
+	if merged.PagesCrawled != 2 || merged.PagesFailed != 0 || merged.TotalSections != 5 {
+		t.Fatalf("unexpected counts: %+v", merged)
+	}
+	if len(merged.Pages) != 2 || merged.Pages[0].URL != "https://example.com/a" || merged.Pages[1].Status != "success" {
+		t.Fatalf("unexpected pages: %#v", merged.Pages)
+	}
+}
@@ -1,18 +1,19 @@
 package output
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
-	"fmt"
-	"io"
 	"net/http"
 	"net/url"
-	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"go_scrap/internal/fetch"
+	"go_scrap/internal/footprint"
+
 	"github.com/PuerkitoBio/goquery"
 )
 
@@ -20,44 +21,94 @@ type downloadJob struct {
 	AbsoluteURL string
 	Filename    string
 	LocalPath   string
-	LocalRef    string
 }
 
 func Download(doc *goquery.Document, baseURL, outputDir, userAgent string) error {
-	if doc == nil {
-		return errors.New("nil document")
-	}
+	return DownloadContext(context.Background(), doc, baseURL, outputDir, userAgent)
+}
 
-	assetsDir := filepath.Join(outputDir, "assets")
-	if err := os.MkdirAll(assetsDir, 0755); err != nil {
-		return err
-	}
+// DownloadContext is Download with cancellation: once ctx is done no further
+// assets are fetched and ctx.Err() is returned. Images are stored in
+// outputDir/assets as by an AssetStore, without writing its index.
+func DownloadContext(ctx context.Context, doc *goquery.Document, baseURL, outputDir, userAgent string) error {
+	return OpenAssetStore(filepath.Join(outputDir, "assets")).DownloadContext(ctx, doc, baseURL, outputDir, userAgent)
+}
 
-	downloaded := make(map[string]string)
+// AssetEstimate is what DownloadContext would fetch for a document.
+type AssetEstimate struct {
+	Count int
+	Bytes int64
+	// Unknown counts assets whose server did not report a size.
+	Unknown int
+}
+
+// Add accumulates o into e.
+func (e *AssetEstimate) Add(o AssetEstimate) {
+	e.Count += o.Count
+	e.Bytes += o.Bytes
+	e.Unknown += o.Unknown
+}
 
-	doc.Find("img").Each(func(_ int, s *goquery.Selection) {
+// EstimateAssets finds the images DownloadContext would download and asks
+// for each one's size with a HEAD request. Nothing is written and doc is not
+// modified.
+func EstimateAssets(ctx context.Context, doc *goquery.Document, baseURL, userAgent string) (AssetEstimate, error) {
+	if doc == nil {
+		return AssetEstimate{}, errors.New("nil document")
+	}
+	est := AssetEstimate{}
+	seen := map[string]struct{}{}
+	client := &http.Client{Timeout: 30 * time.Second, Transport: fetch.TransportFrom(ctx)}
+	doc.Find("img").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		if ctx.Err() != nil {
+			return false
+		}
 		src, exists := s.Attr("src")
 		if !exists || src == "" {
-			return
+			return true
 		}
-
-		job, err := buildDownloadJob(src, baseURL, assetsDir)
+		job, err := buildDownloadJob(src, baseURL, "")
 		if err != nil || job == nil {
-			return
+			return true
 		}
-
-		if localName, ok := downloaded[job.AbsoluteURL]; ok {
-			s.SetAttr("src", "assets/"+localName)
-			return
+		if _, ok := seen[job.AbsoluteURL]; ok {
+			return true
 		}
-
-		if err := fetchAsset(job, userAgent); err == nil {
-			downloaded[job.AbsoluteURL] = job.Filename
-			s.SetAttr("src", job.LocalRef)
+		seen[job.AbsoluteURL] = struct{}{}
+		est.Count++
+		if size, ok := headAssetSize(ctx, client, job.AbsoluteURL, userAgent); ok {
+			est.Bytes += size
+		} else {
+			est.Unknown++
 		}
+		return true
 	})
+	return est, ctx.Err()
+}
 
-	return nil
+func headAssetSize(ctx context.Context, client *http.Client, assetURL, userAgent string) (int64, bool) {
+	rec := footprint.From(ctx)
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, assetURL, nil)
+	if err != nil {
+		return 0, false
+	}
+	if userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+	if err := fetch.WaitURL(ctx, assetURL, 0); err != nil {
+		return 0, false
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		rec.Request(assetURL, 0, err)
+		return 0, false
+	}
+	_ = resp.Body.Close()
+	rec.Request(assetURL, 0, nil)
+	if resp.StatusCode != http.StatusOK || resp.ContentLength < 0 {
+		return 0, false
+	}
+	return resp.ContentLength, true
 }
 
 func buildDownloadJob(src, baseURL, assetsDir string) (*downloadJob, error) {
@@ -87,41 +138,5 @@ func buildDownloadJob(src, baseURL, assetsDir string) (*downloadJob, error) {
 		AbsoluteURL: absURL,
 		Filename:    filename,
 		LocalPath:   localPath,
-		LocalRef:    "assets/" + filename,
 	}, nil
 }
-
-func fetchAsset(job *downloadJob, userAgent string) error {
-	if job == nil {
-		return fmt.Errorf("missing download job")
-	}
-	if _, err := os.Stat(job.LocalPath); err == nil {
-		return nil
-	}
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	req, err := http.NewRequest("GET", job.AbsoluteURL, nil)
-	if err != nil {
-		return err
-	}
-	if userAgent != "" {
-		req.Header.Set("User-Agent", userAgent)
-	}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status %d", resp.StatusCode)
-	}
-
-	out, err := os.Create(job.LocalPath)
-	if err != nil {
-		return err
-	}
-	defer out.Close()
-
-	_, err = io.Copy(out, resp.Body)
-	return err
-}
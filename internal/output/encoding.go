@@ -0,0 +1,102 @@
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"strings"
+
+	"go_scrap/internal/fsutil"
+)
+
+const (
+	NewlineLF   = "lf"
+	NewlineCRLF = "crlf"
+)
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// TextEncoding controls line endings and the UTF-8 byte-order mark of the
+// Markdown and JSON files we write. The zero value writes LF without a BOM.
+type TextEncoding struct {
+	CRLF bool
+	BOM  bool
+}
+
+// Encode normalizes line endings to the configured style and prepends the
+// BOM if requested, so files never mix LF and CRLF.
+func (e TextEncoding) Encode(s string) []byte {
+	if e.CRLF {
+		s = strings.ReplaceAll(s, "\r\n", "\n")
+		s = strings.ReplaceAll(s, "\n", "\r\n")
+	}
+	if !e.BOM {
+		return []byte(s)
+	}
+	return append(append([]byte(nil), utf8BOM...), s...)
+}
+
+func (e TextEncoding) writeFile(path string, s string) error {
+	return fsutil.WriteFile(path, e.Encode(s), 0600)
+}
+
+// streamFile writes parts, each followed by sep, through a buffered encoding
+// writer instead of building the whole file in memory.
+func (e TextEncoding) streamFile(path string, parts []string, sep string) error {
+	f, err := fsutil.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriterSize(f, 64*1024)
+	err = StreamMarkdown(e.writer(bw), parts, sep)
+	if ferr := bw.Flush(); err == nil {
+		err = ferr
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// writer wraps a stream so writes get the configured line endings, with the
+// BOM emitted before the first byte. JSON escapes newlines inside strings,
+// so only structural newlines are rewritten.
+func (e TextEncoding) writer(w io.Writer) io.Writer {
+	if !e.CRLF && !e.BOM {
+		return w
+	}
+	return &encodingWriter{w: w, enc: e, needBOM: e.BOM}
+}
+
+type encodingWriter struct {
+	w       io.Writer
+	enc     TextEncoding
+	needBOM bool
+	lastCR  bool
+}
+
+func (ew *encodingWriter) Write(p []byte) (int, error) {
+	if ew.needBOM {
+		if _, err := ew.w.Write(utf8BOM); err != nil {
+			return 0, err
+		}
+		ew.needBOM = false
+	}
+	if !ew.enc.CRLF || len(p) == 0 {
+		return ew.w.Write(p)
+	}
+	var buf bytes.Buffer
+	buf.Grow(len(p) + bytes.Count(p, []byte{'\n'}))
+	for _, b := range p {
+		if b == '\n' && !ew.lastCR {
+			buf.WriteByte('\r')
+		}
+		buf.WriteByte(b)
+		ew.lastCR = b == '\r'
+	}
+	if _, err := ew.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,74 @@
+package output
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go_scrap/internal/parse"
+	"go_scrap/internal/report"
+)
+
+func TestTextEncoding_Encode(t *testing.T) {
+	enc := TextEncoding{CRLF: true, BOM: true}
+	got := enc.Encode("a\nb\r\nc\n")
+	want := append(append([]byte(nil), utf8BOM...), "a\r\nb\r\nc\r\n"...)
+	if !bytes.Equal(got, want) {
+		t.Fatalf("Encode = %q, want %q", got, want)
+	}
+	if got := (TextEncoding{}).Encode("a\r\nb\n"); string(got) != "a\r\nb\n" {
+		t.Fatalf("zero encoding should leave input untouched, got %q", got)
+	}
+}
+
+func TestWriteOutputs_CRLFAndBOM(t *testing.T) {
+	dir := t.TempDir()
+	enc := TextEncoding{CRLF: true, BOM: true}
+
+	mdPath, err := WriteMarkdownPartsEncoded(dir, "content.md", []string{"# A\n\none\n", "# B\n\ntwo\n"}, ChunkLimits{MaxBytes: 12}, enc, nil)
+	if err != nil {
+		t.Fatalf("WriteMarkdownPartsEncoded: %v", err)
+	}
+	doc := &parse.Document{Sections: []parse.Section{{HeadingText: "A", ContentHTML: "<p>x\ny</p>"}}}
+	jsonPath, err := WriteJSON(doc, report.Report{}, WriteOptions{OutputDir: dir, Encoding: enc})
+	if err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+
+	files := []string{mdPath, filepath.Join(dir, "content", "part-001-a.md"), jsonPath}
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read %s: %v", path, err)
+		}
+		if !bytes.HasPrefix(data, utf8BOM) {
+			t.Fatalf("%s: missing BOM", path)
+		}
+		body := string(data[len(utf8BOM):])
+		if strings.Count(body, "\n") != strings.Count(body, "\r\n") || !strings.Contains(body, "\r\n") {
+			t.Fatalf("%s: expected only CRLF line endings, got %q", path, body)
+		}
+	}
+}
+
+func TestWriteMarkdownStream_MatchesEncodedWrite(t *testing.T) {
+	dir := t.TempDir()
+	enc := TextEncoding{CRLF: true, BOM: true}
+	parts := []string{"# A\n\none\r\n", "# B\n\ntwo\n"}
+
+	streamed, err := WriteMarkdownStream(dir, "streamed.md", parts, "\n", enc)
+	if err != nil {
+		t.Fatalf("WriteMarkdownStream: %v", err)
+	}
+	joined, err := WriteMarkdownEncoded(dir, "joined.md", strings.Join(parts, "\n")+"\n", enc)
+	if err != nil {
+		t.Fatalf("WriteMarkdownEncoded: %v", err)
+	}
+	got, _ := os.ReadFile(streamed)
+	want, _ := os.ReadFile(joined)
+	if !bytes.Equal(got, want) {
+		t.Fatalf("streamed %q, want %q", got, want)
+	}
+}
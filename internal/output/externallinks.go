@@ -0,0 +1,187 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go_scrap/internal/fsutil"
+	"go_scrap/internal/parse"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// ExternalRef is a section linking off-site.
+type ExternalRef struct {
+	URL         string `json:"url"`
+	SectionID   string `json:"section_id"`
+	HeadingPath string `json:"heading_path"`
+}
+
+// ExternalLink is an off-site URL, how often sections link to it and which.
+type ExternalLink struct {
+	URL      string        `json:"url"`
+	Count    int           `json:"count"`
+	Sections []ExternalRef `json:"sections"`
+}
+
+// ExternalDomain groups the external links to one host.
+type ExternalDomain struct {
+	Domain string         `json:"domain"`
+	Count  int            `json:"count"`
+	Links  []ExternalLink `json:"links"`
+}
+
+// ExternalInventory is the content of external-links.json.
+type ExternalInventory struct {
+	Total   int              `json:"total"`
+	Domains []ExternalDomain `json:"domains"`
+}
+
+// CollectExternalLinks finds the links in section content to hosts other
+// than pageURL's, treating a leading "www." as the same host. Links are
+// resolved against pageURL without their fragment; SectionID and HeadingPath
+// match index.jsonl.
+func CollectExternalLinks(pageURL string, sections []parse.Section) []ExternalLink {
+	pageURL = indexPageURL(pageURL)
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+	site := siteHost(base.Hostname())
+	idents := sectionIdentities(pageURL, sections)
+	byURL := map[string]*ExternalLink{}
+	for i, sec := range sections {
+		if !strings.Contains(sec.ContentHTML, "href") {
+			continue
+		}
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(sec.ContentHTML))
+		if err != nil {
+			continue
+		}
+		ref := ExternalRef{URL: pageURL, SectionID: idents[i].ID, HeadingPath: idents[i].HeadingPath}
+		doc.Find("a[href]").Each(func(_ int, s *goquery.Selection) {
+			u, err := url.Parse(strings.TrimSpace(s.AttrOr("href", "")))
+			if err != nil {
+				return
+			}
+			abs := base.ResolveReference(u)
+			if (abs.Scheme != "http" && abs.Scheme != "https") || abs.Hostname() == "" || siteHost(abs.Hostname()) == site {
+				return
+			}
+			abs.Fragment = ""
+			abs.RawFragment = ""
+			link, ok := byURL[abs.String()]
+			if !ok {
+				link = &ExternalLink{URL: abs.String()}
+				byURL[abs.String()] = link
+			}
+			link.Count++
+			if n := len(link.Sections); n == 0 || link.Sections[n-1] != ref {
+				link.Sections = append(link.Sections, ref)
+			}
+		})
+	}
+	return sortedExternalLinks(byURL)
+}
+
+func siteHost(host string) string {
+	return strings.TrimPrefix(strings.ToLower(host), "www.")
+}
+
+func sortedExternalLinks(byURL map[string]*ExternalLink) []ExternalLink {
+	links := make([]ExternalLink, 0, len(byURL))
+	for _, l := range byURL {
+		links = append(links, *l)
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].URL < links[j].URL })
+	return links
+}
+
+// BuildExternalInventory groups links by host, most linked host first.
+func BuildExternalInventory(links []ExternalLink) ExternalInventory {
+	inv := ExternalInventory{Domains: []ExternalDomain{}}
+	byDomain := map[string]int{}
+	for _, l := range links {
+		domain := ""
+		if u, err := url.Parse(l.URL); err == nil {
+			domain = strings.ToLower(u.Hostname())
+		}
+		i, ok := byDomain[domain]
+		if !ok {
+			i = len(inv.Domains)
+			byDomain[domain] = i
+			inv.Domains = append(inv.Domains, ExternalDomain{Domain: domain})
+		}
+		inv.Domains[i].Count += l.Count
+		inv.Domains[i].Links = append(inv.Domains[i].Links, l)
+		inv.Total += l.Count
+	}
+	sort.SliceStable(inv.Domains, func(i, j int) bool {
+		if inv.Domains[i].Count != inv.Domains[j].Count {
+			return inv.Domains[i].Count > inv.Domains[j].Count
+		}
+		return inv.Domains[i].Domain < inv.Domains[j].Domain
+	})
+	return inv
+}
+
+// WriteExternalLinks writes outDir/external-links.json.
+func WriteExternalLinks(outDir string, links []ExternalLink) (string, error) {
+	if err := fsutil.MkdirAll(outDir, 0755); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(BuildExternalInventory(links), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(outDir, "external-links.json")
+	if err := fsutil.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// ReadExternalLinks reads the links of an external-links.json.
+func ReadExternalLinks(path string) ([]ExternalLink, error) {
+	data, err := fsutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var inv ExternalInventory
+	if err := json.Unmarshal(data, &inv); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	var links []ExternalLink
+	for _, d := range inv.Domains {
+		links = append(links, d.Links...)
+	}
+	return links, nil
+}
+
+// MergeExternalLinks merges per-page external-links.json files into
+// outDir/external-links.json, listing each URL once with its total count and
+// every section linking to it. Missing files are skipped.
+func MergeExternalLinks(outDir string, paths []string) (string, error) {
+	byURL := map[string]*ExternalLink{}
+	for _, p := range paths {
+		links, err := ReadExternalLinks(p)
+		if err != nil {
+			continue
+		}
+		for _, l := range links {
+			merged, ok := byURL[l.URL]
+			if !ok {
+				l.Sections = append([]ExternalRef(nil), l.Sections...)
+				byURL[l.URL] = &l
+				continue
+			}
+			merged.Count += l.Count
+			merged.Sections = append(merged.Sections, l.Sections...)
+		}
+	}
+	return WriteExternalLinks(outDir, sortedExternalLinks(byURL))
+}
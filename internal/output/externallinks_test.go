@@ -0,0 +1,75 @@
+package output
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go_scrap/internal/parse"
+)
+
+func TestCollectExternalLinks_GroupsOffSiteLinksBySection(t *testing.T) {
+	sections := []parse.Section{
+		{HeadingText: "Guide", HeadingLevel: 1, HeadingID: "guide", ContentHTML: `<p>
+			<a href="https://github.com/acme/tool#readme">repo</a>
+			<a href="https://github.com/acme/tool">repo again</a>
+			<a href="/docs/other">same site</a>
+			<a href="https://www.example.com/blog">www is same site</a>
+			<a href="mailto:a@example.com">mail</a></p>`},
+		{HeadingText: "Install", HeadingLevel: 2, HeadingID: "install", ContentHTML: `<p>
+			<a href="https://github.com/acme/tool">repo</a>
+			<a href="https://pkg.go.dev/acme/tool">docs</a></p>`},
+	}
+	links := CollectExternalLinks("https://example.com/docs#top", sections)
+	if len(links) != 2 {
+		t.Fatalf("expected 2 external links, got %+v", links)
+	}
+	repo := links[0]
+	if repo.URL != "https://github.com/acme/tool" || repo.Count != 3 || len(repo.Sections) != 2 {
+		t.Fatalf("unexpected repo link %+v", repo)
+	}
+	if repo.Sections[1].HeadingPath != "Guide > Install" || repo.Sections[1].URL != "https://example.com/docs" {
+		t.Fatalf("unexpected section ref %+v", repo.Sections[1])
+	}
+
+	inv := BuildExternalInventory(links)
+	if inv.Total != 4 || len(inv.Domains) != 2 || inv.Domains[0].Domain != "github.com" || inv.Domains[0].Count != 3 {
+		t.Fatalf("unexpected inventory %+v", inv)
+	}
+}
+
+func TestMergeExternalLinks_SumsCountsAcrossPages(t *testing.T) {
+	root := t.TempDir()
+	ref := func(page string) []ExternalRef {
+		return []ExternalRef{{URL: page, SectionID: "s", HeadingPath: "H"}}
+	}
+	pathA, err := WriteExternalLinks(filepath.Join(root, "a"), []ExternalLink{
+		{URL: "https://github.com/acme", Count: 2, Sections: ref("https://example.com/a")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pathB, err := WriteExternalLinks(filepath.Join(root, "b"), []ExternalLink{
+		{URL: "https://github.com/acme", Count: 1, Sections: ref("https://example.com/b")},
+		{URL: "https://npmjs.com/acme", Count: 1, Sections: ref("https://example.com/b")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := MergeExternalLinks(root, []string{pathA, pathB, filepath.Join(root, "missing.json")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var inv ExternalInventory
+	if err := json.Unmarshal(data, &inv); err != nil {
+		t.Fatal(err)
+	}
+	if inv.Total != 4 || len(inv.Domains) != 2 || inv.Domains[0].Count != 3 || len(inv.Domains[0].Links[0].Sections) != 2 {
+		t.Fatalf("unexpected merged inventory %+v", inv)
+	}
+}
@@ -0,0 +1,95 @@
+package output
+
+import (
+	"fmt"
+	"strings"
+
+	"go_scrap/internal/parse"
+)
+
+// SectionFields lists the section keys that can be selected for content.json.
+var SectionFields = []string{
+	"heading_text",
+	"heading_html",
+	"heading_level",
+	"heading_id",
+	"content_html",
+	"content_text",
+	"anchor_targets",
+	"date",
+	"authors",
+}
+
+// ValidateSectionFields reports an error for any field not in SectionFields.
+func ValidateSectionFields(fields []string) error {
+	known := map[string]struct{}{}
+	for _, f := range SectionFields {
+		known[f] = struct{}{}
+	}
+	for _, f := range fields {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		if _, ok := known[f]; !ok {
+			return fmt.Errorf("unknown json field %q (available: %s)", f, strings.Join(SectionFields, ", "))
+		}
+	}
+	return nil
+}
+
+// sectionEncoder returns a function mapping a section to its serialized form,
+// keeping only the selected fields when a selection is active.
+func sectionEncoder(fields []string, omitContentText bool) func(parse.Section) any {
+	if len(fields) == 0 && !omitContentText {
+		return func(s parse.Section) any { return s }
+	}
+	selected := resolveSectionFields(fields, omitContentText)
+	return func(s parse.Section) any {
+		m := make(map[string]any, len(selected))
+		for _, f := range selected {
+			m[f] = sectionFieldValue(s, f)
+		}
+		return m
+	}
+}
+
+func resolveSectionFields(fields []string, omitContentText bool) []string {
+	if len(fields) == 0 {
+		fields = SectionFields
+	}
+	out := make([]string, 0, len(fields))
+	for _, f := range fields {
+		f = strings.TrimSpace(f)
+		if f == "" || (omitContentText && f == "content_text") {
+			continue
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+func sectionFieldValue(s parse.Section, field string) any {
+	switch field {
+	case "heading_text":
+		return s.HeadingText
+	case "heading_html":
+		return s.HeadingHTML
+	case "heading_level":
+		return s.HeadingLevel
+	case "heading_id":
+		return s.HeadingID
+	case "content_html":
+		return s.ContentHTML
+	case "content_text":
+		return s.ContentText
+	case "anchor_targets":
+		return s.AnchorTargets
+	case "date":
+		return s.Date
+	case "authors":
+		return s.Authors
+	default:
+		return nil
+	}
+}
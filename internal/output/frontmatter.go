@@ -0,0 +1,149 @@
+package output
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"strings"
+	"time"
+	"unicode"
+
+	"go_scrap/internal/byline"
+	"go_scrap/internal/dates"
+	"go_scrap/internal/parse"
+)
+
+// FrontMatter returns the YAML front matter block for a page's Markdown:
+// its title (the page title, or else its first heading), source URL, fetch time, dates, byline and contentHash, the hash
+// of the Markdown that follows (see HashMarkdown). Values are written as
+// JSON strings, which YAML reads as double-quoted scalars.
+func FrontMatter(pageURL string, doc *parse.Document, fetchedAt time.Time, contentHash string) string {
+	var b strings.Builder
+	b.WriteString("---\n")
+	if doc != nil && doc.Title != "" {
+		writeYAMLScalar(&b, "title", doc.Title)
+	} else if doc != nil && len(doc.Sections) > 0 {
+		writeYAMLScalar(&b, "title", doc.Sections[0].HeadingText)
+	}
+	writeYAMLScalar(&b, "source_url", pageURL)
+	writeYAMLScalar(&b, "fetched_at", formatFetchedAt(fetchedAt))
+	if doc != nil {
+		writeYAMLScalar(&b, "published", dates.Format(doc.Dates.Published))
+		writeYAMLScalar(&b, "modified", dates.Format(doc.Dates.Modified))
+		writeYAMLPeople(&b, "authors", doc.Byline.Authors)
+		writeYAMLPeople(&b, "contributors", doc.Byline.Contributors)
+	}
+	writeYAMLScalar(&b, "content_hash", contentHash)
+	b.WriteString("---\n")
+	return b.String()
+}
+
+// SectionFrontMatters returns the front matter for each section's own
+// Markdown file: its title, source URL with the section's fragment, heading
+// path and ID (as in index.jsonl), the fetch time and the hash of
+// markdowns[i], the section Markdown it precedes.
+func SectionFrontMatters(pageURL string, sections []parse.Section, markdowns []string, fetchedAt time.Time) []string {
+	pageURL = indexPageURL(pageURL)
+	idents := sectionIdentities(pageURL, sections)
+	out := make([]string, len(sections))
+	for i, sec := range sections {
+		var md string
+		if i < len(markdowns) {
+			md = markdowns[i]
+		}
+		var b strings.Builder
+		b.WriteString("---\n")
+		writeYAMLScalar(&b, "title", sec.HeadingText)
+		writeYAMLScalar(&b, "source_url", sectionSourceURL(pageURL, sec.HeadingID))
+		writeYAMLScalar(&b, "heading_path", idents[i].HeadingPath)
+		writeYAMLScalar(&b, "section_id", idents[i].ID)
+		writeYAMLScalar(&b, "fetched_at", formatFetchedAt(fetchedAt))
+		writeYAMLScalar(&b, "date", sec.Date)
+		writeYAMLList(&b, "authors", sec.Authors)
+		writeYAMLScalar(&b, "content_hash", HashMarkdown([]string{md}, ""))
+		b.WriteString("---\n")
+		out[i] = b.String()
+	}
+	return out
+}
+
+// HashMarkdown returns the hex SHA-256 of parts joined by sep with the
+// surrounding whitespace trimmed, as corpus.jsonl hashes a chunk, without
+// building the joined string.
+func HashMarkdown(parts []string, sep string) string {
+	// Skip leading and trailing parts that are only whitespace, then trim
+	// the edges of the first and last that remain.
+	first, last := 0, len(parts)-1
+	for first <= last && strings.TrimSpace(parts[first]) == "" {
+		first++
+	}
+	for last >= first && strings.TrimSpace(parts[last]) == "" {
+		last--
+	}
+	h := sha256.New()
+	for i := first; i <= last; i++ {
+		part := parts[i]
+		if i == first {
+			part = strings.TrimLeftFunc(part, unicode.IsSpace)
+		}
+		if i == last {
+			part = strings.TrimRightFunc(part, unicode.IsSpace)
+		}
+		if i > first {
+			writeHash(h, sep)
+		}
+		writeHash(h, part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func writeHash(h hash.Hash, s string) {
+	_, _ = h.Write([]byte(s))
+}
+
+func formatFetchedAt(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func writeYAMLScalar(b *strings.Builder, key, value string) {
+	if value == "" {
+		return
+	}
+	b.WriteString(key + ": " + yamlString(value) + "\n")
+}
+
+func writeYAMLPeople(b *strings.Builder, key string, people []byline.Person) {
+	if len(people) == 0 {
+		return
+	}
+	b.WriteString(key + ":\n")
+	for _, p := range people {
+		b.WriteString("  - name: " + yamlString(p.Name) + "\n")
+		if p.URL != "" {
+			b.WriteString("    url: " + yamlString(p.URL) + "\n")
+		}
+	}
+}
+
+func writeYAMLList(b *strings.Builder, key string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	b.WriteString(key + ":\n")
+	for _, v := range values {
+		b.WriteString("  - " + yamlString(v) + "\n")
+	}
+}
+
+func yamlString(s string) string {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	// Headings such as "A > B" should stay readable rather than \u003e.
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,23 @@
+package output
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestHashMarkdown_MatchesJoinedTrimmed(t *testing.T) {
+	cases := [][]string{
+		{"# A\n", "body\n"},
+		{"\n", "  # A", "", "body  \n\n", " \n"},
+		{" only "},
+		{},
+	}
+	for _, parts := range cases {
+		sum := sha256.Sum256([]byte(strings.TrimSpace(strings.Join(parts, "\n"))))
+		if got, want := HashMarkdown(parts, "\n"), hex.EncodeToString(sum[:]); got != want {
+			t.Fatalf("HashMarkdown(%q) = %s, want %s", parts, got, want)
+		}
+	}
+}
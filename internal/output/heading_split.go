@@ -0,0 +1,123 @@
+package output
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"go_scrap/internal/fsutil"
+	"go_scrap/internal/parse"
+	"go_scrap/internal/slug"
+)
+
+// HeadingFile is one file of a split by heading level: a heading at or above
+// the level and every section under it.
+type HeadingFile struct {
+	Title string
+	// Lead is the index of the file's first section.
+	Lead int
+	// Markdown holds the sections with their headings shifted so the first
+	// is a level-1 heading.
+	Markdown string
+}
+
+// SplitByHeadingLevel groups sections into one file per heading of the given
+// level or shallower, so level 2 gives a file per h2 subtree and the page
+// title's h1 keeps its introduction in a file of its own. markdowns are the
+// rendered sections, in the order of sections.
+func SplitByHeadingLevel(sections []parse.Section, markdowns []string, level int) []HeadingFile {
+	var files []HeadingFile
+	var parts []string
+	shift := 0
+	flush := func() {
+		if len(files) > 0 && len(parts) > 0 {
+			files[len(files)-1].Markdown = strings.Join(parts, "\n")
+		}
+		parts = nil
+	}
+	for i, sec := range sections {
+		if len(files) == 0 || (sec.HeadingLevel > 0 && sec.HeadingLevel <= level) {
+			flush()
+			files = append(files, HeadingFile{Title: sec.HeadingText, Lead: i})
+			shift = 0
+			if sec.HeadingLevel > 1 {
+				shift = sec.HeadingLevel - 1
+			}
+		}
+		var md string
+		if i < len(markdowns) {
+			md = markdowns[i]
+		}
+		if strings.TrimSpace(md) == "" {
+			continue
+		}
+		parts = append(parts, shiftHeading(md, sec.HeadingLevel, shift))
+	}
+	flush()
+
+	kept := files[:0]
+	for _, f := range files {
+		if strings.TrimSpace(f.Markdown) != "" {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// shiftHeading raises md's leading heading of the given level by shift
+// levels, never above level 1. Markdown that doesn't start with that
+// heading, for example after a hook rewrote it, is returned unchanged.
+func shiftHeading(md string, level, shift int) string {
+	if level <= 0 || shift <= 0 {
+		return md
+	}
+	body := strings.TrimLeft(md, " \t\r\n")
+	marker := strings.Repeat("#", level) + " "
+	if !strings.HasPrefix(body, marker) {
+		return md
+	}
+	return strings.Repeat("#", max(level-shift, 1)) + " " + body[len(marker):]
+}
+
+// WriteHeadingFiles writes each file to outputDir/<name>/NNN-<slug>.md,
+// splitting those over limits as section files are, and replaces filename
+// with an index linking them in order. heading starts the index when set.
+func WriteHeadingFiles(outputDir string, filename string, heading string, files []HeadingFile, limits ChunkLimits, enc TextEncoding, slugs *slug.Strategy) (string, error) {
+	if outputDir == "" {
+		outputDir = "artifacts"
+	}
+	if filename == "" {
+		filename = "content.md"
+	}
+	baseName := strings.TrimSuffix(filename, filepath.Ext(filename))
+	if err := fsutil.MkdirAll(filepath.Join(outputDir, baseName), 0755); err != nil {
+		return "", err
+	}
+
+	var index strings.Builder
+	if heading != "" {
+		index.WriteString(heading + "\n\n")
+	}
+	index.WriteString(fmt.Sprintf("Split into %d files:\n\n", len(files)))
+	for i, f := range files {
+		name := sectionFileName(f.Title, slugs)
+		if name == "" {
+			name = "section"
+		}
+		name = fmt.Sprintf("%03d-%s", i+1, name)
+		if err := writeMarkdownFile(filepath.Join(outputDir, baseName, name), f.Markdown, limits, enc, slugs); err != nil {
+			return "", err
+		}
+		title := strings.TrimSpace(f.Title)
+		if title == "" {
+			title = name
+		}
+		index.WriteString(fmt.Sprintf("- [%s](%s/%s.md)\n", title, baseName, name))
+	}
+
+	mdPath := filepath.Join(outputDir, filename)
+	if err := enc.writeFile(mdPath, index.String()); err != nil {
+		return "", err
+	}
+	return mdPath, nil
+}
@@ -0,0 +1,59 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go_scrap/internal/parse"
+)
+
+func TestSplitByHeadingLevel_OneFilePerSubtree(t *testing.T) {
+	sections := []parse.Section{
+		{HeadingText: "API", HeadingLevel: 1},
+		{HeadingText: "Users", HeadingLevel: 2},
+		{HeadingText: "List users", HeadingLevel: 3},
+		{HeadingText: "Filters", HeadingLevel: 4},
+		{HeadingText: "Orders", HeadingLevel: 2},
+	}
+	markdowns := []string{
+		"# API\n\nIntro.\n",
+		"## Users\n\nUser endpoints.\n",
+		"### List users\n\n```\n### not a heading\n```\n",
+		"#### Filters\n\nBy name.\n",
+		"## Orders\n\nOrder endpoints.\n",
+	}
+	files := SplitByHeadingLevel(sections, markdowns, 2)
+	if len(files) != 3 {
+		t.Fatalf("expected 3 files, got %+v", files)
+	}
+	if files[1].Title != "Users" || files[1].Lead != 1 {
+		t.Fatalf("unexpected second file %+v", files[1])
+	}
+	want := "# Users\n\nUser endpoints.\n\n## List users\n\n```\n### not a heading\n```\n\n### Filters\n\nBy name.\n"
+	if files[1].Markdown != want {
+		t.Fatalf("expected headings raised one level\n%s\ngot\n%s", want, files[1].Markdown)
+	}
+	if !strings.HasPrefix(files[0].Markdown, "# API\n") || !strings.HasPrefix(files[2].Markdown, "# Orders\n") {
+		t.Fatalf("unexpected files %+v", files)
+	}
+
+	dir := t.TempDir()
+	path, err := WriteHeadingFiles(dir, "content.md", "# API", files, ChunkLimits{}, TextEncoding{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantIndex := "# API\n\nSplit into 3 files:\n\n- [API](content/001-api.md)\n- [Users](content/002-users.md)\n- [Orders](content/003-orders.md)\n"
+	if string(index) != wantIndex {
+		t.Fatalf("expected index\n%s\ngot\n%s", wantIndex, index)
+	}
+	users, err := os.ReadFile(filepath.Join(dir, "content", "002-users.md"))
+	if err != nil || string(users) != want {
+		t.Fatalf("expected users file %q, got %q (%v)", want, users, err)
+	}
+}
@@ -1,44 +1,101 @@
 package output
 
 import (
+	"bufio"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
+	"go_scrap/internal/fsutil"
 	"go_scrap/internal/parse"
+	"go_scrap/internal/tokenize"
 )
 
 type IndexRecord struct {
-	ID            string `json:"id"`
-	URL           string `json:"url"`
-	SourceURL     string `json:"source_url"`
-	Heading       string `json:"heading"`
-	HeadingLevel  int    `json:"heading_level"`
-	HeadingPath   string `json:"heading_path"`
-	Content       string `json:"content"`
-	TokenEstimate int    `json:"token_estimate"`
-}
-
-func WriteIndex(outDir, baseURL string, sections []parse.Section) (string, error) {
-	if err := os.MkdirAll(outDir, 0755); err != nil {
+	ID            string   `json:"id"`
+	URL           string   `json:"url"`
+	SourceURL     string   `json:"source_url"`
+	Heading       string   `json:"heading"`
+	HeadingLevel  int      `json:"heading_level"`
+	HeadingPath   string   `json:"heading_path"`
+	Date          string   `json:"date,omitempty"`
+	Authors       []string `json:"authors,omitempty"`
+	Content       string   `json:"content"`
+	TokenEstimate int      `json:"token_estimate"`
+}
+
+// WriteIndex writes one JSON line per section to outDir/index.jsonl. IDs are
+// derived from the page URL (without fragment), the heading path and the
+// heading ID, so they stay stable across runs and unique across crawled pages.
+// TokenEstimate counts the section HTML with tok (nil = tokenize.Approx).
+func WriteIndex(outDir, pageURL string, sections []parse.Section, tok tokenize.Tokenizer) (string, error) {
+	if err := fsutil.MkdirAll(outDir, 0755); err != nil {
 		return "", err
 	}
 	path := filepath.Join(outDir, "index.jsonl")
-	f, err := os.Create(path)
+	f, err := fsutil.Create(path)
 	if err != nil {
 		return "", err
 	}
 	defer f.Close()
+	w := bufio.NewWriter(f)
+
+	pageURL = indexPageURL(pageURL)
+	idents := sectionIdentities(pageURL, sections)
+	tok = tokenize.Or(tok)
+
+	for i, sec := range sections {
+		rec := IndexRecord{
+			ID:            idents[i].ID,
+			URL:           pageURL,
+			SourceURL:     sectionSourceURL(pageURL, sec.HeadingID),
+			Heading:       sec.HeadingText,
+			HeadingLevel:  sec.HeadingLevel,
+			HeadingPath:   idents[i].HeadingPath,
+			Date:          sec.Date,
+			Authors:       sec.Authors,
+			Content:       strings.TrimSpace(sec.ContentHTML), // Storing HTML for now, could be MD
+			TokenEstimate: tok.Count(sec.ContentHTML),
+		}
+
+		line, err := json.Marshal(rec)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to marshal index record %q: %v\n", rec.Heading, err)
+			continue
+		}
+		if _, err := w.Write(line); err != nil {
+			return "", err
+		}
+		if err := w.WriteByte('\n'); err != nil {
+			return "", err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	return path, nil
+}
 
+type sectionIdentity struct {
+	ID          string
+	HeadingPath string
+}
+
+// sectionIdentities computes each section's heading path ("Parent > Child")
+// and stable ID: hash(pageURL + headingPath + headingID), with an occurrence
+// suffix when the same identity repeats on a page.
+func sectionIdentities(pageURL string, sections []parse.Section) []sectionIdentity {
+	idents := make([]sectionIdentity, 0, len(sections))
 	// Track hierarchy: level -> heading text
 	hierarchy := make(map[int]string)
-
+	seen := make(map[string]int)
 	for _, sec := range sections {
-		// Update hierarchy
 		hierarchy[sec.HeadingLevel] = sec.HeadingText
 		// Clear deeper levels
 		for k := range hierarchy {
@@ -47,7 +104,6 @@ func WriteIndex(outDir, baseURL string, sections []parse.Section) (string, error
 			}
 		}
 
-		// Build path string "Parent > Child"
 		var pathParts []string
 		for i := 1; i <= 6; i++ {
 			if val, ok := hierarchy[i]; ok {
@@ -56,33 +112,175 @@ func WriteIndex(outDir, baseURL string, sections []parse.Section) (string, error
 		}
 		headingPath := strings.Join(pathParts, " > ")
 
-		// Stable ID: hash(baseURL + headingPath + headingID)
-		idRaw := baseURL + "|" + headingPath + "|" + sec.HeadingID
-		idHash := sha256.Sum256([]byte(idRaw))
-		stableID := hex.EncodeToString(idHash[:])[:16]
-
-		rec := IndexRecord{
-			ID:            stableID,
-			URL:           baseURL, // In a crawler, this would be the specific page URL
-			SourceURL:     baseURL + "#" + sec.HeadingID,
-			Heading:       sec.HeadingText,
-			HeadingLevel:  sec.HeadingLevel,
-			HeadingPath:   headingPath,
-			Content:       strings.TrimSpace(sec.ContentHTML), // Storing HTML for now, could be MD
-			TokenEstimate: len(sec.ContentHTML) / 4,           // Rough estimate
+		idRaw := pageURL + "|" + headingPath + "|" + sec.HeadingID
+		if n := seen[idRaw]; n > 0 {
+			seen[idRaw]++
+			idRaw += "|" + strconv.Itoa(n)
+		} else {
+			seen[idRaw] = 1
 		}
+		idents = append(idents, sectionIdentity{ID: shortHash(idRaw), HeadingPath: headingPath})
+	}
+	return idents
+}
 
-		line, err := json.Marshal(rec)
+func sectionSourceURL(pageURL, headingID string) string {
+	if headingID == "" {
+		return pageURL
+	}
+	return pageURL + "#" + headingID
+}
+
+func shortHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// indexPageURL drops the fragment so the same page always yields the same IDs.
+func indexPageURL(pageURL string) string {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return pageURL
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u.String()
+}
+
+// MergeIndexes concatenates per-page index files into outDir/index.jsonl in
+// the given order. Missing files are skipped so pages without sections do not
+// fail the merge.
+func MergeIndexes(outDir string, indexPaths []string) (string, error) {
+	return mergeJSONL(outDir, "index.jsonl", indexPaths)
+}
+
+// jsonlFile is a buffered JSON Lines file being written.
+type jsonlFile struct {
+	path string
+	file *os.File
+	buf  *bufio.Writer
+}
+
+func createJSONL(outDir, filename string) (*jsonlFile, error) {
+	if err := fsutil.MkdirAll(outDir, 0755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(outDir, filename)
+	f, err := fsutil.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonlFile{path: path, file: f, buf: bufio.NewWriter(f)}, nil
+}
+
+func (j *jsonlFile) writeLine(line []byte) error {
+	if _, err := j.buf.Write(line); err != nil {
+		return err
+	}
+	return j.buf.WriteByte('\n')
+}
+
+func (j *jsonlFile) close() (string, error) {
+	err := j.buf.Flush()
+	if cerr := j.file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	return j.path, nil
+}
+
+func (j *jsonlFile) abort() {
+	_ = j.file.Close()
+	_ = os.Remove(j.path)
+}
+
+func mergeJSONL(outDir, filename string, indexPaths []string) (string, error) {
+	if err := fsutil.MkdirAll(outDir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(outDir, filename)
+	f, err := fsutil.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+
+	for _, p := range indexPaths {
+		data, err := os.ReadFile(p)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to marshal index record %q: %v\n", rec.Heading, err)
-			continue
-		}
-		if _, err := f.Write(line); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
 			return "", err
 		}
-		if _, err := f.Write([]byte("\n")); err != nil {
+		if _, err := w.Write(data); err != nil {
 			return "", err
 		}
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			if err := w.WriteByte('\n'); err != nil {
+				return "", err
+			}
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
 	}
 	return path, nil
 }
+
+// SectionFingerprint identifies a section across runs by its index ID and
+// hashes what index.jsonl records for it, so two runs can be compared.
+type SectionFingerprint struct {
+	URL         string
+	ID          string
+	HeadingPath string
+	Hash        string
+}
+
+// SectionFingerprints fingerprints sections as WriteIndex would record them.
+func SectionFingerprints(pageURL string, sections []parse.Section) []SectionFingerprint {
+	idents := sectionIdentities(indexPageURL(pageURL), sections)
+	out := make([]SectionFingerprint, 0, len(sections))
+	for i, sec := range sections {
+		out = append(out, SectionFingerprint{
+			URL:         indexPageURL(pageURL),
+			ID:          idents[i].ID,
+			HeadingPath: idents[i].HeadingPath,
+			Hash:        sectionHash(sec.HeadingText, strings.TrimSpace(sec.ContentHTML)),
+		})
+	}
+	return out
+}
+
+// ReadSectionFingerprints fingerprints the sections of a previous run from
+// outDir/index.jsonl.
+func ReadSectionFingerprints(outDir string) ([]SectionFingerprint, error) {
+	f, err := os.Open(filepath.Join(outDir, "index.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	out := []SectionFingerprint{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var rec IndexRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		out = append(out, SectionFingerprint{
+			URL:         rec.URL,
+			ID:          rec.ID,
+			HeadingPath: rec.HeadingPath,
+			Hash:        sectionHash(rec.Heading, rec.Content),
+		})
+	}
+	return out, scanner.Err()
+}
+
+func sectionHash(heading, content string) string {
+	return shortHash(heading + "\x00" + content)
+}
@@ -22,7 +22,7 @@ func TestWriteIndex_BuildsHierarchyAndStableIDs(t *testing.T) {
 		{HeadingText: "Sibling", HeadingLevel: 2, HeadingID: "sibling", ContentHTML: "<p>xyz</p>"},
 	}
 
-	outPath, err := WriteIndex(dir, baseURL, sections)
+	outPath, err := WriteIndex(dir, baseURL, sections, nil)
 	if err != nil {
 		t.Fatalf("WriteIndex error: %v", err)
 	}
@@ -84,3 +84,69 @@ func TestSlugify(t *testing.T) {
 		t.Fatalf("unexpected slug: %q", got)
 	}
 }
+
+func readIndexRecords(t *testing.T, path string) []IndexRecord {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read index: %v", err)
+	}
+	var recs []IndexRecord
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var rec IndexRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("unmarshal %q: %v", line, err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+func TestWriteIndex_IDsUniqueAcrossPagesAndDuplicates(t *testing.T) {
+	root := t.TempDir()
+	sections := []parse.Section{
+		{HeadingText: "Usage", HeadingLevel: 2, ContentHTML: "<p>a</p>"},
+		{HeadingText: "Usage", HeadingLevel: 2, ContentHTML: "<p>b</p>"},
+	}
+
+	pathA, err := WriteIndex(filepath.Join(root, "a"), "https://example.com/a#top", sections, nil)
+	if err != nil {
+		t.Fatalf("WriteIndex a: %v", err)
+	}
+	pathB, err := WriteIndex(filepath.Join(root, "b"), "https://example.com/b", sections, nil)
+	if err != nil {
+		t.Fatalf("WriteIndex b: %v", err)
+	}
+	recsA := readIndexRecords(t, pathA)
+	recsB := readIndexRecords(t, pathB)
+
+	if recsA[0].URL != "https://example.com/a" || recsA[0].SourceURL != "https://example.com/a" {
+		t.Fatalf("expected fragment-free page URL, got %+v", recsA[0])
+	}
+	ids := map[string]bool{}
+	for _, rec := range append(recsA, recsB...) {
+		if ids[rec.ID] {
+			t.Fatalf("duplicate id %q", rec.ID)
+		}
+		ids[rec.ID] = true
+	}
+
+	again, err := WriteIndex(filepath.Join(root, "a2"), "https://example.com/a", sections, nil)
+	if err != nil {
+		t.Fatalf("WriteIndex a2: %v", err)
+	}
+	for i, rec := range readIndexRecords(t, again) {
+		if rec.ID != recsA[i].ID {
+			t.Fatalf("id not stable: %q vs %q", rec.ID, recsA[i].ID)
+		}
+	}
+
+	merged, err := MergeIndexes(root, []string{pathA, filepath.Join(root, "missing", "index.jsonl"), pathB})
+	if err != nil {
+		t.Fatalf("MergeIndexes: %v", err)
+	}
+	all := readIndexRecords(t, merged)
+	if len(all) != 4 || all[0].URL != "https://example.com/a" || all[3].URL != "https://example.com/b" {
+		t.Fatalf("unexpected merged index: %+v", all)
+	}
+}
@@ -0,0 +1,168 @@
+package output
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+
+	"go_scrap/internal/dates"
+	"go_scrap/internal/fsutil"
+	"go_scrap/internal/parse"
+	"go_scrap/internal/report"
+)
+
+// jsonSink buffers writes to a file, optionally through gzip.
+type jsonSink struct {
+	file *os.File
+	gz   *gzip.Writer
+	buf  *bufio.Writer
+}
+
+func createJSONSink(path string, compress bool, enc TextEncoding) (*jsonSink, error) {
+	f, err := fsutil.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	sink := &jsonSink{file: f}
+	var w io.Writer = f
+	if compress {
+		sink.gz = gzip.NewWriter(f)
+		w = sink.gz
+	}
+	sink.buf = bufio.NewWriter(enc.writer(w))
+	return sink, nil
+}
+
+func (s *jsonSink) Write(p []byte) (int, error) {
+	return s.buf.Write(p)
+}
+
+func (s *jsonSink) Close() error {
+	err := s.buf.Flush()
+	if s.gz != nil {
+		if cerr := s.gz.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if cerr := s.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// writeJSONDocument streams the content.json document section by section so
+// large crawls never hold the whole serialized payload in memory. The layout
+// matches json.MarshalIndent(JSONDoc, "", "  ").
+func writeJSONDocument(w io.Writer, doc *parse.Document, rep report.Report, encode func(parse.Section) any) error {
+	if _, err := io.WriteString(w, "{\n"); err != nil {
+		return err
+	}
+	if err := writeJSONField(w, "heading_ids", doc.HeadingIDs, true); err != nil {
+		return err
+	}
+	if err := writeJSONField(w, "anchor_targets", doc.AnchorTargets, true); err != nil {
+		return err
+	}
+	if published := dates.Format(doc.Dates.Published); published != "" {
+		if err := writeJSONField(w, "published", published, true); err != nil {
+			return err
+		}
+	}
+	if modified := dates.Format(doc.Dates.Modified); modified != "" {
+		if err := writeJSONField(w, "modified", modified, true); err != nil {
+			return err
+		}
+	}
+	if len(doc.Byline.Authors) > 0 {
+		if err := writeJSONField(w, "authors", doc.Byline.Authors, true); err != nil {
+			return err
+		}
+	}
+	if len(doc.Byline.Contributors) > 0 {
+		if err := writeJSONField(w, "contributors", doc.Byline.Contributors, true); err != nil {
+			return err
+		}
+	}
+	if err := writeJSONSections(w, doc.Sections, encode); err != nil {
+		return err
+	}
+	if err := writeJSONField(w, "report", rep, false); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "}")
+	return err
+}
+
+func writeJSONField(w io.Writer, name string, value any, more bool) error {
+	data, err := json.MarshalIndent(value, "  ", "  ")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, "  \""+name+"\": "); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	return writeFieldEnd(w, more)
+}
+
+func writeJSONSections(w io.Writer, sections []parse.Section, encode func(parse.Section) any) error {
+	if _, err := io.WriteString(w, "  \"sections\": "); err != nil {
+		return err
+	}
+	if len(sections) == 0 {
+		empty := "[]"
+		if sections == nil {
+			empty = "null"
+		}
+		if _, err := io.WriteString(w, empty); err != nil {
+			return err
+		}
+		return writeFieldEnd(w, true)
+	}
+	if _, err := io.WriteString(w, "[\n"); err != nil {
+		return err
+	}
+	for i, s := range sections {
+		data, err := json.MarshalIndent(encode(s), "    ", "  ")
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, "    "); err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		if err := writeFieldEnd(w, i < len(sections)-1); err != nil {
+			return err
+		}
+	}
+	if _, err := io.WriteString(w, "  ]"); err != nil {
+		return err
+	}
+	return writeFieldEnd(w, true)
+}
+
+func writeFieldEnd(w io.Writer, more bool) error {
+	end := "\n"
+	if more {
+		end = ",\n"
+	}
+	_, err := io.WriteString(w, end)
+	return err
+}
+
+// writeNDJSONSections writes one section object per line.
+func writeNDJSONSections(w io.Writer, sections []parse.Section, encode func(parse.Section) any) error {
+	enc := json.NewEncoder(w)
+	for _, s := range sections {
+		if err := enc.Encode(encode(s)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package output
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go_scrap/internal/byline"
+	"go_scrap/internal/dates"
+	"go_scrap/internal/parse"
+	"go_scrap/internal/report"
+)
+
+func TestWriteJSONDocument_MatchesMarshalIndent(t *testing.T) {
+	doc := &parse.Document{
+		HeadingIDs:    []string{"a", "b"},
+		AnchorTargets: []string{"a"},
+		Sections: []parse.Section{
+			{HeadingText: "A", HeadingLevel: 1, HeadingID: "a", ContentHTML: "<p>x</p>", ContentText: "x"},
+			{HeadingText: "B", HeadingLevel: 2, HeadingID: "b", AnchorTargets: []string{"a"}, Date: "2024-05-01", Authors: []string{"Jane"}},
+		},
+		Dates:  dates.Page{Published: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+		Byline: byline.Page{Authors: []byline.Person{{Name: "Jane", URL: "https://example.com/jane"}}},
+	}
+	rep := report.Report{EmptySections: []string{"B"}}
+
+	var buf bytes.Buffer
+	if err := writeJSONDocument(&buf, doc, rep, sectionEncoder(nil, false)); err != nil {
+		t.Fatalf("writeJSONDocument error: %v", err)
+	}
+	want, err := json.MarshalIndent(JSONDoc{
+		HeadingIDs:    doc.HeadingIDs,
+		AnchorTargets: doc.AnchorTargets,
+		Published:     "2024-05-01",
+		Authors:       doc.Byline.Authors,
+		Sections:      doc.Sections,
+		Report:        rep,
+	}, "", "  ")
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if buf.String() != string(want) {
+		t.Fatalf("streamed output mismatch\nwant:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
+
+func TestWriteJSON_NDJSONGzip(t *testing.T) {
+	dir := t.TempDir()
+	doc := &parse.Document{Sections: []parse.Section{
+		{HeadingText: "A", HeadingID: "a"},
+		{HeadingText: "B", HeadingID: "b"},
+	}}
+
+	path, err := WriteJSON(doc, report.Report{}, WriteOptions{OutputDir: dir, NDJSON: true, Gzip: true})
+	if err != nil {
+		t.Fatalf("WriteJSON error: %v", err)
+	}
+	if path != filepath.Join(dir, "content.ndjson.gz") {
+		t.Fatalf("unexpected path: %s", path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("read gzip: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d: %q", len(lines), data)
+	}
+	var sec parse.Section
+	if err := json.Unmarshal([]byte(lines[1]), &sec); err != nil {
+		t.Fatalf("unmarshal line: %v", err)
+	}
+	if sec.HeadingID != "b" {
+		t.Fatalf("unexpected section: %+v", sec)
+	}
+}
@@ -0,0 +1,147 @@
+package output
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go_scrap/internal/fsutil"
+	"go_scrap/internal/slug"
+)
+
+// MarkdownWriter writes a Markdown document one part at a time, the way
+// WriteMarkdownPartsEncoded writes it from all of its parts, so the parts
+// never have to be in memory together. Without limits each part goes
+// straight to the file. With limits each bundle is written once it is full;
+// only the parts before the second bundle are held back, until it shows the
+// document needs splitting at all.
+type MarkdownWriter struct {
+	path   string
+	sep    string
+	limits ChunkLimits
+	enc    TextEncoding
+
+	// file, buf and w stream a document written without limits.
+	file *os.File
+	buf  *bufio.Writer
+	w    io.Writer
+
+	bundles bundler
+	namer   partNamer
+	partDir string
+	heading string
+	headed  bool
+	held    []string
+	first   string
+	written []SplitPart
+}
+
+// NewMarkdownWriter starts outputDir/filename. Parts are each followed by
+// sep when the document ends up in one file; see WriteMarkdownStream.
+func NewMarkdownWriter(outputDir string, filename string, sep string, limits ChunkLimits, enc TextEncoding, slugs *slug.Strategy) (*MarkdownWriter, error) {
+	if outputDir == "" {
+		outputDir = "artifacts"
+	}
+	if filename == "" {
+		filename = "content.md"
+	}
+	if err := fsutil.MkdirAll(outputDir, 0755); err != nil {
+		return nil, err
+	}
+	m := &MarkdownWriter{path: filepath.Join(outputDir, filename), sep: sep, limits: limits, enc: enc}
+	if !limits.Enabled() {
+		f, err := fsutil.OpenFile(m.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return nil, err
+		}
+		m.file = f
+		m.buf = bufio.NewWriterSize(f, 64*1024)
+		m.w = enc.writer(m.buf)
+		return m, nil
+	}
+	baseName := strings.TrimSuffix(filename, filepath.Ext(filename))
+	m.partDir = filepath.Join(outputDir, baseName)
+	m.namer = partNamer{slugs: slugs, dir: baseName, limits: limits}
+	m.bundles = bundler{limits: limits, emit: m.writeBundle}
+	return m, nil
+}
+
+// Add appends part to the document.
+func (m *MarkdownWriter) Add(part string) error {
+	if m.file != nil {
+		return StreamMarkdown(m.w, []string{part}, m.sep)
+	}
+	if !m.headed && strings.TrimSpace(part) != "" {
+		m.heading = firstHeadingLine(part)
+		m.headed = true
+	}
+	if len(m.written) < 2 {
+		m.held = append(m.held, part)
+	}
+	return m.bundles.add(part)
+}
+
+// writeBundle writes a full bundle to its part file, holding the first back
+// until there is a second.
+func (m *MarkdownWriter) writeBundle(bundle string) error {
+	m.written = append(m.written, m.namer.next(bundle))
+	count := len(m.written)
+	if count == 1 {
+		m.first = bundle
+		return nil
+	}
+	if count == 2 {
+		if err := fsutil.MkdirAll(m.partDir, 0755); err != nil {
+			return err
+		}
+		if err := m.enc.writeFile(filepath.Join(m.partDir, m.written[0].Name+".md"), m.first); err != nil {
+			return err
+		}
+		m.first = ""
+		m.held = nil
+	}
+	return m.enc.writeFile(filepath.Join(m.partDir, m.written[count-1].Name+".md"), bundle)
+}
+
+// Close finishes the document, writing the last bundle and the index of a
+// split one, and returns its path.
+func (m *MarkdownWriter) Close() (string, error) {
+	if m.file != nil {
+		err := m.buf.Flush()
+		if cerr := m.file.Close(); err == nil {
+			err = cerr
+		}
+		m.file = nil
+		if err != nil {
+			return "", err
+		}
+		return m.path, nil
+	}
+	if err := m.bundles.finish(); err != nil {
+		return "", err
+	}
+	if len(m.written) <= 1 {
+		if err := m.enc.streamFile(m.path, m.held, m.sep); err != nil {
+			return "", err
+		}
+		return m.path, nil
+	}
+	if err := writeSplitIndex(m.path, m.partDir, m.heading, m.written, m.limits, m.enc); err != nil {
+		return "", err
+	}
+	return m.path, nil
+}
+
+// Abort stops the document and removes what was written of it.
+func (m *MarkdownWriter) Abort() {
+	if m.file != nil {
+		_ = m.file.Close()
+		m.file = nil
+	}
+	_ = os.Remove(m.path)
+	if len(m.written) > 1 {
+		_ = os.RemoveAll(m.partDir)
+	}
+}
@@ -0,0 +1,268 @@
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go_scrap/internal/fsutil"
+	"go_scrap/internal/warnings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// mediaTypes maps the extensions of linked non-HTML resources to the type
+// media-links.json counts them under.
+var mediaTypes = map[string]string{
+	"pdf": "pdf",
+
+	"zip": "archive", "tar": "archive", "gz": "archive", "tgz": "archive",
+	"bz2": "archive", "xz": "archive", "7z": "archive", "rar": "archive",
+
+	"mp4": "video", "webm": "video", "mov": "video", "avi": "video",
+	"mkv": "video", "m4v": "video",
+
+	"mp3": "audio", "wav": "audio", "ogg": "audio", "flac": "audio",
+	"m4a": "audio", "aac": "audio",
+
+	"doc": "document", "docx": "document", "xls": "document", "xlsx": "document",
+	"ppt": "document", "pptx": "document", "odt": "document", "ods": "document",
+	"odp": "document", "rtf": "document", "epub": "document", "csv": "document",
+
+	"png": "image", "jpg": "image", "jpeg": "image", "gif": "image",
+	"svg": "image", "webp": "image",
+
+	"exe": "binary", "msi": "binary", "dmg": "binary", "pkg": "binary",
+	"deb": "binary", "rpm": "binary", "apk": "binary", "iso": "binary",
+	"bin": "binary", "jar": "binary", "whl": "binary",
+}
+
+// MediaTypes are the types media links are counted under, for
+// --download-media.
+var MediaTypes = []string{"pdf", "archive", "video", "audio", "document", "image", "binary"}
+
+// MediaLink is a link to a non-HTML resource and the pages linking to it.
+type MediaLink struct {
+	URL   string   `json:"url"`
+	Type  string   `json:"type"`
+	Ext   string   `json:"ext"`
+	Pages []string `json:"pages"`
+	// LocalPath is the downloaded copy, relative to the directory of
+	// media-links.json.
+	LocalPath string `json:"local_path,omitempty"`
+}
+
+// MediaInventory is the content of media-links.json.
+type MediaInventory struct {
+	Total  int            `json:"total"`
+	Counts map[string]int `json:"counts"`
+	Links  []MediaLink    `json:"links"`
+}
+
+// MediaType classifies rawURL by the extension of its path, returning its
+// type and extension, or "" for links to pages and unknown resources.
+func MediaType(rawURL string) (string, string) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", ""
+	}
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(u.Path), "."))
+	return mediaTypes[ext], ext
+}
+
+// ValidateMediaTypes checks the types or extensions given to --download-media.
+func ValidateMediaTypes(types []string) error {
+	for _, t := range types {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || t == "all" || mediaTypes[t] != "" || mediaTypeKnown(t) {
+			continue
+		}
+		return fmt.Errorf("unknown media type %q (available: all, %s, or a file extension such as zip)", t, strings.Join(MediaTypes, ", "))
+	}
+	return nil
+}
+
+func mediaTypeKnown(t string) bool {
+	for _, known := range MediaTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// mediaSelected reports whether link matches one of the types or extensions
+// selected for download.
+func mediaSelected(link MediaLink, selected []string) bool {
+	for _, s := range selected {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if s == "all" || s == link.Type || s == link.Ext {
+			return true
+		}
+	}
+	return false
+}
+
+// CollectMediaLinks finds the links in doc to non-HTML resources: anchors,
+// video, audio, source and embed elements. Inline images are left to
+// --download-assets. Links are resolved against pageURL and sorted.
+func CollectMediaLinks(doc *goquery.Document, pageURL string) []MediaLink {
+	if doc == nil {
+		return nil
+	}
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+	seen := map[string]bool{}
+	var links []MediaLink
+	add := func(ref string) {
+		ref = strings.TrimSpace(ref)
+		if ref == "" || strings.HasPrefix(ref, "#") {
+			return
+		}
+		u, err := url.Parse(ref)
+		if err != nil {
+			return
+		}
+		abs := base.ResolveReference(u)
+		if abs.Scheme != "http" && abs.Scheme != "https" {
+			return
+		}
+		abs.Fragment = ""
+		typ, ext := MediaType(abs.String())
+		if typ == "" || seen[abs.String()] {
+			return
+		}
+		seen[abs.String()] = true
+		links = append(links, MediaLink{URL: abs.String(), Type: typ, Ext: ext, Pages: []string{pageURL}})
+	}
+	doc.Find("a[href]").Each(func(_ int, s *goquery.Selection) {
+		add(s.AttrOr("href", ""))
+	})
+	doc.Find("video[src], audio[src], source[src], embed[src]").Each(func(_ int, s *goquery.Selection) {
+		add(s.AttrOr("src", ""))
+	})
+	sort.Slice(links, func(i, j int) bool { return links[i].URL < links[j].URL })
+	return links
+}
+
+// DownloadMedia downloads the links matching the selected types or
+// extensions to outputDir/media and records their local paths. Failures are
+// reported as warnings and leave the link without a local path.
+func DownloadMedia(ctx context.Context, links []MediaLink, selected []string, outputDir, userAgent string, retry AssetRetry) error {
+	mediaDir := filepath.Join(outputDir, "media")
+	for i := range links {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !mediaSelected(links[i], selected) {
+			continue
+		}
+		if err := fsutil.MkdirAll(mediaDir, 0755); err != nil {
+			return err
+		}
+		job, err := buildDownloadJob(links[i].URL, links[i].URL, mediaDir)
+		if err != nil {
+			continue
+		}
+		if err := fetchAsset(ctx, job, userAgent, retry); err != nil {
+			if ctx.Err() == nil {
+				warnings.Report(ctx, warnings.Warning{
+					Code:    warnings.CodeAssetDownload,
+					Message: fmt.Sprintf("failed to download %s: %v", job.AbsoluteURL, err),
+					URL:     links[i].Pages[0],
+					Context: map[string]string{"asset": job.AbsoluteURL, "error": err.Error()},
+				})
+			}
+			continue
+		}
+		links[i].LocalPath = "media/" + job.Filename
+	}
+	return ctx.Err()
+}
+
+// BuildMediaInventory counts links by type.
+func BuildMediaInventory(links []MediaLink) MediaInventory {
+	inv := MediaInventory{Total: len(links), Counts: map[string]int{}, Links: links}
+	if inv.Links == nil {
+		inv.Links = []MediaLink{}
+	}
+	for _, l := range links {
+		inv.Counts[l.Type]++
+	}
+	return inv
+}
+
+// WriteMediaLinks writes outDir/media-links.json.
+func WriteMediaLinks(outDir string, links []MediaLink) (string, error) {
+	if err := fsutil.MkdirAll(outDir, 0755); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(BuildMediaInventory(links), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(outDir, "media-links.json")
+	if err := fsutil.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// ReadMediaLinks reads the links of a media-links.json.
+func ReadMediaLinks(path string) ([]MediaLink, error) {
+	data, err := fsutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var inv MediaInventory
+	if err := json.Unmarshal(data, &inv); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return inv.Links, nil
+}
+
+// MergeMediaLinks merges per-page media-links.json files into
+// outDir/media-links.json, listing each URL once with every page linking to
+// it. Local paths are made relative to outDir; missing files are skipped.
+func MergeMediaLinks(outDir string, paths []string) (string, error) {
+	byURL := map[string]*MediaLink{}
+	for _, p := range paths {
+		links, err := ReadMediaLinks(p)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(outDir, filepath.Dir(p))
+		if err != nil {
+			rel = ""
+		}
+		for _, l := range links {
+			if l.LocalPath != "" && rel != "" && rel != "." {
+				l.LocalPath = filepath.ToSlash(filepath.Join(rel, filepath.FromSlash(l.LocalPath)))
+			}
+			merged, ok := byURL[l.URL]
+			if !ok {
+				l.Pages = append([]string(nil), l.Pages...)
+				byURL[l.URL] = &l
+				continue
+			}
+			merged.Pages = append(merged.Pages, l.Pages...)
+			if merged.LocalPath == "" {
+				merged.LocalPath = l.LocalPath
+			}
+		}
+	}
+	links := make([]MediaLink, 0, len(byURL))
+	for _, l := range byURL {
+		sort.Strings(l.Pages)
+		links = append(links, *l)
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].URL < links[j].URL })
+	return WriteMediaLinks(outDir, links)
+}
@@ -0,0 +1,79 @@
+package output
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestCollectMediaLinks_ClassifiesNonHTMLLinks(t *testing.T) {
+	html := `<html><body>
+		<a href="docs/manual.PDF#page=2">Manual</a>
+		<a href="/releases/v1.tar.gz?dl=1">Release</a>
+		<a href="/guide">Guide</a>
+		<a href="mailto:a@example.com">Mail</a>
+		<a href="docs/manual.PDF">Manual again</a>
+		<audio src="https://cdn.example.com/talk.mp3"></audio>
+		<img src="/logo.png">
+	</body></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	links := CollectMediaLinks(doc, "https://example.com/start/")
+	want := []MediaLink{
+		{URL: "https://cdn.example.com/talk.mp3", Type: "audio", Ext: "mp3"},
+		{URL: "https://example.com/releases/v1.tar.gz?dl=1", Type: "archive", Ext: "gz"},
+		{URL: "https://example.com/start/docs/manual.PDF", Type: "pdf", Ext: "pdf"},
+	}
+	if len(links) != len(want) {
+		t.Fatalf("expected %d links, got %+v", len(want), links)
+	}
+	for i, w := range want {
+		if links[i].URL != w.URL || links[i].Type != w.Type || links[i].Ext != w.Ext {
+			t.Fatalf("link %d: expected %+v, got %+v", i, w, links[i])
+		}
+	}
+}
+
+func TestMergeMediaLinks_CombinesPagesAndCounts(t *testing.T) {
+	root := t.TempDir()
+	a := filepath.Join(root, "pages", "a")
+	b := filepath.Join(root, "pages", "b")
+	pathA, err := WriteMediaLinks(a, []MediaLink{
+		{URL: "https://example.com/x.pdf", Type: "pdf", Ext: "pdf", Pages: []string{"https://example.com/a"}, LocalPath: "media/x.pdf"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pathB, err := WriteMediaLinks(b, []MediaLink{
+		{URL: "https://example.com/x.pdf", Type: "pdf", Ext: "pdf", Pages: []string{"https://example.com/b"}},
+		{URL: "https://example.com/y.zip", Type: "archive", Ext: "zip", Pages: []string{"https://example.com/b"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := MergeMediaLinks(root, []string{pathA, pathB, filepath.Join(root, "missing.json")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	links, err := ReadMediaLinks(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 2 {
+		t.Fatalf("expected 2 links, got %+v", links)
+	}
+	if got := strings.Join(links[0].Pages, ","); got != "https://example.com/a,https://example.com/b" {
+		t.Fatalf("expected both pages for x.pdf, got %q", got)
+	}
+	if links[0].LocalPath != "pages/a/media/x.pdf" {
+		t.Fatalf("expected local path relative to the crawl root, got %q", links[0].LocalPath)
+	}
+	inv := BuildMediaInventory(links)
+	if inv.Total != 2 || inv.Counts["pdf"] != 1 || inv.Counts["archive"] != 1 {
+		t.Fatalf("unexpected counts %+v", inv)
+	}
+}
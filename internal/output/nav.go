@@ -0,0 +1,71 @@
+package output
+
+import (
+	"path/filepath"
+	"strings"
+
+	"go_scrap/internal/fsutil"
+	"go_scrap/internal/menu"
+)
+
+// Navigation files understood by common doc renderers.
+const (
+	NavSummary = "SUMMARY.md"  // GitBook / mdBook
+	NavSidebar = "_sidebar.md" // Docsify
+)
+
+// WriteNavMarkdown writes SUMMARY.md and _sidebar.md from the menu tree,
+// linking each entry to the section file recorded on the node. Entries
+// without a section file are written as plain text so the tree keeps its
+// shape. It returns the paths written.
+func WriteNavMarkdown(outputDir string, nodes []menu.Node, enc TextEncoding) ([]string, error) {
+	if outputDir == "" {
+		outputDir = "artifacts"
+	}
+	if err := fsutil.MkdirAll(outputDir, 0755); err != nil {
+		return nil, err
+	}
+	var list strings.Builder
+	writeNavList(&list, nodes, 0)
+
+	files := []struct{ name, body string }{
+		{NavSummary, "# Summary\n\n" + list.String()},
+		{NavSidebar, list.String()},
+	}
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		path := filepath.Join(outputDir, f.name)
+		if err := enc.writeFile(path, f.body); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+var (
+	navTitleEscaper = strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`)
+	navLinkEscaper  = strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29")
+)
+
+func writeNavList(b *strings.Builder, nodes []menu.Node, depth int) {
+	for _, node := range nodes {
+		title := strings.Join(strings.Fields(node.Title), " ")
+		if title == "" {
+			title = node.Anchor
+		}
+		if title == "" && node.File == "" {
+			writeNavList(b, node.Children, depth)
+			continue
+		}
+		b.WriteString(strings.Repeat("  ", depth))
+		b.WriteString("* ")
+		if node.File != "" {
+			b.WriteString("[" + navTitleEscaper.Replace(title) + "](" + navLinkEscaper.Replace(node.File) + ")")
+		} else {
+			b.WriteString(navTitleEscaper.Replace(title))
+		}
+		b.WriteString("\n")
+		writeNavList(b, node.Children, depth+1)
+	}
+}
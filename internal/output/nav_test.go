@@ -0,0 +1,44 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go_scrap/internal/menu"
+)
+
+func TestWriteNavMarkdown(t *testing.T) {
+	dir := t.TempDir()
+	nodes := []menu.Node{
+		{Title: "Intro", Anchor: "intro", File: "sections/intro.md"},
+		{Title: "Guides [beta]", Children: []menu.Node{
+			{Title: "Setup (Linux)", Anchor: "setup", File: "sections/guides/setup (linux).md"},
+		}},
+	}
+	paths, err := WriteNavMarkdown(dir, nodes, TextEncoding{})
+	if err != nil {
+		t.Fatalf("WriteNavMarkdown: %v", err)
+	}
+	if len(paths) != 2 {
+		t.Fatalf("expected 2 files, got %v", paths)
+	}
+
+	wantList := "* [Intro](sections/intro.md)\n" +
+		"* Guides \\[beta\\]\n" +
+		"  * [Setup (Linux)](sections/guides/setup%20%28linux%29.md)\n"
+	sidebar, err := os.ReadFile(filepath.Join(dir, NavSidebar))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(sidebar) != wantList {
+		t.Fatalf("_sidebar.md =\n%s", sidebar)
+	}
+	summary, err := os.ReadFile(filepath.Join(dir, NavSummary))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(summary) != "# Summary\n\n"+wantList {
+		t.Fatalf("SUMMARY.md =\n%s", summary)
+	}
+}
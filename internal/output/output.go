@@ -1,27 +1,48 @@
 package output
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
-	"os"
+	"io"
 	"path/filepath"
 	"strings"
+	"text/template"
+	"unicode/utf8"
 
+	"go_scrap/internal/byline"
+	"go_scrap/internal/fsutil"
 	"go_scrap/internal/menu"
 	"go_scrap/internal/parse"
 	"go_scrap/internal/report"
+	"go_scrap/internal/slug"
+	"go_scrap/internal/tokenize"
 )
 
 type WriteOptions struct {
 	OutputDir    string
 	MarkdownFile string
 	JSONFile     string
+	// JSONFields limits which section keys are serialized (empty = all).
+	JSONFields      []string
+	OmitContentText bool
+	// NDJSON writes one section per line (content.ndjson) instead of a single document.
+	NDJSON bool
+	// Gzip compresses the JSON output and appends ".gz" to its filename.
+	Gzip bool
+	// Encoding sets line endings and BOM for the Markdown and JSON files.
+	Encoding TextEncoding
 }
 
 type ChunkLimits struct {
 	MaxBytes  int
 	MaxChars  int
 	MaxTokens int
+	// Tokenizer counts tokens for MaxTokens and token estimates (nil =
+	// tokenize.Approx).
+	Tokenizer tokenize.Tokenizer
+	// IndexTemplate renders the index of a split document (nil = a list of
+	// its parts); see LoadSplitIndexTemplate.
+	IndexTemplate *template.Template
 }
 
 func (c ChunkLimits) Enabled() bool {
@@ -48,14 +69,21 @@ func (c ChunkLimits) exceeds(size chunkSize) bool {
 }
 
 type JSONDoc struct {
-	HeadingIDs    []string        `json:"heading_ids"`
-	AnchorTargets []string        `json:"anchor_targets"`
-	Sections      []parse.Section `json:"sections"`
-	Report        report.Report   `json:"report"`
+	HeadingIDs    []string `json:"heading_ids"`
+	AnchorTargets []string `json:"anchor_targets"`
+	// Published and Modified are the page's dates, when found.
+	Published string `json:"published,omitempty"`
+	Modified  string `json:"modified,omitempty"`
+	// Authors and Contributors are the page's byline, when found.
+	Authors      []byline.Person `json:"authors,omitempty"`
+	Contributors []byline.Person `json:"contributors,omitempty"`
+	// Sections holds []parse.Section, or filtered maps when JSONFields/OmitContentText are set.
+	Sections any           `json:"sections"`
+	Report   report.Report `json:"report"`
 }
 
 func WriteAll(doc *parse.Document, rep report.Report, markdown string, opts WriteOptions) (string, string, error) {
-	mdPath, err := WriteMarkdown(opts.OutputDir, opts.MarkdownFile, markdown)
+	mdPath, err := WriteMarkdownEncoded(opts.OutputDir, opts.MarkdownFile, markdown, opts.Encoding)
 	if err != nil {
 		return "", "", err
 	}
@@ -72,25 +100,34 @@ func WriteJSON(doc *parse.Document, rep report.Report, opts WriteOptions) (strin
 	}
 	if opts.JSONFile == "" {
 		opts.JSONFile = "content.json"
+		if opts.NDJSON {
+			opts.JSONFile = "content.ndjson"
+		}
+	}
+	if opts.Gzip && !strings.HasSuffix(opts.JSONFile, ".gz") {
+		opts.JSONFile += ".gz"
 	}
 
-	if err := os.MkdirAll(opts.OutputDir, 0755); err != nil {
+	if err := fsutil.MkdirAll(opts.OutputDir, 0755); err != nil {
 		return "", err
 	}
 
 	jsonPath := filepath.Join(opts.OutputDir, opts.JSONFile)
-	payload := JSONDoc{
-		HeadingIDs:    doc.HeadingIDs,
-		AnchorTargets: doc.AnchorTargets,
-		Sections:      doc.Sections,
-		Report:        rep,
-	}
-
-	data, err := json.MarshalIndent(payload, "", "  ")
+	sink, err := createJSONSink(jsonPath, opts.Gzip, opts.Encoding)
 	if err != nil {
 		return "", err
 	}
-	if err := os.WriteFile(jsonPath, data, 0600); err != nil {
+
+	encode := sectionEncoder(opts.JSONFields, opts.OmitContentText)
+	if opts.NDJSON {
+		err = writeNDJSONSections(sink, doc.Sections, encode)
+	} else {
+		err = writeJSONDocument(sink, doc, rep, encode)
+	}
+	if cerr := sink.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
 		return "", err
 	}
 
@@ -98,69 +135,121 @@ func WriteJSON(doc *parse.Document, rep report.Report, opts WriteOptions) (strin
 }
 
 func WriteMarkdown(outputDir string, filename string, markdown string) (string, error) {
+	return WriteMarkdownEncoded(outputDir, filename, markdown, TextEncoding{})
+}
+
+// WriteMarkdownEncoded is WriteMarkdown with configurable line endings and BOM.
+func WriteMarkdownEncoded(outputDir string, filename string, markdown string, enc TextEncoding) (string, error) {
 	if outputDir == "" {
 		outputDir = "artifacts"
 	}
 	if filename == "" {
 		filename = "content.md"
 	}
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
+	if err := fsutil.MkdirAll(outputDir, 0755); err != nil {
 		return "", err
 	}
 	mdPath := filepath.Join(outputDir, filename)
-	if err := os.WriteFile(mdPath, []byte(markdown), 0600); err != nil {
+	if err := enc.writeFile(mdPath, markdown); err != nil {
 		return "", err
 	}
 	return mdPath, nil
 }
 
 func WriteMarkdownParts(outputDir string, filename string, parts []string, limits ChunkLimits) (string, error) {
+	return WriteMarkdownPartsEncoded(outputDir, filename, parts, limits, TextEncoding{}, nil)
+}
+
+// WriteMarkdownPartsEncoded is WriteMarkdownParts with configurable line
+// endings and BOM applied to the index and every part file, and heading
+// anchors in the index generated with the given slug strategy.
+func WriteMarkdownPartsEncoded(outputDir string, filename string, parts []string, limits ChunkLimits, enc TextEncoding, slugs *slug.Strategy) (string, error) {
+	m, err := NewMarkdownWriter(outputDir, filename, "", limits, enc, slugs)
+	if err != nil {
+		return "", err
+	}
+	for _, part := range parts {
+		if err := m.Add(part); err != nil {
+			m.Abort()
+			return "", err
+		}
+	}
+	return m.Close()
+}
+
+// WriteMarkdownStream writes parts, each followed by sep, straight to the
+// file so a large document never has to be joined in memory.
+func WriteMarkdownStream(outputDir string, filename string, parts []string, sep string, enc TextEncoding) (string, error) {
 	if outputDir == "" {
 		outputDir = "artifacts"
 	}
 	if filename == "" {
 		filename = "content.md"
 	}
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
+	if err := fsutil.MkdirAll(outputDir, 0755); err != nil {
 		return "", err
 	}
-
-	if !limits.Enabled() {
-		return WriteMarkdown(outputDir, filename, strings.Join(parts, ""))
+	mdPath := filepath.Join(outputDir, filename)
+	if err := enc.streamFile(mdPath, parts, sep); err != nil {
+		return "", err
 	}
+	return mdPath, nil
+}
 
-	baseName := strings.TrimSuffix(filename, filepath.Ext(filename))
-	basePath := filepath.Join(outputDir, baseName)
-	bundles := bundleParts(parts, limits)
-	if len(bundles) <= 1 {
-		return WriteMarkdown(outputDir, filename, strings.Join(parts, ""))
+// StreamMarkdown writes parts to w, each followed by sep.
+func StreamMarkdown(w io.Writer, parts []string, sep string) error {
+	for _, part := range parts {
+		if _, err := io.WriteString(w, part); err != nil {
+			return err
+		}
+		if sep == "" {
+			continue
+		}
+		if _, err := io.WriteString(w, sep); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	if err := os.MkdirAll(basePath, 0755); err != nil {
-		return "", err
+// MarkdownFileCount is how many files WriteMarkdownPartsEncoded writes for
+// parts: one, or an index, parts.json and one file per bundle when limits
+// split them.
+func MarkdownFileCount(parts []string, limits ChunkLimits) int {
+	if !limits.Enabled() {
+		return 1
 	}
-
-	for i, bundle := range bundles {
-		partPath := filepath.Join(basePath, fmt.Sprintf("part-%03d.md", i+1))
-		if err := os.WriteFile(partPath, []byte(bundle), 0600); err != nil {
-			return "", err
-		}
+	bundles := 0
+	_ = eachBundle(parts, limits, func(string) error {
+		bundles++
+		return nil
+	})
+	if bundles <= 1 {
+		return 1
 	}
+	return bundles + 2
+}
 
-	heading := firstHeadingLine(strings.Join(parts, ""))
-	index := buildSplitIndex(heading, baseName, len(bundles))
-	mdPath := filepath.Join(outputDir, filename)
-	if err := os.WriteFile(mdPath, []byte(index), 0600); err != nil {
-		return "", err
+// firstPartHeading is firstHeadingLine of the joined parts.
+func firstPartHeading(parts []string) string {
+	for _, part := range parts {
+		if strings.TrimSpace(part) != "" {
+			return firstHeadingLine(part)
+		}
 	}
-	return mdPath, nil
+	return ""
 }
 
 func WriteMenu(outputDir string, nodes []menu.Node) error {
+	return WriteMenuEncoded(outputDir, nodes, TextEncoding{})
+}
+
+// WriteMenuEncoded is WriteMenu with configurable line endings and BOM.
+func WriteMenuEncoded(outputDir string, nodes []menu.Node, enc TextEncoding) error {
 	if outputDir == "" {
 		outputDir = "artifacts"
 	}
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
+	if err := fsutil.MkdirAll(outputDir, 0755); err != nil {
 		return err
 	}
 	path := filepath.Join(outputDir, "menu.json")
@@ -168,32 +257,59 @@ func WriteMenu(outputDir string, nodes []menu.Node) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, data, 0600)
+	return enc.writeFile(path, string(data))
 }
 
 func WriteSectionFiles(outputDir string, nodes []menu.Node, mdByID map[string]string, maxItems int, limits ChunkLimits) error {
+	_, err := WriteSectionFilesContext(context.Background(), outputDir, nodes, mdByID, maxItems, limits, TextEncoding{}, nil)
+	return err
+}
+
+// WriteSectionFilesContext is WriteSectionFiles with cancellation checked
+// between section files, configurable line endings and BOM, and file names
+// derived with the given slug strategy (nil keeps the default naming). Each
+// written file is recorded in its node's File field, and the file for each
+// menu anchor is returned, relative to outputDir.
+func WriteSectionFilesContext(ctx context.Context, outputDir string, nodes []menu.Node, mdByID map[string]string, maxItems int, limits ChunkLimits, enc TextEncoding, slugs *slug.Strategy) (map[string]string, error) {
 	if outputDir == "" {
 		outputDir = "artifacts"
 	}
 	base := filepath.Join(outputDir, "sections")
-	if err := os.MkdirAll(base, 0755); err != nil {
-		return err
+	if err := fsutil.MkdirAll(base, 0755); err != nil {
+		return nil, err
 	}
-	if maxItems <= 0 {
-		return writeNodes(base, nodes, mdByID, []string{}, nil, limits)
+	w := sectionWriter{base: base, mdByID: mdByID, limits: limits, enc: enc, slugs: slugs, files: map[string]string{}}
+	if maxItems > 0 {
+		w.remaining = &maxItems
 	}
-	remaining := maxItems
-	return writeNodes(base, nodes, mdByID, []string{}, &remaining, limits)
+	if err := w.writeNodes(ctx, nodes, []string{}); err != nil {
+		return nil, err
+	}
+	return w.files, nil
 }
 
-func writeNodes(base string, nodes []menu.Node, mdByID map[string]string, pathParts []string, remaining *int, limits ChunkLimits) error {
-	for _, node := range nodes {
-		if remaining != nil && *remaining == 0 {
+type sectionWriter struct {
+	base      string
+	mdByID    map[string]string
+	remaining *int
+	limits    ChunkLimits
+	enc       TextEncoding
+	slugs     *slug.Strategy
+	files     map[string]string
+}
+
+func (w *sectionWriter) writeNodes(ctx context.Context, nodes []menu.Node, pathParts []string) error {
+	for i := range nodes {
+		node := &nodes[i]
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if w.remaining != nil && *w.remaining == 0 {
 			return nil
 		}
-		part := slugify(node.Title)
+		part := sectionFileName(node.Title, w.slugs)
 		if part == "" {
-			part = slugify(node.Anchor)
+			part = sectionFileName(node.Anchor, w.slugs)
 		}
 		if part == "" {
 			part = "section"
@@ -201,22 +317,26 @@ func writeNodes(base string, nodes []menu.Node, mdByID map[string]string, pathPa
 
 		localPath := append(pathParts, part)
 		if node.Anchor != "" {
-			if md, ok := mdByID[node.Anchor]; ok && strings.TrimSpace(md) != "" {
-				filePath := filepath.Join(append([]string{base}, localPath...)...)
-				if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			if md, ok := w.mdByID[node.Anchor]; ok && strings.TrimSpace(md) != "" {
+				filePath := filepath.Join(append([]string{w.base}, localPath...)...)
+				if err := fsutil.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
 					return err
 				}
-				if err := writeMarkdownFile(filePath, md, limits); err != nil {
+				if err := writeMarkdownFile(filePath, md, w.limits, w.enc, w.slugs); err != nil {
 					return err
 				}
-				if remaining != nil && *remaining > 0 {
-					*remaining--
+				node.File = filepath.ToSlash(filepath.Join(append([]string{"sections"}, localPath...)...)) + ".md"
+				if _, ok := w.files[node.Anchor]; !ok {
+					w.files[node.Anchor] = node.File
+				}
+				if w.remaining != nil && *w.remaining > 0 {
+					*w.remaining--
 				}
 			}
 		}
 
 		if len(node.Children) > 0 {
-			if err := writeNodes(base, node.Children, mdByID, localPath, remaining, limits); err != nil {
+			if err := w.writeNodes(ctx, node.Children, localPath); err != nil {
 				return err
 			}
 		}
@@ -224,43 +344,34 @@ func writeNodes(base string, nodes []menu.Node, mdByID map[string]string, pathPa
 	return nil
 }
 
-func writeMarkdownFile(basePath string, md string, limits ChunkLimits) error {
-	if !limits.Enabled() || !limits.exceeds(sizeOfString(md)) {
-		return os.WriteFile(basePath+".md", []byte(md), 0600)
+func writeMarkdownFile(basePath string, md string, limits ChunkLimits, enc TextEncoding, slugs *slug.Strategy) error {
+	if !limits.Enabled() || !limits.exceeds(limits.sizeOf(md)) {
+		return enc.writeFile(basePath+".md", md)
 	}
 
 	parts := splitMarkdownByHeadings(md, limits)
 	if len(parts) == 0 {
-		return os.WriteFile(basePath+".md", []byte(md), 0600)
+		return enc.writeFile(basePath+".md", md)
 	}
 
 	partDir := basePath
-	if err := os.MkdirAll(partDir, 0755); err != nil {
+	if err := fsutil.MkdirAll(partDir, 0755); err != nil {
 		return err
 	}
 
+	namer := partNamer{slugs: slugs, dir: filepath.Base(basePath), limits: limits}
+	if headings := markdownHeadings(firstHeadingLine(md)); len(headings) > 0 {
+		namer.repeated = headings[0].text
+	}
+	written := make([]SplitPart, len(parts))
 	for i, part := range parts {
-		partPath := filepath.Join(partDir, fmt.Sprintf("part-%03d.md", i+1))
-		if err := os.WriteFile(partPath, []byte(part), 0600); err != nil {
+		written[i] = namer.next(part)
+		if err := enc.writeFile(filepath.Join(partDir, written[i].Name+".md"), part); err != nil {
 			return err
 		}
 	}
 
-	index := buildSplitIndex(firstHeadingLine(md), filepath.Base(basePath), len(parts))
-	return os.WriteFile(basePath+".md", []byte(index), 0600)
-}
-
-func buildSplitIndex(heading string, partDir string, parts int) string {
-	var b strings.Builder
-	if heading != "" {
-		b.WriteString(heading)
-		b.WriteString("\n\n")
-	}
-	b.WriteString(fmt.Sprintf("Split into %d parts:\n\n", parts))
-	for i := 1; i <= parts; i++ {
-		b.WriteString(fmt.Sprintf("- %s/part-%03d.md\n", partDir, i))
-	}
-	return b.String()
+	return writeSplitIndex(basePath+".md", partDir, firstHeadingLine(md), written, limits, enc)
 }
 
 func firstHeadingLine(md string) string {
@@ -282,12 +393,12 @@ func splitMarkdownByHeadings(md string, limits ChunkLimits) []string {
 	if md == "" {
 		return nil
 	}
-	if !limits.Enabled() || !limits.exceeds(sizeOfString(md)) {
+	if !limits.Enabled() || !limits.exceeds(limits.sizeOf(md)) {
 		return []string{md}
 	}
 
 	prefix, body := splitHeadingPrefix(md)
-	if limits.exceeds(sizeOfString(prefix)) {
+	if limits.exceeds(limits.sizeOf(prefix)) {
 		prefix = ""
 	}
 
@@ -351,7 +462,7 @@ func newChunkWriter(prefix string, limits ChunkLimits) *chunkWriter {
 	w := &chunkWriter{
 		prefix: prefix,
 		limits: limits,
-		size:   sizeOfString(prefix),
+		size:   limits.sizeOf(prefix),
 		parts:  []string{},
 	}
 	w.cur.WriteString(prefix)
@@ -369,7 +480,7 @@ func (w *chunkWriter) AddBlock(block string) {
 }
 
 func (w *chunkWriter) expandSubBlocks(block string) []string {
-	if w.limits.exceeds(sizeOfString(block)) {
+	if w.limits.exceeds(w.limits.sizeOf(block)) {
 		return splitOnParagraphs(block)
 	}
 	return []string{block}
@@ -384,15 +495,15 @@ func (w *chunkWriter) addSubBlock(sub string) {
 	if w.hasContentBeyondPrefix() {
 		sep = "\n\n"
 	}
-	combined := w.size.add(sizeOfString(sep)).add(sizeOfString(sub))
+	combined := w.size.add(w.limits.sizeOf(sep)).add(w.limits.sizeOf(sub))
 	if w.hasContentBeyondPrefix() && w.limits.exceeds(combined) {
 		w.flush()
 		sep = ""
-		combined = w.size.add(sizeOfString(sub))
+		combined = w.size.add(w.limits.sizeOf(sub))
 	}
 	if sep != "" {
 		w.cur.WriteString(sep)
-		w.size = w.size.add(sizeOfString(sep))
+		w.size = w.size.add(w.limits.sizeOf(sep))
 	}
 	w.cur.WriteString(sub)
 	w.size = combined
@@ -402,7 +513,7 @@ func (w *chunkWriter) addSubBlock(sub string) {
 }
 
 func (w *chunkWriter) hasContentBeyondPrefix() bool {
-	return curHasContentBeyondPrefix(w.size, w.prefix)
+	return curHasContentBeyondPrefix(w.size, w.prefix, w.limits)
 }
 
 func (w *chunkWriter) flush() {
@@ -412,7 +523,7 @@ func (w *chunkWriter) flush() {
 	}
 	w.cur.Reset()
 	w.cur.WriteString(w.prefix)
-	w.size = sizeOfString(w.prefix)
+	w.size = w.limits.sizeOf(w.prefix)
 }
 
 func (w *chunkWriter) Parts() []string {
@@ -437,25 +548,18 @@ func splitOnParagraphs(body string) []string {
 	return out
 }
 
-func sizeOfString(s string) chunkSize {
+// sizeOf measures s, counting tokens with c's tokenizer.
+func (c ChunkLimits) sizeOf(s string) chunkSize {
 	if s == "" {
 		return chunkSize{}
 	}
-	chars := len([]rune(s))
 	return chunkSize{
 		bytes:  len(s),
-		chars:  chars,
-		tokens: estimateTokens(chars),
+		chars:  utf8.RuneCountInString(s),
+		tokens: tokenize.Or(c.Tokenizer).Count(s),
 	}
 }
 
-func estimateTokens(chars int) int {
-	if chars == 0 {
-		return 0
-	}
-	return (chars + 3) / 4
-}
-
 func (s chunkSize) add(o chunkSize) chunkSize {
 	return chunkSize{
 		bytes:  s.bytes + o.bytes,
@@ -464,48 +568,92 @@ func (s chunkSize) add(o chunkSize) chunkSize {
 	}
 }
 
-func curHasContentBeyondPrefix(cur chunkSize, prefix string) bool {
+func curHasContentBeyondPrefix(cur chunkSize, prefix string, limits ChunkLimits) bool {
 	if strings.TrimSpace(prefix) == "" {
 		return cur.bytes > 0
 	}
-	prefixSize := sizeOfString(prefix)
+	prefixSize := limits.sizeOf(prefix)
 	return cur.bytes > prefixSize.bytes || cur.chars > prefixSize.chars || cur.tokens > prefixSize.tokens
 }
 
 func bundleParts(parts []string, limits ChunkLimits) []string {
 	bundles := []string{}
-	var cur strings.Builder
-	curSize := chunkSize{}
+	_ = eachBundle(parts, limits, func(bundle string) error {
+		bundles = append(bundles, bundle)
+		return nil
+	})
+	return bundles
+}
+
+// eachBundle groups parts into bundles within limits and hands each one to
+// emit as soon as it is complete.
+func eachBundle(parts []string, limits ChunkLimits, emit func(string) error) error {
+	b := bundler{limits: limits, emit: emit}
 	for _, part := range parts {
-		part = strings.TrimSpace(part)
-		if part == "" {
-			continue
-		}
-		if !strings.HasSuffix(part, "\n") {
-			part += "\n"
-		}
-		partSize := sizeOfString(part)
-		if curSize.bytes > 0 && limits.exceeds(curSize.add(partSize)) {
-			bundles = append(bundles, strings.TrimSpace(cur.String())+"\n")
-			cur.Reset()
-			curSize = chunkSize{}
-		}
-		if curSize.bytes == 0 && limits.exceeds(partSize) {
-			bundles = append(bundles, strings.TrimSpace(part)+"\n")
-			continue
+		if err := b.add(part); err != nil {
+			return err
 		}
-		cur.WriteString(part)
-		curSize = curSize.add(partSize)
-		if limits.exceeds(curSize) {
-			bundles = append(bundles, strings.TrimSpace(cur.String())+"\n")
-			cur.Reset()
-			curSize = chunkSize{}
+	}
+	return b.finish()
+}
+
+// bundler is eachBundle one part at a time, for parts that are not all in
+// memory at once.
+type bundler struct {
+	limits  ChunkLimits
+	emit    func(string) error
+	cur     strings.Builder
+	curSize chunkSize
+}
+
+func (b *bundler) add(part string) error {
+	part = strings.TrimSpace(part)
+	if part == "" {
+		return nil
+	}
+	if !strings.HasSuffix(part, "\n") {
+		part += "\n"
+	}
+	partSize := b.limits.sizeOf(part)
+	if b.curSize.bytes > 0 && b.limits.exceeds(b.curSize.add(partSize)) {
+		if err := b.flush(); err != nil {
+			return err
 		}
 	}
-	if strings.TrimSpace(cur.String()) != "" {
-		bundles = append(bundles, strings.TrimSpace(cur.String())+"\n")
+	if b.curSize.bytes == 0 && b.limits.exceeds(partSize) {
+		return b.emit(strings.TrimSpace(part) + "\n")
 	}
-	return bundles
+	b.cur.WriteString(part)
+	b.curSize = b.curSize.add(partSize)
+	if b.limits.exceeds(b.curSize) {
+		return b.flush()
+	}
+	return nil
+}
+
+// finish emits the last, partly filled bundle.
+func (b *bundler) finish() error {
+	if strings.TrimSpace(b.cur.String()) == "" {
+		return nil
+	}
+	return b.flush()
+}
+
+func (b *bundler) flush() error {
+	bundle := strings.TrimSpace(b.cur.String()) + "\n"
+	b.cur.Reset()
+	b.curSize = chunkSize{}
+	return b.emit(bundle)
+}
+
+// sectionFileName names a section file after its menu title. With a slug
+// strategy the name matches the heading anchor style; slugify still strips
+// characters that are unsafe in paths.
+func sectionFileName(title string, slugs *slug.Strategy) string {
+	if slugs == nil {
+		return slugify(title)
+	}
+	return slugify(slugs.Slug(title))
 }
 
 func slugify(s string) string {
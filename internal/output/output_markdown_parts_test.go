@@ -32,7 +32,7 @@ func TestWriteMarkdownParts_ProducesIndexAndParts(t *testing.T) {
 		t.Fatalf("index missing note: %s", string(idxData))
 	}
 
-	part1 := filepath.Join(dir, "content", "part-001.md")
+	part1 := filepath.Join(dir, "content", "part-001-a.md")
 	if _, err := os.Stat(part1); err != nil {
 		t.Fatalf("missing part file: %v", err)
 	}
@@ -66,3 +66,37 @@ func TestWriteMarkdownParts_NoSplitWritesFileOnly(t *testing.T) {
 		t.Fatalf("expected no content directory, got %v", err)
 	}
 }
+
+func TestWriteMarkdownParts_NamesPartsAfterHeadings(t *testing.T) {
+	dir := t.TempDir()
+	segments := []string{
+		"# Guide\n\n## Install\n\n" + strings.Repeat("a", 40) + "\n",
+		"## Install\n\n" + strings.Repeat("b", 40) + "\n",
+		"```sh\n# not a heading\n```\n\n" + strings.Repeat("c", 60) + "\n",
+	}
+
+	out, err := WriteMarkdownParts(dir, "content.md", segments, ChunkLimits{MaxBytes: 80})
+	if err != nil {
+		t.Fatalf("WriteMarkdownParts: %v", err)
+	}
+	for _, name := range []string{"part-001-guide.md", "part-002-install.md", "part-003-install.md"} {
+		if _, err := os.Stat(filepath.Join(dir, "content", name)); err != nil {
+			t.Fatalf("missing part %s: %v", name, err)
+		}
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("read index: %v", err)
+	}
+	index := string(data)
+	for _, want := range []string{
+		"- [part-001-guide.md](content/part-001-guide.md): [Guide](content/part-001-guide.md#guide)\n",
+		"- [part-002-install.md](content/part-002-install.md): Guide > [Install](content/part-002-install.md#install)\n",
+		"- [part-003-install.md](content/part-003-install.md): Guide > Install (continued)\n",
+	} {
+		if !strings.Contains(index, want) {
+			t.Fatalf("index missing %q:\n%s", want, index)
+		}
+	}
+}
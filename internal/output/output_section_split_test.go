@@ -35,10 +35,13 @@ func TestWriteSectionFiles_CreatesIndexAndParts(t *testing.T) {
 	if !strings.Contains(string(data), "Split into") {
 		t.Fatalf("index missing split info: %s", string(data))
 	}
+	if !strings.Contains(string(data), "Alpha Section > [Details](alpha-section/part-002-details.md#details)") {
+		t.Fatalf("index missing heading path and anchor: %s", string(data))
+	}
 
 	partDir := filepath.Join(base, "alpha-section")
-	part1 := filepath.Join(partDir, "part-001.md")
-	part2 := filepath.Join(partDir, "part-002.md")
+	part1 := filepath.Join(partDir, "part-001-alpha-section.md")
+	part2 := filepath.Join(partDir, "part-002-details.md")
 	if _, err := os.Stat(part1); err != nil {
 		t.Fatalf("expected part 1: %v", err)
 	}
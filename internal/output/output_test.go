@@ -1,6 +1,8 @@
 package output_test
 
 import (
+	"context"
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"strings"
@@ -10,6 +12,7 @@ import (
 	"go_scrap/internal/output"
 	"go_scrap/internal/parse"
 	"go_scrap/internal/report"
+	"go_scrap/internal/slug"
 )
 
 func TestWriteAllAndMenuAndSections(t *testing.T) {
@@ -50,6 +53,25 @@ func TestWriteAllAndMenuAndSections(t *testing.T) {
 	}
 }
 
+func TestWriteSectionFilesContext_UsesSlugStrategy(t *testing.T) {
+	dir := t.TempDir()
+	nodes := []menu.Node{{Title: "Getting Started: v1.2", Href: "#getting-started-v12", Anchor: "getting-started-v12"}}
+	mdByID := map[string]string{"getting-started-v12": "## Getting Started: v1.2\n\nx\n"}
+	mkdocs, err := slug.Lookup(slug.MkDocs, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := output.WriteSectionFilesContext(context.Background(), dir, nodes, mdByID, 0, output.ChunkLimits{}, output.TextEncoding{}, mkdocs); err != nil {
+		t.Fatalf("WriteSectionFilesContext error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sections", "getting-started-v12.md")); err != nil {
+		t.Fatalf("expected mkdocs-style section file name: %v", err)
+	}
+	if nodes[0].File != "sections/getting-started-v12.md" {
+		t.Fatalf("node file = %q", nodes[0].File)
+	}
+}
+
 func TestWriteSectionFiles_SplitsLargeMarkdown(t *testing.T) {
 	dir := t.TempDir()
 	nodes := []menu.Node{{Title: "API Index", Href: "#api_index", Anchor: "api_index"}}
@@ -69,7 +91,7 @@ func TestWriteSectionFiles_SplitsLargeMarkdown(t *testing.T) {
 		t.Fatalf("expected split index content, got: %s", string(indexData))
 	}
 
-	partPath := filepath.Join(dir, "sections", "api-index", "part-001.md")
+	partPath := filepath.Join(dir, "sections", "api-index", "part-001-api-index.md")
 	if _, err := os.Stat(partPath); err != nil {
 		t.Fatalf("missing split part file: %v", err)
 	}
@@ -90,8 +112,48 @@ func TestWriteMarkdownParts_BundlesBySection(t *testing.T) {
 	if _, err := os.Stat(mdPath); err != nil {
 		t.Fatalf("missing content index: %v", err)
 	}
-	partPath := filepath.Join(dir, "content", "part-001.md")
+	partPath := filepath.Join(dir, "content", "part-001-one.md")
 	if _, err := os.Stat(partPath); err != nil {
 		t.Fatalf("missing content part: %v", err)
 	}
 }
+
+func TestWriteJSON_SelectsSectionFields(t *testing.T) {
+	dir := t.TempDir()
+	doc := &parse.Document{Sections: []parse.Section{{HeadingText: "A", HeadingLevel: 1, HeadingID: "a", ContentHTML: "<p>x</p>", ContentText: "x"}}}
+
+	jsonPath, err := output.WriteJSON(doc, report.Report{}, output.WriteOptions{
+		OutputDir:       dir,
+		JSONFields:      []string{"heading_id", "content_html", "content_text"},
+		OmitContentText: true,
+	})
+	if err != nil {
+		t.Fatalf("WriteJSON error: %v", err)
+	}
+	data, err := os.ReadFile(jsonPath)
+	if err != nil {
+		t.Fatalf("read json: %v", err)
+	}
+	var payload struct {
+		Sections []map[string]any `json:"sections"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(payload.Sections) != 1 {
+		t.Fatalf("expected 1 section, got %d", len(payload.Sections))
+	}
+	sec := payload.Sections[0]
+	if len(sec) != 2 || sec["heading_id"] != "a" || sec["content_html"] != "<p>x</p>" {
+		t.Fatalf("unexpected section fields: %v", sec)
+	}
+}
+
+func TestValidateSectionFields(t *testing.T) {
+	if err := output.ValidateSectionFields([]string{"heading_id", "content_html"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := output.ValidateSectionFields([]string{"nope"}); err == nil {
+		t.Fatal("expected error for unknown field")
+	}
+}
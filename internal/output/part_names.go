@@ -0,0 +1,224 @@
+package output
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"go_scrap/internal/slug"
+)
+
+// maxPartNameSlug caps the heading slug in a part file name.
+const maxPartNameSlug = 48
+
+// maxIndexHeadings caps the top headings listed per part in a split index.
+const maxIndexHeadings = 5
+
+// PartHeading is a heading in a split part and its anchor in the part file.
+type PartHeading struct {
+	Level  int    `json:"level"`
+	Text   string `json:"text"`
+	Anchor string `json:"anchor,omitempty"`
+}
+
+// SplitPart describes one part file of a split document.
+type SplitPart struct {
+	// Name is the file name without extension, e.g. part-002-install.
+	Name string `json:"name"`
+	// File is the part's path relative to the index, with forward slashes.
+	File string `json:"file"`
+	// HeadingPath holds the headings enclosing the part's first top heading,
+	// or the heading it continues, across parts.
+	HeadingPath []string `json:"heading_path,omitempty"`
+	// Top are the part's top headings: those at the shallowest level it
+	// contains, in order.
+	Top []PartHeading `json:"top_headings,omitempty"`
+	// Continues is the heading a part without headings continues.
+	Continues string `json:"continues,omitempty"`
+	// Headings are all the headings in the part.
+	Headings []PartHeading `json:"headings"`
+	Bytes    int           `json:"bytes"`
+	Chars    int           `json:"chars"`
+	Tokens   int           `json:"tokens"`
+}
+
+// partNamer names consecutive parts of one document after their headings,
+// tracking the heading path across parts.
+type partNamer struct {
+	slugs *slug.Strategy
+	// dir is the part directory relative to the index.
+	dir string
+	// limits measures the parts.
+	limits ChunkLimits
+	// repeated is a heading repeated atop every part, which names only the
+	// first one.
+	repeated string
+	stack    []string
+	n        int
+}
+
+// next describes the part md, numbered after the previous one.
+func (p *partNamer) next(md string) SplitPart {
+	p.n++
+	size := p.limits.sizeOf(md)
+	part := SplitPart{Headings: []PartHeading{}, Bytes: size.bytes, Chars: size.chars, Tokens: size.tokens}
+	lines := markdownHeadings(md)
+	seen := map[string]struct{}{}
+	for _, h := range lines {
+		anchor := p.slugs.Unique(p.slugs.Slug(h.text), seen)
+		part.Headings = append(part.Headings, PartHeading{Level: h.level, Text: h.text, Anchor: anchor})
+	}
+	own := part.Headings
+	if p.n > 1 && p.repeated != "" && len(own) > 0 && own[0].Text == p.repeated {
+		own = own[1:]
+	}
+
+	if len(own) == 0 {
+		if path := trimEmpty(p.stack); len(path) > 0 {
+			part.HeadingPath = path[:len(path)-1]
+			part.Continues = path[len(path)-1]
+		}
+		return p.named(part, part.Continues)
+	}
+
+	top := own[0].Level
+	for _, h := range own {
+		top = min(top, h.Level)
+	}
+	for _, h := range own {
+		if h.Level == top {
+			if len(part.Top) == 0 {
+				part.HeadingPath = trimEmpty(p.stackAbove(h.Level))
+			}
+			part.Top = append(part.Top, h)
+		}
+		p.push(h.Level, h.Text)
+	}
+	return p.named(part, part.Top[0].Text)
+}
+
+func (p *partNamer) named(part SplitPart, heading string) SplitPart {
+	part.Name = partFileName(p.n, heading, p.slugs)
+	part.File = filepath.ToSlash(filepath.Join(p.dir, part.Name+".md"))
+	return part
+}
+
+// stackAbove is the current heading path above level.
+func (p *partNamer) stackAbove(level int) []string {
+	if level-1 < len(p.stack) {
+		return append([]string(nil), p.stack[:level-1]...)
+	}
+	return append([]string(nil), p.stack...)
+}
+
+func (p *partNamer) push(level int, text string) {
+	for len(p.stack) < level-1 {
+		p.stack = append(p.stack, "")
+	}
+	p.stack = append(p.stack[:level-1], text)
+}
+
+func trimEmpty(path []string) []string {
+	var out []string
+	for _, s := range path {
+		if s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// partFileName is part-NNN, followed by the slug of heading when there is
+// one.
+func partFileName(n int, heading string, slugs *slug.Strategy) string {
+	name := fmt.Sprintf("part-%03d", n)
+	s := sectionFileName(heading, slugs)
+	if len(s) > maxPartNameSlug {
+		cut := maxPartNameSlug
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		s = s[:cut]
+	}
+	if s = strings.Trim(s, "-_."); s != "" {
+		name += "-" + s
+	}
+	return name
+}
+
+type markdownHeading struct {
+	level int
+	text  string
+}
+
+// markdownHeadings returns the ATX headings of md outside fenced code.
+func markdownHeadings(md string) []markdownHeading {
+	var out []markdownHeading
+	fence := ""
+	for _, line := range strings.Split(md, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			continue
+		}
+		level := 0
+		for level < len(trimmed) && trimmed[level] == '#' {
+			level++
+		}
+		if level == 0 || level > 6 || (level < len(trimmed) && trimmed[level] != ' ') {
+			continue
+		}
+		text := strings.TrimSpace(strings.TrimRight(strings.TrimSpace(trimmed[level:]), "#"))
+		if text != "" {
+			out = append(out, markdownHeading{level: level, text: text})
+		}
+	}
+	return out
+}
+
+// buildSplitIndex lists the parts with the heading path leading to each and
+// links to its top headings.
+func buildSplitIndex(heading string, parts []SplitPart) string {
+	var b strings.Builder
+	if heading != "" {
+		b.WriteString(heading)
+		b.WriteString("\n\n")
+	}
+	b.WriteString(fmt.Sprintf("Split into %d parts:\n\n", len(parts)))
+	for _, part := range parts {
+		b.WriteString(fmt.Sprintf("- [%s](%s)", part.Name+".md", part.File))
+		var titles []string
+		switch {
+		case len(part.Top) > 0:
+			for i, h := range part.Top {
+				if i == maxIndexHeadings {
+					titles = append(titles, fmt.Sprintf("and %d more", len(part.Top)-i))
+					break
+				}
+				if h.Anchor == "" {
+					titles = append(titles, h.Text)
+					continue
+				}
+				titles = append(titles, fmt.Sprintf("[%s](%s#%s)", h.Text, part.File, h.Anchor))
+			}
+		case part.Continues != "":
+			titles = append(titles, part.Continues+" (continued)")
+		}
+		if len(titles) > 0 {
+			b.WriteString(": ")
+			if len(part.HeadingPath) > 0 {
+				b.WriteString(strings.Join(part.HeadingPath, " > ") + " > ")
+			}
+			b.WriteString(strings.Join(titles, ", "))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
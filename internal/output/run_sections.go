@@ -0,0 +1,76 @@
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go_scrap/internal/fsutil"
+)
+
+// RunSection is one section of a run's output, as index.jsonl lists it.
+type RunSection struct {
+	ID          string `json:"id"`
+	URL         string `json:"url"`
+	HeadingPath string `json:"heading_path"`
+	// Text is the section's Markdown, joined from its corpus.jsonl chunks,
+	// or its HTML from index.jsonl when the run has no corpus.
+	Text string `json:"-"`
+}
+
+// ReadRunSections reads the sections of the run written to outDir, single
+// page or crawl, in index order.
+func ReadRunSections(outDir string) ([]RunSection, error) {
+	var sections []RunSection
+	err := scanJSONLines(filepath.Join(outDir, "index.jsonl"), func(line []byte) {
+		var rec IndexRecord
+		if json.Unmarshal(line, &rec) == nil {
+			sections = append(sections, RunSection{ID: rec.ID, URL: rec.URL, HeadingPath: rec.HeadingPath, Text: rec.Content})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := map[string][]string{}
+	err = scanJSONLines(filepath.Join(outDir, "corpus.jsonl"), func(line []byte) {
+		var rec CorpusRecord
+		if json.Unmarshal(line, &rec) == nil && rec.Chunk > 0 && rec.Chunk <= rec.Chunks {
+			parts := chunks[rec.SectionID]
+			if parts == nil {
+				parts = make([]string, rec.Chunks)
+				chunks[rec.SectionID] = parts
+			}
+			if rec.Chunk <= len(parts) {
+				parts[rec.Chunk-1] = rec.Markdown
+			}
+		}
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for i, sec := range sections {
+		if parts, ok := chunks[sec.ID]; ok {
+			sections[i].Text = strings.Join(parts, "\n\n")
+		}
+	}
+	return sections, nil
+}
+
+// scanJSONLines calls fn with every line of the JSON Lines file at path.
+func scanJSONLines(path string, fn func([]byte)) error {
+	f, err := os.Open(fsutil.LongPath(path))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		fn(scanner.Bytes())
+	}
+	return scanner.Err()
+}
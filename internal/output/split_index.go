@@ -0,0 +1,82 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"go_scrap/internal/fsutil"
+)
+
+// PartsManifestName is the manifest written beside the parts of a split
+// document, in their directory.
+const PartsManifestName = "parts.json"
+
+// SplitIndex describes a document split into parts. It is the content of
+// parts.json and the data of a split index template.
+type SplitIndex struct {
+	// Index is the file name of the index, e.g. content.md.
+	Index string `json:"index"`
+	// Heading is the document's first heading line, e.g. "# Guide", and
+	// Title its text.
+	Heading string      `json:"heading,omitempty"`
+	Title   string      `json:"title,omitempty"`
+	Parts   []SplitPart `json:"parts"`
+}
+
+var splitIndexFuncs = template.FuncMap{"join": strings.Join}
+
+// LoadSplitIndexTemplate parses the text/template file at path, which renders
+// the index of a split document from a SplitIndex; an empty path returns nil.
+// Besides the builtins, templates may call join (strings.Join).
+func LoadSplitIndexTemplate(path string) (*template.Template, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := fsutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("split index template: %w", err)
+	}
+	tmpl, err := template.New(filepath.Base(path)).Funcs(splitIndexFuncs).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("split index template: %w", err)
+	}
+	// Catch references to missing fields now rather than on the first split.
+	sample := SplitIndex{Index: "content.md", Heading: "# Title", Title: "Title", Parts: []SplitPart{{
+		Name: "part-001-title", File: "content/part-001-title.md", HeadingPath: []string{},
+		Top: []PartHeading{{Level: 1, Text: "Title", Anchor: "title"}}, Headings: []PartHeading{{Level: 1, Text: "Title", Anchor: "title"}},
+	}}}
+	if err := tmpl.Execute(io.Discard, sample); err != nil {
+		return nil, fmt.Errorf("split index template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// writeSplitIndex writes the index of a document split into parts, rendered
+// with limits.IndexTemplate when set, and parts.json into partDir.
+func writeSplitIndex(indexPath, partDir, heading string, parts []SplitPart, limits ChunkLimits, enc TextEncoding) error {
+	idx := SplitIndex{Index: filepath.Base(indexPath), Heading: heading, Parts: parts}
+	if headings := markdownHeadings(heading); len(headings) > 0 {
+		idx.Title = headings[0].text
+	}
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := fsutil.WriteFile(filepath.Join(partDir, PartsManifestName), append(data, '\n'), 0600); err != nil {
+		return err
+	}
+
+	index := buildSplitIndex(heading, parts)
+	if limits.IndexTemplate != nil {
+		var b strings.Builder
+		if err := limits.IndexTemplate.Execute(&b, idx); err != nil {
+			return fmt.Errorf("split index template: %w", err)
+		}
+		index = b.String()
+	}
+	return enc.writeFile(indexPath, index)
+}
@@ -0,0 +1,82 @@
+package output
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteMarkdownParts_WritesPartsManifest(t *testing.T) {
+	dir := t.TempDir()
+	segments := []string{
+		"# Guide\n\n## Install\n\n" + strings.Repeat("a", 40) + "\n",
+		"## Usage\n\n### Flags\n\n" + strings.Repeat("b", 40) + "\n",
+	}
+	if _, err := WriteMarkdownParts(dir, "content.md", segments, ChunkLimits{MaxBytes: 80}); err != nil {
+		t.Fatalf("WriteMarkdownParts: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "content", PartsManifestName))
+	if err != nil {
+		t.Fatalf("read manifest: %v", err)
+	}
+	var idx SplitIndex
+	if err := json.Unmarshal(data, &idx); err != nil {
+		t.Fatalf("parse manifest: %v", err)
+	}
+	if idx.Index != "content.md" || idx.Title != "Guide" || len(idx.Parts) != 2 {
+		t.Fatalf("unexpected manifest %+v", idx)
+	}
+	usage := idx.Parts[1]
+	if usage.File != "content/part-002-usage.md" || strings.Join(usage.HeadingPath, " > ") != "Guide" {
+		t.Fatalf("unexpected part %+v", usage)
+	}
+	if len(usage.Headings) != 2 || usage.Headings[1] != (PartHeading{Level: 3, Text: "Flags", Anchor: "flags"}) {
+		t.Fatalf("unexpected headings %+v", usage.Headings)
+	}
+	if usage.Bytes != len(segments[1]) || usage.Tokens == 0 {
+		t.Fatalf("unexpected size %d bytes, %d tokens", usage.Bytes, usage.Tokens)
+	}
+}
+
+func TestWriteMarkdownParts_RendersIndexTemplate(t *testing.T) {
+	dir := t.TempDir()
+	tmplPath := filepath.Join(dir, "index.tmpl")
+	tmplText := "{{.Title}}\n{{range .Parts}}- {{.File}} {{join .HeadingPath \"/\"}}{{range .Top}} {{.Text}}#{{.Anchor}}{{end}}\n{{end}}"
+	if err := os.WriteFile(tmplPath, []byte(tmplText), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := LoadSplitIndexTemplate(tmplPath)
+	if err != nil {
+		t.Fatalf("LoadSplitIndexTemplate: %v", err)
+	}
+
+	segments := []string{"# A\n\n" + strings.Repeat("a", 40) + "\n", "## B\n\n" + strings.Repeat("b", 40) + "\n"}
+	out, err := WriteMarkdownParts(dir, "content.md", segments, ChunkLimits{MaxBytes: 60, IndexTemplate: tmpl})
+	if err != nil {
+		t.Fatalf("WriteMarkdownParts: %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("read index: %v", err)
+	}
+	want := "A\n- content/part-001-a.md  A#a\n- content/part-002-b.md A B#b\n"
+	if string(data) != want {
+		t.Fatalf("index = %q, want %q", data, want)
+	}
+}
+
+func TestLoadSplitIndexTemplate_RejectsUnknownFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.tmpl")
+	if err := os.WriteFile(path, []byte("{{range .Parts}}{{.Size}}{{end}}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSplitIndexTemplate(path); err == nil || !strings.Contains(err.Error(), "Size") {
+		t.Fatalf("expected an error naming the unknown field, got %v", err)
+	}
+	if tmpl, err := LoadSplitIndexTemplate(""); tmpl != nil || err != nil {
+		t.Fatalf("empty path should load nothing, got %v, %v", tmpl, err)
+	}
+}
@@ -0,0 +1,119 @@
+// Package pageclass flags fetched pages that returned 200 but are not real
+// content: soft 404s, login walls, and JavaScript-required shells.
+package pageclass
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Page classes recorded in the crawl index. A page that matches none of the
+// heuristics has the empty class.
+const (
+	Soft404    = "soft-404"
+	LoginWall  = "login-wall"
+	JSRequired = "js-required"
+)
+
+const (
+	// tinyChars is the visible text below which a page counts as an empty
+	// shell or stub.
+	tinyChars = 200
+	// smallChars bounds pages whose title or form marks them as an error or
+	// login page; longer pages are treated as real content about the topic.
+	smallChars = 1500
+)
+
+// Result is the classification of one page.
+type Result struct {
+	Class  string
+	Reason string
+}
+
+var (
+	notFoundRe   = regexp.MustCompile(`(?i)\b404\b|\bnot found\b|page (?:does not|doesn't|no longer) exists?|no longer available|page (?:is )?missing`)
+	loginTitleRe = regexp.MustCompile(`(?i)^(?:sign|log)[ -]?in\b|[|:–—-]\s*(?:sign|log)[ -]?in\s*$|\blogin required\b`)
+	loginURLRe   = regexp.MustCompile(`(?i)(?:^|[/?&=])(?:login|signin|sign-in|sso|auth)(?:$|[/?&=.])`)
+	jsRequiredRe = regexp.MustCompile(`(?i)(?:enable|turn on|activate) javascript|javascript (?:is )?(?:required|disabled|must be enabled)|requires javascript|without javascript|javascript to run this app`)
+	refreshURLRe = regexp.MustCompile(`(?i)url\s*=\s*['"]?([^'"\s;]+)`)
+)
+
+// Classify inspects a fetched page (before exclusions) and returns its class,
+// or an empty Result for ordinary content. Checks run from the most to the
+// least recoverable: a JS shell may render properly in a browser, so it wins
+// over the title-based checks.
+func Classify(doc *goquery.Document) Result {
+	if doc == nil {
+		return Result{}
+	}
+	title := strings.TrimSpace(doc.Find("title").First().Text())
+	h1 := strings.TrimSpace(doc.Find("h1").First().Text())
+	chars := VisibleChars(doc)
+	refresh := metaRefreshURL(doc)
+
+	if chars < tinyChars {
+		if msg := jsMessage(doc); msg != "" {
+			return Result{Class: JSRequired, Reason: fmt.Sprintf("%q with %d chars of content", msg, chars)}
+		}
+	}
+	if refresh != "" && loginURLRe.MatchString(refresh) {
+		return Result{Class: LoginWall, Reason: "meta refresh to " + refresh}
+	}
+	if chars < smallChars {
+		if doc.Find(`input[type="password" i]`).Length() > 0 {
+			return Result{Class: LoginWall, Reason: fmt.Sprintf("password form with %d chars of content", chars)}
+		}
+		if loginTitleRe.MatchString(title) {
+			return Result{Class: LoginWall, Reason: fmt.Sprintf("title %q", title)}
+		}
+		if notFoundRe.MatchString(title) {
+			return Result{Class: Soft404, Reason: fmt.Sprintf("title %q", title)}
+		}
+		if notFoundRe.MatchString(h1) {
+			return Result{Class: Soft404, Reason: fmt.Sprintf("heading %q", h1)}
+		}
+	}
+	if refresh != "" && chars < tinyChars {
+		return Result{Class: Soft404, Reason: "meta refresh to " + refresh + " with no content"}
+	}
+	return Result{}
+}
+
+// VisibleChars counts the body text a reader would see, ignoring scripts,
+// styles, templates and noscript fallbacks.
+func VisibleChars(doc *goquery.Document) int {
+	body := doc.Find("body").First().Clone()
+	body.Find("script, style, template, noscript").Remove()
+	return utf8.RuneCountInString(strings.Join(strings.Fields(body.Text()), " "))
+}
+
+// jsMessage returns the "please enable JavaScript" text on the page, if any.
+func jsMessage(doc *goquery.Document) string {
+	msg := ""
+	doc.Find("noscript, body").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		text := s.Text()
+		if goquery.NodeName(s) == "body" {
+			clone := s.Clone()
+			clone.Find("script, style, template").Remove()
+			text = clone.Text()
+		}
+		msg = jsRequiredRe.FindString(text)
+		return msg == ""
+	})
+	return msg
+}
+
+func metaRefreshURL(doc *goquery.Document) string {
+	content, ok := doc.Find(`meta[http-equiv="refresh" i]`).First().Attr("content")
+	if !ok {
+		return ""
+	}
+	if m := refreshURLRe.FindStringSubmatch(content); m != nil {
+		return m[1]
+	}
+	return ""
+}
@@ -0,0 +1,48 @@
+package pageclass
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func classify(t *testing.T, page string) Result {
+	t.Helper()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return Classify(doc)
+}
+
+func TestClassify(t *testing.T) {
+	long := strings.Repeat("Real documentation text about the API. ", 60)
+	cases := []struct {
+		name string
+		page string
+		want string
+	}{
+		{"content", `<html><head><title>Getting started</title></head><body><h1>Getting started</h1><p>` + long + `</p></body></html>`, ""},
+		{"soft 404 title", `<html><head><title>Page Not Found | Docs</title></head><body><p>Sorry.</p></body></html>`, Soft404},
+		{"soft 404 heading", `<html><head><title>Docs</title></head><body><h1>404</h1><p>This page does not exist.</p></body></html>`, Soft404},
+		{"long page about 404 errors", `<html><head><title>Handling 404 Not Found</title></head><body><p>` + long + `</p></body></html>`, ""},
+		{"password form", `<html><head><title>Acme</title></head><body><form><input name="user"><input type="password" name="pw"></form></body></html>`, LoginWall},
+		{"login title", `<html><head><title>Sign in - Acme</title></head><body><p>Continue with SSO.</p></body></html>`, LoginWall},
+		{"meta refresh to login", `<html><head><meta http-equiv="Refresh" content="0; url=/login?next=/docs"></head><body></body></html>`, LoginWall},
+		{"meta refresh stub", `<html><head><meta http-equiv="refresh" content="0;URL='https://example.com/new'"></head><body><p>Redirecting...</p></body></html>`, Soft404},
+		{"noscript shell", `<html><head><title>App</title></head><body><noscript>You need to enable JavaScript to run this app.</noscript><div id="root"></div><script>boot()</script></body></html>`, JSRequired},
+		{"js message with content", `<html><body><noscript>Please enable JavaScript</noscript><p>` + long + `</p></body></html>`, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := classify(t, tc.page)
+			if got.Class != tc.want {
+				t.Fatalf("class = %q (%s), want %q", got.Class, got.Reason, tc.want)
+			}
+			if got.Class != "" && got.Reason == "" {
+				t.Fatalf("class %q without a reason", got.Class)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package parse
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go_scrap/internal/byline"
+	"go_scrap/internal/dates"
+	"go_scrap/internal/slug"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// ItemSelectors drive ParseItems. Title, Body and Date are matched inside
+// each item.
+type ItemSelectors struct {
+	// Item matches the repeated elements, e.g. ".release" or "article.post".
+	Item string
+	// Title defaults to the first heading in the item.
+	Title string
+	// Body defaults to the whole item without its title.
+	Body string
+	// Date defaults to the first <time datetime> in the item; the datetime
+	// or content attribute of the match wins over its text.
+	Date string
+}
+
+// itemHeadingLevel is the level given to every item section so items stay
+// siblings in heading paths and the Markdown outline.
+const itemHeadingLevel = 2
+
+const headingSelector = "h1, h2, h3, h4, h5, h6"
+
+// ErrNoItems is returned when the item selector matches nothing.
+var ErrNoItems = errors.New("item selector matched nothing")
+
+// ParseItems turns each element matching sel.Item into one section, for
+// pages such as release notes and forum threads whose entries are not
+// separated by headings.
+func ParseItems(doc *goquery.Document, sel ItemSelectors, slugs *slug.Strategy) (*Document, error) {
+	if doc == nil {
+		return nil, errors.New("nil document")
+	}
+	if strings.TrimSpace(sel.Item) == "" {
+		return nil, errors.New("item selector is required")
+	}
+	titleSelector := strings.TrimSpace(sel.Title)
+	if titleSelector == "" {
+		titleSelector = headingSelector
+	}
+
+	allIDs, anchors, anchorsRaw := documentRefs(doc)
+	sections := []Section{}
+	headingIDSet := map[string]struct{}{}
+
+	items := doc.Find(sel.Item)
+	if items.Length() == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoItems, sel.Item)
+	}
+	items.Each(func(i int, item *goquery.Selection) {
+		title := item.Find(titleSelector).First()
+		headingText := strings.TrimSpace(title.Text())
+		if headingText == "" {
+			headingText = fmt.Sprintf("Item %d", i+1)
+		}
+		headingHTML := ""
+		if title.Length() > 0 {
+			headingHTML, _ = goquery.OuterHtml(title)
+		}
+
+		headingID := item.AttrOr("id", "")
+		if headingID == "" {
+			headingID = title.AttrOr("id", "")
+		}
+		if headingID == "" {
+			headingID = slugs.Slug(headingText)
+		}
+		headingID = slugs.Unique(headingID, headingIDSet)
+
+		var body *goquery.Selection
+		if strings.TrimSpace(sel.Body) != "" {
+			body = item.Find(sel.Body)
+		} else {
+			clone := item.Clone()
+			clone.Find(titleSelector).First().Remove()
+			body = clone.Contents()
+		}
+		contentHTML, contentText, contentIDs := renderSelection(body)
+
+		sections = append(sections, Section{
+			HeadingText:   headingText,
+			HeadingHTML:   headingHTML,
+			HeadingLevel:  itemHeadingLevel,
+			HeadingID:     headingID,
+			ContentHTML:   contentHTML,
+			ContentText:   strings.TrimSpace(contentText),
+			AnchorTargets: anchors,
+			Date:          itemDate(item, sel.Date),
+			Authors:       byline.Names(byline.FromSelection(item)),
+			ContentIDs:    contentIDs,
+		})
+	})
+
+	headingIDs := make([]string, 0, len(headingIDSet))
+	for id := range headingIDSet {
+		headingIDs = append(headingIDs, id)
+	}
+
+	htmlText, _ := doc.Html()
+	return &Document{
+		HTML:               htmlText,
+		Sections:           sections,
+		HeadingIDs:         headingIDs,
+		AnchorTargets:      anchors,
+		AllElementIDs:      allIDs,
+		AnchorTargetsByRaw: anchorsRaw,
+		Dates:              dates.FromDocument(doc),
+		Byline:             byline.FromDocument(doc),
+		Title:              PageTitle(doc),
+	}, nil
+}
+
+// itemDate reads the first match of selector in item, preferring the
+// machine-readable datetime (<time>) or content (<meta>) attribute.
+func itemDate(item *goquery.Selection, selector string) string {
+	if strings.TrimSpace(selector) == "" {
+		return sectionDate(item)
+	}
+	el := item.Find(selector).First()
+	if el.Length() == 0 {
+		return ""
+	}
+	for _, attr := range []string{"datetime", "content"} {
+		if v := strings.TrimSpace(el.AttrOr(attr, "")); v != "" {
+			return dates.Normalize(v)
+		}
+	}
+	return dates.Normalize(el.Text())
+}
@@ -2,10 +2,12 @@ package parse
 
 import (
 	"errors"
-	"regexp"
-	"strconv"
 	"strings"
 
+	"go_scrap/internal/byline"
+	"go_scrap/internal/dates"
+	"go_scrap/internal/slug"
+
 	"github.com/PuerkitoBio/goquery"
 )
 
@@ -17,7 +19,13 @@ type Section struct {
 	ContentHTML   string   `json:"content_html"`
 	ContentText   string   `json:"content_text"`
 	AnchorTargets []string `json:"anchor_targets"`
-	ContentIDs    []string `json:"-"`
+	// Date is when the section was published, from the item date selector
+	// or the first <time datetime> in the section ("" when undated).
+	Date string `json:"date,omitempty"`
+	// Authors are the section's own byline in item mode, or else the page's
+	// authors.
+	Authors    []string `json:"authors,omitempty"`
+	ContentIDs []string `json:"-"`
 }
 
 type Document struct {
@@ -27,6 +35,12 @@ type Document struct {
 	AnchorTargets      []string
 	AllElementIDs      []string
 	AnchorTargetsByRaw []string
+	// Dates are the page's publication and update dates.
+	Dates dates.Page
+	// Byline holds the page's authors and contributors.
+	Byline byline.Page
+	// Title is the page's <title>, or its first h1 when it has none.
+	Title string
 }
 
 func NewDocument(htmlText string) (*goquery.Document, error) {
@@ -36,6 +50,11 @@ func NewDocument(htmlText string) (*goquery.Document, error) {
 	return goquery.NewDocumentFromReader(strings.NewReader(htmlText))
 }
 
+// ExtractBySelector returns the first element matching selector as a
+// document of its own. A selector list ("a, b") is tried one selector at a
+// time, in order, so later selectors are fallbacks: "article .body, article"
+// picks .body whenever the page has one, although article comes first in
+// the document.
 func ExtractBySelector(doc *goquery.Document, selector string) (*goquery.Document, error) {
 	if doc == nil {
 		return nil, errors.New("nil document")
@@ -43,8 +62,13 @@ func ExtractBySelector(doc *goquery.Document, selector string) (*goquery.Documen
 	if strings.TrimSpace(selector) == "" {
 		return doc, nil
 	}
-	sel := doc.Find(selector).First()
-	if sel.Length() == 0 {
+	var sel *goquery.Selection
+	for _, part := range SplitSelectorList(selector) {
+		if sel = doc.Find(part).First(); sel.Length() > 0 {
+			break
+		}
+	}
+	if sel == nil || sel.Length() == 0 {
 		return nil, errors.New("selector not found: " + selector)
 	}
 	node := sel.Get(0)
@@ -54,27 +78,49 @@ func ExtractBySelector(doc *goquery.Document, selector string) (*goquery.Documen
 	return goquery.NewDocumentFromNode(node), nil
 }
 
+// SplitSelectorList splits a CSS selector list at its top-level commas,
+// leaving those inside parentheses, brackets and quotes alone.
+func SplitSelectorList(selector string) []string {
+	var parts []string
+	depth, start := 0, 0
+	var quote rune
+	for i, r := range selector {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '(' || r == '[':
+			depth++
+		case r == ')' || r == ']':
+			depth--
+		case r == ',' && depth == 0:
+			if part := strings.TrimSpace(selector[start:i]); part != "" {
+				parts = append(parts, part)
+			}
+			start = i + 1
+		}
+	}
+	if part := strings.TrimSpace(selector[start:]); part != "" {
+		parts = append(parts, part)
+	}
+	return parts
+}
+
 func Parse(doc *goquery.Document) (*Document, error) {
+	return ParseWithSlugger(doc, nil)
+}
+
+// ParseWithSlugger is Parse with heading IDs generated and disambiguated by
+// the given slug strategy (nil keeps the default underscore style).
+func ParseWithSlugger(doc *goquery.Document, slugs *slug.Strategy) (*Document, error) {
 	if doc == nil {
 		return nil, errors.New("nil document")
 	}
 
-	allIDs := []string{}
-	doc.Find("[id]").Each(func(_ int, s *goquery.Selection) {
-		if id, exists := s.Attr("id"); exists && id != "" {
-			allIDs = append(allIDs, id)
-		}
-	})
-
-	anchorsRaw := []string{}
-	anchors := []string{}
-	doc.Find("a[href]").Each(func(_ int, s *goquery.Selection) {
-		href, _ := s.Attr("href")
-		if strings.HasPrefix(href, "#") && len(href) > 1 {
-			anchorsRaw = append(anchorsRaw, href)
-			anchors = append(anchors, strings.TrimPrefix(href, "#"))
-		}
-	})
+	allIDs, anchors, anchorsRaw := documentRefs(doc)
 
 	sections := []Section{}
 	headingIDSet := map[string]struct{}{}
@@ -106,10 +152,10 @@ func Parse(doc *goquery.Document) (*Document, error) {
 		headingText := strings.TrimSpace(s.Text())
 		// 4. Generate Slug if needed
 		if headingID == "" {
-			headingID = slugifyHeading(headingText)
+			headingID = slugs.Slug(headingText)
 		}
 		// 5. Handle ID collisions by appending counter suffix
-		headingID = deduplicateID(headingID, headingIDSet)
+		headingID = slugs.Unique(headingID, headingIDSet)
 
 		headingHTML, _ := goquery.OuterHtml(s)
 
@@ -121,6 +167,7 @@ func Parse(doc *goquery.Document) (*Document, error) {
 			ContentHTML:   contentHTML,
 			ContentText:   strings.TrimSpace(contentText),
 			AnchorTargets: anchors,
+			Date:          sectionDate(contentSel),
 			ContentIDs:    contentIDs,
 		}
 		sections = append(sections, section)
@@ -139,33 +186,58 @@ func Parse(doc *goquery.Document) (*Document, error) {
 		AnchorTargets:      anchors,
 		AllElementIDs:      allIDs,
 		AnchorTargetsByRaw: anchorsRaw,
+		Dates:              dates.FromDocument(doc),
+		Byline:             byline.FromDocument(doc),
+		Title:              PageTitle(doc),
 	}, nil
 }
 
-var slugRegexp = regexp.MustCompile(`[^a-z0-9]+`)
+// PageTitle returns the text of doc's <title>, falling back to its first h1,
+// with runs of whitespace collapsed.
+func PageTitle(doc *goquery.Document) string {
+	title := strings.Join(strings.Fields(doc.Find("title").First().Text()), " ")
+	if title == "" {
+		title = strings.Join(strings.Fields(doc.Find("h1").First().Text()), " ")
+	}
+	return title
+}
 
-func slugifyHeading(text string) string {
-	text = strings.TrimSpace(strings.ToLower(text))
-	return strings.Trim(slugRegexp.ReplaceAllString(text, "_"), "_")
+// documentRefs lists every element ID and every in-page anchor link of doc,
+// the latter with and without the leading "#".
+func documentRefs(doc *goquery.Document) (allIDs, anchors, anchorsRaw []string) {
+	allIDs = []string{}
+	doc.Find("[id]").Each(func(_ int, s *goquery.Selection) {
+		if id, exists := s.Attr("id"); exists && id != "" {
+			allIDs = append(allIDs, id)
+		}
+	})
+
+	anchorsRaw = []string{}
+	anchors = []string{}
+	doc.Find("a[href]").Each(func(_ int, s *goquery.Selection) {
+		href, _ := s.Attr("href")
+		if strings.HasPrefix(href, "#") && len(href) > 1 {
+			anchorsRaw = append(anchorsRaw, href)
+			anchors = append(anchors, strings.TrimPrefix(href, "#"))
+		}
+	})
+	return allIDs, anchors, anchorsRaw
 }
 
-func deduplicateID(id string, seen map[string]struct{}) string {
-	if id == "" {
-		return ""
-	}
-	if _, exists := seen[id]; !exists {
-		seen[id] = struct{}{}
-		return id
-	}
-	counter := 2
-	for {
-		newID := id + "_" + strconv.Itoa(counter)
-		if _, exists := seen[newID]; !exists {
-			seen[newID] = struct{}{}
-			return newID
+// sectionDate is the first <time datetime> in sel, normalized.
+func sectionDate(sel *goquery.Selection) string {
+	date := ""
+	sel.EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		el := s
+		if !s.Is("time[datetime]") {
+			el = s.Find("time[datetime]").First()
 		}
-		counter++
-	}
+		if el.Length() > 0 {
+			date = dates.Normalize(el.AttrOr("datetime", ""))
+		}
+		return date == ""
+	})
+	return date
 }
 
 func headingLevelFromTag(tag string) int {
@@ -1,10 +1,12 @@
 package parse_test
 
 import (
+	"errors"
 	"strings"
 	"testing"
 
 	"go_scrap/internal/parse"
+	"go_scrap/internal/slug"
 )
 
 func TestExtractBySelector(t *testing.T) {
@@ -23,6 +25,27 @@ func TestExtractBySelector(t *testing.T) {
 	}
 }
 
+func TestExtractBySelector_TriesSelectorListInOrder(t *testing.T) {
+	doc, err := parse.NewDocument(`<article><nav>Breadcrumbs</nav><div class="theme-doc-markdown"><h1>Doc</h1></div></article>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := parse.ExtractBySelector(doc, "article .theme-doc-markdown, article")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if htmlOut, _ := out.Html(); strings.Contains(htmlOut, "Breadcrumbs") {
+		t.Fatalf("expected the inner container, got: %s", htmlOut)
+	}
+	fallback, _ := parse.NewDocument(`<article><h1>Plain</h1></article>`)
+	if _, err := parse.ExtractBySelector(fallback, "article .theme-doc-markdown, article"); err != nil {
+		t.Fatalf("expected the later selector as a fallback: %v", err)
+	}
+	if got := parse.SplitSelectorList(`a:is(.x, .y), [data-x="1,2"] , b`); strings.Join(got, "|") != `a:is(.x, .y)|[data-x="1,2"]|b` {
+		t.Fatalf("unexpected split %q", got)
+	}
+}
+
 func TestExtractBySelector_NotFound(t *testing.T) {
 	doc, err := parse.NewDocument("<div></div>")
 	if err != nil {
@@ -178,3 +201,70 @@ func TestParse_HeadingIDCollisionPrevention(t *testing.T) {
 		t.Errorf("expected third ID 'introduction_3', got %q", doc.Sections[2].HeadingID)
 	}
 }
+
+func TestParseWithSlugger_GitHubStyle(t *testing.T) {
+	docHTML, err := parse.NewDocument(`<body><h2>My Heading!</h2><p>x</p><h2>My Heading!</h2><p>y</p></body>`)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	github, err := slug.Lookup(slug.GitHub, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := parse.ParseWithSlugger(docHTML, github)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if len(doc.Sections) != 2 {
+		t.Fatalf("expected 2 sections, got %d", len(doc.Sections))
+	}
+	if doc.Sections[0].HeadingID != "my-heading" || doc.Sections[1].HeadingID != "my-heading-1" {
+		t.Fatalf("unexpected heading ids: %q, %q", doc.Sections[0].HeadingID, doc.Sections[1].HeadingID)
+	}
+}
+
+func TestParseItems_OneSectionPerItem(t *testing.T) {
+	html := `<body>
+	  <h1>Release notes</h1>
+	  <div class="release" id="v2">
+	    <h3>v2.0</h3><time datetime="2024-05-01">May 1</time><p>Breaking changes.</p>
+	  </div>
+	  <div class="release">
+	    <span class="name">v1.1</span><p class="notes">Fixes.</p><p>Ignored.</p>
+	  </div>
+	  <div class="release"><p>No title.</p></div>
+	</body>`
+	htmlDoc, err := parse.NewDocument(html)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := parse.ParseItems(htmlDoc, parse.ItemSelectors{Item: ".release", Title: "h3, .name", Date: "time"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Sections) != 3 {
+		t.Fatalf("expected 3 items, got %d", len(doc.Sections))
+	}
+	first := doc.Sections[0]
+	if first.HeadingText != "v2.0" || first.HeadingID != "v2" || first.Date != "2024-05-01" || first.HeadingLevel != 2 {
+		t.Fatalf("unexpected first item: %+v", first)
+	}
+	if strings.Contains(first.ContentHTML, "<h3") || !strings.Contains(first.ContentText, "Breaking changes.") {
+		t.Fatalf("expected the body without the title, got %q", first.ContentHTML)
+	}
+	if doc.Sections[1].HeadingText != "v1.1" || doc.Sections[2].HeadingText != "Item 3" {
+		t.Fatalf("unexpected titles: %q, %q", doc.Sections[1].HeadingText, doc.Sections[2].HeadingText)
+	}
+
+	withBody, err := parse.ParseItems(htmlDoc, parse.ItemSelectors{Item: ".release", Title: ".name", Body: ".notes"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := withBody.Sections[1].ContentText; got != "Fixes." {
+		t.Fatalf("expected only the body selector's content, got %q", got)
+	}
+
+	if _, err := parse.ParseItems(htmlDoc, parse.ItemSelectors{Item: ".missing"}, nil); !errors.Is(err, parse.ErrNoItems) {
+		t.Fatalf("expected ErrNoItems, got %v", err)
+	}
+}
@@ -0,0 +1,296 @@
+// Package policy loads an organization's limits on what go_scrap may fetch
+// and where it may write, and checks runs against them before they start.
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// PathEnv names a policy file applied on top of SystemPath. It can only add
+// limits: the system policy always applies.
+const PathEnv = "GO_SCRAP_POLICY"
+
+// Policy is the JSON policy file. Zero values impose no limit.
+type Policy struct {
+	// DeniedDomains are hosts that may not be fetched, including their
+	// subdomains ("example.com" also denies "docs.example.com").
+	DeniedDomains []string `json:"denied_domains,omitempty"`
+	// DeniedContentTypes are media types whose responses are rejected. An
+	// entry ending in "/" or "/*" denies the whole type ("image/*").
+	DeniedContentTypes []string `json:"denied_content_types,omitempty"`
+	// MaxPages caps --max-pages in crawl mode.
+	MaxPages int `json:"max_pages,omitempty"`
+	// MaxRateLimit caps --rate-limit in requests per second; a run must
+	// set a rate limit when this is set, except for a single page.
+	MaxRateLimit float64 `json:"max_rate_limit_per_second,omitempty"`
+	// DeniedOutputDirs are directories output may not be written in.
+	DeniedOutputDirs []string `json:"denied_output_dirs,omitempty"`
+
+	// Source is the file the policy was loaded from.
+	Source string `json:"-"`
+}
+
+// Error lists what a run does that its policy forbids.
+type Error struct {
+	Source     string
+	Violations []string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("policy violation (%s): %s", e.Source, strings.Join(e.Violations, "; "))
+}
+
+// SystemPath is where administrators install the policy that applies to
+// every user of the machine.
+func SystemPath() string {
+	if runtime.GOOS == "windows" {
+		base := os.Getenv("ProgramData")
+		if base == "" {
+			base = `C:\ProgramData`
+		}
+		return filepath.Join(base, "go_scrap", "policy.json")
+	}
+	return "/etc/go_scrap/policy.json"
+}
+
+// Find loads the policy at SystemPath and the file named by
+// $GO_SCRAP_POLICY, and returns the stricter combination of the two (see
+// Tighten), so the environment cannot lift the system's limits. It returns
+// nil when there is no policy; a policy file that cannot be read or parsed
+// is an error, so a broken policy never silently lifts its limits.
+func Find() (*Policy, error) {
+	var system *Policy
+	path := SystemPath()
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		p, err := Load(path)
+		if err != nil {
+			return nil, err
+		}
+		system = p
+	}
+	envPath := strings.TrimSpace(os.Getenv(PathEnv))
+	if envPath == "" {
+		return system, nil
+	}
+	user, err := Load(envPath)
+	if err != nil {
+		return nil, err
+	}
+	return system.Tighten(user), nil
+}
+
+// Tighten returns a policy enforcing the limits of both p and other: every
+// denied domain, content type and output directory of either, and the lower
+// of each cap. Either may be nil.
+func (p *Policy) Tighten(other *Policy) *Policy {
+	if p == nil {
+		return other
+	}
+	if other == nil {
+		return p
+	}
+	return &Policy{
+		DeniedDomains:      append(append([]string(nil), p.DeniedDomains...), other.DeniedDomains...),
+		DeniedContentTypes: append(append([]string(nil), p.DeniedContentTypes...), other.DeniedContentTypes...),
+		MaxPages:           lowerLimit(p.MaxPages, other.MaxPages),
+		MaxRateLimit:       lowerLimit(p.MaxRateLimit, other.MaxRateLimit),
+		DeniedOutputDirs:   append(append([]string(nil), p.DeniedOutputDirs...), other.DeniedOutputDirs...),
+		Source:             p.Source + " + " + other.Source,
+	}
+}
+
+// lowerLimit is the stricter of two caps, where 0 means no cap.
+func lowerLimit[T int | float64](a, b T) T {
+	switch {
+	case a <= 0:
+		return b
+	case b <= 0:
+		return a
+	default:
+		return min(a, b)
+	}
+}
+
+// Load reads the policy file at path.
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read policy: %w", err)
+	}
+	var p Policy
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// Unknown keys are errors: a misspelled limit would otherwise be ignored.
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("parse policy %s: %w", path, err)
+	}
+	if p.MaxPages < 0 || p.MaxRateLimit < 0 {
+		return nil, fmt.Errorf("parse policy %s: max_pages and max_rate_limit_per_second must not be negative", path)
+	}
+	p.Source = path
+	return &p, nil
+}
+
+// Run is what a run is about to do.
+type Run struct {
+	// URLs are the start, sitemap and --url-file URLs.
+	URLs  []string
+	Crawl bool
+	// MaxPages and RateLimit are the effective limits (0 rate = off).
+	MaxPages  int
+	RateLimit float64
+	OutputDir string
+}
+
+// Check returns an *Error listing every violation of p by r, or nil. A nil
+// *Policy allows everything.
+func (p *Policy) Check(r Run) error {
+	if p == nil {
+		return nil
+	}
+	var violations []string
+	for _, raw := range r.URLs {
+		if raw == "" {
+			continue
+		}
+		if !p.AllowsURL(raw) {
+			violations = append(violations, fmt.Sprintf("domain of %s is denied", raw))
+		}
+	}
+	if r.Crawl && p.MaxPages > 0 && r.MaxPages > p.MaxPages {
+		violations = append(violations, fmt.Sprintf("max pages %d exceeds the limit of %d", r.MaxPages, p.MaxPages))
+	}
+	if p.MaxRateLimit > 0 {
+		switch {
+		case r.RateLimit <= 0 && r.Crawl:
+			violations = append(violations, fmt.Sprintf("a rate limit of at most %g requests/second is required", p.MaxRateLimit))
+		case r.RateLimit > p.MaxRateLimit:
+			violations = append(violations, fmt.Sprintf("rate limit %g exceeds the limit of %g requests/second", r.RateLimit, p.MaxRateLimit))
+		}
+	}
+	if r.OutputDir != "" {
+		if dir, ok := p.deniedOutputDir(r.OutputDir); ok {
+			violations = append(violations, fmt.Sprintf("output directory %s is inside denied location %s", r.OutputDir, dir))
+		}
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	return &Error{Source: p.Source, Violations: violations}
+}
+
+// AllowsURL reports whether rawURL's host is not denied. Unparsable URLs
+// are left to the fetcher to reject.
+func (p *Policy) AllowsURL(rawURL string) bool {
+	if p == nil {
+		return true
+	}
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "https://" + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return true
+	}
+	return p.AllowsHost(u.Hostname())
+}
+
+// AllowsHost reports whether host is neither a denied domain nor one of its
+// subdomains.
+func (p *Policy) AllowsHost(host string) bool {
+	if p == nil {
+		return true
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, denied := range p.DeniedDomains {
+		denied = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(denied)), ".")
+		denied = strings.TrimPrefix(strings.TrimPrefix(denied, "*"), ".")
+		if denied == "" {
+			continue
+		}
+		if host == denied || strings.HasSuffix(host, "."+denied) {
+			return false
+		}
+	}
+	return true
+}
+
+// AllowsContentType reports whether a Content-Type header value is not
+// denied. An empty or malformed value is allowed.
+func (p *Policy) AllowsContentType(contentType string) bool {
+	if p == nil || strings.TrimSpace(contentType) == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return true
+	}
+	for _, denied := range p.DeniedContentTypes {
+		denied = strings.ToLower(strings.TrimSpace(denied))
+		denied = strings.TrimSuffix(denied, "*")
+		if denied == "" {
+			continue
+		}
+		if strings.HasSuffix(denied, "/") {
+			if strings.HasPrefix(mediaType, denied) {
+				return false
+			}
+		} else if mediaType == denied {
+			return false
+		}
+	}
+	return true
+}
+
+// deniedOutputDir returns the denied directory dir is in, if any.
+func (p *Policy) deniedOutputDir(dir string) (string, bool) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	for _, denied := range p.DeniedOutputDirs {
+		denied = expandHome(strings.TrimSpace(denied))
+		if denied == "" {
+			continue
+		}
+		deniedAbs, err := filepath.Abs(denied)
+		if err != nil {
+			continue
+		}
+		if within(abs, deniedAbs) {
+			return denied, true
+		}
+	}
+	return "", false
+}
+
+func within(path, dir string) bool {
+	if runtime.GOOS == "windows" {
+		path, dir = strings.ToLower(path), strings.ToLower(dir)
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, `~\`) {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
@@ -0,0 +1,127 @@
+package policy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoad_RejectsUnknownKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(path, []byte(`{"max_page": 10}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Fatal("expected a misspelled key to be rejected")
+	}
+}
+
+func TestFind_UsesPathEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(path, []byte(`{"denied_domains": ["example.com"]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(PathEnv, path)
+	p, err := Find()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p == nil || p.Source != path || len(p.DeniedDomains) != 1 {
+		t.Fatalf("expected the policy from %s, got %+v", path, p)
+	}
+
+	t.Setenv(PathEnv, filepath.Join(t.TempDir(), "missing.json"))
+	if _, err := Find(); err == nil {
+		t.Fatal("expected a missing policy named by the env var to be an error")
+	}
+}
+
+func TestAllowsHost_MatchesSubdomains(t *testing.T) {
+	p := &Policy{DeniedDomains: []string{"Example.com", "*.internal.test"}}
+	for host, want := range map[string]bool{
+		"example.com":       false,
+		"docs.example.com":  false,
+		"EXAMPLE.COM.":      false,
+		"notexample.com":    true,
+		"api.internal.test": false,
+		"internal.test":     false,
+		"other.org":         true,
+	} {
+		if got := p.AllowsHost(host); got != want {
+			t.Errorf("AllowsHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+	var none *Policy
+	if !none.AllowsHost("example.com") || !none.AllowsContentType("application/pdf") {
+		t.Fatal("expected a nil policy to allow everything")
+	}
+}
+
+func TestAllowsContentType(t *testing.T) {
+	p := &Policy{DeniedContentTypes: []string{"application/pdf", "image/*", "video/"}}
+	for ct, want := range map[string]bool{
+		"application/pdf":          false,
+		"Application/PDF; q=1":     false,
+		"image/png":                false,
+		"video/mp4":                false,
+		"text/html; charset=utf-8": true,
+		"application/pdfx":         true,
+		"":                         true,
+	} {
+		if got := p.AllowsContentType(ct); got != want {
+			t.Errorf("AllowsContentType(%q) = %v, want %v", ct, got, want)
+		}
+	}
+}
+
+func TestCheck_ListsEveryViolation(t *testing.T) {
+	denied := t.TempDir()
+	p := &Policy{
+		Source:           "policy.json",
+		DeniedDomains:    []string{"example.com"},
+		MaxPages:         50,
+		MaxRateLimit:     2,
+		DeniedOutputDirs: []string{denied},
+	}
+	err := p.Check(Run{
+		URLs:      []string{"https://docs.example.com/", ""},
+		Crawl:     true,
+		MaxPages:  100,
+		RateLimit: 5,
+		OutputDir: filepath.Join(denied, "out"),
+	})
+	var perr *Error
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected a policy error, got %v", err)
+	}
+	if len(perr.Violations) != 4 {
+		t.Fatalf("expected 4 violations, got %q", perr.Violations)
+	}
+	if !strings.HasPrefix(err.Error(), "policy violation (policy.json): ") {
+		t.Fatalf("unexpected message %q", err)
+	}
+
+	if err := p.Check(Run{URLs: []string{"https://other.org/"}, Crawl: true, MaxPages: 50, RateLimit: 2, OutputDir: denied + "-sibling"}); err != nil {
+		t.Fatalf("expected a compliant run to pass, got %v", err)
+	}
+	if err := p.Check(Run{URLs: []string{"https://other.org/"}, Crawl: true, MaxPages: 50}); err == nil {
+		t.Fatal("expected a crawl without a rate limit to violate max_rate_limit_per_second")
+	}
+	if err := p.Check(Run{URLs: []string{"https://other.org/"}, MaxPages: 500}); err != nil {
+		t.Fatalf("expected page and rate limits not to apply to a single page, got %v", err)
+	}
+}
+
+func TestTighten_KeepsTheStricterLimits(t *testing.T) {
+	system := &Policy{DeniedDomains: []string{"intranet.corp"}, MaxPages: 500, MaxRateLimit: 2, Source: "system"}
+	user := &Policy{DeniedDomains: []string{"example.com"}, MaxPages: 1000, MaxRateLimit: 1, Source: "user"}
+	p := system.Tighten(user)
+	if p.AllowsHost("intranet.corp") || p.AllowsHost("example.com") || p.MaxPages != 500 || p.MaxRateLimit != 1 || p.Source != "system + user" {
+		t.Fatalf("unexpected combined policy %+v", p)
+	}
+	if lifted := system.Tighten(&Policy{Source: "empty"}); lifted.AllowsHost("intranet.corp") || lifted.MaxPages != 500 {
+		t.Fatalf("an empty policy lifted the system limits: %+v", lifted)
+	}
+}
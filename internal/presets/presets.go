@@ -0,0 +1,215 @@
+package presets
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"go_scrap/internal/config"
+)
+
+// Auto asks the pipeline to detect the framework from the fetched page.
+const Auto = "auto"
+
+// BuiltinSource marks presets that ship with the binary.
+const BuiltinSource = "builtin"
+
+// Preset is a named site profile: selectors, fetch settings, and request
+// templates that work for a family of sites.
+type Preset struct {
+	Name               string  `json:"name"`
+	Description        string  `json:"description,omitempty"`
+	Mode               string  `json:"mode,omitempty"`
+	WaitFor            string  `json:"wait_for,omitempty"`
+	NavSelector        string  `json:"nav_selector,omitempty"`
+	ContentSelector    string  `json:"content_selector,omitempty"`
+	ExcludeSelector    string  `json:"exclude_selector,omitempty"`
+	RateLimitPerSecond float64 `json:"rate_limit_per_second,omitempty"`
+	// AuthHeaders are templates; ${VAR} references are expanded from the
+	// environment when the preset is applied.
+	AuthHeaders map[string]string `json:"auth_headers,omitempty"`
+	// Generator matches (case-insensitively) against <meta name="generator">.
+	Generator string `json:"generator,omitempty"`
+	// Fingerprints are CSS selectors that only this framework's markup matches.
+	Fingerprints []string `json:"fingerprints,omitempty"`
+	// Source is BuiltinSource or the file the preset was loaded from.
+	Source string `json:"-"`
+}
+
+//go:embed defaults/*.json
+var defaultsFS embed.FS
+
+var builtin = mustLoadBuiltin()
+
+func mustLoadBuiltin() []Preset {
+	entries, err := fs.Glob(defaultsFS, "defaults/*.json")
+	if err != nil {
+		panic(err)
+	}
+	out := make([]Preset, 0, len(entries))
+	for _, name := range entries {
+		data, err := defaultsFS.ReadFile(name)
+		if err != nil {
+			panic(err)
+		}
+		p, err := Parse(data)
+		if err != nil {
+			panic(fmt.Sprintf("embedded preset %s: %v", path.Base(name), err))
+		}
+		p.Source = BuiltinSource
+		out = append(out, p)
+	}
+	sortByName(out)
+	return out
+}
+
+// Parse decodes and validates a preset file.
+func Parse(data []byte) (Preset, error) {
+	var p Preset
+	if err := json.Unmarshal(data, &p); err != nil {
+		return Preset{}, err
+	}
+	p.Name = strings.ToLower(strings.TrimSpace(p.Name))
+	if p.Name == "" {
+		return Preset{}, errors.New("preset name is required")
+	}
+	if p.Name == Auto || strings.ContainsAny(p.Name, `/\ `) {
+		return Preset{}, fmt.Errorf("invalid preset name %q", p.Name)
+	}
+	return p, nil
+}
+
+// Builtin returns the bundled presets sorted by name.
+func Builtin() []Preset {
+	return append([]Preset(nil), builtin...)
+}
+
+// UserDir is where user presets live: $GO_SCRAP_PRESET_DIR, or presets
+// under config.UserDir (e.g. ~/.config/go_scrap/presets).
+func UserDir() (string, error) {
+	if dir := strings.TrimSpace(os.Getenv("GO_SCRAP_PRESET_DIR")); dir != "" {
+		return dir, nil
+	}
+	base, err := config.UserDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "presets"), nil
+}
+
+// LoadAll returns the bundled presets merged with user presets; a user preset
+// replaces a bundled one with the same name.
+func LoadAll() ([]Preset, error) {
+	dir, err := UserDir()
+	if err != nil {
+		return Builtin(), nil
+	}
+	return LoadDir(dir)
+}
+
+// LoadDir is LoadAll with user presets read from dir.
+func LoadDir(dir string) ([]Preset, error) {
+	byName := map[string]Preset{}
+	for _, p := range builtin {
+		byName[p.Name] = p
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		p, err := Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("preset %s: %w", file, err)
+		}
+		p.Source = file
+		byName[p.Name] = p
+	}
+
+	out := make([]Preset, 0, len(byName))
+	for _, p := range byName {
+		out = append(out, p)
+	}
+	sortByName(out)
+	return out, nil
+}
+
+// Find looks up a preset by name.
+func Find(list []Preset, name string) (Preset, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, p := range list {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Preset{}, false
+}
+
+// Names lists preset names in order.
+func Names(list []Preset) []string {
+	names := make([]string, 0, len(list))
+	for _, p := range list {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// ExpandedAuthHeaders returns AuthHeaders with ${VAR} references expanded,
+// dropping headers whose value expands to nothing.
+func (p Preset) ExpandedAuthHeaders() map[string]string {
+	if len(p.AuthHeaders) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(p.AuthHeaders))
+	for k, v := range p.AuthHeaders {
+		if expanded := strings.TrimSpace(os.ExpandEnv(v)); expanded != "" {
+			out[k] = expanded
+		}
+	}
+	return out
+}
+
+// Detect returns the first preset whose generator tag or DOM fingerprints
+// match doc.
+func Detect(doc *goquery.Document, candidates []Preset) (Preset, bool) {
+	if doc == nil {
+		return Preset{}, false
+	}
+	generator := strings.ToLower(doc.Find("meta[name='generator']").AttrOr("content", ""))
+	for _, p := range candidates {
+		if p.Generator != "" && strings.Contains(generator, strings.ToLower(p.Generator)) {
+			return p, true
+		}
+	}
+	for _, p := range candidates {
+		for _, fp := range p.Fingerprints {
+			if doc.Find(fp).Length() > 0 {
+				return p, true
+			}
+		}
+	}
+	return Preset{}, false
+}
+
+func sortByName(list []Preset) {
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+}
+
+// Marshal encodes a preset in the on-disk format.
+func Marshal(p Preset) ([]byte, error) {
+	return json.MarshalIndent(p, "", "  ")
+}
@@ -0,0 +1,60 @@
+package presets
+
+import (
+	"strings"
+	"testing"
+
+	"go_scrap/internal/parse"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func mustDoc(t *testing.T, html string) *goquery.Document {
+	t.Helper()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return doc
+}
+
+func TestDetect(t *testing.T) {
+	cases := map[string]string{
+		`<html><head><meta name="generator" content="Docusaurus v3.1.0"></head><body></body></html>`:    "docusaurus",
+		`<html><head><meta name="generator" content="mkdocs-1.5.3, mkdocs-material-9.4"></head></html>`: "mkdocs",
+		`<html><body><div class="sphinxsidebar"></div></body></html>`:                                   "sphinx",
+		`<html><body><nav class="rm-Sidebar"></nav></body></html>`:                                      "readme",
+	}
+	for html, want := range cases {
+		p, ok := Detect(mustDoc(t, html), Builtin())
+		if !ok || p.Name != want {
+			t.Fatalf("Detect(%s) = %q,%v; want %q", html, p.Name, ok, want)
+		}
+	}
+	if _, ok := Detect(mustDoc(t, `<html><body><main>plain</main></body></html>`), Builtin()); ok {
+		t.Fatal("expected no preset for plain html")
+	}
+}
+
+func TestFind(t *testing.T) {
+	if p, ok := Find(Builtin(), " MkDocs "); !ok || p.Name != "mkdocs" {
+		t.Fatalf("Find failed: %+v %v", p, ok)
+	}
+	if _, ok := Find(Builtin(), "nope"); ok {
+		t.Fatal("expected unknown preset")
+	}
+}
+
+func TestMkDocsContentSelector_ExtractsTheInnerContainer(t *testing.T) {
+	p, _ := Find(Builtin(), "mkdocs")
+	doc := mustDoc(t, `<html><body><div class="md-main" role="main"><nav class="md-sidebar">Navigation</nav>`+
+		`<div class="md-content"><article class="md-content__inner"><h1>Install</h1></article></div></div></body></html>`)
+	content, err := parse.ExtractBySelector(doc, p.ContentSelector)
+	if err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	if root := content.Selection; !root.Is("article.md-content__inner") {
+		html, _ := goquery.OuterHtml(root)
+		t.Fatalf("expected article.md-content__inner, got %s", html)
+	}
+}
@@ -0,0 +1,168 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// TinyChunkTokens is the size below which a chunk is flagged as suspiciously
+// small; such chunks usually carry a heading with no body.
+const TinyChunkTokens = 16
+
+// tokenBuckets are the upper bounds of the token histogram; the last bucket
+// is unbounded. Char buckets use the same bounds times four.
+var tokenBuckets = []int{32, 64, 128, 256, 512, 1024, 2048, 4096}
+
+// ChunkSize describes one Markdown chunk as it will be written.
+type ChunkSize struct {
+	HeadingPath string
+	Chunk       int
+	Bytes       int
+	Chars       int
+	Tokens      int
+}
+
+// ChunkLimits mirrors the configured --max-md-bytes/--max-chars/--max-tokens.
+type ChunkLimits struct {
+	MaxBytes  int
+	MaxChars  int
+	MaxTokens int
+}
+
+type ChunkReport struct {
+	Count     int         `json:"count"`
+	Tokens    Histogram   `json:"tokens"`
+	Chars     Histogram   `json:"chars"`
+	Oversized []ChunkFlag `json:"oversized"`
+	Tiny      []ChunkFlag `json:"tiny"`
+}
+
+type Histogram struct {
+	Min     int      `json:"min"`
+	Median  int      `json:"median"`
+	Max     int      `json:"max"`
+	Buckets []Bucket `json:"buckets"`
+}
+
+// Bucket counts values in [Min, Max]; Max is 0 for the open-ended last bucket.
+type Bucket struct {
+	Min   int `json:"min"`
+	Max   int `json:"max,omitempty"`
+	Count int `json:"count"`
+}
+
+type ChunkFlag struct {
+	HeadingPath string `json:"heading_path"`
+	Chunk       int    `json:"chunk"`
+	Chars       int    `json:"chars"`
+	Tokens      int    `json:"tokens"`
+	Reason      string `json:"reason"`
+}
+
+// AnalyzeChunks builds token/char histograms and flags chunks that exceed
+// limits (a single block too large to split) or fall under TinyChunkTokens.
+func AnalyzeChunks(chunks []ChunkSize, limits ChunkLimits) ChunkReport {
+	rep := ChunkReport{Count: len(chunks), Oversized: []ChunkFlag{}, Tiny: []ChunkFlag{}}
+	tokens := make([]int, 0, len(chunks))
+	chars := make([]int, 0, len(chunks))
+	for _, c := range chunks {
+		tokens = append(tokens, c.Tokens)
+		chars = append(chars, c.Chars)
+		flag := ChunkFlag{HeadingPath: c.HeadingPath, Chunk: c.Chunk, Chars: c.Chars, Tokens: c.Tokens}
+		if reason := exceededLimit(c, limits); reason != "" {
+			flag.Reason = reason
+			rep.Oversized = append(rep.Oversized, flag)
+		} else if c.Tokens < TinyChunkTokens {
+			flag.Reason = fmt.Sprintf("under %d tokens", TinyChunkTokens)
+			rep.Tiny = append(rep.Tiny, flag)
+		}
+	}
+	rep.Tokens = histogram(tokens, 1)
+	rep.Chars = histogram(chars, 4)
+	return rep
+}
+
+func exceededLimit(c ChunkSize, limits ChunkLimits) string {
+	switch {
+	case limits.MaxBytes > 0 && c.Bytes > limits.MaxBytes:
+		return fmt.Sprintf("%d bytes > max %d", c.Bytes, limits.MaxBytes)
+	case limits.MaxChars > 0 && c.Chars > limits.MaxChars:
+		return fmt.Sprintf("%d chars > max %d", c.Chars, limits.MaxChars)
+	case limits.MaxTokens > 0 && c.Tokens > limits.MaxTokens:
+		return fmt.Sprintf("%d tokens > max %d", c.Tokens, limits.MaxTokens)
+	}
+	return ""
+}
+
+func histogram(values []int, scale int) Histogram {
+	h := Histogram{Buckets: make([]Bucket, 0, len(tokenBuckets)+1)}
+	lower := 0
+	for _, upper := range tokenBuckets {
+		h.Buckets = append(h.Buckets, Bucket{Min: lower, Max: upper*scale - 1})
+		lower = upper * scale
+	}
+	h.Buckets = append(h.Buckets, Bucket{Min: lower})
+	if len(values) == 0 {
+		return h
+	}
+
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+	h.Min = sorted[0]
+	h.Max = sorted[len(sorted)-1]
+	h.Median = sorted[len(sorted)/2]
+	for _, v := range sorted {
+		for i := range h.Buckets {
+			if h.Buckets[i].Max == 0 || v <= h.Buckets[i].Max {
+				h.Buckets[i].Count++
+				break
+			}
+		}
+	}
+	return h
+}
+
+// Print writes the token histogram and flagged chunks in a compact form.
+func (r ChunkReport) Print(w io.Writer) {
+	if r.Count == 0 {
+		return
+	}
+	fmt.Fprintf(w, "Chunk sizes: %d chunk(s), tokens min %d / median %d / max %d\n", r.Count, r.Tokens.Min, r.Tokens.Median, r.Tokens.Max)
+	widest := 0
+	for _, b := range r.Tokens.Buckets {
+		widest = max(widest, b.Count)
+	}
+	for _, b := range r.Tokens.Buckets {
+		if b.Count == 0 {
+			continue
+		}
+		label := fmt.Sprintf("%d-%d", b.Min, b.Max)
+		if b.Max == 0 {
+			label = fmt.Sprintf("%d+", b.Min)
+		}
+		bar := strings.Repeat("#", max(1, b.Count*30/widest))
+		fmt.Fprintf(w, "  %10s tokens | %-30s %d\n", label, bar, b.Count)
+	}
+	if len(r.Oversized) > 0 {
+		fmt.Fprintf(w, "  over limit: %d\n", len(r.Oversized))
+		printFlags(w, r.Oversized)
+	}
+	if len(r.Tiny) > 0 {
+		fmt.Fprintf(w, "  tiny: %d\n", len(r.Tiny))
+		printFlags(w, r.Tiny)
+	}
+}
+
+const maxPrintedFlags = 5
+
+func printFlags(w io.Writer, flags []ChunkFlag) {
+	for i, f := range flags {
+		if i == maxPrintedFlags {
+			fmt.Fprintf(w, "    ... and %d more\n", len(flags)-i)
+			return
+		}
+		fmt.Fprintf(w, "    - %s (part %d): %s\n", f.HeadingPath, f.Chunk, f.Reason)
+	}
+}
@@ -0,0 +1,57 @@
+package report_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"go_scrap/internal/report"
+)
+
+func TestAnalyzeChunks_HistogramAndFlags(t *testing.T) {
+	chunks := []report.ChunkSize{
+		{HeadingPath: "A", Chunk: 1, Bytes: 40, Chars: 40, Tokens: 10},
+		{HeadingPath: "B", Chunk: 1, Bytes: 400, Chars: 400, Tokens: 100},
+		{HeadingPath: "B", Chunk: 2, Bytes: 2000, Chars: 2000, Tokens: 500},
+	}
+	rep := report.AnalyzeChunks(chunks, report.ChunkLimits{MaxTokens: 300})
+
+	if rep.Count != 3 || rep.Tokens.Min != 10 || rep.Tokens.Median != 100 || rep.Tokens.Max != 500 {
+		t.Fatalf("unexpected stats: %+v", rep.Tokens)
+	}
+	counts := map[int]int{}
+	for _, b := range rep.Tokens.Buckets {
+		counts[b.Min] = b.Count
+	}
+	if counts[0] != 1 || counts[64] != 1 || counts[256] != 1 {
+		t.Fatalf("unexpected buckets: %+v", rep.Tokens.Buckets)
+	}
+	if last := rep.Chars.Buckets[len(rep.Chars.Buckets)-1]; last.Max != 0 || last.Min != 4096*4 {
+		t.Fatalf("unexpected open-ended char bucket: %+v", last)
+	}
+	if len(rep.Oversized) != 1 || rep.Oversized[0].Chunk != 2 {
+		t.Fatalf("expected chunk B/2 over limit, got %+v", rep.Oversized)
+	}
+	if len(rep.Tiny) != 1 || rep.Tiny[0].HeadingPath != "A" {
+		t.Fatalf("expected chunk A flagged tiny, got %+v", rep.Tiny)
+	}
+
+	var buf bytes.Buffer
+	rep.Print(&buf)
+	out := buf.String()
+	if !strings.Contains(out, "3 chunk(s)") || !strings.Contains(out, "over limit: 1") || !strings.Contains(out, "tiny: 1") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+}
+
+func TestAnalyzeChunks_Empty(t *testing.T) {
+	rep := report.AnalyzeChunks(nil, report.ChunkLimits{})
+	if rep.Count != 0 || len(rep.Tokens.Buckets) == 0 {
+		t.Fatalf("unexpected empty report: %+v", rep)
+	}
+	var buf bytes.Buffer
+	rep.Print(&buf)
+	if buf.Len() != 0 {
+		t.Fatalf("expected no output, got %q", buf.String())
+	}
+}
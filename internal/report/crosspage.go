@@ -0,0 +1,115 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// PageAnchors holds what the crawl-wide anchor check needs from one page: the
+// element IDs it defines and the fragment links it contains.
+type PageAnchors struct {
+	URL   string
+	IDs   []string
+	Links []AnchorLink
+}
+
+// AnchorLink is a link with a fragment, resolved to an absolute URL.
+type AnchorLink struct {
+	Href     string
+	Target   string // absolute URL without the fragment
+	Fragment string
+}
+
+// BrokenAnchor is a fragment link whose target page was crawled but does not
+// define the fragment.
+type BrokenAnchor struct {
+	Page   string `json:"page"`
+	Href   string `json:"href"`
+	Target string `json:"target"`
+}
+
+// CrossPageReport summarizes fragment links checked against every crawled
+// page. Links to pages outside the crawl cannot be checked and are counted
+// as Unchecked rather than reported as broken.
+type CrossPageReport struct {
+	Pages     int            `json:"pages"`
+	Checked   int            `json:"checked"`
+	Resolved  int            `json:"resolved"`
+	Unchecked int            `json:"unchecked"`
+	Broken    []BrokenAnchor `json:"broken"`
+}
+
+// AnalyzeCrossPage resolves every page's fragment links against the IDs of
+// all crawled pages.
+func AnalyzeCrossPage(pages []PageAnchors) CrossPageReport {
+	ids := make(map[string]map[string]struct{}, len(pages))
+	for _, p := range pages {
+		set := make(map[string]struct{}, len(p.IDs))
+		for _, id := range p.IDs {
+			if id != "" {
+				set[id] = struct{}{}
+			}
+		}
+		ids[PageKey(p.URL)] = set
+	}
+
+	rep := CrossPageReport{Pages: len(pages), Broken: []BrokenAnchor{}}
+	for _, p := range pages {
+		for _, link := range p.Links {
+			if link.Fragment == "" {
+				continue
+			}
+			set, ok := ids[PageKey(link.Target)]
+			if !ok {
+				rep.Unchecked++
+				continue
+			}
+			rep.Checked++
+			if _, ok := set[link.Fragment]; ok {
+				rep.Resolved++
+				continue
+			}
+			rep.Broken = append(rep.Broken, BrokenAnchor{Page: p.URL, Href: link.Href, Target: link.Target + "#" + link.Fragment})
+		}
+	}
+	sort.Slice(rep.Broken, func(i, j int) bool {
+		if rep.Broken[i].Page != rep.Broken[j].Page {
+			return rep.Broken[i].Page < rep.Broken[j].Page
+		}
+		return rep.Broken[i].Href < rep.Broken[j].Href
+	})
+	return rep
+}
+
+// PageKey normalizes a page URL for matching links to crawled pages: the
+// fragment is dropped, scheme and host are lowercased, and a trailing slash
+// is ignored.
+func PageKey(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	key := u.String()
+	if len(key) > 1 && strings.HasSuffix(key, "/") && u.RawQuery == "" {
+		key = strings.TrimSuffix(key, "/")
+	}
+	return key
+}
+
+// Print writes a one-line summary and each broken anchor.
+func (r CrossPageReport) Print(w io.Writer) {
+	fmt.Fprintf(w, "Cross-page anchors: %d checked, %d broken, %d outside the crawl (not checked)\n", r.Checked, len(r.Broken), r.Unchecked)
+	for _, b := range r.Broken {
+		fmt.Fprintf(w, "  %s: %s\n", b.Page, b.Href)
+	}
+}
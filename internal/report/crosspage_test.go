@@ -0,0 +1,50 @@
+package report
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzeCrossPage(t *testing.T) {
+	pages := []PageAnchors{
+		{
+			URL: "https://example.com/docs/a",
+			IDs: []string{"intro"},
+			Links: []AnchorLink{
+				{Href: "#intro", Target: "https://example.com/docs/a", Fragment: "intro"},
+				{Href: "b#setup", Target: "https://example.com/docs/b", Fragment: "setup"},
+				{Href: "b#gone", Target: "https://example.com/docs/b", Fragment: "gone"},
+				{Href: "https://other.example/x#y", Target: "https://other.example/x", Fragment: "y"},
+			},
+		},
+		{
+			URL:   "https://EXAMPLE.com/docs/b/",
+			IDs:   []string{"setup"},
+			Links: []AnchorLink{{Href: "/docs/a#missing", Target: "https://example.com/docs/a", Fragment: "missing"}},
+		},
+	}
+	rep := AnalyzeCrossPage(pages)
+	if rep.Pages != 2 || rep.Checked != 4 || rep.Resolved != 2 || rep.Unchecked != 1 {
+		t.Fatalf("unexpected counts: %+v", rep)
+	}
+	want := []BrokenAnchor{
+		{Page: "https://EXAMPLE.com/docs/b/", Href: "/docs/a#missing", Target: "https://example.com/docs/a#missing"},
+		{Page: "https://example.com/docs/a", Href: "b#gone", Target: "https://example.com/docs/b#gone"},
+	}
+	if !reflect.DeepEqual(rep.Broken, want) {
+		t.Fatalf("broken = %+v", rep.Broken)
+	}
+}
+
+func TestPageKey(t *testing.T) {
+	cases := map[string]string{
+		"https://Example.com/docs/#x": "https://example.com/docs",
+		"https://example.com":         "https://example.com",
+		"https://example.com/?q=1":    "https://example.com/?q=1",
+	}
+	for in, want := range cases {
+		if got := PageKey(in); got != want {
+			t.Errorf("PageKey(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -12,6 +12,14 @@ type Report struct {
 	BrokenAnchors     []string `json:"broken_anchors"`
 	EmptySections     []string `json:"empty_sections"`
 	HeadingGaps       []string `json:"heading_gaps"`
+	// DroppedEmptySections, FilteredSections and DateFilteredSections count
+	// sections removed by --drop-empty-sections, --include/--exclude-headings
+	// and --since/--until.
+	DroppedEmptySections int `json:"dropped_empty_sections,omitempty"`
+	FilteredSections     int `json:"filtered_sections,omitempty"`
+	DateFilteredSections int `json:"date_filtered_sections,omitempty"`
+	// Chunks is filled in when outputs are written.
+	Chunks *ChunkReport `json:"chunks,omitempty"`
 }
 
 func Analyze(doc *parse.Document) Report {
@@ -0,0 +1,187 @@
+// Package sanitize strips active content from extracted HTML so content.json
+// and index records only carry markup that is safe to store and re-render.
+package sanitize
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	PolicyDefault = "default"
+	PolicyStrict  = "strict"
+	PolicyOff     = "off"
+)
+
+// Policy is an allowlist: elements outside Tags are unwrapped (their text is
+// kept), elements in dropTags are removed with their contents, and attributes
+// outside Attrs are removed.
+type Policy struct {
+	Tags  map[string]bool
+	Attrs map[string]bool
+}
+
+// dropTags never survive sanitizing, whatever the policy allows.
+var dropTags = set("script", "style", "noscript", "iframe", "frame", "frameset", "object", "embed",
+	"applet", "template", "svg", "math", "link", "meta", "base", "form", "input", "button",
+	"textarea", "select", "option", "canvas", "audio", "video")
+
+var defaultTags = set("a", "abbr", "article", "aside", "b", "blockquote", "br", "caption", "cite",
+	"code", "col", "colgroup", "dd", "del", "details", "dfn", "div", "dl", "dt", "em", "figcaption",
+	"figure", "footer", "h1", "h2", "h3", "h4", "h5", "h6", "header", "hr", "i", "img", "ins", "kbd",
+	"li", "main", "mark", "nav", "ol", "p", "picture", "pre", "q", "s", "samp", "section", "small",
+	"span", "strong", "sub", "summary", "sup", "table", "tbody", "td", "tfoot", "th", "thead", "time",
+	"tr", "u", "ul", "var")
+
+var strictTags = set("a", "b", "blockquote", "br", "code", "dd", "del", "dl", "dt", "em", "h1", "h2",
+	"h3", "h4", "h5", "h6", "hr", "i", "img", "li", "ol", "p", "pre", "strong", "table", "tbody",
+	"td", "th", "thead", "tr", "ul")
+
+// defaultAttrs keeps class so code blocks retain their language-* hint.
+var defaultAttrs = set("id", "class", "href", "src", "alt", "title", "colspan", "rowspan", "lang",
+	"dir", "width", "height", "start", "datetime", "name", "open")
+
+var strictAttrs = set("id", "href", "src", "alt", "title", "colspan", "rowspan")
+
+// Names lists the accepted policy names.
+func Names() []string {
+	return []string{PolicyDefault, PolicyStrict, PolicyOff}
+}
+
+// Lookup returns the policy called name, or nil for "off". An empty name
+// selects the default policy.
+func Lookup(name string) (*Policy, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", PolicyDefault:
+		return &Policy{Tags: defaultTags, Attrs: defaultAttrs}, nil
+	case PolicyStrict:
+		return &Policy{Tags: strictTags, Attrs: strictAttrs}, nil
+	case PolicyOff:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown sanitize policy %q (expected %s)", name, strings.Join(Names(), ", "))
+	}
+}
+
+// HTML sanitizes an HTML fragment. A nil policy returns the input unchanged.
+func (p *Policy) HTML(fragment string) string {
+	if p == nil || strings.TrimSpace(fragment) == "" {
+		return fragment
+	}
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), body)
+	if err != nil {
+		return html.EscapeString(fragment)
+	}
+	for _, n := range nodes {
+		body.AppendChild(n)
+	}
+	p.clean(body)
+
+	var b strings.Builder
+	for c := body.FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(&b, c); err != nil {
+			return html.EscapeString(fragment)
+		}
+	}
+	return b.String()
+}
+
+func (p *Policy) clean(parent *html.Node) {
+	for c := parent.FirstChild; c != nil; {
+		next := c.NextSibling
+		switch c.Type {
+		case html.CommentNode, html.DoctypeNode:
+			parent.RemoveChild(c)
+		case html.ElementNode:
+			tag := strings.ToLower(c.Data)
+			switch {
+			case dropTags[tag]:
+				parent.RemoveChild(c)
+			case !p.Tags[tag]:
+				// Unwrap: clean the children, then hoist them into parent.
+				p.clean(c)
+				for gc := c.FirstChild; gc != nil; {
+					gnext := gc.NextSibling
+					c.RemoveChild(gc)
+					parent.InsertBefore(gc, c)
+					gc = gnext
+				}
+				parent.RemoveChild(c)
+			default:
+				c.Attr = p.cleanAttrs(c.Attr)
+				if tag == "img" && isTrackingPixel(c) {
+					parent.RemoveChild(c)
+					break
+				}
+				p.clean(c)
+			}
+		}
+		c = next
+	}
+}
+
+func (p *Policy) cleanAttrs(attrs []html.Attribute) []html.Attribute {
+	out := attrs[:0]
+	for _, a := range attrs {
+		key := strings.ToLower(a.Key)
+		if a.Namespace != "" || !p.Attrs[key] {
+			continue
+		}
+		if (key == "href" || key == "src") && !safeURL(a.Val) {
+			continue
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+// safeURL rejects script-capable and inline data URLs. Browsers ignore
+// whitespace and control characters inside the scheme, so those are removed
+// before comparing.
+func safeURL(raw string) bool {
+	cleaned := strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(raw))
+	for _, scheme := range []string{"javascript:", "vbscript:", "data:"} {
+		if strings.HasPrefix(cleaned, scheme) {
+			return false
+		}
+	}
+	return true
+}
+
+// isTrackingPixel reports images without a usable src or sized 1x1 or
+// smaller.
+func isTrackingPixel(n *html.Node) bool {
+	var src, width, height string
+	for _, a := range n.Attr {
+		switch a.Key {
+		case "src":
+			src = a.Val
+		case "width":
+			width = strings.TrimSuffix(strings.TrimSpace(a.Val), "px")
+		case "height":
+			height = strings.TrimSuffix(strings.TrimSpace(a.Val), "px")
+		}
+	}
+	if strings.TrimSpace(src) == "" {
+		return true
+	}
+	tiny := func(v string) bool { return v == "0" || v == "1" }
+	return tiny(width) && tiny(height)
+}
+
+func set(values ...string) map[string]bool {
+	m := make(map[string]bool, len(values))
+	for _, v := range values {
+		m[v] = true
+	}
+	return m
+}
@@ -0,0 +1,53 @@
+package sanitize
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDefaultPolicy_StripsActiveContent(t *testing.T) {
+	p, err := Lookup("")
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	in := `<p onclick="steal()" style="color:red" id="x">Hi <a href=" javascript:alert(1)">link</a></p>` +
+		`<script>alert(1)</script><img src="data:image/png;base64,AAAA"><img src="/t.gif" width="1" height="1">` +
+		`<img src="/ok.png" alt="ok"><custom-el>kept text</custom-el><!-- note -->` +
+		`<pre><code class="language-go">x := 1</code></pre>`
+	got := p.HTML(in)
+
+	for _, bad := range []string{"onclick", "style=", "javascript", "<script", "alert", "data:", "t.gif", "custom-el", "note"} {
+		if strings.Contains(got, bad) {
+			t.Fatalf("expected %q removed, got %s", bad, got)
+		}
+	}
+	for _, want := range []string{`<p id="x">`, `<a>link</a>`, `src="/ok.png"`, "kept text", `class="language-go"`} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q kept, got %s", want, got)
+		}
+	}
+}
+
+func TestStrictPolicy_DropsClassesAndLayoutTags(t *testing.T) {
+	p, err := Lookup("strict")
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	got := p.HTML(`<div class="note"><span>text</span></div>`)
+	if got != "text" {
+		t.Fatalf("unexpected strict output: %q", got)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	p, err := Lookup("off")
+	if err != nil || p != nil {
+		t.Fatalf("expected nil policy for off, got %v, %v", p, err)
+	}
+	if got := p.HTML("<script>x</script>"); got != "<script>x</script>" {
+		t.Fatalf("nil policy should not modify input: %q", got)
+	}
+	if _, err := Lookup("bogus"); err == nil {
+		t.Fatal("expected error for unknown policy")
+	}
+}
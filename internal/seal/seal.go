@@ -0,0 +1,176 @@
+// Package seal encrypts files go_scrap keeps between runs (the --cache HTML
+// cache and crawl state) with AES-256-GCM, so authenticated pages do not sit
+// in plaintext on shared machines.
+package seal
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	// KeyEnv holds the key: 32 bytes, base64 or hex encoded
+	// (e.g. `openssl rand -base64 32`).
+	KeyEnv = "GO_SCRAP_CACHE_KEY"
+	// KeyCmdEnv is a command that prints the key, for keys kept in a
+	// keychain, e.g. `security find-generic-password -w -s go_scrap` or
+	// `secret-tool lookup service go_scrap`.
+	KeyCmdEnv = "GO_SCRAP_CACHE_KEY_CMD"
+)
+
+// magic starts every sealed file so plaintext written before encryption was
+// enabled is still recognized.
+var magic = []byte("GOSCRAPSEAL1\n")
+
+var ErrNoKey = errors.New("no encryption key: set " + KeyEnv + " or " + KeyCmdEnv)
+
+// keyCmdTimeout bounds KeyCmdEnv, which may wait on a keychain unlock prompt.
+const keyCmdTimeout = 30 * time.Second
+
+// Sealer encrypts and decrypts with one key. A nil *Sealer is valid and
+// passes data through unchanged.
+type Sealer struct {
+	aead cipher.AEAD
+}
+
+// New returns a Sealer for a 32-byte key.
+func New(key []byte) (*Sealer, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Sealer{aead: aead}, nil
+}
+
+// FromEnv builds a Sealer from $GO_SCRAP_CACHE_KEY, or from the output of
+// $GO_SCRAP_CACHE_KEY_CMD. It returns ErrNoKey when neither is set.
+func FromEnv(ctx context.Context) (*Sealer, error) {
+	encoded := strings.TrimSpace(os.Getenv(KeyEnv))
+	if encoded == "" {
+		command := strings.TrimSpace(os.Getenv(KeyCmdEnv))
+		if command == "" {
+			return nil, ErrNoKey
+		}
+		out, err := runKeyCommand(ctx, command)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", KeyCmdEnv, err)
+		}
+		encoded = strings.TrimSpace(string(out))
+	}
+	key, err := ParseKey(encoded)
+	if err != nil {
+		return nil, err
+	}
+	return New(key)
+}
+
+// ParseKey decodes a 32-byte key written as hex or (URL-safe or standard)
+// base64.
+func ParseKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if key, err := hex.DecodeString(s); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		if key, err := enc.DecodeString(s); err == nil && len(key) == 32 {
+			return key, nil
+		}
+	}
+	return nil, errors.New("encryption key must be 32 bytes encoded as base64 or hex")
+}
+
+func runKeyCommand(ctx context.Context, command string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, keyCmdTimeout)
+	defer cancel()
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Stderr = os.Stderr
+	return cmd.Output()
+}
+
+// Sealed reports whether data was written by Seal.
+func Sealed(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+// Seal encrypts plain under a fresh random nonce.
+func (s *Sealer) Seal(plain []byte) ([]byte, error) {
+	if s == nil {
+		return plain, nil
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(magic)+len(nonce)+len(plain)+s.aead.Overhead())
+	out = append(out, magic...)
+	out = append(out, nonce...)
+	return s.aead.Seal(out, nonce, plain, magic), nil
+}
+
+// Open decrypts data written by Seal. Data that is not sealed is returned
+// as is, so existing plaintext files keep working; sealed data without a
+// key or with the wrong key is an error.
+func (s *Sealer) Open(data []byte) ([]byte, error) {
+	if !Sealed(data) {
+		return data, nil
+	}
+	if s == nil {
+		return nil, ErrNoKey
+	}
+	body := data[len(magic):]
+	if len(body) < s.aead.NonceSize() {
+		return nil, errors.New("sealed data is truncated")
+	}
+	nonce, ciphertext := body[:s.aead.NonceSize()], body[s.aead.NonceSize():]
+	plain, err := s.aead.Open(nil, nonce, ciphertext, magic)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt: %w (wrong key?)", err)
+	}
+	return plain, nil
+}
+
+// SealString seals plain into a single line of text, for line-based files.
+func (s *Sealer) SealString(plain []byte) (string, error) {
+	if s == nil {
+		return string(plain), nil
+	}
+	sealed, err := s.Seal(plain)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// OpenString reverses SealString. Lines that are not base64 of sealed data
+// are returned as is.
+func (s *Sealer) OpenString(line []byte) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(string(line))
+	if err != nil || !Sealed(sealed) {
+		return line, nil
+	}
+	return s.Open(sealed)
+}
@@ -0,0 +1,79 @@
+package seal
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSealer_RoundTripsAndRejectsWrongKey(t *testing.T) {
+	s, err := New(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := []byte("<html>secret</html>")
+	sealed, err := s.Seal(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Sealed(sealed) || bytes.Contains(sealed, []byte("secret")) {
+		t.Fatalf("expected ciphertext, got %q", sealed)
+	}
+	got, err := s.Open(sealed)
+	if err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("round trip failed: %q %v", got, err)
+	}
+
+	other, _ := New(bytes.Repeat([]byte{2}, 32))
+	if _, err := other.Open(sealed); err == nil {
+		t.Fatal("expected the wrong key to fail")
+	}
+	var none *Sealer
+	if _, err := none.Open(sealed); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("expected ErrNoKey without a key, got %v", err)
+	}
+	if got, err := s.Open(plain); err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("expected plaintext to pass through, got %q %v", got, err)
+	}
+
+	line, err := s.SealString([]byte(`{"url":"https://example.com"}`))
+	if err != nil || strings.ContainsAny(line, "\n{") {
+		t.Fatalf("expected a single base64 line, got %q %v", line, err)
+	}
+	if got, err := s.OpenString([]byte(line)); err != nil || string(got) != `{"url":"https://example.com"}` {
+		t.Fatalf("line round trip failed: %q %v", got, err)
+	}
+}
+
+func TestFromEnv_ReadsKeyOrCommand(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32))
+	t.Setenv(KeyEnv, "")
+	t.Setenv(KeyCmdEnv, "")
+	if _, err := FromEnv(context.Background()); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("expected ErrNoKey, got %v", err)
+	}
+
+	t.Setenv(KeyCmdEnv, "echo "+key)
+	fromCmd, err := FromEnv(context.Background())
+	if err != nil {
+		t.Fatalf("key command: %v", err)
+	}
+	t.Setenv(KeyCmdEnv, "")
+	t.Setenv(KeyEnv, key)
+	fromEnv, err := FromEnv(context.Background())
+	if err != nil {
+		t.Fatalf("key env: %v", err)
+	}
+	sealed, _ := fromCmd.Seal([]byte("x"))
+	if got, err := fromEnv.Open(sealed); err != nil || string(got) != "x" {
+		t.Fatalf("expected both sources to yield the same key: %q %v", got, err)
+	}
+
+	t.Setenv(KeyEnv, "too-short")
+	if _, err := FromEnv(context.Background()); err == nil {
+		t.Fatal("expected an invalid key to fail")
+	}
+}
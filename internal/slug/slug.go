@@ -0,0 +1,132 @@
+// Package slug turns heading text into anchor IDs using the conventions of
+// common Markdown renderers, so generated links resolve where the output is
+// published.
+package slug
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	Default = "default"
+	GitHub  = "github"
+	MkDocs  = "mkdocs"
+	Custom  = "custom"
+)
+
+// Strategy generates heading slugs and disambiguates repeated ones. A nil
+// *Strategy behaves like Default.
+type Strategy struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+var defaultRegexp = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Lookup returns the named strategy. pattern is required for Custom: runs of
+// characters it matches in the lowercased text become "-".
+func Lookup(name, pattern string) (*Strategy, error) {
+	switch name {
+	case "", Default:
+		return nil, nil
+	case GitHub, MkDocs:
+		return &Strategy{name: name}, nil
+	case Custom:
+		if strings.TrimSpace(pattern) == "" {
+			return nil, fmt.Errorf("slug strategy %q requires a pattern", Custom)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid slug pattern: %w", err)
+		}
+		return &Strategy{name: Custom, pattern: re}, nil
+	}
+	return nil, fmt.Errorf("unknown slug strategy %q (expected default, github, mkdocs or custom)", name)
+}
+
+func (s *Strategy) Name() string {
+	if s == nil {
+		return Default
+	}
+	return s.name
+}
+
+// Slug converts heading text to an anchor ID.
+func (s *Strategy) Slug(text string) string {
+	text = strings.TrimSpace(text)
+	switch s.Name() {
+	case GitHub:
+		return githubSlug(text)
+	case MkDocs:
+		return mkdocsSlug(text)
+	case Custom:
+		return strings.Trim(s.pattern.ReplaceAllString(strings.ToLower(text), "-"), "-")
+	}
+	return strings.Trim(defaultRegexp.ReplaceAllString(strings.ToLower(text), "_"), "_")
+}
+
+// Unique returns id, or id with the strategy's counter suffix if it is
+// already in seen, and records the result. Empty IDs are returned as is.
+func (s *Strategy) Unique(id string, seen map[string]struct{}) string {
+	if id == "" {
+		return ""
+	}
+	if _, exists := seen[id]; !exists {
+		seen[id] = struct{}{}
+		return id
+	}
+	sep, counter := "_", 2
+	switch s.Name() {
+	case GitHub, Custom:
+		sep, counter = "-", 1
+	case MkDocs:
+		counter = 1
+	}
+	for {
+		candidate := id + sep + strconv.Itoa(counter)
+		if _, exists := seen[candidate]; !exists {
+			seen[candidate] = struct{}{}
+			return candidate
+		}
+		counter++
+	}
+}
+
+// githubSlug follows github-slugger: lowercase, drop punctuation and symbols,
+// and turn each space into "-" without collapsing runs.
+func githubSlug(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case r == ' ':
+			b.WriteByte('-')
+		case r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsMark(r):
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+var (
+	mkdocsStrip    = regexp.MustCompile(`[^\w\s-]`)
+	mkdocsCollapse = regexp.MustCompile(`[-\s]+`)
+)
+
+// mkdocsSlug follows Python-Markdown's toc slugify: fold to ASCII, drop
+// punctuation, lowercase, and collapse spaces and hyphens into one "-".
+func mkdocsSlug(text string) string {
+	var b strings.Builder
+	for _, r := range norm.NFKD.String(text) {
+		if r < unicode.MaxASCII {
+			b.WriteRune(r)
+		}
+	}
+	s := strings.ToLower(strings.TrimSpace(mkdocsStrip.ReplaceAllString(b.String(), "")))
+	return mkdocsCollapse.ReplaceAllString(s, "-")
+}
@@ -0,0 +1,69 @@
+package slug
+
+import "testing"
+
+func TestSlug(t *testing.T) {
+	custom, err := Lookup(Custom, `[^a-z0-9.]+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	github, _ := Lookup(GitHub, "")
+	mkdocs, _ := Lookup(MkDocs, "")
+	var def *Strategy
+
+	cases := []struct {
+		s    *Strategy
+		in   string
+		want string
+	}{
+		{def, "Getting Started: v1.2", "getting_started_v1_2"},
+		{github, "Getting Started: v1.2", "getting-started-v12"},
+		{github, "Foo -- Bar", "foo----bar"},
+		{github, "Café ünïcode", "café-ünïcode"},
+		{github, "🚀 Launch", "-launch"},
+		{mkdocs, "Getting Started: v1.2", "getting-started-v12"},
+		{mkdocs, "Foo -- Bar", "foo-bar"},
+		{mkdocs, "Café ünïcode", "cafe-unicode"},
+		{custom, "Getting Started: v1.2", "getting-started-v1.2"},
+	}
+	for _, tc := range cases {
+		if got := tc.s.Slug(tc.in); got != tc.want {
+			t.Errorf("%s.Slug(%q) = %q, want %q", tc.s.Name(), tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestUnique(t *testing.T) {
+	github, _ := Lookup(GitHub, "")
+	mkdocs, _ := Lookup(MkDocs, "")
+	var def *Strategy
+
+	cases := []struct {
+		s    *Strategy
+		want []string
+	}{
+		{def, []string{"intro", "intro_2", "intro_3"}},
+		{github, []string{"intro", "intro-1", "intro-2"}},
+		{mkdocs, []string{"intro", "intro_1", "intro_2"}},
+	}
+	for _, tc := range cases {
+		seen := map[string]struct{}{}
+		for i, want := range tc.want {
+			if got := tc.s.Unique("intro", seen); got != want {
+				t.Errorf("%s occurrence %d = %q, want %q", tc.s.Name(), i, got, want)
+			}
+		}
+	}
+}
+
+func TestLookup_Errors(t *testing.T) {
+	if _, err := Lookup("kebab", ""); err == nil {
+		t.Error("expected error for unknown strategy")
+	}
+	if _, err := Lookup(Custom, ""); err == nil {
+		t.Error("expected error for custom without pattern")
+	}
+	if _, err := Lookup(Custom, "("); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+}
@@ -0,0 +1,199 @@
+// Package stage is the on-disk format shared by the fetch and transform
+// subcommands: the raw HTML of every fetched page plus its metadata, so
+// selectors and converters can be iterated on without refetching.
+//
+// A staging directory holds stage.json and one html/<key>.html file per page
+// with content. stage.json is written last, so a directory without it is an
+// incomplete fetch.
+package stage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"go_scrap/internal/crawler"
+	"go_scrap/internal/fetch"
+	"go_scrap/internal/fsutil"
+	"go_scrap/internal/seal"
+)
+
+// Version is the staging format version; Read rejects other versions.
+const Version = 1
+
+// ManifestFile is the name of the manifest inside a staging directory.
+const ManifestFile = "stage.json"
+
+const htmlDir = "html"
+
+// Manifest describes a staged fetch.
+type Manifest struct {
+	Version int `json:"version"`
+	// URL and SitemapURL are what was fetched; Crawl is false for a single
+	// page.
+	URL        string    `json:"url,omitempty"`
+	SitemapURL string    `json:"sitemap_url,omitempty"`
+	Crawl      bool      `json:"crawl"`
+	CreatedAt  time.Time `json:"created_at"`
+	// Stats are the crawl's statistics, for the crawl index.
+	Stats crawler.Stats `json:"stats"`
+	Pages []Page        `json:"pages"`
+}
+
+// Page is one fetched page. File is relative to the staging directory and
+// empty when the fetch failed.
+type Page struct {
+	URL         string             `json:"url"`
+	File        string             `json:"file,omitempty"`
+	FetchedAt   time.Time          `json:"fetched_at"`
+	ContentHash string             `json:"content_hash,omitempty"`
+	FinalMode   fetch.Mode         `json:"final_mode,omitempty"`
+	SourceInfo  string             `json:"source_info,omitempty"`
+	Provenance  crawler.Provenance `json:"provenance"`
+	Error       string             `json:"error,omitempty"`
+}
+
+// WriteSingle stages one page fetched from pageURL.
+func WriteSingle(dir, pageURL string, result fetch.Result, fetchedAt time.Time, sealer *seal.Sealer) error {
+	if err := begin(dir); err != nil {
+		return err
+	}
+	m := Manifest{URL: pageURL, CreatedAt: time.Now().UTC()}
+	page := Page{URL: pageURL, FetchedAt: fetchedAt, FinalMode: result.FinalMode, SourceInfo: result.SourceInfo}
+	if err := writeHTML(dir, &page, result.HTML, sealer); err != nil {
+		return err
+	}
+	m.Pages = []Page{page}
+	return writeManifest(dir, m)
+}
+
+// WriteCrawl stages a crawl's results.
+func WriteCrawl(dir, baseURL, sitemapURL string, results map[string]*crawler.Result, stats crawler.Stats, sealer *seal.Sealer) error {
+	if err := begin(dir); err != nil {
+		return err
+	}
+	m := Manifest{URL: baseURL, SitemapURL: sitemapURL, Crawl: true, CreatedAt: time.Now().UTC(), Stats: stats}
+	urls := make([]string, 0, len(results))
+	for pageURL := range results {
+		urls = append(urls, pageURL)
+	}
+	sort.Strings(urls)
+	for _, pageURL := range urls {
+		r := results[pageURL]
+		page := Page{URL: pageURL}
+		if r != nil {
+			page.FetchedAt = r.FetchedAt
+			page.ContentHash = r.ContentHash
+			page.Provenance = r.Provenance
+			if r.Error != nil {
+				page.Error = r.Error.Error()
+			}
+			if err := writeHTML(dir, &page, r.HTML, sealer); err != nil {
+				return err
+			}
+		}
+		m.Pages = append(m.Pages, page)
+	}
+	return writeManifest(dir, m)
+}
+
+// Read loads the manifest of the staging directory dir.
+func Read(dir string) (Manifest, error) {
+	var m Manifest
+	data, err := fsutil.ReadFile(filepath.Join(dir, ManifestFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return m, fmt.Errorf("%s has no %s; run the fetch subcommand first", dir, ManifestFile)
+	}
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("read %s: %w", ManifestFile, err)
+	}
+	if m.Version != Version {
+		return m, fmt.Errorf("%s has staging format version %d, expected %d", dir, m.Version, Version)
+	}
+	return m, nil
+}
+
+// HTML returns the staged HTML of page, or "" when it has none.
+func HTML(dir string, page Page, sealer *seal.Sealer) (string, error) {
+	if page.File == "" {
+		return "", nil
+	}
+	data, err := fsutil.ReadFile(filepath.Join(dir, filepath.FromSlash(page.File)))
+	if err != nil {
+		return "", err
+	}
+	data, err = sealer.Open(data)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", page.File, err)
+	}
+	return string(data), nil
+}
+
+// Results loads a staged crawl as crawler results.
+func Results(dir string, m Manifest, sealer *seal.Sealer) (map[string]*crawler.Result, error) {
+	results := make(map[string]*crawler.Result, len(m.Pages))
+	for _, page := range m.Pages {
+		html, err := HTML(dir, page, sealer)
+		if err != nil {
+			return nil, err
+		}
+		r := &crawler.Result{
+			URL:         page.URL,
+			HTML:        html,
+			FetchedAt:   page.FetchedAt,
+			ContentHash: page.ContentHash,
+			Provenance:  page.Provenance,
+		}
+		if page.Error != "" {
+			r.Error = errors.New(page.Error)
+		}
+		results[page.URL] = r
+	}
+	return results, nil
+}
+
+// begin removes the manifest of an earlier fetch into dir, so a fetch that
+// fails halfway is not mistaken for a complete one.
+func begin(dir string) error {
+	if err := os.Remove(fsutil.LongPath(filepath.Join(dir, ManifestFile))); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func writeHTML(dir string, page *Page, html string, sealer *seal.Sealer) error {
+	if html == "" {
+		return nil
+	}
+	data, err := sealer.Seal([]byte(html))
+	if err != nil {
+		return err
+	}
+	if err := fsutil.MkdirAll(filepath.Join(dir, htmlDir), 0755); err != nil {
+		return err
+	}
+	sum := sha256.Sum256([]byte(page.URL))
+	page.File = htmlDir + "/" + hex.EncodeToString(sum[:12]) + ".html"
+	return fsutil.WriteFile(filepath.Join(dir, filepath.FromSlash(page.File)), data, 0600)
+}
+
+func writeManifest(dir string, m Manifest) error {
+	m.Version = Version
+	if err := fsutil.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFile(filepath.Join(dir, ManifestFile), append(data, '\n'), 0600)
+}
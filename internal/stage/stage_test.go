@@ -0,0 +1,47 @@
+package stage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go_scrap/internal/crawler"
+)
+
+func TestWriteCrawl_RoundTrips(t *testing.T) {
+	dir := t.TempDir()
+	fetchedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	results := map[string]*crawler.Result{
+		"https://example.com/b": {URL: "https://example.com/b", HTML: "<h1>B</h1>", FetchedAt: fetchedAt, Provenance: crawler.Provenance{HTTPStatus: 200}},
+		"https://example.com/a": {URL: "https://example.com/a", Error: errors.New("404 Not Found")},
+	}
+	if err := WriteCrawl(dir, "https://example.com", "", results, crawler.Stats{PagesCrawled: 1, PagesFailed: 1}, nil); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	m, err := Read(dir)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !m.Crawl || m.URL != "https://example.com" || m.Stats.PagesFailed != 1 || len(m.Pages) != 2 || m.Pages[0].URL != "https://example.com/a" {
+		t.Fatalf("unexpected manifest %+v", m)
+	}
+	got, err := Results(dir, m, nil)
+	if err != nil {
+		t.Fatalf("results: %v", err)
+	}
+	b := got["https://example.com/b"]
+	if b.HTML != "<h1>B</h1>" || !b.FetchedAt.Equal(fetchedAt) || b.Provenance.HTTPStatus != 200 {
+		t.Fatalf("unexpected staged page %+v", b)
+	}
+	if a := got["https://example.com/a"]; a.Error == nil || a.Error.Error() != "404 Not Found" || a.HTML != "" {
+		t.Fatalf("expected the failed page to keep its error, got %+v", a)
+	}
+}
+
+func TestRead_RequiresCompleteFetch(t *testing.T) {
+	if _, err := Read(t.TempDir()); err == nil || !strings.Contains(err.Error(), "run the fetch subcommand first") {
+		t.Fatalf("expected a missing manifest error, got %v", err)
+	}
+}
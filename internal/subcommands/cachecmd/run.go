@@ -0,0 +1,186 @@
+package cachecmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"go_scrap/internal/app"
+	"go_scrap/internal/cache"
+	"go_scrap/internal/footprint"
+)
+
+const usage = "usage: cache stats|list|clear|prune [--dir DIR] (list: [--ttl SECONDS]; clear: [--url URL]; prune: [--ttl SECONDS] [--max-mb N])"
+
+func Run(args []string) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	switch args[0] {
+	case "stats":
+		return runStats(os.Stdout, args[1:], time.Now())
+	case "list":
+		return runList(os.Stdout, args[1:], time.Now())
+	case "clear":
+		return runClear(os.Stdout, args[1:])
+	case "prune":
+		return runPrune(os.Stdout, args[1:], time.Now())
+	default:
+		return fmt.Errorf("unknown cache command %q (%s)", args[0], usage)
+	}
+}
+
+func newFlagSet(name string, dir *string) *flag.FlagSet {
+	fs := flag.NewFlagSet("cache "+name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(dir, "dir", cache.DefaultDir(), "Cache directory")
+	return fs
+}
+
+func runStats(w io.Writer, args []string, now time.Time) error {
+	var dir string
+	var list bool
+	fs := newFlagSet("stats", &dir)
+	fs.BoolVar(&list, "list", false, "List every entry, most recently used first")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	entries, err := cache.List(dir)
+	if err != nil {
+		return err
+	}
+	var total int64
+	var oldest, newest time.Time
+	for _, e := range entries {
+		total += e.Size
+		if oldest.IsZero() || e.FetchedAt.Before(oldest) {
+			oldest = e.FetchedAt
+		}
+		if e.FetchedAt.After(newest) {
+			newest = e.FetchedAt
+		}
+	}
+
+	fmt.Fprintf(w, "Cache: %s\n", dir)
+	fmt.Fprintf(w, "Entries: %d\n", len(entries))
+	fmt.Fprintf(w, "Size: %s\n", footprint.FormatBytes(total))
+	if len(entries) == 0 {
+		return nil
+	}
+	fmt.Fprintf(w, "Oldest fetch: %s ago\n", age(now, oldest))
+	fmt.Fprintf(w, "Newest fetch: %s ago\n", age(now, newest))
+	if list {
+		fmt.Fprintln(w)
+		printEntries(w, entries, 0, now)
+	}
+	return nil
+}
+
+func runList(w io.Writer, args []string, now time.Time) error {
+	var dir string
+	var ttl int
+	fs := newFlagSet("list", &dir)
+	fs.IntVar(&ttl, "ttl", 0, "Mark entries fetched more than this many seconds ago as expired")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	entries, err := cache.List(dir)
+	if err != nil {
+		return err
+	}
+	printEntries(w, entries, time.Duration(ttl)*time.Second, now)
+	return nil
+}
+
+// printEntries writes one line per entry: size, fetch mode, HTTP status,
+// ages, URL and ETag.
+func printEntries(w io.Writer, entries []cache.Entry, ttl time.Duration, now time.Time) {
+	for _, e := range entries {
+		url := e.URL
+		if url == "" {
+			url = "(no metadata) " + e.Path
+		}
+		var notes []string
+		if e.ETag != "" {
+			notes = append(notes, "etag "+e.ETag)
+		}
+		if e.Encrypted {
+			notes = append(notes, "encrypted")
+		}
+		if e.Expired(ttl, now) {
+			notes = append(notes, "expired")
+		}
+		if len(notes) > 0 {
+			url += " (" + strings.Join(notes, ", ") + ")"
+		}
+		mode := string(e.Mode)
+		if mode == "" {
+			mode = "-"
+		}
+		status := "-"
+		if e.Status != 0 {
+			status = fmt.Sprint(e.Status)
+		}
+		fmt.Fprintf(w, "%10s  %-8s %3s  fetched %s ago, used %s ago  %s\n", footprint.FormatBytes(e.Size), mode, status, age(now, e.FetchedAt), age(now, e.LastUsed), url)
+	}
+}
+
+func runClear(w io.Writer, args []string) error {
+	var dir, url string
+	fs := newFlagSet("clear", &dir)
+	fs.StringVar(&url, "url", "", "Remove only this page's entry")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	url = strings.TrimSpace(url)
+	removed, freed, err := cache.RemoveIf(dir, func(e cache.Entry) bool {
+		return url == "" || e.URL == url
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Removed %d entries (%s) from %s\n", removed, footprint.FormatBytes(freed), dir)
+	return nil
+}
+
+func runPrune(w io.Writer, args []string, now time.Time) error {
+	var dir string
+	var ttl, maxMB int
+	fs := newFlagSet("prune", &dir)
+	fs.IntVar(&ttl, "ttl", 0, "Remove entries fetched more than this many seconds ago (0 = keep)")
+	fs.IntVar(&maxMB, "max-mb", app.DefaultCacheMaxMB, "Then evict least recently used entries down to this size in MB (0 = unlimited)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if ttl < 0 || maxMB < 0 {
+		return errors.New("--ttl and --max-mb must not be negative")
+	}
+	expired, expiredBytes, err := cache.PruneExpired(dir, time.Duration(ttl)*time.Second, now)
+	if err != nil {
+		return err
+	}
+	evicted, evictedBytes, err := cache.Prune(dir, int64(maxMB)<<20)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Removed %d expired and %d least recently used entries (%s) from %s\n", expired, evicted, footprint.FormatBytes(expiredBytes+evictedBytes), dir)
+	return nil
+}
+
+// age rounds now-t to a readable precision.
+func age(now, t time.Time) time.Duration {
+	d := now.Sub(t)
+	switch {
+	case d < time.Minute:
+		return d.Round(time.Second)
+	case d < 24*time.Hour:
+		return d.Round(time.Minute)
+	default:
+		return d.Round(time.Hour)
+	}
+}
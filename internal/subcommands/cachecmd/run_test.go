@@ -0,0 +1,85 @@
+package cachecmd
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go_scrap/internal/cache"
+	"go_scrap/internal/fetch"
+)
+
+func TestRunStats_ReportsEntriesSizeAndAge(t *testing.T) {
+	dir := t.TempDir()
+	for _, u := range []string{"https://example.com/a", "https://example.com/b"} {
+		if err := cache.Save(cache.Path(dir, u), u, fetch.Result{HTML: "<p>hi</p>", FinalMode: fetch.ModeStatic}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A bare HTML file from before metadata was stored.
+	if err := os.WriteFile(cache.Path(dir, "https://example.com/old"), []byte("<p>old</p>"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := runStats(&out, []string{"--dir", dir, "--list"}, time.Now().Add(2*time.Hour)); err != nil {
+		t.Fatalf("runStats: %v", err)
+	}
+	got := out.String()
+	for _, want := range []string{"Entries: 3", "Oldest fetch: 2h0m0s ago", "static", "https://example.com/b", "(no metadata)"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q in output:\n%s", want, got)
+		}
+	}
+}
+
+func TestRunListClearPrune(t *testing.T) {
+	dir := t.TempDir()
+	for _, u := range []string{"https://example.com/a", "https://example.com/b", "https://example.com/c"} {
+		result := fetch.Result{HTML: "<p>hi</p>", FinalMode: fetch.ModeStatic, Status: 200, ETag: `"` + u[len(u)-1:] + `"`}
+		if err := cache.Save(cache.Path(dir, u), u, result, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := runList(&out, []string{"--dir", dir, "--ttl", "3600"}, time.Now().Add(2*time.Hour)); err != nil {
+		t.Fatalf("runList: %v", err)
+	}
+	for _, want := range []string{"200", `etag "a"`, "expired", "https://example.com/c"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q in list output:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	if err := runClear(&out, []string{"--dir", dir, "--url", "https://example.com/a"}); err != nil {
+		t.Fatalf("runClear: %v", err)
+	}
+	if entries, _ := cache.List(dir); len(entries) != 2 {
+		t.Fatalf("expected clear --url to remove one entry, %d left", len(entries))
+	}
+
+	out.Reset()
+	if err := runPrune(&out, []string{"--dir", dir, "--ttl", "60"}, time.Now()); err != nil {
+		t.Fatalf("runPrune: %v", err)
+	}
+	if entries, _ := cache.List(dir); len(entries) != 2 {
+		t.Fatalf("expected fresh entries to survive prune, %d left", len(entries))
+	}
+	if err := runPrune(&out, []string{"--dir", dir, "--ttl", "60"}, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("runPrune: %v", err)
+	}
+	if entries, _ := cache.List(dir); len(entries) != 0 {
+		t.Fatalf("expected expired entries pruned, %d left", len(entries))
+	}
+	if !strings.Contains(out.String(), "Removed 2 expired") {
+		t.Fatalf("unexpected prune output:\n%s", out.String())
+	}
+
+	if err := runClear(&out, []string{"--dir", dir}); err != nil {
+		t.Fatalf("runClear on an empty cache: %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package configcmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"go_scrap/internal/config"
+)
+
+func Run(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: config migrate [--dir DIR] [--dry-run] [FILE...]")
+	}
+	switch args[0] {
+	case "migrate":
+		return runMigrate(args[1:])
+	default:
+		return fmt.Errorf("unknown config command %q (available: migrate)", args[0])
+	}
+}
+
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("config migrate", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	var dir string
+	var dryRun bool
+	fs.StringVar(&dir, "dir", config.DefaultConfigDir, "Directory of config JSON files (ignored when files are given)")
+	fs.BoolVar(&dryRun, "dry-run", false, "Report changes without rewriting files")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		matches, err := filepath.Glob(filepath.Join(dir, "*.json"))
+		if err != nil {
+			return err
+		}
+		files = matches
+	}
+
+	failed := 0
+	for _, path := range files {
+		if err := migrateFile(path, dryRun); err != nil {
+			fmt.Printf("%s: FAILED (%v)\n", path, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d config file(s) could not be migrated", failed)
+	}
+	return nil
+}
+
+func migrateFile(path string, dryRun bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	migrated, warnings, err := config.Migrate(data)
+	if err != nil {
+		return err
+	}
+	for _, w := range warnings {
+		fmt.Printf("%s: %s\n", path, w)
+	}
+	if bytes.Equal(migrated, data) {
+		fmt.Printf("%s: up to date\n", path)
+		return nil
+	}
+	if dryRun {
+		fmt.Printf("%s: would rewrite\n", path)
+		return nil
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, migrated, "", "  "); err != nil {
+		return err
+	}
+	out.WriteByte('\n')
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, out.Bytes(), info.Mode().Perm()); err != nil {
+		return err
+	}
+	fmt.Printf("%s: migrated\n", path)
+	return nil
+}
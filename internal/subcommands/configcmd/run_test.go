@@ -0,0 +1,32 @@
+package configcmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go_scrap/internal/config"
+)
+
+func TestRunMigrate_RewritesDeprecatedKeys(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "site.json")
+	if err := os.WriteFile(path, []byte(`{"url":"https://example.com","wait_for_selector":"main"}`), 0600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	if err := Run([]string{"migrate", "--dir", dir}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	cfg, warnings, err := config.LoadWithWarnings(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Fatalf("expected no warnings after migration, got %v", warnings)
+	}
+	if cfg.WaitForSelector != "main" || cfg.URL != "https://example.com" {
+		t.Fatalf("unexpected migrated config: %+v", cfg)
+	}
+}
@@ -0,0 +1,161 @@
+package diffcmd
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"go_scrap/internal/output"
+	"go_scrap/internal/textdiff"
+)
+
+const usage = "usage: diff [--json] [--summary] [--context N] DIR_A DIR_B"
+
+// Report is how the sections of the run in From changed in the run in To.
+type Report struct {
+	From      string          `json:"from"`
+	To        string          `json:"to"`
+	Added     []SectionChange `json:"added"`
+	Removed   []SectionChange `json:"removed"`
+	Modified  []SectionChange `json:"modified"`
+	Unchanged int             `json:"unchanged"`
+}
+
+// SectionChange is an added, removed or modified section with its unified
+// diff, from /dev/null for added sections and to it for removed ones.
+type SectionChange struct {
+	output.RunSection
+	// Label is the heading path, prefixed with the page URL when the runs
+	// cover more than one page.
+	Label string `json:"label"`
+	Diff  string `json:"diff,omitempty"`
+}
+
+func Run(args []string) error {
+	return run(os.Stdout, args)
+}
+
+func run(w io.Writer, args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	asJSON := fs.Bool("json", false, "Print the report as JSON")
+	summary := fs.Bool("summary", false, "List changed sections without their text diffs")
+	context := fs.Int("context", 3, "Unchanged lines shown around each change")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%w (%s)", err, usage)
+	}
+	if fs.NArg() != 2 {
+		return errors.New(usage)
+	}
+	report, err := Compare(fs.Arg(0), fs.Arg(1), *context)
+	if err != nil {
+		return err
+	}
+	if *summary {
+		for _, list := range [][]SectionChange{report.Added, report.Removed, report.Modified} {
+			for i := range list {
+				list[i].Diff = ""
+			}
+		}
+	}
+	if *asJSON {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	}
+	printReport(w, report, *summary)
+	return nil
+}
+
+// Compare reads the index.jsonl (and corpus.jsonl, for Markdown) of the runs
+// in from and to and matches their sections by ID, which is derived from the
+// page URL and heading path and so stable across runs.
+func Compare(from, to string, context int) (Report, error) {
+	before, err := output.ReadRunSections(from)
+	if err != nil {
+		return Report{}, fmt.Errorf("read %s: %w", from, err)
+	}
+	after, err := output.ReadRunSections(to)
+	if err != nil {
+		return Report{}, fmt.Errorf("read %s: %w", to, err)
+	}
+	label := labeller(before, after)
+
+	report := Report{From: from, To: to, Added: []SectionChange{}, Removed: []SectionChange{}, Modified: []SectionChange{}}
+	old := make(map[string]output.RunSection, len(before))
+	for _, sec := range before {
+		old[sec.ID] = sec
+	}
+	kept := map[string]bool{}
+	for _, sec := range after {
+		prev, ok := old[sec.ID]
+		switch {
+		case !ok:
+			diff := textdiff.Unified("/dev/null", "b/"+label(sec), "", text(sec), context)
+			report.Added = append(report.Added, SectionChange{RunSection: sec, Label: label(sec), Diff: diff})
+		case prev.Text != sec.Text:
+			diff := textdiff.Unified("a/"+label(prev), "b/"+label(sec), text(prev), text(sec), context)
+			report.Modified = append(report.Modified, SectionChange{RunSection: sec, Label: label(sec), Diff: diff})
+		default:
+			report.Unchanged++
+		}
+		kept[sec.ID] = ok
+	}
+	for _, sec := range before {
+		if _, ok := kept[sec.ID]; !ok {
+			diff := textdiff.Unified("a/"+label(sec), "/dev/null", text(sec), "", context)
+			report.Removed = append(report.Removed, SectionChange{RunSection: sec, Label: label(sec), Diff: diff})
+		}
+	}
+	return report, nil
+}
+
+// text ends a section's text with a newline, so an empty section still
+// shows up as a changed line.
+func text(sec output.RunSection) string {
+	return sec.Text + "\n"
+}
+
+// labeller names sections by heading path, prefixed with the page URL when
+// the runs cover more than one page.
+func labeller(runs ...[]output.RunSection) func(output.RunSection) string {
+	pages := map[string]struct{}{}
+	for _, run := range runs {
+		for _, sec := range run {
+			pages[sec.URL] = struct{}{}
+		}
+	}
+	return func(sec output.RunSection) string {
+		path := sec.HeadingPath
+		if path == "" {
+			path = "(untitled " + sec.ID + ")"
+		}
+		if len(pages) > 1 {
+			return sec.URL + " > " + path
+		}
+		return path
+	}
+}
+
+func printReport(w io.Writer, r Report, summary bool) {
+	fmt.Fprintf(w, "Comparing %s -> %s: %d added, %d removed, %d modified, %d unchanged sections\n",
+		r.From, r.To, len(r.Added), len(r.Removed), len(r.Modified), r.Unchanged)
+	for _, group := range []struct {
+		marker  string
+		changes []SectionChange
+	}{{"+", r.Added}, {"-", r.Removed}, {"~", r.Modified}} {
+		for _, c := range group.changes {
+			if summary {
+				fmt.Fprintf(w, "  %s %s\n", group.marker, c.Label)
+				continue
+			}
+			fmt.Fprintf(w, "\n%s", c.Diff)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package diffcmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go_scrap/internal/output"
+)
+
+func writeRun(t *testing.T, sections map[string][]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	var index, corpus bytes.Buffer
+	enc, cenc := json.NewEncoder(&index), json.NewEncoder(&corpus)
+	for _, id := range []string{"intro", "install", "usage", "faq"} {
+		chunks, ok := sections[id]
+		if !ok {
+			continue
+		}
+		if err := enc.Encode(output.IndexRecord{ID: id, URL: "https://example.com/docs", HeadingPath: "Docs > " + id, Content: "<p>html</p>"}); err != nil {
+			t.Fatal(err)
+		}
+		for i, md := range chunks {
+			rec := output.CorpusRecord{SectionID: id, URL: "https://example.com/docs", Chunk: i + 1, Chunks: len(chunks), Markdown: md}
+			if err := cenc.Encode(rec); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "index.jsonl"), index.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "corpus.jsonl"), corpus.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestRun_ReportsAddedRemovedAndModifiedSections(t *testing.T) {
+	a := writeRun(t, map[string][]string{
+		"intro":   {"Welcome."},
+		"install": {"Run:", "go install example.com/tool@v1"},
+		"faq":     {"Ask us."},
+	})
+	b := writeRun(t, map[string][]string{
+		"intro":   {"Welcome."},
+		"install": {"Run:", "go install example.com/tool@v2"},
+		"usage":   {"tool run"},
+	})
+
+	var out bytes.Buffer
+	if err := run(&out, []string{a, b}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	got := out.String()
+	for _, want := range []string{
+		"1 added, 1 removed, 1 modified, 1 unchanged sections",
+		"--- /dev/null\n+++ b/Docs > usage\n@@ -0,0 +1 @@\n+tool run\n",
+		"--- a/Docs > faq\n+++ /dev/null\n",
+		"--- a/Docs > install\n+++ b/Docs > install\n@@ -1,3 +1,3 @@\n Run:\n \n-go install example.com/tool@v1\n+go install example.com/tool@v2\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q in output:\n%s", want, got)
+		}
+	}
+
+	out.Reset()
+	if err := run(&out, []string{"--summary", a, b}); err != nil {
+		t.Fatalf("run --summary: %v", err)
+	}
+	if got := out.String(); !strings.Contains(got, "  + Docs > usage\n  - Docs > faq\n  ~ Docs > install\n") || strings.Contains(got, "@@") {
+		t.Fatalf("unexpected summary output:\n%s", got)
+	}
+
+	out.Reset()
+	if err := run(&out, []string{"--json", a, b}); err != nil {
+		t.Fatalf("run --json: %v", err)
+	}
+	var report Report
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("decode report: %v", err)
+	}
+	if len(report.Modified) != 1 || report.Modified[0].ID != "install" || !strings.Contains(report.Modified[0].Diff, "+go install example.com/tool@v2") {
+		t.Fatalf("unexpected modified sections: %+v", report.Modified)
+	}
+}
+
+func TestRun_RequiresTwoDirectories(t *testing.T) {
+	if err := run(&bytes.Buffer{}, []string{t.TempDir()}); err == nil || !strings.Contains(err.Error(), "usage") {
+		t.Fatalf("expected usage error, got %v", err)
+	}
+	if err := run(&bytes.Buffer{}, []string{t.TempDir(), t.TempDir()}); err == nil || !strings.Contains(err.Error(), "index.jsonl") {
+		t.Fatalf("expected missing index error, got %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package inspect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"go_scrap/internal/app"
+	"go_scrap/internal/config"
+	"go_scrap/internal/fetch"
+)
+
+// probeStatic fetches the page without a browser so the emitted mode reflects
+// whether static HTML is usable.
+var probeStatic = func(ctx context.Context, url string, timeout time.Duration) (string, error) {
+	res, err := fetch.Fetch(ctx, fetch.Options{
+		URL:       url,
+		Mode:      fetch.ModeStatic,
+		Timeout:   timeout,
+		UserAgent: app.DefaultUserAgent,
+	})
+	return res.HTML, err
+}
+
+func emitStarterConfig(ctx context.Context, opts options, doc *goquery.Document) error {
+	if _, err := os.Stat(opts.EmitConfig); err == nil {
+		return fmt.Errorf("%s already exists", opts.EmitConfig)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	staticHTML, err := probeStatic(ctx, opts.URL, time.Duration(opts.TimeoutSec)*time.Second)
+	dynamic := err != nil || fetch.LooksDynamic(staticHTML)
+
+	cfg := starterConfig(opts.URL, doc, dynamic)
+	if dynamic {
+		cfg.Browser = opts.Browser
+	}
+	data, err := config.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(opts.EmitConfig); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(opts.EmitConfig, data, 0600); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote starter config: %s (mode=%s nav=%s content=%s)\n", opts.EmitConfig, cfg.Mode, orNone(cfg.NavSelector), orNone(cfg.ContentSelector))
+	return nil
+}
+
+func starterConfig(url string, doc *goquery.Document, dynamic bool) config.Config {
+	chosen := chooseSelectors(collectCandidates(doc))
+
+	var excludes []string
+	for _, c := range findExcludeCandidates(doc) {
+		if c.Starter {
+			excludes = append(excludes, c.Selector)
+		}
+	}
+
+	mode := string(fetch.ModeStatic)
+	waitFor := "body"
+	if dynamic {
+		mode = string(fetch.ModeDynamic)
+		if chosen.Content != "" {
+			waitFor = chosen.Content
+		}
+	}
+	headless := true
+	return config.Config{
+		URL:             url,
+		Mode:            mode,
+		TimeoutSeconds:  app.DefaultTimeoutSeconds,
+		UserAgent:       app.DefaultUserAgent,
+		WaitForSelector: waitFor,
+		Headless:        &headless,
+		NavSelector:     chosen.Nav,
+		ContentSelector: chosen.Content,
+		ExcludeSelector: combineSelectors(excludes),
+	}
+}
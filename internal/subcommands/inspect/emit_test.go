@@ -0,0 +1,53 @@
+package inspect
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"go_scrap/internal/config"
+)
+
+func TestEmitStarterConfig(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<html><body>
+<nav><a href="#a">A</a></nav>
+<main><h1 id="a">A</h1><p>Body</p></main>
+<div class="cookie-notice">cookies</div>
+<iframe src="x"></iframe>
+</body></html>`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	orig := probeStatic
+	probeStatic = func(context.Context, string, time.Duration) (string, error) { return "<div id=\"root\"></div>", nil }
+	defer func() { probeStatic = orig }()
+
+	path := filepath.Join(t.TempDir(), "site.json")
+	opts := options{URL: "https://example.com", EmitConfig: path, TimeoutSec: 1}
+	if err := emitStarterConfig(context.Background(), opts, doc); err != nil {
+		t.Fatalf("emitStarterConfig: %v", err)
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Mode != "dynamic" || cfg.WaitForSelector != "main" {
+		t.Fatalf("expected dynamic mode waiting for main: %+v", cfg)
+	}
+	if cfg.NavSelector != "nav" || cfg.ContentSelector != "main" {
+		t.Fatalf("unexpected selectors: %+v", cfg)
+	}
+	if !strings.Contains(cfg.ExcludeSelector, "[class*='cookie']") || strings.Contains(cfg.ExcludeSelector, "iframe") {
+		t.Fatalf("unexpected exclude selector: %q", cfg.ExcludeSelector)
+	}
+
+	if err := emitStarterConfig(context.Background(), opts, doc); err == nil {
+		t.Fatal("expected error when config already exists")
+	}
+}
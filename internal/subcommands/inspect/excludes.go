@@ -0,0 +1,169 @@
+package inspect
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/charmbracelet/huh"
+)
+
+type noisePattern struct {
+	Label    string
+	Selector string
+	// Starter marks patterns safe enough to include in emitted configs.
+	Starter bool
+}
+
+// noisePatterns lists common boilerplate that rarely belongs in scraped docs.
+var noisePatterns = []noisePattern{
+	{"Cookie banners", "[id*='cookie'], [class*='cookie']", true},
+	{"Consent dialogs", "[id*='consent'], [class*='consent'], [class*='gdpr']", true},
+	{"Footers", "footer, [role='contentinfo']", true},
+	{"Social / share widgets", "[class*='share'], [class*='social']", true},
+	{"Ads", ".ad, .ads, .advert, [id^='ad-'], [class*='advert'], [class*='sponsor']", true},
+	{"Newsletter / signup", "[class*='newsletter'], [class*='subscribe'], [class*='signup']", true},
+	{"Modal dialogs", "[role='dialog'], [class*='modal'], [class*='popup']", true},
+	{"Feedback widgets", "[class*='feedback'], [class*='rating']", false},
+	{"Embedded frames", "iframe", false},
+	{"Hidden elements", "[aria-hidden='true'], [hidden]", false},
+}
+
+type excludeCandidate struct {
+	Label    string
+	Selector string
+	Count    int
+	Text     int
+	Starter  bool
+}
+
+type excludePreview struct {
+	Removed    int
+	TextBefore int
+	TextAfter  int
+}
+
+func findExcludeCandidates(doc *goquery.Document) []excludeCandidate {
+	out := []excludeCandidate{}
+	for _, p := range noisePatterns {
+		sel := doc.Find(p.Selector)
+		if sel.Length() == 0 {
+			continue
+		}
+		out = append(out, excludeCandidate{
+			Label:    p.Label,
+			Selector: p.Selector,
+			Count:    sel.Length(),
+			Text:     len(strings.TrimSpace(sel.Text())),
+			Starter:  p.Starter,
+		})
+	}
+	return out
+}
+
+func combineSelectors(selectors []string) string {
+	parts := []string{}
+	for _, s := range selectors {
+		for _, part := range strings.Split(s, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				parts = append(parts, part)
+			}
+		}
+	}
+	return strings.Join(dedupe(parts), ", ")
+}
+
+func dedupe(items []string) []string {
+	seen := map[string]struct{}{}
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		if _, ok := seen[item]; ok {
+			continue
+		}
+		seen[item] = struct{}{}
+		out = append(out, item)
+	}
+	return out
+}
+
+// previewExclude measures the effect of removing selector from a fresh copy
+// of html, leaving the caller's document untouched.
+func previewExclude(html, selector string) (excludePreview, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return excludePreview{}, err
+	}
+	body := doc.Find("body")
+	preview := excludePreview{TextBefore: len(strings.TrimSpace(body.Text()))}
+	if strings.TrimSpace(selector) != "" {
+		removed := doc.Find(selector)
+		preview.Removed = removed.Length()
+		removed.Remove()
+	}
+	preview.TextAfter = len(strings.TrimSpace(body.Text()))
+	return preview, nil
+}
+
+func printExcludeCandidates(candidates []excludeCandidate) {
+	fmt.Println("Exclude candidates (matches/text length):")
+	if len(candidates) == 0 {
+		fmt.Println("- none found")
+		return
+	}
+	for _, c := range candidates {
+		fmt.Printf("- %s: %s (matches=%d text=%d)\n", c.Label, c.Selector, c.Count, c.Text)
+	}
+}
+
+func printExcludePreview(selector string, preview excludePreview) {
+	fmt.Printf("\nExclude selector: %s\n", selector)
+	fmt.Printf("Removes %d element(s); body text %d -> %d chars\n", preview.Removed, preview.TextBefore, preview.TextAfter)
+	fmt.Printf("Use: --exclude-selector %q\n", selector)
+}
+
+// runExcludeBuilder lets the user pick candidates, previews the combined
+// selector, and prints the resulting flag.
+func runExcludeBuilder(html string, doc *goquery.Document) error {
+	candidates := findExcludeCandidates(doc)
+	printExcludeCandidates(candidates)
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	options := make([]huh.Option[string], 0, len(candidates))
+	for _, c := range candidates {
+		options = append(options, huh.NewOption(fmt.Sprintf("%s (%d matches)", c.Label, c.Count), c.Selector))
+	}
+	for {
+		var picked []string
+		var extra string
+		form := huh.NewForm(huh.NewGroup(
+			huh.NewMultiSelect[string]().
+				Title("Exclude these elements").
+				Options(options...).
+				Value(&picked),
+			huh.NewInput().
+				Title("Additional selectors").
+				Description("Optional, comma separated.").
+				Value(&extra),
+		)).WithTheme(huh.ThemeDracula())
+		if err := form.Run(); err != nil {
+			return err
+		}
+
+		selector := combineSelectors(append(picked, extra))
+		preview, err := previewExclude(html, selector)
+		if err != nil {
+			return err
+		}
+		printExcludePreview(selector, preview)
+
+		accept := true
+		if err := huh.NewConfirm().Title("Keep this selector?").Affirmative("Done").Negative("Edit again").Value(&accept).Run(); err != nil {
+			return err
+		}
+		if accept {
+			return nil
+		}
+	}
+}
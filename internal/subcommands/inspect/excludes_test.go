@@ -0,0 +1,49 @@
+package inspect
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const noisyHTML = `<html><body>
+<div id="cookie-banner">We use cookies</div>
+<main><h1>Docs</h1><p>Real content here.</p></main>
+<div class="share-buttons"><a href="#">Tweet</a></div>
+<footer>Copyright</footer>
+</body></html>`
+
+func TestFindExcludeCandidates(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(noisyHTML))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	got := map[string]int{}
+	for _, c := range findExcludeCandidates(doc) {
+		got[c.Label] = c.Count
+	}
+	for _, label := range []string{"Cookie banners", "Footers", "Social / share widgets"} {
+		if got[label] != 1 {
+			t.Fatalf("expected 1 match for %s, got %v", label, got)
+		}
+	}
+	if _, ok := got["Ads"]; ok {
+		t.Fatalf("unexpected ads candidate: %v", got)
+	}
+}
+
+func TestCombineSelectorsAndPreview(t *testing.T) {
+	selector := combineSelectors([]string{"footer, [role='contentinfo']", "footer", " ", "#cookie-banner"})
+	if selector != "footer, [role='contentinfo'], #cookie-banner" {
+		t.Fatalf("unexpected combined selector: %q", selector)
+	}
+
+	preview, err := previewExclude(noisyHTML, selector)
+	if err != nil {
+		t.Fatalf("preview: %v", err)
+	}
+	if preview.Removed != 2 || preview.TextAfter >= preview.TextBefore {
+		t.Fatalf("unexpected preview: %+v", preview)
+	}
+}
@@ -0,0 +1,151 @@
+package inspect
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"go_scrap/internal/fetch"
+	"go_scrap/internal/parse"
+)
+
+const previewHeadingLimit = 20
+
+var (
+	navSelectors     = []string{"nav", "aside", "[role='navigation']", ".sidebar", ".toc", ".menu", ".nav", "#sidebar", "#toc", "#nav"}
+	contentSelectors = []string{"main", "article", ".content", "#content"}
+)
+
+type report struct {
+	URL           string           `json:"url"`
+	Source        string           `json:"source"`
+	Candidates    []candidate      `json:"candidates"`
+	TopContainers []linkContainer  `json:"top_containers"`
+	Chosen        chosenSelectors  `json:"chosen"`
+	Sections      []sectionPreview `json:"section_previews,omitempty"`
+}
+
+type chosenSelectors struct {
+	Nav     string `json:"nav_selector"`
+	Content string `json:"content_selector"`
+}
+
+type sectionPreview struct {
+	Selector string   `json:"selector"`
+	Sections int      `json:"sections"`
+	Headings []string `json:"headings"`
+	Error    string   `json:"error,omitempty"`
+}
+
+func buildReport(opts options, result fetch.Result, doc *goquery.Document) report {
+	candidates := collectCandidates(doc)
+	rep := report{
+		URL:           opts.URL,
+		Source:        result.SourceInfo,
+		Candidates:    candidates,
+		TopContainers: collectTopLinkContainers(doc, 5),
+		Chosen:        chooseSelectors(candidates),
+	}
+	if opts.PreviewSecs {
+		rep.Sections = previewSections(doc, candidates)
+	}
+	return rep
+}
+
+// chooseSelectors picks the nav candidate with the most links and the
+// content candidate with the most text.
+func chooseSelectors(candidates []candidate) chosenSelectors {
+	var chosen chosenSelectors
+	bestLinks, bestText := 0, 0
+	for _, c := range candidates {
+		if contains(navSelectors, c.Selector) && c.Links > bestLinks {
+			chosen.Nav, bestLinks = c.Selector, c.Links
+		}
+		if contains(contentSelectors, c.Selector) && c.Text > bestText {
+			chosen.Content, bestText = c.Selector, c.Text
+		}
+	}
+	return chosen
+}
+
+func previewSections(doc *goquery.Document, candidates []candidate) []sectionPreview {
+	selectors := []string{}
+	for _, c := range candidates {
+		if contains(contentSelectors, c.Selector) && !contains(selectors, c.Selector) {
+			selectors = append(selectors, c.Selector)
+		}
+	}
+
+	out := make([]sectionPreview, 0, len(selectors))
+	for _, sel := range selectors {
+		preview := sectionPreview{Selector: sel, Headings: []string{}}
+		parsed, err := parseWithSelector(doc, sel)
+		if err != nil {
+			preview.Error = err.Error()
+			out = append(out, preview)
+			continue
+		}
+		preview.Sections = len(parsed.Sections)
+		for i, s := range parsed.Sections {
+			if i >= previewHeadingLimit {
+				break
+			}
+			preview.Headings = append(preview.Headings, strings.Repeat("  ", max(s.HeadingLevel-1, 0))+s.HeadingText)
+		}
+		out = append(out, preview)
+	}
+	return out
+}
+
+func parseWithSelector(doc *goquery.Document, selector string) (*parse.Document, error) {
+	scoped, err := parse.ExtractBySelector(doc, selector)
+	if err != nil {
+		return nil, err
+	}
+	return parse.Parse(scoped)
+}
+
+func contains(items []string, v string) bool {
+	for _, item := range items {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSONReport(w io.Writer, rep report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rep)
+}
+
+func printChosen(chosen chosenSelectors) {
+	fmt.Println("\nBest guess:")
+	fmt.Printf("- nav selector: %s\n", orNone(chosen.Nav))
+	fmt.Printf("- content selector: %s\n", orNone(chosen.Content))
+}
+
+func printSectionPreviews(previews []sectionPreview) {
+	fmt.Println("\nSection preview by content selector:")
+	for _, p := range previews {
+		if p.Error != "" {
+			fmt.Printf("- %s: %s\n", p.Selector, p.Error)
+			continue
+		}
+		fmt.Printf("- %s: %d section(s)\n", p.Selector, p.Sections)
+		for _, h := range p.Headings {
+			fmt.Printf("    %s\n", h)
+		}
+	}
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
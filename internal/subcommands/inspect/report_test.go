@@ -0,0 +1,46 @@
+package inspect
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"go_scrap/internal/fetch"
+)
+
+func TestBuildReport_ChoosesSelectorsAndPreviewsSections(t *testing.T) {
+	html := `<html><body>
+<nav><a href="#a">A</a><a href="#b">B</a></nav>
+<main><h1 id="a">Guide</h1><p>Intro text</p><h2 id="b">Setup</h2><p>Steps</p></main>
+</body></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	rep := buildReport(options{URL: "https://example.com", PreviewSecs: true}, fetch.Result{SourceInfo: "static"}, doc)
+	if rep.Chosen.Nav != "nav" || rep.Chosen.Content != "main" {
+		t.Fatalf("unexpected chosen selectors: %+v", rep.Chosen)
+	}
+	if len(rep.Sections) != 1 || rep.Sections[0].Sections != 2 {
+		t.Fatalf("unexpected section previews: %+v", rep.Sections)
+	}
+	if rep.Sections[0].Headings[1] != "  Setup" {
+		t.Fatalf("expected indented h2 heading, got %q", rep.Sections[0].Headings[1])
+	}
+
+	var buf bytes.Buffer
+	if err := writeJSONReport(&buf, rep); err != nil {
+		t.Fatalf("writeJSONReport: %v", err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if _, ok := decoded["chosen"]; !ok {
+		t.Fatalf("missing chosen selectors: %s", buf.String())
+	}
+}
@@ -7,19 +7,21 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"go_scrap/internal/app"
+	"go_scrap/internal/cache"
 	"go_scrap/internal/fetch"
 
 	"github.com/PuerkitoBio/goquery"
 )
 
 type candidate struct {
-	Selector string
-	Links    int
-	Text     int
+	Selector string `json:"selector"`
+	Links    int    `json:"links"`
+	Text     int    `json:"text"`
 }
 
 type options struct {
@@ -28,7 +30,15 @@ type options struct {
 	TimeoutSec    int
 	CheckSelector string
 	UseCache      bool
+	CacheDir      string
+	CacheTTLSec   int
 	Headless      bool
+	Browser       string
+	SuggestExcl   bool
+	BuildExcl     bool
+	JSON          bool
+	PreviewSecs   bool
+	EmitConfig    string
 }
 
 func Run(args []string) error {
@@ -58,9 +68,41 @@ func Run(args []string) error {
 		return nil
 	}
 
-	candidates := collectCandidates(doc)
-	printCandidates(candidates)
-	printTopLinkContainers(doc, 5)
+	if opts.EmitConfig != "" {
+		return emitStarterConfig(ctx, opts, doc)
+	}
+	if opts.BuildExcl {
+		return runExcludeBuilder(result.HTML, doc)
+	}
+	if opts.SuggestExcl {
+		candidates := findExcludeCandidates(doc)
+		printExcludeCandidates(candidates)
+		if len(candidates) == 0 {
+			return nil
+		}
+		selectors := make([]string, 0, len(candidates))
+		for _, c := range candidates {
+			selectors = append(selectors, c.Selector)
+		}
+		selector := combineSelectors(selectors)
+		preview, err := previewExclude(result.HTML, selector)
+		if err != nil {
+			return err
+		}
+		printExcludePreview(selector, preview)
+		return nil
+	}
+
+	rep := buildReport(opts, result, doc)
+	if opts.JSON {
+		return writeJSONReport(os.Stdout, rep)
+	}
+	printCandidates(rep.Candidates)
+	printTopLinkContainers(rep.TopContainers)
+	printChosen(rep.Chosen)
+	if opts.PreviewSecs {
+		printSectionPreviews(rep.Sections)
+	}
 	return nil
 }
 
@@ -74,19 +116,30 @@ func parseOptions(args []string) (options, error) {
 	fs.IntVar(&opts.TimeoutSec, "timeout", app.DefaultTimeoutSeconds, "Timeout seconds")
 	fs.StringVar(&opts.CheckSelector, "check-selector", "", "Specific selector to validate")
 	fs.BoolVar(&opts.UseCache, "cache", false, "Use disk cache for HTML content")
+	fs.StringVar(&opts.CacheDir, "cache-dir", "", "Directory for --cache (default: $GO_SCRAP_CACHE_DIR or the user cache dir)")
+	fs.IntVar(&opts.CacheTTLSec, "cache-ttl", 0, "Seconds a --cache entry is reused before the page is fetched again (0 = forever)")
 	fs.BoolVar(&opts.Headless, "headless", true, "Run browser headless")
+	fs.StringVar(&opts.Browser, "browser", "", "Browser to render with: chromium|firefox|webkit (default: chromium)")
+	fs.StringVar(&opts.EmitConfig, "emit-config", "", "Write a starter config with best-guess selectors and mode to this path")
+	fs.BoolVar(&opts.JSON, "json", false, "Print a machine-readable JSON report instead of text")
+	fs.BoolVar(&opts.PreviewSecs, "preview-sections", false, "Show the sections and headings each content selector candidate would yield")
+	fs.BoolVar(&opts.SuggestExcl, "suggest-excludes", false, "List likely boilerplate elements with match counts and preview excluding them")
+	fs.BoolVar(&opts.BuildExcl, "exclude-builder", false, "Interactively pick boilerplate elements to build an exclude selector")
 	if err := fs.Parse(args); err != nil {
 		return options{}, err
 	}
+	if err := fetch.ValidateBrowser(fetch.Browser(opts.Browser)); err != nil {
+		return options{}, err
+	}
 	return opts, nil
 }
 
 func loadHTML(ctx context.Context, opts options) (fetch.Result, error) {
 	if opts.UseCache {
-		cachePath := fetch.GetCachePath(opts.URL)
-		if content, err := os.ReadFile(cachePath); err == nil {
-			fmt.Printf("Loaded from cache: %s\n", cachePath)
-			return fetch.Result{HTML: string(content), SourceInfo: "cache"}, nil
+		cachePath := cache.Path(opts.CacheDir, opts.URL)
+		if content, err := cache.Load(cachePath, time.Duration(opts.CacheTTLSec)*time.Second, nil); err == nil {
+			fmt.Fprintf(os.Stderr, "Loaded from cache: %s\n", cachePath)
+			return fetch.Result{HTML: content, SourceInfo: "cache"}, nil
 		}
 	}
 
@@ -96,6 +149,7 @@ func loadHTML(ctx context.Context, opts options) (fetch.Result, error) {
 		Timeout:         time.Duration(opts.TimeoutSec) * time.Second,
 		WaitForSelector: opts.WaitFor,
 		Headless:        opts.Headless,
+		Browser:         fetch.Browser(opts.Browser),
 		UserAgent:       app.DefaultUserAgent,
 	})
 	if err != nil {
@@ -103,8 +157,10 @@ func loadHTML(ctx context.Context, opts options) (fetch.Result, error) {
 	}
 
 	if opts.UseCache {
-		cachePath := fetch.GetCachePath(opts.URL)
-		_ = fetch.SaveToCache(cachePath, result.HTML)
+		cachePath := cache.Path(opts.CacheDir, opts.URL)
+		if err := cache.Save(cachePath, opts.URL, result, nil); err == nil {
+			_, _, _ = cache.Prune(filepath.Dir(cachePath), app.DefaultCacheMaxMB<<20)
+		}
 	}
 
 	return result, nil
@@ -137,24 +193,29 @@ func printCandidates(candidates []candidate) {
 	}
 }
 
-func printTopLinkContainers(doc *goquery.Document, limit int) {
-	fmt.Println("\nTop containers by link count (any element):")
-	type box struct {
-		Sel   string
-		Links int
-	}
-	boxes := []box{}
+type linkContainer struct {
+	Selector string `json:"selector"`
+	Links    int    `json:"links"`
+}
+
+func collectTopLinkContainers(doc *goquery.Document, limit int) []linkContainer {
+	boxes := []linkContainer{}
 	doc.Find("*").Each(func(_ int, s *goquery.Selection) {
+		if len(boxes) >= limit {
+			return
+		}
 		links := s.Find("a").Length()
 		if links >= 10 {
-			boxes = append(boxes, box{Sel: nodeSelector(s), Links: links})
+			boxes = append(boxes, linkContainer{Selector: nodeSelector(s), Links: links})
 		}
 	})
-	for i, b := range boxes {
-		if i >= limit {
-			break
-		}
-		fmt.Printf("- %s (links=%d)\n", b.Sel, b.Links)
+	return boxes
+}
+
+func printTopLinkContainers(boxes []linkContainer) {
+	fmt.Println("\nTop containers by link count (any element):")
+	for _, b := range boxes {
+		fmt.Printf("- %s (links=%d)\n", b.Selector, b.Links)
 	}
 }
 
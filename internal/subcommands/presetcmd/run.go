@@ -0,0 +1,105 @@
+package presetcmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go_scrap/internal/presets"
+)
+
+const usage = "usage: preset list | preset show NAME | preset add [--force] FILE"
+
+func Run(args []string) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	switch args[0] {
+	case "list":
+		return runList(os.Stdout)
+	case "show":
+		if len(args) != 2 {
+			return errors.New(usage)
+		}
+		return runShow(os.Stdout, args[1])
+	case "add":
+		return runAdd(os.Stdout, args[1:])
+	default:
+		return fmt.Errorf("unknown preset command %q (%s)", args[0], usage)
+	}
+}
+
+func runList(w io.Writer) error {
+	all, err := presets.LoadAll()
+	if err != nil {
+		return err
+	}
+	for _, p := range all {
+		fmt.Fprintf(w, "%-12s %-40s %s\n", p.Name, p.Description, p.Source)
+	}
+	return nil
+}
+
+func runShow(w io.Writer, name string) error {
+	all, err := presets.LoadAll()
+	if err != nil {
+		return err
+	}
+	p, ok := presets.Find(all, name)
+	if !ok {
+		return fmt.Errorf("unknown preset %q (available: %s)", name, strings.Join(presets.Names(all), ", "))
+	}
+	data, err := presets.Marshal(p)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "# source: %s\n%s\n", p.Source, data)
+	return nil
+}
+
+func runAdd(w io.Writer, args []string) error {
+	fs := flag.NewFlagSet("preset add", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	force := fs.Bool("force", false, "Overwrite an existing user preset with the same name")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New(usage)
+	}
+
+	src := fs.Arg(0)
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	p, err := presets.Parse(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", src, err)
+	}
+
+	dir, err := presets.UserDir()
+	if err != nil {
+		return fmt.Errorf("locate preset dir: %w", err)
+	}
+	dest := filepath.Join(dir, p.Name+".json")
+	if _, err := os.Stat(dest); err == nil && !*force {
+		return fmt.Errorf("%s already exists (use --force to overwrite)", dest)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	out, err := presets.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(dest, append(out, '\n'), 0600); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Added preset %s: %s\n", p.Name, dest)
+	return nil
+}
@@ -0,0 +1,51 @@
+package presetcmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go_scrap/internal/presets"
+)
+
+func TestRunAdd_UserPresetOverridesBuiltin(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GO_SCRAP_PRESET_DIR", filepath.Join(dir, "presets"))
+
+	src := filepath.Join(dir, "mine.json")
+	if err := os.WriteFile(src, []byte(`{"name":"MkDocs","content_selector":".mine","auth_headers":{"X-Token":"${MY_TOKEN}"}}`), 0600); err != nil {
+		t.Fatalf("write preset: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := runAdd(&out, []string{src}); err != nil {
+		t.Fatalf("runAdd: %v", err)
+	}
+	if err := runAdd(&out, []string{src}); err == nil {
+		t.Fatal("expected error when preset already exists")
+	}
+
+	all, err := presets.LoadAll()
+	if err != nil {
+		t.Fatalf("LoadAll: %v", err)
+	}
+	p, ok := presets.Find(all, "mkdocs")
+	if !ok || p.ContentSelector != ".mine" || p.Source == presets.BuiltinSource {
+		t.Fatalf("user preset should override builtin: %+v", p)
+	}
+
+	t.Setenv("MY_TOKEN", "abc")
+	if got := p.ExpandedAuthHeaders()["X-Token"]; got != "abc" {
+		t.Fatalf("auth template not expanded: %q", got)
+	}
+
+	out.Reset()
+	if err := runList(&out); err != nil {
+		t.Fatalf("runList: %v", err)
+	}
+	if !strings.Contains(out.String(), "docusaurus") || !strings.Contains(out.String(), "mkdocs.json") {
+		t.Fatalf("unexpected list output: %s", out.String())
+	}
+}
@@ -0,0 +1,101 @@
+package proxycmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"go_scrap/internal/app"
+	"go_scrap/internal/cache"
+	"go_scrap/internal/cacheproxy"
+)
+
+const usage = "usage: proxy [--listen ADDR] [--token TOKEN] [--dir DIR] [--ttl SECONDS] [--replay] [--timeout SECONDS]"
+
+// Run serves the cache directory as a shared record/replay cache until
+// interrupted; runs use it with --cache-proxy.
+func Run(args []string) error {
+	srv, listen, err := parseFlags(args)
+	if err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		return err
+	}
+	mode := "record"
+	if srv.Replay {
+		mode = "replay"
+	}
+	fmt.Fprintf(os.Stdout, "Cache proxy (%s) for %s on http://%s\n", mode, srv.Dir, ln.Addr())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return serve(ctx, ln, srv)
+}
+
+func parseFlags(args []string) (*cacheproxy.Server, string, error) {
+	var listen string
+	var ttl, timeout int
+	srv := &cacheproxy.Server{}
+	fs := flag.NewFlagSet("proxy", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&listen, "listen", "127.0.0.1:8787", "Address to listen on")
+	fs.StringVar(&srv.Token, "token", "", "Token clients must send as ?token= or X-Go-Scrap-Token (required off loopback)")
+	fs.StringVar(&srv.Dir, "dir", cache.ProxyDir(), "Cache directory (kept apart from the --cache dir, which holds pages fetched with credentials)")
+	fs.IntVar(&ttl, "ttl", 0, "Revalidate pages fetched more than this many seconds ago (0 = never)")
+	fs.BoolVar(&srv.Replay, "replay", false, "Serve only cached pages; never contact the origin")
+	fs.IntVar(&timeout, "timeout", app.DefaultTimeoutSeconds, "Origin fetch timeout in seconds")
+	if err := fs.Parse(args); err != nil {
+		return nil, "", err
+	}
+	if fs.NArg() > 0 {
+		return nil, "", errors.New(usage)
+	}
+	if ttl < 0 || timeout <= 0 {
+		return nil, "", errors.New("--ttl must be >= 0 and --timeout > 0")
+	}
+	if srv.Token == "" && !loopback(listen) {
+		return nil, "", fmt.Errorf("--listen %s is reachable from other machines: set --token so the proxy doesn't fetch pages for anyone", listen)
+	}
+	srv.TTL = time.Duration(ttl) * time.Second
+	srv.Timeout = time.Duration(timeout) * time.Second
+	return srv, listen, nil
+}
+
+// serve answers requests on ln until ctx is done, then lets requests in
+// flight finish.
+func serve(ctx context.Context, ln net.Listener, handler http.Handler) error {
+	httpSrv := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	done := make(chan error, 1)
+	go func() { done <- httpSrv.Serve(ln) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		return httpSrv.Shutdown(shutdownCtx)
+	}
+}
+
+// loopback reports whether the listen address only accepts connections
+// from this machine.
+func loopback(listen string) bool {
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
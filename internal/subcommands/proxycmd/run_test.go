@@ -0,0 +1,58 @@
+package proxycmd
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"go_scrap/internal/cache"
+)
+
+func TestParseFlags(t *testing.T) {
+	srv, listen, err := parseFlags([]string{"--listen", ":9000", "--token", "s3cret", "--dir", "/tmp/c", "--ttl", "60", "--replay"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if listen != ":9000" || srv.Dir != "/tmp/c" || srv.TTL != time.Minute || !srv.Replay || srv.Timeout <= 0 || srv.Token != "s3cret" {
+		t.Fatalf("unexpected server %+v on %s", srv, listen)
+	}
+	if _, _, err := parseFlags([]string{"--listen", "0.0.0.0:9000"}); err == nil {
+		t.Fatal("expected an error for a public listen address without a token")
+	}
+	srv, _, err = parseFlags([]string{"--listen", "localhost:9000"})
+	if err != nil {
+		t.Fatalf("loopback without a token: %v", err)
+	}
+	if srv.Dir != cache.ProxyDir() || srv.Dir == cache.DefaultDir() {
+		t.Fatalf("expected the proxy's own cache dir by default, got %s", srv.Dir)
+	}
+	if _, _, err := parseFlags([]string{"--ttl", "-1"}); err == nil {
+		t.Fatal("expected an error for a negative ttl")
+	}
+	if _, _, err := parseFlags([]string{"extra"}); err == nil {
+		t.Fatal("expected an error for a positional argument")
+	}
+}
+
+func TestServe_StopsWhenContextIsDone(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(ctx, ln, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	}()
+	resp, err := http.Get("http://" + ln.Addr().String() + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+}
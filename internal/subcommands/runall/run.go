@@ -0,0 +1,240 @@
+package runall
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go_scrap/internal/app"
+	"go_scrap/internal/cli"
+	"go_scrap/internal/config"
+	"go_scrap/internal/fetch"
+	"go_scrap/internal/fsutil"
+)
+
+// SummaryFile is the consolidated summary written to the --out directory.
+const SummaryFile = "run-all.json"
+
+// Result is the outcome of one config in the summary.
+type Result struct {
+	Config    string  `json:"config"`
+	URL       string  `json:"url,omitempty"`
+	OutputDir string  `json:"output_dir,omitempty"`
+	Status    string  `json:"status"`
+	Error     string  `json:"error,omitempty"`
+	Seconds   float64 `json:"seconds"`
+}
+
+// Result statuses.
+const (
+	StatusOK      = "ok"
+	StatusFailed  = "failed"
+	StatusInvalid = "invalid"
+)
+
+type options struct {
+	dir        string
+	out        string
+	parallel   int
+	domainRate float64
+	dryRun     bool
+}
+
+// job is a config ready to run.
+type job struct {
+	name string
+	opts app.Options
+}
+
+func Run(args []string) error {
+	opts, err := parseOptions(args)
+	if err != nil {
+		return err
+	}
+	paths, err := filepath.Glob(filepath.Join(opts.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no config files in %s", opts.dir)
+	}
+	sort.Strings(paths)
+
+	jobs, results := prepareJobs(paths, opts)
+	if err := checkOutputDirs(jobs); err != nil {
+		return err
+	}
+	limiter := fetch.NewHostLimiter(opts.domainRate)
+	results = append(results, runJobs(jobs, opts.parallel, limiter, os.Stdout)...)
+	sort.Slice(results, func(i, j int) bool { return results[i].Config < results[j].Config })
+
+	printSummary(os.Stdout, results)
+	if err := writeSummary(opts.out, results); err != nil {
+		return err
+	}
+	failed := 0
+	for _, r := range results {
+		if r.Status != StatusOK {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d config(s) failed", failed, len(results))
+	}
+	return nil
+}
+
+func parseOptions(args []string) (options, error) {
+	fs := flag.NewFlagSet("run-all", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	opts := options{}
+	fs.StringVar(&opts.dir, "dir", config.DefaultConfigDir, "Directory of config JSON files")
+	fs.StringVar(&opts.out, "out", filepath.Join(app.DefaultOutputRoot, "run-all"), "Root for configs without output_dir (each gets <out>/<config name>) and the run-all.json summary")
+	fs.IntVar(&opts.parallel, "parallel", 4, "Configs run at once")
+	fs.Float64Var(&opts.domainRate, "domain-rate", 2, "Requests per second per host, shared by all configs (0 = no shared limit)")
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "Dry-run every config (no files written except the summary)")
+	if err := fs.Parse(args); err != nil {
+		return options{}, err
+	}
+	if opts.parallel < 1 {
+		return options{}, errors.New("--parallel must be at least 1")
+	}
+	if opts.domainRate < 0 {
+		return options{}, errors.New("--domain-rate must not be negative")
+	}
+	return opts, nil
+}
+
+// prepareJobs parses each config as `go_scrap --config PATH --yes` would.
+// Configs that don't parse are returned as invalid results.
+func prepareJobs(paths []string, opts options) ([]job, []Result) {
+	var jobs []job
+	var invalid []Result
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		args := []string{"--config", path, "--yes"}
+		if opts.dryRun {
+			args = append(args, "--dry-run")
+		}
+		runOpts, _, err := cli.ParseArgs(args)
+		if err != nil {
+			invalid = append(invalid, Result{Config: filepath.Base(path), Status: StatusInvalid, Error: err.Error()})
+			continue
+		}
+		if runOpts.OutputDir == "" {
+			runOpts.OutputDir = filepath.Join(opts.out, name)
+		}
+		jobs = append(jobs, job{name: filepath.Base(path), opts: runOpts})
+	}
+	return jobs, invalid
+}
+
+// checkOutputDirs rejects configs that would write into the same directory.
+func checkOutputDirs(jobs []job) error {
+	seen := make(map[string]string, len(jobs))
+	for _, j := range jobs {
+		dir := filepath.Clean(j.opts.OutputDir)
+		if other, ok := seen[dir]; ok {
+			return fmt.Errorf("%s and %s both write to %s; give them distinct output_dir values", other, j.name, dir)
+		}
+		seen[dir] = j.name
+	}
+	return nil
+}
+
+// runJobs runs up to parallel jobs at once. Every run shares limiter, so
+// configs for the same host together stay under its rate.
+func runJobs(jobs []job, parallel int, limiter *fetch.HostLimiter, w io.Writer) []Result {
+	results := make([]Result, len(jobs))
+	var mu sync.Mutex
+	logf := func(format string, args ...any) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(w, format, args...)
+	}
+
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, j := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			logf("=== %s: starting (%s -> %s)\n", j.name, jobURL(j.opts), j.opts.OutputDir)
+			results[i] = runJob(j, limiter)
+			if results[i].Error != "" {
+				logf("=== %s: FAILED after %.1fs: %s\n", j.name, results[i].Seconds, results[i].Error)
+			} else {
+				logf("=== %s: OK in %.1fs\n", j.name, results[i].Seconds)
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// runJobFunc runs one config; tests replace it.
+var runJobFunc = app.Run
+
+func runJob(j job, limiter *fetch.HostLimiter) Result {
+	opts := j.opts
+	opts.HostLimiter = limiter
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := runJobFunc(ctx, opts)
+	r := Result{
+		Config:    j.name,
+		URL:       jobURL(j.opts),
+		OutputDir: j.opts.OutputDir,
+		Status:    StatusOK,
+		Seconds:   time.Since(start).Round(time.Millisecond).Seconds(),
+	}
+	if err != nil {
+		r.Status = StatusFailed
+		r.Error = err.Error()
+	}
+	return r
+}
+
+func jobURL(opts app.Options) string {
+	if opts.URL != "" {
+		return opts.URL
+	}
+	return opts.SitemapURL
+}
+
+func printSummary(w io.Writer, results []Result) {
+	fmt.Fprintf(w, "\nSummary (%d configs):\n", len(results))
+	for _, r := range results {
+		line := fmt.Sprintf("  %-8s %-30s %6.1fs  %s", strings.ToUpper(r.Status), r.Config, r.Seconds, r.OutputDir)
+		if r.Error != "" {
+			line += "  " + r.Error
+		}
+		fmt.Fprintln(w, strings.TrimRight(line, " "))
+	}
+}
+
+func writeSummary(dir string, results []Result) error {
+	if err := fsutil.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFile(filepath.Join(dir, SummaryFile), append(data, '\n'), 0600)
+}
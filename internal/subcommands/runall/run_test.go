@@ -0,0 +1,112 @@
+package runall
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go_scrap/internal/app"
+	"go_scrap/internal/fetch"
+)
+
+func writeConfig(t *testing.T, dir, name, body string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRun_RunsEveryConfigIntoItsOwnDirAndSummarizes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "<html><body><main><h1>Page %s</h1><p>Body text.</p></main></body></html>", r.URL.Path)
+	}))
+	defer srv.Close()
+
+	configs := t.TempDir()
+	out := t.TempDir()
+	writeConfig(t, configs, "a.json", fmt.Sprintf(`{"url": %q, "mode": "static"}`, srv.URL+"/a"))
+	writeConfig(t, configs, "b.json", fmt.Sprintf(`{"url": %q, "mode": "static"}`, srv.URL+"/b"))
+	writeConfig(t, configs, "broken.json", `{"url": `)
+
+	err := Run([]string{"--dir", configs, "--out", out, "--parallel", "2"})
+	if err == nil || !strings.Contains(err.Error(), "1 of 3") {
+		t.Fatalf("expected the broken config to be reported, got %v", err)
+	}
+	for _, name := range []string{"a", "b"} {
+		if _, err := os.Stat(filepath.Join(out, name, "content.md")); err != nil {
+			t.Fatalf("expected %s to write into its own directory: %v", name, err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(out, SummaryFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results []Result
+	if err := json.Unmarshal(data, &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 || results[0].Status != StatusOK || results[1].Status != StatusOK || results[2].Status != StatusInvalid {
+		t.Fatalf("unexpected summary: %+v", results)
+	}
+}
+
+func TestRun_RejectsSharedOutputDir(t *testing.T) {
+	configs := t.TempDir()
+	shared := filepath.Join(t.TempDir(), "shared")
+	writeConfig(t, configs, "a.json", fmt.Sprintf(`{"url": "https://a.example", "output_dir": %q}`, shared))
+	writeConfig(t, configs, "b.json", fmt.Sprintf(`{"url": "https://b.example", "output_dir": %q}`, shared))
+
+	err := Run([]string{"--dir", configs, "--out", t.TempDir()})
+	if err == nil || !strings.Contains(err.Error(), "both write to") {
+		t.Fatalf("expected a shared output dir to be rejected, got %v", err)
+	}
+}
+
+func TestRunJobs_LimitsParallelism(t *testing.T) {
+	prev := runJobFunc
+	defer func() { runJobFunc = prev }()
+	var mu sync.Mutex
+	running, peak := 0, 0
+	limiter := fetch.NewHostLimiter(1000)
+	shared := true
+	runJobFunc = func(ctx context.Context, opts app.Options) error {
+		mu.Lock()
+		shared = shared && opts.HostLimiter == limiter && len(opts.Middleware) == 0
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		if opts.URL == "https://fail.example" {
+			return errors.New("boom")
+		}
+		return nil
+	}
+
+	var jobs []job
+	for _, u := range []string{"https://a.example", "https://b.example", "https://fail.example", "https://c.example", "https://d.example"} {
+		jobs = append(jobs, job{name: u, opts: app.Options{URL: u, Timeout: time.Second}})
+	}
+	results := runJobs(jobs, 2, limiter, &strings.Builder{})
+	if peak != 2 {
+		t.Fatalf("expected at most 2 configs at once, peak was %d", peak)
+	}
+	if !shared {
+		t.Fatal("expected every config to get the shared limiter as its HostLimiter")
+	}
+	if results[2].Status != StatusFailed || results[2].Error != "boom" || results[0].Status != StatusOK {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+}
@@ -0,0 +1,258 @@
+package selfupdate
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"go_scrap/internal/app"
+	"go_scrap/internal/version"
+)
+
+const (
+	defaultRepo   = "cbrieeze/go_scrap"
+	defaultAPIURL = "https://api.github.com"
+	checksumsName = "checksums.txt"
+)
+
+type options struct {
+	Repo       string
+	APIURL     string
+	Target     string
+	CheckOnly  bool
+	Force      bool
+	TimeoutSec int
+}
+
+type release struct {
+	TagName string  `json:"tag_name"`
+	Assets  []asset `json:"assets"`
+}
+
+type asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+func Run(args []string) error {
+	opts, err := parseOptions(args)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(opts.TimeoutSec)*time.Second)
+	defer cancel()
+
+	rel, err := fetchLatestRelease(ctx, opts)
+	if err != nil {
+		return err
+	}
+	current := version.Get().Version
+	if rel.TagName == current && !opts.Force {
+		fmt.Printf("Already up to date (%s)\n", current)
+		return nil
+	}
+	if opts.CheckOnly {
+		fmt.Printf("Update available: %s -> %s\n", current, rel.TagName)
+		return nil
+	}
+
+	name := assetName(runtime.GOOS, runtime.GOARCH)
+	bin, ok := findAsset(rel.Assets, name)
+	if !ok {
+		return fmt.Errorf("release %s has no asset %q", rel.TagName, name)
+	}
+	sums, ok := findAsset(rel.Assets, checksumsName)
+	if !ok {
+		return fmt.Errorf("release %s has no %s; refusing to install an unverified binary", rel.TagName, checksumsName)
+	}
+
+	expected, err := fetchChecksum(ctx, sums.URL, name)
+	if err != nil {
+		return err
+	}
+	target, err := resolveTarget(opts.Target)
+	if err != nil {
+		return err
+	}
+	if err := install(ctx, bin.URL, expected, target); err != nil {
+		return err
+	}
+	fmt.Printf("Updated %s: %s -> %s\n", target, current, rel.TagName)
+	return nil
+}
+
+func parseOptions(args []string) (options, error) {
+	fs := flag.NewFlagSet("self-update", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	opts := options{}
+	fs.StringVar(&opts.Repo, "repo", defaultRepo, "GitHub repository (owner/name) to fetch releases from")
+	fs.StringVar(&opts.APIURL, "api-url", defaultAPIURL, "GitHub API base URL")
+	fs.StringVar(&opts.Target, "target", "", "Binary to replace (default: the running executable)")
+	fs.BoolVar(&opts.CheckOnly, "check", false, "Only report whether an update is available")
+	fs.BoolVar(&opts.Force, "force", false, "Reinstall even if already on the latest release")
+	fs.IntVar(&opts.TimeoutSec, "timeout", app.DefaultTimeoutSeconds, "Timeout seconds")
+	if err := fs.Parse(args); err != nil {
+		return options{}, err
+	}
+	return opts, nil
+}
+
+func assetName(goos, goarch string) string {
+	name := fmt.Sprintf("go_scrap_%s_%s", goos, goarch)
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+func findAsset(assets []asset, name string) (asset, bool) {
+	for _, a := range assets {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return asset{}, false
+}
+
+func fetchLatestRelease(ctx context.Context, opts options) (release, error) {
+	url := strings.TrimRight(opts.APIURL, "/") + "/repos/" + opts.Repo + "/releases/latest"
+	body, err := get(ctx, url)
+	if err != nil {
+		return release{}, fmt.Errorf("fetch latest release: %w", err)
+	}
+	defer body.Close()
+
+	var rel release
+	if err := json.NewDecoder(body).Decode(&rel); err != nil {
+		return release{}, fmt.Errorf("decode release: %w", err)
+	}
+	if rel.TagName == "" {
+		return release{}, errors.New("latest release has no tag")
+	}
+	return rel, nil
+}
+
+// fetchChecksum reads a sha256sum-style checksums file and returns the hash
+// listed for name.
+func fetchChecksum(ctx context.Context, url, name string) (string, error) {
+	body, err := get(ctx, url)
+	if err != nil {
+		return "", fmt.Errorf("fetch checksums: %w", err)
+	}
+	defer body.Close()
+
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("read checksums: %w", err)
+	}
+	return "", fmt.Errorf("no checksum listed for %s", name)
+}
+
+func resolveTarget(target string) (string, error) {
+	if target == "" {
+		exe, err := os.Executable()
+		if err != nil {
+			return "", fmt.Errorf("locate executable: %w", err)
+		}
+		target = exe
+	}
+	resolved, err := filepath.EvalSymlinks(target)
+	if err != nil {
+		return "", fmt.Errorf("resolve target: %w", err)
+	}
+	return resolved, nil
+}
+
+// install downloads the binary next to target, verifies it, and swaps it in.
+func install(ctx context.Context, url, expected, target string) error {
+	body, err := get(ctx, url)
+	if err != nil {
+		return fmt.Errorf("download binary: %w", err)
+	}
+	defer body.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".go_scrap-update-*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, hash), body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("download binary: %w", err)
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != expected {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", expected, got)
+	}
+	if err := os.Chmod(tmpPath, 0755); err != nil {
+		return fmt.Errorf("chmod binary: %w", err)
+	}
+	return replace(tmpPath, target, runtime.GOOS == "windows")
+}
+
+// replace renames path over target. A running executable cannot be
+// overwritten on Windows, but it can be renamed, so with moveAside the
+// current binary goes to target.old first and is moved back if the new one
+// cannot take its place.
+func replace(path, target string, moveAside bool) error {
+	if !moveAside {
+		if err := os.Rename(path, target); err != nil {
+			return fmt.Errorf("replace binary: %w", err)
+		}
+		return nil
+	}
+	old := target + ".old"
+	_ = os.Remove(old)
+	if err := os.Rename(target, old); err != nil {
+		return fmt.Errorf("move current binary: %w", err)
+	}
+	if err := os.Rename(path, target); err != nil {
+		if rerr := os.Rename(old, target); rerr != nil {
+			return fmt.Errorf("replace binary: %w (restore %s: %v)", err, old, rerr)
+		}
+		return fmt.Errorf("replace binary: %w", err)
+	}
+	return nil
+}
+
+func get(ctx context.Context, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", app.DefaultUserAgent)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,88 @@
+package selfupdate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func newReleaseServer(t *testing.T, binary []byte, checksum string) *httptest.Server {
+	t.Helper()
+	name := assetName(runtime.GOOS, runtime.GOARCH)
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/o/r/releases/latest":
+			fmt.Fprintf(w, `{"tag_name":"v9.9.9","assets":[{"name":%q,"browser_download_url":%q},{"name":"checksums.txt","browser_download_url":%q}]}`,
+				name, srv.URL+"/bin", srv.URL+"/sums")
+		case "/bin":
+			_, _ = w.Write(binary)
+		case "/sums":
+			fmt.Fprintf(w, "%s  %s\n", checksum, name)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRun_ReplacesTargetAfterChecksum(t *testing.T) {
+	binary := []byte("new binary")
+	sum := sha256.Sum256(binary)
+	srv := newReleaseServer(t, binary, hex.EncodeToString(sum[:]))
+
+	target := filepath.Join(t.TempDir(), "go_scrap")
+	if err := os.WriteFile(target, []byte("old binary"), 0755); err != nil {
+		t.Fatalf("write target: %v", err)
+	}
+	if err := Run([]string{"--api-url", srv.URL, "--repo", "o/r", "--target", target}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	data, err := os.ReadFile(target)
+	if err != nil || string(data) != "new binary" {
+		t.Fatalf("target not replaced: %q, %v", data, err)
+	}
+}
+
+func TestRun_RejectsChecksumMismatch(t *testing.T) {
+	srv := newReleaseServer(t, []byte("tampered"), strings.Repeat("0", 64))
+
+	target := filepath.Join(t.TempDir(), "go_scrap")
+	if err := os.WriteFile(target, []byte("old binary"), 0755); err != nil {
+		t.Fatalf("write target: %v", err)
+	}
+	err := Run([]string{"--api-url", srv.URL, "--repo", "o/r", "--target", target})
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+	data, _ := os.ReadFile(target)
+	if string(data) != "old binary" {
+		t.Fatalf("target should be untouched, got %q", data)
+	}
+}
+
+func TestReplace_MovesOldBinaryBackOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "go_scrap")
+	if err := os.WriteFile(target, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := replace(filepath.Join(dir, "missing"), target, true); err == nil {
+		t.Fatal("expected an error for a missing new binary")
+	}
+	data, err := os.ReadFile(target)
+	if err != nil || string(data) != "old" {
+		t.Fatalf("current binary not restored: %q %v", data, err)
+	}
+	if _, err := os.Stat(target + ".old"); !os.IsNotExist(err) {
+		t.Fatalf("expected no .old file, got %v", err)
+	}
+}
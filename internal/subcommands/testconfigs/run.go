@@ -50,6 +50,11 @@ func Run(args []string) error {
 			UserAgent:       cfg.UserAgent,
 			WaitFor:         cfg.WaitForSelector,
 			Headless:        headless,
+			Browser:         fetch.Browser(cfg.Browser),
+			ScrollToBottom:  cfg.ScrollToBottom,
+			ClickSelector:   cfg.ClickSelector,
+			MaxScrolls:      cfg.MaxScrolls,
+			ScrollQuiet:     time.Duration(cfg.ScrollQuietMS) * time.Millisecond,
 			Yes:             true,
 			Strict:          false,
 			DryRun:          dryRun,
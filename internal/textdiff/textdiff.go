@@ -0,0 +1,179 @@
+// Package textdiff renders line-based unified diffs, as `diff -u` would, for
+// reviewing how scraped sections changed between runs.
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxCells bounds the comparison table; longer inputs are shown as one hunk
+// replacing every line.
+const maxCells = 4 << 20
+
+type opKind byte
+
+const (
+	opEqual  opKind = ' '
+	opDelete opKind = '-'
+	opInsert opKind = '+'
+)
+
+type op struct {
+	kind opKind
+	line string
+	// a and b are the 0-based line numbers in the old and new text.
+	a, b int
+}
+
+// Unified returns the unified diff from a to b, labelled fromName and toName,
+// with context unchanged lines around each change. It is empty when the texts
+// are equal.
+func Unified(fromName, toName, a, b string, context int) string {
+	if a == b {
+		return ""
+	}
+	ops := diffLines(splitLines(a), splitLines(b))
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+	for _, h := range hunks(ops, max(context, 0)) {
+		writeHunk(&out, ops[h[0]:h[1]])
+	}
+	return out.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines aligns a and b on a longest common subsequence of lines.
+func diffLines(a, b []string) []op {
+	// Common prefix and suffix are matched directly, which keeps the table
+	// small for the usual case of a few edited lines.
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	ops := make([]op, 0, len(a)+len(b))
+	for i := 0; i < pre; i++ {
+		ops = append(ops, op{kind: opEqual, line: a[i], a: i, b: i})
+	}
+	ops = append(ops, diffMiddle(a[pre:len(a)-suf], b[pre:len(b)-suf], pre)...)
+	for i := 0; i < suf; i++ {
+		ia, ib := len(a)-suf+i, len(b)-suf+i
+		ops = append(ops, op{kind: opEqual, line: a[ia], a: ia, b: ib})
+	}
+	return ops
+}
+
+// diffMiddle diffs the lines between the common prefix and suffix; offset is
+// the length of that prefix.
+func diffMiddle(a, b []string, offset int) []op {
+	n, m := len(a), len(b)
+	var ops []op
+	if n == 0 || m == 0 || (n+1)*(m+1) > maxCells {
+		for i, line := range a {
+			ops = append(ops, op{kind: opDelete, line: line, a: offset + i, b: offset})
+		}
+		for j, line := range b {
+			ops = append(ops, op{kind: opInsert, line: line, a: offset + n, b: offset + j})
+		}
+		return ops
+	}
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && a[i] == b[j]:
+			ops = append(ops, op{kind: opEqual, line: a[i], a: offset + i, b: offset + j})
+			i++
+			j++
+		case j < m && (i == n || lcs[i][j+1] > lcs[i+1][j]):
+			ops = append(ops, op{kind: opInsert, line: b[j], a: offset + i, b: offset + j})
+			j++
+		default:
+			ops = append(ops, op{kind: opDelete, line: a[i], a: offset + i, b: offset + j})
+			i++
+		}
+	}
+	return ops
+}
+
+// hunks groups the changes of ops with up to context lines around them,
+// merging groups whose context overlaps. Each hunk is a [start, end) range
+// of ops.
+func hunks(ops []op, context int) [][2]int {
+	var out [][2]int
+	for i := 0; i < len(ops); i++ {
+		if ops[i].kind == opEqual {
+			continue
+		}
+		start := max(i-context, 0)
+		end := i + 1
+		for k := i + 1; k < len(ops) && k <= end+2*context; k++ {
+			if ops[k].kind != opEqual {
+				end = k + 1
+			}
+		}
+		end = min(end+context, len(ops))
+		if n := len(out); n > 0 && start <= out[n-1][1] {
+			out[n-1][1] = end
+		} else {
+			out = append(out, [2]int{start, end})
+		}
+		i = end - 1
+	}
+	return out
+}
+
+func writeHunk(out *strings.Builder, ops []op) {
+	var na, nb int
+	for _, o := range ops {
+		if o.kind != opInsert {
+			na++
+		}
+		if o.kind != opDelete {
+			nb++
+		}
+	}
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(ops[0].a, na), hunkRange(ops[0].b, nb))
+	for _, o := range ops {
+		out.WriteByte(byte(o.kind))
+		out.WriteString(o.line)
+		out.WriteByte('\n')
+	}
+}
+
+// hunkRange formats a 0-based start and a line count as diff -u does: an
+// empty range names the line before it.
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	default:
+		return fmt.Sprintf("%d,%d", start+1, count)
+	}
+}
@@ -0,0 +1,43 @@
+package textdiff
+
+import "testing"
+
+func TestUnified(t *testing.T) {
+	cases := []struct {
+		name    string
+		a, b    string
+		context int
+		want    string
+	}{
+		{"equal", "a\nb\n", "a\nb\n", 3, ""},
+		{
+			"changed line with context",
+			"one\ntwo\nthree\nfour\nfive\nsix\n", "one\ntwo\nTHREE\nfour\nfive\nsix\n", 1,
+			"--- a\n+++ b\n@@ -2,3 +2,3 @@\n two\n-three\n+THREE\n four\n",
+		},
+		{
+			"distant changes make two hunks",
+			"1\n2\n3\n4\n5\n6\n7\n8\n", "x\n2\n3\n4\n5\n6\n7\ny\n", 1,
+			"--- a\n+++ b\n@@ -1,2 +1,2 @@\n-1\n+x\n 2\n@@ -7,2 +7,2 @@\n 7\n-8\n+y\n",
+		},
+		{
+			"close changes share a hunk",
+			"1\n2\n3\n4\n", "x\n2\n3\ny\n", 1,
+			"--- a\n+++ b\n@@ -1,4 +1,4 @@\n-1\n+x\n 2\n 3\n-4\n+y\n",
+		},
+		{"added to empty", "", "new\n", 3, "--- a\n+++ b\n@@ -0,0 +1 @@\n+new\n"},
+		{"removed everything", "old\n", "", 3, "--- a\n+++ b\n@@ -1 +0,0 @@\n-old\n"},
+		{
+			"insertion keeps common lines",
+			"a\nc\n", "a\nb\nc\n", 0,
+			"--- a\n+++ b\n@@ -1,0 +2 @@\n+b\n",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Unified("a", "b", tc.a, tc.b, tc.context); got != tc.want {
+				t.Fatalf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
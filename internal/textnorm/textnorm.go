@@ -0,0 +1,178 @@
+// Package textnorm normalizes Unicode in fetched pages so headings, slugs and
+// anchors come out the same whatever form the source used.
+package textnorm
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	EmojiKeep      = "keep"
+	EmojiStrip     = "strip"
+	EmojiShortcode = "shortcode"
+)
+
+// Options selects which normalizations run. The zero value changes nothing.
+type Options struct {
+	// NFC composes all text to Unicode NFC and removes zero-width characters
+	// from headings.
+	NFC bool
+	// Emoji controls emoji in headings: keep, strip, or shortcode (":rocket:").
+	Emoji string
+}
+
+func (o Options) Enabled() bool {
+	return o.NFC || (o.Emoji != "" && o.Emoji != EmojiKeep)
+}
+
+// ValidateEmoji reports whether mode is a known emoji mode ("" means keep).
+func ValidateEmoji(mode string) error {
+	switch mode {
+	case "", EmojiKeep, EmojiStrip, EmojiShortcode:
+		return nil
+	}
+	return fmt.Errorf("unknown emoji mode %q (expected keep, strip or shortcode)", mode)
+}
+
+// Document normalizes text nodes in place before sections are parsed, so
+// heading text and generated heading IDs are derived from the same string.
+func Document(doc *goquery.Document, opts Options) {
+	if doc == nil || !opts.Enabled() {
+		return
+	}
+	if opts.NFC {
+		for _, n := range doc.Nodes {
+			walkText(n, func(t *html.Node) { t.Data = norm.NFC.String(t.Data) })
+		}
+	}
+	doc.Find("h1, h2, h3, h4, h5, h6").Each(func(_ int, s *goquery.Selection) {
+		for _, n := range s.Nodes {
+			walkText(n, func(t *html.Node) { t.Data = Heading(t.Data, opts) })
+		}
+	})
+}
+
+// Heading applies the heading-only normalizations to s.
+func Heading(s string, opts Options) string {
+	if opts.NFC {
+		s = norm.NFC.String(stripZeroWidth(s))
+	}
+	switch opts.Emoji {
+	case EmojiStrip:
+		s = replaceEmoji(s, false)
+	case EmojiShortcode:
+		s = replaceEmoji(s, true)
+	}
+	return s
+}
+
+func walkText(n *html.Node, fn func(*html.Node)) {
+	if n.Type == html.ElementNode && (n.Data == "script" || n.Data == "style" || n.Data == "pre" || n.Data == "code") {
+		return
+	}
+	if n.Type == html.TextNode {
+		fn(n)
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walkText(c, fn)
+	}
+}
+
+func isZeroWidth(r rune) bool {
+	switch r {
+	case '\u200B', '\u200C', '\u200D', '\u2060', '\uFEFF', '\u00AD':
+		return true
+	}
+	return false
+}
+
+// stripZeroWidth removes invisible characters, keeping joiners that hold an
+// emoji sequence together.
+func stripZeroWidth(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		if isZeroWidth(r) && !(r == '\u200D' && i > 0 && i+1 < len(runes) && isEmoji(runes[i-1]) && isEmoji(runes[i+1])) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF, // pictographs, emoticons, flags, skin tones
+		r >= 0x2600 && r <= 0x27BF,   // misc symbols, dingbats
+		r >= 0x2B00 && r <= 0x2BFF,   // arrows and stars
+		r >= 0xE0020 && r <= 0xE007F: // tag sequences
+		return true
+	case r == 0x231A, r == 0x231B, r == 0x23E9, r == 0x23F0, r == 0x23F3, r == 0x2139, r == 0x203C, r == 0x2049:
+		return true
+	}
+	return false
+}
+
+// isEmojiJoiner reports runes that only make sense inside an emoji sequence.
+func isEmojiJoiner(r rune) bool {
+	return r == '\uFE0F' || r == '\uFE0E' || r == '\u200D' || r == '\u20E3'
+}
+
+// replaceEmoji removes emoji sequences, or replaces known ones with their
+// shortcode, then tidies the whitespace left behind.
+func replaceEmoji(s string, shortcodes bool) string {
+	runes := []rune(s)
+	var b strings.Builder
+	changed := false
+	for i := 0; i < len(runes); {
+		if !isEmoji(runes[i]) {
+			b.WriteRune(runes[i])
+			i++
+			continue
+		}
+		changed = true
+		var seq strings.Builder
+		for i < len(runes) && (isEmoji(runes[i]) || isEmojiJoiner(runes[i])) {
+			if !isEmojiJoiner(runes[i]) || runes[i] == '\u200D' {
+				seq.WriteRune(runes[i])
+			}
+			i++
+			// A non-joined emoji ends the sequence.
+			if i < len(runes) && isEmoji(runes[i]) && runes[i-1] != '\u200D' && !isSkinTone(runes[i]) {
+				break
+			}
+		}
+		if shortcodes {
+			if code, ok := shortcodeFor[seq.String()]; ok {
+				b.WriteString(code)
+			}
+		}
+	}
+	if !changed {
+		return s
+	}
+	return strings.Join(strings.FieldsFunc(b.String(), unicode.IsSpace), " ")
+}
+
+func isSkinTone(r rune) bool {
+	return r >= 0x1F3FB && r <= 0x1F3FF
+}
+
+// shortcodeFor covers emoji common in documentation headings; unknown emoji
+// are stripped in shortcode mode.
+var shortcodeFor = map[string]string{
+	"🚀": ":rocket:", "✅": ":white_check_mark:", "❌": ":x:", "⚠": ":warning:", "ℹ": ":information_source:",
+	"💡": ":bulb:", "📝": ":memo:", "📚": ":books:", "📖": ":book:", "📦": ":package:", "🔧": ":wrench:",
+	"🛠": ":hammer_and_wrench:", "⚙": ":gear:", "🔒": ":lock:", "🔑": ":key:", "🐛": ":bug:", "✨": ":sparkles:",
+	"🎉": ":tada:", "🔥": ":fire:", "⭐": ":star:", "👍": ":+1:", "👎": ":-1:", "❗": ":exclamation:",
+	"❓": ":question:", "🔗": ":link:", "📌": ":pushpin:", "🚧": ":construction:", "🧪": ":test_tube:",
+	"🔍": ":mag:", "📈": ":chart_with_upwards_trend:", "🗑": ":wastebasket:", "💻": ":computer:",
+	"🌐": ":globe_with_meridians:", "📄": ":page_facing_up:", "🏁": ":checkered_flag:", "👋": ":wave:",
+	"❤": ":heart:", "☑": ":ballot_box_with_check:", "✔": ":heavy_check_mark:", "✏": ":pencil2:",
+}
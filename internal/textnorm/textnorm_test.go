@@ -0,0 +1,94 @@
+package textnorm
+
+import (
+	"testing"
+
+	"go_scrap/internal/parse"
+)
+
+func TestHeading(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		opts Options
+		want string
+	}{
+		{"zero value is a no-op", "Intro\u200B 🚀", Options{}, "Intro\u200B 🚀"},
+		{"nfc composes", "Cafe\u0301", Options{NFC: true}, "Café"},
+		{"nfc drops zero width", "In\u200Btro\uFEFF", Options{NFC: true}, "Intro"},
+		{"strip emoji", "🚀 Getting started ✅", Options{Emoji: EmojiStrip}, "Getting started"},
+		{"strip emoji with variation selector", "⚠\uFE0F Warnings", Options{Emoji: EmojiStrip}, "Warnings"},
+		{"strip zwj sequence", "👩\u200D💻 Developers", Options{NFC: true, Emoji: EmojiStrip}, "Developers"},
+		{"shortcode known", "⚠\uFE0F Warnings", Options{Emoji: EmojiShortcode}, ":warning: Warnings"},
+		{"shortcode adjacent", "🚀✅ Done", Options{Emoji: EmojiShortcode}, ":rocket::white_check_mark: Done"},
+		{"shortcode unknown is stripped", "🦩 Flamingo", Options{Emoji: EmojiShortcode}, "Flamingo"},
+		{"keep leaves emoji", "🚀 Launch", Options{NFC: true, Emoji: EmojiKeep}, "🚀 Launch"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Heading(tc.in, tc.opts); got != tc.want {
+				t.Fatalf("Heading(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateEmoji(t *testing.T) {
+	for _, mode := range []string{"", EmojiKeep, EmojiStrip, EmojiShortcode} {
+		if err := ValidateEmoji(mode); err != nil {
+			t.Fatalf("ValidateEmoji(%q): %v", mode, err)
+		}
+	}
+	if err := ValidateEmoji("ascii"); err == nil {
+		t.Fatal("expected error for unknown mode")
+	}
+}
+
+func TestDocument_StableHeadingIDs(t *testing.T) {
+	opts := Options{NFC: true, Emoji: EmojiStrip}
+	variants := []string{
+		"<h2>Café setup</h2><p>x</p>",
+		"<h2>Cafe\u0301 setup 🚀</h2><p>x</p>",
+		"<h2>\u200BCafe\u0301 <span>setup</span>\u200B</h2><p>x</p>",
+	}
+	var want string
+	for i, src := range variants {
+		doc, err := parse.NewDocument(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		Document(doc, opts)
+		parsed, err := parse.Parse(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(parsed.Sections) != 1 {
+			t.Fatalf("variant %d: got %d sections", i, len(parsed.Sections))
+		}
+		s := parsed.Sections[0]
+		if s.HeadingText != "Café setup" {
+			t.Fatalf("variant %d: heading text %q", i, s.HeadingText)
+		}
+		if i == 0 {
+			want = s.HeadingID
+			continue
+		}
+		if s.HeadingID != want {
+			t.Fatalf("variant %d: heading id %q, want %q", i, s.HeadingID, want)
+		}
+	}
+}
+
+func TestDocument_LeavesCodeAlone(t *testing.T) {
+	doc, err := parse.NewDocument("<h2>Title</h2><pre><code>a\u200Bb</code></pre><p>Cafe\u0301</p>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	Document(doc, Options{NFC: true})
+	if got := doc.Find("code").Text(); got != "a\u200Bb" {
+		t.Fatalf("code text changed: %q", got)
+	}
+	if got := doc.Find("p").Text(); got != "Café" {
+		t.Fatalf("paragraph not normalized: %q", got)
+	}
+}
@@ -0,0 +1,143 @@
+package tokenize
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// splitPattern cuts text into the pieces BPE merges within, like the
+// tiktoken regex of an encoding.
+type splitPattern struct {
+	re *regexp.Regexp
+}
+
+// The tiktoken patterns end in `\s+(?!\S)|\s+`, which RE2 cannot express:
+// the last group matches `\s+` and pieces backs off one character when the
+// run is followed by more text. `\s` is widened to Unicode white space, as
+// in the original patterns.
+var (
+	cl100kPattern = newSplitPattern(
+		`(?i:'s|'t|'re|'ve|'m|'ll|'d)` +
+			`|[^\r\n\p{L}\p{N}]?\p{L}+` +
+			`|\p{N}{1,3}` +
+			`| ?[^\s\p{L}\p{N}]+[\r\n]*` +
+			`|\s*[\r\n]+`)
+	o200kPattern = newSplitPattern(
+		`[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+			`|[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+			`|\p{N}{1,3}` +
+			`| ?[^\s\p{L}\p{N}]+[\r\n/]*` +
+			`|\s*[\r\n]+`)
+)
+
+func newSplitPattern(alternatives string) splitPattern {
+	expr := `\A(?:` + alternatives + `|(\s+))`
+	expr = strings.ReplaceAll(expr, `[^\s`, `[^\s\x0b\x85\p{Z}`)
+	expr = strings.ReplaceAll(expr, `\s*`, `[\s\x0b\x85\p{Z}]*`)
+	expr = strings.ReplaceAll(expr, `\s+`, `[\s\x0b\x85\p{Z}]+`)
+	return splitPattern{re: regexp.MustCompile(expr)}
+}
+
+// pieces calls fn with each piece of text in order.
+func (p splitPattern) pieces(text string, fn func(string)) {
+	for text != "" {
+		m := p.re.FindStringSubmatchIndex(text)
+		if m == nil || m[1] == 0 {
+			_, size := utf8.DecodeRuneInString(text)
+			m = []int{0, size, -1, -1}
+		}
+		end := m[1]
+		if m[2] >= 0 && end < len(text) {
+			// `\s+(?!\S)`: leave the last space to the text that follows.
+			if _, size := utf8.DecodeLastRuneInString(text[:end]); size < end {
+				end -= size
+			}
+		}
+		fn(text[:end])
+		text = text[end:]
+	}
+}
+
+// BPE is a tiktoken-compatible byte pair encoding.
+type BPE struct {
+	name    string
+	ranks   map[string]int
+	pattern splitPattern
+}
+
+// loadBPE reads merge ranks in the .tiktoken format: one base64 token and
+// its rank per line.
+func loadBPE(name string, r io.Reader, pattern splitPattern) (*BPE, error) {
+	ranks := map[string]int{}
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" {
+			continue
+		}
+		token, rankStr, ok := strings.Cut(text, " ")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected token and rank", line)
+		}
+		raw, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rank, err := strconv.Atoi(rankStr)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		ranks[string(raw)] = rank
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("no ranks")
+	}
+	return &BPE{name: name, ranks: ranks, pattern: pattern}, nil
+}
+
+func (b *BPE) Name() string { return b.name }
+
+// Count returns the number of tokens text encodes to. Special tokens such
+// as <|endoftext|> are counted as ordinary text.
+func (b *BPE) Count(text string) int {
+	n := 0
+	b.pattern.pieces(text, func(piece string) {
+		n += b.countPiece(piece)
+	})
+	return n
+}
+
+// countPiece merges the bytes of piece, lowest rank first, until no
+// adjacent pair is a known token, and returns the number of parts left.
+func (b *BPE) countPiece(piece string) int {
+	if _, ok := b.ranks[piece]; ok {
+		return 1
+	}
+	bounds := make([]int, len(piece)+1)
+	for i := range bounds {
+		bounds[i] = i
+	}
+	for len(bounds) > 2 {
+		best, bestRank := -1, math.MaxInt
+		for i := 0; i+2 < len(bounds); i++ {
+			if rank, ok := b.ranks[piece[bounds[i]:bounds[i+2]]]; ok && rank < bestRank {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		bounds = append(bounds[:best+1], bounds[best+2:]...)
+	}
+	return len(bounds) - 1
+}
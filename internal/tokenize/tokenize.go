@@ -0,0 +1,169 @@
+// Package tokenize counts tokens for the --max-tokens chunk limit,
+// chunks.jsonl windows and token estimates in index.jsonl and corpus.jsonl.
+// Approx is the original 4-characters-per-token estimate; Cl100k and O200k
+// are the tiktoken byte pair encodings of the OpenAI models, whose merge
+// ranks are downloaded once to a directory the caller picks.
+package tokenize
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go_scrap/internal/fetch"
+	"go_scrap/internal/fsutil"
+)
+
+const (
+	Approx = "approx"
+	Cl100k = "cl100k"
+	O200k  = "o200k"
+)
+
+// Tokenizer counts the tokens text encodes to.
+type Tokenizer interface {
+	Name() string
+	Count(text string) int
+}
+
+type encoding struct {
+	file    string
+	url     string
+	sha256  string
+	pattern splitPattern
+}
+
+var encodings = map[string]encoding{
+	Cl100k: {
+		file:    "cl100k_base.tiktoken",
+		url:     "https://openaipublic.blob.core.windows.net/encodings/cl100k_base.tiktoken",
+		sha256:  "223921b76ee99bde995b7ff738513eef100fb51d18c93597a113bcffe865b2a7",
+		pattern: cl100kPattern,
+	},
+	O200k: {
+		file:    "o200k_base.tiktoken",
+		url:     "https://openaipublic.blob.core.windows.net/encodings/o200k_base.tiktoken",
+		sha256:  "446a9538cb6c348e3516120d7c08b09f57c36495e2acfffe59a5bf8b0cfb1a2d",
+		pattern: o200kPattern,
+	},
+}
+
+var (
+	loadedMu sync.Mutex
+	loaded   = map[string]*BPE{}
+)
+
+// Validate reports whether name is a known tokenizer without loading it.
+func Validate(name string) error {
+	switch name {
+	case "", Approx, Cl100k, O200k:
+		return nil
+	}
+	return fmt.Errorf("unknown tokenizer %q (expected cl100k, o200k or approx)", name)
+}
+
+// New returns the named tokenizer; "" is Approx. BPE encodings are read
+// from dir, downloaded there first if missing, and shared between calls.
+func New(ctx context.Context, name, dir string) (Tokenizer, error) {
+	if err := Validate(name); err != nil {
+		return nil, err
+	}
+	enc, ok := encodings[name]
+	if !ok {
+		return ApproxTokenizer{}, nil
+	}
+	loadedMu.Lock()
+	defer loadedMu.Unlock()
+	if bpe, ok := loaded[name]; ok {
+		return bpe, nil
+	}
+	path, err := ensureRanks(ctx, enc, dir)
+	if err != nil {
+		return nil, fmt.Errorf("tokenizer %s: %w", name, err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("tokenizer %s: %w", name, err)
+	}
+	defer f.Close()
+	bpe, err := loadBPE(name, f, enc.pattern)
+	if err != nil {
+		return nil, fmt.Errorf("tokenizer %s: %s: %w", name, path, err)
+	}
+	loaded[name] = bpe
+	return bpe, nil
+}
+
+// Or returns tok, or Approx when tok is nil.
+func Or(tok Tokenizer) Tokenizer {
+	if tok == nil {
+		return ApproxTokenizer{}
+	}
+	return tok
+}
+
+// ApproxTokenizer estimates one token per 4 bytes of text, rounded down.
+type ApproxTokenizer struct{}
+
+func (ApproxTokenizer) Name() string { return Approx }
+
+func (ApproxTokenizer) Count(text string) int {
+	return len(text) / 4
+}
+
+// Dir is where BPE rank files are kept under a cache directory.
+func Dir(cacheDir string) string {
+	return filepath.Join(cacheDir, "tokenizers")
+}
+
+// ensureRanks returns the path of enc's rank file in dir, downloading and
+// checking it against its published SHA-256 when it is not there yet. The
+// download goes through the transport attached to ctx (see
+// fetch.WithTransport).
+func ensureRanks(ctx context.Context, enc encoding, dir string) (string, error) {
+	path := filepath.Join(dir, enc.file)
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, enc.url, nil)
+	if err != nil {
+		return "", err
+	}
+	client := &http.Client{Transport: fetch.TransportFrom(ctx)}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("download %s (or place %s in %s): %w", enc.url, enc.file, dir, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download %s: %s", enc.url, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("download %s: %w", enc.url, err)
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != enc.sha256 {
+		return "", fmt.Errorf("download %s: sha256 %s does not match %s", enc.url, got, enc.sha256)
+	}
+	if err := fsutil.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	tmp := path + ".tmp"
+	if err := fsutil.WriteFile(tmp, data, 0644); err != nil {
+		return "", err
+	}
+	if err := fsutil.Rename(tmp, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
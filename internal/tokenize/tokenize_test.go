@@ -0,0 +1,140 @@
+package tokenize
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go_scrap/internal/fetch"
+)
+
+func TestPieces_MatchTiktokenSplits(t *testing.T) {
+	cases := []struct {
+		pattern splitPattern
+		in      string
+		want    []string
+	}{
+		{cl100kPattern, "Hello world", []string{"Hello", " world"}},
+		{cl100kPattern, "don't  stop", []string{"don", "'t", " ", " stop"}},
+		{cl100kPattern, "x = 12345;\n\n", []string{"x", " =", " ", "123", "45", ";\n\n"}},
+		{cl100kPattern, "end   ", []string{"end", "   "}},
+		{cl100kPattern, "a  b", []string{"a", " ", " b"}},
+		{o200kPattern, "HelloWorld path/to", []string{"Hello", "World", " path", "/to"}},
+	}
+	for _, tc := range cases {
+		var got []string
+		tc.pattern.pieces(tc.in, func(p string) { got = append(got, p) })
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("pieces(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestBPE_CountMergesLowestRankFirst(t *testing.T) {
+	bpe := testBPE(t, "ab", "bc", "abc", " a")
+	cases := map[string]int{
+		"":       0,
+		"abc":    1,
+		"abcd":   2, // "abc" + "d"
+		"bca":    2, // "bc" + "a"
+		"abc ab": 3, // "abc" + " a" + "b"
+	}
+	for in, want := range cases {
+		if got := bpe.Count(in); got != want {
+			t.Errorf("Count(%q) = %d, want %d", in, got, want)
+		}
+	}
+}
+
+func TestNew_LoadsRanksFromDir(t *testing.T) {
+	dir := Dir(t.TempDir())
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "o200k_base.tiktoken"), []byte(rankFile("he", "llo", "hello")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tok, err := New(context.Background(), O200k, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.Name() != O200k || tok.Count("hello") != 1 {
+		t.Fatalf("got %s counting %d tokens", tok.Name(), tok.Count("hello"))
+	}
+	if _, err := New(context.Background(), "gpt2", dir); err == nil {
+		t.Fatal("expected an error for an unknown tokenizer")
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestEnsureRanks_DownloadsThroughContextTransport(t *testing.T) {
+	ranks := rankFile("he", "llo")
+	sum := sha256.Sum256([]byte(ranks))
+	enc := encoding{file: "test.tiktoken", url: "https://ranks.invalid/test.tiktoken", sha256: hex.EncodeToString(sum[:])}
+	var requested string
+	rt := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requested = req.URL.String()
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(ranks)), Request: req}, nil
+	})
+
+	dir := t.TempDir()
+	path, err := ensureRanks(fetch.WithTransport(context.Background(), rt), enc, dir)
+	if err != nil {
+		t.Fatalf("ensureRanks: %v", err)
+	}
+	if requested != enc.url {
+		t.Fatalf("expected the download to use the context transport, got %q", requested)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != ranks {
+		t.Fatalf("unexpected rank file: %q %v", data, err)
+	}
+}
+
+func TestApprox(t *testing.T) {
+	tok, err := New(context.Background(), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tok.Count("héllo world"); got != 3 {
+		t.Fatalf("Count = %d, want 3", got)
+	}
+	if Or(nil).Name() != Approx {
+		t.Fatal("Or(nil) should be approx")
+	}
+}
+
+func testBPE(t *testing.T, merges ...string) *BPE {
+	t.Helper()
+	bpe, err := loadBPE("test", strings.NewReader(rankFile(merges...)), cl100kPattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bpe
+}
+
+// rankFile ranks every single byte, then merges in order.
+func rankFile(merges ...string) string {
+	var b strings.Builder
+	rank := 0
+	for c := 0; c < 256; c++ {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(c)}), rank)
+		rank++
+	}
+	for _, m := range merges {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(m)), rank)
+		rank++
+	}
+	return b.String()
+}
@@ -0,0 +1,236 @@
+package tui
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/charmbracelet/huh"
+
+	"go_scrap/internal/config"
+)
+
+const historyLimit = 20
+
+// HistoryEntry records one TUI-driven run so it can be repeated later.
+type HistoryEntry struct {
+	URL         string        `json:"url"`
+	OptionsHash string        `json:"options_hash"`
+	OutputDir   string        `json:"output_dir,omitempty"`
+	ConfigPath  string        `json:"config_path,omitempty"`
+	Result      string        `json:"result"`
+	StartedAt   time.Time     `json:"started_at"`
+	Duration    time.Duration `json:"duration_ns"`
+	Config      config.Config `json:"config"`
+	// Credentials reports that the run used credentials left out of Config.
+	Credentials bool `json:"credentials,omitempty"`
+}
+
+// HistoryPath is where TUI runs are recorded: history.json under
+// config.UserDir (e.g. ~/.config/go_scrap/history.json).
+func HistoryPath() (string, error) {
+	dir, err := config.UserDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "history.json"), nil
+}
+
+func LoadHistory(path string) ([]HistoryEntry, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []HistoryEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// RecordRun prepends a run to the history file, replacing any earlier entry
+// with the same options and keeping the newest historyLimit entries.
+// Credentials are never stored; a replayed run reads them back from its
+// config file or asks for them again.
+func RecordRun(path string, res Result, startedAt time.Time, runErr error) error {
+	entries, err := LoadHistory(path)
+	if err != nil {
+		return err
+	}
+	cfg, stripped := withoutCredentials(res.Config)
+	hash, err := configHash(cfg)
+	if err != nil {
+		return err
+	}
+	entry := HistoryEntry{
+		URL:         res.Options.URL,
+		OptionsHash: hash,
+		OutputDir:   res.Options.OutputDir,
+		Result:      "ok",
+		StartedAt:   startedAt,
+		Duration:    time.Since(startedAt),
+		Config:      cfg,
+		Credentials: stripped,
+	}
+	if res.SaveConfig {
+		entry.ConfigPath = res.ConfigPath
+	}
+	if runErr != nil {
+		entry.Result = "error: " + runErr.Error()
+	}
+
+	out := []HistoryEntry{entry}
+	for _, e := range entries {
+		if e.OptionsHash == hash {
+			continue
+		}
+		out = append(out, e)
+		if len(out) == historyLimit {
+			break
+		}
+	}
+
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// withoutCredentials returns cfg without the proxy's user:pass@, the auth
+// headers and cookies and the login password, and whether it had any.
+func withoutCredentials(cfg config.Config) (config.Config, bool) {
+	stripped := false
+	if proxy := withoutUserinfo(cfg.ProxyURL); proxy != cfg.ProxyURL {
+		cfg.ProxyURL = proxy
+		stripped = true
+	}
+	if len(cfg.AuthHeaders) > 0 || len(cfg.AuthCookies) > 0 {
+		cfg.AuthHeaders, cfg.AuthCookies = nil, nil
+		stripped = true
+	}
+	if cfg.Login != nil && cfg.Login.Password != "" {
+		login := *cfg.Login
+		login.Password = ""
+		cfg.Login = &login
+		stripped = true
+	}
+	return cfg, stripped
+}
+
+// restoreCredentials reads the credentials withoutCredentials removed from
+// cfg back from the config file at path.
+func restoreCredentials(cfg config.Config, path string) (config.Config, bool) {
+	if path == "" {
+		return cfg, false
+	}
+	saved, err := config.Load(path)
+	if err != nil {
+		return cfg, false
+	}
+	if saved.ProxyURL != "" && withoutUserinfo(saved.ProxyURL) == cfg.ProxyURL {
+		cfg.ProxyURL = saved.ProxyURL
+	}
+	cfg.AuthHeaders, cfg.AuthCookies = saved.AuthHeaders, saved.AuthCookies
+	if cfg.Login != nil && saved.Login != nil {
+		login := *cfg.Login
+		login.Password = saved.Login.Password
+		cfg.Login = &login
+	}
+	return cfg, true
+}
+
+func configHash(cfg config.Config) (string, error) {
+	data, err := config.Marshal(cfg)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// pickRecentRun offers recent runs at startup. It returns "rerun" or "tweak"
+// after loading the chosen entry into state, or "" to continue normally.
+func pickRecentRun(state *formState) (string, error) {
+	path, err := HistoryPath()
+	if err != nil {
+		// Without a config dir there is no history to offer.
+		return "", nil
+	}
+	entries, err := LoadHistory(path)
+	if err != nil {
+		// A broken history file should not block the TUI.
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return "", nil
+	}
+	if len(entries) == 0 {
+		return "", nil
+	}
+
+	opts := []huh.Option[int]{huh.NewOption("New run / manage configs", -1)}
+	for i, e := range entries {
+		opts = append(opts, huh.NewOption(historyLabel(e), i))
+	}
+	selected := -1
+	if err := runForm(state.plain, huh.NewForm(huh.NewGroup(
+		huh.NewSelect[int]().
+			Title("Recent runs").
+			Description("Re-run or tweak a previous job.").
+			Options(opts...).
+			Value(&selected),
+	))); err != nil {
+		return "", err
+	}
+	if selected < 0 {
+		return "", nil
+	}
+
+	action := "rerun"
+	if err := runForm(state.plain, huh.NewForm(huh.NewGroup(
+		huh.NewSelect[string]().
+			Title(entries[selected].URL).
+			Options(
+				huh.NewOption("Re-run now", "rerun"),
+				huh.NewOption("Tweak in form", "tweak"),
+			).
+			Value(&action),
+	))); err != nil {
+		return "", err
+	}
+
+	entry := entries[selected]
+	cfg := entry.Config
+	if entry.Credentials {
+		restored, ok := restoreCredentials(cfg, entry.ConfigPath)
+		if !ok {
+			fmt.Fprintln(os.Stderr, "This run used credentials that are not kept in history; enter them again in the form.")
+			action = "tweak"
+		}
+		cfg = restored
+	}
+	state.fromConfig(cfg)
+	if entry.ConfigPath != "" {
+		state.configPath = entry.ConfigPath
+	}
+	state.finalAction = "run"
+	return action, nil
+}
+
+func historyLabel(e HistoryEntry) string {
+	result := e.Result
+	if len(result) > 40 {
+		result = result[:40] + "..."
+	}
+	return fmt.Sprintf("%s  (%s, %s, %s)", e.URL, e.StartedAt.Local().Format("2006-01-02 15:04"), e.Duration.Round(time.Second), result)
+}
@@ -0,0 +1,94 @@
+// Package warnings collects the problems a run worked around so they can be
+// written to run.json and the crawl index alongside the usual stderr output.
+package warnings
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Warning codes. Automation should match on these rather than on messages.
+const (
+	CodeAssetDownload    = "asset_download_failed"
+	CodeSelectorFallback = "selector_fallback"
+	CodePageSkipped      = "page_skipped"
+	CodePageFailed       = "page_failed"
+	CodePageClassified   = "page_classified"
+	CodeOutputWrite      = "output_write_failed"
+	CodeMaxPages         = "max_pages_reached"
+	CodeViewFallback     = "view_fallback"
+)
+
+// Warning is one structured warning. Context holds code-specific details
+// such as the selector that matched nothing or the file that failed.
+type Warning struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	URL     string            `json:"url,omitempty"`
+	Context map[string]string `json:"context,omitempty"`
+}
+
+// Collector records warnings and echoes each one to its writer as
+// "Warning: <message>". A nil *Collector is valid and records nothing.
+type Collector struct {
+	mu    sync.Mutex
+	out   io.Writer
+	items []Warning
+}
+
+// New returns a collector that echoes to out (nil for silence).
+func New(out io.Writer) *Collector {
+	return &Collector{out: out}
+}
+
+type ctxKey struct{}
+
+// WithCollector attaches c to ctx so code deep in the pipeline can report.
+func WithCollector(ctx context.Context, c *Collector) context.Context {
+	return context.WithValue(ctx, ctxKey{}, c)
+}
+
+// From returns the collector attached to ctx, or nil.
+func From(ctx context.Context) *Collector {
+	if ctx == nil {
+		return nil
+	}
+	c, _ := ctx.Value(ctxKey{}).(*Collector)
+	return c
+}
+
+// Report records w on the collector attached to ctx, or only prints it to
+// stderr when there is none.
+func Report(ctx context.Context, w Warning) {
+	if c := From(ctx); c != nil {
+		c.Add(w)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Warning: %s\n", w.Message)
+}
+
+// Add records w and echoes it.
+func (c *Collector) Add(w Warning) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items = append(c.items, w)
+	if c.out != nil {
+		fmt.Fprintf(c.out, "Warning: %s\n", w.Message)
+	}
+}
+
+// List returns a copy of the warnings recorded so far, in order.
+func (c *Collector) List() []Warning {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Warning(nil), c.items...)
+}
@@ -0,0 +1,31 @@
+package warnings
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestCollector_RecordsAndEchoes(t *testing.T) {
+	var buf bytes.Buffer
+	c := New(&buf)
+	ctx := WithCollector(context.Background(), c)
+
+	Report(ctx, Warning{Code: CodePageSkipped, Message: "skipping https://example.com/a: too short", URL: "https://example.com/a"})
+	Report(ctx, Warning{Code: CodeOutputWrite, Message: "failed to write index.html: disk full"})
+
+	got := c.List()
+	if len(got) != 2 || got[0].Code != CodePageSkipped || got[1].Code != CodeOutputWrite {
+		t.Fatalf("unexpected warnings %+v", got)
+	}
+	want := "Warning: skipping https://example.com/a: too short\nWarning: failed to write index.html: disk full\n"
+	if buf.String() != want {
+		t.Fatalf("echo = %q, want %q", buf.String(), want)
+	}
+
+	var nilCollector *Collector
+	nilCollector.Add(Warning{Code: CodePageFailed})
+	if nilCollector.List() != nil {
+		t.Fatal("nil collector should record nothing")
+	}
+}
@@ -0,0 +1,325 @@
+// Package goscrap runs the go_scrap pipeline from Go programs: fetch a page
+// or crawl a site, split it into sections and convert them to Markdown,
+// without shelling out to the CLI. Results are returned in memory; nothing
+// is written or printed.
+//
+// The types here are the stable API. They mirror the CLI flags of the same
+// names, and are kept compatible as the internal packages change.
+package goscrap
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"go_scrap/internal/app"
+	"go_scrap/internal/byline"
+	"go_scrap/internal/fetch"
+	"go_scrap/internal/warnings"
+)
+
+// Mode selects how pages are fetched.
+type Mode string
+
+const (
+	// ModeAuto fetches statically and falls back to a browser for pages that
+	// need JavaScript.
+	ModeAuto Mode = "auto"
+	// ModeStatic only uses plain HTTP requests.
+	ModeStatic Mode = "static"
+	// ModeDynamic renders every page in a headless browser.
+	ModeDynamic Mode = "dynamic"
+)
+
+// Options control fetching and extraction. The zero value uses the CLI
+// defaults.
+type Options struct {
+	Mode      Mode
+	Timeout   time.Duration
+	UserAgent string
+	// WaitFor is a selector a browser fetch waits for.
+	WaitFor string
+	// Headed shows the browser window; browser fetches run headless by
+	// default, as in the CLI.
+	Headed bool
+	// Browser is the engine of browser fetches: "chromium" (default),
+	// "firefox" or "webkit".
+	Browser string
+	// ScrollToBottom scrolls a browser fetch to the bottom, and
+	// ClickSelector clicks a "load more" element, until no more content
+	// loads: for at most MaxScrolls rounds, each waiting until the page
+	// height holds still for ScrollQuiet (0 uses the defaults).
+	ScrollToBottom bool
+	ClickSelector  string
+	MaxScrolls     int
+	ScrollQuiet    time.Duration
+	// RateLimit is in requests per second; 0 uses the default.
+	RateLimit float64
+	ProxyURL  string
+	Headers   map[string]string
+	Cookies   map[string]string
+	// UseCache reads and writes fetched HTML under CacheDir (default:
+	// $GO_SCRAP_CACHE_DIR or the user cache dir).
+	UseCache bool
+	CacheDir string
+
+	ContentSelector string
+	ExcludeSelector string
+	// Items splits the page into one section per repeated element instead
+	// of by headings.
+	Items ItemSelectors
+	// IncludeHeadings and ExcludeHeadings are regular expressions matched
+	// against section headings.
+	IncludeHeadings   string
+	ExcludeHeadings   string
+	MaxSections       int
+	DropEmptySections bool
+	// Since and Until keep sections dated inside the window (YYYY-MM-DD or
+	// RFC 3339).
+	Since string
+	Until string
+	// Preset, Sanitize and Slug take the names the CLI accepts.
+	Preset           string
+	Sanitize         string
+	Slug             string
+	NormalizeUnicode bool
+	// Citation is "section" or "page" to end the Markdown of every section,
+	// or of the whole page, with a footer such as "Source: <url> (fetched
+	// <date>)". CitationTemplate overrides the footer; it may use {url},
+	// {section_url}, {heading} and {date}.
+	Citation         string
+	CitationTemplate string
+
+	// Middleware wraps every fetch; the first one is outermost.
+	Middleware []Middleware
+}
+
+// ItemSelectors drive item mode. Title, Body and Date are matched inside
+// each item.
+type ItemSelectors struct {
+	Item  string
+	Title string
+	Body  string
+	Date  string
+}
+
+// CrawlOptions control Crawl.
+type CrawlOptions struct {
+	Options
+	// MaxPages and MaxDepth of 0 use the defaults.
+	MaxPages int
+	MaxDepth int
+	// Filter is a regular expression crawled URLs must match.
+	Filter string
+	// Sitemap is a sitemap.xml URL whose pages are crawled too.
+	Sitemap string
+	// MinPageChars and MaxPageChars skip pages by text length.
+	MinPageChars int
+	MaxPageChars int
+	// SoftPages is keep, drop or retry-dynamic for soft 404s, login walls
+	// and pages that need JavaScript.
+	SoftPages string
+}
+
+// Middleware wraps fetching, e.g. to sign, log or mock requests.
+type Middleware struct {
+	Name string
+	// RoundTrip wraps the transport of static fetches, crawl requests and
+	// sitemaps.
+	RoundTrip func(next http.RoundTripper) http.RoundTripper
+	// BeforeNavigate runs before a browser loads pageURL and may change the
+	// extra request headers.
+	BeforeNavigate func(ctx context.Context, pageURL string, headers map[string]string) error
+	// AfterContent receives the fetched HTML and returns the HTML to use.
+	AfterContent func(ctx context.Context, pageURL, html string) (string, error)
+}
+
+// Page is one extracted page.
+type Page struct {
+	URL string
+	// Published and Modified are zero when the page carries no dates.
+	Published time.Time
+	Modified  time.Time
+	// Authors and Contributors come from JSON-LD, meta tags, rel="author"
+	// links and visible bylines.
+	Authors      []Person
+	Contributors []Person
+	Sections     []Section
+	// Markdown is the whole page, as content.md holds it.
+	Markdown string
+	Warnings []Warning
+
+	// SkipReason and Err are set on crawled pages that were skipped or
+	// failed; such pages have no sections.
+	SkipReason string
+	Err        error
+}
+
+// Section is one heading (or item) and its content.
+type Section struct {
+	ID      string
+	Heading string
+	Level   int
+	// HTML and Text are the section's content without its heading.
+	HTML     string
+	Text     string
+	Markdown string
+	// Date is the section's date ("" when undated).
+	Date string
+	// Authors are the section's own byline in item mode, or else the
+	// page's author names.
+	Authors []string
+}
+
+// Person is an author or contributor; URL is their profile page, if linked.
+type Person struct {
+	Name string
+	URL  string
+}
+
+// Warning is a problem worked around while extracting a page.
+type Warning struct {
+	// Code is stable, e.g. "selector_fallback"; Message is for people.
+	Code    string
+	Message string
+	URL     string
+}
+
+// Scrape fetches url and extracts it.
+func Scrape(ctx context.Context, url string, opts Options) (*Page, error) {
+	page, err := app.Scrape(ctx, appOptions(url, opts))
+	if err != nil {
+		return nil, err
+	}
+	return fromAppPage(page), nil
+}
+
+// Extract extracts html, already fetched from url. url is used to resolve
+// relative links and detect presets.
+func Extract(ctx context.Context, url, html string, opts Options) (*Page, error) {
+	page, err := app.Extract(ctx, appOptions(url, opts), html)
+	if err != nil {
+		return nil, err
+	}
+	return fromAppPage(page), nil
+}
+
+// Crawl crawls from url (which may be empty with a Sitemap) and calls fn
+// with every page, in URL order, once the crawl finishes. Skipped and
+// failed pages are passed too. An error from fn stops Crawl and is returned.
+func Crawl(ctx context.Context, url string, opts CrawlOptions, fn func(*Page) error) error {
+	o := appOptions(url, opts.Options)
+	o.MaxPages = opts.MaxPages
+	o.CrawlDepth = opts.MaxDepth
+	o.CrawlFilter = opts.Filter
+	o.SitemapURL = opts.Sitemap
+	o.MinPageChars = opts.MinPageChars
+	o.MaxPageChars = opts.MaxPageChars
+	o.SoftPages = opts.SoftPages
+	return app.CrawlPages(ctx, o, func(page *app.Page) error {
+		return fn(fromAppPage(page))
+	})
+}
+
+func appOptions(url string, opts Options) app.Options {
+	o := app.Options{
+		URL:                strings.TrimSpace(url),
+		Mode:               fetch.Mode(opts.Mode),
+		Timeout:            opts.Timeout,
+		UserAgent:          opts.UserAgent,
+		WaitFor:            opts.WaitFor,
+		Headless:           !opts.Headed,
+		Browser:            fetch.Browser(opts.Browser),
+		ScrollToBottom:     opts.ScrollToBottom,
+		ClickSelector:      opts.ClickSelector,
+		MaxScrolls:         opts.MaxScrolls,
+		ScrollQuiet:        opts.ScrollQuiet,
+		RateLimitPerSecond: opts.RateLimit,
+		ProxyURL:           opts.ProxyURL,
+		AuthHeaders:        opts.Headers,
+		AuthCookies:        opts.Cookies,
+		UseCache:           opts.UseCache,
+		CacheDir:           opts.CacheDir,
+		ContentSelector:    opts.ContentSelector,
+		ExcludeSelector:    opts.ExcludeSelector,
+		ItemSelector:       opts.Items.Item,
+		ItemTitleSelector:  opts.Items.Title,
+		ItemBodySelector:   opts.Items.Body,
+		ItemDateSelector:   opts.Items.Date,
+		IncludeHeadings:    opts.IncludeHeadings,
+		ExcludeHeadings:    opts.ExcludeHeadings,
+		MaxSections:        opts.MaxSections,
+		DropEmptySections:  opts.DropEmptySections,
+		Since:              opts.Since,
+		Until:              opts.Until,
+		Preset:             opts.Preset,
+		Sanitize:           opts.Sanitize,
+		Slug:               opts.Slug,
+		NormalizeUnicode:   opts.NormalizeUnicode,
+		Citation:           opts.Citation,
+		CitationTemplate:   opts.CitationTemplate,
+	}
+	for _, m := range opts.Middleware {
+		o.Middleware = append(o.Middleware, fetch.Middleware{
+			Name:           m.Name,
+			RoundTrip:      m.RoundTrip,
+			BeforeNavigate: m.BeforeNavigate,
+			AfterContent:   m.AfterContent,
+		})
+	}
+	return o
+}
+
+func fromAppPage(p *app.Page) *Page {
+	page := &Page{
+		URL:        p.URL,
+		Markdown:   p.Markdown,
+		Warnings:   fromWarnings(p.Warnings),
+		SkipReason: p.SkipReason,
+		Err:        p.Err,
+	}
+	if p.Doc == nil {
+		return page
+	}
+	page.Published = p.Doc.Dates.Published
+	page.Modified = p.Doc.Dates.Modified
+	page.Authors = fromPeople(p.Doc.Byline.Authors)
+	page.Contributors = fromPeople(p.Doc.Byline.Contributors)
+	page.Sections = make([]Section, len(p.Doc.Sections))
+	for i, s := range p.Doc.Sections {
+		page.Sections[i] = Section{
+			ID:       s.HeadingID,
+			Heading:  s.HeadingText,
+			Level:    s.HeadingLevel,
+			HTML:     s.ContentHTML,
+			Text:     s.ContentText,
+			Markdown: p.SectionMarkdown[i],
+			Date:     s.Date,
+			Authors:  s.Authors,
+		}
+	}
+	return page
+}
+
+func fromPeople(people []byline.Person) []Person {
+	if len(people) == 0 {
+		return nil
+	}
+	out := make([]Person, len(people))
+	for i, p := range people {
+		out[i] = Person{Name: p.Name, URL: p.URL}
+	}
+	return out
+}
+
+func fromWarnings(ws []warnings.Warning) []Warning {
+	if len(ws) == 0 {
+		return nil
+	}
+	out := make([]Warning, len(ws))
+	for i, w := range ws {
+		out[i] = Warning{Code: w.Code, Message: w.Message, URL: w.URL}
+	}
+	return out
+}
@@ -0,0 +1,12 @@
+package goscrap
+
+import "testing"
+
+func TestAppOptions_HeadlessByDefault(t *testing.T) {
+	if o := appOptions("https://example.com", Options{}); !o.Headless {
+		t.Fatal("expected the zero Options to run the browser headless")
+	}
+	if o := appOptions("https://example.com", Options{Headed: true}); o.Headless {
+		t.Fatal("expected Headed to show the browser")
+	}
+}
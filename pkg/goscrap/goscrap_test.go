@@ -0,0 +1,103 @@
+package goscrap_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go_scrap/pkg/goscrap"
+)
+
+func TestScrape_ReturnsSectionsWithoutWriting(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><head><meta property="article:published_time" content="2024-03-01"></head><body>
+<main><h1 id="intro">Intro</h1><p>Hello <b>world</b>.</p><h2 id="usage">Usage</h2><p>Run it.</p></main>
+<footer>Footer</footer></body></html>`))
+	}))
+	defer srv.Close()
+	t.Chdir(t.TempDir())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	page, err := goscrap.Scrape(ctx, srv.URL, goscrap.Options{Mode: goscrap.ModeStatic, ContentSelector: "main"})
+	if err != nil {
+		t.Fatalf("scrape: %v", err)
+	}
+	if len(page.Sections) != 2 || page.Sections[0].ID != "intro" || page.Sections[1].Heading != "Usage" {
+		t.Fatalf("expected the intro and usage sections, got %+v", page.Sections)
+	}
+	if !strings.Contains(page.Sections[0].Markdown, "**world**") || !strings.Contains(page.Markdown, "## Usage") {
+		t.Fatalf("expected Markdown per section and for the page, got %q / %q", page.Sections[0].Markdown, page.Markdown)
+	}
+	if strings.Contains(page.Markdown, "Footer") {
+		t.Fatalf("expected the content selector to apply, got %q", page.Markdown)
+	}
+	if page.Published.Format("2006-01-02") != "2024-03-01" {
+		t.Fatalf("expected the published date, got %v", page.Published)
+	}
+	if entries, _ := os.ReadDir("."); len(entries) != 0 {
+		t.Fatalf("expected nothing to be written, found %v", entries)
+	}
+}
+
+func TestExtract_ItemsWithoutFetching(t *testing.T) {
+	html := `<html><body>
+<div class="release"><h3>v2</h3><time datetime="2024-05-01">May 1</time><p>New parser.</p></div>
+<div class="release"><h3>v1</h3><time datetime="2023-01-01">Jan 1</time><p>First.</p></div>
+</body></html>`
+	page, err := goscrap.Extract(context.Background(), "https://example.com/releases", html, goscrap.Options{
+		Items: goscrap.ItemSelectors{Item: ".release"},
+		Since: "2024-01-01",
+	})
+	if err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	if len(page.Sections) != 1 || page.Sections[0].Heading != "v2" || page.Sections[0].Date != "2024-05-01" {
+		t.Fatalf("expected only the dated v2 item, got %+v", page.Sections)
+	}
+}
+
+func TestCrawl_CallsBackForEveryPage(t *testing.T) {
+	mux := http.NewServeMux()
+	page := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(body))
+		}
+	}
+	mux.HandleFunc("/", page(`<html><body><h1>Home</h1><p>Start.</p><a href="/a">A</a></body></html>`))
+	mux.HandleFunc("/a", page(`<html><body><h1>Page A</h1><p>Content.</p></body></html>`))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	t.Chdir(t.TempDir())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var headings []string
+	err := goscrap.Crawl(ctx, srv.URL, goscrap.CrawlOptions{
+		Options:  goscrap.Options{Mode: goscrap.ModeStatic, RateLimit: 50},
+		MaxPages: 5,
+		MaxDepth: 2,
+	}, func(p *goscrap.Page) error {
+		if p.Err != nil || p.SkipReason != "" {
+			t.Errorf("unexpected skip of %s: %v %s", p.URL, p.Err, p.SkipReason)
+			return nil
+		}
+		headings = append(headings, p.Sections[0].Heading)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("crawl: %v", err)
+	}
+	if strings.Join(headings, ",") != "Home,Page A" {
+		t.Fatalf("expected both pages in URL order, got %v", headings)
+	}
+	if entries, _ := os.ReadDir("."); len(entries) != 0 {
+		t.Fatalf("expected nothing to be written, found %v", entries)
+	}
+}